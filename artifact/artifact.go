@@ -0,0 +1,167 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+// Package artifact uploads the files produced by sessions to object storage,
+// i.e. Amazon S3, or compatible services, and Google Cloud Storage.
+package artifact
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Store is a bucket of an object storage service.
+type Store interface {
+	// Put uploads the "size" bytes read from "r" as object "key", returning
+	// its URL.
+	Put(ctx context.Context, key string, r io.Reader, size int64) (string, error)
+}
+
+// Destination is the location artifacts are uploaded to, e.g.
+// "s3://bucket/prefix" or "gs://bucket/prefix".
+type Destination struct {
+	Store Store
+	// Prefix is prepended to the keys of the objects, without slashes
+	// at its ends.
+	Prefix string
+}
+
+// Parse returns the destination described by "rawurl", which has to use the
+// "s3" or the "gs" scheme. Credentials are read from the environment when
+// uploading, see S3 and GCS.
+func Parse(rawurl string) (*Destination, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid artifacts destination %q: %w", rawurl, err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid artifacts destination %q: bucket not set", rawurl)
+	}
+	var s Store
+	switch u.Scheme {
+	case "s3":
+		s = &S3{Bucket: u.Host}
+	case "gs":
+		s = &GCS{Bucket: u.Host}
+	default:
+		return nil, fmt.Errorf("invalid artifacts destination %q: unsupported scheme %q", rawurl, u.Scheme)
+	}
+	return &Destination{Store: s, Prefix: strings.Trim(u.Path, "/")}, nil
+}
+
+// Key returns the key of the object storing file "rel" of session "sid".
+func (d *Destination) Key(sid, rel string) string {
+	return path.Join(d.Prefix, sid, filepath.ToSlash(rel))
+}
+
+// ValidatePattern reports whether "pattern" is a valid glob, relative to the
+// working directory and not escaping it.
+func ValidatePattern(pattern string) error {
+	if pattern == "" || filepath.IsAbs(pattern) {
+		return fmt.Errorf("invalid artifact pattern %q: has to be relative to the working directory", pattern)
+	}
+	for _, v := range strings.Split(filepath.ToSlash(pattern), "/") {
+		if v == ".." {
+			return fmt.Errorf("invalid artifact pattern %q: escapes the working directory", pattern)
+		}
+	}
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid artifact pattern %q: %w", pattern, err)
+	}
+	return nil
+}
+
+// Collect returns the regular files of "dir" matching any of "patterns",
+// relative to "dir" and sorted.
+func Collect(dir string, patterns []string) ([]string, error) {
+	seen := map[string]bool{}
+	for _, p := range patterns {
+		if err := ValidatePattern(p); err != nil {
+			return nil, err
+		}
+		matches, err := filepath.Glob(filepath.Join(dir, p))
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range matches {
+			if info, err := os.Stat(v); err != nil || !info.Mode().IsRegular() {
+				continue
+			}
+			rel, err := filepath.Rel(dir, v)
+			if err != nil {
+				return nil, err
+			}
+			seen[rel] = true
+		}
+	}
+	acc := make([]string, 0, len(seen))
+	for k := range seen {
+		acc = append(acc, k)
+	}
+	sort.Strings(acc)
+	return acc, nil
+}
+
+// Upload uploads the files of "dir" matching "patterns" to "d", below the
+// prefix of session "sid". The URLs of the objects uploaded are returned, also
+// when a file cannot be uploaded, together with the error.
+func (d *Destination) Upload(ctx context.Context, sid, dir string, patterns []string) ([]string, error) {
+	files, err := Collect(dir, patterns)
+	if err != nil {
+		return nil, err
+	}
+	urls := make([]string, 0, len(files))
+	for _, v := range files {
+		u, err := d.put(ctx, d.Key(sid, v), filepath.Join(dir, v))
+		if err != nil {
+			return urls, fmt.Errorf("unable to upload artifact %s: %w", v, err)
+		}
+		urls = append(urls, u)
+	}
+	return urls, nil
+}
+
+func (d *Destination) put(ctx context.Context, key, path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	return d.Store.Put(ctx, key, f, info.Size())
+}
+
+// escapePath escapes the segments of object key "key", keeping its slashes.
+func escapePath(key string) string {
+	segs := strings.Split(key, "/")
+	for i, v := range segs {
+		segs[i] = escape(v)
+	}
+	return strings.Join(segs, "/")
+}
+
+// escape percent-encodes every byte of "s" but the unreserved characters of
+// RFC 3986, as required by the signatures of the storage services.
+func escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-._~", c) >= 0 {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
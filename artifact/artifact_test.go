@@ -0,0 +1,143 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package artifact
+
+import (
+	"context"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	d, err := Parse("s3://media/pmux/artifacts/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, ok := d.Store.(*S3); !ok || s.Bucket != "media" || d.Prefix != "pmux/artifacts" {
+		t.Fatalf("unexpected destination: %+v", d)
+	}
+	if key := d.Key("pmux-a", "out/video.mp4"); key != "pmux/artifacts/pmux-a/out/video.mp4" {
+		t.Fatalf("unexpected key: %s", key)
+	}
+	if d, err = Parse("gs://media"); err != nil {
+		t.Fatal(err)
+	}
+	if key := d.Key("pmux-a", "video.mp4"); key != "pmux-a/video.mp4" {
+		t.Fatalf("unexpected key: %s", key)
+	}
+	for _, v := range []string{"ftp://media", "s3:///prefix", "/tmp/artifacts"} {
+		if _, err := Parse(v); err == nil {
+			t.Fatalf("%s SHOULD NOT be a valid destination", v)
+		}
+	}
+}
+
+func TestCollect(t *testing.T) {
+	t.Parallel()
+
+	dir, err := os.MkdirTemp("", "pmux-artifact-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, v := range []string{"a.mp4", "b.mp4", "out/c.mp4", "out/d.txt", "stdout"} {
+		path := filepath.Join(dir, v)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(v), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := Collect(dir, []string{"*.mp4", "out/*", "a.*", "missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a.mp4", "b.mp4", "out/c.mp4", "out/d.txt"}; !reflect.DeepEqual(files, want) {
+		t.Fatalf("wanted %v, found %v", want, files)
+	}
+	for _, v := range []string{"../*", "/etc/passwd", "[", ""} {
+		if _, err := Collect(dir, []string{v}); err == nil {
+			t.Fatalf("pattern %q SHOULD NOT be accepted", v)
+		}
+	}
+}
+
+func TestSigningKey(t *testing.T) {
+	t.Parallel()
+
+	// Example of the AWS Signature Version 4 documentation.
+	key := signingKey("wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "20120215", "us-east-1", "iam")
+	if s := hex.EncodeToString(key); s != "f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d" {
+		t.Fatalf("unexpected signing key: %s", s)
+	}
+}
+
+func TestDestination_Upload(t *testing.T) {
+	t.Parallel()
+
+	dir, err := os.MkdirTemp("", "pmux-artifact-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.WriteFile(filepath.Join(dir, "out put.txt"), []byte("done"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	objects := map[string]string{}
+	auth := map[string]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		objects[r.URL.EscapedPath()] = string(b)
+		auth[r.URL.EscapedPath()] = r.Header.Get("Authorization")
+	}))
+	defer srv.Close()
+
+	now := func() time.Time { return time.Date(2020, 1, 9, 3, 0, 0, 0, time.UTC) }
+	s3 := &S3{Bucket: "media", Region: "eu-central-1", Endpoint: srv.URL, AccessKeyID: "AKID", SecretAccessKey: "secret", now: now}
+	gcs := &GCS{Bucket: "media", Endpoint: srv.URL, Token: "ya29"}
+	for i, tt := range []struct {
+		store Store
+		url   string
+		path  string
+		auth  string
+	}{
+		{s3, "s3://media/jobs/pmux-a/out put.txt", "/media/jobs/pmux-a/out%20put.txt", "AWS4-HMAC-SHA256 Credential=AKID/20200109/eu-central-1/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature="},
+		{gcs, "gs://media/jobs/pmux-a/out put.txt", "/media/jobs/pmux-a/out%20put.txt", "Bearer ya29"},
+	} {
+		d := &Destination{Store: tt.store, Prefix: "jobs"}
+		urls, err := d.Upload(context.Background(), "pmux-a", dir, []string{"*.txt"})
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		if len(urls) != 1 || urls[0] != tt.url {
+			t.Fatalf("%d: unexpected urls: %v", i, urls)
+		}
+		mu.Lock()
+		if objects[tt.path] != "done" {
+			t.Fatalf("%d: object %s SHOULD have been uploaded: %v", i, tt.path, objects)
+		}
+		if !strings.HasPrefix(auth[tt.path], tt.auth) {
+			t.Fatalf("%d: unexpected authorization: %s", i, auth[tt.path])
+		}
+		mu.Unlock()
+	}
+}
@@ -0,0 +1,51 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package artifact
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// GCS is a bucket of Google Cloud Storage, accessed through its XML API.
+type GCS struct {
+	Bucket string
+	// Token is the OAuth 2.0 access token authenticating the requests.
+	// Defaults to $GOOGLE_OAUTH_ACCESS_TOKEN.
+	Token string
+	// Endpoint defaults to "https://storage.googleapis.com".
+	Endpoint string
+	// Client performs the requests. Defaults to "http.DefaultClient".
+	Client *http.Client
+}
+
+// Put implements Store.
+func (g *GCS) Put(ctx context.Context, key string, r io.Reader, size int64) (string, error) {
+	endpoint := g.Endpoint
+	if endpoint == "" {
+		endpoint = "https://storage.googleapis.com"
+	}
+	u := fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(endpoint, "/"), escape(g.Bucket), escapePath(key))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u, r)
+	if err != nil {
+		return "", err
+	}
+	req.ContentLength = size
+	if token := envOr(g.Token, "GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	client := g.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if err := do(client, req); err != nil {
+		return "", err
+	}
+	return "gs://" + g.Bucket + "/" + key, nil
+}
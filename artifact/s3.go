@@ -0,0 +1,154 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package artifact
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// S3 is a bucket of Amazon S3, or of a compatible service. Requests are signed
+// with the AWS Signature Version 4.
+type S3 struct {
+	Bucket string
+	// Region defaults to $AWS_REGION, then to "us-east-1".
+	Region string
+	// Endpoint, if set, addresses the bucket in the path style on a
+	// compatible service, e.g. "http://minio:9000". Defaults to
+	// $AWS_ENDPOINT_URL.
+	Endpoint string
+	// AccessKeyID, SecretAccessKey and SessionToken default to
+	// $AWS_ACCESS_KEY_ID, $AWS_SECRET_ACCESS_KEY and $AWS_SESSION_TOKEN.
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// Client performs the requests. Defaults to "http.DefaultClient".
+	Client *http.Client
+	// now returns the time requests are signed at, for testing.
+	now func() time.Time
+}
+
+// objectURL returns the URL of object "key".
+func (s *S3) objectURL(key string) (*url.URL, error) {
+	endpoint := envOr(s.Endpoint, "AWS_ENDPOINT_URL")
+	raw := fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s.Bucket, s.region(), escapePath(key))
+	if endpoint != "" {
+		raw = strings.TrimSuffix(endpoint, "/") + "/" + escape(s.Bucket) + "/" + escapePath(key)
+	}
+	return url.Parse(raw)
+}
+
+func (s *S3) region() string {
+	if r := envOr(s.Region, "AWS_REGION"); r != "" {
+		return r
+	}
+	return "us-east-1"
+}
+
+// Put implements Store.
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, size int64) (string, error) {
+	u, err := s.objectURL(key)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.String(), r)
+	if err != nil {
+		return "", err
+	}
+	req.ContentLength = size
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	s.sign(req, now().UTC())
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if err := do(client, req); err != nil {
+		return "", err
+	}
+	return "s3://" + s.Bucket + "/" + key, nil
+}
+
+// sign adds the AWS Signature Version 4 of "req", made at "t", to its headers.
+// The payload is not signed, so that it can be streamed.
+func (s *S3) sign(req *http.Request, t time.Time) {
+	const payload = "UNSIGNED-PAYLOAD"
+	amzDate := t.Format("20060102T150405Z")
+	date := t.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payload)
+	headers := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	values := []string{req.URL.Host, payload, amzDate}
+	if token := envOr(s.SessionToken, "AWS_SESSION_TOKEN"); token != "" {
+		req.Header.Set("X-Amz-Security-Token", token)
+		headers = append(headers, "x-amz-security-token")
+		values = append(values, token)
+	}
+	canonical := &strings.Builder{}
+	fmt.Fprintf(canonical, "%s\n%s\n%s\n", req.Method, req.URL.EscapedPath(), req.URL.RawQuery)
+	for i, v := range headers {
+		fmt.Fprintf(canonical, "%s:%s\n", v, values[i])
+	}
+	signed := strings.Join(headers, ";")
+	fmt.Fprintf(canonical, "\n%s\n%s", signed, payload)
+
+	scope := date + "/" + s.region() + "/s3/aws4_request"
+	hash := sha256.Sum256([]byte(canonical.String()))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+	key := signingKey(envOr(s.SecretAccessKey, "AWS_SECRET_ACCESS_KEY"), date, s.region(), "s3")
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		envOr(s.AccessKeyID, "AWS_ACCESS_KEY_ID"), scope, signed, signature))
+}
+
+// signingKey derives the key signing the requests made on "date" to "service"
+// in "region".
+func signingKey(secret, date, region, service string) []byte {
+	k := hmacSHA256([]byte("AWS4"+secret), date)
+	k = hmacSHA256(k, region)
+	k = hmacSHA256(k, service)
+	return hmacSHA256(k, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// envOr returns "v", or the environment variable "name" if empty.
+func envOr(v, name string) string {
+	if v != "" {
+		return v
+	}
+	return os.Getenv(name)
+}
+
+// do performs "req", reporting an error if the response is not successful.
+func do(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s returned %s: %s", req.URL.Host, resp.Status, strings.TrimSpace(string(b)))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
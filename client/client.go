@@ -0,0 +1,768 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+// Package client provides a typed client of the pmux server API, so that
+// services do not have to deal with its routes and payloads.
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kim-company/pmux/http/pmuxapi"
+	"github.com/kim-company/pmux/http/pwrapapi"
+	"github.com/kim-company/pmux/pwrap"
+)
+
+// Client talks to the "/api/v1" routes of a pmux server.
+type Client struct {
+	base      string
+	apiKey    string
+	token     string
+	namespace string
+	hc        *http.Client
+}
+
+// APIKey sets the key presented in the X-API-Key header.
+func APIKey(key string) func(*Client) {
+	return func(c *Client) {
+		c.apiKey = key
+	}
+}
+
+// BearerToken sets the token, e.g. a JWT, presented in the Authorization header.
+func BearerToken(token string) func(*Client) {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// Namespace selects the namespace of the sessions the client operates on. The
+// server's default namespace is used if empty.
+func Namespace(ns string) func(*Client) {
+	return func(c *Client) {
+		c.namespace = ns
+	}
+}
+
+// HTTPClient sets the HTTP client used to perform requests, which defaults to
+// "http.DefaultClient". Streams are only bounded by the client's timeout, if any.
+func HTTPClient(hc *http.Client) func(*Client) {
+	return func(c *Client) {
+		c.hc = hc
+	}
+}
+
+// New returns a client of the pmux server reachable at "addr", e.g.
+// "http://localhost:4002".
+func New(addr string, opts ...func(*Client)) *Client {
+	c := &Client{base: strings.TrimSuffix(addr, "/") + "/api/v1", hc: http.DefaultClient}
+	for _, f := range opts {
+		f(c)
+	}
+	return c
+}
+
+// Error is returned when the server answers with a non successful status.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("pmux: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// IsNotFound reports whether "err" is an "Error" caused by a missing session.
+func IsNotFound(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.StatusCode == http.StatusNotFound
+}
+
+// CreateRequest is the payload of a session creation.
+type CreateRequest struct {
+	// Exec selects the executable by name, from the server's whitelist. The
+	// server's default executable is used if empty.
+	Exec string `json:"exec,omitempty"`
+	// RegisterURL is the URL the session registers its API to.
+	RegisterURL string `json:"register_url,omitempty"`
+	// RegisterHeaders are attached to the registration and callback
+	// requests, e.g. the credentials of the receiver.
+	RegisterHeaders map[string]string `json:"register_headers,omitempty"`
+	// Config is the configuration passed to the session, encoded as JSON.
+	Config interface{} `json:"config"`
+	// Container, if set, runs the session inside a Docker container.
+	Container *pwrap.Container `json:"container,omitempty"`
+	// Kubernetes, if set, runs the session as a Kubernetes Job.
+	Kubernetes *pwrap.Kubernetes `json:"kubernetes,omitempty"`
+	// Host, if set, places the session on a remote host allowed by the server.
+	Host string `json:"host,omitempty"`
+	// DiskQuota, if set, limits the working directory of the session below
+	// the server's quota.
+	DiskQuota *pwrap.Quota `json:"disk_quota,omitempty"`
+	// Retention, if set, overrides the time the server keeps the session
+	// for once finished, e.g. "72h".
+	Retention string `json:"retention,omitempty"`
+	// MaxRuntime, if set, limits the time the child runs for, e.g. "2h".
+	// Sessions exceeding it are stopped and recorded as timed out.
+	MaxRuntime string `json:"max_runtime,omitempty"`
+	// Labels are attached to the session, which can then be selected with
+	// "pmuxapi.ListOptions".
+	Labels map[string]string `json:"labels,omitempty"`
+	// Name, if set, is the alias of the session, unique within its
+	// namespace, accepted in place of its identifier.
+	Name string `json:"name,omitempty"`
+	// Priority orders the session among those queued by the server, higher
+	// first.
+	Priority int `json:"priority,omitempty"`
+	// Secrets maps environment variables of the child to the references
+	// of the secrets they receive, e.g. "vault:secret/data/app#token".
+	Secrets map[string]string `json:"secrets,omitempty"`
+	// Env is added to the environment of the child.
+	Env map[string]string `json:"env,omitempty"`
+	// ExtraArgs are appended to the arguments of the executable, and may
+	// use the templates of "pwrap.ArgsData".
+	ExtraArgs []string `json:"extra_args,omitempty"`
+	// Artifacts are the glob patterns selecting the files of the working
+	// directory uploaded once the child exits, e.g. "out/*.mp4".
+	Artifacts []string `json:"artifacts,omitempty"`
+	// Stdin allows to stream data into the stdin of the child with
+	// "WriteStdin".
+	Stdin bool `json:"stdin,omitempty"`
+	// Sidecars are the names of the auxiliary commands, allowed by the
+	// server, started alongside the child.
+	Sidecars []string `json:"sidecars,omitempty"`
+	// Replicas, if set, makes the server create this many identical
+	// sessions, forming a group. Use "CreateGroup".
+	Replicas int `json:"replicas,omitempty"`
+	// StartAt or Cron, if set, make the server create the session later,
+	// once or every time the cron expression fires. Use "ScheduleSession".
+	StartAt *time.Time `json:"start_at,omitempty"`
+	Cron    string     `json:"cron,omitempty"`
+	// ConfigData, if set, is uploaded as is as the configuration of the
+	// session, e.g. a binary file, instead of Config. It cannot be
+	// scheduled.
+	ConfigData []byte `json:"-"`
+}
+
+// body returns the body of the create request "r", which is multipart when its
+// configuration is uploaded as is.
+func (r *CreateRequest) body() (interface{}, error) {
+	if r.ConfigData == nil {
+		return r, nil
+	}
+	c := *r
+	c.Config = nil
+	req, err := json.Marshal(&c)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	mw := multipart.NewWriter(&b)
+	if err := mw.WriteField(pmuxapi.PartRequest, string(req)); err != nil {
+		return nil, err
+	}
+	w, err := mw.CreateFormFile(pmuxapi.PartConfig, "config")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(r.ConfigData); err != nil {
+		return nil, err
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return &formData{data: b.Bytes(), contentType: mw.FormDataContentType()}, nil
+}
+
+// formData is a multipart request body.
+type formData struct {
+	data        []byte
+	contentType string
+}
+
+// CreateSession starts a new session, returning its identifier.
+func (c *Client) CreateSession(ctx context.Context, req *CreateRequest) (string, error) {
+	body, err := req.body()
+	if err != nil {
+		return "", err
+	}
+	var resp sidResponse
+	if err := c.call(ctx, "POST", "/sessions", nil, body, &resp); err != nil {
+		return "", err
+	}
+	return resp.SID, nil
+}
+
+// DryRunSession checks that the session described by "req" can be started,
+// without creating it. Sessions failing the checks return an "Error" with status
+// 422.
+func (c *Client) DryRunSession(ctx context.Context, req *CreateRequest) error {
+	body, err := req.body()
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(ctx, "POST", "/sessions", url.Values{"dry_run": {"true"}}, body)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// ScheduleSession makes the server create the session described by "req" at
+// "req.StartAt", or every time "req.Cron" fires, returning the schedule. The
+// sessions created carry the "pmuxapi.ScheduleLabel" label.
+func (c *Client) ScheduleSession(ctx context.Context, req *CreateRequest) (*pmuxapi.Schedule, error) {
+	if req.StartAt == nil && req.Cron == "" {
+		return nil, fmt.Errorf("either the start time or the cron expression is required")
+	}
+	if req.ConfigData != nil {
+		return nil, fmt.Errorf("sessions with an uploaded configuration cannot be scheduled")
+	}
+	var s pmuxapi.Schedule
+	if err := c.call(ctx, "POST", "/sessions", nil, req, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// CreateGroup creates "req.Replicas" identical sessions in one call, returning
+// their group. Either every replica is created or none. The sessions created
+// carry the "pmuxapi.GroupLabel" and "pmuxapi.ReplicaLabel" labels.
+func (c *Client) CreateGroup(ctx context.Context, req *CreateRequest) (*pmuxapi.Group, error) {
+	if req.Replicas < 1 {
+		return nil, fmt.Errorf("the number of replicas is required")
+	}
+	body, err := req.body()
+	if err != nil {
+		return nil, err
+	}
+	var g pmuxapi.Group
+	if err := c.call(ctx, "POST", "/sessions", nil, body, &g); err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+// GetGroup returns the state of group "id" and of its replicas.
+func (c *Client) GetGroup(ctx context.Context, id string) (*pmuxapi.Group, error) {
+	var g pmuxapi.Group
+	if err := c.call(ctx, "GET", "/groups/"+url.PathEscape(id), nil, nil, &g); err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+// DeleteGroup deletes the replicas of group "id".
+func (c *Client) DeleteGroup(ctx context.Context, id string) (*pmuxapi.BulkDeleteResult, error) {
+	var res pmuxapi.BulkDeleteResult
+	if err := c.call(ctx, "DELETE", "/groups/"+url.PathEscape(id), nil, nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// ListSchedules returns the schedules of the client's namespace.
+func (c *Client) ListSchedules(ctx context.Context) ([]*pmuxapi.Schedule, error) {
+	var acc []*pmuxapi.Schedule
+	if err := c.call(ctx, "GET", "/schedules", nil, nil, &acc); err != nil {
+		return nil, err
+	}
+	return acc, nil
+}
+
+// DeleteSchedule cancels the future runs of schedule "id".
+func (c *Client) DeleteSchedule(ctx context.Context, id string) error {
+	return c.call(ctx, "DELETE", "/schedules/"+url.PathEscape(id), nil, nil, &pmuxapi.Schedule{})
+}
+
+// PipelineStep is a step of a pipeline, whose session starts once the steps it
+// depends on exited successfully.
+type PipelineStep struct {
+	Name      string         `json:"name"`
+	DependsOn []string       `json:"depends_on,omitempty"`
+	Session   *CreateRequest `json:"session"`
+}
+
+// CreatePipeline creates a pipeline made of "steps", whose sessions are started by
+// the server as their dependencies succeed. The sessions created carry the
+// "pmuxapi.PipelineLabel" and "pmuxapi.StepLabel" labels.
+func (c *Client) CreatePipeline(ctx context.Context, steps []*PipelineStep) (*pmuxapi.Pipeline, error) {
+	body := struct {
+		Steps []*PipelineStep `json:"steps"`
+	}{steps}
+	var p pmuxapi.Pipeline
+	if err := c.call(ctx, "POST", "/pipelines", nil, &body, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ListPipelines returns the pipelines of the client's namespace.
+func (c *Client) ListPipelines(ctx context.Context) ([]*pmuxapi.Pipeline, error) {
+	var acc []*pmuxapi.Pipeline
+	if err := c.call(ctx, "GET", "/pipelines", nil, nil, &acc); err != nil {
+		return nil, err
+	}
+	return acc, nil
+}
+
+// GetPipeline returns the state of pipeline "id" and of its steps.
+func (c *Client) GetPipeline(ctx context.Context, id string) (*pmuxapi.Pipeline, error) {
+	var p pmuxapi.Pipeline
+	if err := c.call(ctx, "GET", "/pipelines/"+url.PathEscape(id), nil, nil, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// DeletePipeline deletes pipeline "id" together with the sessions of its steps.
+func (c *Client) DeletePipeline(ctx context.Context, id string) error {
+	return c.call(ctx, "DELETE", "/pipelines/"+url.PathEscape(id), nil, nil, &pmuxapi.Pipeline{})
+}
+
+// ListSessions returns the sessions selected by "opts", which may be nil, together
+// with the number of sessions matching its filters.
+func (c *Client) ListSessions(ctx context.Context, opts *pmuxapi.ListOptions) ([]*pmuxapi.SessionDetail, int, error) {
+	var q url.Values
+	if opts != nil {
+		q = opts.Values()
+	}
+	resp, err := c.do(ctx, "GET", "/sessions", q, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	var sessions []*pmuxapi.SessionDetail
+	if err := decode(resp, &sessions); err != nil {
+		return nil, 0, err
+	}
+	total, err := strconv.Atoi(resp.Header.Get("X-Total-Count"))
+	if err != nil {
+		total = len(sessions)
+	}
+	return sessions, total, nil
+}
+
+// GetSession returns the state of session "sid".
+func (c *Client) GetSession(ctx context.Context, sid string) (*pmuxapi.SessionDetail, error) {
+	var d pmuxapi.SessionDetail
+	if err := c.call(ctx, "GET", sessionPath(sid), nil, nil, &d); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// DeleteSession terminates session "sid". Its files are trashed unless the
+// server keeps them.
+func (c *Client) DeleteSession(ctx context.Context, sid string) error {
+	return c.call(ctx, "DELETE", sessionPath(sid), nil, nil, &sidResponse{})
+}
+
+// DeleteSessions terminates either the sessions "sids", or those selected by the
+// filters of "opts" if "sids" is empty. Sessions are deleted concurrently by the
+// server, which refuses to delete every session when no filter is given.
+func (c *Client) DeleteSessions(ctx context.Context, sids []string, opts *pmuxapi.ListOptions) (*pmuxapi.BulkDeleteResult, error) {
+	var q url.Values
+	if opts != nil {
+		q = opts.Values()
+	}
+	body := struct {
+		SIDs []string `json:"sids,omitempty"`
+	}{sids}
+	var res pmuxapi.BulkDeleteResult
+	if err := c.call(ctx, "DELETE", "/sessions", q, &body, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// RestartSession restarts session "sid", keeping its identifier and configuration.
+func (c *Client) RestartSession(ctx context.Context, sid string) error {
+	return c.call(ctx, "POST", sessionPath(sid)+"/restart", nil, nil, &sidResponse{})
+}
+
+// ResumeSession restarts session "sid", whose child continues from the checkpoint
+// it wrote.
+func (c *Client) ResumeSession(ctx context.Context, sid string) error {
+	return c.call(ctx, "POST", sessionPath(sid)+"/resume", nil, nil, &sidResponse{})
+}
+
+// PauseSession pauses the child of session "sid" in "mode", either
+// "pwrapapi.PauseSignal" or "pwrapapi.PauseCommand", returning its status.
+// Sessions that are paused already return an "Error" with status 409.
+func (c *Client) PauseSession(ctx context.Context, sid, mode string) (*pwrapapi.ChildStatus, error) {
+	q := url.Values{}
+	if mode != "" {
+		q.Set("mode", mode)
+	}
+	var s pwrapapi.ChildStatus
+	if err := c.call(ctx, "POST", sessionPath(sid)+"/pause", q, nil, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// UnpauseSession resumes the child of session "sid", paused with "PauseSession",
+// returning its status. Sessions that are not paused return an "Error" with
+// status 409.
+func (c *Client) UnpauseSession(ctx context.Context, sid string) (*pwrapapi.ChildStatus, error) {
+	var s pwrapapi.ChildStatus
+	if err := c.call(ctx, "POST", sessionPath(sid)+"/unpause", nil, nil, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// UpdateConfig replaces the configuration of session "sid" with the data read from
+// "r". Running sessions reload it without restarting, while children refusing the
+// reload command return an "Error" with status 422, the configuration being
+// replaced nonetheless.
+func (c *Client) UpdateConfig(ctx context.Context, sid string, r io.Reader) error {
+	resp, err := c.do(ctx, "PUT", sessionPath(sid)+"/config", nil, r)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// Drain makes the server refuse new sessions, shutting down once the running
+// ones are finished.
+func (c *Client) Drain(ctx context.Context) error {
+	resp, err := c.do(ctx, "POST", "/drain", nil, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// Reload makes the server read its settings again, returning those in effect.
+func (c *Client) Reload(ctx context.Context) (*pmuxapi.Settings, error) {
+	var s pmuxapi.Settings
+	if err := c.call(ctx, "POST", "/reload", nil, nil, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Audit returns the entries of the server's audit log selected by "f", in
+// chronological order.
+func (c *Client) Audit(ctx context.Context, f *pmuxapi.AuditFilter) ([]*pmuxapi.AuditEntry, error) {
+	var q url.Values
+	if f != nil {
+		q = f.Values()
+	}
+	var entries []*pmuxapi.AuditEntry
+	if err := c.call(ctx, "GET", "/audit", q, nil, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// LogsOptions select the output returned by "StreamLogs".
+type LogsOptions struct {
+	// Stderr selects the standard error of the session instead of its
+	// standard output.
+	Stderr bool
+	// Tail is the number of trailing lines returned, zero meaning the
+	// server's default and negative values the whole output.
+	Tail int
+	// Follow keeps the stream open, returning the output as it is written.
+	Follow bool
+}
+
+// StreamLogs returns the output of session "sid", as described by "opts". The
+// caller has to close the stream, which also ends when "ctx" is done.
+func (c *Client) StreamLogs(ctx context.Context, sid string, opts LogsOptions) (io.ReadCloser, error) {
+	q := url.Values{}
+	if opts.Stderr {
+		q.Set("stream", "stderr")
+	}
+	if opts.Tail != 0 {
+		q.Set("tail", strconv.Itoa(opts.Tail))
+	}
+	if opts.Follow {
+		q.Set("follow", "true")
+	}
+	resp, err := c.do(ctx, "GET", sessionPath(sid)+"/logs", q, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// ListFiles lists the files of the working directory of session "sid", which
+// remain available once the session is over.
+func (c *Client) ListFiles(ctx context.Context, sid string) ([]pwrapapi.FileInfo, error) {
+	var files []pwrapapi.FileInfo
+	if err := c.call(ctx, "GET", sessionPath(sid)+"/files", nil, nil, &files); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// DownloadFile returns the contents of file "name" of the working directory of
+// session "sid", as listed by "ListFiles".
+func (c *Client) DownloadFile(ctx context.Context, sid, name string) (io.ReadCloser, error) {
+	segments := strings.Split(name, "/")
+	for i, v := range segments {
+		segments[i] = url.PathEscape(v)
+	}
+	resp, err := c.do(ctx, "GET", sessionPath(sid)+"/files/"+strings.Join(segments, "/"), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// ProgressStream delivers the progress updates of a session.
+type ProgressStream struct {
+	body io.ReadCloser
+	s    *bufio.Scanner
+}
+
+// Next blocks until the next update is received. It returns io.EOF once the
+// stream is over.
+func (p *ProgressStream) Next() (*pwrap.ProgressUpdate, error) {
+	for p.s.Scan() {
+		line := bytes.TrimSpace(p.s.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var u pwrap.ProgressUpdate
+		if err := json.Unmarshal(line, &u); err != nil {
+			return nil, fmt.Errorf("unable to decode progress update: %w", err)
+		}
+		return &u, nil
+	}
+	if err := p.s.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// Close ends the stream.
+func (p *ProgressStream) Close() error {
+	return p.body.Close()
+}
+
+// StreamProgress returns the stream of the progress updates of session "sid",
+// which the caller has to close. The stream also ends when "ctx" is done.
+func (c *Client) StreamProgress(ctx context.Context, sid string) (*ProgressStream, error) {
+	resp, err := c.do(ctx, "GET", sessionPath(sid)+"/progress", url.Values{"format": {"json"}}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &ProgressStream{body: resp.Body, s: bufio.NewScanner(resp.Body)}, nil
+}
+
+// ProgressFeed is the stream of the progress updates of every running session,
+// see "StreamAllProgress".
+type ProgressFeed struct {
+	body io.ReadCloser
+	s    *bufio.Scanner
+}
+
+// Next blocks until the next update is received. It returns io.EOF once the
+// stream is over.
+func (f *ProgressFeed) Next() (*pmuxapi.ProgressEvent, error) {
+	var data []byte
+	for f.s.Scan() {
+		line := f.s.Bytes()
+		switch {
+		case len(line) == 0 && len(data) > 0:
+			var e pmuxapi.ProgressEvent
+			if err := json.Unmarshal(data, &e); err != nil {
+				return nil, fmt.Errorf("unable to decode progress event: %w", err)
+			}
+			return &e, nil
+		case bytes.HasPrefix(line, []byte("data:")):
+			data = append(data, bytes.TrimSpace(line[len("data:"):])...)
+		}
+	}
+	if err := f.s.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// Close ends the stream.
+func (f *ProgressFeed) Close() error {
+	return f.body.Close()
+}
+
+// StreamAllProgress returns the stream of the progress updates of the running
+// sessions, selected by the labels of "opts" if not nil, including those started
+// later. The caller has to close it; it also ends when "ctx" is done.
+func (c *Client) StreamAllProgress(ctx context.Context, opts *pmuxapi.ListOptions) (*ProgressFeed, error) {
+	q := url.Values{}
+	if opts != nil {
+		q["label"] = opts.Values()["label"]
+	}
+	resp, err := c.do(ctx, "GET", "/progress", q, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &ProgressFeed{body: resp.Body, s: bufio.NewScanner(resp.Body)}, nil
+}
+
+// ProgressHistory returns the last "tail" progress updates recorded for session
+// "sid", in chronological order. Zero means the server's default and negative
+// values the whole history.
+func (c *Client) ProgressHistory(ctx context.Context, sid string, tail int) ([]*pwrap.ProgressRecord, error) {
+	q := url.Values{}
+	if tail != 0 {
+		q.Set("tail", strconv.Itoa(tail))
+	}
+	resp, err := c.do(ctx, "GET", sessionPath(sid)+"/progress/history", q, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return pwrap.ReadProgress(resp.Body)
+}
+
+// SendCommand delivers "cmd" to the child of session "sid", returning its response.
+// The response is nil if the child accepted the command without responding.
+// Commands rejected by the child return an "Error" with status 422, carrying the
+// response as message, while operations that the session does not allow return
+// one with status 403.
+func (c *Client) SendCommand(ctx context.Context, sid string, cmd *pwrap.Command) (json.RawMessage, error) {
+	resp, err := c.do(ctx, "POST", sessionPath(sid)+"/command", nil, cmd)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusAccepted {
+		return nil, nil
+	}
+	var raw json.RawMessage
+	if err := decode(resp, &raw); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+// WriteStdin streams "r" into the stdin of the child of session "sid", which has
+// to be created with "CreateRequest.Stdin", returning the number of bytes
+// written. If "close" is set, the stdin of the child is closed afterwards.
+func (c *Client) WriteStdin(ctx context.Context, sid string, r io.Reader, close bool) (int64, error) {
+	q := url.Values{}
+	if close {
+		q.Set("close", "true")
+	}
+	var resp struct {
+		Bytes int64 `json:"bytes"`
+	}
+	if err := c.call(ctx, "POST", sessionPath(sid)+"/stdin", q, r, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Bytes, nil
+}
+
+// Screen returns the text displayed by the tmux pane of session "sid", preceded
+// by up to "history" lines of its scrollback.
+func (c *Client) Screen(ctx context.Context, sid string, history int) (string, error) {
+	q := url.Values{}
+	if history > 0 {
+		q.Set("history", strconv.Itoa(history))
+	}
+	resp, err := c.do(ctx, "GET", sessionPath(sid)+"/screen", q, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+type sidResponse struct {
+	SID string `json:"sid"`
+}
+
+func sessionPath(sid string) string {
+	return "/sessions/" + url.PathEscape(sid)
+}
+
+// call performs a request, decoding the response into "out".
+func (c *Client) call(ctx context.Context, method, path string, q url.Values, in, out interface{}) error {
+	resp, err := c.do(ctx, method, path, q, in)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return decode(resp, out)
+}
+
+// do performs a request to "path", encoding "in" as the body if not nil. Readers
+// are streamed as they are. Non successful responses are returned as an "Error".
+func (c *Client) do(ctx context.Context, method, path string, q url.Values, in interface{}) (*http.Response, error) {
+	var body io.Reader
+	contentType := "application/json"
+	switch v := in.(type) {
+	case nil:
+	case *formData:
+		body, contentType = bytes.NewReader(v.data), v.contentType
+	case io.Reader:
+		body, contentType = v, "application/octet-stream"
+	default:
+		data, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	}
+	u := c.base + path
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if c.namespace != "" {
+		req.Header.Set(pmuxapi.NamespaceHeader, c.namespace)
+	}
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, &Error{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(msg))}
+	}
+	return resp, nil
+}
+
+func decode(resp *http.Response, out interface{}) error {
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("unable to decode response: %w", err)
+	}
+	return nil
+}
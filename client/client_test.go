@@ -0,0 +1,197 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kim-company/pmux/http/pmuxapi"
+	"github.com/kim-company/pmux/pwrap"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/sessions", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key") != "key" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.Method {
+		case "POST":
+			var req CreateRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			fmt.Fprintf(w, `{"sid":"pmux-%s"}`, req.Exec)
+		case "GET":
+			if r.URL.Query().Get("state") != "running" {
+				http.Error(w, "missing filter", http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("X-Total-Count", "3")
+			fmt.Fprint(w, `[{"sid":"pmux-a","state":"running"}]`)
+		}
+	})
+	mux.HandleFunc("/api/v1/sessions/pmux-a/progress", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"description":"one","partial":1}`)
+		fmt.Fprintln(w, `{"description":"two","partial":2}`)
+	})
+	mux.HandleFunc("/api/v1/progress", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("label") != "team=video" {
+			http.Error(w, "missing filter", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, ": heartbeat\n\n")
+		fmt.Fprint(w, "event: progress\ndata: {\"sid\":\"pmux-a\",\"description\":\"one\"}\n\n")
+		fmt.Fprint(w, "event: progress\ndata: {\"sid\":\"pmux-b\",\"description\":\"two\"}\n\n")
+	})
+	mux.HandleFunc("/api/v1/sessions/pmux-a/command", func(w http.ResponseWriter, r *http.Request) {
+		var cmd pwrap.Command
+		json.NewDecoder(r.Body).Decode(&cmd)
+		if cmd.Op != pwrap.CommandPause {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			fmt.Fprintln(w, `{"ok":false}`)
+			return
+		}
+		fmt.Fprintln(w, `{"ok":true}`)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestClient(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer(t)
+	ctx := context.Background()
+	c := New(srv.URL, APIKey("key"))
+
+	sid, err := c.CreateSession(ctx, &CreateRequest{Exec: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sid != "pmux-x" {
+		t.Fatalf("unexpected sid: %q", sid)
+	}
+
+	sessions, total, err := c.ListSessions(ctx, &pmuxapi.ListOptions{States: []pwrap.SessionState{pwrap.SessionRunning}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 3 || len(sessions) != 1 || sessions[0].SID != "pmux-a" {
+		t.Fatalf("unexpected sessions: %d %v", total, sessions)
+	}
+
+	if _, err := c.GetSession(ctx, "pmux-b"); !IsNotFound(err) {
+		t.Fatalf("expected a not found error, found %v", err)
+	}
+	if _, err := New(srv.URL).CreateSession(ctx, &CreateRequest{}); err == nil || err.(*Error).StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected an unauthorized error, found %v", err)
+	}
+}
+
+func TestClient_StreamProgress(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer(t)
+	s, err := New(srv.URL).StreamProgress(context.Background(), "pmux-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	for _, want := range []string{"one", "two"} {
+		u, err := s.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.Description != want {
+			t.Fatalf("wanted %q, found %q", want, u.Description)
+		}
+	}
+	if _, err := s.Next(); err != io.EOF {
+		t.Fatalf("expected EOF, found %v", err)
+	}
+}
+
+func TestClient_StreamAllProgress(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer(t)
+	opts := &pmuxapi.ListOptions{Labels: map[string]string{"team": "video"}}
+	f, err := New(srv.URL).StreamAllProgress(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	for _, want := range []string{"pmux-a", "pmux-b"} {
+		e, err := f.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.SID != want {
+			t.Fatalf("wanted %q, found %q", want, e.SID)
+		}
+	}
+	if _, err := f.Next(); err != io.EOF {
+		t.Fatalf("expected EOF, found %v", err)
+	}
+}
+
+func TestClient_SendCommand(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestServer(t)
+	c := New(srv.URL)
+	resp, err := c.SendCommand(context.Background(), "pmux-a", &pwrap.Command{Op: pwrap.CommandPause})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(resp) != `{"ok":true}` {
+		t.Fatalf("unexpected response: %s", resp)
+	}
+	_, err = c.SendCommand(context.Background(), "pmux-a", &pwrap.Command{Op: "fly"})
+	if e, ok := err.(*Error); !ok || e.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("expected a rejection, found %v", err)
+	}
+}
+
+func TestClient_UploadConfig(t *testing.T) {
+	t.Parallel()
+
+	blob := []byte("license\x00\xff")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req CreateRequest
+		if err := json.Unmarshal([]byte(r.FormValue(pmuxapi.PartRequest)), &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f, _, err := r.FormFile(pmuxapi.PartConfig)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		data, _ := io.ReadAll(f)
+		if req.Config != nil || string(data) != string(blob) {
+			http.Error(w, "unexpected configuration", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, `{"sid":"pmux-%s"}`, req.Exec)
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL)
+	sid, err := c.CreateSession(context.Background(), &CreateRequest{Exec: "x", Config: json.RawMessage("{}"), ConfigData: blob})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sid != "pmux-x" {
+		t.Fatalf("unexpected sid: %q", sid)
+	}
+}
@@ -0,0 +1,31 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"log"
+
+	"github.com/kim-company/pmux/tmux"
+	"github.com/spf13/cobra"
+)
+
+var attachReadOnly bool
+
+// attachCmd represents the attach command
+var attachCmd = &cobra.Command{
+	Use:   "attach <sid>",
+	Short: "Attach the terminal to the tmux session of a pmux session",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := tmux.Attach(args[0], attachReadOnly); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(attachCmd)
+	attachCmd.Flags().BoolVarP(&attachReadOnly, "read-only", "r", false, "Attach without forwarding any input to the session.")
+}
@@ -0,0 +1,107 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// envPrefix is the prefix of the environment variables setting the options of
+// the server, e.g. PMUX_MAX_RUNNING for --max-running.
+const envPrefix = "PMUX"
+
+// loadConfig sets the flags of "flags" that were not given on the command line
+// from the configuration, see "readConfig".
+func loadConfig(flags *pflag.FlagSet, path string) error {
+	v, err := readConfig(flags, path)
+	if err != nil {
+		return err
+	}
+	return setFlags(flags, v)
+}
+
+// readConfig returns the configuration made of the environment variables named
+// after the flags of "flags", see "envPrefix", and of the file at "path", if not
+// empty, whose keys are flag names. The environment takes precedence over the
+// file, which is YAML unless its extension selects another format supported by
+// viper, e.g. ".json".
+func readConfig(flags *pflag.FlagSet, path string) (*viper.Viper, error) {
+	v := viper.New()
+	v.SetEnvPrefix(envPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
+	v.AutomaticEnv()
+	if path == "" {
+		return v, nil
+	}
+	v.SetConfigFile(path)
+	if filepath.Ext(path) == "" {
+		v.SetConfigType("yaml")
+	}
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("unable to read configuration file %s: %w", path, err)
+	}
+	for _, k := range v.AllKeys() {
+		if flags.Lookup(k) == nil || k == "config" {
+			return nil, fmt.Errorf("configuration file %s: unknown option %q", path, k)
+		}
+	}
+	return v, nil
+}
+
+// setFlags sets the flags of "flags" that were not given on the command line
+// from "v".
+func setFlags(flags *pflag.FlagSet, v *viper.Viper) error {
+	var err error
+	flags.VisitAll(func(f *pflag.Flag) {
+		if err != nil || f.Changed || f.Name == "config" || !v.IsSet(f.Name) {
+			return
+		}
+		err = setConfigValue(f, v.Get(f.Name))
+	})
+	return err
+}
+
+// setConfigValue sets flag "f" to "v", its value in the configuration. Repeatable
+// flags take either an array or, e.g. from the environment, a space separated
+// list.
+func setConfigValue(f *pflag.Flag, v interface{}) error {
+	var values []interface{}
+	switch v := v.(type) {
+	case []interface{}:
+		values = v
+	case string:
+		if !repeatable(f) {
+			values = []interface{}{v}
+			break
+		}
+		for _, s := range strings.Fields(v) {
+			values = append(values, s)
+		}
+	default:
+		values = []interface{}{v}
+	}
+	for _, v := range values {
+		s, err := cast.ToStringE(v)
+		if err != nil {
+			return fmt.Errorf("invalid value for option %q: %v", f.Name, v)
+		}
+		if err := f.Value.Set(s); err != nil {
+			return fmt.Errorf("invalid value for option %q: %w", f.Name, err)
+		}
+	}
+	return nil
+}
+
+// repeatable reports whether flag "f" can be given more than once.
+func repeatable(f *pflag.Flag) bool {
+	t := f.Value.Type()
+	return strings.HasSuffix(t, "Array") || strings.HasSuffix(t, "Slice")
+}
@@ -0,0 +1,69 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// envDaemonized is set in the environment of the process started by
+// "daemonize", so that it does not detach again.
+const envDaemonized = "PMUX_DAEMONIZED"
+
+// daemonized reports whether the current process was started by "daemonize".
+func daemonized() bool {
+	return os.Getenv(envDaemonized) != ""
+}
+
+// writePIDFile stores the PID of the current process at "path". It fails if the
+// file exists and refers to a process that is still running.
+func writePIDFile(path string) error {
+	if data, err := os.ReadFile(path); err == nil {
+		pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		if err == nil && pid != os.Getpid() && processAlive(pid) {
+			return fmt.Errorf("pid file %s: process %d is still running", path, pid)
+		}
+	}
+	if err := os.WriteFile(path, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644); err != nil {
+		return fmt.Errorf("unable to write pid file: %w", err)
+	}
+	return nil
+}
+
+// removePIDFile removes the pid file at "path", if it still belongs to the current
+// process.
+func removePIDFile(path string) {
+	data, err := os.ReadFile(path)
+	if err != nil || strings.TrimSpace(string(data)) != strconv.Itoa(os.Getpid()) {
+		return
+	}
+	os.Remove(path)
+}
+
+// sdNotify sends "state" to the systemd service manager, e.g. "READY=1". It does
+// nothing if the process was not started by systemd with "Type=notify".
+func sdNotify(state string) error {
+	addr := os.Getenv("NOTIFY_SOCKET")
+	if addr == "" {
+		return nil
+	}
+	if strings.HasPrefix(addr, "@") {
+		// Abstract namespace socket.
+		addr = "\x00" + addr[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("unable to notify service manager: %w", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return fmt.Errorf("unable to notify service manager: %w", err)
+	}
+	return nil
+}
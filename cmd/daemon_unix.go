@@ -0,0 +1,47 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+//go:build !windows
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// daemonize starts the current command again in a new session, detached from the
+// terminal, with its output appended to "logFile" or discarded if empty. It returns
+// the PID of the process started.
+func daemonize(logFile string) (int, error) {
+	out, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if logFile != "" {
+		out, err = os.OpenFile(logFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("unable to open daemon output: %w", err)
+	}
+	defer out.Close()
+
+	self, err := os.Executable()
+	if err != nil {
+		return 0, fmt.Errorf("unable to locate executable: %w", err)
+	}
+	cmd := exec.Command(self, os.Args[1:]...)
+	cmd.Env = append(os.Environ(), envDaemonized+"=1")
+	cmd.Stdout = out
+	cmd.Stderr = out
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return 0, fmt.Errorf("unable to start daemon: %w", err)
+	}
+	return cmd.Process.Pid, cmd.Process.Release()
+}
+
+// processAlive reports whether the process "pid" is still running.
+func processAlive(pid int) bool {
+	return syscall.Kill(pid, 0) == nil
+}
@@ -0,0 +1,29 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+//go:build windows
+
+package cmd
+
+import (
+	"errors"
+	"os"
+)
+
+// daemonize is not supported on windows, where the server is expected to be
+// run as a service instead.
+func daemonize(logFile string) (int, error) {
+	return 0, errors.New("daemon mode is not supported on windows")
+}
+
+// processAlive reports whether the process "pid" is still running. Opening a
+// process fails on windows if it does not exist.
+func processAlive(pid int) bool {
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	proc.Release()
+	return true
+}
@@ -0,0 +1,109 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kim-company/pmux/http/pmuxapi"
+	"github.com/kim-company/pmux/pwrap"
+	"github.com/spf13/cobra"
+)
+
+var killKeepFiles bool
+var killAll bool
+var killYes bool
+var killGracePeriod time.Duration
+
+// killCmd represents the kill command
+var killCmd = &cobra.Command{
+	Use:   "kill [sid...]",
+	Short: "Terminate pmux sessions of this host, trashing their files",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if killAll && len(args) > 0 {
+			return fmt.Errorf("session identifiers cannot be combined with --all")
+		}
+		if !killAll && len(args) == 0 {
+			return fmt.Errorf("at least one session identifier or --all is required")
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		sids := args
+		if killAll {
+			sessions, err := pmuxapi.ListLocal()
+			if err != nil {
+				log.Fatal(err)
+			}
+			for _, v := range sessions {
+				if v.Tmux {
+					sids = append(sids, v.SID)
+				}
+			}
+			if len(sids) == 0 {
+				printOutput([]killResult{}, func() { fmt.Println("No running sessions.") })
+				return
+			}
+			if !killYes && !confirm(fmt.Sprintf("Kill %d running sessions?", len(sids))) {
+				os.Exit(1)
+			}
+		}
+
+		failed := false
+		results := make([]killResult, 0, len(sids))
+		for _, sid := range sids {
+			res := killResult{SID: sid}
+			if err := pmuxapi.KillLocal(sid, killKeepFiles, killGracePeriod); err != nil {
+				res.Error = err.Error()
+				failed = true
+			}
+			results = append(results, res)
+		}
+		printOutput(results, func() {
+			for _, v := range results {
+				if v.Error != "" {
+					fmt.Fprintf(os.Stderr, "%s: %s\n", v.SID, v.Error)
+					continue
+				}
+				fmt.Println(v.SID)
+			}
+		})
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+// killResult is the outcome of the termination of a session.
+type killResult struct {
+	SID   string `json:"sid"`
+	Error string `json:"error,omitempty"`
+}
+
+// confirm asks the user "question" on the terminal, returning true only if the
+// answer is yes.
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	default:
+		return false
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(killCmd)
+	killCmd.Flags().BoolVarP(&killKeepFiles, "keep-files", "", false, "Keep the working directories of the sessions.")
+	killCmd.Flags().BoolVarP(&killAll, "all", "", false, "Kill every running session, after confirmation.")
+	killCmd.Flags().BoolVarP(&killYes, "yes", "y", false, "Do not ask for confirmation.")
+	killCmd.Flags().DurationVarP(&killGracePeriod, "grace-period", "", pwrap.DefaultGracePeriod, "Time given to sessions to exit gracefully, before they are killed.")
+}
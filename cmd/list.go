@@ -0,0 +1,107 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kim-company/pmux/http/pmuxapi"
+	"github.com/spf13/cobra"
+)
+
+var listJSON bool
+var listAll bool
+
+// listCmd represents the list command
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the pmux sessions of this host",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		sessions, err := pmuxapi.ListLocal()
+		if err != nil {
+			log.Fatal(err)
+		}
+		acc := make([]*pmuxapi.SessionDetail, 0, len(sessions))
+		for _, v := range sessions {
+			if listAll || v.Tmux {
+				acc = append(acc, v)
+			}
+		}
+
+		if listJSON {
+			output = outputJSON
+		}
+		printOutput(acc, func() { printSessions(acc) })
+	},
+}
+
+// printSessions prints a table describing "sessions".
+func printSessions(sessions []*pmuxapi.SessionDetail) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "SID\tEXEC\tSTATE\tUPTIME")
+	for _, v := range sessions {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", v.SID, orDash(filepath.Base(v.Exec)), orDash(string(v.State)), uptime(v, time.Now()))
+	}
+	w.Flush()
+}
+
+// printSession prints a table describing the fields of "d".
+func printSession(d *pmuxapi.SessionDetail) {
+	exitCode := "-"
+	if d.ExitCode != nil {
+		exitCode = strconv.Itoa(*d.ExitCode)
+	}
+	if d.Signal != "" {
+		exitCode = d.Signal
+	}
+	if d.OOMKilled {
+		exitCode += " (OOM)"
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "SID:\t%s\n", d.SID)
+	fmt.Fprintf(w, "EXEC:\t%s\n", orDash(d.Exec))
+	fmt.Fprintf(w, "STATE:\t%s\n", orDash(string(d.State)))
+	fmt.Fprintf(w, "EXIT CODE:\t%s\n", exitCode)
+	fmt.Fprintf(w, "UPTIME:\t%s\n", uptime(d, time.Now()))
+	fmt.Fprintf(w, "TMUX:\t%t\n", d.Tmux)
+	fmt.Fprintf(w, "WORKDIR:\t%s\n", orDash(d.WorkDir))
+	if d.Error != "" {
+		fmt.Fprintf(w, "ERROR:\t%s\n", d.Error)
+	}
+	w.Flush()
+}
+
+// uptime returns how long the child of "d" has been running, or ran for if it
+// is finished.
+func uptime(d *pmuxapi.SessionDetail, now time.Time) string {
+	if d.StartedAt == nil {
+		return "-"
+	}
+	if d.FinishedAt != nil {
+		now = *d.FinishedAt
+	}
+	return now.Sub(*d.StartedAt).Round(time.Second).String()
+}
+
+func orDash(s string) string {
+	if s == "" || s == "." {
+		return "-"
+	}
+	return s
+}
+
+func init() {
+	rootCmd.AddCommand(listCmd)
+	listCmd.Flags().BoolVarP(&listJSON, "json", "", false, "Print the sessions as JSON.")
+	listCmd.Flags().MarkDeprecated("json", "use --output json instead")
+	listCmd.Flags().BoolVarP(&listAll, "all", "a", false, "Include the sessions whose tmux session is gone.")
+}
@@ -0,0 +1,86 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/kim-company/pmux/http/pmuxapi"
+	"github.com/kim-company/pmux/pwrap"
+	"github.com/kim-company/pmux/tail"
+	"github.com/spf13/cobra"
+)
+
+var logsStderr bool
+var logsFollow bool
+var logsTail int
+
+// logsCmd represents the logs command
+var logsCmd = &cobra.Command{
+	Use:   "logs <sid>",
+	Short: "Print the output of a pmux session of this host",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		sid := args[0]
+		file := pwrap.FileStdout
+		if logsStderr {
+			file = pwrap.FileStderr
+		}
+		path, err := pmuxapi.LocalPath(sid, file)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, os.Interrupt)
+		go func() {
+			select {
+			case <-c:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		if logsFollow {
+			// Stop following once the session is gone.
+			go func() {
+				for pwrap.HasSession(pmuxapi.RootDir(), sid) {
+					select {
+					case <-ctx.Done():
+						return
+					case <-time.After(time.Second):
+					}
+				}
+				// Leave time for the last output to be read.
+				time.Sleep(tail.PollInterval * 2)
+				cancel()
+			}()
+		}
+
+		var w io.Writer = os.Stdout
+		if output != outputTable {
+			lw := &lineWriter{w: os.Stdout, stream: file}
+			defer lw.Flush()
+			w = lw
+		}
+		opts := tail.Options{Lines: logsTail, Follow: logsFollow}
+		if err := tail.File(ctx, w, path, opts); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(logsCmd)
+	logsCmd.Flags().BoolVarP(&logsStderr, "stderr", "", false, "Print the standard error of the session instead of its standard output.")
+	logsCmd.Flags().BoolVarP(&logsFollow, "follow", "f", false, "Keep on printing the output as it is written, until the session exits.")
+	logsCmd.Flags().IntVarP(&logsTail, "tail", "", -1, "Number of trailing lines printed. Negative values print the whole output.")
+}
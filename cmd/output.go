@@ -0,0 +1,134 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Output formats accepted by the "--output" flag.
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+// outputFormat is the value of the "--output" flag, validated when set.
+type outputFormat string
+
+func (f *outputFormat) String() string { return string(*f) }
+func (f *outputFormat) Type() string   { return "format" }
+
+func (f *outputFormat) Set(s string) error {
+	switch s {
+	case outputTable, outputJSON, outputYAML:
+		*f = outputFormat(s)
+		return nil
+	default:
+		return fmt.Errorf("unsupported output format %q, expected one of: %s, %s, %s", s, outputTable, outputJSON, outputYAML)
+	}
+}
+
+var output = outputFormat(outputTable)
+
+// printOutput prints "v" to stdout in the format selected with "--output".
+// "table" is called instead when the human readable format is selected.
+func printOutput(v interface{}, table func()) {
+	switch output {
+	case outputJSON:
+		printJSON(v)
+	case outputYAML:
+		printYAML(v)
+	default:
+		table()
+	}
+}
+
+func printJSON(v interface{}) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func printYAML(v interface{}) {
+	data, err := toYAML(v)
+	if err != nil {
+		log.Fatal(err)
+	}
+	os.Stdout.Write(data)
+}
+
+// toYAML encodes "v" as YAML. "v" is encoded as JSON first, so that the keys
+// follow its json tags.
+func toYAML(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(doc)
+}
+
+// lineWriter is an io.Writer encoding every line written to it as a record
+// of the selected output format, tagged with its stream name.
+type lineWriter struct {
+	w      io.Writer
+	stream string
+	buf    []byte
+}
+
+func (l *lineWriter) Write(p []byte) (int, error) {
+	l.buf = append(l.buf, p...)
+	for {
+		i := bytes.IndexByte(l.buf, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		if err := l.writeLine(string(l.buf[:i])); err != nil {
+			return 0, err
+		}
+		l.buf = l.buf[i+1:]
+	}
+}
+
+// Flush writes the last line, if it was not terminated by a newline.
+func (l *lineWriter) Flush() error {
+	if len(l.buf) == 0 {
+		return nil
+	}
+	line := string(l.buf)
+	l.buf = nil
+	return l.writeLine(line)
+}
+
+func (l *lineWriter) writeLine(line string) error {
+	rec := struct {
+		Stream string `json:"stream"`
+		Line   string `json:"line"`
+	}{l.stream, strings.TrimSuffix(line, "\r")}
+	if output == outputYAML {
+		data, err := toYAML(&rec)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(l.w, "---\n%s", data)
+		return err
+	}
+	// One compact record per line, so that the output can be streamed.
+	return json.NewEncoder(l.w).Encode(&rec)
+}
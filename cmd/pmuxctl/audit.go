@@ -0,0 +1,56 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kim-company/pmux/http/pmuxapi"
+	"github.com/spf13/cobra"
+)
+
+var auditFilter pmuxapi.AuditFilter
+var auditSince time.Duration
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Print the operations performed through the API of the server",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		f := auditFilter
+		f.Namespace = namespace
+		if auditSince > 0 {
+			f.Since = time.Now().Add(-auditSince)
+		}
+		ctx, cancel := requestContext()
+		defer cancel()
+		entries, err := newClient().Audit(ctx, &f)
+		if err != nil {
+			log.Fatal(err)
+		}
+		printOutput(entries, func() {
+			w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+			fmt.Fprintln(w, "TIME\tACTOR\tACTION\tSESSIONS\tSTATUS\tREMOTE")
+			for _, v := range entries {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\n", v.Time.Format(time.RFC3339), v.Actor, v.Action, orDash(strings.Join(v.SIDs, ",")), v.Status, v.RemoteAddr)
+			}
+			w.Flush()
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(auditCmd)
+	auditCmd.Flags().StringVarP(&auditFilter.SID, "sid", "", "", "Only print the operations targeting this session.")
+	auditCmd.Flags().StringVarP(&auditFilter.Actor, "actor", "", "", "Only print the operations performed by this actor.")
+	auditCmd.Flags().StringVarP(&auditFilter.Action, "action", "", "", "Only print the operations of this kind, e.g. delete.")
+	auditCmd.Flags().DurationVarP(&auditSince, "since", "", 0, "Only print the operations performed in this last period.")
+	auditCmd.Flags().IntVarP(&auditFilter.Limit, "limit", "", 0, "Maximum number of operations printed, the most recent ones. Zero means no limit.")
+}
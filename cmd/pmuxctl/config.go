@@ -0,0 +1,36 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config <sid> <file>",
+	Short: "Replace the configuration of a session, which running sessions reload without restarting",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		f, err := os.Open(args[1])
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		ctx, cancel := requestContext()
+		defer cancel()
+		if err := newClient().UpdateConfig(ctx, args[0], f); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+}
@@ -0,0 +1,145 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/kim-company/pmux/http/pmuxapi"
+	"github.com/spf13/cobra"
+)
+
+var exportOpts listOptions
+var exportFormat string
+var exportFile string
+
+// sessionReport is the summary of a session written by the export command.
+type sessionReport struct {
+	SID        string     `json:"sid"`
+	Exec       string     `json:"exec"`
+	State      string     `json:"state"`
+	ExitCode   *int       `json:"exit_code,omitempty"`
+	Signal     string     `json:"signal,omitempty"`
+	Restarts   int        `json:"restarts"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	// Duration is the running time of the child, in seconds.
+	Duration float64 `json:"duration_seconds"`
+	Error    string  `json:"error,omitempty"`
+}
+
+func newSessionReport(d *pmuxapi.SessionDetail, now time.Time) *sessionReport {
+	r := &sessionReport{
+		SID:        d.SID,
+		Exec:       d.Exec,
+		State:      string(d.State),
+		ExitCode:   d.ExitCode,
+		Signal:     d.Signal,
+		Restarts:   d.Restarts,
+		CreatedAt:  d.CreatedAt,
+		StartedAt:  d.StartedAt,
+		FinishedAt: d.FinishedAt,
+		Error:      d.Error,
+	}
+	if d.StartedAt != nil {
+		if d.FinishedAt != nil {
+			now = *d.FinishedAt
+		}
+		r.Duration = now.Sub(*d.StartedAt).Seconds()
+	}
+	return r
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export a report of the sessions selected by the filters, as CSV or JSON",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if exportFormat != "csv" && exportFormat != "json" {
+			log.Fatalf("unsupported export format %q, expected either csv or json", exportFormat)
+		}
+		opts, err := exportOpts.options()
+		if err != nil {
+			log.Fatal(err)
+		}
+		ctx, cancel := requestContext()
+		defer cancel()
+		sessions, _, err := newClient().ListSessions(ctx, opts)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		now := time.Now()
+		reports := make([]*sessionReport, 0, len(sessions))
+		for _, v := range sessions {
+			reports = append(reports, newSessionReport(v, now))
+		}
+
+		var w io.Writer = os.Stdout
+		if exportFile != "" {
+			f, err := os.Create(exportFile)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer f.Close()
+			w = f
+		}
+		if exportFormat == "json" {
+			err = json.NewEncoder(w).Encode(reports)
+		} else {
+			err = writeCSV(w, reports)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func writeCSV(w io.Writer, reports []*sessionReport) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"sid", "exec", "state", "exit_code", "signal", "restarts", "created_at", "started_at", "finished_at", "duration_seconds", "error"})
+	formatTime := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.Format(time.RFC3339)
+	}
+	for _, v := range reports {
+		exitCode := ""
+		if v.ExitCode != nil {
+			exitCode = strconv.Itoa(*v.ExitCode)
+		}
+		cw.Write([]string{
+			v.SID,
+			v.Exec,
+			v.State,
+			exitCode,
+			v.Signal,
+			strconv.Itoa(v.Restarts),
+			formatTime(&v.CreatedAt),
+			formatTime(v.StartedAt),
+			formatTime(v.FinishedAt),
+			fmt.Sprintf("%.3f", v.Duration),
+			v.Error,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+	exportOpts.register(exportCmd)
+	exportCmd.Flags().StringVarP(&exportFormat, "format", "f", "csv", "Format of the report: csv or json.")
+	exportCmd.Flags().StringVarP(&exportFile, "file", "", "", "File the report is written to, instead of stdout.")
+}
@@ -0,0 +1,69 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var filesOutput string
+
+var filesCmd = &cobra.Command{
+	Use:   "files <sid> [name]",
+	Short: "List the files of the working directory of a session, or download one of them",
+	Args:  cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		c := newClient()
+		if len(args) == 1 {
+			ctx, cancel := requestContext()
+			defer cancel()
+			files, err := c.ListFiles(ctx, args[0])
+			if err != nil {
+				log.Fatal(err)
+			}
+			printOutput(files, func() {
+				w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+				fmt.Fprintln(w, "NAME\tSIZE\tMODIFIED")
+				for _, v := range files {
+					fmt.Fprintf(w, "%s\t%d\t%s\n", v.Name, v.Size, v.ModTime.Format(time.RFC3339))
+				}
+				w.Flush()
+			})
+			return
+		}
+
+		// Large files may take longer than the timeout to download.
+		ctx, cancel := interruptible(context.WithCancel(context.Background()))
+		defer cancel()
+		body, err := c.DownloadFile(ctx, args[0], args[1])
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer body.Close()
+		out := os.Stdout
+		if filesOutput != "" && filesOutput != "-" {
+			if out, err = os.Create(filesOutput); err != nil {
+				log.Fatal(err)
+			}
+			defer out.Close()
+		}
+		if _, err := io.Copy(out, body); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(filesCmd)
+	filesCmd.Flags().StringVarP(&filesOutput, "output-file", "O", "", "Path the downloaded file is written to. The standard output is used if empty.")
+}
@@ -0,0 +1,97 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/kim-company/pmux/client"
+	"github.com/kim-company/pmux/http/pmuxapi"
+	"github.com/spf13/cobra"
+)
+
+var groupCmd = &cobra.Command{
+	Use:   "group",
+	Short: "Administer groups, identical sessions created in one call with create --replicas",
+}
+
+// createGroup creates the replicas of the session described by "req", printing
+// the identifier of their group.
+func createGroup(req *client.CreateRequest) {
+	if createCount != 1 {
+		log.Fatal("--count cannot be combined with --replicas")
+	}
+	req.Replicas = createReplicas
+	ctx, cancel := requestContext()
+	defer cancel()
+	g, err := newClient().CreateGroup(ctx, req)
+	if err != nil {
+		log.Fatal(err)
+	}
+	printOutput(g, func() {
+		fmt.Println(g.ID)
+	})
+}
+
+var groupShowCmd = &cobra.Command{
+	Use:   "show <id>",
+	Short: "Print the state of a group and of its replicas",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := requestContext()
+		defer cancel()
+		g, err := newClient().GetGroup(ctx, args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		printOutput(g, func() { printGroup(g) })
+	},
+}
+
+func printGroup(g *pmuxapi.Group) {
+	fmt.Printf("Group %s: %s\n", g.ID, g.State)
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "REPLICA\tSESSION\tSTATE\tERROR")
+	for _, v := range g.Sessions {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", v.Labels[pmuxapi.ReplicaLabel], v.SID, v.State, orDash(v.Error))
+	}
+	w.Flush()
+}
+
+var groupDeleteCmd = &cobra.Command{
+	Use:   "delete <id...>",
+	Short: "Delete the replicas of groups",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := requestContext()
+		defer cancel()
+		c := newClient()
+		failed := false
+		for _, id := range args {
+			res, err := c.DeleteGroup(ctx, id)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", id, err)
+				failed = true
+				continue
+			}
+			for sid, v := range res.Errors {
+				fmt.Fprintf(os.Stderr, "%s: %s: %s\n", id, sid, v)
+				failed = true
+			}
+			fmt.Println(id)
+		}
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(groupCmd)
+	groupCmd.AddCommand(groupShowCmd, groupDeleteCmd)
+}
@@ -0,0 +1,195 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+// Command pmuxctl administers remote pmux servers through their API. Unlike
+// pmux, it does not depend on tmux and can be distributed on its own.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kim-company/pmux/client"
+	"github.com/kim-company/pmux/http/pmuxapi"
+	"github.com/kim-company/pmux/pwrap"
+	"github.com/spf13/cobra"
+)
+
+var server string
+var apiKey string
+var token string
+var namespace string
+var timeout time.Duration
+var output string
+
+// rootCmd represents the base command when called without any subcommands
+var rootCmd = &cobra.Command{
+	Use:   "pmuxctl",
+	Short: "Administer the sessions of remote pmux servers",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if output != "table" && output != "json" {
+			return fmt.Errorf("unsupported output format %q, expected either table or json", output)
+		}
+		return nil
+	},
+}
+
+// newClient returns a client of the server selected with the global flags.
+func newClient() *client.Client {
+	opts := []func(*client.Client){client.APIKey(apiKey), client.Namespace(namespace)}
+	if token != "" {
+		opts = append(opts, client.BearerToken(token))
+	}
+	return client.New(server, opts...)
+}
+
+// requestContext returns a context bounded by the "--timeout" flag, canceled on
+// interrupt.
+func requestContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), timeout)
+	}
+	return interruptible(ctx, cancel)
+}
+
+// interruptible cancels "ctx" on interrupt.
+func interruptible(ctx context.Context, cancel context.CancelFunc) (context.Context, context.CancelFunc) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt)
+	go func() {
+		select {
+		case <-c:
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(c)
+	}()
+	return ctx, cancel
+}
+
+// listOptions holds the flags selecting sessions.
+type listOptions struct {
+	states []string
+	labels []string
+	older  time.Duration
+	sort   string
+	limit  int
+}
+
+func (o *listOptions) register(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVarP(&o.states, "state", "", nil, "Only select the sessions in these states, \"finished\" matching exited, failed and timed out ones.")
+	cmd.Flags().StringSliceVarP(&o.labels, "label", "l", nil, "Only select the sessions with these labels, in the key=value form.")
+	cmd.Flags().DurationVarP(&o.older, "older-than", "", 0, "Only select the sessions created at least this long ago.")
+	cmd.Flags().StringVarP(&o.sort, "sort", "", "sid", "Order of the sessions: sid, created_at or -created_at.")
+	cmd.Flags().IntVarP(&o.limit, "limit", "", 0, "Maximum number of sessions selected. Zero means no limit.")
+}
+
+func (o *listOptions) empty() bool {
+	return len(o.states) == 0 && len(o.labels) == 0 && o.older == 0
+}
+
+func (o *listOptions) options() (*pmuxapi.ListOptions, error) {
+	opts := &pmuxapi.ListOptions{Limit: o.limit, OlderThan: o.older, Sort: o.sort, Labels: map[string]string{}}
+	for _, v := range o.states {
+		opts.States = append(opts.States, pwrap.SessionState(v))
+	}
+	for _, v := range o.labels {
+		kv := strings.SplitN(v, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid label %q, expected key=value", v)
+		}
+		opts.Labels[kv[0]] = kv[1]
+	}
+	// Validate the remaining options as the server would.
+	return pmuxapi.ParseListOptions(opts.Values())
+}
+
+// printOutput prints "v" as JSON if selected with "--output", calling "table"
+// otherwise.
+func printOutput(v interface{}, table func()) {
+	if output == "json" {
+		printJSON(v)
+		return
+	}
+	table()
+}
+
+func printJSON(v interface{}) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// printSessions prints a table describing "sessions".
+func printSessions(sessions []*pmuxapi.SessionDetail) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "SID\tNAME\tEXEC\tSTATE\tEXIT\tCREATED\tDURATION")
+	for _, v := range sessions {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", v.SID, orDash(v.Name), orDash(filepath.Base(v.Exec)), orDash(string(v.State)), exitCode(v), v.CreatedAt.Format(time.RFC3339), duration(v, time.Now()))
+	}
+	w.Flush()
+}
+
+// duration returns how long the child of "d" has been running, or ran for if
+// it is finished.
+func duration(d *pmuxapi.SessionDetail, now time.Time) string {
+	if d.StartedAt == nil {
+		return "-"
+	}
+	if d.FinishedAt != nil {
+		now = *d.FinishedAt
+	}
+	return now.Sub(*d.StartedAt).Round(time.Second).String()
+}
+
+func exitCode(d *pmuxapi.SessionDetail) string {
+	if d.OOMKilled {
+		return d.Signal + " (OOM)"
+	}
+	if d.Signal != "" {
+		return d.Signal
+	}
+	if d.ExitCode == nil {
+		return "-"
+	}
+	return strconv.Itoa(*d.ExitCode)
+}
+
+func orDash(s string) string {
+	if s == "" || s == "." {
+		return "-"
+	}
+	return s
+}
+
+func init() {
+	addr := os.Getenv("PMUX_SERVER")
+	if addr == "" {
+		addr = "http://localhost:4002"
+	}
+	rootCmd.PersistentFlags().StringVarP(&server, "server", "s", addr, "Address of the pmux server. Defaults to $PMUX_SERVER.")
+	rootCmd.PersistentFlags().StringVarP(&apiKey, "api-key", "", os.Getenv("PMUX_API_KEY"), "API key presented to the server. Defaults to $PMUX_API_KEY.")
+	rootCmd.PersistentFlags().StringVarP(&token, "token", "", os.Getenv("PMUX_TOKEN"), "JWT presented to the server as bearer token. Defaults to $PMUX_TOKEN.")
+	rootCmd.PersistentFlags().StringVarP(&namespace, "namespace", "", os.Getenv("PMUX_NAMESPACE"), "Namespace of the sessions administered. Defaults to $PMUX_NAMESPACE, or the server's default namespace.")
+	rootCmd.PersistentFlags().DurationVarP(&timeout, "timeout", "", time.Minute, "Maximum duration of each command, watching excluded. Zero means no limit.")
+	rootCmd.PersistentFlags().StringVarP(&output, "output", "o", "table", "Output format of the results: table or json.")
+}
+
+func main() {
+	if err := rootCmd.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
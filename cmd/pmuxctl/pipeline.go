@@ -0,0 +1,124 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/kim-company/pmux/client"
+	"github.com/kim-company/pmux/http/pmuxapi"
+	"github.com/spf13/cobra"
+)
+
+var pipelineCmd = &cobra.Command{
+	Use:   "pipeline",
+	Short: "Administer pipelines, groups of sessions started as their dependencies succeed",
+}
+
+var pipelineCreateCmd = &cobra.Command{
+	Use:   "create <file>",
+	Short: "Create the pipeline described by a JSON file holding its steps, printing its identifier",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		var req struct {
+			Steps []*client.PipelineStep `json:"steps"`
+		}
+		if err := json.Unmarshal(data, &req); err != nil {
+			log.Fatalf("unable to decode pipeline: %v", err)
+		}
+		ctx, cancel := requestContext()
+		defer cancel()
+		p, err := newClient().CreatePipeline(ctx, req.Steps)
+		if err != nil {
+			log.Fatal(err)
+		}
+		printOutput(p, func() {
+			fmt.Println(p.ID)
+		})
+	},
+}
+
+var pipelineListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List pipelines",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := requestContext()
+		defer cancel()
+		pipelines, err := newClient().ListPipelines(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		printOutput(pipelines, func() {
+			w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tSTATE\tSTEPS\tCREATED")
+			for _, v := range pipelines {
+				fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", v.ID, v.State, len(v.Steps), formatTime(&v.CreatedAt))
+			}
+			w.Flush()
+		})
+	},
+}
+
+var pipelineShowCmd = &cobra.Command{
+	Use:   "show <id>",
+	Short: "Print the state of a pipeline and of its steps",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := requestContext()
+		defer cancel()
+		p, err := newClient().GetPipeline(ctx, args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		printOutput(p, func() { printPipeline(p) })
+	},
+}
+
+func printPipeline(p *pmuxapi.Pipeline) {
+	fmt.Printf("Pipeline %s: %s\n", p.ID, p.State)
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "STEP\tSTATE\tSESSION\tERROR")
+	for _, v := range p.Steps {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", v.Name, v.State, orDash(v.SID), orDash(v.Error))
+	}
+	w.Flush()
+}
+
+var pipelineDeleteCmd = &cobra.Command{
+	Use:   "delete <id...>",
+	Short: "Delete pipelines together with the sessions of their steps",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := requestContext()
+		defer cancel()
+		c := newClient()
+		failed := false
+		for _, id := range args {
+			if err := c.DeletePipeline(ctx, id); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", id, err)
+				failed = true
+				continue
+			}
+			fmt.Println(id)
+		}
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pipelineCmd)
+	pipelineCmd.AddCommand(pipelineCreateCmd, pipelineListCmd, pipelineShowCmd, pipelineDeleteCmd)
+}
@@ -0,0 +1,102 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kim-company/pmux/client"
+	"github.com/spf13/cobra"
+)
+
+// scheduleSession makes the server create the session of "req" later, as set
+// by the create command flags, and prints the identifier of the schedule.
+func scheduleSession(req *client.CreateRequest) {
+	if createCount != 1 {
+		log.Fatal("--count cannot be combined with --start-at or --cron")
+	}
+	if createStartAt != "" {
+		t, err := time.Parse(time.RFC3339, createStartAt)
+		if err != nil {
+			log.Fatalf("invalid start time: %v", err)
+		}
+		req.StartAt = &t
+	}
+	req.Cron = createCron
+	ctx, cancel := requestContext()
+	defer cancel()
+	s, err := newClient().ScheduleSession(ctx, req)
+	if err != nil {
+		log.Fatal(err)
+	}
+	printOutput(s, func() {
+		fmt.Println(s.ID)
+	})
+}
+
+// formatTime formats "t", a dash if nil.
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
+
+var schedulesCmd = &cobra.Command{
+	Use:   "schedules",
+	Short: "List the sessions scheduled for later",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := requestContext()
+		defer cancel()
+		schedules, err := newClient().ListSchedules(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		printOutput(schedules, func() {
+			w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tWHEN\tNEXT RUN\tRUNS\tLAST SESSION\tLAST ERROR")
+			for _, v := range schedules {
+				when := v.Cron
+				if when == "" {
+					when = formatTime(v.StartAt)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n", v.ID, when, formatTime(v.NextRun), v.Runs, orDash(v.LastSID), orDash(v.LastError))
+			}
+			w.Flush()
+		})
+	},
+}
+
+var unscheduleCmd = &cobra.Command{
+	Use:   "unschedule <id...>",
+	Short: "Cancel the future runs of schedules, leaving the sessions already created",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := requestContext()
+		defer cancel()
+		c := newClient()
+		failed := false
+		for _, id := range args {
+			if err := c.DeleteSchedule(ctx, id); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", id, err)
+				failed = true
+				continue
+			}
+			fmt.Println(id)
+		}
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(schedulesCmd, unscheduleCmd)
+}
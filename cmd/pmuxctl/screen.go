@@ -0,0 +1,34 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/spf13/cobra"
+)
+
+var screenHistory int
+
+var screenCmd = &cobra.Command{
+	Use:   "screen <sid>",
+	Short: "Print what the tmux pane of a running session is displaying",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := requestContext()
+		defer cancel()
+		screen, err := newClient().Screen(ctx, args[0], screenHistory)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Print(screen)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(screenCmd)
+	screenCmd.Flags().IntVarP(&screenHistory, "history", "", 0, "Lines of scrollback printed before the screen.")
+}
@@ -0,0 +1,420 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kim-company/pmux/client"
+	"github.com/kim-company/pmux/http/pmuxapi"
+	"github.com/kim-company/pmux/http/pwrapapi"
+	"github.com/kim-company/pmux/pwrap"
+	"github.com/kim-company/pmux/secrets"
+	"github.com/spf13/cobra"
+)
+
+var listOpts listOptions
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the sessions of the server",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		opts, err := listOpts.options()
+		if err != nil {
+			log.Fatal(err)
+		}
+		ctx, cancel := requestContext()
+		defer cancel()
+		sessions, total, err := newClient().ListSessions(ctx, opts)
+		if err != nil {
+			log.Fatal(err)
+		}
+		printOutput(sessions, func() {
+			printSessions(sessions)
+			if total > len(sessions) {
+				fmt.Printf("%d of %d sessions shown\n", len(sessions), total)
+			}
+		})
+	},
+}
+
+var showCmd = &cobra.Command{
+	Use:   "show <sid>",
+	Short: "Print the state of a session",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := requestContext()
+		defer cancel()
+		d, err := newClient().GetSession(ctx, args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		// The table format does not fit a single session.
+		printJSON(d)
+	},
+}
+
+var createExec string
+var createConfig string
+var createRegisterURL string
+var createRegisterHeaders []string
+var createCount int
+var createContainer pwrap.Container
+var createQuota pwrap.Quota
+var createQuotaSize string
+var createRetention time.Duration
+var createMaxRuntime time.Duration
+var createLabels []string
+var createName string
+var createPriority int
+var createSecrets []string
+var createEnv []string
+var createExtraArgs []string
+var createArtifacts []string
+var createStdin bool
+var createSidecars []string
+var createDryRun bool
+var createStartAt string
+var createCron string
+var createReplicas int
+
+var createCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Start new sessions, printing their identifiers",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		req := &client.CreateRequest{Exec: createExec, RegisterURL: createRegisterURL, Config: json.RawMessage("{}"), Priority: createPriority, Artifacts: createArtifacts, Stdin: createStdin, Sidecars: createSidecars}
+		if createContainer.Image != "" {
+			req.Container = &createContainer
+		}
+		if createRetention > 0 {
+			req.Retention = createRetention.String()
+		}
+		if createMaxRuntime > 0 {
+			req.MaxRuntime = createMaxRuntime.String()
+		}
+		if len(createLabels) > 0 {
+			labels, err := pwrap.ParseLabels(createLabels)
+			if err != nil {
+				log.Fatal(err)
+			}
+			req.Labels = labels
+		}
+		if len(createSecrets) > 0 {
+			refs, err := secrets.ParseRefs(createSecrets)
+			if err != nil {
+				log.Fatal(err)
+			}
+			req.Secrets = refs
+		}
+		if len(createEnv) > 0 {
+			env, err := pwrap.ParseEnv(createEnv)
+			if err != nil {
+				log.Fatal(err)
+			}
+			req.Env = env
+		}
+		req.ExtraArgs = createExtraArgs
+		if createName != "" && createCount > 1 {
+			log.Fatal("--name cannot be combined with --count, names are unique")
+		}
+		req.Name = createName
+		if len(createRegisterHeaders) > 0 {
+			headers, err := pwrap.ParseHeaders(createRegisterHeaders)
+			if err != nil {
+				log.Fatal(err)
+			}
+			req.RegisterHeaders = headers
+		}
+		if createQuotaSize != "" {
+			n, err := pwrap.ParseSize(createQuotaSize)
+			if err != nil {
+				log.Fatal(err)
+			}
+			createQuota.Bytes = n
+			req.DiskQuota = &createQuota
+		}
+		if createConfig != "" {
+			data, err := os.ReadFile(createConfig)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if json.Valid(data) {
+				req.Config = json.RawMessage(data)
+			} else {
+				req.ConfigData = data
+			}
+		}
+		if createDryRun {
+			ctx, cancel := requestContext()
+			defer cancel()
+			if err := newClient().DryRunSession(ctx, req); err != nil {
+				log.Fatal(err)
+			}
+			fmt.Println("ok")
+			return
+		}
+		if createStartAt != "" || createCron != "" {
+			scheduleSession(req)
+			return
+		}
+		if createReplicas > 0 {
+			createGroup(req)
+			return
+		}
+		ctx, cancel := requestContext()
+		defer cancel()
+		c := newClient()
+		sids := []string{}
+		for i := 0; i < createCount; i++ {
+			sid, err := c.CreateSession(ctx, req)
+			if err != nil {
+				log.Printf("[ERROR] %v", err)
+				break
+			}
+			sids = append(sids, sid)
+		}
+		printOutput(sids, func() {
+			for _, v := range sids {
+				fmt.Println(v)
+			}
+		})
+		if len(sids) < createCount {
+			os.Exit(1)
+		}
+	},
+}
+
+var deleteOpts listOptions
+
+var deleteCmd = &cobra.Command{
+	Use:   "delete [sid...]",
+	Short: "Terminate the sessions given, or those selected by the filters",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) > 0 && !deleteOpts.empty() {
+			return fmt.Errorf("session identifiers cannot be combined with filters")
+		}
+		if len(args) == 0 && deleteOpts.empty() {
+			return fmt.Errorf("at least one session identifier or filter is required")
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		var opts *pmuxapi.ListOptions
+		if len(args) == 0 {
+			var err error
+			if opts, err = deleteOpts.options(); err != nil {
+				log.Fatal(err)
+			}
+		}
+		ctx, cancel := requestContext()
+		defer cancel()
+		res, err := newClient().DeleteSessions(ctx, args, opts)
+		if err != nil {
+			log.Fatal(err)
+		}
+		printOutput(res, func() {
+			for _, v := range res.Deleted {
+				fmt.Println(v)
+			}
+			for _, v := range res.Pending {
+				fmt.Printf("%s (terminating)\n", v)
+			}
+			sids := make([]string, 0, len(res.Errors))
+			for k := range res.Errors {
+				sids = append(sids, k)
+			}
+			sort.Strings(sids)
+			for _, v := range sids {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", v, res.Errors[v])
+			}
+		})
+		if len(res.Errors) > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+var restartCmd = &cobra.Command{
+	Use:   "restart <sid...>",
+	Short: "Restart sessions, keeping their identifiers and configurations",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := requestContext()
+		defer cancel()
+		c := newClient()
+		failed := false
+		for _, sid := range args {
+			if err := c.RestartSession(ctx, sid); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", sid, err)
+				failed = true
+				continue
+			}
+			fmt.Println(sid)
+		}
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+var pauseMode string
+
+var pauseCmd = &cobra.Command{
+	Use:   "pause <sid...>",
+	Short: "Pause the children of running sessions, until they are unpaused",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := requestContext()
+		defer cancel()
+		c := newClient()
+		failed := false
+		for _, sid := range args {
+			if _, err := c.PauseSession(ctx, sid, pauseMode); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", sid, err)
+				failed = true
+				continue
+			}
+			fmt.Println(sid)
+		}
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+var unpauseCmd = &cobra.Command{
+	Use:   "unpause <sid...>",
+	Short: "Resume the children of paused sessions",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := requestContext()
+		defer cancel()
+		c := newClient()
+		failed := false
+		for _, sid := range args {
+			if _, err := c.UnpauseSession(ctx, sid); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", sid, err)
+				failed = true
+				continue
+			}
+			fmt.Println(sid)
+		}
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+var resumeCmd = &cobra.Command{
+	Use:   "resume <sid...>",
+	Short: "Restart sessions, their children continuing from the checkpoint they wrote",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := requestContext()
+		defer cancel()
+		c := newClient()
+		failed := false
+		for _, sid := range args {
+			if err := c.ResumeSession(ctx, sid); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", sid, err)
+				failed = true
+				continue
+			}
+			fmt.Println(sid)
+		}
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+var drainCmd = &cobra.Command{
+	Use:   "drain",
+	Short: "Make the server refuse new sessions and shut down once the running ones are finished",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := requestContext()
+		defer cancel()
+		if err := newClient().Drain(ctx); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("Server is draining.")
+	},
+}
+
+var reloadCmd = &cobra.Command{
+	Use:   "reload",
+	Short: "Make the server read again its executables, limits and retention period",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := requestContext()
+		defer cancel()
+		s, err := newClient().Reload(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		printOutput(s, func() { printSettings(s) })
+	},
+}
+
+func printSettings(s *pmuxapi.Settings) {
+	max := "unlimited"
+	if s.MaxRunning > 0 {
+		max = strconv.Itoa(s.MaxRunning)
+	}
+	fmt.Printf("Executables: %s\n", orDash(strings.Join(s.Execs, ", ")))
+	fmt.Printf("Max running: %s\n", max)
+	fmt.Printf("Retention: %s\n", orDash(s.Retention))
+	ns := make([]string, 0, len(s.NamespaceLimits))
+	for k := range s.NamespaceLimits {
+		ns = append(ns, k)
+	}
+	sort.Strings(ns)
+	for _, k := range ns {
+		fmt.Printf("Namespace %s: at most %d sessions\n", k, s.NamespaceLimits[k])
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(listCmd, showCmd, createCmd, deleteCmd, restartCmd, resumeCmd, pauseCmd, unpauseCmd, drainCmd, reloadCmd)
+	pauseCmd.Flags().StringVarP(&pauseMode, "mode", "", pwrapapi.PauseSignal, "How the children are paused: signal, stopping their processes, or command, asking them to pause.")
+	listOpts.register(listCmd)
+	deleteOpts.register(deleteCmd)
+	createCmd.Flags().StringVarP(&createExec, "exec", "", "", "Name of the executable run by the sessions, as whitelisted on the server.")
+	createCmd.Flags().StringVarP(&createConfig, "config", "c", "", "Path of the configuration passed to the sessions. Files that are not JSON, e.g. binary ones, are uploaded as they are. An empty object is used if not set.")
+	createCmd.Flags().StringVarP(&createRegisterURL, "register-url", "", "", "URL the sessions register their API to.")
+	createCmd.Flags().StringArrayVarP(&createRegisterHeaders, "register-header", "", []string{}, "HTTP header attached to the registration and callback requests, in the \"Name: value\" form, e.g. \"Authorization: Bearer token\". Can be repeated.")
+	createCmd.Flags().StringVarP(&createContainer.Image, "image", "", "", "Docker image the sessions run in, as allowed by the server.")
+	createCmd.Flags().StringArrayVarP(&createContainer.Mounts, "mount", "", []string{}, "Bind mount of the sessions' containers, in the source:destination[:options] form. Can be repeated.")
+	createCmd.Flags().StringVarP(&createContainer.CPUs, "cpus", "", "", "Number of CPUs available to each container.")
+	createCmd.Flags().StringVarP(&createContainer.Memory, "memory", "", "", "Memory limit of each container, e.g. 512m.")
+	createCmd.Flags().StringVarP(&createQuotaSize, "disk-quota", "", "", "Maximum size of the working directory of each session, e.g. 10G. The server's quota is used if empty.")
+	createCmd.Flags().StringVarP(&createQuota.Action, "disk-quota-action", "", "", "Action performed when a working directory exceeds its quota: warn or stop.")
+	createCmd.Flags().DurationVarP(&createRetention, "retention", "", 0, "Time the sessions are kept for once finished. The server's retention is used if zero.")
+	createCmd.Flags().DurationVarP(&createMaxRuntime, "max-runtime", "", 0, "Time the children are allowed to run for, before being stopped. Not limited if zero.")
+	createCmd.Flags().StringSliceVarP(&createLabels, "label", "l", nil, "Labels attached to the sessions, in the key=value form.")
+	createCmd.Flags().StringVarP(&createName, "name", "", "", "Name of the session, unique within its namespace and accepted in place of its identifier. Replicas are named after their index, e.g. name-0.")
+	createCmd.Flags().IntVarP(&createPriority, "priority", "", 0, "Priority of the sessions when queued by the server, higher first.")
+	createCmd.Flags().StringArrayVarP(&createSecrets, "secret", "", []string{}, "Secret injected into the environment of the sessions, in the NAME=scheme:location form, e.g. TOKEN=vault:secret/data/app#token. Can be repeated.")
+	createCmd.Flags().StringArrayVarP(&createEnv, "env", "e", []string{}, "Environment variable added to the environment of the sessions, in the NAME=value form. Can be repeated.")
+	createCmd.Flags().StringArrayVarP(&createExtraArgs, "arg", "", []string{}, "Argument appended to those of the executable of the sessions. Can be repeated.")
+	createCmd.Flags().StringArrayVarP(&createArtifacts, "artifact", "", []string{}, "Glob pattern, relative to the working directory, selecting the files uploaded by the server once the sessions exit. Can be repeated.")
+	createCmd.Flags().BoolVarP(&createStdin, "stdin", "", false, "Allow to stream data into the stdin of the sessions with the stdin command.")
+	createCmd.Flags().StringArrayVarP(&createSidecars, "sidecar", "", []string{}, "Name of a sidecar allowed by the server, started alongside the child of the sessions. Can be repeated.")
+	createCmd.Flags().BoolVarP(&createDryRun, "dry-run", "", false, "Check that the session can be started, without creating it.")
+	createCmd.Flags().IntVarP(&createCount, "count", "n", 1, "Number of sessions started.")
+	createCmd.Flags().IntVarP(&createReplicas, "replicas", "", 0, "Number of identical sessions created by the server in one call, forming a group. Either all of them are created or none. Prints the identifier of the group.")
+	createCmd.Flags().StringVarP(&createStartAt, "start-at", "", "", "Time the session is created at by the server, in the RFC 3339 format. Prints the identifier of the schedule.")
+	createCmd.Flags().StringVarP(&createCron, "cron", "", "", "Cron expression the server creates a session at, e.g. \"0 3 * * *\". Prints the identifier of the schedule.")
+}
@@ -0,0 +1,34 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var stdinClose bool
+
+var stdinCmd = &cobra.Command{
+	Use:   "stdin <sid>",
+	Short: "Stream the standard input into the stdin of a running session",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		// The input is streamed until its end, regardless of the timeout.
+		ctx, cancel := interruptible(context.WithCancel(context.Background()))
+		defer cancel()
+		if _, err := newClient().WriteStdin(ctx, args[0], os.Stdin, stdinClose); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(stdinCmd)
+	stdinCmd.Flags().BoolVarP(&stdinClose, "close", "", false, "Close the stdin of the session once the input ends.")
+}
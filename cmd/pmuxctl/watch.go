@@ -0,0 +1,206 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/kim-company/pmux/pwrap"
+	"github.com/spf13/cobra"
+)
+
+var watchOpts listOptions
+var watchInterval time.Duration
+var watchHistory bool
+var watchAllProgress bool
+
+var watchCmd = &cobra.Command{
+	Use:   "watch [sid]",
+	Short: "Print the progress of a session, or the state changes of the sessions, until interrupted",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := interruptible(context.WithCancel(context.Background()))
+		defer cancel()
+
+		var err error
+		switch {
+		case watchHistory && len(args) == 1:
+			err = progressHistory(ctx, args[0])
+		case watchHistory:
+			err = errors.New("--history requires a session identifier")
+		case watchAllProgress && len(args) == 1:
+			err = errors.New("--progress cannot be used with a session identifier")
+		case len(args) == 1:
+			err = watchProgress(ctx, args[0])
+		case watchAllProgress:
+			err = watchFeed(ctx)
+		default:
+			err = watchSessions(ctx)
+		}
+		if err != nil && !errors.Is(err, context.Canceled) {
+			log.Fatal(err)
+		}
+	},
+}
+
+// watchProgress prints the progress updates of session "sid".
+func watchProgress(ctx context.Context, sid string) error {
+	s, err := newClient().StreamProgress(ctx, sid)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	enc := json.NewEncoder(os.Stdout)
+	for {
+		u, err := s.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		if output == "json" {
+			enc.Encode(u)
+			continue
+		}
+		fmt.Printf("%s %s\n", time.Now().Format(time.RFC3339), describe(u))
+	}
+}
+
+// watchFeed prints the progress updates of the running sessions selected by
+// the label filters, in a single stream.
+func watchFeed(ctx context.Context) error {
+	opts, err := watchOpts.options()
+	if err != nil {
+		return err
+	}
+	f, err := newClient().StreamAllProgress(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	enc := json.NewEncoder(os.Stdout)
+	for {
+		e, err := f.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		if output == "json" {
+			enc.Encode(e)
+			continue
+		}
+		fmt.Printf("%s %s %s\n", e.Time.Format(time.RFC3339), e.SID, describe(e.ProgressUpdate))
+	}
+}
+
+// progressHistory prints the progress updates recorded for session "sid".
+func progressHistory(ctx context.Context, sid string) error {
+	records, err := newClient().ProgressHistory(ctx, sid, -1)
+	if err != nil {
+		return err
+	}
+	printOutput(records, func() {
+		for _, v := range records {
+			fmt.Printf("%s %s\n", v.Time.Format(time.RFC3339), describe(v.ProgressUpdate))
+		}
+	})
+	return nil
+}
+
+// describe returns a single line description of "u".
+func describe(u *pwrap.ProgressUpdate) string {
+	s := u.Description
+	if u.Stages > 0 {
+		s = fmt.Sprintf("[%d/%d] %s", u.Stage, u.Stages, s)
+	}
+	if p := u.Percent(); p >= 0 {
+		s += fmt.Sprintf(" %.1f%%", p)
+	} else if u.Partial >= 0 {
+		s += fmt.Sprintf(" %d", u.Partial)
+	}
+	return s
+}
+
+// stateChange describes a session entering a new state.
+type stateChange struct {
+	Time  time.Time          `json:"time"`
+	SID   string             `json:"sid"`
+	From  pwrap.SessionState `json:"from,omitempty"`
+	State pwrap.SessionState `json:"state"`
+}
+
+// watchSessions polls the sessions selected by the filters, printing their
+// state changes. Sessions that disappear are reported as "gone".
+func watchSessions(ctx context.Context) error {
+	opts, err := watchOpts.options()
+	if err != nil {
+		return err
+	}
+	c := newClient()
+	enc := json.NewEncoder(os.Stdout)
+	states := map[string]pwrap.SessionState{}
+	for {
+		sessions, _, err := c.ListSessions(ctx, opts)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Printf("[WARN] %v", err)
+		} else {
+			seen := make(map[string]bool, len(sessions))
+			var changes []stateChange
+			for _, v := range sessions {
+				seen[v.SID] = true
+				if from, ok := states[v.SID]; !ok || from != v.State {
+					changes = append(changes, stateChange{Time: time.Now(), SID: v.SID, From: from, State: v.State})
+					states[v.SID] = v.State
+				}
+			}
+			for sid, from := range states {
+				if !seen[sid] {
+					changes = append(changes, stateChange{Time: time.Now(), SID: sid, From: from, State: "gone"})
+					delete(states, sid)
+				}
+			}
+			for _, v := range changes {
+				if output == "json" {
+					enc.Encode(&v)
+					continue
+				}
+				fmt.Printf("%s %s %s -> %s\n", v.Time.Format(time.RFC3339), v.SID, orDash(string(v.From)), v.State)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(watchInterval):
+		}
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(watchCmd)
+	watchOpts.register(watchCmd)
+	watchCmd.Flags().BoolVarP(&watchAllProgress, "progress", "", false, "Print the progress updates of every running session selected by the label filters, rather than their state changes.")
+	watchCmd.Flags().BoolVarP(&watchHistory, "history", "", false, "Print the progress updates recorded for the session so far, even if it is over, and exit.")
+	watchCmd.Flags().DurationVarP(&watchInterval, "interval", "", time.Second*2, "Interval at which the sessions are polled.")
+}
@@ -0,0 +1,44 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/kim-company/pmux/http/pmuxapi"
+	"github.com/spf13/cobra"
+)
+
+var pruneOlderThan time.Duration
+var pruneDryRun bool
+
+// pruneCmd represents the prune command
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove the files left behind by finished and orphaned sessions of this host",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		paths, err := pmuxapi.PruneLocal(pruneOlderThan, pruneDryRun)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if paths == nil {
+			paths = []string{}
+		}
+		printOutput(paths, func() {
+			for _, v := range paths {
+				fmt.Println(v)
+			}
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pruneCmd)
+	pruneCmd.Flags().DurationVarP(&pruneOlderThan, "older-than", "", time.Hour*24, "Only remove the files untouched for at least this long.")
+	pruneCmd.Flags().BoolVarP(&pruneDryRun, "dry-run", "", false, "Print the files that would be removed, without removing them.")
+}
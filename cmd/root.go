@@ -25,3 +25,7 @@ func Execute() {
 		os.Exit(1)
 	}
 }
+
+func init() {
+	rootCmd.PersistentFlags().VarP(&output, "output", "o", "Output format of the commands printing results: table, json or yaml.")
+}
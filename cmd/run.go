@@ -0,0 +1,195 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/kim-company/pmux/http/pmuxapi"
+	"github.com/kim-company/pmux/pwrap"
+	"github.com/spf13/cobra"
+)
+
+var runConfig string
+var runKeepFiles bool
+var runGracePeriod time.Duration
+var runDetach bool
+var runPortRange string
+var runQuotaSize, runQuotaAction string
+var runMaxRuntime time.Duration
+
+// runPollInterval is the interval at which "run" checks the state of its session.
+const runPollInterval = time.Millisecond * 250
+
+// runCmd represents the run command
+var runCmd = &cobra.Command{
+	Use:   "run -- <cmd> [args...]",
+	Short: "Run a command inside a pmux session, reporting its progress until it exits",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		q, err := diskQuota(runQuotaSize, runQuotaAction)
+		if err != nil {
+			log.Fatal(err)
+		}
+		var ports *pwrap.PortRange
+		if runPortRange != "" {
+			if ports, err = pwrap.ParsePortRange(runPortRange); err != nil {
+				log.Fatal(err)
+			}
+		}
+		pw, err := pwrap.New(
+			pwrap.DiskQuota(q),
+			pwrap.MaxRuntime(runMaxRuntime),
+			pwrap.Exec(args[0], args[1:]...),
+			pwrap.RootDir(pmuxapi.RootDir()),
+			pwrap.GracePeriod(runGracePeriod),
+			pwrap.Detach(runDetach),
+			pwrap.APIPorts(ports),
+		)
+		if err != nil {
+			log.Fatal(err)
+		}
+		var config io.Reader = strings.NewReader("{}\n")
+		if runConfig != "" {
+			f, err := os.Open(runConfig)
+			if err != nil {
+				pw.Trash()
+				log.Fatal(err)
+			}
+			defer f.Close()
+			config = f
+		}
+		if err := pw.WriteConfig(config); err != nil {
+			pw.Trash()
+			log.Fatal(err)
+		}
+		if _, err := pw.StartSession(); err != nil {
+			pw.Trash()
+			log.Fatal(err)
+		}
+		fmt.Fprintf(os.Stderr, "Session %s, working dir: %s\n", pw.SID(), pw.WorkDir())
+
+		code := waitSession(pw)
+		if runKeepFiles {
+			if pw.Running() {
+				err = pw.KillSession()
+			}
+		} else {
+			err = pw.Trash()
+		}
+		if err != nil {
+			log.Printf("[ERROR] %v", err)
+		}
+		os.Exit(code)
+	},
+}
+
+// waitSession reports the progress of the session of "pw" on stderr until it
+// finishes, returning the exit status of its child. It returns early on
+// interrupts, leaving the session running.
+func waitSession(pw *pwrap.PWrap) int {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(c)
+
+	var bar progressBar
+	var last time.Time
+	defer bar.done()
+	for {
+		select {
+		case <-c:
+			bar.done()
+			fmt.Fprintln(os.Stderr, "Terminating session...")
+			return 130
+		case <-time.After(runPollInterval):
+		}
+
+		s, err := pw.ReadSession()
+		if err != nil {
+			log.Printf("[WARN] unable to read session state: %v", err)
+			continue
+		}
+		if s.LastProgressAt != nil && !s.LastProgressAt.Equal(last) {
+			last = *s.LastProgressAt
+			bar.draw(s.LastProgress)
+		}
+		switch s.State {
+		case pwrap.SessionExited, pwrap.SessionFailed, pwrap.SessionTimedOut:
+			bar.done()
+			if s.Error != "" {
+				fmt.Fprintf(os.Stderr, "Session failed: %s\n", s.Error)
+			}
+			if s.ExitCode != nil && *s.ExitCode >= 0 {
+				return *s.ExitCode
+			}
+			return 1
+		}
+		if !pw.Running() {
+			// The state is written before the wrapper exits, check
+			// once more to avoid racing with it.
+			if s, err := pw.ReadSession(); err == nil && (s.State == pwrap.SessionExited || s.State == pwrap.SessionFailed || s.State == pwrap.SessionTimedOut) {
+				continue
+			}
+			bar.done()
+			fmt.Fprintln(os.Stderr, "Session exited without reporting a result, see its stderr file")
+			return 1
+		}
+	}
+}
+
+// progressBarWidth is the number of characters of the bar drawn by progressBar.
+const progressBarWidth = 30
+
+// progressBar draws progress updates on a single terminal line.
+type progressBar struct {
+	drawn bool
+}
+
+func (b *progressBar) draw(u *pwrap.ProgressUpdate) {
+	var line string
+	if p := u.Percent(); p >= 0 {
+		n := int(p / 100 * progressBarWidth)
+		if n > progressBarWidth {
+			n = progressBarWidth
+		}
+		line = fmt.Sprintf("[%s%s] %5.1f%%", strings.Repeat("#", n), strings.Repeat(" ", progressBarWidth-n), p)
+	} else {
+		line = fmt.Sprintf("[%d]", u.Partial)
+	}
+	if u.Stages > 0 {
+		line += fmt.Sprintf(" stage %d/%d", u.Stage, u.Stages)
+	}
+	line += " " + u.Description
+	// Clear the rest of the line, the previous update might have been longer.
+	fmt.Fprintf(os.Stderr, "\r%s\033[K", line)
+	b.drawn = true
+}
+
+// done moves the cursor past the bar, if any was drawn.
+func (b *progressBar) done() {
+	if b.drawn {
+		fmt.Fprintln(os.Stderr)
+		b.drawn = false
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(runCmd)
+	runCmd.Flags().StringVarP(&runConfig, "config", "c", "", "Path of the configuration file passed to the command. An empty JSON object is used if not set.")
+	runCmd.Flags().BoolVarP(&runKeepFiles, "keep-files", "", false, "Keep the working directory of the session after it exits.")
+	runCmd.Flags().StringVarP(&runPortRange, "port-range", "", "", "Range of ports the wrapper API listens on, e.g. 42000-43000. A random free port is used if empty.")
+	runCmd.Flags().BoolVarP(&runDetach, "detach", "", false, "Start the wrapper as a detached process rather than inside a tmux session. Implied when tmux is not installed.")
+	runCmd.Flags().StringVarP(&runQuotaSize, "disk-quota", "", "", "Maximum size of the working directory, e.g. 10G. Not limited if empty.")
+	runCmd.Flags().StringVarP(&runQuotaAction, "disk-quota-action", "", pwrap.QuotaWarn, "Action performed when the working directory exceeds its quota: warn or stop.")
+	runCmd.Flags().DurationVarP(&runMaxRuntime, "max-runtime", "", 0, "Time the command is allowed to run for, before being stopped. Not limited if zero.")
+	runCmd.Flags().DurationVarP(&runGracePeriod, "grace-period", "", pwrap.DefaultGracePeriod, "Time given to the command to exit gracefully when interrupted, before it is killed.")
+}
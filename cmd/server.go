@@ -6,56 +6,246 @@ package cmd
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
+	"github.com/kim-company/pmux/artifact"
 	"github.com/kim-company/pmux/http/pmuxapi"
+	"github.com/kim-company/pmux/http/pwrapapi"
+	"github.com/kim-company/pmux/pwrap"
+	"github.com/kim-company/pmux/tmux"
+	"github.com/kim-company/pmux/trace"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 var port int
 var execName string
 var childArgsRaw string
 var dirty bool
+var serverGracePeriod time.Duration
+var execsRaw []string
+var serverWebhooks []string
+var apiKeys []string
+var jwtSecret string
+var tlsCert, tlsKey, clientCA string
+var corsOrigins, corsMethods []string
+var maxRunning int
+var preempt bool
+var configTemplates bool
+var configVars []string
+var artifactsURL string
+var serverSidecars []string
+var drainTimeout time.Duration
+var serverConfig string
+var serverRootDir string
+var daemon bool
+var pidFile, logFile string
+var containerImages, containerMounts []string
+var secretRefPrefixes []string
+var detach bool
+var preflight bool
+var serverSockDir string
+var serverStreaming string
+var serverCommandOps []string
+var serverPortRange string
+var kubeTemplate pwrap.Kubernetes
+var sshHosts []string
+var sshRoot, sshPMux string
+var serverOTLPEndpoint string
+var auditLog string
+var serverQuotaSize, serverQuotaAction string
+var retention time.Duration
+var compressLogs bool
+var namespaceKeys, namespaceLimits []string
 
 // serverCmd represents the server command
 var serverCmd = &cobra.Command{
 	Use:   "server",
 	Short: "A brief description of your command",
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return loadConfig(cmd.Flags(), serverConfig)
+	},
 	Run: func(cmd *cobra.Command, args []string) {
+		if daemon && !daemonized() {
+			pid, err := daemonize(logFile)
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Printf("Server started in the background, pid %d\n", pid)
+			return
+		}
+		if pidFile != "" {
+			if err := writePIDFile(pidFile); err != nil {
+				log.Fatal(err)
+			}
+			defer removePIDFile(pidFile)
+		}
+
+		pmuxapi.SetRootDir(serverRootDir)
+		trace.SetEndpoint(serverOTLPEndpoint, "pmux")
+		execs, err := executables(execsRaw)
+		if err != nil {
+			log.Fatal(err)
+		}
+		quota, err := diskQuota(serverQuotaSize, serverQuotaAction)
+		if err != nil {
+			log.Fatal(err)
+		}
+		ns, err := namespaces(namespaceKeys, namespaceLimits)
+		if err != nil {
+			log.Fatal(err)
+		}
+		templateVars, err := parseConfigVars(configTemplates, configVars)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if artifactsURL != "" {
+			if _, err := artifact.Parse(artifactsURL); err != nil {
+				log.Fatal(err)
+			}
+		}
+		sidecars := make([]pwrap.Sidecar, 0, len(serverSidecars))
+		for _, v := range serverSidecars {
+			s, err := pwrap.ParseSidecar(v)
+			if err != nil {
+				log.Fatal(err)
+			}
+			sidecars = append(sidecars, s)
+		}
+		streamMode, err := pwrapapi.ParseStreamMode(serverStreaming)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, v := range serverCommandOps {
+			if err := pwrapapi.ValidateCommandOp(v); err != nil {
+				log.Fatal(err)
+			}
+		}
+		var ports *pwrap.PortRange
+		if serverPortRange != "" {
+			if ports, err = pwrap.ParsePortRange(serverPortRange); err != nil {
+				log.Fatal(err)
+			}
+		}
+		// The default audit log is kept in the root directory.
+		var audit pmuxapi.AuditLog
+		if auditLog != "" {
+			l, err := pmuxapi.NewFileAuditLog(auditLog)
+			if err != nil {
+				log.Fatal(err)
+			}
+			audit = l
+		}
 		r := pmuxapi.NewRouter(execName,
+			pmuxapi.Execs(execs),
+			pmuxapi.Webhooks(serverWebhooks...),
+			pmuxapi.APIKeys(apiKeys...),
+			pmuxapi.JWTSecret([]byte(jwtSecret)),
+			pmuxapi.CORS(corsOrigins, corsMethods),
+			pmuxapi.MaxRunning(maxRunning),
+			pmuxapi.Preemption(preempt),
+			pmuxapi.ContainerImages(containerImages...),
+			pmuxapi.ContainerMounts(containerMounts...),
+			pmuxapi.SecretRefs(secretRefPrefixes...),
+			pmuxapi.Detach(detach),
+			pmuxapi.Preflight(preflight),
+			pmuxapi.SockDir(serverSockDir),
+			pmuxapi.Streaming(streamMode),
+			pmuxapi.CommandOps(serverCommandOps...),
+			pmuxapi.APIPorts(ports),
+			pmuxapi.Kubernetes(kubernetes()),
+			pmuxapi.RemoteHosts(sshRoot, sshPMux, sshHosts...),
 			pmuxapi.Args(strings.Split(childArgsRaw, ",")),
 			pmuxapi.KeepFiles(dirty),
+			pmuxapi.GracePeriod(serverGracePeriod),
+			pmuxapi.Audit(audit),
+			pmuxapi.DiskQuota(quota),
+			pmuxapi.Retention(retention),
+			pmuxapi.CompressLogs(compressLogs),
+			pmuxapi.ConfigTemplates(templateVars),
+			pmuxapi.ArtifactsDestination(artifactsURL),
+			pmuxapi.Sidecars(sidecars...),
+			pmuxapi.Reloader(func() ([]func(*pmuxapi.Router), error) {
+				return reloadOptions(cmd.Flags(), serverConfig)
+			}),
+			ns,
 		)
+		tlsConf, err := serverTLSConfig()
+		if err != nil {
+			log.Fatal(err)
+		}
 		srv := &http.Server{
 			Addr:         fmt.Sprintf("0.0.0.0:%d", port),
 			WriteTimeout: time.Second * 15,
 			ReadTimeout:  time.Second * 15,
 			IdleTimeout:  time.Second * 60,
 			Handler:      r,
+			TLSConfig:    tlsConf,
+		}
+		ln, err := net.Listen("tcp", srv.Addr)
+		if err != nil {
+			log.Fatal(err)
 		}
 		// Run our server in a goroutine so that it doesn't block.
-		log.Printf("Port: %d, Executable: %s", port, execName)
+		log.Printf("Port: %d, Executable: %s, TLS: %t", port, execName, tlsConf != nil)
+		logTmux()
 		log.Printf("Server listening...")
 		go func() {
-			if err := srv.ListenAndServe(); err != nil {
+			var err error
+			if tlsConf != nil {
+				err = srv.ServeTLS(ln, tlsCert, tlsKey)
+			} else {
+				err = srv.Serve(ln)
+			}
+			if err != nil {
 				log.Println(err)
 			}
 		}()
+		if err := sdNotify("READY=1"); err != nil {
+			log.Printf("[WARN] %v", err)
+		}
 
 		c := make(chan os.Signal, 1)
 
 		// We'll accept graceful shutdowns when quit via SIGINT (Ctrl+C)
-		// SIGKILL, SIGQUIT or SIGTERM (Ctrl+/) will not be caught.
-		signal.Notify(c, os.Interrupt)
+		// SIGKILL, SIGQUIT will not be caught. SIGTERM, like the drain
+		// route, lets running sessions finish before shutting down.
+		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+		// SIGHUP, like the reload route, reloads the settings.
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
 
-		// Block until we receive our signal.
-		<-c
+		// Block until we receive our signal, or until drained.
+	wait:
+		for {
+			select {
+			case <-hup:
+				if err := reload(r, cmd.Flags()); err != nil {
+					log.Printf("[ERROR] unable to reload settings: %v", err)
+				}
+			case sig := <-c:
+				if sig == syscall.SIGTERM {
+					r.Drain()
+					waitDrained(r, c)
+				}
+				break wait
+			case <-r.Draining():
+				waitDrained(r, c)
+				break wait
+			}
+		}
 
 		// Create a deadline to wait for.
 		ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
@@ -64,16 +254,269 @@ var serverCmd = &cobra.Command{
 		// Doesn't block if no connections, but will otherwise wait
 		// until the timeout deadline.
 		log.Println("Server is shutting down...")
+		sdNotify("STOPPING=1")
 		srv.Shutdown(ctx)
+		trace.Flush(ctx)
+		if pidFile != "" {
+			removePIDFile(pidFile)
+		}
 		os.Exit(0)
 	},
 }
 
+// waitDrained waits for the sessions of "r" to finish, for at most the drain
+// timeout. A signal received on "c" interrupts the wait.
+func waitDrained(r *pmuxapi.Router, c <-chan os.Signal) {
+	log.Printf("Server is draining, waiting up to %v for sessions to finish...", drainTimeout)
+	sdNotify("STATUS=Draining, waiting for sessions to finish")
+	ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-c:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	if err := r.WaitDrained(ctx); err != nil {
+		log.Printf("[WARN] drain interrupted: %v", err)
+	}
+}
+
+// reload reloads the settings of "r" from "flags" and from the configuration
+// file.
+func reload(r *pmuxapi.Router, flags *pflag.FlagSet) error {
+	sdNotify("RELOADING=1")
+	defer sdNotify("READY=1")
+	opts, err := reloadOptions(flags, serverConfig)
+	if err != nil {
+		return err
+	}
+	_, err = r.Reload(opts...)
+	return err
+}
+
+// reloadOptions returns the options of the settings that can be reloaded, see
+// "pmuxapi.Router.Reload": the values of their flags if set on the command line,
+// or else those read again from the environment and from the configuration file
+// at "path", if not empty.
+func reloadOptions(flags *pflag.FlagSet, path string) ([]func(*pmuxapi.Router), error) {
+	fs := pflag.NewFlagSet("reload", pflag.ContinueOnError)
+	var execs, limits []string
+	var max int
+	var ttl time.Duration
+	fs.StringArrayVar(&execs, "exec", []string{}, "")
+	fs.IntVar(&max, "max-running", 0, "")
+	fs.StringArrayVar(&limits, "namespace-limit", []string{}, "")
+	fs.DurationVar(&ttl, "retention", 0, "")
+
+	set := map[string][]string{
+		"exec":            execsRaw,
+		"max-running":     {strconv.Itoa(maxRunning)},
+		"namespace-limit": namespaceLimits,
+		"retention":       {retention.String()},
+	}
+	for k, values := range set {
+		if f := flags.Lookup(k); f == nil || !f.Changed {
+			continue
+		}
+		for _, v := range values {
+			if err := fs.Set(k, v); err != nil {
+				return nil, err
+			}
+		}
+	}
+	conf, err := readConfig(flags, path)
+	if err != nil {
+		return nil, err
+	}
+	// Other options require a restart.
+	if err := setFlags(fs, conf); err != nil {
+		return nil, err
+	}
+
+	m, err := executables(execs)
+	if err != nil {
+		return nil, err
+	}
+	ns, err := namespaces(nil, limits)
+	if err != nil {
+		return nil, err
+	}
+	return []func(*pmuxapi.Router){pmuxapi.Execs(m), pmuxapi.MaxRunning(max), pmuxapi.Retention(ttl), ns}, nil
+}
+
+// executables parses the definitions of the executables sessions may select,
+// in the name=path[,arg...] form.
+func executables(defs []string) (map[string]pmuxapi.Executable, error) {
+	m := make(map[string]pmuxapi.Executable, len(defs))
+	for _, v := range defs {
+		name, e, err := pmuxapi.ParseExecutable(v)
+		if err != nil {
+			return nil, err
+		}
+		m[name] = e
+	}
+	return m, nil
+}
+
+// logTmux logs the version of tmux, if available, and the features it lacks.
+func logTmux() {
+	if !tmux.Available() {
+		log.Printf("[INFO] tmux is not available, sessions run as detached processes")
+		return
+	}
+	v, err := tmux.VersionInfo()
+	if err != nil {
+		log.Printf("[WARN] %v", err)
+		return
+	}
+	log.Printf("[INFO] using tmux %v", v)
+	for _, f := range []tmux.Feature{tmux.FeatureExitHook, tmux.FeatureCaptureEscapes} {
+		if err := tmux.Supports(f); err != nil {
+			log.Printf("[WARN] %v", err)
+		}
+	}
+}
+
+// kubernetes returns the template of the Kubernetes Jobs, or nil if Kubernetes
+// sessions are not enabled.
+func kubernetes() *pwrap.Kubernetes {
+	if kubeTemplate.Namespace == "" {
+		return nil
+	}
+	return &kubeTemplate
+}
+
+// namespaces returns the option applying the namespaced API keys and the session
+// limits of the namespaces, in the namespace=key and namespace=n forms.
+func namespaces(keys, limits []string) (func(*pmuxapi.Router), error) {
+	var opts []func(*pmuxapi.Router)
+	for _, v := range keys {
+		kv := strings.SplitN(v, "=", 2)
+		if len(kv) != 2 || kv[1] == "" || pwrap.ValidateNamespace(kv[0]) != nil {
+			return nil, fmt.Errorf("invalid namespace API key %q, expected namespace=key", v)
+		}
+		opts = append(opts, pmuxapi.NamespaceAPIKeys(kv[0], kv[1]))
+	}
+	for _, v := range limits {
+		kv := strings.SplitN(v, "=", 2)
+		if len(kv) != 2 || pwrap.ValidateNamespace(kv[0]) != nil {
+			return nil, fmt.Errorf("invalid namespace limit %q, expected namespace=n", v)
+		}
+		n, err := strconv.Atoi(kv[1])
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid namespace limit %q, expected namespace=n", v)
+		}
+		opts = append(opts, pmuxapi.NamespaceLimit(kv[0], n))
+	}
+	return func(r *pmuxapi.Router) {
+		for _, f := range opts {
+			f(r)
+		}
+	}, nil
+}
+
+// parseConfigVars returns the variables of configuration templates, nil if
+// templates are not "enabled".
+func parseConfigVars(enabled bool, kvs []string) (map[string]string, error) {
+	if !enabled {
+		if len(kvs) > 0 {
+			return nil, fmt.Errorf("--config-var requires --config-templates")
+		}
+		return nil, nil
+	}
+	vars := make(map[string]string, len(kvs))
+	for _, v := range kvs {
+		kv := strings.SplitN(v, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid configuration variable %q, expected name=value", v)
+		}
+		vars[kv[0]] = kv[1]
+	}
+	return vars, nil
+}
+
+// serverTLSConfig returns the TLS configuration selected by the flags, or nil if
+// the server has to listen in plaintext.
+func serverTLSConfig() (*tls.Config, error) {
+	if tlsCert == "" && tlsKey == "" {
+		if clientCA != "" {
+			return nil, fmt.Errorf("--client-ca requires --tls-cert and --tls-key")
+		}
+		return nil, nil
+	}
+	if tlsCert == "" || tlsKey == "" {
+		return nil, fmt.Errorf("both --tls-cert and --tls-key are required to enable TLS")
+	}
+	conf := &tls.Config{MinVersion: tls.VersionTLS12}
+	if clientCA == "" {
+		return conf, nil
+	}
+	data, err := ioutil.ReadFile(clientCA)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read client CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in client CA %s", clientCA)
+	}
+	conf.ClientCAs = pool
+	conf.ClientAuth = tls.RequireAndVerifyClientCert
+	return conf, nil
+}
+
 func init() {
 	rootCmd.AddCommand(serverCmd)
 
+	serverCmd.Flags().StringVarP(&serverConfig, "config", "c", "", "YAML file setting any of the options below, keyed by flag name, or JSON or TOML depending on its extension. Options can also be set with environment variables, e.g. $PMUX_MAX_RUNNING. Flags take precedence over the environment, which takes precedence over the file.")
 	serverCmd.Flags().IntVarP(&port, "port", "p", 4002, "Server listening port.")
+	serverCmd.Flags().StringVarP(&serverRootDir, "root-dir", "", pmuxapi.RootDir(), "Directory containing the working directories of the sessions.")
 	serverCmd.Flags().StringVarP(&execName, "exec-name", "n", "bin/mockcmd", "Pmux will spawn sessions running this executable.")
 	serverCmd.Flags().StringVarP(&childArgsRaw, "args", "", "", "Comma separated list of arguments that pmux will use togheter with \"execName\".")
+	serverCmd.Flags().StringArrayVarP(&execsRaw, "exec", "", []string{}, "Executable that sessions may select by name, in the name=path[,arg...] form. Can be repeated.")
+	serverCmd.Flags().StringArrayVarP(&serverWebhooks, "webhook", "", []string{}, "URL receiving the lifecycle events of every session, nats:// and redis:// URLs publishing them on an event bus. Can be repeated.")
+	serverCmd.Flags().StringArrayVarP(&apiKeys, "api-key", "", []string{}, "API key accepted by the session routes. Can be repeated.")
+	serverCmd.Flags().StringVarP(&jwtSecret, "jwt-secret", "", os.Getenv("PMUX_JWT_SECRET"), "Secret used to validate HS256 JSON Web Tokens presented to the session routes. Defaults to $PMUX_JWT_SECRET.")
+	serverCmd.Flags().StringVarP(&tlsCert, "tls-cert", "", "", "PEM encoded certificate used to serve the API over HTTPS.")
+	serverCmd.Flags().StringVarP(&tlsKey, "tls-key", "", "", "PEM encoded private key of the TLS certificate.")
+	serverCmd.Flags().StringVarP(&clientCA, "client-ca", "", "", "PEM encoded CA bundle used to verify client certificates. Enables mutual TLS.")
+	serverCmd.Flags().StringArrayVarP(&corsOrigins, "cors-origin", "", []string{}, "Origin allowed to perform cross-origin requests, \"*\" for any. Can be repeated.")
+	serverCmd.Flags().StringArrayVarP(&corsMethods, "cors-method", "", []string{}, "Method allowed to cross-origin requests. Can be repeated, defaults to GET, POST, PUT and DELETE.")
+	serverCmd.Flags().IntVarP(&maxRunning, "max-running", "", 0, "Maximum number of sessions running concurrently, further sessions are queued. Zero means no limit.")
+	serverCmd.Flags().BoolVarP(&preempt, "preempt", "", false, "Stop running sessions of lower priority, queueing them again, to start queued sessions when --max-running is reached.")
+	serverCmd.Flags().StringArrayVarP(&containerImages, "container-image", "", []string{}, "Docker image that sessions may run in. Can be repeated, sessions cannot use containers if not set.")
+	serverCmd.Flags().StringArrayVarP(&secretRefPrefixes, "secret-ref-prefix", "", []string{}, "Prefix of the secret references sessions may use, e.g. vault:secret/data/ci/. Can be repeated, sessions cannot use secrets if not set.")
+	serverCmd.Flags().StringArrayVarP(&containerMounts, "container-mount", "", []string{}, "Host path that containerized sessions may bind mount. Can be repeated.")
+	serverCmd.Flags().BoolVarP(&preflight, "preflight", "", false, "Probe the executable of every session with --help, and check its configuration, before starting it. Sessions failing the checks are rejected.")
+	serverCmd.Flags().StringVarP(&serverSockDir, "sock-dir", "", "", "Directory hosting the unix sockets of the children. Defaults to $XDG_RUNTIME_DIR, then to the temporary directory.")
+	serverCmd.Flags().StringVarP(&serverStreaming, "streaming", "", "auto", "How the wrapper API of the sessions delivers the progress and the streams of their children: hijack, flush or auto, which hijacks HTTP/1.x connections only.")
+	serverCmd.Flags().StringArrayVarP(&serverCommandOps, "command-op", "", []string{}, "Operation of the commands that the wrapper API of the sessions accepts. Can be repeated, defaults to pause, resume, cancel and reload.")
+	serverCmd.Flags().StringVarP(&serverPortRange, "port-range", "", "", "Range of ports the wrapper API of the sessions listens on, e.g. 42000-43000. Random free ports are used if empty.")
+	serverCmd.Flags().BoolVarP(&detach, "detach", "", false, "Start session wrappers as detached processes rather than inside tmux sessions. Implied when tmux is not installed.")
+	serverCmd.Flags().StringVarP(&kubeTemplate.Namespace, "kube-namespace", "", "", "Namespace of the Kubernetes Jobs sessions may run as. Kubernetes sessions are not allowed if empty.")
+	serverCmd.Flags().StringVarP(&kubeTemplate.Image, "kube-image", "", "", "Default image of the Kubernetes Jobs, providing both pmux and the executables.")
+	serverCmd.Flags().StringVarP(&kubeTemplate.PMux, "kube-pmux", "", "pmux", "Path of the pmux executable inside the images of the Kubernetes Jobs.")
+	serverCmd.Flags().StringVarP(&kubeTemplate.ServiceAccount, "kube-service-account", "", "", "Service account of the pods of the Kubernetes Jobs.")
+	serverCmd.Flags().StringArrayVarP(&sshHosts, "ssh-host", "", []string{}, "Host, in the [user@]hostname form, sessions may be placed on over SSH. Can be repeated.")
+	serverCmd.Flags().StringVarP(&sshRoot, "ssh-root-dir", "", "/tmp/pmux/sessionsd", "Directory containing the working directories of the sessions on the remote hosts.")
+	serverCmd.Flags().StringVarP(&sshPMux, "ssh-pmux", "", "pmux", "Path of the pmux executable on the remote hosts.")
+	serverCmd.Flags().StringVarP(&auditLog, "audit-log", "", "", "File the operations performed through the API are appended to, as JSON lines. Defaults to "+pmuxapi.AuditFile+" inside the root directory.")
+	serverCmd.Flags().StringVarP(&serverOTLPEndpoint, "otlp-endpoint", "", os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"), "OTLP/HTTP collector receiving the spans of the server and of the wrappers, e.g. http://localhost:4318. Defaults to $OTEL_EXPORTER_OTLP_ENDPOINT.")
+	serverCmd.Flags().StringVarP(&serverQuotaSize, "disk-quota", "", "", "Maximum size of the working directory of each session, e.g. 10G, which sessions may only lower. Not limited if empty.")
+	serverCmd.Flags().StringVarP(&serverQuotaAction, "disk-quota-action", "", pwrap.QuotaWarn, "Action performed when a working directory exceeds its quota: warn, reporting it as progress, or stop, failing the session.")
+	serverCmd.Flags().BoolVarP(&configTemplates, "config-templates", "", false, "Execute the strings of session configurations containing {{ as Go templates, e.g. {{.SID}} or {{.WorkDir}}, before storing them.")
+	serverCmd.Flags().StringArrayVarP(&configVars, "config-var", "", []string{}, "Variable available to configuration templates as {{.Vars.name}}, in the name=value form. Can be repeated.")
+	serverCmd.Flags().StringArrayVarP(&serverSidecars, "sidecar", "", []string{}, "Command that sessions may select by name to run alongside their child, in the name=path[,arg...] form. Can be repeated.")
+	serverCmd.Flags().StringVarP(&artifactsURL, "artifacts-url", "", "", "Bucket and prefix sessions upload their artifacts to, e.g. s3://bucket/prefix or gs://bucket/prefix. Credentials are read from the environment of the wrappers. Uploads are not allowed if empty.")
+	serverCmd.Flags().DurationVarP(&retention, "retention", "", 0, "Time finished sessions are kept for before being trashed, records included. Sessions may select their own. Zero keeps them forever.")
+	serverCmd.Flags().BoolVarP(&compressLogs, "compress-logs", "", false, "Compress the stdout and stderr of the sessions with gzip once they finish. The logs route and command decompress them transparently.")
+	serverCmd.Flags().StringArrayVarP(&namespaceKeys, "namespace-api-key", "", []string{}, "API key restricted to the sessions of a namespace, in the namespace=key form. Can be repeated.")
+	serverCmd.Flags().StringArrayVarP(&namespaceLimits, "namespace-limit", "", []string{}, "Maximum number of sessions of a namespace that are not finished, in the namespace=n form. Can be repeated.")
+	serverCmd.Flags().DurationVarP(&drainTimeout, "drain-timeout", "", time.Hour, "Maximum time waited for sessions to finish when draining, before shutting down.")
+	serverCmd.Flags().DurationVarP(&serverGracePeriod, "grace-period", "", pwrap.DefaultGracePeriod, "Time given to sessions to exit gracefully when deleted, before they are killed.")
+	serverCmd.Flags().BoolVarP(&daemon, "daemon", "d", false, "Detach from the terminal and run in the background.")
+	serverCmd.Flags().StringVarP(&pidFile, "pid-file", "", "", "File storing the PID of the server while it runs.")
+	serverCmd.Flags().StringVarP(&logFile, "log-file", "", "", "File receiving the output of the server when running as a daemon. The output is discarded if empty.")
 	serverCmd.Flags().BoolVarP(&dirty, "dirty", "", false, "Enables dirty mode: all files created by pmux child processes are kept.")
 }
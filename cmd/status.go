@@ -0,0 +1,117 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/kim-company/pmux/client"
+	"github.com/spf13/cobra"
+)
+
+var statusServer string
+var statusAPIKey string
+var statusExec string
+var statusConfig string
+var statusRegisterURL string
+
+// statusCmd represents the status command
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Manage the sessions of a remote pmux server",
+}
+
+var statusListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the sessions of the server",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		sessions, _, err := remoteClient().ListSessions(context.Background(), nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+		printOutput(sessions, func() { printSessions(sessions) })
+	},
+}
+
+var statusShowCmd = &cobra.Command{
+	Use:   "show <sid>",
+	Short: "Print the state of a session of the server",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		d, err := remoteClient().GetSession(context.Background(), args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		printOutput(d, func() { printSession(d) })
+	},
+}
+
+var statusCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Start a new session on the server, printing its identifier",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		req := &client.CreateRequest{Exec: statusExec, RegisterURL: statusRegisterURL, Config: json.RawMessage("{}")}
+		if statusConfig != "" {
+			data, err := os.ReadFile(statusConfig)
+			if err != nil {
+				log.Fatal(err)
+			}
+			req.Config = json.RawMessage(data)
+		}
+		sid, err := remoteClient().CreateSession(context.Background(), req)
+		if err != nil {
+			log.Fatal(err)
+		}
+		printSID(sid)
+	},
+}
+
+var statusDeleteCmd = &cobra.Command{
+	Use:   "delete <sid>",
+	Short: "Terminate a session of the server, trashing its files",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := remoteClient().DeleteSession(context.Background(), args[0]); err != nil {
+			log.Fatal(err)
+		}
+		printSID(args[0])
+	},
+}
+
+// printSID prints the session identifier "sid" in the selected output format.
+func printSID(sid string) {
+	resp := struct {
+		SID string `json:"sid"`
+	}{sid}
+	printOutput(&resp, func() { fmt.Println(sid) })
+}
+
+// remoteClient returns a client of the server selected with the status flags.
+func remoteClient() *client.Client {
+	return client.New(statusServer, client.APIKey(statusAPIKey), client.HTTPClient(&http.Client{Timeout: time.Minute}))
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+	statusCmd.AddCommand(statusListCmd, statusShowCmd, statusCreateCmd, statusDeleteCmd)
+
+	server := os.Getenv("PMUX_SERVER")
+	if server == "" {
+		server = "http://localhost:4002"
+	}
+	statusCmd.PersistentFlags().StringVarP(&statusServer, "server", "s", server, "Address of the pmux server. Defaults to $PMUX_SERVER.")
+	statusCmd.PersistentFlags().StringVarP(&statusAPIKey, "api-key", "", os.Getenv("PMUX_API_KEY"), "API key presented to the server. Defaults to $PMUX_API_KEY.")
+	statusCreateCmd.Flags().StringVarP(&statusExec, "exec", "", "", "Name of the executable run by the session, as whitelisted on the server.")
+	statusCreateCmd.Flags().StringVarP(&statusConfig, "config", "c", "", "Path of the JSON configuration passed to the session. An empty object is used if not set.")
+	statusCreateCmd.Flags().StringVarP(&statusRegisterURL, "register-url", "", "", "URL the session registers its API to.")
+}
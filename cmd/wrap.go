@@ -10,13 +10,37 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"github.com/kim-company/pmux/http/pwrapapi"
 	"github.com/kim-company/pmux/pwrap"
+	"github.com/kim-company/pmux/secrets"
 	"github.com/kim-company/pmux/tmux"
+	"github.com/kim-company/pmux/trace"
 	"github.com/spf13/cobra"
 )
 
 var rootDir, sid, url, stderr string
+var gracePeriod time.Duration
+var transport string
+var restarts int
+var stopCommand string
+var webhooks []string
+var regHeaders []string
+var container pwrap.Container
+var traceparent, otlpEndpoint string
+var quotaSize, quotaAction string
+var secretRefs []string
+var envVars []string
+var artifacts pwrap.Artifacts
+var openStdin bool
+var resume bool
+var portRange string
+var sidecarsRaw []string
+var sockDir string
+var streaming string
+var commandOps []string
+var maxRuntime time.Duration
 
 // wrapCmd represents the pwrap command
 var wrapCmd = &cobra.Command{
@@ -44,33 +68,151 @@ var wrapCmd = &cobra.Command{
 
 		// Note: tmux sends SIGHUP to all child processes when the session
 		// is terminated. Children need to be killed when that happens.
+		// SIGTERM is sent by pmux when it wants the session to terminate
+		// gracefully.
 		srx := make(chan os.Signal, 1)
-		signal.Notify(srx, syscall.SIGHUP, os.Interrupt)
+		signal.Notify(srx, syscall.SIGHUP, syscall.SIGTERM, os.Interrupt)
 		go func() {
 			s := <-srx
 			log.Printf("[INFO] signal %v received. Exiting...", s)
 			cancel()
 		}()
 
+		trace.SetEndpoint(otlpEndpoint, "pmux-wrap")
+		var tc trace.SpanContext
+		if traceparent != "" {
+			var err error
+			if tc, err = trace.ParseTraceparent(traceparent); err != nil {
+				log.Printf("[WARN] %v", err)
+			}
+		}
+		var c *pwrap.Container
+		if container.Image != "" {
+			c = &container
+		}
+		refs, err := secrets.ParseRefs(secretRefs)
+		if err != nil {
+			log.Fatal(err)
+		}
+		headers, err := pwrap.ParseHeaders(regHeaders)
+		if err != nil {
+			log.Fatal(err)
+		}
+		env, err := pwrap.ParseEnv(envVars)
+		if err != nil {
+			log.Fatal(err)
+		}
+		q, err := diskQuota(quotaSize, quotaAction)
+		if err != nil {
+			log.Fatal(err)
+		}
+		var a *pwrap.Artifacts
+		if len(artifacts.Paths) > 0 {
+			a = &artifacts
+		}
+		sidecars := make([]pwrap.Sidecar, 0, len(sidecarsRaw))
+		for _, v := range sidecarsRaw {
+			s, err := pwrap.ParseSidecar(v)
+			if err != nil {
+				log.Fatal(err)
+			}
+			sidecars = append(sidecars, s)
+		}
+		mode, err := pwrapapi.ParseStreamMode(streaming)
+		if err != nil {
+			log.Fatal(err)
+		}
+		var ports *pwrap.PortRange
+		if portRange != "" {
+			if ports, err = pwrap.ParsePortRange(portRange); err != nil {
+				log.Fatal(err)
+			}
+		}
 		pw, err := pwrap.New(
+			pwrap.Docker(c),
+			pwrap.DiskQuota(q),
+			pwrap.MaxRuntime(maxRuntime),
+			pwrap.Secrets(refs),
+			pwrap.Env(env),
+			pwrap.UploadArtifacts(a),
+			pwrap.Stdin(openStdin),
+			pwrap.ResumeChild(resume),
+			pwrap.APIPorts(ports),
+			pwrap.Sidecars(sidecars...),
+			pwrap.SockDir(sockDir),
+			pwrap.Streaming(mode),
+			pwrap.CommandOps(commandOps...),
 			pwrap.Exec(args[0], args[1:]...),
 			pwrap.OverrideSID(sid),
 			pwrap.RootDir(rootDir),
 			pwrap.Register(url),
+			pwrap.RegisterHeaders(headers),
+			pwrap.GracePeriod(gracePeriod),
+			pwrap.Transport(transport),
+			pwrap.Restarts(restarts),
+			pwrap.StopCommand(stopCommand),
+			pwrap.Webhooks(webhooks...),
+			pwrap.Trace(tc),
 		)
 		if err != nil {
 			log.Fatal(err)
 		}
-		if err := pw.Run(ctx); err != nil {
+		err = pw.Run(ctx)
+		trace.Flush(context.Background())
+		if err != nil {
 			log.Fatal(err)
 		}
 	},
 }
 
+// diskQuota returns the quota described by the "size" and "action" flags, or
+// nil if "size" is empty.
+func diskQuota(size, action string) (*pwrap.Quota, error) {
+	if size == "" {
+		return nil, nil
+	}
+	n, err := pwrap.ParseSize(size)
+	if err != nil {
+		return nil, err
+	}
+	q := &pwrap.Quota{Bytes: n, Action: action}
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
 func init() {
 	rootCmd.AddCommand(wrapCmd)
 	wrapCmd.Flags().StringVarP(&rootDir, "root", "", "", "Root process sandbox directory.")
 	wrapCmd.Flags().StringVarP(&sid, "sid", "", tmux.NewSID(), "Override session identifier.")
 	wrapCmd.Flags().StringVarP(&url, "reg-url", "", "", "Set registration URL to contact before running the task.")
+	wrapCmd.Flags().StringArrayVarP(&regHeaders, "reg-header", "", []string{}, "HTTP header attached to the registration and callback requests, in the \"Name: value\" form. Can be repeated.")
 	wrapCmd.Flags().StringVarP(&stderr, "stderr", "", "", "Pipe wrapper's stderr.")
+	wrapCmd.Flags().StringVarP(&transport, "transport", "", pwrap.TransportUnix, "Transport used to communicate with the child: unix, tcp, pipe or grpc.")
+	wrapCmd.Flags().IntVarP(&restarts, "restarts", "", 0, "Number of times the session has been restarted.")
+	wrapCmd.Flags().StringVarP(&stopCommand, "stop-command", "", "", "Operation of the command delivered to the child to make it stop gracefully, e.g. cancel. SIGTERM is used if empty.")
+	wrapCmd.Flags().StringArrayVarP(&webhooks, "webhook", "", []string{}, "URL receiving the session lifecycle events, nats:// and redis:// URLs publishing them on an event bus. Can be repeated.")
+	wrapCmd.Flags().StringVarP(&container.Image, "docker-image", "", "", "Docker image the child is executed in. The child runs on the host if empty.")
+	wrapCmd.Flags().StringArrayVarP(&container.Mounts, "docker-mount", "", []string{}, "Bind mount of the child's container, in the source:destination[:options] form. Can be repeated.")
+	wrapCmd.Flags().StringVarP(&container.CPUs, "docker-cpus", "", "", "Number of CPUs available to the child's container.")
+	wrapCmd.Flags().StringVarP(&container.Memory, "docker-memory", "", "", "Memory limit of the child's container, e.g. 512m.")
+	wrapCmd.Flags().StringVarP(&container.Network, "docker-network", "", "", "Network the child's container is attached to. Defaults to host.")
+	wrapCmd.Flags().StringVarP(&quotaSize, "disk-quota", "", "", "Maximum size of the working directory, e.g. 10G. Not limited if empty.")
+	wrapCmd.Flags().StringVarP(&quotaAction, "disk-quota-action", "", pwrap.QuotaWarn, "Action performed when the working directory exceeds its quota: warn, reporting it as progress, or stop.")
+	wrapCmd.Flags().DurationVarP(&maxRuntime, "max-runtime", "", 0, "Time the child is allowed to run for, before being stopped. Not limited if zero.")
+	wrapCmd.Flags().StringArrayVarP(&secretRefs, "secret", "", []string{}, "Secret injected into the environment of the child, in the NAME=scheme:location form, e.g. TOKEN=vault:secret/data/app#token. Can be repeated.")
+	wrapCmd.Flags().StringArrayVarP(&envVars, "env", "", []string{}, "Environment variable added to the environment of the child, in the NAME=value form. Can be repeated.")
+	wrapCmd.Flags().StringArrayVarP(&artifacts.Paths, "artifact", "", []string{}, "Glob pattern, relative to the working directory, selecting the files uploaded once the child exits. Can be repeated.")
+	wrapCmd.Flags().StringVarP(&artifacts.Destination, "artifacts-url", "", "", "Bucket and prefix artifacts are uploaded to, e.g. s3://bucket/prefix or gs://bucket/prefix, followed by the session identifier.")
+	wrapCmd.Flags().BoolVarP(&openStdin, "stdin", "", false, "Connect the stdin of the child to a pipe, fed through the /stdin route of the wrapper API.")
+	wrapCmd.Flags().StringVarP(&portRange, "port-range", "", "", "Range of ports the wrapper API listens on, e.g. 42000-43000. A random free port is used if empty.")
+	wrapCmd.Flags().BoolVarP(&resume, "resume", "", false, "Pass the checkpoint of the working directory to the child with the --resume flag.")
+	wrapCmd.Flags().StringArrayVarP(&sidecarsRaw, "sidecar", "", []string{}, "Command started alongside the child and stopped with it, in the name=path[,arg...] form. Can be repeated.")
+	wrapCmd.Flags().StringVarP(&streaming, "streaming", "", "auto", "How the progress and the streams of the child are delivered: hijack, flush or auto, which hijacks HTTP/1.x connections only.")
+	wrapCmd.Flags().StringArrayVarP(&commandOps, "command-op", "", []string{}, "Operation of the commands accepted by the wrapper API. Can be repeated, defaults to pause, resume, cancel and reload.")
+	wrapCmd.Flags().StringVarP(&sockDir, "sock-dir", "", "", "Directory hosting the unix socket of the child. Defaults to $XDG_RUNTIME_DIR, then to the temporary directory.")
+	wrapCmd.Flags().StringVarP(&traceparent, "traceparent", "", "", "W3C trace context the spans of the wrapper descend from.")
+	wrapCmd.Flags().StringVarP(&otlpEndpoint, "otlp-endpoint", "", os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"), "OTLP/HTTP collector receiving the spans of the wrapper. Defaults to $OTEL_EXPORTER_OTLP_ENDPOINT.")
+	wrapCmd.Flags().DurationVarP(&gracePeriod, "grace-period", "", pwrap.DefaultGracePeriod, "Time given to the child to exit after SIGTERM, before it is killed.")
 }
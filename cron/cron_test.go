@@ -0,0 +1,52 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	for _, v := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "*/0 * * * *", "5-1 * * * *", "* * * foo *", "@often"} {
+		if _, err := Parse(v); err == nil {
+			t.Fatalf("%q SHOULD NOT be a valid expression", v)
+		}
+	}
+}
+
+func TestSchedule_Next(t *testing.T) {
+	t.Parallel()
+
+	// Wednesday.
+	now := time.Date(2020, time.January, 8, 15, 24, 23, 0, time.UTC)
+	for _, tt := range []struct {
+		expr string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2020, time.January, 8, 15, 25, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2020, time.January, 8, 15, 30, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2020, time.January, 9, 3, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2020, time.January, 9, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * sun", time.Date(2020, time.January, 12, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2020, time.January, 12, 0, 0, 0, 0, time.UTC)},
+		{"0 9 * * mon-fri", time.Date(2020, time.January, 9, 9, 0, 0, 0, time.UTC)},
+		{"30 12 1,15 * *", time.Date(2020, time.January, 15, 12, 30, 0, 0, time.UTC)},
+		{"0 0 29 feb *", time.Date(2020, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		// Either the day of the month or the day of the week.
+		{"0 0 20 * fri", time.Date(2020, time.January, 10, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	} {
+		s, err := Parse(tt.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if next := s.Next(now); !next.Equal(tt.next) {
+			t.Fatalf("%q: wanted %v, found %v", tt.expr, tt.next, next)
+		}
+	}
+}
@@ -9,16 +9,17 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"strings"
 	"time"
 
 	"github.com/kim-company/pmux/pwrap"
+	"github.com/kim-company/pmux/pwrap/report"
 	"github.com/spf13/cobra"
 )
 
 var (
 	configPath string
 	sockPath   string
+	transport  string
 )
 
 // mockCmd represents the mockcmd command
@@ -29,58 +30,51 @@ var mockCmd = &cobra.Command{
 		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
 
-		pw, close := makeProgressWriter(ctx, cancel, sockPath)
-		defer close()
+		r, err := report.New(ctx, transport, sockPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer r.Close()
+		r.OnCommand(func(cmd string) (string, error) {
+			log.Printf("[INFO] command received: %v", cmd)
+			c, err := pwrap.ParseCommand(cmd)
+			if err != nil {
+				return "", err
+			}
+			switch c.Op {
+			case pwrap.CommandCancel:
+				cancel()
+				return "canceled", nil
+			case pwrap.CommandReload:
+				data, err := os.ReadFile(configPath)
+				if err != nil {
+					return "", err
+				}
+				log.Printf("[INFO] configuration reloaded: %s", data)
+				return "reloaded", nil
+			}
+			return "", fmt.Errorf("unknown command %q", c.Op)
+		})
 
 		for i := 0; ; i++ {
 			select {
 			case <-time.After(time.Millisecond * 1000):
-				if err := pw("waited 1 second", -1, -1, i, -1); err != nil {
+				if err := r.WriteProgressUpdate("waited 1 second", -1, -1, i, -1); err != nil {
 					log.Printf("[ERROR] %v", err)
 				}
 			case <-ctx.Done():
 				log.Printf("[INFO] exiting: %v", ctx.Err())
+				r.Result(map[string]int{"seconds": i})
 				return
 			}
 		}
 	},
 }
 
-func writeProgressUpdateDefault(d string, stage, stages, partial, tot int) error {
-	fmt.Fprintf(os.Stdout, "%d: %s\n", partial, d)
-	return nil
-}
-
-func makeProgressWriter(ctx context.Context, cancel context.CancelFunc, sockPath string) (pwrap.WriteProgressUpdateFunc, func()) {
-	if sockPath == "" {
-		return writeProgressUpdateDefault, func() {}
-	}
-
-	br, err := pwrap.NewUnixCommBridge(ctx, sockPath, makeOnCommandOption(cancel))
-	if err != nil {
-		log.Printf("[ERROR] unable to make progress writer: %v", err)
-		return writeProgressUpdateDefault, func() {}
-	}
-	go br.Open(ctx)
-	return br.WriteProgressUpdate, func() {
-		br.Close()
-	}
-}
-
-func makeOnCommandOption(cancel context.CancelFunc) func(*pwrap.UnixCommBridge) {
-	return pwrap.OnCommand(func(u *pwrap.UnixCommBridge, cmd string) error {
-		log.Printf("[INFO] command received: %v", cmd)
-		if strings.Contains(cmd, "cancel") {
-			cancel()
-			return u.Close()
-		}
-		return nil
-	})
-}
-
 func init() {
 	mockCmd.Flags().StringVarP(&configPath, "config", "", "config.json", "Path to the configuration file.")
 	mockCmd.Flags().StringVarP(&sockPath, "socket-path", "", "", "Path to the communication socket address.")
+	mockCmd.Flags().StringVarP(&transport, "socket-transport", "", pwrap.TransportUnix, "Transport of the communication socket.")
 }
 
 func main() {
@@ -3,9 +3,16 @@ module github.com/kim-company/pmux
 go 1.13
 
 require (
+	github.com/golang/protobuf v1.3.2
 	github.com/google/uuid v1.1.1
 	github.com/gorilla/mux v1.7.3
 	github.com/phayes/freeport v0.0.0-20180830031419-95f893ade6f2
+	github.com/spf13/cast v1.3.0
 	github.com/spf13/cobra v0.0.5
+	github.com/spf13/pflag v1.0.3
+	github.com/spf13/viper v1.7.1
+	go.etcd.io/bbolt v1.3.6
+	google.golang.org/grpc v1.21.1
 	gopkg.in/pipe.v2 v2.0.0-20140414041502-3c2ca4d52544
+	gopkg.in/yaml.v2 v2.4.0
 )
@@ -0,0 +1,34 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"fmt"
+
+	"github.com/kim-company/pmux/pwrap"
+)
+
+// ArtifactsDestination allows sessions to upload files of their working directory
+// once their child exits, to the bucket and prefix "url", e.g. "s3://bucket/prefix".
+// Every session uploads its files below its own identifier. Uploads are not
+// allowed if empty.
+func ArtifactsDestination(url string) func(*Router) {
+	return func(r *Router) {
+		r.artifacts = url
+	}
+}
+
+// artifactsFor returns the artifacts of a session requesting the upload of the
+// files matching "paths", nil if none.
+func (h *SessionHandler) artifactsFor(paths []string) (*pwrap.Artifacts, error) {
+	if len(paths) == 0 {
+		return nil, nil
+	}
+	if h.artifacts == "" {
+		return nil, fmt.Errorf("artifact uploads are not enabled")
+	}
+	a := &pwrap.Artifacts{Paths: paths, Destination: h.artifacts}
+	return a, a.Validate()
+}
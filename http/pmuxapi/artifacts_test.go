@@ -0,0 +1,30 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import "testing"
+
+func TestSessionHandler_ArtifactsFor(t *testing.T) {
+	t.Parallel()
+
+	h := &SessionHandler{}
+	if a, err := h.artifactsFor(nil); a != nil || err != nil {
+		t.Fatalf("sessions without artifacts SHOULD NOT upload any: %v, %v", a, err)
+	}
+	if _, err := h.artifactsFor([]string{"*.mp4"}); err == nil {
+		t.Fatal("artifacts SHOULD NOT be accepted when uploads are not enabled")
+	}
+	h.artifacts = "s3://media/pmux"
+	a, err := h.artifactsFor([]string{"*.mp4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Destination != h.artifacts {
+		t.Fatalf("artifacts SHOULD be uploaded to the server's destination, found %s", a.Destination)
+	}
+	if _, err := h.artifactsFor([]string{"/etc/*"}); err == nil {
+		t.Fatal("patterns outside of the working directory SHOULD NOT be accepted")
+	}
+}
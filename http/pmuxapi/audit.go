@@ -0,0 +1,387 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// AuditFile is the name of the file used by the default audit log, inside the
+// root directory.
+const AuditFile = "audit.jsonl"
+
+// Actions recorded in the audit log, which are also the names of the routes
+// performing them.
+const (
+	ActionCreate       = "create"
+	ActionDelete       = "delete"
+	ActionBulkDelete   = "bulk_delete"
+	ActionRestart      = "restart"
+	ActionResume       = "resume"
+	ActionPause        = "pause"
+	ActionUnpause      = "unpause"
+	ActionUpdateConfig = "update_config"
+	ActionCommand      = "command"
+	ActionStdin        = "stdin"
+	ActionTerminal     = "terminal"
+	ActionDrain        = "drain"
+	// ActionDeleteSchedule cancels the future runs of a schedule.
+	ActionDeleteSchedule = "delete_schedule"
+	ActionCreatePipeline = "create_pipeline"
+	ActionDeletePipeline = "delete_pipeline"
+	ActionDeleteGroup    = "delete_group"
+	ActionReload         = "reload"
+)
+
+// auditDetailSize is the maximum size of the request body recorded with
+// commands.
+const auditDetailSize = 4096
+
+// AuditEntry records an operation performed through the API.
+type AuditEntry struct {
+	Time time.Time `json:"time"`
+	// Actor identifies the client: the subject of its JSON Web Token, the
+	// fingerprint of its API key, or "anonymous" when authentication is disabled.
+	Actor  string `json:"actor"`
+	Action string `json:"action"`
+	// Namespace is the namespace selected by the request.
+	Namespace string `json:"namespace,omitempty"`
+	// SIDs are the sessions the operation targeted.
+	SIDs         []string `json:"sids,omitempty"`
+	Method       string   `json:"method"`
+	Path         string   `json:"path"`
+	Query        string   `json:"query,omitempty"`
+	RemoteAddr   string   `json:"remote_addr"`
+	ForwardedFor string   `json:"forwarded_for,omitempty"`
+	UserAgent    string   `json:"user_agent,omitempty"`
+	// Status is the HTTP status code of the response.
+	Status int `json:"status"`
+	// Detail is the body of command requests.
+	Detail string `json:"detail,omitempty"`
+}
+
+// AuditFilter selects the entries returned by "AuditLog.Query". Empty fields
+// match every entry.
+type AuditFilter struct {
+	SID       string
+	Actor     string
+	Action    string
+	Namespace string
+	Since     time.Time
+	Until     time.Time
+	// Limit is the maximum number of entries returned, the most recent
+	// ones, 0 meaning no limit.
+	Limit int
+}
+
+// ParseAuditFilter parses the "sid", "actor", "action", "namespace", "since",
+// "until" and "limit" query parameters. Times are in the RFC 3339 format.
+func ParseAuditFilter(q url.Values) (*AuditFilter, error) {
+	f := &AuditFilter{SID: q.Get("sid"), Actor: q.Get("actor"), Action: q.Get("action"), Namespace: q.Get("namespace")}
+	var err error
+	if v := q.Get("since"); v != "" {
+		if f.Since, err = time.Parse(time.RFC3339, v); err != nil {
+			return nil, fmt.Errorf("invalid since parameter %q: %w", v, err)
+		}
+	}
+	if v := q.Get("until"); v != "" {
+		if f.Until, err = time.Parse(time.RFC3339, v); err != nil {
+			return nil, fmt.Errorf("invalid until parameter %q: %w", v, err)
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil || f.Limit < 0 {
+			return nil, fmt.Errorf("invalid limit parameter %q", v)
+		}
+	}
+	return f, nil
+}
+
+// Values encodes "f" as the query parameters parsed by "ParseAuditFilter".
+func (f *AuditFilter) Values() url.Values {
+	q := url.Values{}
+	if f.SID != "" {
+		q.Set("sid", f.SID)
+	}
+	if f.Actor != "" {
+		q.Set("actor", f.Actor)
+	}
+	if f.Action != "" {
+		q.Set("action", f.Action)
+	}
+	if f.Namespace != "" {
+		q.Set("namespace", f.Namespace)
+	}
+	if !f.Since.IsZero() {
+		q.Set("since", f.Since.Format(time.RFC3339))
+	}
+	if !f.Until.IsZero() {
+		q.Set("until", f.Until.Format(time.RFC3339))
+	}
+	if f.Limit > 0 {
+		q.Set("limit", strconv.Itoa(f.Limit))
+	}
+	return q
+}
+
+// Match reports whether "e" satisfies the filter.
+func (f *AuditFilter) Match(e *AuditEntry) bool {
+	if f.Actor != "" && e.Actor != f.Actor {
+		return false
+	}
+	if f.Action != "" && e.Action != f.Action {
+		return false
+	}
+	if f.Namespace != "" && e.Namespace != f.Namespace {
+		return false
+	}
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !e.Time.Before(f.Until) {
+		return false
+	}
+	if f.SID != "" && !contains(e.SIDs, f.SID) {
+		return false
+	}
+	return true
+}
+
+// AuditLog records the operations performed through the API. Entries cannot
+// be modified nor deleted.
+type AuditLog interface {
+	Append(e *AuditEntry) error
+	// Query returns the entries matching "f", in chronological order.
+	Query(f *AuditFilter) ([]*AuditEntry, error)
+}
+
+// FileAuditLog is an AuditLog that appends its entries to a file, one JSON
+// document per line.
+type FileAuditLog struct {
+	path string
+
+	sync.Mutex
+	f *os.File
+}
+
+// NewFileAuditLog returns an audit log appending to the file at "path", which is
+// created if it does not exist.
+func NewFileAuditLog(path string) (*FileAuditLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return nil, fmt.Errorf("unable to create audit log: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open audit log: %w", err)
+	}
+	return &FileAuditLog{path: path, f: f}, nil
+}
+
+// Append writes "e" to the file, which is synced before returning.
+func (l *FileAuditLog) Append(e *AuditEntry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("unable to encode audit entry: %w", err)
+	}
+	l.Lock()
+	defer l.Unlock()
+	if _, err := l.f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("unable to write audit entry: %w", err)
+	}
+	return l.f.Sync()
+}
+
+func (l *FileAuditLog) Query(f *AuditFilter) ([]*AuditEntry, error) {
+	l.Lock()
+	defer l.Unlock()
+	file, err := os.Open(l.path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open audit log: %w", err)
+	}
+	defer file.Close()
+
+	acc := []*AuditEntry{}
+	r := bufio.NewReader(file)
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var e AuditEntry
+			if err := json.Unmarshal(line, &e); err != nil {
+				return nil, fmt.Errorf("unable to decode audit entry: %w", err)
+			}
+			if f.Match(&e) {
+				acc = append(acc, &e)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to read audit log: %w", err)
+		}
+	}
+	if f.Limit > 0 && len(acc) > f.Limit {
+		acc = acc[len(acc)-f.Limit:]
+	}
+	return acc, nil
+}
+
+// Close closes the underlying file.
+func (l *FileAuditLog) Close() error {
+	l.Lock()
+	defer l.Unlock()
+	return l.f.Close()
+}
+
+// Audit sets the log recording the operations performed through the API.
+// Defaults to a "FileAuditLog" kept in the root directory.
+func Audit(l AuditLog) func(*Router) {
+	return func(r *Router) {
+		r.audit = l
+	}
+}
+
+type actorKey struct{}
+
+// ActorFromContext returns the identity of the client that performed the
+// request, as recorded in the audit log.
+func ActorFromContext(ctx context.Context) string {
+	if v, ok := ctx.Value(actorKey{}).(string); ok {
+		return v
+	}
+	return "anonymous"
+}
+
+// keyFingerprint identifies an API key without disclosing it.
+func keyFingerprint(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "key:" + hex.EncodeToString(sum[:6])
+}
+
+type auditKey struct{}
+
+// auditSessions records "sids" as the targets of the operation performed by the
+// request carrying "ctx", if audited.
+func auditSessions(ctx context.Context, sids ...string) {
+	if e, ok := ctx.Value(auditKey{}).(*AuditEntry); ok {
+		e.SIDs = append(e.SIDs, sids...)
+	}
+}
+
+// statusRecorder records the status code written to the response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Unwrap allows "http.ResponseController" to reach the underlying writer.
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// auditMiddleware records the requests served by named routes in "l", once
+// they are completed.
+func auditMiddleware(l AuditLog) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := mux.CurrentRoute(r)
+			if route == nil || route.GetName() == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			e := &AuditEntry{
+				Time:         time.Now(),
+				Actor:        ActorFromContext(r.Context()),
+				Action:       route.GetName(),
+				Namespace:    NamespaceFromContext(r.Context()),
+				Method:       r.Method,
+				Path:         r.URL.Path,
+				Query:        r.URL.RawQuery,
+				RemoteAddr:   r.RemoteAddr,
+				ForwardedFor: r.Header.Get("X-Forwarded-For"),
+				UserAgent:    r.UserAgent(),
+			}
+			if sid, ok := mux.Vars(r)["sid"]; ok {
+				e.SIDs = []string{sid}
+			}
+			if e.Action == ActionCommand {
+				// The body is read again by the wrapper.
+				body, _ := io.ReadAll(io.LimitReader(r.Body, auditDetailSize))
+				e.Detail = string(body)
+				r.Body = struct {
+					io.Reader
+					io.Closer
+				}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+			}
+
+			rec := &statusRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), auditKey{}, e)))
+			e.Status = rec.status
+			if e.Status == 0 {
+				e.Status = http.StatusOK
+			}
+			if err := l.Append(e); err != nil {
+				log.Printf("[ERROR] %s by %s was not audited: %v", e.Action, e.Actor, err)
+			}
+		})
+	}
+}
+
+// HandleAudit returns the entries of the audit log selected by the filters
+// described in "ParseAuditFilter". Namespaced clients only obtain the entries
+// of the namespace they selected.
+func (r *Router) HandleAudit() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if r.audit == nil {
+			r.h.writeError(w, fmt.Errorf("audit log not available"), http.StatusNotFound)
+			return
+		}
+		f, err := ParseAuditFilter(req.URL.Query())
+		if err != nil {
+			r.h.writeError(w, err, http.StatusBadRequest)
+			return
+		}
+		if scopeFromContext(req.Context()) != nil {
+			f.Namespace = NamespaceFromContext(req.Context())
+		}
+		entries, err := r.audit.Query(f)
+		if err != nil {
+			r.h.writeError(w, err, http.StatusInternalServerError)
+			return
+		}
+		r.h.writeResponse(w, entries)
+	}
+}
@@ -0,0 +1,106 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileAuditLog(t *testing.T) {
+	t.Parallel()
+
+	dir, err := os.MkdirTemp("", "pmux-audit-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	l, err := NewFileAuditLog(filepath.Join(dir, AuditFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	now := time.Now()
+	entries := []*AuditEntry{
+		{Time: now.Add(-time.Hour), Actor: "alice", Action: ActionCreate, SIDs: []string{"pmux-a"}},
+		{Time: now.Add(-time.Minute), Actor: "bob", Action: ActionBulkDelete, SIDs: []string{"pmux-a", "pmux-b"}},
+		{Time: now, Actor: "alice", Action: ActionDelete, SIDs: []string{"pmux-b"}},
+	}
+	for _, v := range entries {
+		if err := l.Append(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tt := []struct {
+		f    AuditFilter
+		want []string
+	}{
+		{AuditFilter{}, []string{ActionCreate, ActionBulkDelete, ActionDelete}},
+		{AuditFilter{SID: "pmux-a"}, []string{ActionCreate, ActionBulkDelete}},
+		{AuditFilter{Actor: "alice"}, []string{ActionCreate, ActionDelete}},
+		{AuditFilter{Since: now.Add(-time.Hour / 2)}, []string{ActionBulkDelete, ActionDelete}},
+		{AuditFilter{Limit: 1}, []string{ActionDelete}},
+	}
+	for i, v := range tt {
+		found, err := l.Query(&v.f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(found) != len(v.want) {
+			t.Fatalf("%d: unexpected number of entries: %d", i, len(found))
+		}
+		for j, e := range found {
+			if e.Action != v.want[j] {
+				t.Fatalf("%d: unexpected entry %d: %+v", i, j, e)
+			}
+		}
+	}
+}
+
+func TestRouter_Audit(t *testing.T) {
+	t.Parallel()
+
+	dir, err := os.MkdirTemp("", "pmux-audit-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	l, err := NewFileAuditLog(filepath.Join(dir, AuditFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	r := NewRouter("yes", APIKeys("secret"), Audit(l))
+	for _, v := range []struct{ method, path string }{
+		{"GET", "/api/v1/sessions/pmux-missing"},
+		{"DELETE", "/api/v1/sessions/pmux-missing"},
+	} {
+		req := httptest.NewRequest(v.method, v.path, nil)
+		req.Header.Set("X-API-Key", "secret")
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	entries, err := l.Query(&AuditFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("unexpected number of entries: %d", len(entries))
+	}
+	e := entries[0]
+	if e.Action != ActionDelete || e.Actor != keyFingerprint("secret") || len(e.SIDs) != 1 || e.SIDs[0] != "pmux-missing" {
+		t.Fatalf("unexpected entry: %+v", e)
+	}
+	if e.Status != http.StatusOK {
+		t.Fatalf("unexpected status recorded: %d", e.Status)
+	}
+}
@@ -0,0 +1,172 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// APIKeys allows clients presenting one of "keys" to use the session routes.
+func APIKeys(keys ...string) func(*Router) {
+	return func(r *Router) {
+		r.apiKeys = append(r.apiKeys, keys...)
+	}
+}
+
+// JWTSecret allows clients presenting a JSON Web Token signed with HS256 using
+// "secret" to use the session routes.
+func JWTSecret(secret []byte) func(*Router) {
+	return func(r *Router) {
+		r.jwtSecret = secret
+	}
+}
+
+// Claims are the claims of a validated JSON Web Token.
+type Claims map[string]interface{}
+
+type claimsKey struct{}
+
+// ClaimsFromContext returns the claims of the token used to authenticate the
+// request, if it was a JSON Web Token.
+func ClaimsFromContext(ctx context.Context) (Claims, bool) {
+	c, ok := ctx.Value(claimsKey{}).(Claims)
+	return c, ok
+}
+
+// authenticator validates the credentials presented by clients, either in the
+// Authorization header as bearer tokens or in the X-API-Key header.
+type authenticator struct {
+	keys []string
+	// scopes maps the keys restricted to some namespaces to them.
+	scopes map[string][]string
+	secret []byte
+}
+
+func (a *authenticator) enabled() bool {
+	return len(a.keys) > 0 || len(a.scopes) > 0 || len(a.secret) > 0
+}
+
+func (a *authenticator) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get("X-API-Key")
+		if h := r.Header.Get("Authorization"); token == "" && len(h) > 7 && strings.EqualFold(h[:7], "Bearer ") {
+			token = strings.TrimSpace(h[7:])
+		}
+		if token == "" {
+			// Browsers cannot set the headers of WebSocket requests.
+			token = wsToken(r)
+		}
+		claims, err := a.authenticate(token)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="pmux"`)
+			log.Printf("[ERROR] [STATUS %d] %v", http.StatusUnauthorized, err)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		ctx := r.Context()
+		if claims != nil {
+			ctx = context.WithValue(ctx, claimsKey{}, claims)
+			actor, _ := claims["sub"].(string)
+			if actor == "" {
+				actor = "jwt"
+			}
+			ctx = context.WithValue(ctx, actorKey{}, actor)
+			if scope := claimsScope(claims); scope != nil {
+				ctx = context.WithValue(ctx, scopeKey{}, scope)
+			}
+		} else {
+			ctx = context.WithValue(ctx, actorKey{}, keyFingerprint(token))
+			if scope, ok := a.scopes[token]; ok {
+				ctx = context.WithValue(ctx, scopeKey{}, scope)
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// authenticate returns the claims of "token" if it is a valid JSON Web Token, or
+// nil if it is a valid API key.
+func (a *authenticator) authenticate(token string) (Claims, error) {
+	if token == "" {
+		return nil, errors.New("missing credentials")
+	}
+	for _, v := range a.keys {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(v)) == 1 {
+			return nil, nil
+		}
+	}
+	for v := range a.scopes {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(v)) == 1 {
+			return nil, nil
+		}
+	}
+	if len(a.secret) > 0 && strings.Count(token, ".") == 2 {
+		claims, err := ValidateJWT(token, a.secret, time.Now())
+		if err != nil {
+			return nil, fmt.Errorf("invalid token: %w", err)
+		}
+		return claims, nil
+	}
+	return nil, errors.New("invalid credentials")
+}
+
+// ValidateJWT verifies the HS256 signature of the JSON Web Token "token" using
+// "secret", and its "exp" and "nbf" claims against "now".
+func ValidateJWT(token string, secret []byte, now time.Time) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed header: %w", err)
+	}
+	if header.Alg != "HS256" {
+		return nil, fmt.Errorf("unsupported signing algorithm %q", header.Alg)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed signature: %w", err)
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return nil, errors.New("signature mismatch")
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("malformed claims: %w", err)
+	}
+	if exp, ok := claims["exp"].(float64); ok && now.Unix() >= int64(exp) {
+		return nil, errors.New("token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Unix() < int64(nbf) {
+		return nil, errors.New("token not valid yet")
+	}
+	return claims, nil
+}
+
+func decodeSegment(s string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,79 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func signJWT(header, claims string, secret []byte) string {
+	s := base64.RawURLEncoding.EncodeToString([]byte(header)) + "." + base64.RawURLEncoding.EncodeToString([]byte(claims))
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(s))
+	return s + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestValidateJWT(t *testing.T) {
+	t.Parallel()
+
+	secret := []byte("secret")
+	now := time.Unix(1000, 0)
+	hs256 := `{"alg":"HS256","typ":"JWT"}`
+	for _, tt := range []struct {
+		token string
+		ok    bool
+	}{
+		{signJWT(hs256, `{"sub":"ci","exp":2000}`, secret), true},
+		{signJWT(hs256, `{"sub":"ci","exp":500}`, secret), false},
+		{signJWT(hs256, `{"sub":"ci","nbf":2000}`, secret), false},
+		{signJWT(hs256, `{"sub":"ci"}`, []byte("other")), false},
+		{signJWT(`{"alg":"none"}`, `{"sub":"ci"}`, secret), false},
+		{"not.a.token", false},
+	} {
+		claims, err := ValidateJWT(tt.token, secret, now)
+		if tt.ok && (err != nil || claims["sub"] != "ci") {
+			t.Fatalf("%q: unexpected error: %v", tt.token, err)
+		}
+		if !tt.ok && err == nil {
+			t.Fatalf("%q: expected an error", tt.token)
+		}
+	}
+}
+
+func TestRouter_Auth(t *testing.T) {
+	t.Parallel()
+
+	secret := []byte("secret")
+	r := NewRouter("yes", APIKeys("key"), JWTSecret(secret))
+	for _, tt := range []struct {
+		path   string
+		header string
+		value  string
+		status int
+	}{
+		{"/health_check", "", "", http.StatusOK},
+		{"/api/v1/sessions/x", "", "", http.StatusUnauthorized},
+		{"/api/v1/sessions/x", "Authorization", "Bearer wrong", http.StatusUnauthorized},
+		{"/api/v1/sessions/x", "X-API-Key", "key", http.StatusNotFound},
+		{"/api/v1/sessions/x", "Authorization", "Bearer key", http.StatusNotFound},
+		{"/api/v1/sessions/x", "Authorization", "Bearer " + signJWT(`{"alg":"HS256"}`, `{}`, secret), http.StatusNotFound},
+	} {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		if tt.header != "" {
+			req.Header.Set(tt.header, tt.value)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.status {
+			t.Fatalf("%s %s=%q: wanted status %d, found %d", tt.path, tt.header, tt.value, tt.status, w.Code)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"log"
+	"time"
+)
+
+// compressInterval is the time between two searches of the finished sessions
+// whose logs are not compressed yet.
+const compressInterval = time.Second * 30
+
+// CompressLogs makes the server compress the stdout and stderr of the sessions
+// once they finish, see "pwrap.PWrap.CompressLogs". The log routes and the logs
+// command decompress them transparently.
+func CompressLogs(ok bool) func(*Router) {
+	return func(r *Router) {
+		r.compress = ok
+	}
+}
+
+// compressLogs compresses the logs of the finished sessions whose wrapper is not
+// running anymore, returning their identifiers. Sessions running as Kubernetes
+// Jobs or on remote hosts are skipped, as their logs are not stored here.
+func (h *SessionHandler) compressLogs() []string {
+	sessions, err := h.listSessions()
+	if err != nil {
+		log.Printf("[WARN] compression: unable to list sessions: %v", err)
+		return nil
+	}
+	var acc []string
+	for _, v := range sessions {
+		if v.Tmux || v.FinishedAt == nil || v.WorkDir == "" || v.Kubernetes != nil || v.Remote != nil {
+			continue
+		}
+		pw, err := openSession(v.SID)
+		if err != nil {
+			continue
+		}
+		ok, err := pw.CompressLogs()
+		if err != nil {
+			log.Printf("[ERROR] compression: session %s: %v", v.SID, err)
+			continue
+		}
+		if ok {
+			acc = append(acc, v.SID)
+		}
+	}
+	if len(acc) > 0 {
+		log.Printf("[INFO] compression: logs of %d finished sessions compressed", len(acc))
+	}
+	return acc
+}
+
+// compressEvery compresses the logs of finished sessions every "interval",
+// forever.
+func (h *SessionHandler) compressEvery(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for range t.C {
+		h.compressLogs()
+	}
+}
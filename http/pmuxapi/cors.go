@@ -0,0 +1,66 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"net/http"
+	"strings"
+)
+
+// DefaultCORSMethods are the methods allowed to cross-origin requests when none
+// is configured.
+var DefaultCORSMethods = []string{"GET", "POST", "PUT", "DELETE"}
+
+// CORS allows browsers to perform cross-origin requests from "origins", which may
+// contain "*" to allow any origin, using "methods". If "methods" is empty,
+// "DefaultCORSMethods" are allowed.
+func CORS(origins, methods []string) func(*Router) {
+	return func(r *Router) {
+		if len(origins) == 0 {
+			return
+		}
+		if len(methods) == 0 {
+			methods = DefaultCORSMethods
+		}
+		r.cors = &cors{origins: origins, methods: strings.Join(methods, ", ")}
+	}
+}
+
+type cors struct {
+	origins []string
+	methods string
+}
+
+func (c *cors) allowed(origin string) bool {
+	for _, v := range c.origins {
+		if v == "*" || v == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// handle sets the CORS headers on "w", returning true if "r" is a preflight
+// request that has been answered.
+func (c *cors) handle(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	w.Header().Add("Vary", "Origin")
+	if origin == "" || !c.allowed(origin) {
+		return false
+	}
+
+	h := w.Header()
+	h.Set("Access-Control-Allow-Origin", origin)
+	h.Set("Access-Control-Allow-Credentials", "true")
+	h.Set("Access-Control-Expose-Headers", "X-Total-Count")
+	if r.Method != http.MethodOptions || r.Header.Get("Access-Control-Request-Method") == "" {
+		return false
+	}
+	h.Set("Access-Control-Allow-Methods", c.methods)
+	h.Set("Access-Control-Allow-Headers", "Authorization, Content-Type, X-API-Key")
+	h.Set("Access-Control-Max-Age", "600")
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
@@ -0,0 +1,84 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// drainer tracks whether the server is draining, i.e. refusing new sessions
+// while waiting for the existing ones to finish.
+type drainer struct {
+	once sync.Once
+	c    chan struct{}
+}
+
+func newDrainer() *drainer {
+	return &drainer{c: make(chan struct{})}
+}
+
+func (d *drainer) start() {
+	d.once.Do(func() {
+		log.Printf("[INFO] draining: new sessions are refused from now on")
+		close(d.c)
+	})
+}
+
+func (d *drainer) draining() bool {
+	select {
+	case <-d.c:
+		return true
+	default:
+		return false
+	}
+}
+
+// Drain makes the server refuse new sessions. It does not affect the running
+// and queued ones.
+func (r *Router) Drain() {
+	r.h.drain.start()
+}
+
+// Draining returns a channel that is closed once the server starts draining.
+func (r *Router) Draining() <-chan struct{} {
+	return r.h.drain.c
+}
+
+// WaitDrained blocks until no session is running or queued anymore, or until
+// "ctx" is done.
+func (r *Router) WaitDrained(ctx context.Context) error {
+	t := time.NewTicker(schedulerInterval)
+	defer t.Stop()
+	for {
+		n := runningSessions()
+		if r.h.sched != nil {
+			r.h.sched.Lock()
+			n += len(r.h.sched.queue)
+			r.h.sched.Unlock()
+		}
+		if n == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%d sessions still active: %w", n, ctx.Err())
+		case <-t.C:
+		}
+	}
+}
+
+// HandleDrain makes the server start draining.
+func (r *Router) HandleDrain() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		r.Drain()
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprintln(w, "Draining!")
+	}
+}
@@ -0,0 +1,210 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/kim-company/pmux/pwrap"
+	"github.com/kim-company/pmux/tail"
+)
+
+// feedInterval is the time between two searches of the sessions followed by the
+// aggregated progress route, while feedHeartbeat is the longest time the route
+// stays silent, which keeps intermediaries from closing the stream.
+const (
+	feedInterval  = time.Second
+	feedHeartbeat = 15 * time.Second
+)
+
+// ProgressEvent is a progress update of session "SID", as streamed by the
+// aggregated progress route.
+type ProgressEvent struct {
+	SID string `json:"sid"`
+	*pwrap.ProgressRecord
+}
+
+// feedCursor is the position reached in the progress history of a session.
+type feedCursor struct {
+	path string
+	off  int64
+	// done is set once the session is over: its remaining updates are
+	// streamed before it stops being followed.
+	done bool
+}
+
+// progressFeed follows the progress histories of the running sessions selected
+// by "opts", which are recorded by their wrappers on this host. Histories are
+// read without keeping them open.
+type progressFeed struct {
+	h       *SessionHandler
+	opts    *ListOptions
+	cursors map[string]*feedCursor
+}
+
+// scan starts following the sessions that are running, and marks as done those
+// that are not anymore. The histories found at the first scan are followed from
+// their end, the others from their beginning, as they were created afterwards.
+func (f *progressFeed) scan(first bool) error {
+	sessions, err := f.h.listSessions()
+	if err != nil {
+		return err
+	}
+	seen := make(map[string]bool, len(sessions))
+	for _, v := range sessions {
+		if !f.opts.Match(v) {
+			continue
+		}
+		seen[v.SID] = true
+		c, ok := f.cursors[v.SID]
+		if ok {
+			c.done = v.FinishedAt != nil
+			continue
+		}
+		if v.FinishedAt != nil {
+			continue
+		}
+		pw, err := openSession(v.SID)
+		if err != nil {
+			continue
+		}
+		c = &feedCursor{path: pw.Path(pwrap.FileProgress)}
+		fi, err := os.Stat(c.path)
+		if err != nil {
+			// The wrapper did not start yet, or runs on another host.
+			continue
+		}
+		if first {
+			c.off = fi.Size()
+		}
+		f.cursors[v.SID] = c
+	}
+	for sid, c := range f.cursors {
+		if !seen[sid] {
+			c.done = true
+		}
+	}
+	return nil
+}
+
+// poll writes to "w" the progress updates recorded since the last poll, as
+// server-sent events, returning their number.
+func (f *progressFeed) poll(w io.Writer) (int, error) {
+	n := 0
+	for sid, c := range f.cursors {
+		records, err := c.read()
+		if err != nil {
+			log.Printf("[WARN] progress feed: session %s: %v", sid, err)
+		}
+		for _, v := range records {
+			data, err := json.Marshal(&ProgressEvent{SID: sid, ProgressRecord: v})
+			if err != nil {
+				return n, err
+			}
+			if _, err := fmt.Fprintf(w, "event: progress\ndata: %s\n\n", data); err != nil {
+				return n, err
+			}
+			n++
+		}
+		if c.done || err != nil {
+			delete(f.cursors, sid)
+		}
+	}
+	return n, nil
+}
+
+// read returns the records appended to the history since the last read. A line
+// that is not terminated yet is left for the next read.
+func (c *feedCursor) read() ([]*pwrap.ProgressRecord, error) {
+	file, err := os.Open(c.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	size, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if size < c.off {
+		// Start over if the history was truncated in the meantime.
+		c.off = 0
+	}
+	if size == c.off {
+		return nil, nil
+	}
+	data := make([]byte, size-c.off)
+	if _, err := file.ReadAt(data, c.off); err != nil {
+		return nil, err
+	}
+	data = data[:bytes.LastIndexByte(data, '\n')+1]
+	c.off += int64(len(data))
+	return pwrap.ReadProgress(bytes.NewReader(data))
+}
+
+// HandleProgressFeed streams, as server-sent events, the progress updates of the
+// running sessions of the namespace of the request, tagged with their session
+// identifier, optionally selected with the "label" query parameter. Sessions
+// started after the request are followed too. Only sessions whose wrapper runs
+// on this host are included.
+func (h *SessionHandler) HandleProgressFeed() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		opts, err := ParseListOptions(r.URL.Query())
+		if err != nil {
+			h.writeError(w, err, http.StatusBadRequest)
+			return
+		}
+		opts.Namespace = NamespaceFromContext(r.Context())
+		f := &progressFeed{h: h, opts: opts, cursors: map[string]*feedCursor{}}
+		if err := f.scan(true); err != nil {
+			h.writeError(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		// Streams are meant to outlive the timeouts of the server.
+		rc := http.NewResponseController(w)
+		rc.SetWriteDeadline(time.Time{})
+		w.WriteHeader(http.StatusOK)
+		rc.Flush()
+
+		t := time.NewTicker(tail.PollInterval)
+		defer t.Stop()
+		scanned, written := time.Now(), time.Now()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case now := <-t.C:
+				if now.Sub(scanned) >= feedInterval {
+					if err := f.scan(false); err != nil {
+						log.Printf("[WARN] progress feed: %v", err)
+					}
+					scanned = now
+				}
+				n, err := f.poll(w)
+				if err == nil && n == 0 && now.Sub(written) >= feedHeartbeat {
+					_, err = io.WriteString(w, ": heartbeat\n\n")
+					n = 1
+				}
+				if err != nil {
+					return
+				}
+				if n > 0 {
+					rc.Flush()
+					written = now
+				}
+			}
+		}
+	}
+}
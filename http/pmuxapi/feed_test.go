@@ -0,0 +1,126 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// appendProgress appends "s" to the progress history at "path".
+func appendProgress(t *testing.T, path, s string) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(s); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// progressLine returns a line of a progress history describing "desc".
+func progressLine(desc string) string {
+	return fmt.Sprintf(`{"time":%q,"description":%q}`+"\n", time.Now().Format(time.RFC3339), desc)
+}
+
+// readEvents decodes the progress events written as server-sent events in "b".
+func readEvents(t *testing.T, b *bytes.Buffer) []*ProgressEvent {
+	var acc []*ProgressEvent
+	for _, v := range strings.Split(b.String(), "\n\n") {
+		if v == "" {
+			continue
+		}
+		lines := strings.Split(v, "\n")
+		if len(lines) != 2 || lines[0] != "event: progress" || !strings.HasPrefix(lines[1], "data: ") {
+			t.Fatalf("Unexpected event %q", v)
+		}
+		var e ProgressEvent
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(lines[1], "data: ")), &e); err != nil {
+			t.Fatal(err)
+		}
+		acc = append(acc, &e)
+	}
+	b.Reset()
+	return acc
+}
+
+func TestProgressFeed_Poll(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	appendProgress(t, a, progressLine("one"))
+	appendProgress(t, b, "")
+	f := &progressFeed{cursors: map[string]*feedCursor{
+		"a": {path: a},
+		"b": {path: b},
+	}}
+
+	var buf bytes.Buffer
+	if n, err := f.poll(&buf); err != nil || n != 1 {
+		t.Fatalf("Unexpected poll: %d events, %v", n, err)
+	}
+	if e := readEvents(t, &buf); e[0].SID != "a" || e[0].Description != "one" {
+		t.Fatalf("Unexpected event of session %s: %q", e[0].SID, e[0].Description)
+	}
+
+	// Lines are streamed once terminated.
+	line := progressLine("two")
+	appendProgress(t, b, line[:10])
+	if n, err := f.poll(&buf); err != nil || n != 0 {
+		t.Fatalf("Truncated lines SHOULD NOT be streamed: %d events, %v", n, err)
+	}
+	appendProgress(t, b, line[10:])
+	f.cursors["b"].done = true
+	if n, err := f.poll(&buf); err != nil || n != 1 {
+		t.Fatalf("Unexpected poll: %d events, %v", n, err)
+	}
+	if e := readEvents(t, &buf); e[0].SID != "b" || e[0].Description != "two" {
+		t.Fatalf("Unexpected event of session %s: %q", e[0].SID, e[0].Description)
+	}
+	if _, ok := f.cursors["b"]; ok {
+		t.Fatalf("Finished sessions SHOULD NOT be followed anymore")
+	}
+
+	// Truncated histories are read again from their beginning.
+	if err := os.WriteFile(a, []byte(progressLine("3")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := f.poll(&buf); err != nil || n != 1 {
+		t.Fatalf("Unexpected poll: %d events, %v", n, err)
+	}
+	if e := readEvents(t, &buf); e[0].Description != "3" {
+		t.Fatalf("Unexpected event %q", e[0].Description)
+	}
+}
+
+func TestSessionHandler_HandleProgressFeed(t *testing.T) {
+	t.Parallel()
+
+	h := &SessionHandler{}
+	w := httptest.NewRecorder()
+	h.HandleProgressFeed()(w, httptest.NewRequest("GET", "/api/v1/progress?label=invalid", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Invalid filters SHOULD be rejected, found status %d", w.Code)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	w = httptest.NewRecorder()
+	h.HandleProgressFeed()(w, httptest.NewRequest("GET", "/api/v1/progress?label=test=none", nil).WithContext(ctx))
+	if ct := w.Header().Get("Content-Type"); w.Code != http.StatusOK || ct != "text/event-stream" {
+		t.Fatalf("Unexpected response: status %d, content type %q", w.Code, ct)
+	}
+}
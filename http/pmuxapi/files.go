@@ -0,0 +1,86 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"path/filepath"
+
+	"github.com/gorilla/mux"
+	"github.com/kim-company/pmux/http/pwrapapi"
+	"github.com/kim-company/pmux/pwrap"
+)
+
+// hiddenFile reports whether file "name" of a working directory is not served by
+// the files routes: the session state carries the credentials of the session,
+// and is served by the show route without them.
+func hiddenFile(name string) bool {
+	return path.Clean("/"+name) == "/"+pwrap.FileSession
+}
+
+// hiddenLink reports whether "path", inside working directory "dir", is a
+// symbolic link to a hidden file.
+func hiddenLink(dir, path string) bool {
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return false
+	}
+	real, err := pwrapapi.ResolveFile(dir, path)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(root, real)
+	return err == nil && hiddenFile(filepath.ToSlash(rel))
+}
+
+// HandleFiles lists the files of the working directory of a session, like the
+// "/files" route of the process wrapper API, except that the server reads them
+// directly: they remain available once the session is over. Only the working
+// directories on this host are available.
+func (h *SessionHandler) HandleFiles() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		pw, err := openSession(mux.Vars(r)["sid"])
+		if err != nil {
+			h.writeSessionError(w, err)
+			return
+		}
+		files, err := pwrapapi.ListFiles(pw.WorkDir())
+		if err != nil {
+			h.writeError(w, fmt.Errorf("unable to list files: %w", err), http.StatusInternalServerError)
+			return
+		}
+		acc := files[:0]
+		for _, v := range files {
+			if !hiddenFile(v.Name) {
+				acc = append(acc, v)
+			}
+		}
+		h.writeResponse(w, acc)
+	}
+}
+
+// HandleFile serves a file of the working directory of a session, see
+// "HandleFiles".
+func (h *SessionHandler) HandleFile() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+		if hiddenFile(name) {
+			h.writeError(w, fmt.Errorf("file %q not found", name), http.StatusNotFound)
+			return
+		}
+		pw, err := openSession(mux.Vars(r)["sid"])
+		if err != nil {
+			h.writeSessionError(w, err)
+			return
+		}
+		if path, err := pwrapapi.FilePath(pw.WorkDir(), name); err == nil && hiddenLink(pw.WorkDir(), path) {
+			h.writeError(w, fmt.Errorf("file %q not found", name), http.StatusNotFound)
+			return
+		}
+		pwrapapi.FileHandler(pw.WorkDir())(w, r)
+	}
+}
@@ -0,0 +1,91 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/kim-company/pmux/http/pwrapapi"
+	"github.com/kim-company/pmux/pwrap"
+)
+
+func TestSessionHandler_HandleFiles(t *testing.T) {
+	t.Parallel()
+
+	pw, err := pwrap.New(pwrap.RootDir(RootDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(pw.WorkDir())
+	if err := os.MkdirAll(pw.Path("out"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(pw.Path("out/video.mp4"), []byte("video"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Links leaving the working directory, or reaching the session state,
+	// are not followed.
+	outside := filepath.Join(t.TempDir(), "session")
+	if err := os.WriteFile(outside, []byte("token"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for name, target := range map[string]string{"out/link": outside, "out/dir": filepath.Dir(outside), "out/state": pw.Path(pwrap.FileSession), "out/copy": pw.Path("out/video.mp4")} {
+		if err := os.Symlink(target, pw.Path(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h := &SessionHandler{}
+	r := mux.NewRouter()
+	r.HandleFunc("/api/v1/sessions/{sid}/files", h.HandleFiles())
+	r.HandleFunc("/api/v1/sessions/{sid}/files/{name:.+}", h.HandleFile())
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/sessions/"+pw.SID()+"/files", nil))
+	var files []pwrapapi.FileInfo
+	if err := json.NewDecoder(w.Body).Decode(&files); err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, v := range files {
+		found = found || (v.Name == "out/video.mp4" && v.Size == 5)
+		if v.Name == pwrap.FileSession {
+			t.Fatalf("The session state SHOULD NOT be listed")
+		}
+	}
+	if !found {
+		t.Fatalf("Files SHOULD list out/video.mp4: %+v", files)
+	}
+
+	for _, tt := range []struct {
+		path   string
+		status int
+	}{
+		{pw.SID() + "/files/out/video.mp4", http.StatusOK},
+		{pw.SID() + "/files/out/missing.mp4", http.StatusNotFound},
+		{pw.SID() + "/files/out", http.StatusBadRequest},
+		{pw.SID() + "/files/" + pwrap.FileSession, http.StatusNotFound},
+		{pw.SID() + "/files/out/link", http.StatusForbidden},
+		{pw.SID() + "/files/out/dir/session", http.StatusForbidden},
+		{pw.SID() + "/files/out/state", http.StatusNotFound},
+		{pw.SID() + "/files/out/copy", http.StatusOK},
+		{"pmux-missing/files/out/video.mp4", http.StatusNotFound},
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/sessions/"+tt.path, nil))
+		if w.Code != tt.status {
+			t.Fatalf("%s: wanted status %d, found %d", tt.path, tt.status, w.Code)
+		}
+		if tt.status == http.StatusOK && w.Body.String() != "video" {
+			t.Fatalf("Unexpected contents %q", w.Body)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/kim-company/pmux/pwrap"
+)
+
+// Labels attached to the replicas of a group, set to its identifier and to the
+// index of the replica, starting from 0.
+const (
+	GroupLabel   = "pmux.group"
+	ReplicaLabel = "pmux.replica"
+)
+
+// MaxReplicas is the maximum number of replicas created in one call.
+const MaxReplicas = 100
+
+// GroupState is the state of a group.
+type GroupState string
+
+const (
+	GroupRunning GroupState = "running"
+	// GroupSucceeded groups had every replica exit successfully.
+	GroupSucceeded = "succeeded"
+	// GroupFailed groups are finished, with at least one replica that failed.
+	GroupFailed = "failed"
+)
+
+// Group is a set of identical sessions, its replicas, created in one call.
+type Group struct {
+	ID    string     `json:"id"`
+	State GroupState `json:"state"`
+	// States counts the replicas in each state.
+	States map[pwrap.SessionState]int `json:"states"`
+	// Sessions are the replicas, sorted by index.
+	Sessions []*SessionDetail `json:"sessions"`
+}
+
+// newGroup returns the group "id" made of "sessions".
+func newGroup(id string, sessions []*SessionDetail) *Group {
+	g := &Group{ID: id, State: GroupSucceeded, States: map[pwrap.SessionState]int{}, Sessions: sessions}
+	sort.Slice(sessions, func(i, j int) bool {
+		a, _ := strconv.Atoi(sessions[i].Labels[ReplicaLabel])
+		b, _ := strconv.Atoi(sessions[j].Labels[ReplicaLabel])
+		return a < b
+	})
+	failed := false
+	for _, v := range sessions {
+		g.States[v.State]++
+		switch v.State {
+		case pwrap.SessionExited:
+		case pwrap.SessionFailed, pwrap.SessionTimedOut:
+			failed = true
+		default:
+			g.State = GroupRunning
+		}
+	}
+	if failed && g.State != GroupRunning {
+		g.State = GroupFailed
+	}
+	return g
+}
+
+// group returns group "id" of namespace "ns", which does not exist once its
+// replicas are deleted.
+func (h *SessionHandler) group(id, ns string) (*Group, error) {
+	sessions, err := h.listSessions()
+	if err != nil {
+		return nil, err
+	}
+	opts := &ListOptions{Namespace: ns, Labels: map[string]string{GroupLabel: id}}
+	replicas, _ := opts.Apply(sessions)
+	if len(replicas) == 0 {
+		return nil, fmt.Errorf("group %s: %w", id, os.ErrNotExist)
+	}
+	return newGroup(id, replicas), nil
+}
+
+// createGroup creates "c.Replicas" sessions described by "c" in namespace "ns",
+// returning the identifier of their group. Either every replica is created, or
+// none: those already created are deleted when one fails. On failure, the status
+// code describing the error is returned as well.
+func (h *SessionHandler) createGroup(ctx context.Context, c *createRequest, name string, args []string, ns string) ([]string, string, int, error) {
+	if c.Replicas < 1 || c.Replicas > MaxReplicas {
+		return nil, "", http.StatusBadRequest, fmt.Errorf("replicas must be between 1 and %d", MaxReplicas)
+	}
+	id := "pmux-group-" + uuid.New().String()
+	sids := make([]string, 0, c.Replicas)
+	for i := 0; i < c.Replicas; i++ {
+		req := *c
+		req.Replicas = 0
+		req.Labels = make(map[string]string, len(c.Labels)+2)
+		for k, v := range c.Labels {
+			req.Labels[k] = v
+		}
+		req.Labels[GroupLabel], req.Labels[ReplicaLabel] = id, strconv.Itoa(i)
+		if c.Name != "" {
+			// Replicas are named after their index.
+			req.Name = c.Name + "-" + strconv.Itoa(i)
+		}
+		pw, status, err := h.createSession(ctx, &req, name, args, ns)
+		if err != nil {
+			for _, sid := range sids {
+				if derr := h.deleteSession(sid, false); derr != nil {
+					log.Printf("[WARN] group %s: unable to delete replica %s: %v", id, sid, derr)
+				}
+			}
+			return nil, "", status, fmt.Errorf("replica %d: %w", i, err)
+		}
+		h.record(pw)
+		s, _ := pw.ReadSession()
+		h.notify(pwrap.EventCreated, pw.SID(), s)
+		sids = append(sids, pw.SID())
+	}
+	log.Printf("[INFO] Group %s created with %d replicas", id, len(sids))
+	return sids, id, 0, nil
+}
+
+// HandleShowGroup returns the combined status of a group and of its replicas.
+func (h *SessionHandler) HandleShowGroup() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		g, err := h.group(mux.Vars(r)["id"], NamespaceFromContext(r.Context()))
+		if err != nil {
+			h.writeSessionError(w, err)
+			return
+		}
+		h.writeResponse(w, g)
+	}
+}
+
+// HandleDeleteGroup deletes the replicas of a group, trashing their files unless
+// "keepFiles" is set.
+func (h *SessionHandler) HandleDeleteGroup(keepFiles bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		g, err := h.group(mux.Vars(r)["id"], NamespaceFromContext(r.Context()))
+		if err != nil {
+			h.writeSessionError(w, err)
+			return
+		}
+		sids := make([]string, 0, len(g.Sessions))
+		for _, v := range g.Sessions {
+			if v.WorkDir != "" {
+				sids = append(sids, v.SID)
+			}
+		}
+		auditSessions(r.Context(), sids...)
+		res := h.deleteSessions(sids, NamespaceFromContext(r.Context()), keepFiles)
+		log.Printf("[INFO] Group %s deleted", g.ID)
+		h.writeResponse(w, res)
+	}
+}
@@ -0,0 +1,106 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/kim-company/pmux/pwrap"
+)
+
+func TestNewGroup(t *testing.T) {
+	t.Parallel()
+
+	replica := func(i string, state pwrap.SessionState) *SessionDetail {
+		return &SessionDetail{Session: pwrap.Session{SID: "pmux-" + i, State: state, Labels: map[string]string{ReplicaLabel: i}}}
+	}
+	g := newGroup("pmux-group-test", []*SessionDetail{
+		replica("10", pwrap.SessionExited),
+		replica("2", pwrap.SessionRunning),
+		replica("0", pwrap.SessionFailed),
+	})
+	if g.State != GroupRunning {
+		t.Fatalf("Groups SHOULD run until every replica is finished, found %s", g.State)
+	}
+	if g.Sessions[0].SID != "pmux-0" || g.Sessions[1].SID != "pmux-2" || g.Sessions[2].SID != "pmux-10" {
+		t.Fatalf("Replicas SHOULD be sorted by index: %s, %s, %s", g.Sessions[0].SID, g.Sessions[1].SID, g.Sessions[2].SID)
+	}
+	if g.States[pwrap.SessionRunning] != 1 || g.States[pwrap.SessionFailed] != 1 {
+		t.Fatalf("Unexpected states: %v", g.States)
+	}
+	if g = newGroup("pmux-group-test", []*SessionDetail{replica("0", pwrap.SessionExited), replica("1", pwrap.SessionFailed)}); g.State != GroupFailed {
+		t.Fatalf("Wanted failed group, found %s", g.State)
+	}
+	if g = newGroup("pmux-group-test", []*SessionDetail{replica("0", pwrap.SessionExited), replica("1", pwrap.SessionTimedOut)}); g.State != GroupFailed {
+		t.Fatalf("Timed out replicas SHOULD fail their group, found %s", g.State)
+	}
+	if g = newGroup("pmux-group-test", []*SessionDetail{replica("0", pwrap.SessionExited)}); g.State != GroupSucceeded {
+		t.Fatalf("Wanted succeeded group, found %s", g.State)
+	}
+}
+
+func TestRouter_Groups(t *testing.T) {
+	t.Parallel()
+
+	r := NewRouter("yes")
+	for _, v := range []string{
+		`{"replicas": -1}`,
+		`{"replicas": 101}`,
+		`{"replicas": 2, "cron": "@daily"}`,
+		`{"replicas": 2, "exec": "missing"}`,
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/sessions", strings.NewReader(v)))
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("%s: wanted status %d, found %d", v, http.StatusBadRequest, w.Code)
+		}
+	}
+
+	id := "pmux-group-test-" + t.Name()
+	exited, failed := finishedSession(t, pwrap.SessionExited), finishedSession(t, pwrap.SessionFailed)
+	defer os.RemoveAll(exited.WorkDir())
+	defer os.RemoveAll(failed.WorkDir())
+	for i, pw := range []*pwrap.PWrap{exited, failed} {
+		if err := pw.UpdateSession(func(s *pwrap.Session) {
+			s.Labels = map[string]string{GroupLabel: id, ReplicaLabel: strconv.Itoa(i)}
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/groups/"+id, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Wanted status %d, found %d: %s", http.StatusOK, w.Code, w.Body)
+	}
+	var g Group
+	if err := json.NewDecoder(w.Body).Decode(&g); err != nil {
+		t.Fatal(err)
+	}
+	if g.State != GroupFailed || len(g.Sessions) != 2 || g.Sessions[0].SID != exited.SID() {
+		t.Fatalf("Unexpected group: %+v", g)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("DELETE", "/api/v1/groups/"+id, nil))
+	var res BulkDeleteResult
+	if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Deleted) != 2 {
+		t.Fatalf("The replicas of the group SHOULD be deleted: %+v", res)
+	}
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/groups/"+id, nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Groups SHOULD NOT exist without replicas, found status %d", w.Code)
+	}
+}
@@ -5,19 +5,211 @@
 package pmuxapi
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/kim-company/pmux/http/pwrapapi"
 	"github.com/kim-company/pmux/pwrap"
-	"github.com/kim-company/pmux/tmux"
+	"github.com/kim-company/pmux/secrets"
+	"github.com/kim-company/pmux/trace"
 )
 
 type SessionHandler struct {
+	grace time.Duration
+	// settings guards the settings that can be reloaded: execs, nsLimits
+	// and retention.
+	settings sync.RWMutex
+	execs    map[string]Executable
+	webhooks pwrap.Hooks
+	// store, if set, records the sessions created.
+	store Store
+	// observed are the states read while serving requests, which are
+	// recorded in the store in the background.
+	observed chan *pwrap.Session
+	drain    *drainer
+	// sched, if set, limits the number of sessions running concurrently.
+	sched *scheduler
+	// exits receives a value when tmux sessions exit.
+	exits chan struct{}
+	// images and mounts are the Docker images and the host paths that
+	// containerized sessions are allowed to use.
+	images []string
+	mounts []string
+	// cors, if set, allows the pages of other origins to open WebSockets.
+	cors *cors
+	// secrets are the prefixes of the secret references sessions may use.
+	secrets []string
+	// detach makes wrappers start as detached processes rather than in
+	// tmux sessions.
+	detach bool
+	// kube, if set, is the template of the Jobs of the sessions running
+	// on Kubernetes.
+	kube *pwrap.Kubernetes
+	// remote is the template of the placement of remote sessions, which
+	// may run on one of the "hosts".
+	remote pwrap.Remote
+	hosts  []string
+	// quota, if set, is the default disk quota of the sessions, and the
+	// most they may request.
+	quota *pwrap.Quota
+	// retention, if positive, is the time finished sessions are kept for,
+	// unless they select their own.
+	retention time.Duration
+	// nsLimits maps the namespaces to the maximum number of their sessions
+	// that are not finished.
+	nsLimits map[string]int
+	// reaper starts the removal of expired sessions once.
+	reaper sync.Once
+	// configVars, if set, are the variables provided to the templates of
+	// session configurations, which are not executed otherwise.
+	configVars map[string]string
+	// artifacts, if set, is the destination of the artifacts uploaded by
+	// the sessions.
+	artifacts string
+	// sidecars are the auxiliary commands sessions may select by name.
+	sidecars map[string]pwrap.Sidecar
+	// preflight, if set, makes every session pass a dry run before it is
+	// started.
+	preflight bool
+	// sockDir is the directory hosting the sockets of the children.
+	sockDir string
+	// streaming is how the wrapper API delivers the streams of the
+	// children.
+	streaming pwrapapi.StreamMode
+	// commandOps are the operations of the commands accepted by the
+	// wrapper API, the default ones if empty.
+	commandOps []string
+	// apiPorts is the range of ports of the wrapper API, if limited.
+	apiPorts *pwrap.PortRange
+	// timetable, if set, keeps the sessions scheduled for later.
+	timetable *timetable
+	// pipelines, if set, keeps the pipelines of sessions.
+	pipelines *pipelines
+	// creating serializes the creation of named sessions, whose names are
+	// unique within their namespace, and of the sessions counted against the
+	// limit of their namespace.
+	creating sync.Mutex
+}
+
+// quotaFor returns the disk quota of a session requesting "req", which may
+// lower the server's quota but not relax it.
+func (h *SessionHandler) quotaFor(req *pwrap.Quota) (*pwrap.Quota, error) {
+	if req == nil {
+		return h.quota, nil
+	}
+	q := *req
+	if h.quota != nil {
+		if q.Bytes > h.quota.Bytes {
+			return nil, fmt.Errorf("disk quota cannot exceed %d bytes", h.quota.Bytes)
+		}
+		if h.quota.Action == pwrap.QuotaStop {
+			if q.Action != "" && q.Action != pwrap.QuotaStop {
+				return nil, fmt.Errorf("disk quota action has to be %q", pwrap.QuotaStop)
+			}
+			q.Action = pwrap.QuotaStop
+		}
+	}
+	return &q, q.Validate()
+}
+
+// placementOn returns the placement of a session on "host", which must be among
+// those allowed.
+func (h *SessionHandler) placementOn(host string) (*pwrap.Remote, error) {
+	if !contains(h.hosts, host) {
+		return nil, fmt.Errorf("host %q is not allowed", host)
+	}
+	r := h.remote
+	r.Host = host
+	return &r, r.Validate()
+}
+
+// jobFor returns the Job running a session, built from the server's template and
+// the image and resource limits requested in "req".
+func (h *SessionHandler) jobFor(req *pwrap.Kubernetes) (*pwrap.Kubernetes, error) {
+	if h.kube == nil {
+		return nil, fmt.Errorf("kubernetes sessions are not enabled")
+	}
+	k := *h.kube
+	if req.Image != "" && req.Image != k.Image {
+		if !contains(h.images, req.Image) {
+			return nil, fmt.Errorf("image %q is not allowed", req.Image)
+		}
+		k.Image = req.Image
+	}
+	k.CPU, k.Memory = req.CPU, req.Memory
+	return &k, k.Validate()
+}
+
+// checkContainer returns an error if "c" uses an image or mounts a host path
+// that is not allowed.
+func (h *SessionHandler) checkContainer(c *pwrap.Container) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	if !contains(h.images, c.Image) {
+		return fmt.Errorf("container image %q is not allowed", c.Image)
+	}
+	for _, v := range c.Mounts {
+		if src := pwrap.MountSource(v); !contains(h.mounts, filepath.Clean(src)) {
+			return fmt.Errorf("container mount source %q is not allowed", src)
+		}
+	}
+	return nil
+}
+
+// checkSecrets returns an error if a reference of "refs" does not start with
+// one of the prefixes allowed, or may escape it with a ".." element.
+func (h *SessionHandler) checkSecrets(refs map[string]string) error {
+	names := make([]string, 0, len(refs))
+	for k := range refs {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		ref := refs[k]
+		allowed := false
+		for _, v := range h.secrets {
+			allowed = allowed || strings.HasPrefix(ref, v)
+		}
+		for _, v := range strings.Split(ref, "/") {
+			allowed = allowed && v != ".."
+		}
+		if !allowed {
+			return fmt.Errorf("secret reference %q of %q is not allowed", ref, k)
+		}
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// notify delivers the event "t" about session "sid" to the webhooks, in the background.
+func (h *SessionHandler) notify(t pwrap.EventType, sid string, s *pwrap.Session) {
+	if len(h.webhooks) == 0 {
+		return
+	}
+	go h.webhooks.Send(pwrap.NewEvent(t, sid, s))
 }
 
 func (h *SessionHandler) writeSID(w http.ResponseWriter, sid string) error {
@@ -38,67 +230,807 @@ func (h *SessionHandler) writeResponse(w http.ResponseWriter, p interface{}) err
 	return nil
 }
 
+// writeSessionError writes "err", returned while looking up a session, reporting
+// missing sessions as such.
+func (h *SessionHandler) writeSessionError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, os.ErrNotExist) {
+		status = http.StatusNotFound
+	}
+	h.writeError(w, err, status)
+}
+
 func (h *SessionHandler) writeError(w http.ResponseWriter, err error, status int) {
 	log.Printf("[ERROR] [STATUS %d] %v", status, err)
 	http.Error(w, err.Error(), status)
 }
 
+var rootDir = filepath.Join(os.TempDir(), "pmux", "sessionsd")
+
+// RootDir returns the directory containing the working directories of the
+// sessions.
+func RootDir() string {
+	return rootDir
+}
+
+// SetRootDir changes the directory containing the working directories of the
+// sessions. It has to be called before creating any router.
+func SetRootDir(path string) {
+	rootDir = path
+}
+
+// SessionDetail describes a single session.
+type SessionDetail struct {
+	pwrap.Session
+	// Tmux reports whether the wrapper is still running, inside its tmux session
+	// or as a detached process.
+	Tmux    bool   `json:"tmux"`
+	WorkDir string `json:"workdir"`
+	// ChildPID is the PID of the child found in the process tree of the
+	// wrapper, see "pwrap.PWrap.ChildPID". It is only resolved by the show
+	// route, for running sessions whose wrapper runs on this host.
+	ChildPID int `json:"child_pid,omitempty"`
+}
+
+// validSID reports whether "sid" can be safely used as a working directory name.
+func validSID(sid string) bool {
+	return sid != "" && sid == filepath.Base(sid) && sid != "." && sid != ".."
+}
+
+// openSession returns the process wrapper of session "sid", which must have a
+// working directory inside the root directory.
+func openSession(sid string) (*pwrap.PWrap, error) {
+	if !validSID(sid) {
+		return nil, fmt.Errorf("invalid session identifier %q: %w", sid, os.ErrNotExist)
+	}
+	if _, err := os.Stat(filepath.Join(rootDir, sid)); err != nil {
+		return nil, fmt.Errorf("session %s: %w", sid, err)
+	}
+	return pwrap.New(pwrap.OverrideSID(sid), pwrap.RootDir(rootDir))
+}
+
+// readSession returns the state of session "sid", as recorded in its working
+// directory or, if that is not available, in the store. The working directory
+// path is empty if it does not exist anymore.
+func (h *SessionHandler) readSession(sid string) (*pwrap.Session, string, error) {
+	pw, err := openSession(sid)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, "", err
+	}
+	var s *pwrap.Session
+	var workDir string
+	if pw != nil {
+		workDir = pw.WorkDir()
+		if s, err = pw.ReadSession(); err != nil && !errors.Is(err, pwrap.ErrNoSession) {
+			return nil, "", err
+		}
+	}
+	if s != nil && s.Refreshed() {
+		if err := pw.Refresh(); err != nil {
+			log.Printf("[WARN] unable to refresh session %s: %v", sid, err)
+		} else if r, err := pw.ReadSession(); err == nil {
+			s = r
+		}
+	}
+	if s != nil {
+		h.observe(s)
+		return s, workDir, nil
+	}
+	if h.store != nil {
+		if r, err := h.store.Get(sid); err == nil {
+			return r, workDir, nil
+		}
+	}
+	if pw == nil {
+		return nil, "", fmt.Errorf("session %s: %w", sid, os.ErrNotExist)
+	}
+	// Sessions created by older versions do not record their state.
+	return &pwrap.Session{SID: sid}, workDir, nil
+}
+
+// observedQueue is the number of session states read while serving requests that
+// may wait to be recorded in the store.
+const observedQueue = 256
+
+// observe records "s", read while serving a request, in the store. Reads do not
+// write to the store themselves: states are handed over to "recordObserved",
+// and dropped if it is lagging behind, as the next read observes them again.
+func (h *SessionHandler) observe(s *pwrap.Session) {
+	if h.observed == nil {
+		return
+	}
+	select {
+	case h.observed <- s:
+	default:
+	}
+}
+
+// recordObserved records the states passed to "observe", forever.
+func (h *SessionHandler) recordObserved() {
+	for s := range h.observed {
+		h.sync(s)
+	}
+}
+
+// sync updates the record of "s" in the store, if its state or address changed.
+func (h *SessionHandler) sync(s *pwrap.Session) {
+	if h.store == nil {
+		return
+	}
+	if r, err := h.store.Get(s.SID); err == nil && r.State == s.State && r.Port == s.Port && r.Restarts == s.Restarts {
+		return
+	}
+	if err := h.store.Put(s); err != nil {
+		log.Printf("[WARN] unable to record session %s: %v", s.SID, err)
+	}
+}
+
+// record stores the state of the session of "pw".
+func (h *SessionHandler) record(pw *pwrap.PWrap) {
+	s, err := pw.ReadSession()
+	if err != nil {
+		log.Printf("[WARN] unable to record session %s: %v", pw.SID(), err)
+		return
+	}
+	h.sync(s)
+}
+
+// sessionDetail collects the details of session "sid". "running" reports whether its
+// wrapper is running.
+func (h *SessionHandler) sessionDetail(sid string, running bool) (*SessionDetail, error) {
+	s, workDir, err := h.readSession(sid)
+	if err != nil {
+		return nil, err
+	}
+	// The token grants access to the wrapper API, which is proxied
+	// by this server instead, while the headers may carry the
+	// credentials of the registration receiver.
+	s.APIToken, s.RegisterHeaders = "", nil
+	return &SessionDetail{
+		Session: *s,
+		Tmux:    running,
+		WorkDir: workDir,
+	}, nil
+}
+
+// listSessions returns the details of every session that either has a working
+// directory inside the root directory, a running wrapper or a record in
+// the store.
+func (h *SessionHandler) listSessions() ([]*SessionDetail, error) {
+	running, err := pwrap.ListSessions(rootDir)
+	if err != nil {
+		return nil, err
+	}
+	sids := make(map[string]bool, len(running))
+	for _, v := range running {
+		sids[v] = true
+	}
+	entries, err := os.ReadDir(rootDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("unable to read sessions root directory: %w", err)
+	}
+	for _, v := range entries {
+		if v.IsDir() {
+			if _, ok := sids[v.Name()]; !ok {
+				sids[v.Name()] = false
+			}
+		}
+	}
+	if h.store != nil {
+		records, err := h.store.List()
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range records {
+			if _, ok := sids[v.SID]; !ok {
+				sids[v.SID] = false
+			}
+		}
+	}
+
+	acc := make([]*SessionDetail, 0, len(sids))
+	for sid, ok := range sids {
+		d, err := h.sessionDetail(sid, ok)
+		if errors.Is(err, os.ErrNotExist) {
+			// tmux session which does not belong to this server.
+			d, err = &SessionDetail{Session: pwrap.Session{SID: sid}, Tmux: true}, nil
+		}
+		if err != nil {
+			log.Printf("[WARN] skipping session %s: %v", sid, err)
+			continue
+		}
+		acc = append(acc, d)
+	}
+	return acc, nil
+}
+
+// HandleList lists the sessions selected by the query parameters described in
+// "ParseListOptions". The number of sessions matching the filters is reported in
+// the "X-Total-Count" header.
 func (h *SessionHandler) HandleList() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		sessions, err := tmux.ListSessions()
+		opts, err := ParseListOptions(r.URL.Query())
+		if err != nil {
+			h.writeError(w, err, http.StatusBadRequest)
+			return
+		}
+		opts.Namespace = NamespaceFromContext(r.Context())
+		sessions, err := h.listSessions()
 		if err != nil {
 			h.writeError(w, err, http.StatusInternalServerError)
 			return
 		}
-		h.writeResponse(w, sessions)
+		page, total := opts.Apply(sessions)
+		w.Header().Set("X-Total-Count", strconv.Itoa(total))
+		h.writeResponse(w, page)
 	}
 }
 
-var rootDir = filepath.Join(os.TempDir(), "pmux", "sessionsd")
-
-func (h *SessionHandler) HandleCreate(name string, args ...string) http.HandlerFunc {
+// HandleLogs serves the stdout or stderr file of a session, selected with the "stream"
+// query parameter. The "tail" and "follow" parameters are those supported by the
+// log routes of the process wrapper API.
+func (h *SessionHandler) HandleLogs() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		defer r.Body.Close()
-		var c struct {
-			URL    string      `json:"register_url"`
-			Config interface{} `json:"config"`
+		sid := mux.Vars(r)["sid"]
+		pw, err := openSession(sid)
+		if err != nil {
+			h.writeSessionError(w, err)
+			return
+		}
+
+		var file string
+		switch stream := r.URL.Query().Get("stream"); stream {
+		case "", "stdout":
+			file = pwrap.FileStdout
+		case "stderr":
+			file = pwrap.FileStderr
+		default:
+			h.writeError(w, fmt.Errorf("unknown log stream %q", stream), http.StatusBadRequest)
+			return
 		}
-		if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
-			h.writeError(w, fmt.Errorf("unable to decode create payload body: %w", err), http.StatusInternalServerError)
+		opts, err := pwrapapi.ParseTailOptions(r)
+		if err != nil {
+			h.writeError(w, err, http.StatusBadRequest)
 			return
 		}
+		pwrapapi.ServeTail(w, r, pw.Path(file), opts)
+	}
+}
 
-		pw, err := pwrap.New(pwrap.Exec(name, args...), pwrap.RootDir(rootDir), pwrap.Register(c.URL))
+// HandleProgressHistory returns the progress updates recorded by the wrapper of
+// the session, one JSON encoded "pwrap.ProgressRecord" per line. The history
+// is kept after the session is over, and supports the "tail" and "follow"
+// query parameters of the logs.
+func (h *SessionHandler) HandleProgressHistory() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		pw, err := openSession(mux.Vars(r)["sid"])
 		if err != nil {
-			h.writeError(w, err, http.StatusInternalServerError)
+			h.writeSessionError(w, err)
 			return
 		}
-		configFile, err := pw.Open(pwrap.FileConfig, os.O_RDWR|os.O_CREATE, os.ModePerm)
+		opts, err := pwrapapi.ParseTailOptions(r)
 		if err != nil {
-			h.writeError(w, err, http.StatusInternalServerError)
-			pw.Trash()
+			h.writeError(w, err, http.StatusBadRequest)
 			return
 		}
-		defer configFile.Close()
-		if err := json.NewEncoder(configFile).Encode(c.Config); err != nil {
-			h.writeError(w, fmt.Errorf("unable to store configuration: %w", err), http.StatusInternalServerError)
-			pw.Trash()
+		if _, err := os.Stat(pw.Path(pwrap.FileProgress)); os.IsNotExist(err) {
+			// The wrapper did not start yet, or runs on another host.
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 			return
 		}
+		pwrapapi.ServeTail(w, r, pw.Path(pwrap.FileProgress), opts)
+	}
+}
 
-		log.Printf("[INFO] Starting [%v] session, working dir: %v", name, pw.WorkDir())
-		sid, err := pw.StartSession()
+// LocalPath returns the path of file "rel" inside the working directory of
+// session "sid", which must exist on this host.
+func LocalPath(sid, rel string) (string, error) {
+	pw, err := openSession(sid)
+	if err != nil {
+		return "", err
+	}
+	return pw.Path(rel), nil
+}
+
+func (h *SessionHandler) HandleShow() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sid := mux.Vars(r)["sid"]
+		d, err := h.sessionDetail(sid, pwrap.HasSession(rootDir, sid))
 		if err != nil {
-			h.writeError(w, err, http.StatusInternalServerError)
-			pw.Trash()
+			h.writeSessionError(w, err)
+			return
+		}
+		if d.Tmux && d.FinishedAt == nil && d.Kubernetes == nil && d.Remote == nil {
+			if pw, err := openSession(sid); err == nil {
+				if pid, err := pw.ChildPID(); err == nil {
+					d.ChildPID = pid
+				} else if !errors.Is(err, pwrap.ErrNoChild) {
+					log.Printf("[WARN] session %s: unable to resolve child pid: %v", sid, err)
+				}
+			}
+		}
+		h.writeResponse(w, d)
+	}
+}
+
+// createRequest is the payload of the create operation.
+type createRequest struct {
+	URL       string           `json:"register_url"`
+	Exec      string           `json:"exec"`
+	Config    interface{}      `json:"config"`
+	Container *pwrap.Container `json:"container"`
+	// Kubernetes selects the image and resource limits of the Job, the
+	// rest comes from the server's template.
+	Kubernetes *pwrap.Kubernetes `json:"kubernetes"`
+	// Host places the session on a remote host.
+	Host      string       `json:"host"`
+	DiskQuota *pwrap.Quota `json:"disk_quota"`
+	// Retention overrides the time the session is kept for once finished.
+	Retention string `json:"retention"`
+	// MaxRuntime limits the time the child runs for.
+	MaxRuntime string `json:"max_runtime"`
+	// Labels are used to select the session when listing and deleting.
+	Labels map[string]string `json:"labels"`
+	// Name, if set, is the alias of the session, unique within its
+	// namespace, accepted in place of its identifier.
+	Name string `json:"name"`
+	// Priority orders the sessions queued when the concurrency limit is
+	// reached, higher first.
+	Priority int `json:"priority"`
+	// Secrets maps the names of environment variables of the child to
+	// the references of the secrets they receive, resolved by the wrapper.
+	Secrets map[string]string `json:"secrets"`
+	// Artifacts are the glob patterns selecting the files of the working
+	// directory uploaded once the child exits.
+	Artifacts []string `json:"artifacts"`
+	// Stdin connects the stdin of the child to a pipe fed through the
+	// stdin route.
+	Stdin bool `json:"stdin"`
+	// Sidecars are the names of the auxiliary commands started alongside
+	// the child.
+	Sidecars []string `json:"sidecars"`
+	// RegisterHeaders are attached by the wrapper to the registration
+	// and callback requests.
+	RegisterHeaders map[string]string `json:"register_headers"`
+	// Env is added to the environment of the child, while ExtraArgs
+	// are appended to the arguments of the executable.
+	Env       map[string]string `json:"env"`
+	ExtraArgs []string          `json:"extra_args"`
+	// Replicas, if set, creates this many identical sessions forming a
+	// group.
+	Replicas int `json:"replicas,omitempty"`
+	// StartAt and Cron, if set, schedule the session for later, once or
+	// recurrently, rather than starting it now.
+	StartAt *time.Time `json:"start_at,omitempty"`
+	Cron    string     `json:"cron,omitempty"`
+	// rawConfig is the configuration uploaded as is, which replaces
+	// Config, see "decodeCreate".
+	rawConfig []byte
+}
+
+func (h *SessionHandler) HandleCreate(name string, args ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+		// The trace of the session starts here, unless the client
+		// propagated its own.
+		ctx, span := trace.Start(trace.Extract(r.Context(), r.Header), "pmuxapi.HandleCreate")
+		defer span.End()
+		if h.drain.draining() {
+			h.writeError(w, fmt.Errorf("server is draining, new sessions are not accepted"), http.StatusServiceUnavailable)
 			return
 		}
+		var c createRequest
+		if status, err := decodeCreate(w, r, &c); err != nil {
+			h.writeError(w, err, status)
+			return
+		}
+		ns := NamespaceFromContext(r.Context())
+		if r.URL.Query().Get("dry_run") == "true" {
+			// Scheduled sessions are checked as if they started now.
+			if status, err := h.dryRunSession(ctx, &c, name, args, ns); err != nil {
+				h.writeError(w, err, status)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if c.Replicas != 0 && (c.StartAt != nil || c.Cron != "") {
+			h.writeError(w, fmt.Errorf("replicas cannot be scheduled"), http.StatusBadRequest)
+			return
+		}
+		if c.StartAt != nil || c.Cron != "" {
+			s, err := h.scheduleSession(&c, name, args, ns)
+			if err != nil {
+				h.writeError(w, err, http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(s)
+			return
+		}
+		if c.Replicas != 0 {
+			sids, id, status, err := h.createGroup(ctx, &c, name, args, ns)
+			if err != nil {
+				span.SetError(err)
+				h.writeError(w, err, status)
+				return
+			}
+			span.SetAttribute("pmux.group", id)
+			auditSessions(r.Context(), sids...)
+			g, err := h.group(id, ns)
+			if err != nil {
+				h.writeError(w, err, http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(g)
+			return
+		}
+
+		pw, status, err := h.createSession(ctx, &c, name, args, ns)
+		if err != nil {
+			span.SetError(err)
+			h.writeError(w, err, status)
+			return
+		}
+		sid := pw.SID()
+		s, _ := pw.ReadSession()
+		span.SetAttribute("pmux.sid", sid)
+		if s != nil {
+			span.SetAttribute("pmux.exec", s.Exec)
+		}
+		auditSessions(r.Context(), sid)
 		if err = h.writeSID(w, sid); err != nil {
+			if h.sched != nil {
+				h.sched.remove(sid)
+			}
 			pw.Trash()
+			return
 		}
+		h.record(pw)
+		h.notify(pwrap.EventCreated, sid, s)
 	}
 }
 
+// executable returns the executable run by the session described by "c", and
+// its arguments: "name" and "args" unless it selects another one.
+func (h *SessionHandler) executable(c *createRequest, name string, args []string) (string, []string, error) {
+	if err := pwrap.ValidateExtraArgs(c.ExtraArgs); err != nil {
+		return "", nil, err
+	}
+	if c.Exec != "" {
+		h.settings.RLock()
+		e, ok := h.execs[c.Exec]
+		h.settings.RUnlock()
+		if !ok {
+			return "", nil, fmt.Errorf("executable %q is not allowed", c.Exec)
+		}
+		name, args = e.Path, e.Args
+	}
+	if len(c.ExtraArgs) == 0 {
+		return name, args, nil
+	}
+	// The arguments of the executable are shared by the sessions.
+	return name, append(append([]string{}, args...), c.ExtraArgs...), nil
+}
+
+// sessionOptions validates "c", returning the options of the session it describes
+// but its executable.
+func (h *SessionHandler) sessionOptions(c *createRequest) ([]func(*pwrap.PWrap) error, error) {
+	if c.Container != nil {
+		if err := h.checkContainer(c.Container); err != nil {
+			return nil, err
+		}
+	}
+	var job *pwrap.Kubernetes
+	if c.Kubernetes != nil {
+		if c.Container != nil {
+			return nil, fmt.Errorf("container and kubernetes cannot be both set")
+		}
+		var err error
+		if job, err = h.jobFor(c.Kubernetes); err != nil {
+			return nil, err
+		}
+	}
+	var remote *pwrap.Remote
+	if c.Host != "" {
+		if c.Container != nil || c.Kubernetes != nil {
+			return nil, fmt.Errorf("host cannot be set together with container or kubernetes")
+		}
+		var err error
+		if remote, err = h.placementOn(c.Host); err != nil {
+			return nil, err
+		}
+	}
+	quota, err := h.quotaFor(c.DiskQuota)
+	if err != nil {
+		return nil, err
+	}
+	if err := parseRetention(c.Retention); err != nil {
+		return nil, err
+	}
+	maxRuntime, err := pwrap.ParseMaxRuntime(c.MaxRuntime)
+	if err != nil {
+		return nil, err
+	}
+	if err := pwrap.ValidateLabels(c.Labels); err != nil {
+		return nil, err
+	}
+	if c.Name != "" {
+		if err := pwrap.ValidateName(c.Name); err != nil {
+			return nil, err
+		}
+	}
+	if err := secrets.ValidateRefs(c.Secrets); err != nil {
+		return nil, err
+	}
+	if err := h.checkSecrets(c.Secrets); err != nil {
+		return nil, err
+	}
+	if err := pwrap.ValidateHeaders(c.RegisterHeaders); err != nil {
+		return nil, err
+	}
+	if err := pwrap.ValidateEnv(c.Env); err != nil {
+		return nil, err
+	}
+	for k := range c.Env {
+		if _, ok := c.Secrets[k]; ok {
+			return nil, fmt.Errorf("environment variable %q is also a secret", k)
+		}
+	}
+	artifacts, err := h.artifactsFor(c.Artifacts)
+	if err != nil {
+		return nil, err
+	}
+	sidecars, err := h.sidecarsFor(c.Sidecars)
+	if err != nil {
+		return nil, err
+	}
+	if h.configVars != nil && c.rawConfig == nil {
+		// The identifier of the session is not known yet.
+		data := &ConfigData{SID: "pmux-template", WorkDir: filepath.Join(rootDir, "pmux-template"), Labels: c.Labels, Vars: h.configVars}
+		if _, err := renderConfig(c.Config, data); err != nil {
+			return nil, err
+		}
+	}
+	return []func(*pwrap.PWrap) error{
+		pwrap.DiskQuota(quota),
+		pwrap.MaxRuntime(maxRuntime),
+		pwrap.Docker(c.Container),
+		pwrap.KubernetesJob(job),
+		pwrap.OnRemote(remote),
+		pwrap.Labels(c.Labels),
+		pwrap.Name(c.Name),
+		pwrap.Priority(c.Priority),
+		pwrap.Secrets(c.Secrets),
+		pwrap.RegisterHeaders(c.RegisterHeaders),
+		pwrap.Env(c.Env),
+		pwrap.UploadArtifacts(artifacts),
+		pwrap.Stdin(c.Stdin),
+		pwrap.Sidecars(sidecars...),
+	}, nil
+}
+
+// createSession creates the session described by "c" in namespace "ns" and
+// starts it, or queues it if the concurrency limit is reached. On failure, the
+// status code describing the error is returned as well.
+func (h *SessionHandler) createSession(ctx context.Context, c *createRequest, name string, args []string, ns string) (*pwrap.PWrap, int, error) {
+	name, args, err := h.executable(c, name, args)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	pw, status, err := h.newSession(ctx, c, name, args, ns, true)
+	if err != nil {
+		return nil, status, err
+	}
+	if h.preflight {
+		if err := pw.DryRun(ctx); err != nil {
+			pw.Trash()
+			return nil, http.StatusUnprocessableEntity, err
+		}
+	}
+	if h.sched != nil {
+		log.Printf("[INFO] Queueing [%v] session, working dir: %v", name, pw.WorkDir())
+		err = h.sched.enqueue(pw)
+	} else {
+		log.Printf("[INFO] Starting [%v] session, working dir: %v", name, pw.WorkDir())
+		_, err = pw.StartSession()
+	}
+	if err != nil {
+		pw.Trash()
+		return nil, http.StatusInternalServerError, err
+	}
+	return pw, 0, nil
+}
+
+// dryRunSession checks that the session described by "c" can be started in
+// namespace "ns", without starting it. On failure, the status code describing the
+// error is returned as well.
+func (h *SessionHandler) dryRunSession(ctx context.Context, c *createRequest, name string, args []string, ns string) (int, error) {
+	name, args, err := h.executable(c, name, args)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	pw, status, err := h.newSession(ctx, c, name, args, ns, false)
+	if err != nil {
+		return status, err
+	}
+	defer pw.Trash()
+	if err := pw.DryRun(ctx); err != nil {
+		return http.StatusUnprocessableEntity, err
+	}
+	return 0, nil
+}
+
+// newSession validates "c" and prepares the working directory of the session it
+// describes, running executable "name" in namespace "ns", without starting it. If
+// "limited" is set, the session is refused when the namespace reached its limit.
+func (h *SessionHandler) newSession(ctx context.Context, c *createRequest, name string, args []string, ns string, limited bool) (*pwrap.PWrap, int, error) {
+	opts, err := h.sessionOptions(c)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	if limited || c.Name != "" {
+		// The namespace and the name are recorded by "initSession"
+		// before the lock is released.
+		h.creating.Lock()
+		defer h.creating.Unlock()
+	}
+	if limited {
+		if err := h.checkLimit(ns); err != nil {
+			return nil, http.StatusTooManyRequests, err
+		}
+	}
+	if c.Name != "" {
+		if err := h.checkName(ns, c.Name); err != nil {
+			return nil, http.StatusConflict, err
+		}
+	}
+
+	pw, err := pwrap.New(append(opts,
+		pwrap.Trace(trace.FromContext(ctx)),
+		pwrap.Exec(name, args...),
+		pwrap.RootDir(rootDir),
+		pwrap.Register(c.URL),
+		pwrap.Namespace(ns),
+		pwrap.GracePeriod(h.grace),
+		pwrap.Webhooks(h.webhooks...),
+		pwrap.Detach(h.detach),
+		pwrap.SockDir(h.sockDir),
+		pwrap.Streaming(h.streaming),
+		pwrap.CommandOps(h.commandOps...),
+		pwrap.APIPorts(h.apiPorts),
+	)...)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	if err := h.initSession(pw, c, ns, limited); err != nil {
+		pw.Trash()
+		return nil, http.StatusInternalServerError, err
+	}
+	return pw, 0, nil
+}
+
+// initSession stores the configuration, the retention period and the name requested
+// by "c" in the working directory of "pw", a session of namespace "ns". Templates
+// of the configuration are executed beforehand, if enabled, unless it was
+// uploaded as is. If "limited" is set, the state is recorded so that the session
+// counts against the limit of its namespace right away.
+func (h *SessionHandler) initSession(pw *pwrap.PWrap, c *createRequest, ns string, limited bool) error {
+	if c.rawConfig != nil {
+		if err := pw.WriteConfig(bytes.NewReader(c.rawConfig)); err != nil {
+			return err
+		}
+		return pw.UpdateSession(func(s *pwrap.Session) {
+			s.Retention = c.Retention
+			s.RawConfig = true
+		})
+	}
+	config := c.Config
+	if h.configVars != nil {
+		var err error
+		data := &ConfigData{SID: pw.SID(), WorkDir: pw.WorkDir(), Namespace: ns, Labels: c.Labels, Vars: h.configVars}
+		if config, err = renderConfig(config, data); err != nil {
+			return err
+		}
+	}
+	configFile, err := pw.Open(pwrap.FileConfig, os.O_RDWR|os.O_CREATE, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	defer configFile.Close()
+	if err := json.NewEncoder(configFile).Encode(config); err != nil {
+		return fmt.Errorf("unable to store configuration: %w", err)
+	}
+	if c.Retention == "" && c.Name == "" && !limited {
+		return nil
+	}
+	// Recording the state makes the name and the namespace of the
+	// session known.
+	return pw.UpdateSession(func(s *pwrap.Session) {
+		s.Retention = c.Retention
+	})
+}
+
+// deleteWait is the time the handlers wait for a session to be deleted. Sessions
+// that take longer, using their grace period to exit, keep being deleted in the
+// background, so that responses are not delayed past the server's write timeout.
+const deleteWait = time.Second * 3
+
+// errDeletePending is returned for the sessions still being deleted in the
+// background, see "deleteWait".
+var errDeletePending = errors.New("session is still being deleted")
+
+// within runs "f", waiting at most "d" for it to return. If "f" takes longer it
+// keeps running in the background, "errDeletePending" is returned and its error,
+// if any, is passed to "late".
+func within(d time.Duration, f func() error, late func(error)) error {
+	done := make(chan error, 1)
+	go func() { done <- f() }()
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-t.C:
+		go func() {
+			if err := <-done; err != nil {
+				late(err)
+			}
+		}()
+		return errDeletePending
+	}
+}
+
+// deleteSessionWithin deletes session "sid" like "deleteSession", returning
+// "errDeletePending" if that takes longer than "deleteWait".
+func (h *SessionHandler) deleteSessionWithin(sid string, keepFiles bool) error {
+	return within(deleteWait, func() error {
+		return h.deleteSession(sid, keepFiles)
+	}, func(err error) {
+		log.Printf("[ERROR] unable to delete session %s: %v", sid, err)
+	})
+}
+
+// deleteSession kills the session "sid", trashing its files unless "keepFiles" is set.
+func (h *SessionHandler) deleteSession(sid string, keepFiles bool) error {
+	pw, err := pwrap.New(pwrap.OverrideSID(sid), pwrap.RootDir(rootDir), pwrap.GracePeriod(h.grace))
+	if err != nil {
+		return err
+	}
+
+	if h.sched != nil {
+		h.sched.remove(sid)
+	}
+	s, _ := pw.ReadSession()
+	deleteFunc := pw.Trash
+	if keepFiles {
+		deleteFunc = pw.KillSession
+	}
+	if err := deleteFunc(); err != nil {
+		return err
+	}
+	if h.store != nil && !keepFiles {
+		if err := h.store.Delete(sid); err != nil {
+			log.Printf("[WARN] unable to remove session %s from the store: %v", sid, err)
+		}
+	}
+	h.notify(pwrap.EventDeleted, sid, s)
+	return nil
+}
+
+// KillLocal terminates session "sid" without going through a server, trashing its
+// working directory unless "keepFiles" is set. The records of a running server are
+// left untouched.
+func KillLocal(sid string, keepFiles bool, grace time.Duration) error {
+	if _, err := openSession(sid); err != nil && !pwrap.HasSession(rootDir, sid) {
+		return err
+	}
+	return (&SessionHandler{grace: grace}).deleteSession(sid, keepFiles)
+}
+
 func (h *SessionHandler) HandleDelete(keepFiles bool) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		sid := mux.Vars(r)["sid"]
@@ -107,20 +1039,237 @@ func (h *SessionHandler) HandleDelete(keepFiles bool) http.HandlerFunc {
 			return
 		}
 
-		pw, err := pwrap.New(pwrap.OverrideSID(sid), pwrap.RootDir(rootDir))
+		err := h.deleteSessionWithin(sid, keepFiles)
+		if errors.Is(err, errDeletePending) {
+			log.Printf("[INFO] session %s is still terminating, deleting it in the background", sid)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusAccepted)
+			h.writeSID(w, sid)
+			return
+		}
 		if err != nil {
 			h.writeError(w, err, http.StatusInternalServerError)
 			return
 		}
+		h.writeSID(w, sid)
+	}
+}
+
+// HandleRestart restarts a session, keeping its identifier, configuration and
+// working directory.
+func (h *SessionHandler) HandleRestart() http.HandlerFunc {
+	return h.handleRestart(false)
+}
+
+// HandleResume restarts a session like "HandleRestart", but its child continues
+// from the checkpoint it wrote, see "pwrap.PWrap.Resume".
+func (h *SessionHandler) HandleResume() http.HandlerFunc {
+	return h.handleRestart(true)
+}
+
+// HandleUnpause resumes the child of a paused session, see "pwrapapi.Pause".
+// Sessions that are not paused are left alone and answered with 409.
+func (h *SessionHandler) HandleUnpause() http.HandlerFunc {
+	proxy := h.HandleProxy("/resume")
+	return func(w http.ResponseWriter, r *http.Request) {
+		sid := mux.Vars(r)["sid"]
+		s, _, err := h.readSession(sid)
+		if err != nil {
+			h.writeSessionError(w, err)
+			return
+		}
+		if s.State != pwrap.SessionPaused {
+			h.writeError(w, fmt.Errorf("session %s is not paused: %s", sid, s.State), http.StatusConflict)
+			return
+		}
+		proxy(w, r)
+	}
+}
 
-		deleteFunc := pw.Trash
-		if keepFiles {
-			deleteFunc = pw.KillSession
+func (h *SessionHandler) handleRestart(resume bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sid := mux.Vars(r)["sid"]
+		if _, err := openSession(sid); err != nil {
+			h.writeSessionError(w, err)
+			return
 		}
-		if err = deleteFunc(); err != nil {
+		pw, err := pwrap.New(
+			pwrap.OverrideSID(sid),
+			pwrap.RootDir(rootDir),
+			pwrap.GracePeriod(h.grace),
+			pwrap.Webhooks(h.webhooks...),
+			pwrap.Detach(h.detach),
+			pwrap.APIPorts(h.apiPorts),
+		)
+		if err != nil {
 			h.writeError(w, err, http.StatusInternalServerError)
 			return
 		}
+
+		restart, verb := pw.Restart, "Restarting"
+		if resume {
+			restart, verb = pw.Resume, "Resuming"
+		}
+		log.Printf("[INFO] %s session %v, working dir: %v", verb, sid, pw.WorkDir())
+		if _, err := restart(); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, pwrap.ErrNoSession) || errors.Is(err, pwrap.ErrNoCheckpoint) {
+				// Sessions created by older versions cannot be restarted.
+				status = http.StatusConflict
+			}
+			h.writeError(w, err, status)
+			return
+		}
+		h.record(pw)
+		h.writeSID(w, sid)
+	}
+}
+
+// MaxConfigSize is the maximum size of a configuration accepted by the server.
+const MaxConfigSize = pwrapapi.MaxConfigSize
+
+// HandleConfig serves the configuration file of a session.
+func (h *SessionHandler) HandleConfig() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		pw, err := openSession(mux.Vars(r)["sid"])
+		if err != nil {
+			h.writeSessionError(w, err)
+			return
+		}
+		pwrapapi.ConfigHandler(pw.Path(pwrap.FileConfig))(w, r)
+	}
+}
+
+// HandleUpdateConfig replaces the configuration file of a session with the request
+// body. The configuration of running sessions is pushed to their wrapper, which
+// tells the child to reload it, see "pwrapapi.Reload".
+func (h *SessionHandler) HandleUpdateConfig() http.HandlerFunc {
+	reload := h.HandleProxy("/config")
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+		sid := mux.Vars(r)["sid"]
+		d, err := h.sessionDetail(sid, pwrap.HasSession(rootDir, sid))
+		if err != nil {
+			h.writeSessionError(w, err)
+			return
+		}
+		if d.Tmux && (d.State == pwrap.SessionRunning || d.State == pwrap.SessionPaused) {
+			reload(w, r)
+			return
+		}
+		if d.Tmux && d.State != pwrap.SessionCreated {
+			h.writeError(w, fmt.Errorf("session %s is finishing, its configuration cannot be replaced", sid), http.StatusConflict)
+			return
+		}
+		pw, err := openSession(sid)
+		if err != nil {
+			h.writeSessionError(w, err)
+			return
+		}
+		if err := pw.WriteConfig(http.MaxBytesReader(w, r.Body, MaxConfigSize)); err != nil {
+			status := http.StatusInternalServerError
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				status = http.StatusRequestEntityTooLarge
+			}
+			h.writeError(w, err, status)
+			return
+		}
 		h.writeSID(w, sid)
 	}
 }
+
+// BulkDeleteResult reports the outcome of a bulk delete.
+type BulkDeleteResult struct {
+	Deleted []string `json:"deleted"`
+	// Pending are the sessions still being deleted in the background,
+	// which took too long to terminate.
+	Pending []string          `json:"pending,omitempty"`
+	Errors  map[string]string `json:"errors,omitempty"`
+}
+
+// HandleBulkDelete deletes either the sessions listed in the "sids" field of the
+// JSON body, by identifier or name, or those selected by the filters described in "ParseListOptions".
+// At least one of the two has to be provided. Sessions are deleted concurrently.
+func (h *SessionHandler) HandleBulkDelete(keepFiles bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+		var body struct {
+			SIDs []string `json:"sids"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+			h.writeError(w, fmt.Errorf("unable to decode bulk delete payload body: %w", err), http.StatusBadRequest)
+			return
+		}
+
+		sids := body.SIDs
+		for i, v := range sids {
+			sids[i] = h.resolveSID(NamespaceFromContext(r.Context()), v)
+		}
+		if len(sids) == 0 {
+			if len(r.URL.Query()) == 0 {
+				h.writeError(w, fmt.Errorf("refusing to delete every session: provide either a list of sids or a filter"), http.StatusBadRequest)
+				return
+			}
+			opts, err := ParseListOptions(r.URL.Query())
+			if err != nil {
+				h.writeError(w, err, http.StatusBadRequest)
+				return
+			}
+			opts.Namespace = NamespaceFromContext(r.Context())
+			sessions, err := h.listSessions()
+			if err != nil {
+				h.writeError(w, err, http.StatusInternalServerError)
+				return
+			}
+			page, _ := opts.Apply(sessions)
+			for _, v := range page {
+				if v.WorkDir != "" {
+					sids = append(sids, v.SID)
+				}
+			}
+		}
+
+		auditSessions(r.Context(), sids...)
+		h.writeResponse(w, h.deleteSessions(sids, NamespaceFromContext(r.Context()), keepFiles))
+	}
+}
+
+// deleteSessions deletes the sessions "sids" of namespace "ns" concurrently,
+// trashing their files unless "keepFiles" is set.
+func (h *SessionHandler) deleteSessions(sids []string, ns string, keepFiles bool) *BulkDeleteResult {
+	res := BulkDeleteResult{Deleted: []string{}, Errors: map[string]string{}}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, sid := range sids {
+		if !validSID(sid) {
+			res.Errors[sid] = "invalid session identifier"
+			continue
+		}
+		if found, ok := h.namespaceOf(sid); ok && found != ns {
+			res.Errors[sid] = "session not found"
+			continue
+		}
+		wg.Add(1)
+		go func(sid string) {
+			defer wg.Done()
+			err := h.deleteSessionWithin(sid, keepFiles)
+			mu.Lock()
+			defer mu.Unlock()
+			if errors.Is(err, errDeletePending) {
+				res.Pending = append(res.Pending, sid)
+				return
+			}
+			if err != nil {
+				log.Printf("[ERROR] unable to delete session %s: %v", sid, err)
+				res.Errors[sid] = err.Error()
+				return
+			}
+			res.Deleted = append(res.Deleted, sid)
+		}(sid)
+	}
+	wg.Wait()
+	sort.Strings(res.Deleted)
+	sort.Strings(res.Pending)
+	return &res
+}
@@ -0,0 +1,39 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWithin(t *testing.T) {
+	t.Parallel()
+
+	fail := errors.New("failed")
+	late := make(chan error, 1)
+	if err := within(time.Second, func() error { return fail }, func(err error) { late <- err }); err != fail {
+		t.Fatalf("Errors of quick functions SHOULD be returned, found %v", err)
+	}
+
+	release := make(chan struct{})
+	err := within(10*time.Millisecond, func() error {
+		<-release
+		return fail
+	}, func(err error) { late <- err })
+	if !errors.Is(err, errDeletePending) {
+		t.Fatalf("Slow functions SHOULD be left running in the background, found %v", err)
+	}
+	close(release)
+	select {
+	case err := <-late:
+		if err != fail {
+			t.Fatalf("Unexpected late error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Errors of slow functions SHOULD be reported once they return")
+	}
+}
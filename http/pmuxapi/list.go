@@ -0,0 +1,180 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kim-company/pmux/pwrap"
+)
+
+// ListOptions select and order the sessions returned by the list route.
+type ListOptions struct {
+	// Limit is the maximum number of sessions returned, 0 meaning no limit.
+	Limit  int
+	Offset int
+	// States, if not empty, contains the states the sessions must be in.
+	// "StateFinished" matches exited, failed and timed out sessions.
+	States []pwrap.SessionState
+	// OlderThan, if set, selects sessions created at least this long ago.
+	OlderThan time.Duration
+	// Labels contains the labels the sessions must have.
+	Labels map[string]string
+	// Namespace, if set, is the namespace the sessions must belong to. It
+	// is selected by the "NamespaceHeader" rather than a query parameter.
+	Namespace string
+	// Sort is either "sid", "created_at" or "-created_at" for descending
+	// creation time.
+	Sort string
+}
+
+// StateFinished is a filter matching sessions that are no longer running.
+const StateFinished pwrap.SessionState = "finished"
+
+// ParseListOptions parses the "limit", "offset", "state", "label", "older_than"
+// and "sort" query parameters. "state" and "label" may be repeated, the latter in the
+// "key=value" form.
+func ParseListOptions(q url.Values) (*ListOptions, error) {
+	opts := &ListOptions{Labels: map[string]string{}, Sort: "sid"}
+	var err error
+	if v := q.Get("limit"); v != "" {
+		if opts.Limit, err = strconv.Atoi(v); err != nil || opts.Limit < 0 {
+			return nil, fmt.Errorf("invalid limit parameter %q", v)
+		}
+	}
+	if v := q.Get("offset"); v != "" {
+		if opts.Offset, err = strconv.Atoi(v); err != nil || opts.Offset < 0 {
+			return nil, fmt.Errorf("invalid offset parameter %q", v)
+		}
+	}
+	if v := q.Get("older_than"); v != "" {
+		if opts.OlderThan, err = time.ParseDuration(v); err != nil {
+			return nil, fmt.Errorf("invalid older_than parameter %q: %w", v, err)
+		}
+	}
+	for _, v := range q["state"] {
+		opts.States = append(opts.States, pwrap.SessionState(v))
+	}
+	for _, v := range q["label"] {
+		kv := strings.SplitN(v, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid label parameter %q, expected key=value", v)
+		}
+		opts.Labels[kv[0]] = kv[1]
+	}
+	if v := q.Get("sort"); v != "" {
+		switch v {
+		case "sid", "created_at", "-created_at":
+			opts.Sort = v
+		default:
+			return nil, fmt.Errorf("invalid sort parameter %q", v)
+		}
+	}
+	return opts, nil
+}
+
+// Values encodes "o" as the query parameters parsed by "ParseListOptions".
+func (o *ListOptions) Values() url.Values {
+	q := url.Values{}
+	if o.Limit > 0 {
+		q.Set("limit", strconv.Itoa(o.Limit))
+	}
+	if o.Offset > 0 {
+		q.Set("offset", strconv.Itoa(o.Offset))
+	}
+	if o.OlderThan > 0 {
+		q.Set("older_than", o.OlderThan.String())
+	}
+	for _, v := range o.States {
+		q.Add("state", string(v))
+	}
+	keys := make([]string, 0, len(o.Labels))
+	for k := range o.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		q.Add("label", k+"="+o.Labels[k])
+	}
+	if o.Sort != "" && o.Sort != "sid" {
+		q.Set("sort", o.Sort)
+	}
+	return q
+}
+
+// Match reports whether "d" satisfies the namespace, state and label filters.
+func (o *ListOptions) Match(d *SessionDetail) bool {
+	if o.Namespace != "" && d.InNamespace() != o.Namespace {
+		return false
+	}
+	if len(o.States) > 0 {
+		found := false
+		for _, v := range o.States {
+			if d.State == v || (v == StateFinished && (d.State == pwrap.SessionExited || d.State == pwrap.SessionFailed || d.State == pwrap.SessionTimedOut)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if o.OlderThan > 0 && (d.CreatedAt.IsZero() || time.Since(d.CreatedAt) < o.OlderThan) {
+		return false
+	}
+	for k, v := range o.Labels {
+		if lv, ok := d.Labels[k]; !ok || lv != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Apply filters, sorts and paginates "sessions", returning the selected page
+// and the number of sessions matching the filters.
+func (o *ListOptions) Apply(sessions []*SessionDetail) ([]*SessionDetail, int) {
+	acc := make([]*SessionDetail, 0, len(sessions))
+	for _, v := range sessions {
+		if o.Match(v) {
+			acc = append(acc, v)
+		}
+	}
+
+	switch o.Sort {
+	case "created_at":
+		sort.SliceStable(acc, func(i, j int) bool { return acc[i].CreatedAt.Before(acc[j].CreatedAt) })
+	case "-created_at":
+		sort.SliceStable(acc, func(i, j int) bool { return acc[i].CreatedAt.After(acc[j].CreatedAt) })
+	default:
+		sort.SliceStable(acc, func(i, j int) bool { return acc[i].SID < acc[j].SID })
+	}
+
+	total := len(acc)
+	if o.Offset >= total {
+		return []*SessionDetail{}, total
+	}
+	acc = acc[o.Offset:]
+	if o.Limit > 0 && o.Limit < len(acc) {
+		acc = acc[:o.Limit]
+	}
+	return acc, total
+}
+
+// ListLocal returns the sessions of this host, read from the running wrappers and from the
+// working directories inside the root directory, sorted by identifier. It does
+// not need a running server.
+func ListLocal() ([]*SessionDetail, error) {
+	sessions, err := (&SessionHandler{}).listSessions()
+	if err != nil {
+		return nil, err
+	}
+	sessions, _ = (&ListOptions{Sort: "sid"}).Apply(sessions)
+	return sessions, nil
+}
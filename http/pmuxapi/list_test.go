@@ -0,0 +1,93 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/kim-company/pmux/pwrap"
+)
+
+func TestListOptions_Apply(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	sessions := []*SessionDetail{
+		{Session: pwrap.Session{SID: "pmux-a", State: pwrap.SessionRunning, CreatedAt: now.Add(time.Second * 2)}},
+		{Session: pwrap.Session{SID: "pmux-b", State: pwrap.SessionExited, CreatedAt: now, Labels: map[string]string{"team": "video"}}},
+		{Session: pwrap.Session{SID: "pmux-c", State: pwrap.SessionRunning, CreatedAt: now.Add(time.Second), Labels: map[string]string{"team": "video"}}},
+	}
+
+	for _, tt := range []struct {
+		query string
+		sids  []string
+		total int
+	}{
+		{"", []string{"pmux-a", "pmux-b", "pmux-c"}, 3},
+		{"state=running", []string{"pmux-a", "pmux-c"}, 2},
+		{"label=team=video", []string{"pmux-b", "pmux-c"}, 2},
+		{"label=team=video&state=running", []string{"pmux-c"}, 1},
+		{"state=finished", []string{"pmux-b"}, 1},
+		{"older_than=1h", []string{}, 0},
+		{"sort=created_at", []string{"pmux-b", "pmux-c", "pmux-a"}, 3},
+		{"sort=-created_at&limit=2", []string{"pmux-a", "pmux-c"}, 3},
+		{"limit=1&offset=1", []string{"pmux-b"}, 3},
+		{"offset=5", []string{}, 3},
+	} {
+		q, _ := url.ParseQuery(tt.query)
+		opts, err := ParseListOptions(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		page, total := opts.Apply(sessions)
+		if total != tt.total {
+			t.Fatalf("%q: wanted total %d, found %d", tt.query, tt.total, total)
+		}
+		sids := make([]string, len(page))
+		for i, v := range page {
+			sids[i] = v.SID
+		}
+		if len(sids) != len(tt.sids) {
+			t.Fatalf("%q: wanted %v, found %v", tt.query, tt.sids, sids)
+		}
+		for i := range sids {
+			if sids[i] != tt.sids[i] {
+				t.Fatalf("%q: wanted %v, found %v", tt.query, tt.sids, sids)
+			}
+		}
+	}
+}
+
+func TestParseListOptions_Invalid(t *testing.T) {
+	t.Parallel()
+
+	for _, v := range []string{"limit=-1", "offset=x", "label=team", "sort=name"} {
+		q, _ := url.ParseQuery(v)
+		if _, err := ParseListOptions(q); err == nil {
+			t.Fatalf("%q: expected an error", v)
+		}
+	}
+}
+
+func TestListOptions_Values(t *testing.T) {
+	t.Parallel()
+
+	for _, v := range []string{
+		"",
+		"limit=2&offset=1",
+		"label=owner%3Dme&label=team%3Dvideo&older_than=1h0m0s&sort=-created_at&state=running&state=exited",
+	} {
+		q, _ := url.ParseQuery(v)
+		opts, err := ParseListOptions(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have := opts.Values().Encode(); have != q.Encode() {
+			t.Fatalf("%q: wanted %q, found %q", v, q.Encode(), have)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// sessionByName returns the identifier of the session of namespace "ns" named
+// "name", reading the states recorded in the working directories and in the
+// store without refreshing them. It reports false if no session is found.
+func (h *SessionHandler) sessionByName(ns, name string) (string, bool) {
+	entries, err := os.ReadDir(rootDir)
+	if err != nil && !os.IsNotExist(err) {
+		return "", false
+	}
+	for _, v := range entries {
+		if !v.IsDir() {
+			continue
+		}
+		pw, err := openSession(v.Name())
+		if err != nil {
+			continue
+		}
+		if s, err := pw.ReadSession(); err == nil && s.Name == name && s.InNamespace() == ns {
+			return s.SID, true
+		}
+	}
+	if h.store == nil {
+		return "", false
+	}
+	records, err := h.store.List()
+	if err != nil {
+		return "", false
+	}
+	for _, v := range records {
+		if v.Name == name && v.InNamespace() == ns {
+			return v.SID, true
+		}
+	}
+	return "", false
+}
+
+// resolveSID returns the identifier of the session of namespace "ns" named "s",
+// or "s" itself if it is an identifier or no session is named so.
+func (h *SessionHandler) resolveSID(ns, s string) string {
+	if strings.HasPrefix(s, "pmux-") {
+		return s
+	}
+	if sid, ok := h.sessionByName(ns, s); ok {
+		return sid
+	}
+	return s
+}
+
+// resolveName replaces the "sid" variable of "r" with the identifier of the
+// session of namespace "ns" it names, if it is an alias rather than an
+// identifier.
+func (h *SessionHandler) resolveName(r *http.Request, ns string) *http.Request {
+	vars := mux.Vars(r)
+	name, ok := vars["sid"]
+	if !ok {
+		return r
+	}
+	sid := h.resolveSID(ns, name)
+	if sid == name {
+		return r
+	}
+	resolved := make(map[string]string, len(vars))
+	for k, v := range vars {
+		resolved[k] = v
+	}
+	resolved["sid"] = sid
+	return mux.SetURLVars(r, resolved)
+}
+
+// checkName reports an error if a session of namespace "ns" is already named
+// "name".
+func (h *SessionHandler) checkName(ns, name string) error {
+	if sid, ok := h.sessionByName(ns, name); ok {
+		return fmt.Errorf("session name %q is already used by %s", name, sid)
+	}
+	return nil
+}
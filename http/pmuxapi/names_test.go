@@ -0,0 +1,64 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/kim-company/pmux/pwrap"
+)
+
+func TestSessionHandler_ResolveName(t *testing.T) {
+	t.Parallel()
+
+	pw, err := pwrap.New(pwrap.RootDir(RootDir()), pwrap.Namespace("names-test"), pwrap.Name("aliased"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(pw.WorkDir())
+	if err := pw.UpdateSession(func(*pwrap.Session) {}); err != nil {
+		t.Fatal(err)
+	}
+
+	h := &SessionHandler{}
+	r := mux.NewRouter()
+	r.Use(h.namespaceMiddleware)
+	r.HandleFunc("/api/v1/sessions/{sid}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(mux.Vars(r)["sid"]))
+	})
+	for _, tt := range []struct {
+		ns, path, sid string
+		status        int
+	}{
+		{"names-test", "aliased", pw.SID(), http.StatusOK},
+		{"names-test", pw.SID(), pw.SID(), http.StatusOK},
+		{"names-test", "missing", "missing", http.StatusOK},
+		// Names are resolved within the namespace of the request.
+		{"default", "aliased", "aliased", http.StatusOK},
+		{"default", pw.SID(), "", http.StatusNotFound},
+	} {
+		req := httptest.NewRequest("GET", "/api/v1/sessions/"+tt.path, nil)
+		req.Header.Set(NamespaceHeader, tt.ns)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.status {
+			t.Fatalf("%s/%s: wanted status %d, found %d", tt.ns, tt.path, tt.status, w.Code)
+		}
+		if tt.status == http.StatusOK && w.Body.String() != tt.sid {
+			t.Fatalf("%s/%s SHOULD resolve to %q, found %q", tt.ns, tt.path, tt.sid, w.Body)
+		}
+	}
+
+	if err := h.checkName("names-test", "aliased"); err == nil {
+		t.Fatalf("Names SHOULD be unique within their namespace")
+	}
+	if err := h.checkName("default", "aliased"); err != nil {
+		t.Fatalf("Names SHOULD be reusable in other namespaces: %v", err)
+	}
+}
@@ -0,0 +1,173 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/gorilla/mux"
+	"github.com/kim-company/pmux/pwrap"
+)
+
+// NamespaceHeader is the header selecting the namespace of the sessions a
+// request operates on. Requests without it operate on "pwrap.DefaultNamespace".
+const NamespaceHeader = "X-Pmux-Namespace"
+
+// NamespaceAPIKeys allows clients presenting one of "keys" to use the session
+// routes of namespace "ns" only.
+func NamespaceAPIKeys(ns string, keys ...string) func(*Router) {
+	return func(r *Router) {
+		if r.scopedKeys == nil {
+			r.scopedKeys = map[string][]string{}
+		}
+		for _, v := range keys {
+			r.scopedKeys[v] = append(r.scopedKeys[v], ns)
+		}
+	}
+}
+
+// NamespaceLimit limits the number of sessions of namespace "ns" that are not
+// finished to "n". Namespaces are not limited by default.
+func NamespaceLimit(ns string, n int) func(*Router) {
+	return func(r *Router) {
+		if r.nsLimits == nil {
+			r.nsLimits = map[string]int{}
+		}
+		r.nsLimits[ns] = n
+	}
+}
+
+type namespaceKey struct{}
+
+// NamespaceFromContext returns the namespace selected by the request.
+func NamespaceFromContext(ctx context.Context) string {
+	if v, ok := ctx.Value(namespaceKey{}).(string); ok {
+		return v
+	}
+	return pwrap.DefaultNamespace
+}
+
+type scopeKey struct{}
+
+// scopeFromContext returns the namespaces the client is restricted to, nil if
+// it may use every namespace.
+func scopeFromContext(ctx context.Context) []string {
+	scope, _ := ctx.Value(scopeKey{}).([]string)
+	return scope
+}
+
+// claimsScope returns the namespaces listed in the "namespaces" claim, or
+// the one in the "namespace" claim, nil if neither is present.
+func claimsScope(c Claims) []string {
+	if ns, ok := c["namespace"].(string); ok {
+		return []string{ns}
+	}
+	list, ok := c["namespaces"].([]interface{})
+	if !ok {
+		return nil
+	}
+	scope := []string{}
+	for _, v := range list {
+		if ns, ok := v.(string); ok {
+			scope = append(scope, ns)
+		}
+	}
+	return scope
+}
+
+// namespaceMiddleware resolves the namespace selected by the request, checking
+// that the client is allowed to use it. Sessions of other namespaces are
+// reported as not existing, while those referred to by their name are replaced
+// by their identifier.
+func (h *SessionHandler) namespaceMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ns := r.Header.Get(NamespaceHeader)
+		if ns == "" && isWebSocket(r) {
+			// Browsers cannot set the headers of WebSocket requests.
+			ns = r.URL.Query().Get("namespace")
+		}
+		if ns == "" {
+			ns = pwrap.DefaultNamespace
+		}
+		if err := pwrap.ValidateNamespace(ns); err != nil {
+			h.writeError(w, err, http.StatusBadRequest)
+			return
+		}
+		if scope := scopeFromContext(r.Context()); scope != nil && !contains(scope, ns) {
+			h.writeError(w, fmt.Errorf("namespace %q is not allowed", ns), http.StatusForbidden)
+			return
+		}
+		r = h.resolveName(r, ns)
+		if sid, ok := mux.Vars(r)["sid"]; ok {
+			if found, ok := h.namespaceOf(sid); ok && found != ns {
+				h.writeSessionError(w, fmt.Errorf("session %s: %w", sid, os.ErrNotExist))
+				return
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), namespaceKey{}, ns)))
+	})
+}
+
+// unscoped restricts "next" to the clients that may use every namespace, as it
+// affects the whole server.
+func (h *SessionHandler) unscoped(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if scopeFromContext(r.Context()) != nil {
+			h.writeError(w, fmt.Errorf("operation not allowed to namespaced clients"), http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// namespaceOf returns the namespace of session "sid", reading its state from the
+// working directory or the store without refreshing it. It reports false if the
+// session is not found.
+func (h *SessionHandler) namespaceOf(sid string) (string, bool) {
+	pw, err := openSession(sid)
+	if err == nil {
+		if s, err := pw.ReadSession(); err == nil {
+			return s.InNamespace(), true
+		}
+	}
+	if h.store != nil {
+		if s, err := h.store.Get(sid); err == nil {
+			return s.InNamespace(), true
+		}
+	}
+	if pw != nil {
+		// Sessions created by older versions do not record their state.
+		return pwrap.DefaultNamespace, true
+	}
+	return "", false
+}
+
+// checkLimit reports an error if namespace "ns" reached the maximum number of
+// sessions that are not finished.
+func (h *SessionHandler) checkLimit(ns string) error {
+	h.settings.RLock()
+	n, ok := h.nsLimits[ns]
+	h.settings.RUnlock()
+	if !ok {
+		return nil
+	}
+	sessions, err := h.listSessions()
+	if err != nil {
+		return err
+	}
+	active := 0
+	for _, v := range sessions {
+		if v.InNamespace() == ns && v.State != pwrap.SessionExited && v.State != pwrap.SessionFailed && v.State != pwrap.SessionTimedOut {
+			active++
+		}
+	}
+	if active >= n {
+		return fmt.Errorf("namespace %q reached its limit of %d sessions", ns, n)
+	}
+	return nil
+}
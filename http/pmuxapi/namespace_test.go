@@ -0,0 +1,148 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/kim-company/pmux/pwrap"
+)
+
+// namespacedSession returns a session of namespace "ns" that is not running,
+// inside the root directory.
+func namespacedSession(t *testing.T, ns string) *pwrap.PWrap {
+	pw, err := pwrap.New(pwrap.RootDir(RootDir()), pwrap.Namespace(ns))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pw.UpdateSession(func(*pwrap.Session) {}); err != nil {
+		os.RemoveAll(pw.WorkDir())
+		t.Fatal(err)
+	}
+	return pw
+}
+
+func TestRouter_Namespaces(t *testing.T) {
+	t.Parallel()
+
+	dir, err := os.MkdirTemp("", "pmux-namespace-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store, err := NewBoltStore(filepath.Join(dir, RegistryFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	pw := namespacedSession(t, "video")
+	defer os.RemoveAll(pw.WorkDir())
+
+	secret := []byte("secret")
+	r := NewRouter("yes", SessionStore(store), APIKeys("admin"), NamespaceAPIKeys("video", "tv"), JWTSecret(secret))
+	path := "/api/v1/sessions/" + pw.SID()
+	for i, tt := range []struct {
+		method string
+		path   string
+		token  string
+		ns     string
+		status int
+	}{
+		{"GET", path, "admin", "", http.StatusNotFound},
+		{"GET", path, "admin", "video", http.StatusOK},
+		{"GET", path, "admin", "Video", http.StatusBadRequest},
+		{"GET", path, "tv", "", http.StatusForbidden},
+		{"GET", path, "tv", "video", http.StatusOK},
+		{"GET", path, signJWT(`{"alg":"HS256"}`, `{"namespaces":["video"]}`, secret), "audio", http.StatusForbidden},
+		{"GET", path, signJWT(`{"alg":"HS256"}`, `{"namespace":"video"}`, secret), "video", http.StatusOK},
+		{"POST", "/api/v1/drain", "tv", "video", http.StatusForbidden},
+	} {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		req.Header.Set("Authorization", "Bearer "+tt.token)
+		if tt.ns != "" {
+			req.Header.Set(NamespaceHeader, tt.ns)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.status {
+			t.Fatalf("%d: %s %s: wanted status %d, found %d", i, tt.method, tt.path, tt.status, w.Code)
+		}
+	}
+}
+
+func TestListOptions_Namespace(t *testing.T) {
+	t.Parallel()
+
+	d := &SessionDetail{Session: pwrap.Session{SID: "pmux-a"}}
+	if o := (&ListOptions{Namespace: pwrap.DefaultNamespace}); !o.Match(d) {
+		t.Fatal("sessions without namespace SHOULD belong to the default one")
+	}
+	if o := (&ListOptions{Namespace: "video"}); o.Match(d) {
+		t.Fatal("sessions of other namespaces SHOULD NOT match")
+	}
+}
+
+func TestSessionHandler_CheckLimit(t *testing.T) {
+	t.Parallel()
+
+	pw := namespacedSession(t, "limit-test")
+	defer os.RemoveAll(pw.WorkDir())
+
+	h := &SessionHandler{nsLimits: map[string]int{"limit-test": 1, "limit-test-free": 1}}
+	if err := h.checkLimit("limit-test"); err == nil {
+		t.Fatal("the limit of the namespace SHOULD be reached")
+	}
+	if err := h.checkLimit("limit-test-free"); err != nil {
+		t.Fatal(err)
+	}
+	if err := pw.UpdateSession(func(s *pwrap.Session) {
+		s.State = pwrap.SessionExited
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.checkLimit("limit-test"); err != nil {
+		t.Fatalf("finished sessions SHOULD NOT count: %v", err)
+	}
+}
+
+func TestSessionHandler_NewSession_Limit(t *testing.T) {
+	t.Parallel()
+
+	h := &SessionHandler{nsLimits: map[string]int{"limit-test-concurrent": 2}}
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var created []*pwrap.PWrap
+	refused := 0
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pw, status, err := h.newSession(context.Background(), &createRequest{}, "sh", nil, "limit-test-concurrent", true)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if status != http.StatusTooManyRequests {
+					t.Errorf("unexpected error: %d %v", status, err)
+				}
+				refused++
+				return
+			}
+			created = append(created, pw)
+		}()
+	}
+	wg.Wait()
+	for _, v := range created {
+		defer v.Trash()
+	}
+	if len(created) != 2 || refused != 6 {
+		t.Fatalf("concurrent creates SHOULD NOT exceed the limit, %d created and %d refused", len(created), refused)
+	}
+}
@@ -0,0 +1,784 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// HandleOpenAPI serves the OpenAPI 3 document describing the API.
+func HandleOpenAPI() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, OpenAPISpec)
+	}
+}
+
+// HandleDocs serves a Swagger UI page rendering the OpenAPI document found at
+// "specURL".
+func HandleDocs(specURL string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprintf(w, docsPage, specURL)
+	}
+}
+
+const docsPage = `<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>pmux API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    window.onload = function() {
+      SwaggerUIBundle({url: %q, dom_id: "#swagger-ui"});
+    };
+  </script>
+</body>
+</html>
+`
+
+// OpenAPISpec is the OpenAPI 3 document describing the API.
+const OpenAPISpec = `{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "pmux",
+    "description": "Runs processes inside tmux sessions, wrapped by pwrap, exposing their state, logs, progress and commands. Requests operate on the sessions of the namespace selected with the X-Pmux-Namespace header, default if missing: sessions of other namespaces are not found. Credentials restricted to some namespaces are refused other namespaces with 403.",
+    "version": "1"
+  },
+  "servers": [{"url": "/api/v1"}],
+  "security": [{"bearer": []}, {"apiKey": []}],
+  "paths": {
+    "/audit": {
+      "get": {
+        "summary": "Query the audit log",
+        "description": "Returns the operations performed through the API, in chronological order.",
+        "parameters": [
+          {"name": "sid", "in": "query", "description": "Session targeted by the operations.", "schema": {"type": "string"}},
+          {"name": "actor", "in": "query", "schema": {"type": "string"}},
+          {"name": "action", "in": "query", "schema": {"type": "string", "enum": ["create", "delete", "bulk_delete", "restart", "resume", "update_config", "command", "drain"]}},
+          {"name": "namespace", "in": "query", "description": "Namespace selected by the operations. Namespaced clients only obtain the operations of their namespace.", "schema": {"type": "string"}},
+          {"name": "since", "in": "query", "schema": {"type": "string", "format": "date-time"}},
+          {"name": "until", "in": "query", "schema": {"type": "string", "format": "date-time"}},
+          {"name": "limit", "in": "query", "description": "Maximum number of operations returned, the most recent ones. 0 for no limit.", "schema": {"type": "integer", "minimum": 0}}
+        ],
+        "responses": {
+          "200": {
+            "description": "The selected operations.",
+            "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/AuditEntry"}}}}
+          },
+          "400": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/drain": {
+      "post": {
+        "summary": "Start draining the server",
+        "description": "New sessions are refused from now on, while the existing ones keep being served. The server shuts down once they are all finished, or when its drain deadline passes.",
+        "responses": {"202": {"description": "The server is draining."}}
+      }
+    },
+    "/reload": {
+      "post": {
+        "summary": "Reload the settings of the server",
+        "description": "Reads again the executables, the maximum number of running sessions, the namespace limits and the retention period from the configuration of the server, the same as sending it SIGHUP. Existing sessions and their streams are not affected. Not available to namespaced clients.",
+        "responses": {
+          "200": {"description": "The settings in effect.", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Settings"}}}},
+          "409": {"description": "The settings cannot be applied without a restart."},
+          "500": {"description": "The configuration cannot be read."},
+          "501": {"description": "The server does not support reloading."}
+        }
+      }
+    },
+    "/sessions": {
+      "get": {
+        "summary": "List sessions",
+        "parameters": [
+          {"$ref": "#/components/parameters/limit"},
+          {"$ref": "#/components/parameters/offset"},
+          {"$ref": "#/components/parameters/state"},
+          {"$ref": "#/components/parameters/label"},
+          {"$ref": "#/components/parameters/olderThan"},
+          {"name": "sort", "in": "query", "schema": {"type": "string", "enum": ["sid", "created_at", "-created_at"], "default": "sid"}}
+        ],
+        "responses": {
+          "200": {
+            "description": "The selected page of sessions.",
+            "headers": {"X-Total-Count": {"description": "Number of sessions matching the filters.", "schema": {"type": "integer"}}},
+            "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/SessionDetail"}}}}
+          },
+          "400": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "post": {
+        "summary": "Create a session",
+        "description": "Sessions requesting start_at or cron are scheduled rather than started, and the schedule is returned. Sessions requesting replicas are created that many times, forming a group which is returned.",
+        "parameters": [{"name": "dry_run", "in": "query", "schema": {"type": "boolean"}, "description": "Check that the session can be started, probing its executable with --help, without creating it."}],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {"schema": {"$ref": "#/components/schemas/CreateRequest"}},
+            "multipart/form-data": {
+              "schema": {
+                "type": "object",
+                "required": ["config"],
+                "properties": {
+                  "request": {"$ref": "#/components/schemas/CreateRequest"},
+                  "config": {"type": "string", "format": "binary", "description": "Configuration stored as is, e.g. a binary file, up to 32 MiB. It is not executed as a template, and the request cannot set config nor be scheduled."}
+                }
+              },
+              "encoding": {"request": {"contentType": "application/json"}}
+            }
+          }
+        },
+        "responses": {
+          "200": {"$ref": "#/components/responses/SID"},
+          "201": {"description": "The replicas were created.", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Group"}}}},
+          "202": {"description": "The session was scheduled.", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Schedule"}}}},
+          "204": {"description": "The dry run succeeded."},
+          "400": {"$ref": "#/components/responses/Error"},
+          "409": {"description": "The name is already used by a session of the namespace.", "content": {"text/plain": {"schema": {"type": "string"}}}},
+          "413": {"$ref": "#/components/responses/Error"},
+          "422": {"description": "The dry run of the session failed.", "content": {"text/plain": {"schema": {"type": "string"}}}},
+          "429": {"$ref": "#/components/responses/Error"},
+          "500": {"$ref": "#/components/responses/Error"},
+          "503": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "delete": {
+        "summary": "Delete many sessions",
+        "description": "Deletes the sessions listed in the body or, if none is listed, those selected by the filters. At least one of the two is required.",
+        "parameters": [
+          {"$ref": "#/components/parameters/state"},
+          {"$ref": "#/components/parameters/label"},
+          {"$ref": "#/components/parameters/olderThan"}
+        ],
+        "requestBody": {
+          "content": {"application/json": {"schema": {"type": "object", "properties": {"sids": {"type": "array", "items": {"type": "string"}, "description": "Identifiers or names of the sessions."}}}}}
+        },
+        "responses": {
+          "200": {"description": "Outcome of the deletion.", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/BulkDeleteResult"}}}},
+          "400": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/sessions/{sid}": {
+      "parameters": [{"$ref": "#/components/parameters/sid"}],
+      "get": {
+        "summary": "Show a session",
+        "responses": {
+          "200": {"description": "The session.", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/SessionDetail"}}}},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "delete": {
+        "summary": "Delete a session",
+        "description": "Sessions that take longer than a few seconds to terminate keep being deleted in the background.",
+        "responses": {
+          "200": {"$ref": "#/components/responses/SID"},
+          "202": {"$ref": "#/components/responses/SID"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/sessions/{sid}/restart": {
+      "parameters": [{"$ref": "#/components/parameters/sid"}],
+      "post": {
+        "summary": "Restart a session keeping its identifier, configuration and working directory",
+        "responses": {
+          "200": {"$ref": "#/components/responses/SID"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/sessions/{sid}/resume": {
+      "parameters": [{"$ref": "#/components/parameters/sid"}],
+      "post": {
+        "summary": "Restart a session whose child continues from the checkpoint it wrote",
+        "description": "The child receives the path of the checkpoint file of the working directory with the --resume flag.",
+        "responses": {
+          "200": {"$ref": "#/components/responses/SID"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "409": {"description": "The child did not write a checkpoint, or the session runs as a Kubernetes Job or on a remote host.", "content": {"text/plain": {"schema": {"type": "string"}}}}
+        }
+      }
+    },
+    "/sessions/{sid}/pause": {
+      "parameters": [{"$ref": "#/components/parameters/sid"}],
+      "post": {
+        "summary": "Pause the child of a running session, until it is resumed",
+        "description": "The session is reported as paused until resumed with the unpause route. Paused sessions still count as running for --max-running.",
+        "parameters": [
+          {"name": "mode", "in": "query", "description": "signal stops the processes of the child with SIGSTOP, or pauses its container, while command delivers the pause command, and later the resume one, to the child.", "schema": {"type": "string", "enum": ["signal", "command"], "default": "signal"}}
+        ],
+        "responses": {
+          "200": {"description": "The status of the paused child.", "content": {"application/json": {"schema": {"type": "object"}}}},
+          "400": {"$ref": "#/components/responses/Error"},
+          "409": {"description": "The child is paused already or is not running.", "content": {"text/plain": {"schema": {"type": "string"}}}},
+          "410": {"$ref": "#/components/responses/Error"},
+          "422": {"description": "The child refused the pause command.", "content": {"text/plain": {"schema": {"type": "string"}}}},
+          "502": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/sessions/{sid}/unpause": {
+      "parameters": [{"$ref": "#/components/parameters/sid"}],
+      "post": {
+        "summary": "Resume the child of a paused session",
+        "description": "The child is resumed the way it was paused. Sessions that are not paused are left alone.",
+        "responses": {
+          "200": {"description": "The status of the resumed child.", "content": {"application/json": {"schema": {"type": "object"}}}},
+          "404": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"},
+          "422": {"description": "The child refused the resume command.", "content": {"text/plain": {"schema": {"type": "string"}}}},
+          "502": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/sessions/{sid}/config": {
+      "parameters": [{"$ref": "#/components/parameters/sid"}],
+      "get": {
+        "summary": "Read the configuration of a session",
+        "responses": {
+          "200": {"description": "The configuration file.", "content": {"application/json": {}, "application/octet-stream": {}}},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "put": {
+        "summary": "Replace the configuration of a session",
+        "description": "The configuration of sessions that did not start yet is stored for their child. That of running sessions is pushed to their wrapper, which replaces the configuration file and delivers the reload command to the child, so that it picks up the new settings without restarting.",
+        "requestBody": {"required": true, "content": {"application/octet-stream": {}}},
+        "responses": {
+          "200": {"$ref": "#/components/responses/SID"},
+          "204": {"description": "The configuration was replaced and reloaded by the running child."},
+          "404": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"},
+          "413": {"$ref": "#/components/responses/Error"},
+          "422": {"description": "The configuration was replaced, but the child refused to reload it.", "content": {"text/plain": {"schema": {"type": "string"}}}},
+          "502": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/sessions/{sid}/logs": {
+      "parameters": [{"$ref": "#/components/parameters/sid"}],
+      "get": {
+        "summary": "Read or follow the output of a session",
+        "description": "Output compressed once the session finished, see the --compress-logs option of the server, is decompressed transparently, and not followed.",
+        "parameters": [
+          {"name": "stream", "in": "query", "schema": {"type": "string", "enum": ["stdout", "stderr"], "default": "stdout"}},
+          {"name": "tail", "in": "query", "description": "Number of trailing lines, -1 for the whole file.", "schema": {"type": "integer", "default": 100}},
+          {"name": "follow", "in": "query", "description": "Keep streaming the data appended to the file.", "schema": {"type": "boolean", "default": false}}
+        ],
+        "responses": {
+          "200": {"description": "The log lines.", "content": {"text/plain": {}}},
+          "400": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/sessions/{sid}/files": {
+      "parameters": [{"$ref": "#/components/parameters/sid"}],
+      "get": {
+        "summary": "List the files of the working directory of a session",
+        "description": "Files are read by the server, so that they remain available once the session is over, until it is deleted. Only working directories on the server's host are available. The session state file is not served, as it carries the credentials of the session.",
+        "responses": {
+          "200": {"description": "The regular files, named relative to the working directory.", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/FileInfo"}}}}},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/sessions/{sid}/files/{name}": {
+      "parameters": [
+        {"$ref": "#/components/parameters/sid"},
+        {"name": "name", "in": "path", "required": true, "description": "Name of the file relative to the working directory, which may contain slashes.", "schema": {"type": "string"}}
+      ],
+      "get": {
+        "summary": "Download a file of the working directory of a session",
+        "description": "Range requests are supported.",
+        "responses": {
+          "200": {"description": "The contents of the file.", "content": {"application/octet-stream": {"schema": {"type": "string", "format": "binary"}}}},
+          "206": {"description": "The requested range of the file."},
+          "400": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/progress": {
+      "get": {
+        "summary": "Stream the progress updates of every running session",
+        "description": "Server-sent events named progress, each carrying an update of a session of the namespace tagged with its identifier. Sessions started later are followed too, while only those whose wrapper runs on the server's host are included. Comments are sent as heartbeats while no update is received.",
+        "parameters": [{"$ref": "#/components/parameters/label"}],
+        "responses": {
+          "200": {
+            "description": "A stream of server-sent events.",
+            "content": {"text/event-stream": {"schema": {"$ref": "#/components/schemas/ProgressEvent"}}}
+          },
+          "400": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/sessions/{sid}/progress": {
+      "parameters": [{"$ref": "#/components/parameters/sid"}],
+      "get": {
+        "summary": "Stream the progress updates of a running session",
+        "parameters": [
+          {"name": "format", "in": "query", "schema": {"type": "string", "enum": ["csv", "json"], "default": "csv"}},
+          {"name": "v", "in": "query", "description": "Version of the progress protocol requested to the child, the latest one if missing.", "schema": {"type": "integer", "minimum": 1, "default": 2}}
+        ],
+        "responses": {
+          "200": {
+            "description": "A stream of progress updates, one per line. The v parameter of the content type, e.g. application/x-ndjson; v=2, is the version of the progress protocol spoken by the child: 1 for children predating the negotiation.",
+            "content": {"text/csv": {}, "application/x-ndjson": {"schema": {"$ref": "#/components/schemas/ProgressUpdate"}}}
+          },
+          "400": {"$ref": "#/components/responses/Error"},
+          "410": {"$ref": "#/components/responses/Error"},
+          "502": {"$ref": "#/components/responses/Error"},
+          "503": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/sessions/{sid}/progress/history": {
+      "parameters": [{"$ref": "#/components/parameters/sid"}],
+      "get": {
+        "summary": "Read or follow the progress updates recorded for a session, also after it is over",
+        "parameters": [
+          {"name": "tail", "in": "query", "description": "Number of trailing updates, -1 for the whole history.", "schema": {"type": "integer", "default": 100}},
+          {"name": "follow", "in": "query", "description": "Keep streaming the updates as they are recorded.", "schema": {"type": "boolean", "default": false}}
+        ],
+        "responses": {
+          "200": {
+            "description": "The recorded updates, one per line, together with the time they were received.",
+            "content": {"application/x-ndjson": {"schema": {"allOf": [{"$ref": "#/components/schemas/ProgressUpdate"}, {"type": "object", "properties": {"time": {"type": "string", "format": "date-time"}}}]}}}
+          },
+          "400": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/sessions/{sid}/command": {
+      "parameters": [{"$ref": "#/components/parameters/sid"}],
+      "post": {
+        "summary": "Deliver a command to a running session",
+        "description": "The command is written to the child as a JSON line. Its operation has to be allowed by the server, see --command-op: pause, resume, cancel and reload by default.",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Command"}}}},
+        "responses": {
+          "200": {"description": "The command was accepted.", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/CommandResponse"}}}},
+          "202": {"description": "The command was delivered, the child did not respond."},
+          "400": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "422": {"description": "The command was rejected.", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/CommandResponse"}}}},
+          "502": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/sessions/{sid}/stdin": {
+      "parameters": [{"$ref": "#/components/parameters/sid"}],
+      "post": {
+        "summary": "Stream data into the stdin of a running session",
+        "description": "The body is written to the stdin of the child while it is received. Requests are served one at a time.",
+        "parameters": [{"name": "close", "in": "query", "schema": {"type": "boolean"}, "description": "Close the stdin of the child once the body is written."}],
+        "requestBody": {"required": true, "content": {"application/octet-stream": {"schema": {"type": "string", "format": "binary"}}}},
+        "responses": {
+          "200": {"description": "The data was written.", "content": {"application/json": {"schema": {"type": "object", "properties": {"bytes": {"type": "integer"}}}}}},
+          "409": {"description": "The session was not created with its stdin enabled.", "content": {"text/plain": {"schema": {"type": "string"}}}},
+          "410": {"description": "The stdin of the child is closed.", "content": {"text/plain": {"schema": {"type": "string"}}}},
+          "502": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/sessions/{sid}/terminal": {
+      "parameters": [{"$ref": "#/components/parameters/sid"}],
+      "get": {
+        "summary": "Attach to the tmux session of a running session over a WebSocket",
+        "description": "Speaks the pmux.terminal subprotocol: binary messages carry the input and the output of the terminal, text messages hold a JSON object with cols and rows resizing it. Browsers may present their credentials as a bearer.<token> subprotocol and select the namespace with the namespace query parameter.",
+        "parameters": [
+          {"name": "cols", "in": "query", "schema": {"type": "integer", "default": 80}},
+          {"name": "rows", "in": "query", "schema": {"type": "integer", "default": 24}},
+          {"name": "readonly", "in": "query", "schema": {"type": "boolean"}, "description": "Ignore the input of the client."}
+        ],
+        "responses": {
+          "101": {"description": "The connection was upgraded to a WebSocket."},
+          "409": {"description": "The session does not run inside tmux, e.g. because it is detached.", "content": {"text/plain": {"schema": {"type": "string"}}}},
+          "410": {"description": "The session is not running anymore.", "content": {"text/plain": {"schema": {"type": "string"}}}},
+          "426": {"description": "The request is not a WebSocket upgrade.", "content": {"text/plain": {"schema": {"type": "string"}}}}
+        }
+      }
+    },
+    "/sessions/{sid}/screen": {
+      "parameters": [{"$ref": "#/components/parameters/sid"}],
+      "get": {
+        "summary": "Capture the text displayed by the tmux pane of a running session",
+        "parameters": [
+          {"name": "history", "in": "query", "schema": {"type": "integer", "default": 0, "maximum": 10000}, "description": "Lines of scrollback preceding the screen."},
+          {"name": "escapes", "in": "query", "schema": {"type": "boolean"}, "description": "Keep colours and attributes as escape sequences."}
+        ],
+        "responses": {
+          "200": {"description": "The content of the pane.", "content": {"text/plain": {"schema": {"type": "string"}}}},
+          "400": {"$ref": "#/components/responses/Error"},
+          "409": {"description": "The session does not run inside tmux, e.g. because it is detached.", "content": {"text/plain": {"schema": {"type": "string"}}}},
+          "410": {"description": "The session is not running anymore.", "content": {"text/plain": {"schema": {"type": "string"}}}},
+          "501": {"description": "The escape sequences cannot be kept by the installed tmux.", "content": {"text/plain": {"schema": {"type": "string"}}}}
+        }
+      }
+    },
+    "/schedules": {
+      "get": {
+        "summary": "List the sessions scheduled for later",
+        "responses": {
+          "200": {"description": "The schedules of the namespace.", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Schedule"}}}}}
+        }
+      }
+    },
+    "/schedules/{id}": {
+      "parameters": [{"name": "id", "in": "path", "required": true, "schema": {"type": "string"}}],
+      "get": {
+        "summary": "Show a schedule",
+        "responses": {
+          "200": {"description": "The schedule.", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Schedule"}}}},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "delete": {
+        "summary": "Cancel the future runs of a schedule, leaving the sessions already created",
+        "responses": {
+          "200": {"description": "The deleted schedule.", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Schedule"}}}},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/pipelines": {
+      "get": {
+        "summary": "List pipelines",
+        "responses": {
+          "200": {"description": "The pipelines of the namespace.", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Pipeline"}}}}}
+        }
+      },
+      "post": {
+        "summary": "Create a pipeline",
+        "description": "Steps start once the steps they depend on exited successfully, and are skipped if one of them did not.",
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"type": "object", "required": ["steps"], "properties": {"steps": {"type": "array", "items": {"$ref": "#/components/schemas/PipelineStep"}}}}}}
+        },
+        "responses": {
+          "200": {"description": "The pipeline created.", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Pipeline"}}}},
+          "400": {"$ref": "#/components/responses/Error"},
+          "503": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/pipelines/{id}": {
+      "parameters": [{"name": "id", "in": "path", "required": true, "schema": {"type": "string"}}],
+      "get": {
+        "summary": "Show the combined status of a pipeline and of its steps",
+        "responses": {
+          "200": {"description": "The pipeline.", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Pipeline"}}}},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "delete": {
+        "summary": "Delete a pipeline together with the sessions of its steps",
+        "responses": {
+          "200": {"description": "The deleted pipeline.", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Pipeline"}}}},
+          "404": {"$ref": "#/components/responses/Error"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/groups/{id}": {
+      "parameters": [{"name": "id", "in": "path", "required": true, "schema": {"type": "string"}}],
+      "get": {
+        "summary": "Show the combined status of a group and of its replicas",
+        "responses": {
+          "200": {"description": "The group.", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Group"}}}},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "delete": {
+        "summary": "Delete the replicas of a group",
+        "responses": {
+          "200": {"description": "The sessions deleted.", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/BulkDeleteResult"}}}},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    }
+  },
+  "components": {
+    "securitySchemes": {
+      "bearer": {"type": "http", "scheme": "bearer", "description": "API key or HS256 JSON Web Token."},
+      "apiKey": {"type": "apiKey", "in": "header", "name": "X-API-Key"}
+    },
+    "parameters": {
+      "sid": {"name": "sid", "in": "path", "required": true, "description": "Identifier of the session, or its name.", "schema": {"type": "string"}},
+      "limit": {"name": "limit", "in": "query", "description": "Maximum number of sessions returned, 0 for no limit.", "schema": {"type": "integer", "minimum": 0}},
+      "offset": {"name": "offset", "in": "query", "schema": {"type": "integer", "minimum": 0}},
+      "state": {"name": "state", "in": "query", "description": "Can be repeated. \"finished\" matches exited, failed and timed out sessions.", "schema": {"$ref": "#/components/schemas/State"}},
+      "label": {"name": "label", "in": "query", "description": "Label in the key=value form. Can be repeated.", "schema": {"type": "string"}},
+      "olderThan": {"name": "older_than", "in": "query", "description": "Minimum age of the sessions, e.g. 24h.", "schema": {"type": "string"}}
+    },
+    "responses": {
+      "SID": {
+        "description": "The session identifier.",
+        "content": {"application/json": {"schema": {"type": "object", "properties": {"sid": {"type": "string"}}}}}
+      },
+      "Error": {"description": "The error that occurred.", "content": {"text/plain": {"schema": {"type": "string"}}}}
+    },
+    "schemas": {
+      "AuditEntry": {
+        "type": "object",
+        "properties": {
+          "time": {"type": "string", "format": "date-time"},
+          "actor": {"type": "string", "description": "Subject of the JSON Web Token, fingerprint of the API key or anonymous."},
+          "action": {"type": "string"},
+          "namespace": {"type": "string"},
+          "sids": {"type": "array", "items": {"type": "string"}},
+          "method": {"type": "string"},
+          "path": {"type": "string"},
+          "query": {"type": "string"},
+          "remote_addr": {"type": "string"},
+          "forwarded_for": {"type": "string"},
+          "user_agent": {"type": "string"},
+          "status": {"type": "integer"},
+          "detail": {"type": "string", "description": "Body of command requests."}
+        }
+      },
+      "State": {"type": "string", "enum": ["created", "queued", "running", "paused", "exited", "failed", "timed_out", "finished"]},
+      "CreateRequest": {
+        "type": "object",
+        "properties": {
+          "register_url": {"type": "string", "description": "URL receiving the registration and the final callback of the wrapper."},
+          "exec": {"type": "string", "description": "Name of the executable to run, chosen among those allowed by the server."},
+          "config": {"description": "Configuration handed to the executable. When the server enables configuration templates, strings containing {{ are executed as Go templates with .SID, .WorkDir, .Namespace, .Labels and .Vars."},
+          "container": {"$ref": "#/components/schemas/Container"},
+          "kubernetes": {"$ref": "#/components/schemas/Kubernetes"},
+          "host": {"type": "string", "description": "Remote host the session is placed on, chosen among those allowed by the server."},
+          "disk_quota": {"$ref": "#/components/schemas/Quota"},
+          "retention": {"type": "string", "description": "Time the session is kept for once finished, e.g. 72h, overriding the server's retention."},
+          "max_runtime": {"type": "string", "description": "Time the child is allowed to run for, e.g. 2h, paused time included. Once exceeded, the child is stopped like when the session is deleted, and the session is recorded as timed_out. Not limited if empty."},
+          "labels": {"type": "object", "additionalProperties": {"type": "string"}, "description": "Labels selecting the session with the label parameter of the list and bulk delete operations. Keys cannot contain =."},
+          "name": {"type": "string", "maxLength": 63, "pattern": "^[a-z]([a-z0-9-]*[a-z0-9])?$", "description": "Name of the session, unique within its namespace, accepted in place of its identifier. It cannot start with pmux-. Replicas are named after their index, e.g. name-0, while recurring schedules cannot be named."},
+          "priority": {"type": "integer", "description": "Priority of the session when queued because the server's concurrency limit is reached, higher first. Defaults to zero."},
+          "secrets": {"type": "object", "additionalProperties": {"type": "string"}, "description": "Environment variables of the child set to secrets, referenced as env:NAME, file:/path or vault:path#key, which have to start with a prefix allowed by the server. The wrapper resolves the references when the child starts; their values are never stored."},
+          "artifacts": {"type": "array", "items": {"type": "string"}, "description": "Glob patterns, relative to the working directory, selecting the files uploaded to the server's artifacts destination once the child exits, below the session identifier. Their URLs are sent with the final callback."},
+          "stdin": {"type": "boolean", "description": "Connect the stdin of the child to a pipe fed through the stdin route. The child reads an empty input otherwise."},
+          "sidecars": {"type": "array", "items": {"type": "string"}, "description": "Names of the sidecars allowed by the server that are started alongside the child."},
+          "register_headers": {"type": "object", "additionalProperties": {"type": "string"}, "description": "HTTP headers attached to the registration and callback requests, e.g. credentials. They are never shown back."},
+          "env": {"type": "object", "additionalProperties": {"type": "string"}, "description": "Variables added to the environment of the child. Names starting with PMUX_, TRACEPARENT and the names of secrets are rejected."},
+          "extra_args": {"type": "array", "items": {"type": "string"}, "description": "Arguments appended to those of the executable, which may use the same templates. The flags passed by the wrapper, --config, --socket-path, --socket-transport and --resume, are rejected."},
+          "replicas": {"type": "integer", "minimum": 1, "maximum": 100, "description": "Number of identical sessions created, forming a group. Either all of them are created or none. Cannot be scheduled."},
+          "start_at": {"type": "string", "format": "date-time", "description": "Time the session is created at, once. Cannot be combined with cron."},
+          "cron": {"type": "string", "description": "Five fields cron expression, evaluated in the server's time zone, creating a session every time it fires. Runs missed while the server is down are skipped."}
+        }
+      },
+      "Quota": {
+        "type": "object",
+        "description": "Disk quota of the working directory. Sessions may lower the server's quota, but not relax it.",
+        "required": ["bytes"],
+        "properties": {
+          "bytes": {"type": "integer", "format": "int64", "minimum": 1},
+          "action": {"type": "string", "enum": ["warn", "stop"], "default": "warn", "description": "warn reports the excess as a progress update, stop terminates the child and fails the session."}
+        }
+      },
+      "Remote": {
+        "type": "object",
+        "description": "Remote host a session is placed on, reached over SSH.",
+        "properties": {
+          "host": {"type": "string"},
+          "root": {"type": "string", "description": "Directory containing the working directories on the remote host."},
+          "pmux": {"type": "string"}
+        }
+      },
+      "Kubernetes": {
+        "type": "object",
+        "description": "Kubernetes Job the session runs as. The namespace, service account and pmux path come from the server.",
+        "properties": {
+          "namespace": {"type": "string", "readOnly": true},
+          "image": {"type": "string", "description": "Image providing pmux and the executable, defaults to the server's."},
+          "pmux": {"type": "string", "readOnly": true},
+          "service_account": {"type": "string", "readOnly": true},
+          "cpu": {"type": "string", "description": "CPU limit of the pod, e.g. 500m."},
+          "memory": {"type": "string", "description": "Memory limit of the pod, e.g. 1Gi."}
+        }
+      },
+      "Container": {
+        "type": "object",
+        "description": "Docker container the executable runs in, its image and mount sources must be allowed by the server.",
+        "required": ["image"],
+        "properties": {
+          "image": {"type": "string"},
+          "mounts": {"type": "array", "items": {"type": "string"}, "description": "Bind mounts in the source:destination[:options] form."},
+          "cpus": {"type": "string", "description": "Number of CPUs available to the container, e.g. 1.5."},
+          "memory": {"type": "string", "description": "Memory limit of the container, e.g. 512m."},
+          "network": {"type": "string", "description": "Network the container is attached to, defaults to host."}
+        }
+      },
+      "ProgressUpdate": {
+        "type": "object",
+        "properties": {
+          "description": {"type": "string"},
+          "stage": {"type": "integer"},
+          "stages": {"type": "integer"},
+          "partial": {"type": "integer"},
+          "total": {"type": "integer"},
+          "stage_name": {"type": "string"},
+          "unit": {"type": "string"},
+          "eta": {"type": "string", "format": "date-time"},
+          "labels": {"type": "object", "additionalProperties": {"type": "string"}}
+        }
+      },
+      "FileInfo": {
+        "type": "object",
+        "properties": {
+          "name": {"type": "string"},
+          "size": {"type": "integer", "format": "int64"},
+          "mod_time": {"type": "string", "format": "date-time"}
+        }
+      },
+      "ProgressEvent": {
+        "description": "Progress update of a session, with the time it was received by its wrapper.",
+        "allOf": [
+          {"type": "object", "properties": {"sid": {"type": "string"}, "time": {"type": "string", "format": "date-time"}}},
+          {"$ref": "#/components/schemas/ProgressUpdate"}
+        ]
+      },
+      "SessionDetail": {
+        "type": "object",
+        "properties": {
+          "sid": {"type": "string"},
+          "exec": {"type": "string"},
+          "args": {"type": "array", "items": {"type": "string"}},
+          "state": {"$ref": "#/components/schemas/State"},
+          "labels": {"type": "object", "additionalProperties": {"type": "string"}},
+          "namespace": {"type": "string", "description": "Namespace the session belongs to, omitted for the default one."},
+          "name": {"type": "string"},
+          "priority": {"type": "integer"},
+          "register_url": {"type": "string"},
+          "restarts": {"type": "integer"},
+          "created_at": {"type": "string", "format": "date-time"},
+          "started_at": {"type": "string", "format": "date-time"},
+          "finished_at": {"type": "string", "format": "date-time"},
+          "pid": {"type": "integer"},
+          "exit_code": {"type": "integer", "description": "Exit code of the child, -1 if it was terminated by a signal."},
+          "signal": {"type": "string", "description": "Name of the signal that terminated the child, e.g. SIGKILL."},
+          "oom_killed": {"type": "boolean", "description": "Whether the child was likely killed by the OOM killer, rather than crashing."},
+          "resumed": {"type": "boolean", "description": "Whether the child was told to continue from its checkpoint."},
+          "raw_config": {"type": "boolean", "description": "Whether the configuration was uploaded as is rather than encoded as JSON."},
+          "error": {"type": "string"},
+          "last_progress": {"$ref": "#/components/schemas/ProgressUpdate"},
+          "last_progress_at": {"type": "string", "format": "date-time"},
+          "paused_at": {"type": "string", "format": "date-time", "description": "Time the child was paused at, while paused."},
+          "port": {"type": "integer", "description": "Port of the wrapper API."},
+          "container": {"$ref": "#/components/schemas/Container"},
+          "kubernetes": {"$ref": "#/components/schemas/Kubernetes"},
+          "remote": {"$ref": "#/components/schemas/Remote"},
+          "disk_quota": {"$ref": "#/components/schemas/Quota"},
+          "retention": {"type": "string"},
+          "max_runtime": {"type": "string"},
+          "secrets": {"type": "object", "additionalProperties": {"type": "string"}, "description": "References of the secrets injected into the environment of the child."},
+          "env": {"type": "object", "additionalProperties": {"type": "string"}, "description": "Variables added to the environment of the child."},
+          "artifacts": {"type": "object", "properties": {"paths": {"type": "array", "items": {"type": "string"}}, "destination": {"type": "string"}}},
+          "artifact_urls": {"type": "array", "items": {"type": "string"}, "description": "URLs of the artifacts uploaded once the child exited."},
+          "stdin": {"type": "boolean", "description": "Whether data can be streamed into the stdin of the child."},
+          "sidecars": {"type": "array", "items": {"type": "object", "properties": {"name": {"type": "string"}, "path": {"type": "string"}, "args": {"type": "array", "items": {"type": "string"}}}}, "description": "Commands running alongside the child."},
+          "tmux": {"type": "boolean", "description": "Whether the tmux session is present."},
+          "workdir": {"type": "string"},
+          "child_pid": {"type": "integer", "description": "PID of the child found in the process tree of the wrapper, only returned when showing a running session whose wrapper runs on the server's host."}
+        }
+      },
+      "Schedule": {
+        "type": "object",
+        "description": "Sessions created by a schedule carry the pmux.schedule label, set to its identifier.",
+        "properties": {
+          "id": {"type": "string"},
+          "namespace": {"type": "string"},
+          "start_at": {"type": "string", "format": "date-time"},
+          "cron": {"type": "string"},
+          "request": {"$ref": "#/components/schemas/CreateRequest"},
+          "created_at": {"type": "string", "format": "date-time"},
+          "next_run": {"type": "string", "format": "date-time"},
+          "last_run": {"type": "string", "format": "date-time"},
+          "runs": {"type": "integer"},
+          "last_sid": {"type": "string"},
+          "last_error": {"type": "string"}
+        }
+      },
+      "Pipeline": {
+        "type": "object",
+        "description": "Sessions created by a pipeline carry the pmux.pipeline and pmux.step labels, set to its identifier and to the name of their step.",
+        "properties": {
+          "id": {"type": "string"},
+          "namespace": {"type": "string"},
+          "state": {"type": "string", "enum": ["running", "succeeded", "failed"]},
+          "created_at": {"type": "string", "format": "date-time"},
+          "finished_at": {"type": "string", "format": "date-time"},
+          "steps": {"type": "array", "description": "Sorted so that every step follows its dependencies.", "items": {"$ref": "#/components/schemas/PipelineStep"}}
+        }
+      },
+      "Settings": {
+        "type": "object",
+        "properties": {
+          "execs": {"type": "array", "description": "Names of the executables sessions may select.", "items": {"type": "string"}},
+          "max_running": {"type": "integer", "description": "Zero means no limit."},
+          "namespace_limits": {"type": "object", "additionalProperties": {"type": "integer"}},
+          "retention": {"type": "string", "description": "Empty if finished sessions are kept forever."}
+        }
+      },
+      "Group": {
+        "type": "object",
+        "description": "Replicas carry the pmux.group and pmux.replica labels, set to the identifier of the group and to their index, starting from 0. Groups exist as long as their replicas do.",
+        "properties": {
+          "id": {"type": "string"},
+          "state": {"type": "string", "enum": ["running", "succeeded", "failed"]},
+          "states": {"type": "object", "additionalProperties": {"type": "integer"}, "description": "Number of replicas in each state."},
+          "sessions": {"type": "array", "description": "Sorted by index.", "items": {"$ref": "#/components/schemas/SessionDetail"}}
+        }
+      },
+      "PipelineStep": {
+        "type": "object",
+        "required": ["name", "session"],
+        "properties": {
+          "name": {"type": "string"},
+          "depends_on": {"type": "array", "items": {"type": "string"}, "description": "Names of the steps that have to exit successfully before this one starts."},
+          "session": {"$ref": "#/components/schemas/CreateRequest"},
+          "sid": {"type": "string", "readOnly": true},
+          "state": {"type": "string", "readOnly": true, "description": "pending, skipped or the state of the session of the step."},
+          "error": {"type": "string", "readOnly": true}
+        }
+      },
+      "BulkDeleteResult": {
+        "type": "object",
+        "properties": {
+          "deleted": {"type": "array", "items": {"type": "string"}},
+          "pending": {"type": "array", "items": {"type": "string"}, "description": "Sessions still being deleted in the background."},
+          "errors": {"type": "object", "additionalProperties": {"type": "string"}}
+        }
+      },
+      "Command": {
+        "type": "object",
+        "required": ["op"],
+        "properties": {
+          "op": {"type": "string", "example": "cancel"},
+          "args": {"type": "object", "description": "Arguments of the operation, passed to the child as they are."}
+        },
+        "additionalProperties": false
+      },
+      "CommandResponse": {
+        "type": "object",
+        "properties": {
+          "ok": {"type": "boolean"},
+          "response": {"type": "string"},
+          "error": {"type": "string"}
+        }
+      }
+    }
+  }
+}
+`
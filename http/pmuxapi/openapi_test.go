@@ -0,0 +1,51 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+var pathVarRe = regexp.MustCompile(`\{(\w+):[^}]+\}`)
+
+func TestOpenAPISpec(t *testing.T) {
+	t.Parallel()
+
+	var spec struct {
+		Paths map[string]map[string]interface{} `json:"paths"`
+	}
+	if err := json.Unmarshal([]byte(OpenAPISpec), &spec); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every session route has to be documented.
+	r := NewRouter("yes")
+	err := r.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil || !strings.HasPrefix(path, "/api/v1/") {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		// Patterns of path variables are not part of the spec.
+		path = pathVarRe.ReplaceAllString(strings.TrimPrefix(path, "/api/v1"), "{$1}")
+		for _, m := range methods {
+			if _, ok := spec.Paths[path][strings.ToLower(m)]; !ok {
+				t.Errorf("Route %s %s is not documented", m, path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
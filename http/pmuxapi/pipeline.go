@@ -0,0 +1,514 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/kim-company/pmux/pwrap"
+)
+
+// PipelinesFile is the name of the file, inside the root directory, where the
+// pipelines are persisted.
+const PipelinesFile = "pipelines.json"
+
+// Labels attached to the sessions created by a pipeline, set to its identifier
+// and to the name of the step.
+const (
+	PipelineLabel = "pmux.pipeline"
+	StepLabel     = "pmux.step"
+)
+
+// pipelineInterval is the time between two checks of the steps of the running
+// pipelines, as sessions exit without notifying the server.
+const pipelineInterval = time.Second * 2
+
+// PipelineState is the state of a pipeline.
+type PipelineState string
+
+const (
+	PipelineRunning PipelineState = "running"
+	// PipelineSucceeded pipelines had every step exit successfully.
+	PipelineSucceeded = "succeeded"
+	// PipelineFailed pipelines are finished, with at least one step that
+	// failed or was skipped.
+	PipelineFailed = "failed"
+)
+
+// States of the steps whose session was not created.
+const (
+	// StepPending steps wait for their dependencies to succeed.
+	StepPending = "pending"
+	// StepSkipped steps will never run, as one of their dependencies
+	// did not succeed.
+	StepSkipped = "skipped"
+)
+
+// Pipeline is a group of sessions, its steps, that start once the steps they
+// depend on exited successfully.
+type Pipeline struct {
+	ID         string        `json:"id"`
+	Namespace  string        `json:"namespace,omitempty"`
+	State      PipelineState `json:"state"`
+	CreatedAt  time.Time     `json:"created_at"`
+	FinishedAt *time.Time    `json:"finished_at,omitempty"`
+	// Steps are sorted so that every step follows its dependencies.
+	Steps []*PipelineStep `json:"steps"`
+}
+
+// PipelineStep is a session of a pipeline.
+type PipelineStep struct {
+	Name      string   `json:"name"`
+	DependsOn []string `json:"depends_on,omitempty"`
+	// Session is the create payload of the session.
+	Session json.RawMessage `json:"session"`
+	SID     string          `json:"sid,omitempty"`
+	// State is either "StepPending", "StepSkipped" or the state of the
+	// session of the step once created.
+	State string `json:"state"`
+	Error string `json:"error,omitempty"`
+}
+
+// finished reports whether the step will not change anymore.
+func (s *PipelineStep) finished() bool {
+	switch s.State {
+	case StepSkipped, pwrap.SessionExited, pwrap.SessionFailed, pwrap.SessionTimedOut:
+		return true
+	}
+	return false
+}
+
+func (p *Pipeline) copy() *Pipeline {
+	c := *p
+	c.Steps = make([]*PipelineStep, len(p.Steps))
+	for i, v := range p.Steps {
+		s := *v
+		c.Steps[i] = &s
+	}
+	return &c
+}
+
+// sortSteps sorts "steps" so that every step follows its dependencies, keeping
+// the order given otherwise. It reports an error if the names of the steps are
+// not unique, if a dependency does not exist or if the dependencies are cyclic.
+func sortSteps(steps []*PipelineStep) ([]*PipelineStep, error) {
+	byName := make(map[string]*PipelineStep, len(steps))
+	for _, v := range steps {
+		if v.Name == "" {
+			return nil, fmt.Errorf("steps must have a name")
+		}
+		if byName[v.Name] != nil {
+			return nil, fmt.Errorf("step %q is defined more than once", v.Name)
+		}
+		byName[v.Name] = v
+	}
+	sorted := make([]*PipelineStep, 0, len(steps))
+	// visiting holds the steps whose dependencies are being sorted.
+	visiting, done := map[string]bool{}, map[string]bool{}
+	var visit func(s *PipelineStep) error
+	visit = func(s *PipelineStep) error {
+		if done[s.Name] {
+			return nil
+		}
+		if visiting[s.Name] {
+			return fmt.Errorf("step %q depends on itself", s.Name)
+		}
+		visiting[s.Name] = true
+		for _, v := range s.DependsOn {
+			dep, ok := byName[v]
+			if !ok {
+				return fmt.Errorf("step %q depends on the unknown step %q", s.Name, v)
+			}
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		done[s.Name] = true
+		sorted = append(sorted, s)
+		return nil
+	}
+	for _, v := range steps {
+		if err := visit(v); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
+
+// pipelines keeps the pipelines, persisting them in a JSON file which is
+// rewritten atomically on every change.
+type pipelines struct {
+	path string
+	// run is held while the steps of a pipeline are advanced or deleted.
+	run  sync.Mutex
+	wake chan struct{}
+
+	sync.Mutex
+	items map[string]*Pipeline
+}
+
+// loadPipelines returns the pipelines backed by the file at "path", loading
+// those it contains, if any.
+func loadPipelines(path string) (*pipelines, error) {
+	p := &pipelines{path: path, items: map[string]*Pipeline{}, wake: make(chan struct{}, 1)}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("unable to read pipelines: %w", err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &p.items); err != nil {
+			return nil, fmt.Errorf("unable to decode pipelines: %w", err)
+		}
+	}
+	return p, nil
+}
+
+func (p *pipelines) notify() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// put stores "pl", replacing the pipeline with the same identifier.
+func (p *pipelines) put(pl *Pipeline) error {
+	p.Lock()
+	defer p.Unlock()
+	p.items[pl.ID] = pl.copy()
+	return p.flush()
+}
+
+func (p *pipelines) get(id string) (*Pipeline, error) {
+	p.Lock()
+	defer p.Unlock()
+	pl, ok := p.items[id]
+	if !ok {
+		return nil, fmt.Errorf("pipeline %s: %w", id, os.ErrNotExist)
+	}
+	return pl.copy(), nil
+}
+
+// list returns the pipelines of namespace "ns", sorted by creation time. All of
+// them are returned if "ns" is empty.
+func (p *pipelines) list(ns string) []*Pipeline {
+	p.Lock()
+	defer p.Unlock()
+	acc := []*Pipeline{}
+	for _, v := range p.items {
+		if ns == "" || v.Namespace == ns {
+			acc = append(acc, v.copy())
+		}
+	}
+	sort.Slice(acc, func(i, j int) bool { return acc[i].CreatedAt.Before(acc[j].CreatedAt) })
+	return acc
+}
+
+func (p *pipelines) remove(id string) error {
+	p.Lock()
+	defer p.Unlock()
+	delete(p.items, id)
+	return p.flush()
+}
+
+// flush writes the pipelines to disk. Must be called with the lock held.
+func (p *pipelines) flush() error {
+	if err := os.MkdirAll(filepath.Dir(p.path), os.ModePerm); err != nil {
+		return fmt.Errorf("unable to store pipelines: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p.path), "."+filepath.Base(p.path)+"-*")
+	if err != nil {
+		return fmt.Errorf("unable to store pipelines: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := json.NewEncoder(tmp).Encode(p.items); err != nil {
+		tmp.Close()
+		return fmt.Errorf("unable to encode pipelines: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("unable to store pipelines: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), p.path); err != nil {
+		return fmt.Errorf("unable to store pipelines: %w", err)
+	}
+	return nil
+}
+
+// advancePipeline refreshes the state of the steps of "p" from their sessions,
+// then creates the sessions of the pending steps whose dependencies exited
+// successfully, and skips those having a dependency that did not. "name" and
+// "args" are the executable run by default. Steps are left pending while the
+// server is draining, or while their namespace reached its limit.
+func (h *SessionHandler) advancePipeline(p *Pipeline, name string, args []string) {
+	steps := make(map[string]*PipelineStep, len(p.Steps))
+	for _, v := range p.Steps {
+		steps[v.Name] = v
+		if v.SID == "" || v.finished() {
+			continue
+		}
+		s, _, err := h.readSession(v.SID)
+		if err != nil {
+			v.State, v.Error = pwrap.SessionFailed, err.Error()
+			continue
+		}
+		v.State, v.Error = string(s.State), s.Error
+	}
+
+	finished := true
+	for _, v := range p.Steps {
+		if v.State == StepPending {
+			h.startStep(p, v, steps, name, args)
+		}
+		finished = finished && v.finished()
+	}
+	if !finished {
+		return
+	}
+	p.State = PipelineSucceeded
+	for _, v := range p.Steps {
+		if v.State != pwrap.SessionExited {
+			p.State = PipelineFailed
+		}
+	}
+	now := time.Now()
+	p.FinishedAt = &now
+	log.Printf("[INFO] Pipeline %s %s", p.ID, p.State)
+}
+
+// startStep creates the session of step "s" of pipeline "p" if its dependencies,
+// found in "steps", exited successfully.
+func (h *SessionHandler) startStep(p *Pipeline, s *PipelineStep, steps map[string]*PipelineStep, name string, args []string) {
+	for _, v := range s.DependsOn {
+		switch dep := steps[v]; {
+		case dep.State == pwrap.SessionExited:
+		case dep.finished():
+			s.State, s.Error = StepSkipped, fmt.Sprintf("dependency %q did not succeed", v)
+			return
+		default:
+			return
+		}
+	}
+	if h.drain.draining() {
+		return
+	}
+	var c createRequest
+	if err := json.Unmarshal(s.Session, &c); err != nil {
+		s.State, s.Error = pwrap.SessionFailed, err.Error()
+		return
+	}
+	if c.Labels == nil {
+		c.Labels = map[string]string{}
+	}
+	c.Labels[PipelineLabel], c.Labels[StepLabel] = p.ID, s.Name
+	pw, status, err := h.createSession(context.Background(), &c, name, args, p.Namespace)
+	if status == http.StatusTooManyRequests {
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] pipeline %s: unable to create the session of step %q: %v", p.ID, s.Name, err)
+		s.State, s.Error = pwrap.SessionFailed, err.Error()
+		return
+	}
+	log.Printf("[INFO] Session %s created for step %q of pipeline %s", pw.SID(), s.Name, p.ID)
+	h.record(pw)
+	st, _ := pw.ReadSession()
+	h.notify(pwrap.EventCreated, pw.SID(), st)
+	s.SID, s.State = pw.SID(), string(pwrap.SessionCreated)
+	if st != nil {
+		s.State = string(st.State)
+	}
+}
+
+// advancePipelines advances the running pipelines.
+func (h *SessionHandler) advancePipelines(name string, args []string) {
+	h.pipelines.run.Lock()
+	defer h.pipelines.run.Unlock()
+	for _, v := range h.pipelines.list("") {
+		if v.State != PipelineRunning {
+			continue
+		}
+		h.advancePipeline(v, name, args)
+		if err := h.pipelines.put(v); err != nil {
+			log.Printf("[WARN] %v", err)
+		}
+	}
+}
+
+// runPipelines advances the running pipelines every "interval", or as soon as
+// one is created, forever.
+func (h *SessionHandler) runPipelines(interval time.Duration, name string, args []string) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		h.advancePipelines(name, args)
+		select {
+		case <-h.pipelines.wake:
+		case <-t.C:
+		}
+	}
+}
+
+// HandleCreatePipeline creates a pipeline, whose steps are started by the server
+// as their dependencies succeed.
+func (h *SessionHandler) HandleCreatePipeline(name string, args ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+		if h.pipelines == nil {
+			h.writeError(w, fmt.Errorf("pipelines are not available"), http.StatusInternalServerError)
+			return
+		}
+		if h.drain.draining() {
+			h.writeError(w, fmt.Errorf("server is draining, new sessions are not accepted"), http.StatusServiceUnavailable)
+			return
+		}
+		var req struct {
+			Steps []*PipelineStep `json:"steps"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.writeError(w, fmt.Errorf("unable to decode pipeline payload body: %w", err), http.StatusBadRequest)
+			return
+		}
+		p, err := h.newPipeline(req.Steps, name, args)
+		if err != nil {
+			h.writeError(w, err, http.StatusBadRequest)
+			return
+		}
+		p.Namespace = NamespaceFromContext(r.Context())
+		if err := h.pipelines.put(p); err != nil {
+			h.writeError(w, err, http.StatusInternalServerError)
+			return
+		}
+		log.Printf("[INFO] Pipeline %s created with %d steps", p.ID, len(p.Steps))
+		h.pipelines.notify()
+		h.writeResponse(w, p)
+	}
+}
+
+// newPipeline validates "steps", returning the pipeline they form. "name" and
+// "args" are the executable run by default.
+func (h *SessionHandler) newPipeline(steps []*PipelineStep, name string, args []string) (*Pipeline, error) {
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("pipelines need at least one step")
+	}
+	steps, err := sortSteps(steps)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range steps {
+		var c createRequest
+		if err := json.Unmarshal(v.Session, &c); err != nil {
+			return nil, fmt.Errorf("step %q: invalid session: %w", v.Name, err)
+		}
+		if c.StartAt != nil || c.Cron != "" {
+			return nil, fmt.Errorf("step %q: sessions of pipelines cannot be scheduled", v.Name)
+		}
+		if c.Replicas != 0 {
+			return nil, fmt.Errorf("step %q: sessions of pipelines cannot be replicated", v.Name)
+		}
+		if _, _, err := h.executable(&c, name, args); err != nil {
+			return nil, fmt.Errorf("step %q: %w", v.Name, err)
+		}
+		if _, err := h.sessionOptions(&c); err != nil {
+			return nil, fmt.Errorf("step %q: %w", v.Name, err)
+		}
+		v.SID, v.State, v.Error = "", StepPending, ""
+	}
+	return &Pipeline{
+		ID:        "pmux-pipeline-" + uuid.New().String(),
+		State:     PipelineRunning,
+		CreatedAt: time.Now(),
+		Steps:     steps,
+	}, nil
+}
+
+// pipeline returns the pipeline selected by the request, which is reported as not
+// existing if it belongs to another namespace.
+func (h *SessionHandler) pipeline(r *http.Request) (*Pipeline, error) {
+	id := mux.Vars(r)["id"]
+	if h.pipelines == nil {
+		return nil, fmt.Errorf("pipeline %s: %w", id, os.ErrNotExist)
+	}
+	p, err := h.pipelines.get(id)
+	if err != nil {
+		return nil, err
+	}
+	if p.Namespace != NamespaceFromContext(r.Context()) {
+		return nil, fmt.Errorf("pipeline %s: %w", id, os.ErrNotExist)
+	}
+	return p, nil
+}
+
+// HandleListPipelines returns the pipelines of the namespace of the request.
+func (h *SessionHandler) HandleListPipelines() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.pipelines == nil {
+			h.writeResponse(w, []*Pipeline{})
+			return
+		}
+		h.writeResponse(w, h.pipelines.list(NamespaceFromContext(r.Context())))
+	}
+}
+
+// HandleShowPipeline returns the combined status of a pipeline and of its steps.
+func (h *SessionHandler) HandleShowPipeline() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, err := h.pipeline(r)
+		if err != nil {
+			h.writeSessionError(w, err)
+			return
+		}
+		h.writeResponse(w, p)
+	}
+}
+
+// HandleDeletePipeline deletes a pipeline together with the sessions of its steps,
+// trashing their files unless "keepFiles" is set.
+func (h *SessionHandler) HandleDeletePipeline(keepFiles bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, err := h.pipeline(r)
+		if err != nil {
+			h.writeSessionError(w, err)
+			return
+		}
+		h.pipelines.run.Lock()
+		defer h.pipelines.run.Unlock()
+		// The steps may have progressed in the meantime.
+		if p, err = h.pipelines.get(p.ID); err != nil {
+			h.writeSessionError(w, err)
+			return
+		}
+		for _, v := range p.Steps {
+			if v.SID == "" || (keepFiles && v.finished()) {
+				continue
+			}
+			if _, err := openSession(v.SID); errors.Is(err, os.ErrNotExist) {
+				// The session was deleted on its own.
+				continue
+			}
+			auditSessions(r.Context(), v.SID)
+			if err := h.deleteSession(v.SID, keepFiles); err != nil {
+				h.writeError(w, fmt.Errorf("unable to delete step %q: %w", v.Name, err), http.StatusInternalServerError)
+				return
+			}
+		}
+		if err := h.pipelines.remove(p.ID); err != nil {
+			h.writeError(w, err, http.StatusInternalServerError)
+			return
+		}
+		log.Printf("[INFO] Pipeline %s deleted", p.ID)
+		h.writeResponse(w, p)
+	}
+}
@@ -0,0 +1,123 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/kim-company/pmux/pwrap"
+)
+
+func TestSortSteps(t *testing.T) {
+	t.Parallel()
+
+	steps, err := sortSteps([]*PipelineStep{
+		{Name: "upload", DependsOn: []string{"package"}},
+		{Name: "package", DependsOn: []string{"transcode"}},
+		{Name: "transcode"},
+		{Name: "thumbnails"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, v := range steps {
+		names = append(names, v.Name)
+	}
+	if s := strings.Join(names, ","); s != "transcode,package,upload,thumbnails" {
+		t.Fatalf("Unexpected order %s", s)
+	}
+
+	for i, v := range [][]*PipelineStep{
+		{{Name: "a"}, {Name: "a"}},
+		{{Name: ""}},
+		{{Name: "a", DependsOn: []string{"b"}}},
+		{{Name: "a", DependsOn: []string{"b"}}, {Name: "b", DependsOn: []string{"a"}}},
+	} {
+		if _, err := sortSteps(v); err == nil {
+			t.Fatalf("%d: steps SHOULD be refused", i)
+		}
+	}
+}
+
+// finishedSession returns a session inside the root directory that finished in
+// state "state".
+func finishedSession(t *testing.T, state pwrap.SessionState) *pwrap.PWrap {
+	pw := namespacedSession(t, pwrap.DefaultNamespace)
+	if err := pw.UpdateSession(func(s *pwrap.Session) {
+		s.State = state
+	}); err != nil {
+		os.RemoveAll(pw.WorkDir())
+		t.Fatal(err)
+	}
+	return pw
+}
+
+func TestSessionHandler_AdvancePipeline(t *testing.T) {
+	t.Parallel()
+
+	exited, failed := finishedSession(t, pwrap.SessionExited), finishedSession(t, pwrap.SessionFailed)
+	defer os.RemoveAll(exited.WorkDir())
+	defer os.RemoveAll(failed.WorkDir())
+
+	// New sessions are not created while draining.
+	h := &SessionHandler{drain: newDrainer()}
+	h.drain.start()
+	p := &Pipeline{ID: "pmux-pipeline-test", State: PipelineRunning, Steps: []*PipelineStep{
+		{Name: "a", SID: exited.SID(), State: string(pwrap.SessionRunning)},
+		{Name: "b", SID: failed.SID(), State: string(pwrap.SessionRunning)},
+		{Name: "c", DependsOn: []string{"a"}, State: StepPending},
+		{Name: "d", DependsOn: []string{"b"}, State: StepPending},
+		{Name: "e", DependsOn: []string{"a", "d"}, State: StepPending},
+	}}
+	h.advancePipeline(p, "yes", nil)
+	for i, v := range []string{pwrap.SessionExited, pwrap.SessionFailed, StepPending, StepSkipped, StepSkipped} {
+		if p.Steps[i].State != v {
+			t.Fatalf("Step %s: wanted state %s, found %s", p.Steps[i].Name, v, p.Steps[i].State)
+		}
+	}
+	if p.State != PipelineRunning || p.FinishedAt != nil {
+		t.Fatalf("Pipelines SHOULD run until every step is finished, found %s", p.State)
+	}
+
+	p.Steps = p.Steps[:2]
+	h.advancePipeline(p, "yes", nil)
+	if p.State != PipelineFailed || p.FinishedAt == nil {
+		t.Fatalf("Wanted failed pipeline, found %s", p.State)
+	}
+	p.State, p.Steps = PipelineRunning, p.Steps[:1]
+	h.advancePipeline(p, "yes", nil)
+	if p.State != PipelineSucceeded {
+		t.Fatalf("Wanted succeeded pipeline, found %s", p.State)
+	}
+}
+
+func TestRouter_CreatePipeline(t *testing.T) {
+	t.Parallel()
+
+	r := NewRouter("yes")
+	for _, v := range []string{
+		`{"steps": []}`,
+		`{"steps": [{"name": "a", "session": {"exec": "missing"}}]}`,
+		`{"steps": [{"name": "a", "session": {"cron": "@daily"}}]}`,
+		`{"steps": [{"name": "a", "depends_on": ["a"], "session": {}}]}`,
+	} {
+		req := httptest.NewRequest("POST", "/api/v1/pipelines", strings.NewReader(v))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("%s: wanted status %d, found %d", v, http.StatusBadRequest, w.Code)
+		}
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/pipelines/pmux-pipeline-missing", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Wanted status %d, found %d", http.StatusNotFound, w.Code)
+	}
+}
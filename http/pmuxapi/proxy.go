@@ -0,0 +1,86 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// wrapperHost is the host used to reach the process wrapper APIs which run on
+// the same machine as the server. Those of remote sessions are reached on their host.
+const wrapperHost = "127.0.0.1"
+
+// HandleProxy forwards requests to the "path" route of the API exposed by the
+// process wrapper of the session, using the port and token it recorded.
+func (h *SessionHandler) HandleProxy(path string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sid := mux.Vars(r)["sid"]
+		s, _, err := h.readSession(sid)
+		if err != nil {
+			h.writeSessionError(w, err)
+			return
+		}
+		if s.Port == 0 {
+			h.writeError(w, fmt.Errorf("session %s has not registered its API yet", sid), http.StatusServiceUnavailable)
+			return
+		}
+		if s.FinishedAt != nil {
+			h.writeError(w, fmt.Errorf("session %s is not running anymore", sid), http.StatusGone)
+			return
+		}
+
+		host := wrapperHost
+		if s.Remote != nil {
+			host = s.Remote.Hostname()
+		}
+		target := &url.URL{Scheme: "http", Host: net.JoinHostPort(host, strconv.Itoa(s.Port))}
+		proxy := &httputil.ReverseProxy{
+			Director: func(req *http.Request) {
+				req.URL.Scheme = target.Scheme
+				req.URL.Host = target.Host
+				req.URL.Path = path
+				req.Host = target.Host
+				req.Header.Set("Authorization", "Bearer "+s.APIToken)
+			},
+			// Progress updates are streamed as soon as they are received.
+			FlushInterval: -1,
+			ErrorHandler: func(w http.ResponseWriter, req *http.Request, err error) {
+				h.writeError(w, fmt.Errorf("unable to reach session %s: %w", sid, err), http.StatusBadGateway)
+			},
+		}
+		// Streams are meant to outlive the timeouts of the server.
+		rc := http.NewResponseController(w)
+		rc.SetWriteDeadline(time.Time{})
+		rc.SetReadDeadline(time.Time{})
+		proxy.ServeHTTP(w, r)
+	}
+}
+
+// HandleStdin streams the body of the request into the stdin of the child of
+// the session, which has to be created with its stdin enabled.
+func (h *SessionHandler) HandleStdin() http.HandlerFunc {
+	proxy := h.HandleProxy("/stdin")
+	return func(w http.ResponseWriter, r *http.Request) {
+		sid := mux.Vars(r)["sid"]
+		s, _, err := h.readSession(sid)
+		if err != nil {
+			h.writeSessionError(w, err)
+			return
+		}
+		if !s.Stdin {
+			h.writeError(w, fmt.Errorf("session %s was not created with its stdin enabled", sid), http.StatusConflict)
+			return
+		}
+		proxy(w, r)
+	}
+}
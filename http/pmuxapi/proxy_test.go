@@ -0,0 +1,94 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/kim-company/pmux/pwrap"
+)
+
+func TestSessionHandler_HandleStdin(t *testing.T) {
+	t.Parallel()
+
+	h := &SessionHandler{}
+	for i, tt := range []struct {
+		stdin  bool
+		status int
+	}{
+		{false, http.StatusConflict},
+		// The wrapper did not register its API.
+		{true, http.StatusServiceUnavailable},
+	} {
+		pw, err := pwrap.New(pwrap.RootDir(RootDir()), pwrap.Stdin(tt.stdin))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(pw.WorkDir())
+		if err := pw.UpdateSession(func(*pwrap.Session) {}); err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest("POST", "/api/v1/sessions/"+pw.SID()+"/stdin", strings.NewReader("data"))
+		req = mux.SetURLVars(req, map[string]string{"sid": pw.SID()})
+		w := httptest.NewRecorder()
+		h.HandleStdin()(w, req)
+		if w.Code != tt.status {
+			t.Fatalf("%d: wanted status %d, found %d", i, tt.status, w.Code)
+		}
+	}
+}
+
+func TestSessionHandler_HandleUnpause(t *testing.T) {
+	t.Parallel()
+
+	h := &SessionHandler{}
+	for _, tt := range []struct {
+		state  pwrap.SessionState
+		status int
+	}{
+		{pwrap.SessionRunning, http.StatusConflict},
+		{pwrap.SessionExited, http.StatusConflict},
+		// The wrapper did not register its API.
+		{pwrap.SessionPaused, http.StatusServiceUnavailable},
+	} {
+		pw, err := pwrap.New(pwrap.RootDir(RootDir()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(pw.WorkDir())
+		if err := pw.UpdateSession(func(s *pwrap.Session) {
+			s.State = tt.state
+		}); err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest("POST", "/api/v1/sessions/"+pw.SID()+"/unpause", nil)
+		req = mux.SetURLVars(req, map[string]string{"sid": pw.SID()})
+		w := httptest.NewRecorder()
+		h.HandleUnpause()(w, req)
+		if w.Code != tt.status {
+			t.Fatalf("%s: wanted status %d, found %d", tt.state, tt.status, w.Code)
+		}
+		s, err := pw.ReadSession()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.State != tt.state || s.Restarts != 0 {
+			t.Fatalf("Unpausing SHOULD NOT restart sessions: %+v", s)
+		}
+	}
+
+	req := httptest.NewRequest("POST", "/api/v1/sessions/pmux-missing/unpause", nil)
+	req = mux.SetURLVars(req, map[string]string{"sid": "pmux-missing"})
+	w := httptest.NewRecorder()
+	h.HandleUnpause()(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Missing sessions SHOULD be reported, found %d", w.Code)
+	}
+}
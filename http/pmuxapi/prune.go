@@ -0,0 +1,93 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kim-company/pmux/pwrap"
+)
+
+// PruneLocal removes the working directories of the sessions of this host that are
+// no longer running, together with the sockets left behind by their children in the
+// temporary directory and in the default socket directory. Only files untouched for at least "olderThan" are considered,
+// and queued sessions are always kept. PruneLocal returns the paths removed, or that
+// would be removed if "dryRun" is set.
+func PruneLocal(olderThan time.Duration, dryRun bool) ([]string, error) {
+	sockDirs := []string{os.TempDir()}
+	if dir := pwrap.DefaultSockDir(); dir != sockDirs[0] {
+		sockDirs = append(sockDirs, dir)
+	}
+	return prune(rootDir, sockDirs, time.Now().Add(-olderThan), dryRun, func(sid string) bool {
+		return pwrap.HasSession(rootDir, sid)
+	})
+}
+
+func prune(root string, sockDirs []string, before time.Time, dryRun bool, running func(string) bool) ([]string, error) {
+	var acc []string
+	remove := func(path string) {
+		if !dryRun {
+			if err := os.RemoveAll(path); err != nil {
+				log.Printf("[WARN] unable to prune %v: %v", path, err)
+				return
+			}
+		}
+		acc = append(acc, path)
+	}
+
+	entries, err := os.ReadDir(root)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("unable to read sessions root directory: %w", err)
+	}
+	for _, v := range entries {
+		if !v.IsDir() || running(v.Name()) {
+			continue
+		}
+		info, err := v.Info()
+		if err != nil || !stale(info, before) {
+			continue
+		}
+		pw, err := pwrap.New(pwrap.OverrideSID(v.Name()), pwrap.RootDir(root))
+		if err != nil {
+			continue
+		}
+		if s, err := pw.ReadSession(); err == nil {
+			if s.State == pwrap.SessionQueued {
+				continue
+			}
+			if s.FinishedAt != nil && s.FinishedAt.After(before) {
+				continue
+			}
+		}
+		remove(pw.WorkDir())
+	}
+
+	for _, dir := range sockDirs {
+		socks, err := filepath.Glob(filepath.Join(dir, "pmux-*.sock"))
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range socks {
+			if running(strings.TrimSuffix(filepath.Base(v), ".sock")) {
+				continue
+			}
+			if info, err := os.Lstat(v); err == nil && stale(info, before) {
+				remove(v)
+			}
+		}
+	}
+	return acc, nil
+}
+
+// stale reports whether the file described by "info" was last modified before
+// "before".
+func stale(info os.FileInfo, before time.Time) bool {
+	return info.ModTime().Before(before)
+}
@@ -0,0 +1,75 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/kim-company/pmux/pwrap"
+)
+
+func TestPrune(t *testing.T) {
+	t.Parallel()
+
+	dir, err := os.MkdirTemp("", "pmux-prune-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	root := filepath.Join(dir, "root")
+
+	old := time.Now().Add(-time.Hour * 48)
+	for _, v := range []struct {
+		sid   string
+		state pwrap.SessionState
+	}{
+		{"pmux-finished", pwrap.SessionExited},
+		{"pmux-queued", pwrap.SessionQueued},
+		{"pmux-running", pwrap.SessionRunning},
+		{"pmux-recent", pwrap.SessionExited},
+	} {
+		pw, err := pwrap.New(pwrap.OverrideSID(v.sid), pwrap.RootDir(root))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := pw.UpdateSession(func(s *pwrap.Session) { s.State = v.state }); err != nil {
+			t.Fatal(err)
+		}
+		if v.sid != "pmux-recent" {
+			os.Chtimes(pw.WorkDir(), old, old)
+		}
+	}
+	for _, v := range []string{"pmux-finished.sock", "pmux-running.sock"} {
+		path := filepath.Join(dir, v)
+		if err := os.WriteFile(path, nil, os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(path, old, old)
+	}
+	running := func(sid string) bool { return sid == "pmux-running" }
+	before := time.Now().Add(-time.Hour * 24)
+
+	want := []string{filepath.Join(dir, "pmux-finished.sock"), filepath.Join(root, "pmux-finished")}
+	for _, dryRun := range []bool{true, false} {
+		paths, err := prune(root, []string{dir}, before, dryRun, running)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(paths)
+		if !reflect.DeepEqual(paths, want) {
+			t.Fatalf("Dry run %t: unexpected paths pruned: %v", dryRun, paths)
+		}
+	}
+	for _, v := range want {
+		if _, err := os.Stat(v); !os.IsNotExist(err) {
+			t.Fatalf("%v still exists: %v", v, err)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/kim-company/pmux/pwrap"
+)
+
+// errSessionLost is recorded in the state of sessions whose wrapper
+// disappeared without the wrapper recording their termination.
+var errSessionLost = errors.New("session lost: its wrapper is gone but the wrapper did not record its termination")
+
+// lost reports whether the session "s" should be running according to its
+// state, while its wrapper is not.
+func lost(s *pwrap.Session, running bool) bool {
+	return !running && (s.State == pwrap.SessionCreated || s.State == pwrap.SessionRunning || s.State == pwrap.SessionPaused)
+}
+
+// markLost records the termination of the lost session of "pw".
+func markLost(pw *pwrap.PWrap) (*pwrap.Session, error) {
+	var acc *pwrap.Session
+	err := pw.UpdateSession(func(s *pwrap.Session) {
+		now := time.Now()
+		s.State = pwrap.SessionFailed
+		s.Error = errSessionLost.Error()
+		s.FinishedAt = &now
+		c := *s
+		acc = &c
+	})
+	return acc, err
+}
+
+// declareLost records the termination of the lost session of "pw", delivering
+// the finished event and the final callback on behalf of its wrapper.
+func (h *SessionHandler) declareLost(pw *pwrap.PWrap) (*pwrap.Session, error) {
+	s, err := markLost(pw)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[WARN] session %s lost: its wrapper is gone", s.SID)
+	h.notify(pwrap.EventFinished, s.SID, s)
+	if s.RegisterURL != "" {
+		pwrap.Register(s.RegisterURL)(pw)
+		go func() {
+			if err := pw.Callback(errSessionLost); err != nil {
+				log.Printf("[WARN] unable to deliver the callback of lost session %s: %v", s.SID, err)
+			}
+		}()
+	}
+	return s, nil
+}
+
+// reconcile rebuilds the state of the server from the working directories found
+// in the root directory and the running sessions, so that sessions created before
+// a restart keep being managed: queued sessions are scheduled again, while those
+// whose wrapper is gone are declared lost.
+func (h *SessionHandler) reconcile() error {
+	running, err := pwrap.ListSessions(rootDir)
+	if err != nil {
+		return fmt.Errorf("unable to reconcile sessions: %w", err)
+	}
+	alive := make(map[string]bool, len(running))
+	for _, v := range running {
+		alive[v] = true
+	}
+	entries, err := os.ReadDir(rootDir)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("unable to reconcile sessions: %w", err)
+	}
+
+	seen := map[string]bool{}
+	var adopted, failed, queued int
+	for _, v := range entries {
+		if !v.IsDir() {
+			continue
+		}
+		pw, err := openSession(v.Name())
+		if err != nil {
+			log.Printf("[WARN] reconcile: skipping %s: %v", v.Name(), err)
+			continue
+		}
+		s, err := pw.ReadSession()
+		if err != nil {
+			if !errors.Is(err, pwrap.ErrNoSession) {
+				log.Printf("[WARN] reconcile: skipping %s: %v", v.Name(), err)
+			}
+			continue
+		}
+		seen[s.SID] = true
+
+		switch {
+		case s.State == pwrap.SessionQueued:
+			queued++
+			if h.sched != nil {
+				err = h.sched.enqueue(pw)
+			} else {
+				_, err = startSession(pw)
+			}
+		case lost(s, alive[s.SID]):
+			failed++
+			s, err = h.declareLost(pw)
+		case alive[s.SID]:
+			adopted++
+		}
+		if err != nil {
+			log.Printf("[ERROR] reconcile: session %s: %v", pw.SID(), err)
+			continue
+		}
+		h.sync(s)
+	}
+
+	if h.store != nil {
+		// Sessions whose working directory is gone are only known to the store.
+		records, err := h.store.List()
+		if err != nil {
+			return fmt.Errorf("unable to reconcile sessions: %w", err)
+		}
+		for _, s := range records {
+			if seen[s.SID] || !lost(s, alive[s.SID]) {
+				continue
+			}
+			failed++
+			now := time.Now()
+			s.State = pwrap.SessionFailed
+			s.Error = errSessionLost.Error()
+			s.FinishedAt = &now
+			h.sync(s)
+			h.notify(pwrap.EventFinished, s.SID, s)
+		}
+	}
+	log.Printf("[INFO] reconcile: %d running sessions adopted, %d queued, %d marked as failed", adopted, queued, failed)
+	return nil
+}
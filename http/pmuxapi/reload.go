@@ -0,0 +1,116 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+)
+
+// Settings are the settings of the server that can be changed while it runs,
+// see "Router.Reload".
+type Settings struct {
+	// Execs are the names of the executables sessions may select.
+	Execs []string `json:"execs"`
+	// MaxRunning is the maximum number of sessions running concurrently,
+	// zero meaning no limit.
+	MaxRunning      int            `json:"max_running"`
+	NamespaceLimits map[string]int `json:"namespace_limits,omitempty"`
+	// Retention is the time finished sessions are kept for, empty if they
+	// are kept forever.
+	Retention string `json:"retention,omitempty"`
+}
+
+// Reloader sets the function called by the reload route, returning the options
+// that "Router.Reload" applies, usually read again from the configuration of the
+// server. The reload route is not available if not set.
+func Reloader(f func() ([]func(*Router), error)) func(*Router) {
+	return func(r *Router) {
+		r.reloader = f
+	}
+}
+
+// Reload applies to the running server the executables, the maximum number of
+// running sessions, the namespace limits and the retention period set by "opts",
+// other options being ignored. Settings that "opts" do not set are reset to their
+// defaults. Existing sessions, and the streams open to them, are not affected:
+// the new settings apply to the sessions created from now on, while queued
+// sessions are started as soon as the new maximum allows.
+//
+// The maximum number of running sessions cannot be set if the server was started
+// without one, as sessions started in the meantime are not accounted for.
+func (r *Router) Reload(opts ...func(*Router)) (*Settings, error) {
+	n := &Router{}
+	for _, f := range opts {
+		f(n)
+	}
+	h := r.h
+	if n.maxRun > 0 && h.sched == nil {
+		return nil, fmt.Errorf("the maximum number of running sessions can only be enabled by restarting the server")
+	}
+	h.settings.Lock()
+	h.execs, h.nsLimits, h.retention = n.execs, n.nsLimits, n.retention
+	h.settings.Unlock()
+	if h.sched != nil {
+		h.sched.setMax(n.maxRun)
+	}
+	if n.retention > 0 {
+		h.startReaper()
+	}
+	s := r.Settings()
+	log.Printf("[INFO] settings reloaded: %d executables, max running %d, %d namespace limits, retention %v", len(s.Execs), s.MaxRunning, len(s.NamespaceLimits), n.retention)
+	return s, nil
+}
+
+// Settings returns the current settings of the server that can be reloaded.
+func (r *Router) Settings() *Settings {
+	h := r.h
+	s := &Settings{Execs: []string{}}
+	h.settings.RLock()
+	for k := range h.execs {
+		s.Execs = append(s.Execs, k)
+	}
+	if len(h.nsLimits) > 0 {
+		s.NamespaceLimits = make(map[string]int, len(h.nsLimits))
+		for k, v := range h.nsLimits {
+			s.NamespaceLimits[k] = v
+		}
+	}
+	if h.retention > 0 {
+		s.Retention = h.retention.String()
+	}
+	h.settings.RUnlock()
+	sort.Strings(s.Execs)
+	if h.sched != nil {
+		h.sched.Lock()
+		s.MaxRunning = h.sched.max
+		h.sched.Unlock()
+	}
+	return s
+}
+
+// HandleReload reloads the settings of the server, see "Reloader", responding
+// with the settings in effect.
+func (r *Router) HandleReload() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if r.reloader == nil {
+			r.h.writeError(w, fmt.Errorf("reload is not enabled"), http.StatusNotImplemented)
+			return
+		}
+		opts, err := r.reloader()
+		if err != nil {
+			r.h.writeError(w, fmt.Errorf("unable to reload settings: %w", err), http.StatusInternalServerError)
+			return
+		}
+		s, err := r.Reload(opts...)
+		if err != nil {
+			r.h.writeError(w, err, http.StatusConflict)
+			return
+		}
+		r.h.writeResponse(w, s)
+	}
+}
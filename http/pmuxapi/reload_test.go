@@ -0,0 +1,133 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kim-company/pmux/pwrap"
+)
+
+func TestRouter_Reload(t *testing.T) {
+	t.Parallel()
+
+	dir, err := os.MkdirTemp("", "pmux-reload-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store, err := NewBoltStore(filepath.Join(dir, RegistryFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	opts := []func(*Router){
+		Execs(map[string]Executable{"cat": {Path: "cat"}, "yes": {Path: "yes"}}),
+		MaxRunning(4),
+		NamespaceLimit("video", 1),
+		Retention(time.Hour),
+	}
+	r := NewRouter("yes", SessionStore(store), APIKeys("admin"), NamespaceAPIKeys("video", "tv"),
+		Execs(map[string]Executable{"echo": {Path: "echo"}}), MaxRunning(2), Reloader(func() ([]func(*Router), error) {
+			return opts, nil
+		}))
+
+	for i, tt := range []struct {
+		token  string
+		status int
+	}{
+		{"tv", http.StatusForbidden},
+		{"admin", http.StatusOK},
+	} {
+		req := httptest.NewRequest("POST", "/api/v1/reload", nil)
+		req.Header.Set("Authorization", "Bearer "+tt.token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.status {
+			t.Fatalf("%d: status %d SHOULD be %d: %s", i, w.Code, tt.status, w.Body)
+		}
+		if tt.status != http.StatusOK {
+			continue
+		}
+		var s Settings
+		if err := json.NewDecoder(w.Body).Decode(&s); err != nil {
+			t.Fatal(err)
+		}
+		want := Settings{Execs: []string{"cat", "yes"}, MaxRunning: 4, NamespaceLimits: map[string]int{"video": 1}, Retention: "1h0m0s"}
+		if !reflect.DeepEqual(s, want) {
+			t.Fatalf("%d: reloaded settings SHOULD be %+v, found %+v", i, want, s)
+		}
+	}
+
+	if _, _, err := r.h.executable(&createRequest{Exec: "echo"}, "yes", nil); err == nil {
+		t.Fatalf("Executables removed by the reload SHOULD NOT be allowed")
+	}
+	if path, _, err := r.h.executable(&createRequest{Exec: "cat"}, "yes", nil); err != nil || path != "cat" {
+		t.Fatalf("Executables added by the reload SHOULD be allowed: %q, %v", path, err)
+	}
+
+	// Settings not set by the options are reset to their defaults.
+	s, err := r.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Execs) != 0 || s.MaxRunning != 0 || s.NamespaceLimits != nil || s.Retention != "" {
+		t.Fatalf("Reloading without options SHOULD reset the settings: %+v", s)
+	}
+}
+
+func TestRouter_ReloadErrors(t *testing.T) {
+	t.Parallel()
+
+	r := NewRouter("yes")
+	if _, err := r.Reload(MaxRunning(1)); err == nil {
+		t.Fatalf("Limiting running sessions SHOULD require a restart when they were not limited")
+	}
+	req := httptest.NewRequest("POST", "/api/v1/reload", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNotImplemented {
+		t.Fatalf("Reloading SHOULD NOT be available without a reloader, found status %d", w.Code)
+	}
+}
+
+func TestScheduler_SetMax(t *testing.T) {
+	t.Parallel()
+
+	running := 0
+	s := &scheduler{
+		max:     1,
+		running: func() int { return running },
+		start: func(pw *pwrap.PWrap) (string, error) {
+			running++
+			return pw.SID(), nil
+		},
+		wake: make(chan struct{}, 1),
+	}
+	for i := 0; i < 3; i++ {
+		pw := queuedSession(t, 0)
+		defer os.RemoveAll(pw.WorkDir())
+		if err := s.enqueue(pw); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.schedule()
+	if running != 1 {
+		t.Fatalf("Unexpected scheduling: running %d", running)
+	}
+	s.setMax(0)
+	s.schedule()
+	if running != 3 || len(s.queue) != 0 {
+		t.Fatalf("Queued sessions SHOULD start once the limit is lifted: running %d, queued %d", running, len(s.queue))
+	}
+}
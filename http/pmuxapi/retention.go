@@ -0,0 +1,96 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/kim-company/pmux/pwrap"
+)
+
+// retentionInterval is the time between two searches of expired sessions.
+const retentionInterval = time.Minute
+
+// Retention makes the server trash finished sessions, their working directories
+// and records included, once "ttl" has passed since they finished. Sessions may
+// select their own retention period when created. Sessions are kept forever if
+// zero.
+func Retention(ttl time.Duration) func(*Router) {
+	return func(r *Router) {
+		r.retention = ttl
+	}
+}
+
+// parseRetention validates the retention period requested by a session.
+func parseRetention(s string) error {
+	if s == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid retention %q: %w", s, err)
+	}
+	if d <= 0 {
+		return fmt.Errorf("retention has to be positive: %v", d)
+	}
+	return nil
+}
+
+// expired reports whether session "s" finished before "now" by more than its
+// retention period, or "ttl" if it did not select one.
+func expired(s *pwrap.Session, ttl time.Duration, now time.Time) bool {
+	if s.FinishedAt == nil {
+		return false
+	}
+	if d, err := time.ParseDuration(s.Retention); err == nil && d > 0 {
+		ttl = d
+	}
+	return ttl > 0 && now.Sub(*s.FinishedAt) >= ttl
+}
+
+// reap trashes the sessions that expired at "now", returning their identifiers.
+func (h *SessionHandler) reap(now time.Time) []string {
+	sessions, err := h.listSessions()
+	if err != nil {
+		log.Printf("[WARN] retention: unable to list sessions: %v", err)
+		return nil
+	}
+	h.settings.RLock()
+	ttl := h.retention
+	h.settings.RUnlock()
+	var acc []string
+	for _, v := range sessions {
+		if v.Tmux || !expired(&v.Session, ttl, now) {
+			continue
+		}
+		if err := h.deleteSession(v.SID, false); err != nil {
+			log.Printf("[ERROR] retention: unable to trash session %s: %v", v.SID, err)
+			continue
+		}
+		acc = append(acc, v.SID)
+	}
+	if len(acc) > 0 {
+		log.Printf("[INFO] retention: %d expired sessions trashed", len(acc))
+	}
+	return acc
+}
+
+// startReaper starts looking for expired sessions, unless it did already.
+func (h *SessionHandler) startReaper() {
+	h.reaper.Do(func() {
+		go h.reapEvery(retentionInterval)
+	})
+}
+
+// reapEvery looks for expired sessions every "interval", forever.
+func (h *SessionHandler) reapEvery(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for now := range t.C {
+		h.reap(now)
+	}
+}
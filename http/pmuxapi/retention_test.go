@@ -0,0 +1,51 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kim-company/pmux/pwrap"
+)
+
+func TestExpired(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	finished := now.Add(-time.Hour * 2)
+	tt := []struct {
+		s   pwrap.Session
+		ttl time.Duration
+		ok  bool
+	}{
+		{pwrap.Session{State: pwrap.SessionRunning}, time.Hour, false},
+		{pwrap.Session{State: pwrap.SessionExited, FinishedAt: &finished}, 0, false},
+		{pwrap.Session{State: pwrap.SessionExited, FinishedAt: &finished}, time.Hour, true},
+		{pwrap.Session{State: pwrap.SessionFailed, FinishedAt: &finished}, time.Hour * 3, false},
+		{pwrap.Session{State: pwrap.SessionExited, FinishedAt: &finished, Retention: "72h"}, time.Hour, false},
+		{pwrap.Session{State: pwrap.SessionExited, FinishedAt: &finished, Retention: "1h"}, 0, true},
+	}
+	for i, v := range tt {
+		if ok := expired(&v.s, v.ttl, now); ok != v.ok {
+			t.Fatalf("%d: wanted %t, found %t", i, v.ok, ok)
+		}
+	}
+}
+
+func TestParseRetention(t *testing.T) {
+	t.Parallel()
+
+	for _, v := range []string{"", "72h", "30m"} {
+		if err := parseRetention(v); err != nil {
+			t.Fatalf("%q: %v", v, err)
+		}
+	}
+	for _, v := range []string{"0s", "-1h", "3 days"} {
+		if err := parseRetention(v); err == nil {
+			t.Fatalf("%q: expected an error", v)
+		}
+	}
+}
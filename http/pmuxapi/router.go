@@ -8,8 +8,14 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/kim-company/pmux/http/pwrapapi"
+	"github.com/kim-company/pmux/pwrap"
+	"github.com/kim-company/pmux/tmux"
 )
 
 type Router struct {
@@ -17,6 +23,51 @@ type Router struct {
 	keepFiles bool
 	execName  string
 	args      []string
+	grace     time.Duration
+	execs     map[string]Executable
+	webhooks  []string
+	apiKeys   []string
+	jwtSecret []byte
+	cors      *cors
+	maxRun    int
+	preempt   bool
+	images    []string
+	mounts    []string
+	secrets   []string
+	detach    bool
+	preflight bool
+	sockDir   string
+	streaming pwrapapi.StreamMode
+	ops       []string
+	apiPorts  *pwrap.PortRange
+	kube      *pwrap.Kubernetes
+	remote    pwrap.Remote
+	hosts     []string
+	quota     *pwrap.Quota
+	retention time.Duration
+	compress  bool
+	// scopedKeys maps the API keys restricted to some namespaces to
+	// them, and nsLimits the namespaces to their session limit.
+	scopedKeys map[string][]string
+	nsLimits   map[string]int
+	// configVars, if set, enables configuration templates.
+	configVars map[string]string
+	artifacts  string
+	sidecars   map[string]pwrap.Sidecar
+	store      Store
+	audit      AuditLog
+	h          *SessionHandler
+	// reloader, if set, returns the options applied by the reload route.
+	reloader func() ([]func(*Router), error)
+}
+
+// ServeHTTP dispatches the request to the matching route. Cross-origin preflight
+// requests are answered before routing, as routes do not match the OPTIONS method.
+func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if r.cors != nil && r.cors.handle(w, req) {
+		return
+	}
+	r.Router.ServeHTTP(w, req)
 }
 
 func KeepFiles(ok bool) func(*Router) {
@@ -25,6 +76,181 @@ func KeepFiles(ok bool) func(*Router) {
 	}
 }
 
+// GracePeriod sets the time sessions are given to exit gracefully when
+// they are deleted.
+func GracePeriod(d time.Duration) func(*Router) {
+	return func(r *Router) {
+		r.grace = d
+	}
+}
+
+// Executable is a program that sessions may run, together with its first arguments.
+type Executable struct {
+	Path string
+	Args []string
+}
+
+// ParseExecutable parses an executable definition in the "name=path[,arg...]" form.
+func ParseExecutable(s string) (string, Executable, error) {
+	kv := strings.SplitN(s, "=", 2)
+	if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+		return "", Executable{}, fmt.Errorf("invalid executable definition %q, expected name=path[,arg...]", s)
+	}
+	fields := strings.Split(kv[1], ",")
+	if err := pwrap.ValidateArgs(fields[1:]); err != nil {
+		return "", Executable{}, fmt.Errorf("invalid executable definition %q: %w", s, err)
+	}
+	return kv[0], Executable{Path: fields[0], Args: fields[1:]}, nil
+}
+
+// Execs sets the executables that can be selected by name, using the "exec"
+// field, when creating a session. Sessions that do not select one run the
+// router's default executable.
+func Execs(m map[string]Executable) func(*Router) {
+	return func(r *Router) {
+		r.execs = m
+	}
+}
+
+// Webhooks sets the URLs that receive the lifecycle events of every session: the
+// server delivers creation and deletion events, while the process wrappers deliver
+// the others.
+func Webhooks(urls ...string) func(*Router) {
+	return func(r *Router) {
+		r.webhooks = urls
+	}
+}
+
+// MaxRunning limits the number of sessions running concurrently to "n": sessions
+// created when the limit is reached are queued, and started as soon as a running
+// one exits. Zero means no limit.
+func MaxRunning(n int) func(*Router) {
+	return func(r *Router) {
+		r.maxRun = n
+	}
+}
+
+// Preemption makes the server stop running sessions, when "MaxRunning" is reached,
+// to start queued sessions of higher priority. Preempted sessions are queued again
+// and restarted from scratch once their turn comes.
+func Preemption(ok bool) func(*Router) {
+	return func(r *Router) {
+		r.preempt = ok
+	}
+}
+
+// ContainerImages sets the Docker images that sessions may run in, using the
+// "container" field, when creating a session. Containers are not allowed if empty.
+func ContainerImages(images ...string) func(*Router) {
+	return func(r *Router) {
+		r.images = images
+	}
+}
+
+// SecretRefs sets the prefixes of the secret references sessions may use, e.g.
+// "vault:secret/data/ci/". As secrets are resolved with the privileges of the
+// server, sessions cannot reference any if no prefix is set.
+func SecretRefs(prefixes ...string) func(*Router) {
+	return func(r *Router) {
+		r.secrets = prefixes
+	}
+}
+
+// ContainerMounts sets the host paths that containers may bind mount.
+func ContainerMounts(sources ...string) func(*Router) {
+	return func(r *Router) {
+		r.mounts = make([]string, len(sources))
+		for i, v := range sources {
+			r.mounts[i] = filepath.Clean(v)
+		}
+	}
+}
+
+// Detach makes session wrappers start as detached processes, keeping their PID
+// in the working directory, rather than inside tmux sessions. This is always
+// the case when tmux is not installed.
+func Detach(ok bool) func(*Router) {
+	return func(r *Router) {
+		r.detach = ok
+	}
+}
+
+// SockDir sets the directory hosting the unix sockets of the children of the
+// sessions, see "pwrap.SockDir".
+func SockDir(dir string) func(*Router) {
+	return func(r *Router) {
+		r.sockDir = dir
+	}
+}
+
+// APIPorts limits the ports the wrapper API of the sessions listens on to range
+// "ports", see "pwrap.APIPorts".
+func APIPorts(ports *pwrap.PortRange) func(*Router) {
+	return func(r *Router) {
+		r.apiPorts = ports
+	}
+}
+
+// Streaming sets how the wrapper API of the sessions delivers the progress and
+// the streams of their children, see "pwrapapi.StreamMode".
+func Streaming(m pwrapapi.StreamMode) func(*Router) {
+	return func(r *Router) {
+		r.streaming = m
+	}
+}
+
+// CommandOps restricts the operations of the commands that the wrapper API of the
+// sessions accepts to "ops", "pwrapapi.DefaultCommandOps" if empty.
+func CommandOps(ops ...string) func(*Router) {
+	return func(r *Router) {
+		r.ops = ops
+	}
+}
+
+// Preflight makes every session pass a dry run, see "pwrap.PWrap.DryRun", before
+// it is started, so that sessions that would fail at once are rejected by the
+// create request.
+func Preflight(ok bool) func(*Router) {
+	return func(r *Router) {
+		r.preflight = ok
+	}
+}
+
+// Kubernetes allows sessions to run as Kubernetes Jobs, using "k" as template:
+// sessions may select an image among those allowed by "ContainerImages" and
+// their resource limits. Kubernetes sessions are not allowed if nil.
+func Kubernetes(k *pwrap.Kubernetes) func(*Router) {
+	return func(r *Router) {
+		r.kube = k
+	}
+}
+
+// RemoteHosts allows sessions to be placed on "hosts", reached over SSH, which
+// have pmux and tmux installed. "root" is the directory containing the working
+// directories on the remote hosts, and "pmux" the path of the pmux executable there.
+func RemoteHosts(root, pmux string, hosts ...string) func(*Router) {
+	return func(r *Router) {
+		r.remote = pwrap.Remote{Root: root, PMux: pmux}
+		r.hosts = hosts
+	}
+}
+
+// DiskQuota limits the working directories of the sessions to "q", unless they
+// request a lower quota. Working directories are not limited if nil.
+func DiskQuota(q *pwrap.Quota) func(*Router) {
+	return func(r *Router) {
+		r.quota = q
+	}
+}
+
+// SessionStore sets the store recording the sessions created. Defaults to a
+// "BoltStore" kept in the root directory.
+func SessionStore(s Store) func(*Router) {
+	return func(r *Router) {
+		r.store = s
+	}
+}
+
 func Args(args []string) func(*Router) {
 	return func(r *Router) {
 		r.args = args
@@ -34,23 +260,127 @@ func Args(args []string) func(*Router) {
 // NewRouter returns a new ``Router'' instance which satisfies the ``http.Handler''
 // interface.
 func NewRouter(execName string, opts ...func(*Router)) *Router {
-	r := &Router{Router: mux.NewRouter()}
+	r := &Router{Router: mux.NewRouter(), grace: pwrap.DefaultGracePeriod}
 
 	r.Use(loggingMiddleware)
-	r.HandleFunc("/health_check", func(w http.ResponseWriter, r *http.Request) {
+	r.HandleFunc("/health_check", func(w http.ResponseWriter, req *http.Request) {
+		if r.h.drain.draining() {
+			// Take the server out of load balancers.
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintln(w, "Draining!")
+			return
+		}
 		fmt.Fprintln(w, "Online!")
 	}).Methods("GET")
+	r.HandleFunc("/openapi.json", HandleOpenAPI()).Methods("GET")
+	r.HandleFunc("/docs", HandleDocs("/openapi.json")).Methods("GET")
 
 	// Apply options on router.
 	for _, f := range opts {
 		f(r)
 	}
 
-	h := &SessionHandler{}
+	if r.store == nil {
+		s, err := defaultStore()
+		if err != nil {
+			log.Printf("[ERROR] sessions will not be recorded: %v", err)
+		} else {
+			r.store = s
+		}
+	}
+
+	if r.audit == nil {
+		l, err := NewFileAuditLog(filepath.Join(rootDir, AuditFile))
+		if err != nil {
+			log.Printf("[ERROR] operations will not be audited: %v", err)
+		} else {
+			r.audit = l
+		}
+	}
+
+	h := &SessionHandler{grace: r.grace, execs: r.execs, webhooks: r.webhooks, store: r.store, drain: newDrainer(), images: r.images, mounts: r.mounts, secrets: r.secrets, cors: r.cors, detach: r.detach, kube: r.kube, remote: r.remote, hosts: r.hosts, quota: r.quota, retention: r.retention, nsLimits: r.nsLimits, configVars: r.configVars, artifacts: r.artifacts, sidecars: r.sidecars, preflight: r.preflight, sockDir: r.sockDir, streaming: r.streaming, commandOps: r.ops, apiPorts: r.apiPorts}
+	r.h = h
+	if h.store != nil {
+		h.observed = make(chan *pwrap.Session, observedQueue)
+		go h.recordObserved()
+	}
+	if r.maxRun > 0 {
+		// Exits of sessions running in tmux are notified, the others
+		// have to be polled for.
+		interval := schedulerInterval
+		if tmux.Supports(tmux.FeatureExitHook) == nil && !r.detach && r.kube == nil && len(r.hosts) == 0 {
+			interval = watchdogInterval
+		}
+		h.sched = newScheduler(r.maxRun, r.preempt, r.grace, interval)
+	}
+	if err := h.reconcile(); err != nil {
+		log.Printf("[ERROR] %v", err)
+	}
+	if t, err := loadTimetable(filepath.Join(rootDir, SchedulesFile), time.Now()); err != nil {
+		log.Printf("[ERROR] sessions cannot be scheduled: %v", err)
+	} else {
+		h.timetable = t
+		go h.fireEvery(timetableInterval, execName, r.args)
+	}
+	if p, err := loadPipelines(filepath.Join(rootDir, PipelinesFile)); err != nil {
+		log.Printf("[ERROR] pipelines cannot be created: %v", err)
+	} else {
+		h.pipelines = p
+		go h.runPipelines(pipelineInterval, execName, r.args)
+	}
+	h.exits = make(chan struct{}, 1)
+	go h.watchExits()
+	go h.watch(watchdogInterval)
+	if r.retention > 0 {
+		h.startReaper()
+	}
+	if r.compress {
+		go h.compressEvery(compressInterval)
+	}
 	v1 := r.PathPrefix("/api/v1").Subrouter()
+	// The health check is left unauthenticated.
+	if a := (&authenticator{keys: r.apiKeys, scopes: r.scopedKeys, secret: r.jwtSecret}); a.enabled() {
+		v1.Use(a.middleware)
+	}
+	v1.Use(h.namespaceMiddleware)
+	// Routes changing the state of the server or of its sessions are named
+	// after the action they perform, and audited.
+	if r.audit != nil {
+		v1.Use(auditMiddleware(r.audit))
+	}
+	v1.HandleFunc("/audit", r.HandleAudit()).Methods("GET")
+	v1.HandleFunc("/drain", h.unscoped(r.HandleDrain())).Methods("POST").Name(ActionDrain)
+	v1.HandleFunc("/reload", h.unscoped(r.HandleReload())).Methods("POST").Name(ActionReload)
+	v1.HandleFunc("/progress", h.HandleProgressFeed()).Methods("GET")
 	v1.HandleFunc("/sessions", h.HandleList()).Methods("GET")
-	v1.HandleFunc("/sessions", h.HandleCreate(execName, r.args...)).Methods("POST")
-	v1.HandleFunc("/sessions/{sid}", h.HandleDelete(r.keepFiles)).Methods("DELETE")
+	v1.HandleFunc("/sessions", h.HandleCreate(execName, r.args...)).Methods("POST").Name(ActionCreate)
+	v1.HandleFunc("/sessions", h.HandleBulkDelete(r.keepFiles)).Methods("DELETE").Name(ActionBulkDelete)
+	v1.HandleFunc("/sessions/{sid}", h.HandleShow()).Methods("GET")
+	v1.HandleFunc("/sessions/{sid}/restart", h.HandleRestart()).Methods("POST").Name(ActionRestart)
+	v1.HandleFunc("/sessions/{sid}/resume", h.HandleResume()).Methods("POST").Name(ActionResume)
+	v1.HandleFunc("/sessions/{sid}/pause", h.HandleProxy("/pause")).Methods("POST").Name(ActionPause)
+	v1.HandleFunc("/sessions/{sid}/unpause", h.HandleUnpause()).Methods("POST").Name(ActionUnpause)
+	v1.HandleFunc("/sessions/{sid}/config", h.HandleConfig()).Methods("GET")
+	v1.HandleFunc("/sessions/{sid}/config", h.HandleUpdateConfig()).Methods("PUT").Name(ActionUpdateConfig)
+	v1.HandleFunc("/sessions/{sid}/logs", h.HandleLogs()).Methods("GET")
+	v1.HandleFunc("/sessions/{sid}/files", h.HandleFiles()).Methods("GET")
+	v1.HandleFunc("/sessions/{sid}/files/{name:.+}", h.HandleFile()).Methods("GET")
+	v1.HandleFunc("/sessions/{sid}/progress", h.HandleProxy("/progress")).Methods("GET")
+	v1.HandleFunc("/sessions/{sid}/progress/history", h.HandleProgressHistory()).Methods("GET")
+	v1.HandleFunc("/sessions/{sid}/command", h.HandleProxy("/command")).Methods("POST").Name(ActionCommand)
+	v1.HandleFunc("/sessions/{sid}/stdin", h.HandleStdin()).Methods("POST").Name(ActionStdin)
+	v1.HandleFunc("/sessions/{sid}/terminal", h.HandleTerminal()).Methods("GET").Name(ActionTerminal)
+	v1.HandleFunc("/sessions/{sid}/screen", h.HandleScreen()).Methods("GET")
+	v1.HandleFunc("/sessions/{sid}", h.HandleDelete(r.keepFiles)).Methods("DELETE").Name(ActionDelete)
+	v1.HandleFunc("/schedules", h.HandleListSchedules()).Methods("GET")
+	v1.HandleFunc("/schedules/{id}", h.HandleShowSchedule()).Methods("GET")
+	v1.HandleFunc("/schedules/{id}", h.HandleDeleteSchedule()).Methods("DELETE").Name(ActionDeleteSchedule)
+	v1.HandleFunc("/pipelines", h.HandleListPipelines()).Methods("GET")
+	v1.HandleFunc("/pipelines", h.HandleCreatePipeline(execName, r.args...)).Methods("POST").Name(ActionCreatePipeline)
+	v1.HandleFunc("/pipelines/{id}", h.HandleShowPipeline()).Methods("GET")
+	v1.HandleFunc("/pipelines/{id}", h.HandleDeletePipeline(r.keepFiles)).Methods("DELETE").Name(ActionDeletePipeline)
+	v1.HandleFunc("/groups/{id}", h.HandleShowGroup()).Methods("GET")
+	v1.HandleFunc("/groups/{id}", h.HandleDeleteGroup(r.keepFiles)).Methods("DELETE").Name(ActionDeleteGroup)
 
 	return r
 }
@@ -0,0 +1,267 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kim-company/pmux/pwrap"
+)
+
+// TestMain moves the root directory away from the one of the host, so that the
+// routers of the tests do not reconcile, watch or reap live sessions.
+func TestMain(m *testing.M) {
+	root, err := ioutil.TempDir("", "pmuxapi-test")
+	if err != nil {
+		panic(err)
+	}
+	rootDir = root
+	code := m.Run()
+	os.RemoveAll(root)
+	os.Exit(code)
+}
+
+func TestParseExecutable(t *testing.T) {
+	t.Parallel()
+
+	name, e, err := ParseExecutable("transcode=/usr/bin/transcoder,--fast,-v")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Executable{Path: "/usr/bin/transcoder", Args: []string{"--fast", "-v"}}
+	if name != "transcode" || !reflect.DeepEqual(e, want) {
+		t.Fatalf("Unexpected executable %q: %+v", name, e)
+	}
+
+	for _, v := range []string{"transcode", "=/usr/bin/transcoder", "transcode=", "transcode=/usr/bin/transcoder,--out={{.SID"} {
+		if _, _, err := ParseExecutable(v); err == nil {
+			t.Fatalf("%q: expected an error", v)
+		}
+	}
+}
+
+func TestSessionHandler_Executable(t *testing.T) {
+	t.Parallel()
+
+	h := &SessionHandler{execs: map[string]Executable{"transcode": {Path: "transcoder", Args: []string{"--fast"}}}}
+	name, args, err := h.executable(&createRequest{Exec: "transcode", ExtraArgs: []string{"--out={{.SID}}.mp4"}}, "yes", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"--fast", "--out={{.SID}}.mp4"}; name != "transcoder" || !reflect.DeepEqual(args, want) {
+		t.Fatalf("Extra arguments SHOULD follow those of the executable: %s %q", name, args)
+	}
+	if e := h.execs["transcode"]; len(e.Args) != 1 {
+		t.Fatalf("The arguments of the executable SHOULD NOT be modified: %q", e.Args)
+	}
+	if _, args, err := h.executable(&createRequest{ExtraArgs: []string{"-v"}}, "yes", []string{"y"}); err != nil || !reflect.DeepEqual(args, []string{"y", "-v"}) {
+		t.Fatalf("Extra arguments SHOULD apply to the default executable: %q, %v", args, err)
+	}
+	for _, v := range []string{"--config=/etc/passwd", "--socket-path", "{{.SID"} {
+		if _, _, err := h.executable(&createRequest{ExtraArgs: []string{v}}, "yes", nil); err == nil {
+			t.Fatalf("Extra argument %q SHOULD be rejected", v)
+		}
+	}
+
+	h.secrets = []string{"env:"}
+	c := &createRequest{Env: map[string]string{"TOKEN": "x"}, Secrets: map[string]string{"TOKEN": "env:TOKEN"}}
+	if _, err := h.sessionOptions(c); err == nil {
+		t.Fatalf("Variables set both as env and as secrets SHOULD be rejected")
+	}
+}
+
+func TestSessionHandler_CheckContainer(t *testing.T) {
+	t.Parallel()
+
+	h := &SessionHandler{images: []string{"alpine"}, mounts: []string{"/data"}}
+	tt := []struct {
+		c  pwrap.Container
+		ok bool
+	}{
+		{pwrap.Container{Image: "alpine"}, true},
+		{pwrap.Container{Image: "alpine", Mounts: []string{"/data/:/data:ro"}}, true},
+		{pwrap.Container{Image: "ubuntu"}, false},
+		{pwrap.Container{Image: "alpine", Mounts: []string{"/etc:/data"}}, false},
+		{pwrap.Container{Image: "alpine", Mounts: []string{"/data"}}, false},
+	}
+	for i, v := range tt {
+		if err := h.checkContainer(&v.c); (err == nil) != v.ok {
+			t.Fatalf("%d: unexpected error: %v", i, err)
+		}
+	}
+}
+
+func TestSessionHandler_JobFor(t *testing.T) {
+	t.Parallel()
+
+	h := &SessionHandler{images: []string{"registry/other:1"}}
+	if _, err := h.jobFor(&pwrap.Kubernetes{}); err == nil {
+		t.Fatal("expected an error when kubernetes is not enabled")
+	}
+
+	h.kube = &pwrap.Kubernetes{Namespace: "batch", Image: "registry/job:1", ServiceAccount: "pmux"}
+	k, err := h.jobFor(&pwrap.Kubernetes{Namespace: "kube-system", Image: "registry/other:1", CPU: "500m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := pwrap.Kubernetes{Namespace: "batch", Image: "registry/other:1", ServiceAccount: "pmux", CPU: "500m"}
+	if *k != want {
+		t.Fatalf("unexpected job: %+v", k)
+	}
+	if _, err := h.jobFor(&pwrap.Kubernetes{Image: "registry/evil:1"}); err == nil {
+		t.Fatal("expected an error for an image not allowed")
+	}
+}
+
+func TestRouter_CORS(t *testing.T) {
+	t.Parallel()
+
+	r := NewRouter("yes", CORS([]string{"https://dashboard.example"}, nil), APIKeys("key"))
+
+	req := httptest.NewRequest("OPTIONS", "/api/v1/sessions", nil)
+	req.Header.Set("Origin", "https://dashboard.example")
+	req.Header.Set("Access-Control-Request-Method", "DELETE")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Preflight: unexpected status %d", w.Code)
+	}
+	if v := w.Header().Get("Access-Control-Allow-Methods"); v != "GET, POST, PUT, DELETE" {
+		t.Fatalf("Preflight: unexpected allowed methods %q", v)
+	}
+
+	req = httptest.NewRequest("GET", "/health_check", nil)
+	req.Header.Set("Origin", "https://evil.example")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if v := w.Header().Get("Access-Control-Allow-Origin"); v != "" {
+		t.Fatalf("Unexpected allowed origin %q", v)
+	}
+
+	req = httptest.NewRequest("GET", "/health_check", nil)
+	req.Header.Set("Origin", "https://dashboard.example")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if v := w.Header().Get("Access-Control-Allow-Origin"); v != "https://dashboard.example" {
+		t.Fatalf("Unexpected allowed origin %q", v)
+	}
+}
+
+func TestRouter_Drain(t *testing.T) {
+	t.Parallel()
+
+	r := NewRouter("yes")
+	req := httptest.NewRequest("POST", "/api/v1/drain", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Drain: unexpected status %d", w.Code)
+	}
+	select {
+	case <-r.Draining():
+	default:
+		t.Fatal("Router is not draining")
+	}
+
+	for _, v := range []struct{ method, path string }{
+		{"GET", "/health_check"},
+		{"POST", "/api/v1/sessions"},
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(v.method, v.path, nil))
+		if w.Code != http.StatusServiceUnavailable {
+			t.Fatalf("%s %s: unexpected status %d", v.method, v.path, w.Code)
+		}
+	}
+}
+
+func TestSessionHandler_QuotaFor(t *testing.T) {
+	t.Parallel()
+
+	h := &SessionHandler{}
+	if q, err := h.quotaFor(nil); err != nil || q != nil {
+		t.Fatalf("unexpected quota: %+v, %v", q, err)
+	}
+
+	h.quota = &pwrap.Quota{Bytes: 1 << 30, Action: pwrap.QuotaStop}
+	q, err := h.quotaFor(nil)
+	if err != nil || q != h.quota {
+		t.Fatalf("unexpected default quota: %+v, %v", q, err)
+	}
+	q, err = h.quotaFor(&pwrap.Quota{Bytes: 1 << 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (pwrap.Quota{Bytes: 1 << 20, Action: pwrap.QuotaStop}); *q != want {
+		t.Fatalf("unexpected quota: %+v", q)
+	}
+	if _, err := h.quotaFor(&pwrap.Quota{Bytes: 2 << 30}); err == nil {
+		t.Fatal("expected an error for a quota above the server's")
+	}
+	if _, err := h.quotaFor(&pwrap.Quota{Bytes: 1 << 20, Action: pwrap.QuotaWarn}); err == nil {
+		t.Fatal("expected an error for a relaxed action")
+	}
+}
+
+func TestRouter_SecretRefs(t *testing.T) {
+	t.Parallel()
+
+	r := NewRouter("yes", Execs(map[string]Executable{"true": {Path: "true"}}), SecretRefs("file:/run/secrets/", "env:CI_"))
+	if err := r.h.checkSecrets(map[string]string{"X": "env:CI_TOKEN", "Y": "file:/run/secrets/token"}); err != nil {
+		t.Fatalf("Allowed references SHOULD be accepted: %v", err)
+	}
+	for _, ref := range []string{
+		"file:/etc/shadow",
+		"file:/run/secrets/../../etc/shadow",
+		"env:VAULT_TOKEN",
+		"vault:secret/data/db#password",
+	} {
+		body := `{"exec": "true", "config": {}, "secrets": {"X": "` + ref + `"}}`
+		req := httptest.NewRequest("POST", "/api/v1/sessions", strings.NewReader(body))
+		req.Header.Set(NamespaceHeader, "secrets-test")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("%s: wanted status %d, found %d: %s", ref, http.StatusBadRequest, w.Code, w.Body)
+		}
+	}
+}
+
+func TestRouter_DryRun(t *testing.T) {
+	t.Parallel()
+
+	// yes rejects the flags of the wrapper, while true ignores them.
+	r := NewRouter("yes", Execs(map[string]Executable{"true": {Path: "true"}}))
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(NamespaceHeader, "dryrun-test")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	for _, tt := range []struct {
+		body   string
+		status int
+	}{
+		{`{"exec": "true", "config": {}}`, http.StatusNoContent},
+		{`{"exec": "true", "config": {}, "cron": "0 0 1 1 *"}`, http.StatusNoContent},
+		{`{"config": {}}`, http.StatusUnprocessableEntity},
+		{`{"exec": "missing", "config": {}}`, http.StatusBadRequest},
+	} {
+		if w := do("POST", "/api/v1/sessions?dry_run=true", tt.body); w.Code != tt.status {
+			t.Fatalf("%s: wanted status %d, found %d: %s", tt.body, tt.status, w.Code, w.Body)
+		}
+	}
+	w := do("GET", "/api/v1/sessions", "")
+	if body := strings.TrimSpace(w.Body.String()); body != "[]" {
+		t.Fatalf("Dry runs SHOULD NOT create sessions: %s", body)
+	}
+}
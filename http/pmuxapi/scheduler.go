@@ -0,0 +1,280 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/kim-company/pmux/pwrap"
+)
+
+// schedulerInterval is the time waited before checking again whether queued
+// sessions can be started, as sessions exit without notifying the server.
+const schedulerInterval = time.Second
+
+// scheduler starts queued sessions as soon as the number of running sessions
+// drops below "max", higher-priority sessions first. When "preempt" is set,
+// running sessions started by the scheduler are stopped and queued again to
+// make room for queued sessions of higher priority.
+type scheduler struct {
+	max     int
+	preempt bool
+	// interval is the time between two checks of the running sessions
+	// when not notified of exits.
+	interval time.Duration
+	// running returns the number of running sessions, while start starts one.
+	running func() int
+	start   func(*pwrap.PWrap) (string, error)
+	// alive reports whether a session is still running, while stop stops one
+	// to preempt it.
+	alive func(*pwrap.PWrap) bool
+	stop  func(*pwrap.PWrap) error
+
+	sync.Mutex
+	queue []*queued
+	// started lists the running sessions started by the scheduler, the
+	// candidates for preemption, in start order.
+	started []*queued
+	// stopping is set while a session is being preempted.
+	stopping bool
+	wake     chan struct{}
+}
+
+// queued is a session handled by the scheduler.
+type queued struct {
+	pw       *pwrap.PWrap
+	priority int
+}
+
+func newScheduler(max int, preempt bool, grace, interval time.Duration) *scheduler {
+	s := &scheduler{
+		max:      max,
+		preempt:  preempt,
+		interval: interval,
+		running:  runningSessions,
+		start:    startSession,
+		alive:    (*pwrap.PWrap).Running,
+		stop: func(pw *pwrap.PWrap) error {
+			// A fresh wrapper is used, as "pw" may be in use elsewhere.
+			k, err := pwrap.New(pwrap.OverrideSID(pw.SID()), pwrap.RootDir(rootDir), pwrap.GracePeriod(grace))
+			if err != nil {
+				return err
+			}
+			return k.KillSession()
+		},
+		wake: make(chan struct{}, 1),
+	}
+	go s.loop()
+	return s
+}
+
+// setMax changes the maximum number of running sessions to "max", zero meaning
+// no limit, and schedules the queued sessions that fit.
+func (s *scheduler) setMax(max int) {
+	s.Lock()
+	s.max = max
+	s.Unlock()
+	s.notify()
+}
+
+// enqueue marks the session of "pw" as queued and schedules its start.
+func (s *scheduler) enqueue(pw *pwrap.PWrap) error {
+	var priority int
+	if err := pw.UpdateSession(func(s *pwrap.Session) {
+		s.State = pwrap.SessionQueued
+		priority = s.Priority
+	}); err != nil {
+		return err
+	}
+	s.Lock()
+	s.insert(&queued{pw: pw, priority: priority}, false)
+	s.Unlock()
+	s.notify()
+	return nil
+}
+
+// insert adds "q" to the queue after the sessions of higher priority, and after
+// those of the same priority unless "first" is set. Must be called with the lock
+// held.
+func (s *scheduler) insert(q *queued, first bool) {
+	i := 0
+	for ; i < len(s.queue); i++ {
+		if p := s.queue[i].priority; p < q.priority || (first && p == q.priority) {
+			break
+		}
+	}
+	s.queue = append(s.queue, nil)
+	copy(s.queue[i+1:], s.queue[i:])
+	s.queue[i] = q
+}
+
+// remove drops session "sid" from the queue, reporting whether it was queued.
+func (s *scheduler) remove(sid string) bool {
+	s.Lock()
+	defer s.Unlock()
+	for i, v := range s.started {
+		if v.pw.SID() == sid {
+			s.started = append(s.started[:i], s.started[i+1:]...)
+			break
+		}
+	}
+	for i, v := range s.queue {
+		if v.pw.SID() == sid {
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (s *scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *scheduler) loop() {
+	t := time.NewTicker(s.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-s.wake:
+		case <-t.C:
+		}
+		s.schedule()
+	}
+}
+
+// schedule starts queued sessions while there are free slots, then preempts a
+// running session if the first queued one outranks it.
+func (s *scheduler) schedule() {
+	s.Lock()
+	defer s.Unlock()
+	if len(s.queue) == 0 {
+		return
+	}
+	free := len(s.queue)
+	if s.max > 0 {
+		free = s.max - s.running()
+	}
+	for ; free > 0 && len(s.queue) > 0; free-- {
+		q := s.queue[0]
+		s.queue = s.queue[1:]
+		log.Printf("[INFO] Starting queued session %v, working dir: %v", q.pw.SID(), q.pw.WorkDir())
+		if _, err := s.start(q.pw); err != nil {
+			log.Printf("[ERROR] unable to start queued session %s: %v", q.pw.SID(), err)
+			continue
+		}
+		if s.preempt {
+			s.started = append(s.started, q)
+		}
+	}
+	if !s.preempt || s.stopping || len(s.queue) == 0 {
+		return
+	}
+	if v := s.victim(s.queue[0].priority); v != nil {
+		s.stopping = true
+		go s.preemptSession(v)
+	}
+}
+
+// victim removes from the started sessions and returns the one to preempt in
+// favour of a session of priority "priority": the most recently started among
+// those of lowest priority, nil if none has a lower priority. Must be called
+// with the lock held.
+func (s *scheduler) victim(priority int) *queued {
+	var v *queued
+	started := s.started[:0]
+	for _, q := range s.started {
+		if !s.alive(q.pw) {
+			continue
+		}
+		started = append(started, q)
+		if q.priority < priority && (v == nil || q.priority <= v.priority) {
+			v = q
+		}
+	}
+	s.started = started
+	if v == nil {
+		return nil
+	}
+	for i, q := range s.started {
+		if q == v {
+			s.started = append(s.started[:i], s.started[i+1:]...)
+			break
+		}
+	}
+	return v
+}
+
+// preemptSession stops the session of "q" and queues it again, ahead of the
+// sessions of the same priority. It is started from scratch once its turn comes.
+func (s *scheduler) preemptSession(q *queued) {
+	log.Printf("[INFO] Preempting session %s of priority %d", q.pw.SID(), q.priority)
+	err := s.stop(q.pw)
+	if err == nil {
+		// The wrapper recorded the termination of the child, which
+		// has to be overwritten as the session is not finished.
+		err = q.pw.UpdateSession(func(s *pwrap.Session) {
+			s.State = pwrap.SessionQueued
+			s.FinishedAt = nil
+			s.Error = "preempted by a session of higher priority"
+		})
+	}
+	s.Lock()
+	s.stopping = false
+	if err != nil {
+		log.Printf("[ERROR] unable to preempt session %s: %v", q.pw.SID(), err)
+	} else {
+		s.insert(q, true)
+	}
+	s.Unlock()
+	s.notify()
+}
+
+// startSession starts the session of "pw", recording the failure in its state
+// if that is not possible. Sessions that already ran, i.e. preempted ones, are
+// restarted.
+func startSession(pw *pwrap.PWrap) (string, error) {
+	var started bool
+	pw.UpdateSession(func(s *pwrap.Session) {
+		s.State = pwrap.SessionCreated
+		started = s.StartedAt != nil
+	})
+	start := pw.StartSession
+	if started {
+		start = pw.Restart
+	}
+	sid, err := start()
+	if err != nil {
+		pw.UpdateSession(func(s *pwrap.Session) {
+			s.State = pwrap.SessionFailed
+			s.Error = err.Error()
+		})
+	}
+	return sid, err
+}
+
+// runningSessions returns the number of running sessions that belong to this
+// server, i.e. that have a working directory inside the root directory.
+func runningSessions() int {
+	sids, err := pwrap.ListSessions(rootDir)
+	if err != nil {
+		log.Printf("[WARN] unable to count running sessions: %v", err)
+		return 0
+	}
+	n := 0
+	for _, v := range sids {
+		if _, err := os.Stat(filepath.Join(rootDir, v)); err == nil {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,181 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/kim-company/pmux/pwrap"
+)
+
+func TestScheduler_Schedule(t *testing.T) {
+	t.Parallel()
+
+	running := 0
+	s := &scheduler{
+		max:     2,
+		running: func() int { return running },
+		start: func(pw *pwrap.PWrap) (string, error) {
+			running++
+			return pw.SID(), nil
+		},
+		wake: make(chan struct{}, 1),
+	}
+
+	var sids []string
+	for i := 0; i < 3; i++ {
+		pw, err := pwrap.New(pwrap.RootDir(os.TempDir()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(pw.WorkDir())
+		if err := s.enqueue(pw); err != nil {
+			t.Fatal(err)
+		}
+		st, err := pw.ReadSession()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st.State != pwrap.SessionQueued {
+			t.Fatalf("Unexpected state %q", st.State)
+		}
+		sids = append(sids, pw.SID())
+	}
+
+	s.schedule()
+	if running != 2 || len(s.queue) != 1 {
+		t.Fatalf("Unexpected scheduling: running %d, queued %d", running, len(s.queue))
+	}
+	s.schedule()
+	if running != 2 {
+		t.Fatalf("Scheduler SHOULD NOT exceed its limit: running %d", running)
+	}
+
+	if !s.remove(sids[2]) || len(s.queue) != 0 {
+		t.Fatalf("Unable to remove queued session")
+	}
+}
+
+// queuedSession returns a session of priority "priority" inside the temporary
+// directory.
+func queuedSession(t *testing.T, priority int) *pwrap.PWrap {
+	pw, err := pwrap.New(pwrap.RootDir(os.TempDir()), pwrap.Priority(priority))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pw
+}
+
+func TestScheduler_Priority(t *testing.T) {
+	t.Parallel()
+
+	var started []string
+	s := &scheduler{
+		max:     1,
+		running: func() int { return len(started) },
+		start: func(pw *pwrap.PWrap) (string, error) {
+			started = append(started, pw.SID())
+			return pw.SID(), nil
+		},
+		wake: make(chan struct{}, 1),
+	}
+
+	var sids []string
+	for _, v := range []int{0, 5, 0, 10} {
+		pw := queuedSession(t, v)
+		defer os.RemoveAll(pw.WorkDir())
+		if err := s.enqueue(pw); err != nil {
+			t.Fatal(err)
+		}
+		sids = append(sids, pw.SID())
+	}
+	for _, v := range []string{sids[3], sids[1], sids[0], sids[2]} {
+		s.schedule()
+		if last := started[len(started)-1]; last != v {
+			t.Fatalf("Wanted %s to be started, found %s", v, last)
+		}
+		started = started[:0]
+	}
+}
+
+func TestScheduler_Preempt(t *testing.T) {
+	t.Parallel()
+
+	alive := map[string]bool{}
+	stopped := make(chan string, 1)
+	s := &scheduler{
+		max:     2,
+		preempt: true,
+		running: func() int { return len(alive) },
+		start: func(pw *pwrap.PWrap) (string, error) {
+			alive[pw.SID()] = true
+			return pw.SID(), nil
+		},
+		alive: func(pw *pwrap.PWrap) bool { return alive[pw.SID()] },
+		stop: func(pw *pwrap.PWrap) error {
+			stopped <- pw.SID()
+			return nil
+		},
+		wake: make(chan struct{}, 1),
+	}
+
+	low, lower := queuedSession(t, 1), queuedSession(t, 0)
+	defer os.RemoveAll(low.WorkDir())
+	defer os.RemoveAll(lower.WorkDir())
+	for _, v := range []*pwrap.PWrap{lower, low} {
+		if err := s.enqueue(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.schedule()
+	if len(alive) != 2 {
+		t.Fatalf("Unexpected scheduling: running %d", len(alive))
+	}
+
+	same := queuedSession(t, 1)
+	defer os.RemoveAll(same.WorkDir())
+	if err := s.enqueue(same); err != nil {
+		t.Fatal(err)
+	}
+	s.schedule()
+	sid := <-stopped
+	if sid != lower.SID() {
+		t.Fatalf("The session of lowest priority SHOULD be preempted, found %s", sid)
+	}
+
+	s.Lock()
+	delete(alive, sid)
+	s.Unlock()
+	for i := 0; i < 100; i++ {
+		s.Lock()
+		n := len(s.queue)
+		s.Unlock()
+		if n == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	s.schedule()
+	if !alive[same.SID()] || len(s.queue) != 1 || s.queue[0].pw.SID() != lower.SID() {
+		t.Fatalf("The preempted session SHOULD be queued again")
+	}
+	st, err := lower.ReadSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.State != pwrap.SessionQueued {
+		t.Fatalf("Unexpected state %q", st.State)
+	}
+
+	// Sessions never preempt those of higher or equal priority.
+	s.schedule()
+	select {
+	case sid := <-stopped:
+		t.Fatalf("Session %s SHOULD NOT be preempted", sid)
+	default:
+	}
+}
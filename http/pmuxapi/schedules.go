@@ -0,0 +1,360 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/kim-company/pmux/cron"
+	"github.com/kim-company/pmux/pwrap"
+)
+
+// SchedulesFile is the name of the file, inside the root directory, where the
+// sessions scheduled for later are persisted.
+const SchedulesFile = "schedules.json"
+
+// ScheduleLabel is the label attached to the sessions created by a schedule,
+// set to its identifier.
+const ScheduleLabel = "pmux.schedule"
+
+// timetableInterval is the time between two searches of the schedules due.
+const timetableInterval = time.Second
+
+// Schedule creates sessions at a later time, once at "StartAt" or every time
+// "Cron" fires.
+type Schedule struct {
+	ID        string     `json:"id"`
+	Namespace string     `json:"namespace,omitempty"`
+	StartAt   *time.Time `json:"start_at,omitempty"`
+	Cron      string     `json:"cron,omitempty"`
+	// Request is the create payload of the sessions.
+	Request   json.RawMessage `json:"request"`
+	CreatedAt time.Time       `json:"created_at"`
+	// NextRun is the time the next session is created at, nil if the
+	// expression never fires again.
+	NextRun *time.Time `json:"next_run,omitempty"`
+	LastRun *time.Time `json:"last_run,omitempty"`
+	Runs    int        `json:"runs"`
+	// LastSID is the session created by the last run, while LastError
+	// reports why it could not be created.
+	LastSID   string `json:"last_sid,omitempty"`
+	LastError string `json:"last_error,omitempty"`
+}
+
+// next computes the run following "now".
+func (s *Schedule) next(now time.Time) error {
+	if s.Cron == "" {
+		s.NextRun = s.StartAt
+		return nil
+	}
+	c, err := cron.Parse(s.Cron)
+	if err != nil {
+		return err
+	}
+	s.NextRun = nil
+	if t := c.Next(now); !t.IsZero() {
+		s.NextRun = &t
+	}
+	return nil
+}
+
+// timetable keeps the schedules, persisting them in a JSON file which is
+// rewritten atomically on every change.
+type timetable struct {
+	path string
+
+	sync.Mutex
+	items map[string]*Schedule
+}
+
+// loadTimetable returns the timetable backed by the file at "path", loading the
+// schedules it contains, if any. Runs of recurring schedules missed in the
+// meantime are skipped, while one-off schedules that are overdue run as soon as
+// possible.
+func loadTimetable(path string, now time.Time) (*timetable, error) {
+	t := &timetable{path: path, items: map[string]*Schedule{}}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("unable to read schedules: %w", err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &t.items); err != nil {
+			return nil, fmt.Errorf("unable to decode schedules: %w", err)
+		}
+	}
+	for _, v := range t.items {
+		if v.Cron != "" && v.NextRun != nil && v.NextRun.Before(now) {
+			if err := v.next(now); err != nil {
+				log.Printf("[WARN] schedule %s: %v", v.ID, err)
+			}
+		}
+	}
+	return t, nil
+}
+
+// add stores "s", assigning it an identifier and its first run.
+func (t *timetable) add(s *Schedule, now time.Time) error {
+	s.ID = "pmux-schedule-" + uuid.New().String()
+	s.CreatedAt = now
+	if err := s.next(now); err != nil {
+		return err
+	}
+	t.Lock()
+	defer t.Unlock()
+	c := *s
+	t.items[s.ID] = &c
+	return t.flush()
+}
+
+func (t *timetable) get(id string) (*Schedule, error) {
+	t.Lock()
+	defer t.Unlock()
+	s, ok := t.items[id]
+	if !ok {
+		return nil, fmt.Errorf("schedule %s: %w", id, os.ErrNotExist)
+	}
+	c := *s
+	return &c, nil
+}
+
+// list returns the schedules of namespace "ns", sorted by creation time.
+func (t *timetable) list(ns string) []*Schedule {
+	t.Lock()
+	defer t.Unlock()
+	acc := []*Schedule{}
+	for _, v := range t.items {
+		if v.Namespace == ns {
+			c := *v
+			acc = append(acc, &c)
+		}
+	}
+	sort.Slice(acc, func(i, j int) bool { return acc[i].CreatedAt.Before(acc[j].CreatedAt) })
+	return acc
+}
+
+func (t *timetable) remove(id string) error {
+	t.Lock()
+	defer t.Unlock()
+	if _, ok := t.items[id]; !ok {
+		return fmt.Errorf("schedule %s: %w", id, os.ErrNotExist)
+	}
+	delete(t.items, id)
+	return t.flush()
+}
+
+// due returns the schedules whose run is due at "now", moving them to their next
+// run beforehand, so that a run is never repeated. One-off schedules are removed.
+func (t *timetable) due(now time.Time) []*Schedule {
+	t.Lock()
+	defer t.Unlock()
+	var acc []*Schedule
+	for id, v := range t.items {
+		if v.NextRun == nil || v.NextRun.After(now) {
+			continue
+		}
+		c := *v
+		acc = append(acc, &c)
+		if v.Cron == "" {
+			delete(t.items, id)
+		} else if err := v.next(now); err != nil {
+			log.Printf("[WARN] schedule %s: %v", id, err)
+		}
+	}
+	if len(acc) > 0 {
+		if err := t.flush(); err != nil {
+			log.Printf("[WARN] %v", err)
+		}
+	}
+	sort.Slice(acc, func(i, j int) bool { return acc[i].NextRun.Before(*acc[j].NextRun) })
+	return acc
+}
+
+// ran records the outcome of the run of schedule "id" performed at "now", which
+// created session "sid" unless "err" is set.
+func (t *timetable) ran(id string, now time.Time, sid string, err error) {
+	t.Lock()
+	defer t.Unlock()
+	s, ok := t.items[id]
+	if !ok {
+		return
+	}
+	s.LastRun = &now
+	s.Runs++
+	s.LastSID, s.LastError = sid, ""
+	if err != nil {
+		s.LastError = err.Error()
+	}
+	if err := t.flush(); err != nil {
+		log.Printf("[WARN] %v", err)
+	}
+}
+
+// flush writes the schedules to disk. Must be called with the lock held.
+func (t *timetable) flush() error {
+	if err := os.MkdirAll(filepath.Dir(t.path), os.ModePerm); err != nil {
+		return fmt.Errorf("unable to store schedules: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(t.path), "."+filepath.Base(t.path)+"-*")
+	if err != nil {
+		return fmt.Errorf("unable to store schedules: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := json.NewEncoder(tmp).Encode(t.items); err != nil {
+		tmp.Close()
+		return fmt.Errorf("unable to encode schedules: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("unable to store schedules: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), t.path); err != nil {
+		return fmt.Errorf("unable to store schedules: %w", err)
+	}
+	return nil
+}
+
+// scheduleSession validates "c" and schedules the creation of the session it
+// describes, in namespace "ns". "name" and "args" are the executable run by
+// default.
+func (h *SessionHandler) scheduleSession(c *createRequest, name string, args []string, ns string) (*Schedule, error) {
+	if h.timetable == nil {
+		return nil, fmt.Errorf("scheduling sessions is not available")
+	}
+	if c.StartAt != nil && c.Cron != "" {
+		return nil, fmt.Errorf("start_at and cron cannot be both set")
+	}
+	if c.rawConfig != nil {
+		return nil, fmt.Errorf("sessions with an uploaded configuration cannot be scheduled")
+	}
+	if c.Name != "" && c.Cron != "" {
+		return nil, fmt.Errorf("recurring sessions cannot be named")
+	}
+	if _, _, err := h.executable(c, name, args); err != nil {
+		return nil, err
+	}
+	if _, err := h.sessionOptions(c); err != nil {
+		return nil, err
+	}
+	s := &Schedule{Namespace: ns, StartAt: c.StartAt, Cron: c.Cron}
+	req := *c
+	req.StartAt, req.Cron = nil, ""
+	data, err := json.Marshal(&req)
+	if err != nil {
+		return nil, err
+	}
+	s.Request = data
+	if err := h.timetable.add(s, time.Now()); err != nil {
+		return nil, err
+	}
+	log.Printf("[INFO] Session scheduled by %s, next run at %v", s.ID, s.NextRun)
+	return s, nil
+}
+
+// fire creates the session of schedule "s", labelled with its identifier.
+func (h *SessionHandler) fire(s *Schedule, name string, args []string, now time.Time) {
+	var c createRequest
+	err := json.Unmarshal(s.Request, &c)
+	var sid string
+	switch {
+	case err != nil:
+	case h.drain.draining():
+		err = fmt.Errorf("server is draining, new sessions are not accepted")
+	default:
+		if c.Labels == nil {
+			c.Labels = map[string]string{}
+		}
+		c.Labels[ScheduleLabel] = s.ID
+		var pw *pwrap.PWrap
+		if pw, _, err = h.createSession(context.Background(), &c, name, args, s.Namespace); err == nil {
+			sid = pw.SID()
+			h.record(pw)
+			st, _ := pw.ReadSession()
+			h.notify(pwrap.EventCreated, sid, st)
+		}
+	}
+	if err != nil {
+		log.Printf("[ERROR] schedule %s: unable to create session: %v", s.ID, err)
+	} else {
+		log.Printf("[INFO] Session %s created by %s", sid, s.ID)
+	}
+	h.timetable.ran(s.ID, now, sid, err)
+}
+
+// fireEvery creates the sessions of the schedules due every "interval", forever.
+func (h *SessionHandler) fireEvery(interval time.Duration, name string, args []string) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for now := range t.C {
+		for _, v := range h.timetable.due(now) {
+			h.fire(v, name, args, now)
+		}
+	}
+}
+
+// HandleListSchedules returns the schedules of the namespace of the request.
+func (h *SessionHandler) HandleListSchedules() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.timetable == nil {
+			h.writeResponse(w, []*Schedule{})
+			return
+		}
+		h.writeResponse(w, h.timetable.list(NamespaceFromContext(r.Context())))
+	}
+}
+
+// schedule returns the schedule selected by the request, which is reported as not
+// existing if it belongs to another namespace.
+func (h *SessionHandler) schedule(r *http.Request) (*Schedule, error) {
+	id := mux.Vars(r)["id"]
+	if h.timetable == nil {
+		return nil, fmt.Errorf("schedule %s: %w", id, os.ErrNotExist)
+	}
+	s, err := h.timetable.get(id)
+	if err != nil {
+		return nil, err
+	}
+	if s.Namespace != NamespaceFromContext(r.Context()) {
+		return nil, fmt.Errorf("schedule %s: %w", id, os.ErrNotExist)
+	}
+	return s, nil
+}
+
+func (h *SessionHandler) HandleShowSchedule() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s, err := h.schedule(r)
+		if err != nil {
+			h.writeSessionError(w, err)
+			return
+		}
+		h.writeResponse(w, s)
+	}
+}
+
+// HandleDeleteSchedule cancels the future runs of a schedule, returning it.
+// Sessions already created are not affected.
+func (h *SessionHandler) HandleDeleteSchedule() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s, err := h.schedule(r)
+		if err == nil {
+			err = h.timetable.remove(s.ID)
+		}
+		if err != nil {
+			h.writeSessionError(w, err)
+			return
+		}
+		log.Printf("[INFO] Schedule %s deleted", s.ID)
+		h.writeResponse(w, s)
+	}
+}
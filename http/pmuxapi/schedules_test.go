@@ -0,0 +1,159 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimetable(t *testing.T) {
+	t.Parallel()
+
+	dir, err := os.MkdirTemp("", "pmux-timetable-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, SchedulesFile)
+	tt, err := loadTimetable(path, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2020, time.January, 8, 15, 24, 23, 0, time.UTC)
+	at := now.Add(time.Hour)
+	once := &Schedule{StartAt: &at}
+	every := &Schedule{Cron: "*/30 * * * *", Namespace: "video"}
+	for _, v := range []*Schedule{once, every} {
+		if err := tt.add(v, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tt.add(&Schedule{Cron: "* * *"}, now); err == nil {
+		t.Fatal("invalid cron expressions SHOULD be refused")
+	}
+	if n := len(tt.list("video")); n != 1 {
+		t.Fatalf("Wanted 1 schedule in the namespace, found %d", n)
+	}
+
+	due := tt.due(now.Add(time.Minute * 10))
+	if len(due) != 1 || due[0].ID != every.ID {
+		t.Fatalf("Only the cron schedule SHOULD be due, found %d", len(due))
+	}
+	tt.ran(every.ID, now, "pmux-a", nil)
+	s, err := tt.get(every.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Runs != 1 || s.LastSID != "pmux-a" || !s.NextRun.Equal(time.Date(2020, time.January, 8, 16, 0, 0, 0, time.UTC)) {
+		t.Fatalf("Unexpected schedule after its run: %+v", s)
+	}
+
+	// Due schedules are sorted by run time.
+	if due = tt.due(at); len(due) != 2 || due[0].ID != every.ID || due[1].ID != once.ID {
+		t.Fatalf("Both schedules SHOULD be due, found %d", len(due))
+	}
+	if _, err := tt.get(once.ID); err == nil {
+		t.Fatal("one-off schedules SHOULD be removed once due")
+	}
+
+	// Runs missed while the server was down are skipped.
+	later := now.Add(time.Hour * 24)
+	if tt, err = loadTimetable(path, later); err != nil {
+		t.Fatal(err)
+	}
+	if s, err = tt.get(every.ID); err != nil {
+		t.Fatal(err)
+	}
+	if !s.NextRun.After(later) {
+		t.Fatalf("Missed run %v SHOULD be skipped", s.NextRun)
+	}
+}
+
+func TestSessionHandler_Fire(t *testing.T) {
+	t.Parallel()
+
+	dir, err := os.MkdirTemp("", "pmux-timetable-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tt, err := loadTimetable(filepath.Join(dir, SchedulesFile), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Schedule{Cron: "@daily", Request: json.RawMessage(`{"config": {}}`)}
+	if err := tt.add(s, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	h := &SessionHandler{drain: newDrainer(), timetable: tt}
+	h.drain.start()
+	h.fire(s, "yes", nil, time.Now())
+	if s, err = tt.get(s.ID); err != nil {
+		t.Fatal(err)
+	}
+	if s.Runs != 1 || s.LastSID != "" || s.LastError == "" {
+		t.Fatalf("Runs of a draining server SHOULD fail: %+v", s)
+	}
+}
+
+func TestRouter_Schedules(t *testing.T) {
+	t.Parallel()
+
+	r := NewRouter("yes")
+	do := func(method, path, body, ns string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if ns != "" {
+			req.Header.Set(NamespaceHeader, ns)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, v := range []string{
+		`{"config": {}, "cron": "0 0 * *"}`,
+		`{"config": {}, "cron": "0 0 1 1 *", "start_at": "2020-01-08T15:24:23Z"}`,
+		`{"config": {}, "cron": "0 0 1 1 *", "exec": "missing"}`,
+	} {
+		if w := do("POST", "/api/v1/sessions", v, ""); w.Code != http.StatusBadRequest {
+			t.Fatalf("%s: wanted status %d, found %d", v, http.StatusBadRequest, w.Code)
+		}
+	}
+
+	w := do("POST", "/api/v1/sessions", `{"config": {}, "cron": "0 0 1 1 *"}`, "schedules-test")
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Wanted status %d, found %d: %s", http.StatusAccepted, w.Code, w.Body)
+	}
+	var s Schedule
+	if err := json.NewDecoder(w.Body).Decode(&s); err != nil {
+		t.Fatal(err)
+	}
+	path := "/api/v1/schedules/" + s.ID
+	if w := do("GET", path, "", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("Schedules of other namespaces SHOULD NOT be found, status %d", w.Code)
+	}
+	var list []*Schedule
+	if err := json.NewDecoder(do("GET", "/api/v1/schedules", "", "schedules-test").Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].ID != s.ID || list[0].NextRun == nil {
+		t.Fatalf("Unexpected schedules: %+v", list)
+	}
+	if w := do("DELETE", path, "", "schedules-test"); w.Code != http.StatusOK {
+		t.Fatalf("Unable to delete schedule, status %d", w.Code)
+	}
+	if w := do("GET", path, "", "schedules-test"); w.Code != http.StatusNotFound {
+		t.Fatalf("Deleted schedule SHOULD NOT be found, status %d", w.Code)
+	}
+}
@@ -0,0 +1,41 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"fmt"
+
+	"github.com/kim-company/pmux/pwrap"
+)
+
+// Sidecars sets the auxiliary commands that sessions may select by name, using
+// the "sidecars" field, to be started alongside their child, e.g. a metrics
+// exporter.
+func Sidecars(s ...pwrap.Sidecar) func(*Router) {
+	return func(r *Router) {
+		r.sidecars = make(map[string]pwrap.Sidecar, len(s))
+		for _, v := range s {
+			r.sidecars[v.Name] = v
+		}
+	}
+}
+
+// sidecarsFor returns the sidecars selected by "names".
+func (h *SessionHandler) sidecarsFor(names []string) ([]pwrap.Sidecar, error) {
+	acc := make([]pwrap.Sidecar, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, v := range names {
+		s, ok := h.sidecars[v]
+		if !ok {
+			return nil, fmt.Errorf("sidecar %q is not allowed", v)
+		}
+		if seen[v] {
+			return nil, fmt.Errorf("duplicate sidecar %s", v)
+		}
+		seen[v] = true
+		acc = append(acc, s)
+	}
+	return acc, nil
+}
@@ -0,0 +1,36 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"testing"
+
+	"github.com/kim-company/pmux/pwrap"
+)
+
+func TestSessionHandler_SidecarsFor(t *testing.T) {
+	t.Parallel()
+
+	h := &SessionHandler{}
+	if s, err := h.sidecarsFor(nil); len(s) != 0 || err != nil {
+		t.Fatalf("sessions SHOULD NOT have sidecars by default: %v, %v", s, err)
+	}
+	if _, err := h.sidecarsFor([]string{"metrics"}); err == nil {
+		t.Fatal("sidecars SHOULD NOT be accepted when none is allowed")
+	}
+	r := &Router{}
+	Sidecars(pwrap.Sidecar{Name: "metrics", Path: "/usr/bin/exporter"})(r)
+	h.sidecars = r.sidecars
+	s, err := h.sidecarsFor([]string{"metrics"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s) != 1 || s[0].Path != "/usr/bin/exporter" {
+		t.Fatalf("unexpected sidecars: %+v", s)
+	}
+	if _, err := h.sidecarsFor([]string{"metrics", "metrics"}); err == nil {
+		t.Fatal("sidecars SHOULD NOT be selected twice")
+	}
+}
@@ -0,0 +1,169 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/kim-company/pmux/pwrap"
+	bolt "go.etcd.io/bbolt"
+)
+
+// ErrNotFound is returned by stores when a session is not recorded.
+var ErrNotFound = errors.New("session not found")
+
+// Store records the sessions created by the server, so that their metadata
+// survives server restarts and the loss of their working directories.
+type Store interface {
+	// Put records "s", replacing any previous record of the same session.
+	Put(s *pwrap.Session) error
+	Get(sid string) (*pwrap.Session, error)
+	// List returns every recorded session, sorted by session identifier.
+	List() ([]*pwrap.Session, error)
+	Delete(sid string) error
+}
+
+// RegistryFile is the name of the database used by the default store, inside
+// the root directory.
+const RegistryFile = "registry.db"
+
+// sessionsBucket is the bucket holding the records of a BoltStore, keyed by
+// session identifier.
+var sessionsBucket = []byte("sessions")
+
+// storeOpenTimeout is the time a BoltStore waits for the database to be
+// released by other processes, e.g. another server sharing the root directory.
+const storeOpenTimeout = time.Second
+
+// BoltStore is a Store backed by a bolt database, which keeps every record as a
+// JSON document.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// NewBoltStore returns a store backed by the bolt database at "path", creating
+// it if needed. The database is locked until the store is closed.
+func NewBoltStore(path string) (*BoltStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return nil, fmt.Errorf("unable to open session registry: %w", err)
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: storeOpenTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("unable to open session registry: %w", err)
+	}
+	if err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(sessionsBucket)
+		return err
+	}); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("unable to open session registry: %w", err)
+	}
+	return &BoltStore{db: db}, nil
+}
+
+// Put records "session", unless its record is the same already.
+func (s *BoltStore) Put(session *pwrap.Session) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("unable to encode session record: %w", err)
+	}
+	key := []byte(session.SID)
+	unchanged := false
+	s.db.View(func(tx *bolt.Tx) error {
+		unchanged = bytes.Equal(tx.Bucket(sessionsBucket).Get(key), data)
+		return nil
+	})
+	if unchanged {
+		return nil
+	}
+	if err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(sessionsBucket).Put(key, data)
+	}); err != nil {
+		return fmt.Errorf("unable to store session record: %w", err)
+	}
+	return nil
+}
+
+func (s *BoltStore) Get(sid string) (*pwrap.Session, error) {
+	r := &pwrap.Session{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(sessionsBucket).Get([]byte(sid))
+		if data == nil {
+			return ErrNotFound
+		}
+		if err := json.Unmarshal(data, r); err != nil {
+			return fmt.Errorf("unable to decode session record: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// List returns the records in key order, which is the order of the session
+// identifiers.
+func (s *BoltStore) List() ([]*pwrap.Session, error) {
+	var acc []*pwrap.Session
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(sessionsBucket).ForEach(func(k, v []byte) error {
+			r := &pwrap.Session{}
+			if err := json.Unmarshal(v, r); err != nil {
+				return fmt.Errorf("unable to decode session record %s: %w", k, err)
+			}
+			acc = append(acc, r)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return acc, nil
+}
+
+func (s *BoltStore) Delete(sid string) error {
+	if err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(sessionsBucket).Delete([]byte(sid))
+	}); err != nil {
+		return fmt.Errorf("unable to delete session record: %w", err)
+	}
+	return nil
+}
+
+// Close releases the database.
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+var (
+	defaultStoresMu sync.Mutex
+	defaultStores   = map[string]*BoltStore{}
+)
+
+// defaultStore returns the store kept in the root directory, which is opened
+// once and shared by the routers of the process, as bolt databases can be
+// opened by a single user at a time.
+func defaultStore() (*BoltStore, error) {
+	defaultStoresMu.Lock()
+	defer defaultStoresMu.Unlock()
+	path := filepath.Join(rootDir, RegistryFile)
+	if s, ok := defaultStores[path]; ok {
+		return s, nil
+	}
+	s, err := NewBoltStore(path)
+	if err != nil {
+		return nil, err
+	}
+	defaultStores[path] = s
+	return s, nil
+}
@@ -0,0 +1,71 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kim-company/pmux/pwrap"
+)
+
+func TestBoltStore(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), RegistryFile)
+	s, err := NewBoltStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sid := range []string{"pmux-b", "pmux-a", "pmux-c"} {
+		if err := s.Put(&pwrap.Session{SID: sid, Exec: "yes", Port: 4000}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Delete("pmux-b"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewBoltStore(path); err == nil {
+		t.Fatal("Databases in use SHOULD NOT be opened again")
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Records have to survive a restart.
+	s, err = NewBoltStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	list, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].SID != "pmux-a" || list[1].SID != "pmux-c" || list[0].Port != 4000 {
+		t.Fatalf("Unexpected records: %+v", list)
+	}
+	if _, err := s.Get("pmux-b"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	now := time.Now().UTC()
+	if err := s.Put(&pwrap.Session{SID: "pmux-a", Exec: "yes", FinishedAt: &now}); err != nil {
+		t.Fatal(err)
+	}
+	if r, err := s.Get("pmux-a"); err != nil || r.FinishedAt == nil || !r.FinishedAt.Equal(now) || r.Port != 0 {
+		t.Fatalf("Records SHOULD be replaced: %+v, %v", r, err)
+	}
+
+	// Unchanged records are not written again.
+	writes := s.db.Stats().TxStats.Write
+	if err := s.Put(&pwrap.Session{SID: "pmux-a", Exec: "yes", FinishedAt: &now}); err != nil {
+		t.Fatal(err)
+	}
+	if n := s.db.Stats().TxStats.Write; n != writes {
+		t.Fatalf("Unchanged records SHOULD NOT be written, found %d writes", n-writes)
+	}
+}
@@ -0,0 +1,73 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// ConfigTemplates makes the server execute the strings of session configurations
+// containing "{{" as Go templates before storing them, using "ConfigData", so that
+// children find e.g. their own working directory in their configuration. "vars"
+// are available to the templates as ".Vars". Templates are not executed if nil.
+func ConfigTemplates(vars map[string]string) func(*Router) {
+	return func(r *Router) {
+		r.configVars = vars
+	}
+}
+
+// ConfigData is the data the templates of session configurations are executed
+// with.
+type ConfigData struct {
+	SID       string
+	WorkDir   string
+	Namespace string
+	Labels    map[string]string
+	// Vars are the variables provided by the server.
+	Vars map[string]string
+}
+
+// renderConfig returns a copy of the decoded JSON value "v" whose strings are
+// executed as templates with "data". Keys are left untouched.
+func renderConfig(v interface{}, data *ConfigData) (interface{}, error) {
+	switch v := v.(type) {
+	case string:
+		if !strings.Contains(v, "{{") {
+			return v, nil
+		}
+		t, err := template.New("config").Option("missingkey=error").Parse(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid configuration template: %w", err)
+		}
+		var b strings.Builder
+		if err := t.Execute(&b, data); err != nil {
+			return nil, fmt.Errorf("unable to execute configuration template: %w", err)
+		}
+		return b.String(), nil
+	case map[string]interface{}:
+		acc := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			r, err := renderConfig(e, data)
+			if err != nil {
+				return nil, err
+			}
+			acc[k] = r
+		}
+		return acc, nil
+	case []interface{}:
+		acc := make([]interface{}, len(v))
+		for i, e := range v {
+			r, err := renderConfig(e, data)
+			if err != nil {
+				return nil, err
+			}
+			acc[i] = r
+		}
+		return acc, nil
+	}
+	return v, nil
+}
@@ -0,0 +1,67 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/kim-company/pmux/pwrap"
+)
+
+func TestRenderConfig(t *testing.T) {
+	t.Parallel()
+
+	var config interface{}
+	if err := json.Unmarshal([]byte(`{"out": "{{.WorkDir}}/out.mp4", "{{.SID}}": [1, "{{.Vars.bucket}}/{{.Labels.team}}", "{ {.SID}}"], "n": null}`), &config); err != nil {
+		t.Fatal(err)
+	}
+	data := &ConfigData{SID: "pmux-a", WorkDir: "/tmp/pmux-a", Labels: map[string]string{"team": "video"}, Vars: map[string]string{"bucket": "s3://media"}}
+	r, err := renderConfig(config, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"out":      "/tmp/pmux-a/out.mp4",
+		"{{.SID}}": []interface{}{1.0, "s3://media/video", "{ {.SID}}"},
+		"n":        nil,
+	}
+	if !reflect.DeepEqual(r, want) {
+		t.Fatalf("Wanted %v, found %v", want, r)
+	}
+
+	for _, v := range []string{"{{.Vars.missing}}", "{{.Unknown}}", "{{.SID"} {
+		if _, err := renderConfig(v, data); err == nil {
+			t.Fatalf("%q SHOULD NOT be rendered", v)
+		}
+	}
+}
+
+func TestSessionHandler_InitSession(t *testing.T) {
+	t.Parallel()
+
+	pw, err := pwrap.New(pwrap.RootDir(os.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(pw.WorkDir())
+	h := &SessionHandler{configVars: map[string]string{}}
+	if err := h.initSession(pw, &createRequest{Config: map[string]interface{}{"sid": "{{.SID}}", "ns": "{{.Namespace}}"}}, "video", false); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(pw.Path(pwrap.FileConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var config map[string]string
+	if err := json.Unmarshal(data, &config); err != nil {
+		t.Fatal(err)
+	}
+	if config["sid"] != pw.SID() || config["ns"] != "video" {
+		t.Fatalf("Configuration SHOULD be rendered, found %s", data)
+	}
+}
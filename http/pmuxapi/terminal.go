@@ -0,0 +1,204 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/gorilla/mux"
+	"github.com/kim-company/pmux/tmux"
+)
+
+// TerminalProtocol is the WebSocket subprotocol spoken by the terminal route:
+// binary messages carry the input and the output of the terminal, while the
+// client resizes it with text messages holding a "TerminalSize".
+const TerminalProtocol = "pmux.terminal"
+
+// TerminalSize is the size of a terminal, in characters.
+type TerminalSize struct {
+	Cols int `json:"cols"`
+	Rows int `json:"rows"`
+}
+
+// validate reports whether "s" is a reasonable terminal size.
+func (s TerminalSize) validate() error {
+	if s.Cols < 1 || s.Rows < 1 || s.Cols > 1000 || s.Rows > 1000 {
+		return fmt.Errorf("invalid terminal size %dx%d", s.Cols, s.Rows)
+	}
+	return nil
+}
+
+// parseTerminalSize returns the size selected by the "cols" and "rows" query
+// parameters, 80x24 by default.
+func parseTerminalSize(r *http.Request) (TerminalSize, error) {
+	size := TerminalSize{Cols: 80, Rows: 24}
+	for _, v := range []struct {
+		name string
+		n    *int
+	}{{"cols", &size.Cols}, {"rows", &size.Rows}} {
+		s := r.URL.Query().Get(v.name)
+		if s == "" {
+			continue
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return size, fmt.Errorf("invalid %s %q", v.name, s)
+		}
+		*v.n = n
+	}
+	return size, size.validate()
+}
+
+// HandleTerminal attaches a WebSocket to the tmux session of a running session,
+// through a tmux client running in a pseudo terminal, so that operators can
+// interact with it from a browser. With the "readonly" query parameter set to
+// true, the input of the client is ignored. Pages of other origins than those
+// allowed by "CORS" are refused.
+func (h *SessionHandler) HandleTerminal() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := checkOrigin(r, h.cors); err != nil {
+			h.writeError(w, err, http.StatusForbidden)
+			return
+		}
+		sid := mux.Vars(r)["sid"]
+		s, _, err := h.readSession(sid)
+		if err != nil {
+			h.writeSessionError(w, err)
+			return
+		}
+		if s.FinishedAt != nil {
+			h.writeError(w, fmt.Errorf("session %s is not running anymore", sid), http.StatusGone)
+			return
+		}
+		if !tmux.HasSession(sid) {
+			h.writeError(w, fmt.Errorf("session %s does not run inside tmux", sid), http.StatusConflict)
+			return
+		}
+		size, err := parseTerminalSize(r)
+		if err != nil {
+			h.writeError(w, err, http.StatusBadRequest)
+			return
+		}
+		if !isWebSocket(r) {
+			h.writeError(w, fmt.Errorf("websocket upgrade required"), http.StatusUpgradeRequired)
+			return
+		}
+		term, err := tmux.AttachTerminal(sid, r.URL.Query().Get("readonly") == "true", size.Cols, size.Rows)
+		if err != nil {
+			h.writeError(w, err, http.StatusInternalServerError)
+			return
+		}
+		defer term.Close()
+		ws, err := upgradeWebSocket(w, r, TerminalProtocol)
+		if err != nil {
+			log.Printf("[ERROR] terminal of session %s: %v", sid, err)
+			return
+		}
+		var once sync.Once
+		closeWS := func(code uint16, reason string) {
+			once.Do(func() { ws.Close(code, reason) })
+		}
+		defer closeWS(1000, "")
+		log.Printf("[INFO] terminal attached to session %s", sid)
+
+		go func() {
+			buf := make([]byte, 32<<10)
+			for {
+				n, err := term.Read(buf)
+				if n > 0 {
+					if werr := ws.WriteMessage(wsBinary, buf[:n]); werr != nil {
+						break
+					}
+				}
+				if err != nil {
+					// The client detached, e.g. because the session is over.
+					closeWS(1000, "detached")
+					return
+				}
+			}
+			closeWS(1011, "")
+		}()
+		for {
+			op, msg, err := ws.ReadMessage()
+			if err != nil {
+				break
+			}
+			if op == wsBinary {
+				if _, err := term.Write(msg); err != nil {
+					break
+				}
+				continue
+			}
+			var size TerminalSize
+			if err := json.Unmarshal(msg, &size); err != nil || size.validate() != nil {
+				log.Printf("[WARN] terminal of session %s: invalid resize message %q", sid, msg)
+				continue
+			}
+			if err := term.Resize(size.Cols, size.Rows); err != nil {
+				log.Printf("[WARN] terminal of session %s: %v", sid, err)
+			}
+		}
+		log.Printf("[INFO] terminal detached from session %s", sid)
+	}
+}
+
+// maxScreenHistory is the maximum number of scrollback lines returned by the
+// screen route.
+const maxScreenHistory = 10000
+
+// HandleScreen returns the text currently displayed by the tmux pane of a running
+// session, to check what it is doing without attaching to it. The "history"
+// query parameter prepends up to that many lines of scrollback, while "escapes"
+// keeps colours and attributes as escape sequences.
+func (h *SessionHandler) HandleScreen() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sid := mux.Vars(r)["sid"]
+		s, _, err := h.readSession(sid)
+		if err != nil {
+			h.writeSessionError(w, err)
+			return
+		}
+		if s.FinishedAt != nil {
+			h.writeError(w, fmt.Errorf("session %s is not running anymore", sid), http.StatusGone)
+			return
+		}
+		if !tmux.HasSession(sid) {
+			h.writeError(w, fmt.Errorf("session %s does not run inside tmux", sid), http.StatusConflict)
+			return
+		}
+		q := r.URL.Query()
+		var history int
+		if v := q.Get("history"); v != "" {
+			if history, err = strconv.Atoi(v); err != nil || history < 0 || history > maxScreenHistory {
+				h.writeError(w, fmt.Errorf("invalid history parameter %q", v), http.StatusBadRequest)
+				return
+			}
+		}
+		var escapes bool
+		if v := q.Get("escapes"); v != "" {
+			if escapes, err = strconv.ParseBool(v); err != nil {
+				h.writeError(w, fmt.Errorf("invalid escapes parameter %q", v), http.StatusBadRequest)
+				return
+			}
+		}
+		screen, err := tmux.CapturePane(sid, history, escapes)
+		if errors.Is(err, tmux.ErrUnsupported) {
+			h.writeError(w, err, http.StatusNotImplemented)
+			return
+		}
+		if err != nil {
+			h.writeError(w, err, http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write(screen)
+	}
+}
@@ -0,0 +1,64 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/kim-company/pmux/pwrap"
+	"github.com/kim-company/pmux/tmux"
+)
+
+func TestSessionHandler_HandleScreen(t *testing.T) {
+	t.Parallel()
+
+	pw, err := pwrap.New(pwrap.RootDir(RootDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(pw.WorkDir())
+	if err := pw.UpdateSession(func(*pwrap.Session) {}); err != nil {
+		t.Fatal(err)
+	}
+	sid := pw.SID()
+
+	h := &SessionHandler{}
+	screen := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/v1/sessions/"+sid+"/screen"+query, nil)
+		req = mux.SetURLVars(req, map[string]string{"sid": sid})
+		w := httptest.NewRecorder()
+		h.HandleScreen()(w, req)
+		return w
+	}
+	if w := screen(""); w.Code != http.StatusConflict {
+		t.Fatalf("detached sessions SHOULD NOT have a screen, found status %d", w.Code)
+	}
+
+	if err := tmux.NewSession(sid, "sh", "-c", "echo ready; sleep 60"); err != nil {
+		t.Fatal(err)
+	}
+	defer tmux.KillSession(sid)
+	var w *httptest.ResponseRecorder
+	for i := 0; i < 20; i++ {
+		if w = screen("?history=10"); strings.Contains(w.Body.String(), "ready") {
+			break
+		}
+		time.Sleep(time.Millisecond * 50)
+	}
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "ready") {
+		t.Fatalf("unexpected screen: %d %q", w.Code, w.Body.String())
+	}
+	for _, v := range []string{"?history=-1", "?history=a", "?escapes=maybe"} {
+		if w := screen(v); w.Code != http.StatusBadRequest {
+			t.Fatalf("%s SHOULD be rejected, found status %d", v, w.Code)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+)
+
+// Parts of the multipart create payload, see "decodeCreate".
+const (
+	PartRequest = "request"
+	PartConfig  = "config"
+)
+
+// maxRequestSize is the maximum size of the create payload, besides the
+// configuration.
+const maxRequestSize = 1 << 20
+
+// decodeCreate decodes the create payload of "r" into "c". Besides JSON, the
+// payload may be "multipart/form-data", made of the optional "request" part, the
+// JSON create payload, and of the "config" part, the configuration, which is
+// stored as is instead of being encoded as JSON, e.g. a binary file. On failure,
+// the status code describing the error is returned as well.
+func decodeCreate(w http.ResponseWriter, r *http.Request, c *createRequest) (int, error) {
+	if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt != "multipart/form-data" {
+		if err := json.NewDecoder(r.Body).Decode(c); err != nil {
+			return http.StatusInternalServerError, fmt.Errorf("unable to decode create payload body: %w", err)
+		}
+		return 0, nil
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, MaxConfigSize+maxRequestSize)
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return uploadStatus(err), fmt.Errorf("unable to read create payload: %w", err)
+		}
+		switch p.FormName() {
+		case PartRequest:
+			err = json.NewDecoder(io.LimitReader(p, maxRequestSize)).Decode(c)
+		case PartConfig:
+			c.rawConfig, err = io.ReadAll(io.LimitReader(p, MaxConfigSize+1))
+			if err == nil && len(c.rawConfig) > MaxConfigSize {
+				return http.StatusRequestEntityTooLarge, fmt.Errorf("configuration exceeds %d bytes", MaxConfigSize)
+			}
+		default:
+			err = fmt.Errorf("unknown part %q", p.FormName())
+		}
+		p.Close()
+		if err != nil {
+			return uploadStatus(err), fmt.Errorf("unable to read create payload: %w", err)
+		}
+	}
+	if c.rawConfig == nil {
+		return http.StatusBadRequest, fmt.Errorf("the %q part is missing", PartConfig)
+	}
+	if c.Config != nil {
+		return http.StatusBadRequest, fmt.Errorf("the configuration cannot be both uploaded and set in the request")
+	}
+	return 0, nil
+}
+
+// uploadStatus returns the status code describing "err", an error reading a
+// multipart payload.
+func uploadStatus(err error) int {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,73 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// multipartRequest returns a create request made of "parts", mapping the names
+// of the parts to their contents.
+func multipartRequest(t *testing.T, parts map[string]string) *http.Request {
+	var b bytes.Buffer
+	mw := multipart.NewWriter(&b)
+	for k, v := range parts {
+		w, err := mw.CreateFormFile(k, k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(v))
+	}
+	mw.Close()
+	r := httptest.NewRequest("POST", "/api/v1/sessions", &b)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return r
+}
+
+func TestDecodeCreate(t *testing.T) {
+	t.Parallel()
+
+	blob := "license\x00\xff\xfe"
+	var c createRequest
+	r := multipartRequest(t, map[string]string{PartRequest: `{"exec":"transcode","labels":{"t":"x"}}`, PartConfig: blob})
+	if _, err := decodeCreate(httptest.NewRecorder(), r, &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Exec != "transcode" || c.Labels["t"] != "x" || string(c.rawConfig) != blob {
+		t.Fatalf("Unexpected request: %+v, config %q", c, c.rawConfig)
+	}
+
+	c = createRequest{}
+	if _, err := decodeCreate(httptest.NewRecorder(), multipartRequest(t, map[string]string{PartConfig: ""}), &c); err != nil || c.rawConfig == nil {
+		t.Fatalf("The request part SHOULD be optional: %v", err)
+	}
+
+	for i, parts := range []map[string]string{
+		{PartRequest: `{}`},
+		{PartRequest: `{"config":{}}`, PartConfig: blob},
+		{PartRequest: `{`, PartConfig: blob},
+		{PartConfig: blob, "other": ""},
+	} {
+		if status, err := decodeCreate(httptest.NewRecorder(), multipartRequest(t, parts), &createRequest{}); err == nil || status != http.StatusBadRequest {
+			t.Fatalf("%d: payload SHOULD be rejected, found status %d", i, status)
+		}
+	}
+
+	r = multipartRequest(t, map[string]string{PartConfig: strings.Repeat("x", MaxConfigSize+1)})
+	if status, _ := decodeCreate(httptest.NewRecorder(), r, &createRequest{}); status != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Large configurations SHOULD be rejected, found status %d", status)
+	}
+
+	c = createRequest{}
+	r = httptest.NewRequest("POST", "/api/v1/sessions", strings.NewReader(`{"config":{"a":1}}`))
+	if _, err := decodeCreate(httptest.NewRecorder(), r, &c); err != nil || c.Config == nil || c.rawConfig != nil {
+		t.Fatalf("JSON payloads SHOULD be decoded as before: %+v, %v", c, err)
+	}
+}
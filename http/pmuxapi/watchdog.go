@@ -0,0 +1,138 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"github.com/kim-company/pmux/pwrap"
+	"github.com/kim-company/pmux/tmux"
+)
+
+// watchdogInterval is the time between two searches of lost sessions.
+const watchdogInterval = time.Second * 15
+
+// exitRetryInterval is the time waited before waiting again for the exits of
+// tmux sessions, when no tmux server could be reached.
+const exitRetryInterval = time.Second * 5
+
+// watch looks for lost sessions every "interval", forever, and as soon as a tmux
+// session exits.
+func (h *SessionHandler) watch(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	var confirm <-chan time.Time
+	suspects := map[string]bool{}
+	for {
+		select {
+		case <-t.C:
+		case <-h.exits:
+			// Sessions are declared lost when found missing twice:
+			// the second search is anticipated too.
+			confirm = time.After(schedulerInterval)
+		case <-confirm:
+			confirm = nil
+		}
+		suspects = h.checkLost(suspects)
+	}
+}
+
+// watchExits notifies the scheduler and the watchdog each time a tmux session
+// exits, as reported by the hook installed by "tmux.NewSession", so that they
+// do not have to wait for their next search. It returns only if tmux is not
+// available, or does not support exit notifications.
+func (h *SessionHandler) watchExits() {
+	if err := tmux.Supports(tmux.FeatureExitHook); err != nil {
+		if tmux.Available() {
+			log.Printf("[WARN] exits of sessions are polled for: %v", err)
+		}
+		return
+	}
+	for {
+		if err := tmux.WaitExit(context.Background()); err != nil {
+			// No tmux server is running: the next session starts one.
+			time.Sleep(exitRetryInterval)
+			continue
+		}
+		if h.sched != nil {
+			h.sched.notify()
+		}
+		select {
+		case h.exits <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// checkLost declares lost the sessions whose wrapper is gone while their state
+// says they are running, e.g. because the wrapper was killed before recording
+// their termination. As the state of a session is recorded slightly before its
+// wrapper starts, sessions are only declared lost when they were already found
+// without a wrapper in the previous check, listed in "suspects". The sessions
+// suspected in this check are returned.
+func (h *SessionHandler) checkLost(suspects map[string]bool) map[string]bool {
+	running, err := pwrap.ListSessions(rootDir)
+	if err != nil {
+		log.Printf("[WARN] watchdog: unable to list running sessions: %v", err)
+		return suspects
+	}
+	alive := make(map[string]bool, len(running))
+	for _, v := range running {
+		alive[v] = true
+	}
+	entries, err := os.ReadDir(rootDir)
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("[WARN] watchdog: unable to read sessions root directory: %v", err)
+		return suspects
+	}
+
+	next := map[string]bool{}
+	for _, v := range entries {
+		if !v.IsDir() {
+			continue
+		}
+		pw, err := openSession(v.Name())
+		if err != nil {
+			continue
+		}
+		s, err := pw.ReadSession()
+		if err != nil {
+			continue
+		}
+		if s.Refreshed() {
+			// Jobs and remote wrappers may have finished since
+			// their state was last refreshed.
+			if err := pw.Refresh(); err != nil {
+				log.Printf("[WARN] watchdog: unable to refresh session %s: %v", s.SID, err)
+				continue
+			}
+			if s, err = pw.ReadSession(); err != nil {
+				continue
+			}
+		}
+		if !lost(s, alive[s.SID]) {
+			continue
+		}
+		// Sessions of remote hosts that could not be listed are
+		// checked one by one, as they are considered running when
+		// their host cannot be reached.
+		if s.Remote != nil && pw.Running() {
+			continue
+		}
+		if !suspects[s.SID] {
+			next[s.SID] = true
+			continue
+		}
+		if s, err = h.declareLost(pw); err != nil {
+			log.Printf("[ERROR] watchdog: session %s: %v", pw.SID(), err)
+			continue
+		}
+		h.sync(s)
+	}
+	return next
+}
@@ -0,0 +1,63 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/kim-company/pmux/pwrap"
+)
+
+func TestSessionHandler_DeclareLost(t *testing.T) {
+	t.Parallel()
+
+	callbacks := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Status string `json:"status"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		callbacks <- payload.Status
+	}))
+	defer srv.Close()
+
+	pw, err := pwrap.New(pwrap.RootDir(os.TempDir()), pwrap.Register(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(pw.WorkDir())
+	if err := pw.UpdateSession(func(s *pwrap.Session) {
+		s.State = pwrap.SessionRunning
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The process wrapper of the handler does not know the registration URL,
+	// which is recorded in the session state.
+	lost, err := pwrap.New(pwrap.OverrideSID(pw.SID()), pwrap.RootDir(os.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := (&SessionHandler{}).declareLost(lost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.State != pwrap.SessionFailed || s.FinishedAt == nil || s.Error != errSessionLost.Error() {
+		t.Fatalf("unexpected session state: %+v", s)
+	}
+	select {
+	case status := <-callbacks:
+		if status != string(pwrap.WrapStatusError) {
+			t.Fatalf("unexpected callback status: %q", status)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("callback not delivered")
+	}
+}
@@ -0,0 +1,260 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WebSocket opcodes, RFC 6455 section 5.2.
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xA
+)
+
+// wsMaxMessage is the maximum size of the messages accepted from clients.
+const wsMaxMessage = 1 << 20
+
+// wsGUID is concatenated to the key of the client to compute the accept key.
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// wsTokenPrefix prefixes the subprotocol carrying the credentials of browsers,
+// which cannot set the headers of WebSocket requests.
+const wsTokenPrefix = "bearer."
+
+// isWebSocket reports whether "r" asks to upgrade the connection to a WebSocket.
+func isWebSocket(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket") && headerHas(r.Header, "Connection", "upgrade")
+}
+
+// headerHas reports whether the comma separated values of header "name" include
+// "token", ignoring case.
+func headerHas(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// wsProtocols returns the subprotocols offered by the client.
+func wsProtocols(r *http.Request) []string {
+	var acc []string
+	for _, v := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, p := range strings.Split(v, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				acc = append(acc, p)
+			}
+		}
+	}
+	return acc
+}
+
+// wsToken returns the credentials carried by the subprotocols of the WebSocket
+// request "r", if any.
+func wsToken(r *http.Request) string {
+	if !isWebSocket(r) {
+		return ""
+	}
+	for _, v := range wsProtocols(r) {
+		if strings.HasPrefix(v, wsTokenPrefix) {
+			return strings.TrimPrefix(v, wsTokenPrefix)
+		}
+	}
+	return ""
+}
+
+// checkOrigin returns an error if the WebSocket request "r" comes from a page of
+// another origin that is not allowed by "c", if set. Browsers do not apply CORS
+// to WebSockets, so any page visited by an operator could open them otherwise.
+// Requests without an origin do not come from browsers.
+func checkOrigin(r *http.Request, c *cors) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return nil
+	}
+	if c != nil && c.allowed(origin) {
+		return nil
+	}
+	return fmt.Errorf("websocket origin %q is not allowed", origin)
+}
+
+// wsConn is a server side WebSocket connection.
+type wsConn struct {
+	conn net.Conn
+	br   *bufio.Reader
+	wmu  sync.Mutex
+}
+
+// upgradeWebSocket completes the opening handshake of the WebSocket request "r",
+// selecting subprotocol "protocol" if the client offered it. Errors are written
+// to "w" when the connection is not hijacked yet.
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request, protocol string) (*wsConn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != http.MethodGet || !isWebSocket(r) || key == "" {
+		err := errors.New("websocket upgrade required")
+		w.Header().Set("Upgrade", "websocket")
+		http.Error(w, err.Error(), http.StatusUpgradeRequired)
+		return nil, err
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		err := errors.New("unsupported websocket version")
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, err
+	}
+	conn, brw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, fmt.Errorf("unable to hijack connection: %w", err)
+	}
+	// The connection outlives the timeouts of the server.
+	conn.SetDeadline(time.Time{})
+
+	sum := sha1.Sum([]byte(key + wsGUID))
+	resp := "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n"
+	for _, v := range wsProtocols(r) {
+		if v == protocol {
+			resp += "Sec-WebSocket-Protocol: " + protocol + "\r\n"
+			break
+		}
+	}
+	if _, err := conn.Write([]byte(resp + "\r\n")); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("unable to complete websocket handshake: %w", err)
+	}
+	return &wsConn{conn: conn, br: brw.Reader}, nil
+}
+
+// readFrame reads a frame sent by the client, unmasking its payload.
+func (c *wsConn) readFrame() (fin bool, op byte, payload []byte, err error) {
+	var h [2]byte
+	if _, err = io.ReadFull(c.br, h[:]); err != nil {
+		return
+	}
+	fin, op = h[0]&0x80 != 0, h[0]&0x0F
+	if h[1]&0x80 == 0 {
+		return fin, op, nil, errors.New("websocket frame of the client is not masked")
+	}
+	n := uint64(h[1] & 0x7F)
+	switch n {
+	case 126:
+		var b [2]byte
+		if _, err = io.ReadFull(c.br, b[:]); err != nil {
+			return
+		}
+		n = uint64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		if _, err = io.ReadFull(c.br, b[:]); err != nil {
+			return
+		}
+		n = binary.BigEndian.Uint64(b[:])
+	}
+	if n > wsMaxMessage {
+		return fin, op, nil, fmt.Errorf("websocket frame too large: %d bytes", n)
+	}
+	var mask [4]byte
+	if _, err = io.ReadFull(c.br, mask[:]); err != nil {
+		return
+	}
+	payload = make([]byte, n)
+	if _, err = io.ReadFull(c.br, payload); err != nil {
+		return
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, op, payload, nil
+}
+
+// ReadMessage returns the next text or binary message sent by the client,
+// answering its pings. io.EOF is returned once the client closes the connection.
+func (c *wsConn) ReadMessage() (byte, []byte, error) {
+	var op byte
+	var msg []byte
+	for {
+		fin, fop, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+		switch fop {
+		case wsPing:
+			if err := c.WriteMessage(wsPong, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case wsPong:
+			continue
+		case wsClose:
+			c.WriteMessage(wsClose, payload)
+			return 0, nil, io.EOF
+		case wsContinuation:
+			if op == 0 {
+				return 0, nil, errors.New("unexpected websocket continuation frame")
+			}
+		default:
+			op = fop
+		}
+		if len(msg)+len(payload) > wsMaxMessage {
+			return 0, nil, errors.New("websocket message too large")
+		}
+		msg = append(msg, payload...)
+		if fin {
+			return op, msg, nil
+		}
+	}
+}
+
+// WriteMessage sends "data" to the client in a single frame of type "op".
+func (c *wsConn) WriteMessage(op byte, data []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	h := []byte{0x80 | op, 0}
+	switch n := len(data); {
+	case n < 126:
+		h[1] = byte(n)
+	case n <= 0xFFFF:
+		h[1] = 126
+		h = binary.BigEndian.AppendUint16(h, uint16(n))
+	default:
+		h[1] = 127
+		h = binary.BigEndian.AppendUint64(h, uint64(n))
+	}
+	if _, err := c.conn.Write(append(h, data...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Close sends a close frame with status "code" and "reason" to the client, then
+// closes the connection.
+func (c *wsConn) Close(code uint16, reason string) error {
+	c.WriteMessage(wsClose, append(binary.BigEndian.AppendUint16(nil, code), reason...))
+	return c.conn.Close()
+}
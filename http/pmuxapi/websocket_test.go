@@ -0,0 +1,138 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pmuxapi
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// wsClientFrame returns a masked frame of type "op" carrying "data", as sent by
+// clients.
+func wsClientFrame(op byte, data []byte) []byte {
+	mask := []byte{1, 2, 3, 4}
+	frame := []byte{0x80 | op, 0x80 | byte(len(data))}
+	frame = append(frame, mask...)
+	for i, v := range data {
+		frame = append(frame, v^mask[i%4])
+	}
+	return frame
+}
+
+func TestUpgradeWebSocket(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgradeWebSocket(w, r, TerminalProtocol)
+		if err != nil {
+			return
+		}
+		defer ws.Close(1000, "")
+		for {
+			op, msg, err := ws.ReadMessage()
+			if err != nil {
+				return
+			}
+			ws.WriteMessage(op, append([]byte("echo: "), msg...))
+		}
+	}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUpgradeRequired {
+		t.Fatalf("plain requests SHOULD be refused, found status %d", resp.StatusCode)
+	}
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "GET / HTTP/1.1\r\nHost: pmux\r\nUpgrade: websocket\r\nConnection: keep-alive, Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\nSec-WebSocket-Protocol: bearer.secret, pmux.terminal\r\n\r\n")
+	br := bufio.NewReader(conn)
+	resp, err = http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Example of RFC 6455, section 1.3.
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("unexpected handshake response: %d %v", resp.StatusCode, resp.Header)
+	}
+	if p := resp.Header.Get("Sec-WebSocket-Protocol"); p != TerminalProtocol {
+		t.Fatalf("the terminal protocol SHOULD be selected, found %q", p)
+	}
+
+	conn.Write(wsClientFrame(wsPing, []byte("ping")))
+	conn.Write(wsClientFrame(wsBinary, []byte("ls\r")))
+	for _, want := range []struct {
+		op   byte
+		data string
+	}{{wsPong, "ping"}, {wsBinary, "echo: ls\r"}} {
+		h := make([]byte, 2)
+		if _, err := io.ReadFull(br, h); err != nil {
+			t.Fatal(err)
+		}
+		data := make([]byte, h[1])
+		if _, err := io.ReadFull(br, data); err != nil {
+			t.Fatal(err)
+		}
+		if h[0] != 0x80|want.op || string(data) != want.data {
+			t.Fatalf("wanted frame %x %q, found %x %q", want.op, want.data, h[0], data)
+		}
+	}
+}
+
+func TestWSToken(t *testing.T) {
+	t.Parallel()
+
+	r := httptest.NewRequest("GET", "/api/v1/sessions/pmux-a/terminal", nil)
+	r.Header.Set("Sec-WebSocket-Protocol", "pmux.terminal, bearer.secret")
+	if token := wsToken(r); token != "" {
+		t.Fatalf("tokens of requests that are not upgrades SHOULD be ignored, found %q", token)
+	}
+	r.Header.Set("Upgrade", "websocket")
+	r.Header.Set("Connection", "Upgrade")
+	if token := wsToken(r); token != "secret" {
+		t.Fatalf("wanted token secret, found %q", token)
+	}
+}
+
+func TestSessionHandler_HandleTerminal_Origin(t *testing.T) {
+	t.Parallel()
+
+	h := &SessionHandler{cors: &cors{origins: []string{"https://dashboard.example.org"}}}
+	for origin, code := range map[string]int{
+		"":                              http.StatusNotFound,
+		"http://example.com":            http.StatusNotFound,
+		"https://dashboard.example.org": http.StatusNotFound,
+		"https://evil.example.org":      http.StatusForbidden,
+		"null":                          http.StatusForbidden,
+	} {
+		r := httptest.NewRequest("GET", "/api/v1/sessions/pmux-missing/terminal", nil)
+		r = mux.SetURLVars(r, map[string]string{"sid": "pmux-missing"})
+		r.Header.Set("Upgrade", "websocket")
+		r.Header.Set("Connection", "Upgrade")
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		w := httptest.NewRecorder()
+		h.HandleTerminal()(w, r)
+		if w.Code != code {
+			t.Fatalf("origin %q: wanted status %d, found %d", origin, code, w.Code)
+		}
+	}
+}
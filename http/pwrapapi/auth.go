@@ -0,0 +1,46 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrapapi
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// AuthToken protects every route of the router, requiring clients to present
+// "token" in the Authorization header as a bearer token. An empty token
+// disables authentication.
+func AuthToken(token string) func(*Router) {
+	return func(r *Router) {
+		if token == "" {
+			return
+		}
+		r.Use(authMiddleware(token))
+	}
+}
+
+func authMiddleware(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !validBearer(r.Header.Get("Authorization"), token) {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="pwrap"`)
+				serveError(w, errors.New("missing or invalid bearer token"), http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func validBearer(header, token string) bool {
+	const prefix = "Bearer "
+	if len(header) < len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return false
+	}
+	given := strings.TrimSpace(header[len(prefix):])
+	return subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+}
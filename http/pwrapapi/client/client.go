@@ -0,0 +1,349 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+// Package client consumes the progress streams served by the process wrapper API,
+// either directly or through the pmux server, reconnecting when they break.
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/kim-company/pmux/pwrap"
+)
+
+// Client subscribes to a progress stream.
+type Client struct {
+	url        string
+	format     string
+	token      string
+	header     http.Header
+	hc         *http.Client
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	idle       time.Duration
+	replay     int
+	onError    func(error)
+}
+
+// Token sets the bearer token presented to the process wrapper API.
+func Token(token string) func(*Client) {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// Header adds a header to each request, e.g. the X-API-Key required by the pmux
+// server when the stream is consumed through it.
+func Header(key, value string) func(*Client) {
+	return func(c *Client) {
+		c.header.Add(key, value)
+	}
+}
+
+// Format selects the encoding of the stream, either "pwrap.FormatJSON", the default,
+// or "pwrap.FormatCSV". CSV streams only carry the positional fields of the updates.
+func Format(format string) func(*Client) {
+	return func(c *Client) {
+		c.format = format
+	}
+}
+
+// HTTPClient sets the HTTP client used to open the stream. Its timeout, if any,
+// bounds the lifetime of each connection.
+func HTTPClient(hc *http.Client) func(*Client) {
+	return func(c *Client) {
+		c.hc = hc
+	}
+}
+
+// Backoff sets the delay between reconnection attempts, which starts from "min"
+// and doubles after each failed attempt, up to "max".
+func Backoff(min, max time.Duration) func(*Client) {
+	return func(c *Client) {
+		c.minBackoff, c.maxBackoff = min, max
+	}
+}
+
+// IdleTimeout sets how long a connection may stay silent before being considered
+// dead and replaced. The wrapper sends heartbeats every
+// "pwrap.DefaultHeartbeatInterval" by default. Zero disables the check.
+func IdleTimeout(d time.Duration) func(*Client) {
+	return func(c *Client) {
+		c.idle = d
+	}
+}
+
+// ReplaySize is the number of updates replayed by the wrapper to new connections,
+// as configured with "pwrap.ReplaySize". Replayed updates that were already
+// delivered are skipped after a reconnection.
+func ReplaySize(n int) func(*Client) {
+	return func(c *Client) {
+		c.replay = n
+	}
+}
+
+// OnError sets a function called with every error that causes a reconnection.
+func OnError(f func(error)) func(*Client) {
+	return func(c *Client) {
+		c.onError = f
+	}
+}
+
+// New returns a client of the progress stream found at "rawurl", e.g.
+// "http://127.0.0.1:55032/progress" or the progress route of a pmux session.
+func New(rawurl string, opts ...func(*Client)) *Client {
+	c := &Client{
+		url:        rawurl,
+		format:     pwrap.FormatJSON,
+		header:     http.Header{},
+		hc:         http.DefaultClient,
+		minBackoff: time.Millisecond * 250,
+		maxBackoff: time.Second * 30,
+		idle:       pwrap.DefaultHeartbeatInterval * 3,
+		replay:     pwrap.DefaultReplaySize,
+		onError:    func(error) {},
+	}
+	for _, f := range opts {
+		f(c)
+	}
+	return c
+}
+
+// ErrStatus is returned when the stream is refused with a status that does not
+// change by retrying, e.g. when the session does not exist or the token is wrong.
+type ErrStatus struct {
+	StatusCode int
+}
+
+func (e *ErrStatus) Error() string {
+	return fmt.Sprintf("progress stream refused: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// Subscription delivers the updates of a progress stream on "C".
+type Subscription struct {
+	// C is closed when the subscription ends, after which "Err" reports why.
+	C   <-chan *pwrap.ProgressUpdate
+	err error
+}
+
+// Err returns the reason the subscription ended: the context error, or an
+// "*ErrStatus" if the stream was refused. It is only valid once "C" is closed.
+func (s *Subscription) Err() error {
+	return s.err
+}
+
+// Subscribe consumes the progress stream until "ctx" is done, reconnecting when
+// the connection breaks.
+func (c *Client) Subscribe(ctx context.Context) *Subscription {
+	ch := make(chan *pwrap.ProgressUpdate)
+	s := &Subscription{C: ch}
+	go func() {
+		defer close(ch)
+		s.err = c.run(ctx, ch)
+	}()
+	return s
+}
+
+func (c *Client) run(ctx context.Context, ch chan<- *pwrap.ProgressUpdate) error {
+	recent := &history{size: c.replay}
+	backoff := c.minBackoff
+	for {
+		delivered, err := c.stream(ctx, ch, recent)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var se *ErrStatus
+		if errors.As(err, &se) && se.StatusCode != http.StatusServiceUnavailable && se.StatusCode != http.StatusBadGateway && se.StatusCode != http.StatusGatewayTimeout {
+			return err
+		}
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		c.onError(err)
+		if delivered {
+			backoff = c.minBackoff
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > c.maxBackoff {
+			backoff = c.maxBackoff
+		}
+	}
+}
+
+// stream opens a single connection, delivering its updates on "ch" until it breaks.
+// It reports whether any update was delivered.
+func (c *Client) stream(ctx context.Context, ch chan<- *pwrap.ProgressUpdate, recent *history) (bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	u, err := url.Parse(c.url)
+	if err != nil {
+		return false, err
+	}
+	q := u.Query()
+	q.Set("format", c.format)
+	u.RawQuery = q.Encode()
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return false, err
+	}
+	for k, v := range c.header {
+		req.Header[k] = v
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, &ErrStatus{StatusCode: resp.StatusCode}
+	}
+
+	var body io.Reader = resp.Body
+	if c.idle > 0 {
+		t := time.AfterFunc(c.idle, cancel)
+		defer t.Stop()
+		body = &idleReader{r: resp.Body, t: t, d: c.idle}
+	}
+	dec := newDecoder(c.format, body)
+
+	// The first updates may be replayed by the wrapper.
+	replaying := true
+	delivered := false
+	for {
+		upd, err := dec.Decode()
+		if err != nil {
+			return delivered, err
+		}
+		if replaying && recent.contains(upd) {
+			continue
+		}
+		replaying = false
+		recent.add(upd)
+		select {
+		case ch <- upd:
+			delivered = true
+		case <-ctx.Done():
+			return delivered, ctx.Err()
+		}
+	}
+}
+
+// idleReader resets timer "t" every time data is read.
+type idleReader struct {
+	r io.Reader
+	t *time.Timer
+	d time.Duration
+}
+
+func (r *idleReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.t.Reset(r.d)
+	}
+	return n, err
+}
+
+// history keeps the most recent updates delivered.
+type history struct {
+	size    int
+	updates []*pwrap.ProgressUpdate
+}
+
+func (h *history) add(u *pwrap.ProgressUpdate) {
+	if h.size <= 0 {
+		return
+	}
+	h.updates = append(h.updates, u)
+	if len(h.updates) > h.size {
+		h.updates = h.updates[1:]
+	}
+}
+
+func (h *history) contains(u *pwrap.ProgressUpdate) bool {
+	for _, v := range h.updates {
+		if reflect.DeepEqual(u, v) {
+			return true
+		}
+	}
+	return false
+}
+
+type decoder interface {
+	Decode() (*pwrap.ProgressUpdate, error)
+}
+
+func newDecoder(format string, r io.Reader) decoder {
+	if format == pwrap.FormatCSV {
+		cr := csv.NewReader(r)
+		cr.FieldsPerRecord = 5
+		return &csvDecoder{r: cr}
+	}
+	return &jsonDecoder{s: bufio.NewScanner(r)}
+}
+
+type jsonDecoder struct {
+	s *bufio.Scanner
+}
+
+func (d *jsonDecoder) Decode() (*pwrap.ProgressUpdate, error) {
+	for d.s.Scan() {
+		// Heartbeats are empty lines.
+		if len(d.s.Bytes()) == 0 {
+			continue
+		}
+		var u pwrap.ProgressUpdate
+		if err := json.Unmarshal(d.s.Bytes(), &u); err != nil {
+			return nil, fmt.Errorf("unable to decode progress update: %w", err)
+		}
+		return &u, nil
+	}
+	if err := d.s.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+type csvDecoder struct {
+	r *csv.Reader
+}
+
+func (d *csvDecoder) Decode() (*pwrap.ProgressUpdate, error) {
+	for {
+		// Empty lines, i.e. heartbeats, are skipped by the csv reader.
+		rec, err := d.r.Read()
+		if err != nil {
+			return nil, err
+		}
+		if rec[0] == "DESCRIPTION" && rec[1] == "STAGE" {
+			// Header, written before the first update of each connection.
+			continue
+		}
+		var n [4]int
+		for i := range n {
+			if n[i], err = strconv.Atoi(rec[i+1]); err != nil {
+				return nil, fmt.Errorf("unable to decode progress update: %w", err)
+			}
+		}
+		return &pwrap.ProgressUpdate{Description: rec[0], Stage: n[0], Stages: n[1], Partial: n[2], Total: n[3]}, nil
+	}
+}
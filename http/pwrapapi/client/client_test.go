@@ -0,0 +1,110 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package client
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kim-company/pmux/http/pwrapapi"
+)
+
+// fakeBridge serves, on each connection, the next entry of "conns" after
+// the handshake header, then closes the connection.
+type fakeBridge struct {
+	sync.Mutex
+	conns   []string
+	headers []string
+}
+
+func (b *fakeBridge) dial() (net.Conn, error) {
+	b.Lock()
+	defer b.Unlock()
+	if len(b.conns) == 0 {
+		return nil, fmt.Errorf("no more connections")
+	}
+	data := b.conns[0]
+	b.conns = b.conns[1:]
+	client, server := net.Pipe()
+	go func() {
+		defer server.Close()
+		header, _ := bufio.NewReader(server).ReadString('\n')
+		b.Lock()
+		b.headers = append(b.headers, strings.TrimSpace(header))
+		b.Unlock()
+		server.Write([]byte(data))
+	}()
+	return client, nil
+}
+
+func TestSubscribe(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		format string
+		conns  []string
+	}{
+		{"json", []string{
+			`{"description":"a","partial":1}` + "\n\n" + `{"description":"b","partial":2}` + "\n",
+			// "b" is replayed after the reconnection.
+			`{"description":"b","partial":2}` + "\n" + `{"description":"c","partial":3}` + "\n",
+		}},
+		{"csv", []string{
+			"DESCRIPTION,STAGE,STAGES,PARTIAL,TOTAL\na,-1,-1,1,-1\n\n\"b,\nb\",-1,-1,2,-1\n",
+			"DESCRIPTION,STAGE,STAGES,PARTIAL,TOTAL\n\"b,\nb\",-1,-1,2,-1\nc,-1,-1,3,-1\n",
+		}},
+	} {
+		b := &fakeBridge{conns: tt.conns}
+		srv := httptest.NewServer(pwrapapi.NewRouter(pwrapapi.RouteBridge(pwrapapi.Bridge{Dial: b.dial})))
+		defer srv.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+		s := New(srv.URL+"/progress", Format(tt.format), Backoff(time.Millisecond, time.Millisecond)).Subscribe(ctx)
+		for _, want := range []int{1, 2, 3} {
+			u, ok := <-s.C
+			if !ok {
+				t.Fatalf("%s: subscription ended: %v", tt.format, s.Err())
+			}
+			if u.Partial != want {
+				t.Fatalf("%s: wanted partial %d, found %+v", tt.format, want, u)
+			}
+		}
+		cancel()
+		for range s.C {
+		}
+		if s.Err() != context.Canceled {
+			t.Fatalf("%s: unexpected error: %v", tt.format, s.Err())
+		}
+		if b.headers[0] != "mode=progress;format="+tt.format+";v=2" {
+			t.Fatalf("%s: unexpected header %q", tt.format, b.headers[0])
+		}
+	}
+}
+
+func TestSubscribe_Refused(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(pwrapapi.NewRouter(
+		pwrapapi.AuthToken("secret"),
+		pwrapapi.RouteBridge(pwrapapi.Bridge{Dial: (&fakeBridge{}).dial}),
+	))
+	defer srv.Close()
+
+	s := New(srv.URL+"/progress", Token("wrong")).Subscribe(context.Background())
+	for range s.C {
+	}
+	if e, ok := s.Err().(*ErrStatus); !ok || e.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("unexpected error: %v", s.Err())
+	}
+}
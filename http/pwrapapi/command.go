@@ -0,0 +1,96 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrapapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// The operations of the commands that children are expected to understand.
+// "CommandReload" tells the child to read its configuration file again.
+const (
+	CommandPause  = "pause"
+	CommandResume = "resume"
+	CommandCancel = "cancel"
+	CommandReload = "reload"
+)
+
+// DefaultCommandOps are the operations accepted by the command route unless
+// configured otherwise, see "CommandOps".
+var DefaultCommandOps = []string{CommandPause, CommandResume, CommandCancel, CommandReload}
+
+// Command is the envelope of the commands delivered to the child, e.g.
+// {"op":"cancel","args":{}}. It is written to the bridge JSON encoded on a
+// single line.
+type Command struct {
+	Op   string          `json:"op"`
+	Args json.RawMessage `json:"args,omitempty"`
+}
+
+// ParseCommand decodes the command envelope "data", which has to carry an
+// operation and, if any, arguments encoded as a JSON object.
+func ParseCommand(data []byte) (*Command, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var c Command
+	if err := dec.Decode(&c); err != nil {
+		return nil, fmt.Errorf("invalid command: %w", err)
+	}
+	if dec.More() {
+		return nil, errors.New("invalid command: unexpected data after the envelope")
+	}
+	if err := ValidateCommandOp(c.Op); err != nil {
+		return nil, err
+	}
+	if args := bytes.TrimSpace(c.Args); len(args) > 0 && args[0] != '{' && string(args) != "null" {
+		return nil, errors.New("invalid command: args must be an object")
+	}
+	return &c, nil
+}
+
+// ValidateCommandOp reports whether "op" can be the operation of a command: it
+// cannot be empty nor contain spaces.
+func ValidateCommandOp(op string) error {
+	if op == "" || strings.ContainsAny(op, " \t\r\n") {
+		return fmt.Errorf("invalid command operation %q", op)
+	}
+	return nil
+}
+
+// Encode returns the line delivering "c" to the child, newline included.
+func (c *Command) Encode() ([]byte, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// CommandOps restricts the operations accepted by the command route to "ops",
+// "DefaultCommandOps" if empty. Commands are refused before reaching the child
+// otherwise.
+func CommandOps(ops []string) func(*Router) {
+	return func(r *Router) {
+		r.ops = ops
+	}
+}
+
+// allowed reports whether the command route accepts operation "op".
+func (r *Router) allowed(op string) bool {
+	ops := r.ops
+	if len(ops) == 0 {
+		ops = DefaultCommandOps
+	}
+	for _, v := range ops {
+		if v == op {
+			return true
+		}
+	}
+	return false
+}
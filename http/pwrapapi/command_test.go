@@ -0,0 +1,100 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrapapi
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseCommand(t *testing.T) {
+	t.Parallel()
+
+	c, err := ParseCommand([]byte(`{"op":"pause","args":{"after": 2}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	line, err := c.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"op":"pause","args":{"after":2}}` + "\n"; string(line) != want {
+		t.Fatalf("Commands SHOULD be encoded on a single line: wanted %q, found %q", want, line)
+	}
+	for _, v := range []string{"cancel", `{}`, `{"op":""}`, `{"op":"a b"}`, `{"op":"cancel","args":[1]}`, `{"op":"cancel","name":"x"}`, `{"op":"cancel"}{}`} {
+		if _, err := ParseCommand([]byte(v)); err == nil {
+			t.Fatalf("Command %s SHOULD be rejected", v)
+		}
+	}
+}
+
+// commandBridge returns a bridge recording on "lines" the commands it receives,
+// accepting them.
+func commandBridge(lines chan<- string) Bridge {
+	return Bridge{Dial: func() (net.Conn, error) {
+		client, child := net.Pipe()
+		go func() {
+			defer child.Close()
+			r := bufio.NewReader(child)
+			if _, err := r.ReadString('\n'); err != nil {
+				return
+			}
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			lines <- line
+			io.WriteString(child, `{"ok":true}`+"\n")
+		}()
+		return client, nil
+	}}
+}
+
+func TestCommandHandler(t *testing.T) {
+	t.Parallel()
+
+	lines := make(chan string, 1)
+	srv := httptest.NewServer(NewRouter(CommandOps([]string{"cancel", "rewind"}), RouteBridge(commandBridge(lines))))
+	defer srv.Close()
+
+	if r := NewRouter(); !r.allowed(CommandPause) || r.allowed("rewind") {
+		t.Fatalf("Routers SHOULD accept the default operations only")
+	}
+
+	for _, tt := range []struct {
+		body   string
+		status int
+		line   string
+	}{
+		{`{"op":"rewind","args":{"to":0}}`, http.StatusOK, `{"op":"rewind","args":{"to":0}}` + "\n"},
+		{`{"op":"pause"}`, http.StatusForbidden, ""},
+		{"cancel", http.StatusBadRequest, ""},
+	} {
+		resp, err := http.Post(srv.URL+"/command", "application/json", strings.NewReader(tt.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Fatalf("%s: wanted status %d, found %d", tt.body, tt.status, resp.StatusCode)
+		}
+		if tt.line == "" {
+			continue
+		}
+		if line := <-lines; line != tt.line {
+			t.Fatalf("%s: wanted %q delivered to the child, found %q", tt.body, tt.line, line)
+		}
+	}
+	select {
+	case line := <-lines:
+		t.Fatalf("Refused commands SHOULD NOT be delivered, found %q", line)
+	default:
+	}
+}
@@ -0,0 +1,88 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrapapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// MaxConfigSize is the maximum size of the configurations accepted by the
+// "/config" route.
+const MaxConfigSize = 32 << 20
+
+// ErrReloadState is returned by "ReloadFunc"s when the child cannot reload its
+// configuration in its current state, e.g. when it is suspended.
+var ErrReloadState = errors.New("configuration cannot be reloaded")
+
+// ReloadFunc replaces the configuration of the child with the data read from "r"
+// and makes the child read it again.
+type ReloadFunc func(ctx context.Context, r io.Reader) error
+
+// RouteConfig registers the "/config" route, serving the child's configuration
+// file found at "path".
+func RouteConfig(path string) func(*Router) {
+	return func(r *Router) {
+		r.HandleFunc("/config", ConfigHandler(path)).Methods("GET")
+	}
+}
+
+// ConfigHandler serves the configuration file found at "path". JSON configurations
+// are served as such, otherwise the content type is sniffed from the data.
+func ConfigHandler(path string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			serveError(w, fmt.Errorf("unable to read configuration: %w", err), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", ConfigContentType(data))
+		w.Write(data)
+	}
+}
+
+// ConfigContentType returns the content type of the configuration "data".
+func ConfigContentType(data []byte) string {
+	if len(data) > 0 && json.Valid(data) {
+		return "application/json"
+	}
+	return http.DetectContentType(data)
+}
+
+// Reload enables the "PUT /config" route, which replaces the configuration of the
+// running child with the request body using "f".
+func Reload(f ReloadFunc) func(*Router) {
+	return func(r *Router) {
+		r.HandleFunc("/config", reloadHandler(f)).Methods("PUT")
+	}
+}
+
+// reloadHandler pushes the configuration found in the request body to the child
+// using "reload".
+func reloadHandler(reload ReloadFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+		if err := reload(r.Context(), http.MaxBytesReader(w, r.Body, MaxConfigSize)); err != nil {
+			code := http.StatusInternalServerError
+			var maxErr *http.MaxBytesError
+			switch {
+			case errors.As(err, &maxErr):
+				code = http.StatusRequestEntityTooLarge
+			case errors.Is(err, ErrReloadState):
+				code = http.StatusConflict
+			case errors.Is(err, ErrCommandRejected):
+				code = http.StatusUnprocessableEntity
+			}
+			serveError(w, err, code)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,63 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrapapi
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReloadHandler(t *testing.T) {
+	t.Parallel()
+
+	var got string
+	reload := func(_ context.Context, r io.Reader) error {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		switch got = string(data); got {
+		case "suspended":
+			return fmt.Errorf("%w: child is suspended", ErrReloadState)
+		case "rejected":
+			return fmt.Errorf("%w: %q: unknown command", ErrCommandRejected, CommandReload)
+		}
+		return nil
+	}
+	srv := httptest.NewServer(NewRouter(Reload(reload)))
+	defer srv.Close()
+
+	for _, tt := range []struct {
+		body   string
+		status int
+	}{
+		{`{"bitrate":"4M"}`, http.StatusNoContent},
+		{"suspended", http.StatusConflict},
+		{"rejected", http.StatusUnprocessableEntity},
+		{strings.Repeat("x", MaxConfigSize+1), http.StatusRequestEntityTooLarge},
+	} {
+		req, err := http.NewRequest("PUT", srv.URL+"/config", bytes.NewBufferString(tt.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Fatalf("Wanted status %d, found %d", tt.status, resp.StatusCode)
+		}
+		if tt.status == http.StatusNoContent && got != tt.body {
+			t.Fatalf("The configuration SHOULD reach the reload function, found %q", got)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrapapi
+
+import (
+	"fmt"
+	"net"
+)
+
+// DialFunc opens a new connection to the child's communication bridge.
+type DialFunc func() (net.Conn, error)
+
+// NewDialer returns a DialFunc connecting to "addr" using "transport", which
+// is either "unix", "tcp" or "pipe".
+func NewDialer(transport, addr string) DialFunc {
+	switch transport {
+	case "tcp":
+		return func() (net.Conn, error) {
+			return net.Dial("tcp", addr)
+		}
+	case "pipe":
+		return func() (net.Conn, error) {
+			return dialPipe(addr)
+		}
+	default:
+		return unixDialer(addr)
+	}
+}
+
+func unixDialer(path string) DialFunc {
+	return func() (net.Conn, error) {
+		return net.Dial("unix", path)
+	}
+}
+
+// Bridge describes how to reach the child's communication bridge.
+type Bridge struct {
+	Dial DialFunc
+	// Token, if set, is presented in the handshake header of each connection.
+	Token string
+}
+
+// Open dials the bridge and writes the handshake "header", adding the
+// authentication token if needed.
+func (b Bridge) Open(header string) (net.Conn, error) {
+	conn, err := b.Dial()
+	if err != nil {
+		return nil, err
+	}
+	if b.Token != "" {
+		header += ";token=" + b.Token
+	}
+	if _, err := conn.Write([]byte(header + "\n")); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("unable to write handshake header: %w", err)
+	}
+	return conn, nil
+}
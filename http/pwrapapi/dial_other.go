@@ -0,0 +1,16 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+//go:build !windows
+
+package pwrapapi
+
+import (
+	"errors"
+	"net"
+)
+
+func dialPipe(path string) (net.Conn, error) {
+	return nil, errors.New("named pipes are only supported on windows")
+}
@@ -0,0 +1,145 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrapapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// FileInfo describes a file found in the working directory.
+type FileInfo struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// RouteFiles registers the "/files" and "/files/{name}" routes, listing and serving
+// the files found in "dir".
+func RouteFiles(dir string) func(*Router) {
+	return func(r *Router) {
+		r.HandleFunc("/files", FilesHandler(dir)).Methods("GET")
+		r.HandleFunc("/files/{name:.+}", FileHandler(dir)).Methods("GET")
+	}
+}
+
+// ListFiles returns the regular files found in "dir" and its subdirectories. Names
+// are relative to "dir" and use forward slashes.
+func ListFiles(dir string) ([]FileInfo, error) {
+	acc := []FileInfo{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		acc = append(acc, FileInfo{
+			Name:    filepath.ToSlash(rel),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		})
+		return nil
+	})
+	return acc, err
+}
+
+// FilePath resolves "name" inside "dir", returning an error if the result
+// would escape from it.
+func FilePath(dir, name string) (string, error) {
+	clean := filepath.Clean("/" + filepath.FromSlash(name))
+	path := filepath.Join(dir, clean)
+	if path != dir && !strings.HasPrefix(path, filepath.Clean(dir)+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid file name %q", name)
+	}
+	return path, nil
+}
+
+// ResolveFile evaluates the symbolic links of "path", found inside "dir", returning
+// an error if the file it refers to is not inside "dir" as well.
+func ResolveFile(dir, path string) (string, error) {
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", err
+	}
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+	if real != root && !strings.HasPrefix(real, root+string(filepath.Separator)) {
+		return "", fmt.Errorf("%q refers to a file outside of the working directory", path)
+	}
+	return real, nil
+}
+
+// FilesHandler lists the files found in "dir".
+func FilesHandler(dir string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		files, err := ListFiles(dir)
+		if err != nil {
+			serveError(w, fmt.Errorf("unable to list files: %w", err), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(files); err != nil {
+			logError(fmt.Errorf("unable to encode file list: %w", err), http.StatusInternalServerError)
+		}
+	}
+}
+
+// FileHandler serves the file found in "dir" named after the "name" path variable.
+// Symbolic links are followed as long as they do not leave "dir".
+func FileHandler(dir string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+		path, err := FilePath(dir, name)
+		if err != nil {
+			serveError(w, err, http.StatusBadRequest)
+			return
+		}
+		path, err = ResolveFile(dir, path)
+		if errors.Is(err, os.ErrNotExist) {
+			serveError(w, fmt.Errorf("file %q not found", name), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			serveError(w, err, http.StatusForbidden)
+			return
+		}
+		f, err := os.Open(path)
+		if errors.Is(err, os.ErrNotExist) {
+			serveError(w, fmt.Errorf("file %q not found", name), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			serveError(w, fmt.Errorf("unable to open file: %w", err), http.StatusInternalServerError)
+			return
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			serveError(w, fmt.Errorf("unable to stat file: %w", err), http.StatusInternalServerError)
+			return
+		}
+		if !info.Mode().IsRegular() {
+			serveError(w, fmt.Errorf("%q is not a regular file", name), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filepath.Base(path)))
+		http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+	}
+}
@@ -0,0 +1,32 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrapapi
+
+import (
+	"testing"
+)
+
+func TestFilePath(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name string
+		exp  string
+	}{
+		{"out.mp4", "/work/out.mp4"},
+		{"sub/out.mp4", "/work/sub/out.mp4"},
+		{"../etc/passwd", "/work/etc/passwd"},
+		{"/etc/passwd", "/work/etc/passwd"},
+	}
+	for _, v := range tt {
+		path, err := FilePath("/work", v.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if path != v.exp {
+			t.Fatalf("Name %q: wanted %v, found %v", v.name, v.exp, path)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrapapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ChildStatus describes the state of the child process tracked by the server.
+type ChildStatus struct {
+	PID            int        `json:"pid"`
+	Running        bool       `json:"running"`
+	StartedAt      *time.Time `json:"started_at,omitempty"`
+	FinishedAt     *time.Time `json:"finished_at,omitempty"`
+	UptimeSeconds  float64    `json:"uptime_seconds"`
+	LastProgressAt *time.Time `json:"last_progress_at,omitempty"`
+	ExitCode       *int       `json:"exit_code,omitempty"`
+	Error          string     `json:"error,omitempty"`
+	// Signal is the name of the signal that terminated the child, if any.
+	Signal string `json:"signal,omitempty"`
+	// OOMKilled is set when the child was likely killed by the OOM killer.
+	OOMKilled bool `json:"oom_killed,omitempty"`
+	// TimedOut is set when the child was stopped for exceeding its
+	// maximum runtime.
+	TimedOut bool `json:"timed_out,omitempty"`
+	// ProgressPercent is the completion percentage reported by the last
+	// progress update, if it can be computed.
+	ProgressPercent *float64 `json:"progress_percent,omitempty"`
+	// Restarts is the number of times the child has been restarted.
+	Restarts int `json:"restarts"`
+	// PausedAt is set while the child is paused, see "Pause".
+	PausedAt *time.Time `json:"paused_at,omitempty"`
+}
+
+// StatusFunc returns the current state of the child process.
+type StatusFunc func() ChildStatus
+
+// healthHandler reports the status returned by "status", or a static message if
+// no status function is available.
+func healthHandler(status StatusFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if status == nil {
+			fmt.Fprintln(w, "Online!")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(status()); err != nil {
+			logError(fmt.Errorf("unable to encode health response: %w", err), http.StatusInternalServerError)
+		}
+	}
+}
+
+// shutdownHandler stops the child using "stop" and reports its final status, if
+// "status" is available.
+func shutdownHandler(stop StopFunc, status StatusFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := stop(r.Context()); err != nil {
+			serveError(w, fmt.Errorf("unable to shutdown child gracefully: %w", err), http.StatusInternalServerError)
+			return
+		}
+		if status == nil {
+			fmt.Fprintln(w, "Stopped!")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(status()); err != nil {
+			logError(fmt.Errorf("unable to encode shutdown response: %w", err), http.StatusInternalServerError)
+		}
+	}
+}
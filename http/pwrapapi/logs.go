@@ -0,0 +1,94 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrapapi
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/kim-company/pmux/tail"
+)
+
+// DefaultTailLines is the number of lines returned by the log handlers when
+// the "tail" query parameter is not provided.
+const DefaultTailLines = 100
+
+// RouteLogs registers the "/logs/stdout" and "/logs/stderr" routes, serving
+// the content of the files found at "stdout" and "stderr".
+func RouteLogs(stdout, stderr string) func(*Router) {
+	return func(r *Router) {
+		r.HandleFunc("/logs/{stream:stdout|stderr}", LogHandler(map[string]string{
+			"stdout": stdout,
+			"stderr": stderr,
+		})).Methods("GET")
+	}
+}
+
+// LogHandler serves the file associated to the "stream" path variable in "paths".
+// The "tail" query parameter selects the number of trailing lines returned (-1 for
+// the whole file), while "follow=true" keeps the response open, streaming the content
+// appended to the file until the client goes away.
+func LogHandler(paths map[string]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		path, ok := paths[mux.Vars(r)["stream"]]
+		if !ok {
+			serveError(w, fmt.Errorf("unknown log stream %q", mux.Vars(r)["stream"]), http.StatusNotFound)
+			return
+		}
+		opts, err := ParseTailOptions(r)
+		if err != nil {
+			serveError(w, err, http.StatusBadRequest)
+			return
+		}
+		ServeTail(w, r, path, opts)
+	}
+}
+
+// ParseTailOptions parses the "tail" and "follow" query parameters of "r".
+func ParseTailOptions(r *http.Request) (tail.Options, error) {
+	opts := tail.Options{Lines: DefaultTailLines}
+	q := r.URL.Query()
+	if v := q.Get("tail"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return opts, fmt.Errorf("invalid tail parameter %q: %w", v, err)
+		}
+		opts.Lines = n
+	}
+	if v := q.Get("follow"); v != "" {
+		ok, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, fmt.Errorf("invalid follow parameter %q: %w", v, err)
+		}
+		opts.Follow = ok
+	}
+	return opts, nil
+}
+
+// ServeTail writes the tail of the file at "path" to "w", as described by "opts".
+func ServeTail(w http.ResponseWriter, r *http.Request, path string, opts tail.Options) {
+	if f, ok := w.(http.Flusher); ok {
+		opts.Flush = f.Flush
+	} else if opts.Follow {
+		serveError(w, fmt.Errorf("webserver doesn't support streaming"), http.StatusInternalServerError)
+		return
+	}
+
+	if opts.Follow {
+		// Following responses are meant to outlive the write timeout of
+		// the server, if any.
+		http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if err := tail.File(r.Context(), w, path, opts); err != nil {
+		// Headers might have been written already.
+		logError(err, http.StatusInternalServerError)
+	}
+}
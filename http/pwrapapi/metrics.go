@@ -0,0 +1,105 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrapapi
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// clockTicks is the number of clock ticks per second used by the kernel to
+// account process CPU times in /proc. It is 100 on every supported platform.
+const clockTicks = 100
+
+// ProcStats contains resource usage information of a process.
+type ProcStats struct {
+	CPUSeconds float64
+	RSSBytes   int64
+}
+
+// ReadProcStats reads resource usage information of process "pid" from /proc.
+// It is only supported on Linux.
+func ReadProcStats(pid int) (ProcStats, error) {
+	var s ProcStats
+
+	stat, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return s, fmt.Errorf("unable to read process stats: %w", err)
+	}
+	// The command name, second field, may contain spaces: skip it.
+	i := bytes.LastIndexByte(stat, ')')
+	if i < 0 {
+		return s, fmt.Errorf("unable to parse process stats")
+	}
+	fields := strings.Fields(string(stat[i+1:]))
+	// utime and stime are the 14th and 15th fields, starting from 1.
+	if len(fields) < 13 {
+		return s, fmt.Errorf("unable to parse process stats: too few fields")
+	}
+	utime, err := strconv.ParseFloat(fields[11], 64)
+	if err != nil {
+		return s, fmt.Errorf("unable to parse utime: %w", err)
+	}
+	stime, err := strconv.ParseFloat(fields[12], 64)
+	if err != nil {
+		return s, fmt.Errorf("unable to parse stime: %w", err)
+	}
+	s.CPUSeconds = (utime + stime) / clockTicks
+
+	statm, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/statm", pid))
+	if err != nil {
+		return s, fmt.Errorf("unable to read process memory stats: %w", err)
+	}
+	mfields := strings.Fields(string(statm))
+	if len(mfields) < 2 {
+		return s, fmt.Errorf("unable to parse process memory stats")
+	}
+	pages, err := strconv.ParseInt(mfields[1], 10, 64)
+	if err != nil {
+		return s, fmt.Errorf("unable to parse resident pages: %w", err)
+	}
+	s.RSSBytes = pages * int64(os.Getpagesize())
+	return s, nil
+}
+
+// metricsHandler exposes the child's status and resource usage in the Prometheus
+// text exposition format.
+func metricsHandler(status StatusFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s := status()
+		buf := &bytes.Buffer{}
+		metric := func(name, kind, help string, v float64) {
+			fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n%s %s\n", name, help, name, kind, name, strconv.FormatFloat(v, 'g', -1, 64))
+		}
+
+		up := 0.0
+		if s.Running {
+			up = 1
+		}
+		metric("pmux_child_up", "gauge", "Whether the child process is running.", up)
+		metric("pmux_child_uptime_seconds", "gauge", "Time elapsed since the child process started.", s.UptimeSeconds)
+		metric("pmux_child_restarts_total", "counter", "Number of times the child process has been restarted.", float64(s.Restarts))
+		if s.ProgressPercent != nil {
+			metric("pmux_child_progress_percent", "gauge", "Completion percentage of the current stage, as reported by the child.", *s.ProgressPercent)
+		}
+		if s.LastProgressAt != nil {
+			metric("pmux_child_last_progress_timestamp_seconds", "gauge", "Time of the last progress update, in seconds since the epoch.", float64(s.LastProgressAt.UnixNano())/1e9)
+		}
+		if s.Running {
+			if ps, err := ReadProcStats(s.PID); err == nil {
+				metric("pmux_child_cpu_seconds_total", "counter", "Total user and system CPU time spent by the child process.", ps.CPUSeconds)
+				metric("pmux_child_resident_memory_bytes", "gauge", "Resident memory size of the child process.", float64(ps.RSSBytes))
+			}
+		}
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Write(buf.Bytes())
+	}
+}
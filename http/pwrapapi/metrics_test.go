@@ -0,0 +1,51 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrapapi
+
+import (
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestReadProcStats(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS != "linux" {
+		t.Skip("/proc is only available on linux")
+	}
+
+	s, err := ReadProcStats(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.RSSBytes <= 0 {
+		t.Fatalf("Unexpected resident memory: %d", s.RSSBytes)
+	}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	t.Parallel()
+
+	percent := 42.5
+	h := metricsHandler(func() ChildStatus {
+		return ChildStatus{PID: os.Getpid(), Running: true, ProgressPercent: &percent, Restarts: 2}
+	})
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	body := w.Body.String()
+	for _, v := range []string{
+		"pmux_child_up 1\n",
+		"pmux_child_restarts_total 2\n",
+		"pmux_child_progress_percent 42.5\n",
+		"# TYPE pmux_child_uptime_seconds gauge\n",
+	} {
+		if !strings.Contains(body, v) {
+			t.Fatalf("Metrics SHOULD contain %q, found:\n%s", v, body)
+		}
+	}
+}
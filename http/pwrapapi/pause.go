@@ -0,0 +1,80 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrapapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// The ways the child is paused: stopping its processes, with SIGSTOP, or asking
+// it to pause itself, delivering the "CommandPause" command.
+const (
+	PauseSignal  = "signal"
+	PauseCommand = "command"
+)
+
+// ErrPauseState is returned by "PauseFunc"s when the child cannot be paused or
+// resumed in its current state, e.g. when it is paused already.
+var ErrPauseState = errors.New("invalid child state")
+
+// ErrCommandRejected is returned when the child refuses a command, e.g. the pause
+// command of children that cannot pause themselves.
+var ErrCommandRejected = errors.New("command rejected")
+
+// PauseFunc pauses the child in "mode", either "PauseSignal" or "PauseCommand",
+// or resumes it if "resume" is set, the way it was paused.
+type PauseFunc func(ctx context.Context, mode string, resume bool) error
+
+// Pause enables the "/pause" and "/resume" routes, which suspend and resume the
+// child using "f".
+func Pause(f PauseFunc) func(*Router) {
+	return func(r *Router) {
+		r.HandleFunc("/pause", func(w http.ResponseWriter, req *http.Request) {
+			pauseHandler(f, r.status, false)(w, req)
+		}).Methods("POST")
+		r.HandleFunc("/resume", func(w http.ResponseWriter, req *http.Request) {
+			pauseHandler(f, r.status, true)(w, req)
+		}).Methods("POST")
+	}
+}
+
+// pauseHandler pauses the child using "pause", in the mode selected by the "mode"
+// query parameter, or resumes it, and reports its status if "status" is available.
+func pauseHandler(pause PauseFunc, status StatusFunc, resume bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mode := r.URL.Query().Get("mode")
+		switch mode {
+		case "":
+			mode = PauseSignal
+		case PauseSignal, PauseCommand:
+		default:
+			serveError(w, fmt.Errorf("invalid pause mode %q", mode), http.StatusBadRequest)
+			return
+		}
+		if err := pause(r.Context(), mode, resume); err != nil {
+			code := http.StatusInternalServerError
+			switch {
+			case errors.Is(err, ErrPauseState):
+				code = http.StatusConflict
+			case errors.Is(err, ErrCommandRejected):
+				code = http.StatusUnprocessableEntity
+			}
+			serveError(w, err, code)
+			return
+		}
+		if status == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(status()); err != nil {
+			logError(fmt.Errorf("unable to encode pause response: %w", err), http.StatusInternalServerError)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrapapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPauseHandler(t *testing.T) {
+	t.Parallel()
+
+	paused := ""
+	pause := func(_ context.Context, mode string, resume bool) error {
+		if resume == (paused == "") {
+			return fmt.Errorf("%w: paused %v", ErrPauseState, paused != "")
+		}
+		if paused = mode; resume {
+			paused = ""
+		}
+		return nil
+	}
+	srv := httptest.NewServer(NewRouter(Pause(pause)))
+	defer srv.Close()
+
+	for _, tt := range []struct {
+		path   string
+		status int
+		paused string
+	}{
+		{"/resume", http.StatusConflict, ""},
+		{"/pause?mode=freeze", http.StatusBadRequest, ""},
+		{"/pause?mode=command", http.StatusNoContent, PauseCommand},
+		{"/pause", http.StatusConflict, PauseCommand},
+		{"/resume", http.StatusNoContent, ""},
+		{"/pause", http.StatusNoContent, PauseSignal},
+	} {
+		resp, err := http.Post(srv.URL+tt.path, "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.status || paused != tt.paused {
+			t.Fatalf("%s: wanted status %d and mode %q, found %d and %q", tt.path, tt.status, tt.paused, resp.StatusCode, paused)
+		}
+	}
+}
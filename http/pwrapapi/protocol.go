@@ -0,0 +1,108 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrapapi
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProgressProtocol is the latest version of the progress protocol, which clients
+// request with the "v" key of the handshake header, e.g. "mode=progress;v=2".
+// Bridges answer with a "v=<version>" line, the version they speak, which is
+// the requested one at most, before the first update, or with an "error=<reason>"
+// line if they cannot speak it. Version 1 is the protocol of the children that
+// predate the negotiation: they ignore the key and do not answer.
+const ProgressProtocol = 2
+
+// handshakeTimeout is the time waited for the answer of the bridge, after which
+// the child is assumed to speak version 1 of the progress protocol.
+const handshakeTimeout = time.Second
+
+// ProgressConn is a connection to the progress stream of a child, positioned at
+// the beginning of its updates.
+type ProgressConn struct {
+	net.Conn
+	r io.Reader
+	// Version is the version of the progress protocol spoken by the child.
+	Version int
+}
+
+func (c *ProgressConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+// ParseProtocolVersion parses the version of the progress protocol "s", which
+// has to be positive.
+func ParseProtocolVersion(s string) (int, error) {
+	v, err := strconv.Atoi(s)
+	if err != nil || v < 1 {
+		return 0, fmt.Errorf("invalid progress protocol version %q", s)
+	}
+	return v, nil
+}
+
+// OpenProgress opens the progress stream of the child, encoded in "format" and
+// negotiating "version" of the progress protocol. Children that do not answer
+// the negotiation speak version 1.
+func (b Bridge) OpenProgress(format string, version int) (*ProgressConn, error) {
+	header := "mode=progress"
+	if format != "" {
+		header += ";format=" + format
+	}
+	if version < 2 {
+		conn, err := b.Open(header)
+		if err != nil {
+			return nil, err
+		}
+		return &ProgressConn{Conn: conn, r: conn, Version: 1}, nil
+	}
+	conn, err := b.Open(header + ";v=" + strconv.Itoa(version))
+	if err != nil {
+		return nil, err
+	}
+	c, err := negotiate(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// negotiate reads the answer of the bridge to the protocol version requested on
+// "conn". Whatever is read from children that do not answer is kept, as it is
+// the beginning of their updates.
+func negotiate(conn net.Conn) (*ProgressConn, error) {
+	conn.SetReadDeadline(time.Now().Add(handshakeTimeout))
+	r := bufio.NewReader(conn)
+	line, err := r.ReadString('\n')
+	var ne net.Error
+	if err != nil && !errors.Is(err, io.EOF) && !(errors.As(err, &ne) && ne.Timeout()) {
+		return nil, fmt.Errorf("unable to read handshake answer: %w", err)
+	}
+	// Children that already closed the connection cannot clear the deadline,
+	// which is reported by the next read anyway.
+	conn.SetReadDeadline(time.Time{})
+	if err == nil {
+		answer := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(answer, "v="):
+			v, err := ParseProtocolVersion(strings.TrimPrefix(answer, "v="))
+			if err != nil {
+				return nil, err
+			}
+			return &ProgressConn{Conn: conn, r: r, Version: v}, nil
+		case strings.HasPrefix(answer, "error="):
+			return nil, fmt.Errorf("progress protocol refused by the child: %s", strings.TrimPrefix(answer, "error="))
+		}
+	}
+	return &ProgressConn{Conn: conn, r: io.MultiReader(strings.NewReader(line), r), Version: 1}, nil
+}
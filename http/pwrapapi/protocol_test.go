@@ -0,0 +1,79 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrapapi
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBridge_OpenProgress(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		data    string
+		version int
+		updates string
+	}{
+		{"v=2\na,0,0,1,1\n", 2, "a,0,0,1,1\n"},
+		// Children predating the negotiation do not answer.
+		{"a,0,0,1,1\n", 1, "a,0,0,1,1\n"},
+		{"", 1, ""},
+	} {
+		conn, err := pipeBridge(tt.data).OpenProgress("", ProgressProtocol)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.data, err)
+		}
+		b, err := io.ReadAll(conn)
+		conn.Close()
+		if err != nil {
+			t.Fatalf("%q: %v", tt.data, err)
+		}
+		if conn.Version != tt.version || string(b) != tt.updates {
+			t.Fatalf("%q: wanted version %d and %q, found %d and %q", tt.data, tt.version, tt.updates, conn.Version, b)
+		}
+	}
+
+	if _, err := pipeBridge("error=unsupported\n").OpenProgress("", ProgressProtocol); err == nil {
+		t.Fatalf("Refused versions SHOULD be reported")
+	}
+}
+
+func TestProgressStreamHandler_Version(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(NewRouter(RouteBridge(pipeBridge("v=2\n{}\n"))))
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/progress?format=json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "application/x-ndjson; v=2" {
+		t.Fatalf("The content type SHOULD carry the version, found %q", ct)
+	}
+
+	resp, err = http.Get(srv.URL + "/progress?v=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Invalid versions SHOULD be rejected, found status %d", resp.StatusCode)
+	}
+
+	for _, v := range []string{"xml", "csv%3Bmode%3Dcommand", "json%0Av%3D1"} {
+		resp, err = http.Get(srv.URL + "/progress?format=" + v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("%q: unknown formats SHOULD be rejected, found status %d", v, resp.StatusCode)
+		}
+	}
+}
@@ -5,34 +5,73 @@
 package pwrapapi
 
 import (
-	"bytes"
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
-	"net"
 	"net/http"
 	"net/http/httputil"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 )
 
 type Router struct {
 	*mux.Router
+	status    StatusFunc
+	streaming StreamMode
+	// ops are the operations accepted by the command route.
+	ops []string
 }
 
+// StopFunc asks the child to terminate gracefully and waits for it to exit.
+type StopFunc func(context.Context) error
+
+// Stop enables the "/shutdown" route, which terminates the child using "f".
+func Stop(f StopFunc) func(*Router) {
+	return func(r *Router) {
+		r.HandleFunc("/shutdown", func(w http.ResponseWriter, req *http.Request) {
+			shutdownHandler(f, r.status)(w, req)
+		}).Methods("POST")
+	}
+}
+
+// Status makes the health check route report the child's status returned by "f",
+// and enables the "/metrics" route.
+func Status(f StatusFunc) func(*Router) {
+	return func(r *Router) {
+		r.status = f
+		r.HandleFunc("/metrics", metricsHandler(f)).Methods("GET")
+	}
+}
+
+// RouteProgress registers the progress and command routes, connected to the
+// unix socket found at "path".
 func RouteProgress(path string) func(*Router) {
+	return RouteBridge(Bridge{Dial: unixDialer(path)})
+}
+
+// RouteBridge registers the progress and command routes, connected to the
+// child's communication bridge "b".
+func RouteBridge(b Bridge) func(*Router) {
 	return func(r *Router) {
-		r.HandleFunc("/progress", progressStreamHandler(path)).Methods("GET")
-		r.HandleFunc("/command", commandHandler(path)).Methods("POST")
+		r.HandleFunc("/progress", progressStreamHandler(b, r)).Methods("GET")
+		r.HandleFunc("/streams/{channel}", channelStreamHandler(b, r)).Methods("GET")
+		r.HandleFunc("/command", commandHandler(b, r)).Methods("POST")
 	}
 }
 
 func NewRouter(opts ...func(*Router)) *Router {
 	r := &Router{Router: mux.NewRouter()}
 	r.Use(loggingMiddleware)
-	r.HandleFunc("/health_check", func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprintln(w, "Online!")
+	r.HandleFunc("/health_check", func(w http.ResponseWriter, req *http.Request) {
+		healthHandler(r.status)(w, req)
 	}).Methods("GET")
 
 	for _, f := range opts {
@@ -59,45 +98,131 @@ func logError(err error, status int) {
 	log.Printf("[ERROR] [STATUS %d] %v", status, err)
 }
 
-func progressStreamHandler(sockPath string) http.HandlerFunc {
+func progressStreamHandler(b Bridge, rt *Router) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		sock, err := net.Dial("unix", sockPath)
+		// Clients may choose the progress encoding using the "format" query
+		// parameter, and the version of the progress protocol using the "v"
+		// one, which are forwarded to the socket. The version spoken by the
+		// child is reported in the content type, e.g. "text/csv; v=2".
+		contentType := "text/csv"
+		format := r.URL.Query().Get("format")
+		switch format {
+		case "", "csv":
+		case "json":
+			contentType = "application/x-ndjson"
+		default:
+			serveError(w, fmt.Errorf("unknown progress format %q", format), http.StatusBadRequest)
+			return
+		}
+		version := ProgressProtocol
+		if v := r.URL.Query().Get("v"); v != "" {
+			var err error
+			if version, err = ParseProtocolVersion(v); err != nil {
+				serveError(w, err, http.StatusBadRequest)
+				return
+			}
+		}
+
+		sock, err := b.OpenProgress(format, version)
 		if err != nil {
 			serveError(w, fmt.Errorf("unable to open progress socket: %w", err), http.StatusInternalServerError)
 			return
 		}
-		header := []byte("mode=progress\n")
-		sock.Write(header)
 		defer sock.Close()
-		hijackCopy(w, sock, "text/csv")
+		streamCopy(w, r, sock, contentType+"; v="+strconv.Itoa(sock.Version), rt.streaming)
 	}
 }
 
-func commandHandler(sockPath string) http.HandlerFunc {
+// channelStreamHandler streams the content the child publishes on a named
+// channel of its communication bridge.
+func channelStreamHandler(b Bridge, rt *Router) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["channel"]
+		if strings.ContainsAny(name, ";=\n") {
+			serveError(w, fmt.Errorf("invalid channel name %q", name), http.StatusBadRequest)
+			return
+		}
+		sock, err := b.Open("mode=stream;channel=" + name)
+		if err != nil {
+			serveError(w, fmt.Errorf("unable to open stream socket: %w", err), http.StatusInternalServerError)
+			return
+		}
+		defer sock.Close()
+		streamCopy(w, r, sock, "application/octet-stream", rt.streaming)
+	}
+}
+
+// commandTimeout is the maximum amount of time the command handler waits for
+// the child to respond to a command.
+const commandTimeout = time.Second * 30
+
+// commandHandler delivers the command envelope of the request body, see "Command",
+// to the child, provided that its operation is allowed by "rt".
+func commandHandler(b Bridge, rt *Router) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		defer r.Body.Close()
 
-		sock, err := net.Dial("unix", sockPath)
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			serveError(w, fmt.Errorf("unable to read command: %w", err), http.StatusBadRequest)
+			return
+		}
+		cmd, err := ParseCommand(data)
+		if err != nil {
+			serveError(w, err, http.StatusBadRequest)
+			return
+		}
+		if !rt.allowed(cmd.Op) {
+			serveError(w, fmt.Errorf("command operation %q is not allowed", cmd.Op), http.StatusForbidden)
+			return
+		}
+		payload, err := cmd.Encode()
+		if err != nil {
+			serveError(w, err, http.StatusBadRequest)
+			return
+		}
+
+		sock, err := b.Open("mode=command")
 		if err != nil {
-			io.Copy(ioutil.Discard, r.Body)
 			serveError(w, fmt.Errorf("unable to open progress socket: %w", err), http.StatusInternalServerError)
 			return
 		}
 		defer sock.Close()
 
-		w.WriteHeader(http.StatusOK)
-		buf := bytes.NewBuffer([]byte("mode=command\n"))
-		_, err = io.Copy(buf, r.Body)
+		_, err = sock.Write(payload)
 		if err != nil {
-			logError(fmt.Errorf("unable to complete copy: %w", err), http.StatusInternalServerError)
+			serveError(w, fmt.Errorf("unable to write command: %w", err), http.StatusInternalServerError)
 			return
 		}
-		buf.Write([]byte("\n"))
-		_, err = io.Copy(sock, buf)
-		if err != nil {
-			logError(fmt.Errorf("unable to complete copy: %w", err), http.StatusInternalServerError)
+
+		// Wait for the child to process the command and report back.
+		sock.SetReadDeadline(time.Now().Add(commandTimeout))
+		line, err := bufio.NewReader(sock).ReadBytes('\n')
+		if err != nil && len(line) == 0 {
+			if errors.Is(err, io.EOF) {
+				// The child closed the connection without responding: the
+				// command was delivered but we know nothing more.
+				w.WriteHeader(http.StatusAccepted)
+				return
+			}
+			serveError(w, fmt.Errorf("unable to read command response: %w", err), http.StatusGatewayTimeout)
 			return
 		}
+
+		var resp struct {
+			OK bool `json:"ok"`
+		}
+		if err := json.Unmarshal(line, &resp); err != nil {
+			serveError(w, fmt.Errorf("unable to decode command response: %w", err), http.StatusBadGateway)
+			return
+		}
+		status := http.StatusOK
+		if !resp.OK {
+			status = http.StatusUnprocessableEntity
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(line)
 	}
 }
 
@@ -118,12 +243,15 @@ func hijackCopy(w http.ResponseWriter, src io.Reader, contentType string) {
 	}
 	cw := httputil.NewChunkedWriter(conn)
 	defer conn.Close()
-	defer cw.Close()
 
 	n, err := io.Copy(cw, src)
 	if err != nil {
 		logError(fmt.Errorf("unable to complete copy: %w", err), http.StatusInternalServerError)
 		return
 	}
+	// The last chunk is followed by the empty trailer, which the chunked
+	// writer leaves to the caller.
+	cw.Close()
+	io.WriteString(conn, "\r\n")
 	log.Printf("[INFO] copy: #%d bytes transferred", n)
 }
@@ -5,7 +5,10 @@
 package pwrapapi
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 )
 
@@ -14,14 +17,122 @@ import (
 // Each server tracks only one child cmd.
 type Server struct {
 	*http.Server
-	port int
-	r    *Router
+	port   int
+	r      *Router
+	bridge Bridge
 }
 
+// Child makes the health check route report the child's status returned by "f".
+func Child(f StatusFunc) func(*Server) {
+	return func(s *Server) {
+		Status(f)(s.r)
+	}
+}
+
+// ChildStop enables the "/shutdown" route, which terminates the child using "f".
+func ChildStop(f StopFunc) func(*Server) {
+	return func(s *Server) {
+		Stop(f)(s.r)
+	}
+}
+
+// ChildPause enables the "/pause" and "/resume" routes, which suspend and resume
+// the child using "f".
+func ChildPause(f PauseFunc) func(*Server) {
+	return func(s *Server) {
+		Pause(f)(s.r)
+	}
+}
+
+// ChildReload enables the "PUT /config" route, which replaces the configuration of
+// the child using "f".
+func ChildReload(f ReloadFunc) func(*Server) {
+	return func(s *Server) {
+		Reload(f)(s.r)
+	}
+}
+
+// ConfigPath enables the "/config" route, serving the child's configuration file.
+func ConfigPath(path string) func(*Server) {
+	return func(s *Server) {
+		RouteConfig(path)(s.r)
+	}
+}
+
+// WorkDir enables the "/files" routes, serving the files found in the
+// child's working directory.
+func WorkDir(dir string) func(*Server) {
+	return func(s *Server) {
+		RouteFiles(dir)(s.r)
+	}
+}
+
+// Stdin enables the "/stdin" route, streaming data into "w", the stdin of the
+// child. The route is not enabled if nil.
+func Stdin(w io.WriteCloser) func(*Server) {
+	return func(s *Server) {
+		RouteStdin(w)(s.r)
+	}
+}
+
+// LogPaths enables the log routes, serving the stdout and stderr files of the child.
+func LogPaths(stdout, stderr string) func(*Server) {
+	return func(s *Server) {
+		RouteLogs(stdout, stderr)(s.r)
+	}
+}
+
+// CmdSockPath connects the progress and command routes to the unix socket
+// found at "path".
 func CmdSockPath(path string) func(*Server) {
 	return func(s *Server) {
-		RouteProgress(path)(s.r)
-		// TODO: Add also command route to deliver commands.
+		s.bridge.Dial = unixDialer(path)
+	}
+}
+
+// CmdAddr connects the progress and command routes to the communication bridge
+// listening on "addr" using "transport", which is either "unix", "tcp" or "pipe".
+func CmdAddr(transport, addr string) func(*Server) {
+	return func(s *Server) {
+		s.bridge.Dial = NewDialer(transport, addr)
+	}
+}
+
+// CmdBridge connects the progress and command routes to the communication bridge "b".
+func CmdBridge(b Bridge) func(*Server) {
+	return func(s *Server) {
+		s.bridge = b
+	}
+}
+
+// CmdToken sets the token presented to the communication bridge when connecting.
+func CmdToken(token string) func(*Server) {
+	return func(s *Server) {
+		s.bridge.Token = token
+	}
+}
+
+// CmdOps restricts the operations accepted by the command route to "ops", see
+// "CommandOps".
+func CmdOps(ops []string) func(*Server) {
+	return func(s *Server) {
+		CommandOps(ops)(s.r)
+	}
+}
+
+// APIToken requires clients to authenticate on every route using "token" as
+// bearer token.
+func APIToken(token string) func(*Server) {
+	return func(s *Server) {
+		AuthToken(token)(s.r)
+	}
+}
+
+// Streaming sets how the progress and stream routes deliver their content.
+// Defaults to "StreamAuto".
+func Streaming(m StreamMode) func(*Server) {
+	return func(s *Server) {
+		RouteStreaming(m)(s.r)
 	}
 }
 
@@ -39,10 +150,18 @@ func NewServer(opts ...func(*Server)) *Server {
 	for _, f := range opts {
 		f(s)
 	}
+	if s.bridge.Dial != nil {
+		RouteBridge(s.bridge)(s.r)
+	}
 
+	// Requests that stream data until their client goes away are
+	// interrupted when the server is shutting down.
+	ctx, cancel := context.WithCancel(context.Background())
 	s.Server = &http.Server{
-		Addr:    fmt.Sprintf(":%d", s.port),
-		Handler: s.r,
+		Addr:        fmt.Sprintf(":%d", s.port),
+		Handler:     s.r,
+		BaseContext: func(net.Listener) context.Context { return ctx },
 	}
+	s.RegisterOnShutdown(cancel)
 	return s
 }
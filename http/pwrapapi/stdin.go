@@ -0,0 +1,77 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrapapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"syscall"
+)
+
+// RouteStdin registers the "/stdin" route, streaming the body of the requests
+// into "w", the stdin of the child. The route is not registered if "w" is nil.
+func RouteStdin(w io.WriteCloser) func(*Router) {
+	return func(r *Router) {
+		if w == nil {
+			return
+		}
+		r.HandleFunc("/stdin", StdinHandler(w)).Methods("POST")
+	}
+}
+
+// stdinWriter records the errors of the writes to the stdin of the child, telling
+// them apart from those of the request body.
+type stdinWriter struct {
+	io.Writer
+	err error
+}
+
+func (w *stdinWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	if err != nil {
+		w.err = err
+	}
+	return n, err
+}
+
+// StdinHandler writes the body of the requests to "w" while it is received.
+// Requests are served one at a time, so that their data is not interleaved.
+// With the "close" query parameter set to true, "w" is closed afterwards and
+// the child reads the end of its input.
+func StdinHandler(w io.WriteCloser) http.HandlerFunc {
+	var mu sync.Mutex
+	return func(rw http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+		mu.Lock()
+		defer mu.Unlock()
+
+		sw := &stdinWriter{Writer: w}
+		n, err := io.Copy(sw, r.Body)
+		if err == nil && r.URL.Query().Get("close") == "true" {
+			sw.err = w.Close()
+			err = sw.err
+		}
+		switch {
+		case err != nil && sw.err != nil && (errors.Is(sw.err, os.ErrClosed) || errors.Is(sw.err, syscall.EPIPE)):
+			serveError(rw, fmt.Errorf("stdin of the child is closed: %w", sw.err), http.StatusGone)
+			return
+		case err != nil && sw.err != nil:
+			serveError(rw, fmt.Errorf("unable to write to stdin: %w", sw.err), http.StatusInternalServerError)
+			return
+		case err != nil:
+			serveError(rw, fmt.Errorf("unable to read request body: %w", err), http.StatusBadRequest)
+			return
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rw).Encode(&struct {
+			Bytes int64 `json:"bytes"`
+		}{n})
+	}
+}
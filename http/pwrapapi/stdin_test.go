@@ -0,0 +1,57 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrapapi
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestStdinHandler(t *testing.T) {
+	t.Parallel()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	read := make(chan string, 1)
+	go func() {
+		b, _ := io.ReadAll(r)
+		read <- string(b)
+	}()
+
+	router := NewRouter(RouteStdin(w))
+	for i, tt := range []struct {
+		body   string
+		query  string
+		status int
+	}{
+		{"pause\n", "", http.StatusOK},
+		{"resume\n", "?close=true", http.StatusOK},
+		{"stop\n", "", http.StatusGone},
+	} {
+		req := httptest.NewRequest("POST", "/stdin"+tt.query, strings.NewReader(tt.body))
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != tt.status {
+			t.Fatalf("%d: wanted status %d, found %d: %s", i, tt.status, rec.Code, rec.Body)
+		}
+	}
+	if s := <-read; s != "pause\nresume\n" {
+		t.Fatalf("the child SHOULD read the data written before closing its stdin, found %q", s)
+	}
+
+	req := httptest.NewRequest("POST", "/stdin", strings.NewReader("data"))
+	rec := httptest.NewRecorder()
+	NewRouter(RouteStdin(nil)).ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("the stdin route SHOULD NOT be registered without a pipe, found status %d", rec.Code)
+	}
+}
@@ -0,0 +1,115 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrapapi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// StreamMode describes how the progress and stream routes deliver the content
+// of the child to their clients.
+type StreamMode string
+
+const (
+	// StreamAuto hijacks HTTP/1.x connections, and falls back to StreamFlush
+	// on those that cannot be hijacked, e.g. HTTP/2 ones.
+	StreamAuto StreamMode = ""
+	// StreamHijack takes over the connection, writing the chunked encoding
+	// itself.
+	StreamHijack StreamMode = "hijack"
+	// StreamFlush writes a regular response, flushed after every write. It
+	// works behind HTTP/2 and proxies that do not support hijacked connections.
+	StreamFlush StreamMode = "flush"
+)
+
+// ParseStreamMode parses "s", which is either "auto", "hijack" or "flush".
+func ParseStreamMode(s string) (StreamMode, error) {
+	switch m := StreamMode(s); m {
+	case StreamAuto, StreamHijack, StreamFlush:
+		return m, nil
+	case "auto":
+		return StreamAuto, nil
+	default:
+		return "", fmt.Errorf("unsupported stream mode %q", s)
+	}
+}
+
+// String returns the name of "m", as parsed by "ParseStreamMode".
+func (m StreamMode) String() string {
+	if m == StreamAuto {
+		return "auto"
+	}
+	return string(m)
+}
+
+// RouteStreaming sets how the progress and stream routes deliver their content.
+// Defaults to "StreamAuto".
+func RouteStreaming(m StreamMode) func(*Router) {
+	return func(r *Router) {
+		r.streaming = m
+	}
+}
+
+// canHijack reports whether the connection of "r" can be hijacked.
+func canHijack(w http.ResponseWriter, r *http.Request) bool {
+	_, ok := w.(http.Hijacker)
+	return ok && r.ProtoMajor == 1
+}
+
+// streamCopy delivers the content of "src" to the client using "mode", until
+// either "src" or the client goes away.
+func streamCopy(w http.ResponseWriter, r *http.Request, src io.ReadCloser, contentType string, mode StreamMode) {
+	if mode == StreamHijack || (mode == StreamAuto && canHijack(w, r)) {
+		hijackCopy(w, src, contentType)
+		return
+	}
+	flushCopy(r.Context(), w, src, contentType)
+}
+
+// flushWriter flushes the response after every write.
+type flushWriter struct {
+	w  io.Writer
+	rc *http.ResponseController
+}
+
+func (f *flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if err != nil {
+		return n, err
+	}
+	return n, f.rc.Flush()
+}
+
+// flushCopy is like hijackCopy, writing a regular response instead. "src" is
+// closed once "ctx" is done, as the client going away would otherwise only be
+// noticed on the next write.
+func flushCopy(ctx context.Context, w http.ResponseWriter, src io.ReadCloser, contentType string) {
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", contentType)
+	// Ask buffering proxies, e.g. nginx, to deliver the stream as it comes.
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		logError(fmt.Errorf("webserver doesn't support flushing: %w", err), http.StatusInternalServerError)
+		return
+	}
+	// The response outlives the write timeout of the server.
+	rc.SetWriteDeadline(time.Time{})
+
+	stop := context.AfterFunc(ctx, func() { src.Close() })
+	defer stop()
+
+	n, err := io.Copy(&flushWriter{w: w, rc: rc}, src)
+	if err != nil && ctx.Err() == nil {
+		logError(fmt.Errorf("unable to complete copy: %w", err), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("[INFO] copy: #%d bytes transferred", n)
+}
@@ -0,0 +1,86 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrapapi
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http/httptest"
+	"testing"
+)
+
+// pipeBridge returns a bridge whose connections write "data" after reading the
+// handshake header, then close.
+func pipeBridge(data string) Bridge {
+	return Bridge{Dial: func() (net.Conn, error) {
+		client, child := net.Pipe()
+		go func() {
+			defer child.Close()
+			if _, err := bufio.NewReader(child).ReadString('\n'); err != nil {
+				return
+			}
+			io.WriteString(child, data)
+		}()
+		return client, nil
+	}}
+}
+
+func TestProgressStreamHandler_Streaming(t *testing.T) {
+	t.Parallel()
+
+	for i, tt := range []struct {
+		mode  StreamMode
+		http2 bool
+		proto string
+		flush bool
+	}{
+		{StreamAuto, false, "HTTP/1.1", false},
+		{StreamAuto, true, "HTTP/2.0", true},
+		{StreamFlush, false, "HTTP/1.1", true},
+		{StreamHijack, false, "HTTP/1.1", false},
+	} {
+		srv := httptest.NewUnstartedServer(NewRouter(RouteStreaming(tt.mode), RouteBridge(pipeBridge("a,0,0,1,1\n"))))
+		srv.EnableHTTP2 = tt.http2
+		srv.StartTLS()
+
+		resp, err := srv.Client().Get(srv.URL + "/progress")
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		b, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		srv.Close()
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		if string(b) != "a,0,0,1,1\n" {
+			t.Fatalf("%d: unexpected stream %q", i, b)
+		}
+		if resp.Proto != tt.proto {
+			t.Fatalf("%d: wanted %s, found %s", i, tt.proto, resp.Proto)
+		}
+		if flushed := resp.Header.Get("X-Accel-Buffering") == "no"; flushed != tt.flush {
+			t.Fatalf("%d: the stream SHOULD be flushed: %v, found %v", i, tt.flush, flushed)
+		}
+	}
+}
+
+func TestParseStreamMode(t *testing.T) {
+	t.Parallel()
+
+	for s, want := range map[string]StreamMode{"": StreamAuto, "auto": StreamAuto, "hijack": StreamHijack, "flush": StreamFlush} {
+		m, err := ParseStreamMode(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m != want {
+			t.Fatalf("%q: wanted %v, found %v", s, want, m)
+		}
+	}
+	if _, err := ParseStreamMode("chunked"); err == nil {
+		t.Fatal("unknown stream modes SHOULD NOT be accepted")
+	}
+}
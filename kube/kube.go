@@ -0,0 +1,149 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+// Package kube provides an interface for the subset of kubectl functions needed
+// to run sessions as Kubernetes Jobs.
+package kube
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kubectl is the command used to reach the cluster. It uses the configuration
+// found in the environment, i.e. $KUBECONFIG or the service account of the pod
+// the server runs in.
+var Kubectl = "kubectl"
+
+// LabelSID is the label carrying the session identifier of the resources
+// created for a session.
+const LabelSID = "pmux/sid"
+
+const defaultCmdExecTimeout = time.Second * 10
+
+// ErrNotFound is returned when the requested resource does not exist.
+var ErrNotFound = errors.New("resource not found")
+
+// run executes kubectl with "args", feeding it "stdin" if not nil, and
+// returns its output.
+func run(stdin []byte, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultCmdExecTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, Kubectl, args...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if strings.Contains(msg, "NotFound") {
+			return nil, fmt.Errorf("%s: %w", msg, ErrNotFound)
+		}
+		return nil, fmt.Errorf("kubectl %s: %w: %s", args[0], err, msg)
+	}
+	return stdout.Bytes(), nil
+}
+
+// Apply creates or updates the resources of "manifest", a JSON or YAML document.
+func Apply(manifest []byte) error {
+	if _, err := run(manifest, "apply", "--filename=-"); err != nil {
+		return fmt.Errorf("unable to apply manifest: %w", err)
+	}
+	return nil
+}
+
+// Replace deletes the resources of "manifest", a JSON or YAML document, if they
+// exist and creates them again. The old resources are not given the chance to
+// terminate gracefully.
+func Replace(manifest []byte) error {
+	if _, err := run(manifest, "delete", "--filename=-", "--ignore-not-found", "--wait", "--grace-period=0", "--force"); err != nil {
+		return fmt.Errorf("unable to replace manifest: %w", err)
+	}
+	return Apply(manifest)
+}
+
+// DeleteSession deletes the Job and Secret created for session "sid" in
+// "namespace". Their pods are given "grace" to terminate, and are removed
+// immediately if "grace" is negative.
+func DeleteSession(namespace, sid string, grace time.Duration) error {
+	args := []string{"delete", "job,secret",
+		"--namespace=" + namespace,
+		"--selector=" + LabelSID + "=" + sid,
+		"--wait=false",
+		"--ignore-not-found",
+	}
+	if grace < 0 {
+		args = append(args, "--grace-period=0", "--force")
+	} else {
+		args = append(args, "--grace-period="+strconv.Itoa(int(grace.Seconds())))
+	}
+	if _, err := run(nil, args...); err != nil {
+		return fmt.Errorf("unable to delete session %s: %w", sid, err)
+	}
+	return nil
+}
+
+// JobStatus is the subset of the status of a Job used by pmux.
+type JobStatus struct {
+	Active         int        `json:"active"`
+	Succeeded      int        `json:"succeeded"`
+	Failed         int        `json:"failed"`
+	StartTime      *time.Time `json:"startTime"`
+	CompletionTime *time.Time `json:"completionTime"`
+	Conditions     []struct {
+		Type    string `json:"type"`
+		Status  string `json:"status"`
+		Message string `json:"message"`
+	} `json:"conditions"`
+}
+
+// Finished reports whether the Job completed, either successfully or not.
+func (s *JobStatus) Finished() bool {
+	for _, v := range s.Conditions {
+		if (v.Type == "Complete" || v.Type == "Failed") && v.Status == "True" {
+			return true
+		}
+	}
+	return false
+}
+
+// FailureMessage returns the reason of the Job failure, if any.
+func (s *JobStatus) FailureMessage() string {
+	for _, v := range s.Conditions {
+		if v.Type == "Failed" && v.Status == "True" {
+			return v.Message
+		}
+	}
+	return ""
+}
+
+// GetJob returns the status of Job "name" in "namespace". Returns an error
+// wrapping "ErrNotFound" if the Job does not exist.
+func GetJob(namespace, name string) (*JobStatus, error) {
+	out, err := run(nil, "get", "job", name, "--namespace="+namespace, "--output=json")
+	if err != nil {
+		return nil, fmt.Errorf("unable to get job %s: %w", name, err)
+	}
+	return parseJob(out)
+}
+
+func parseJob(data []byte) (*JobStatus, error) {
+	var job struct {
+		Status JobStatus `json:"status"`
+	}
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, fmt.Errorf("unable to decode job: %w", err)
+	}
+	return &job.Status, nil
+}
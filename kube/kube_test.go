@@ -0,0 +1,29 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package kube
+
+import (
+	"testing"
+)
+
+func TestParseJob(t *testing.T) {
+	t.Parallel()
+
+	s, err := parseJob([]byte(`{"kind": "Job", "status": {"active": 1, "startTime": "2020-01-08T15:28:33Z"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Active != 1 || s.StartTime == nil || s.Finished() {
+		t.Fatalf("unexpected status: %+v", s)
+	}
+
+	s, err = parseJob([]byte(`{"status": {"failed": 1, "conditions": [{"type": "Failed", "status": "True", "message": "Job has reached the specified backoff limit"}]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Finished() || s.FailureMessage() != "Job has reached the specified backoff limit" {
+		t.Fatalf("unexpected status: %+v", s)
+	}
+}
@@ -0,0 +1,98 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// ArgsData is the data the arguments of the child containing "{{" are executed
+// with, as Go templates, right before the child is executed, see "Exec".
+type ArgsData struct {
+	SID string
+	// WorkDir is the working directory of the session, as seen by the
+	// wrapper.
+	WorkDir   string
+	Namespace string
+	Labels    map[string]string
+	// Port is the port the wrapper API is listening on, zero during dry
+	// runs.
+	Port int
+}
+
+// parseArg parses argument "s" as a template, returning nil if it does not
+// contain any action.
+func parseArg(s string) (*template.Template, error) {
+	if !strings.Contains(s, "{{") {
+		return nil, nil
+	}
+	t, err := template.New("arg").Option("missingkey=error").Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid argument template %q: %w", s, err)
+	}
+	return t, nil
+}
+
+// ValidateArgs checks the syntax of the templates in "args". Whether the fields
+// they reference exist is only known when they are executed.
+func ValidateArgs(args []string) error {
+	for _, v := range args {
+		if _, err := parseArg(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// wrapperFlags are the flags the wrapper passes to the child.
+var wrapperFlags = []string{"--config", "--socket-path", "--socket-transport", "--resume"}
+
+// ValidateExtraArgs reports whether "args", appended by clients to the arguments
+// of the executable, are valid templates that do not set the flags the wrapper
+// passes to the child.
+func ValidateExtraArgs(args []string) error {
+	for _, v := range args {
+		for _, f := range wrapperFlags {
+			if v == f || strings.HasPrefix(v, f+"=") {
+				return fmt.Errorf("argument %q is reserved to the wrapper", v)
+			}
+		}
+	}
+	return ValidateArgs(args)
+}
+
+// ExpandArgs returns a copy of "args" whose templates are executed with "data".
+func ExpandArgs(args []string, data *ArgsData) ([]string, error) {
+	acc := make([]string, len(args))
+	for i, v := range args {
+		t, err := parseArg(v)
+		if err != nil {
+			return nil, err
+		}
+		if t == nil {
+			acc[i] = v
+			continue
+		}
+		var b strings.Builder
+		if err := t.Execute(&b, data); err != nil {
+			return nil, fmt.Errorf("unable to execute argument template %q: %w", v, err)
+		}
+		acc[i] = b.String()
+	}
+	return acc, nil
+}
+
+// argsData returns the data the arguments of the child of "p" are executed with.
+func (p *PWrap) argsData(port int) *ArgsData {
+	return &ArgsData{
+		SID:       p.sid,
+		WorkDir:   p.WorkDir(),
+		Namespace: p.namespace,
+		Labels:    p.labels,
+		Port:      port,
+	}
+}
@@ -0,0 +1,63 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpandArgs(t *testing.T) {
+	t.Parallel()
+
+	data := &ArgsData{SID: "pmux-1", WorkDir: "/tmp/pmux-1", Labels: map[string]string{"tenant": "acme"}, Port: 4242}
+	args, err := ExpandArgs([]string{"-v", "--output={{.WorkDir}}/{{.SID}}.mp4", "--api=localhost:{{.Port}}", "{{.Labels.tenant}}"}, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"-v", "--output=/tmp/pmux-1/pmux-1.mp4", "--api=localhost:4242", "acme"}
+	if !reflect.DeepEqual(args, want) {
+		t.Fatalf("Arguments SHOULD be expanded to %q, found %q", want, args)
+	}
+
+	for _, v := range []string{"{{.Unknown}}", "{{.Labels.missing}}", "{{.SID"} {
+		if _, err := ExpandArgs([]string{v}, data); err == nil {
+			t.Fatalf("Expanding %q SHOULD fail", v)
+		}
+	}
+}
+
+func TestChildArgs(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	if _, err := New(RootDir(root), Exec("yes", "{{.SID")); err == nil {
+		t.Fatalf("Invalid argument templates SHOULD be rejected")
+	}
+	pw, err := New(RootDir(root), Exec("yes", "--out={{.SID}}.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	args, err := pw.childArgs("config", "sock", 4242)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args[0] != "--out="+pw.SID()+".log" || args[1] != "--config=config" {
+		t.Fatalf("Unexpected child arguments %q", args)
+	}
+}
+
+func TestValidateExtraArgs(t *testing.T) {
+	t.Parallel()
+
+	if err := ValidateExtraArgs([]string{"-v", "--configuration=x", "--out={{.SID}}"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []string{"--config", "--config=x", "--resume=x", "--socket-transport=tcp", "{{.SID"} {
+		if err := ValidateExtraArgs([]string{v}); err == nil {
+			t.Fatalf("Argument %q SHOULD be rejected", v)
+		}
+	}
+}
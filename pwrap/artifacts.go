@@ -0,0 +1,74 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/kim-company/pmux/artifact"
+)
+
+// Artifacts are the files of the working directory uploaded to object storage
+// once the child exits.
+type Artifacts struct {
+	// Paths are the glob patterns selecting the files, relative to the
+	// working directory.
+	Paths []string `json:"paths"`
+	// Destination is the bucket and prefix the files are uploaded to, e.g.
+	// "s3://bucket/prefix", followed by the session identifier.
+	Destination string `json:"destination"`
+}
+
+// Validate reports whether "a" can be uploaded.
+func (a *Artifacts) Validate() error {
+	if len(a.Paths) == 0 {
+		return fmt.Errorf("artifact paths not set")
+	}
+	for _, v := range a.Paths {
+		if err := artifact.ValidatePattern(v); err != nil {
+			return err
+		}
+	}
+	_, err := artifact.Parse(a.Destination)
+	return err
+}
+
+// UploadArtifacts makes the wrapper upload the artifacts "a" once the child
+// exits, whatever its outcome, reporting their URLs in the session state and in
+// the callback. Nothing is uploaded if nil.
+func UploadArtifacts(a *Artifacts) func(*PWrap) error {
+	return func(p *PWrap) error {
+		if a != nil {
+			if err := a.Validate(); err != nil {
+				return err
+			}
+		}
+		p.artifacts = a
+		return nil
+	}
+}
+
+// uploadArtifacts uploads the artifacts of the session, if any, and records the
+// URLs of those uploaded.
+func (p *PWrap) uploadArtifacts(ctx context.Context) error {
+	a := p.artifacts
+	if a == nil {
+		return nil
+	}
+	d, err := artifact.Parse(a.Destination)
+	if err != nil {
+		return err
+	}
+	urls, err := d.Upload(ctx, p.sid, p.WorkDir(), a.Paths)
+	log.Printf("[INFO] %d artifacts uploaded to %s", len(urls), a.Destination)
+	if uerr := p.UpdateSession(func(s *Session) {
+		s.ArtifactURLs = urls
+	}); uerr != nil {
+		log.Printf("[WARN] unable to record artifacts: %v", uerr)
+	}
+	return err
+}
@@ -0,0 +1,75 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUploadArtifacts(t *testing.T) {
+	t.Parallel()
+
+	for _, v := range []*Artifacts{
+		{Paths: []string{"*.mp4"}, Destination: "ftp://media"},
+		{Paths: []string{"../*.mp4"}, Destination: "s3://media"},
+		{Destination: "s3://media"},
+	} {
+		if _, err := New(UploadArtifacts(v)); err == nil {
+			t.Fatalf("Artifacts %+v SHOULD NOT be accepted", v)
+		}
+	}
+
+	uploaded := map[string]bool{}
+	var payload struct {
+		Artifacts []string `json:"artifacts"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			uploaded[r.URL.Path] = true
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer srv.Close()
+	os.Setenv("AWS_ENDPOINT_URL", srv.URL)
+	defer os.Unsetenv("AWS_ENDPOINT_URL")
+
+	a := &Artifacts{Paths: []string{"*.mp4"}, Destination: "s3://media/jobs"}
+	pw, err := New(RootDir(os.TempDir()), Register(srv.URL), UploadArtifacts(a))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(pw.WorkDir())
+	args := strings.Join(pw.wrapArgs(os.TempDir()), " ")
+	if !strings.Contains(args, "--artifacts-url=s3://media/jobs --artifact=*.mp4") {
+		t.Fatalf("Artifacts SHOULD be passed to the wrapper: %s", args)
+	}
+	for _, v := range []string{"a.mp4", "b.txt"} {
+		if err := os.WriteFile(pw.Path(v), []byte(v), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pw.uploadArtifacts(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]bool{"/media/jobs/" + pw.SID() + "/a.mp4": true}; !reflect.DeepEqual(uploaded, want) {
+		t.Fatalf("Wanted %v uploaded, found %v", want, uploaded)
+	}
+	if err := pw.Callback(nil); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"s3://media/jobs/" + pw.SID() + "/a.mp4"}; !reflect.DeepEqual(payload.Artifacts, want) {
+		t.Fatalf("The callback SHOULD report %v, found %v", want, payload.Artifacts)
+	}
+}
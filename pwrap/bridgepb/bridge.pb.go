@@ -0,0 +1,710 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: bridge.proto
+
+package bridgepb
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	timestamp "github.com/golang/protobuf/ptypes/timestamp"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+type ProgressRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ProgressRequest) Reset()         { *m = ProgressRequest{} }
+func (m *ProgressRequest) String() string { return proto.CompactTextString(m) }
+func (*ProgressRequest) ProtoMessage()    {}
+func (*ProgressRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_1d3ed31acb30cd14, []int{0}
+}
+
+func (m *ProgressRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ProgressRequest.Unmarshal(m, b)
+}
+func (m *ProgressRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ProgressRequest.Marshal(b, m, deterministic)
+}
+func (m *ProgressRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ProgressRequest.Merge(m, src)
+}
+func (m *ProgressRequest) XXX_Size() int {
+	return xxx_messageInfo_ProgressRequest.Size(m)
+}
+func (m *ProgressRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ProgressRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ProgressRequest proto.InternalMessageInfo
+
+type ProgressUpdate struct {
+	Description          string               `protobuf:"bytes,1,opt,name=description,proto3" json:"description,omitempty"`
+	Stage                int64                `protobuf:"varint,2,opt,name=stage,proto3" json:"stage,omitempty"`
+	Stages               int64                `protobuf:"varint,3,opt,name=stages,proto3" json:"stages,omitempty"`
+	Partial              int64                `protobuf:"varint,4,opt,name=partial,proto3" json:"partial,omitempty"`
+	Total                int64                `protobuf:"varint,5,opt,name=total,proto3" json:"total,omitempty"`
+	StageName            string               `protobuf:"bytes,6,opt,name=stage_name,json=stageName,proto3" json:"stage_name,omitempty"`
+	Unit                 string               `protobuf:"bytes,7,opt,name=unit,proto3" json:"unit,omitempty"`
+	Eta                  *timestamp.Timestamp `protobuf:"bytes,8,opt,name=eta,proto3" json:"eta,omitempty"`
+	Labels               map[string]string    `protobuf:"bytes,9,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *ProgressUpdate) Reset()         { *m = ProgressUpdate{} }
+func (m *ProgressUpdate) String() string { return proto.CompactTextString(m) }
+func (*ProgressUpdate) ProtoMessage()    {}
+func (*ProgressUpdate) Descriptor() ([]byte, []int) {
+	return fileDescriptor_1d3ed31acb30cd14, []int{1}
+}
+
+func (m *ProgressUpdate) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ProgressUpdate.Unmarshal(m, b)
+}
+func (m *ProgressUpdate) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ProgressUpdate.Marshal(b, m, deterministic)
+}
+func (m *ProgressUpdate) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ProgressUpdate.Merge(m, src)
+}
+func (m *ProgressUpdate) XXX_Size() int {
+	return xxx_messageInfo_ProgressUpdate.Size(m)
+}
+func (m *ProgressUpdate) XXX_DiscardUnknown() {
+	xxx_messageInfo_ProgressUpdate.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ProgressUpdate proto.InternalMessageInfo
+
+func (m *ProgressUpdate) GetDescription() string {
+	if m != nil {
+		return m.Description
+	}
+	return ""
+}
+
+func (m *ProgressUpdate) GetStage() int64 {
+	if m != nil {
+		return m.Stage
+	}
+	return 0
+}
+
+func (m *ProgressUpdate) GetStages() int64 {
+	if m != nil {
+		return m.Stages
+	}
+	return 0
+}
+
+func (m *ProgressUpdate) GetPartial() int64 {
+	if m != nil {
+		return m.Partial
+	}
+	return 0
+}
+
+func (m *ProgressUpdate) GetTotal() int64 {
+	if m != nil {
+		return m.Total
+	}
+	return 0
+}
+
+func (m *ProgressUpdate) GetStageName() string {
+	if m != nil {
+		return m.StageName
+	}
+	return ""
+}
+
+func (m *ProgressUpdate) GetUnit() string {
+	if m != nil {
+		return m.Unit
+	}
+	return ""
+}
+
+func (m *ProgressUpdate) GetEta() *timestamp.Timestamp {
+	if m != nil {
+		return m.Eta
+	}
+	return nil
+}
+
+func (m *ProgressUpdate) GetLabels() map[string]string {
+	if m != nil {
+		return m.Labels
+	}
+	return nil
+}
+
+type StreamRequest struct {
+	Channel              string   `protobuf:"bytes,1,opt,name=channel,proto3" json:"channel,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *StreamRequest) Reset()         { *m = StreamRequest{} }
+func (m *StreamRequest) String() string { return proto.CompactTextString(m) }
+func (*StreamRequest) ProtoMessage()    {}
+func (*StreamRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_1d3ed31acb30cd14, []int{2}
+}
+
+func (m *StreamRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_StreamRequest.Unmarshal(m, b)
+}
+func (m *StreamRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_StreamRequest.Marshal(b, m, deterministic)
+}
+func (m *StreamRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StreamRequest.Merge(m, src)
+}
+func (m *StreamRequest) XXX_Size() int {
+	return xxx_messageInfo_StreamRequest.Size(m)
+}
+func (m *StreamRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_StreamRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StreamRequest proto.InternalMessageInfo
+
+func (m *StreamRequest) GetChannel() string {
+	if m != nil {
+		return m.Channel
+	}
+	return ""
+}
+
+type StreamFrame struct {
+	Data                 []byte   `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *StreamFrame) Reset()         { *m = StreamFrame{} }
+func (m *StreamFrame) String() string { return proto.CompactTextString(m) }
+func (*StreamFrame) ProtoMessage()    {}
+func (*StreamFrame) Descriptor() ([]byte, []int) {
+	return fileDescriptor_1d3ed31acb30cd14, []int{3}
+}
+
+func (m *StreamFrame) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_StreamFrame.Unmarshal(m, b)
+}
+func (m *StreamFrame) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_StreamFrame.Marshal(b, m, deterministic)
+}
+func (m *StreamFrame) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StreamFrame.Merge(m, src)
+}
+func (m *StreamFrame) XXX_Size() int {
+	return xxx_messageInfo_StreamFrame.Size(m)
+}
+func (m *StreamFrame) XXX_DiscardUnknown() {
+	xxx_messageInfo_StreamFrame.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StreamFrame proto.InternalMessageInfo
+
+func (m *StreamFrame) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+type CommandRequest struct {
+	Command              string   `protobuf:"bytes,1,opt,name=command,proto3" json:"command,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CommandRequest) Reset()         { *m = CommandRequest{} }
+func (m *CommandRequest) String() string { return proto.CompactTextString(m) }
+func (*CommandRequest) ProtoMessage()    {}
+func (*CommandRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_1d3ed31acb30cd14, []int{4}
+}
+
+func (m *CommandRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CommandRequest.Unmarshal(m, b)
+}
+func (m *CommandRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CommandRequest.Marshal(b, m, deterministic)
+}
+func (m *CommandRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CommandRequest.Merge(m, src)
+}
+func (m *CommandRequest) XXX_Size() int {
+	return xxx_messageInfo_CommandRequest.Size(m)
+}
+func (m *CommandRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_CommandRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CommandRequest proto.InternalMessageInfo
+
+func (m *CommandRequest) GetCommand() string {
+	if m != nil {
+		return m.Command
+	}
+	return ""
+}
+
+type CommandResponse struct {
+	Ok                   bool     `protobuf:"varint,1,opt,name=ok,proto3" json:"ok,omitempty"`
+	Response             string   `protobuf:"bytes,2,opt,name=response,proto3" json:"response,omitempty"`
+	Error                string   `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CommandResponse) Reset()         { *m = CommandResponse{} }
+func (m *CommandResponse) String() string { return proto.CompactTextString(m) }
+func (*CommandResponse) ProtoMessage()    {}
+func (*CommandResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_1d3ed31acb30cd14, []int{5}
+}
+
+func (m *CommandResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CommandResponse.Unmarshal(m, b)
+}
+func (m *CommandResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CommandResponse.Marshal(b, m, deterministic)
+}
+func (m *CommandResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CommandResponse.Merge(m, src)
+}
+func (m *CommandResponse) XXX_Size() int {
+	return xxx_messageInfo_CommandResponse.Size(m)
+}
+func (m *CommandResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_CommandResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CommandResponse proto.InternalMessageInfo
+
+func (m *CommandResponse) GetOk() bool {
+	if m != nil {
+		return m.Ok
+	}
+	return false
+}
+
+func (m *CommandResponse) GetResponse() string {
+	if m != nil {
+		return m.Response
+	}
+	return ""
+}
+
+func (m *CommandResponse) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+type ResultRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ResultRequest) Reset()         { *m = ResultRequest{} }
+func (m *ResultRequest) String() string { return proto.CompactTextString(m) }
+func (*ResultRequest) ProtoMessage()    {}
+func (*ResultRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_1d3ed31acb30cd14, []int{6}
+}
+
+func (m *ResultRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ResultRequest.Unmarshal(m, b)
+}
+func (m *ResultRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ResultRequest.Marshal(b, m, deterministic)
+}
+func (m *ResultRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ResultRequest.Merge(m, src)
+}
+func (m *ResultRequest) XXX_Size() int {
+	return xxx_messageInfo_ResultRequest.Size(m)
+}
+func (m *ResultRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ResultRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ResultRequest proto.InternalMessageInfo
+
+type ResultResponse struct {
+	// JSON encoded result, as written by the child.
+	Json                 []byte   `protobuf:"bytes,1,opt,name=json,proto3" json:"json,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ResultResponse) Reset()         { *m = ResultResponse{} }
+func (m *ResultResponse) String() string { return proto.CompactTextString(m) }
+func (*ResultResponse) ProtoMessage()    {}
+func (*ResultResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_1d3ed31acb30cd14, []int{7}
+}
+
+func (m *ResultResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ResultResponse.Unmarshal(m, b)
+}
+func (m *ResultResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ResultResponse.Marshal(b, m, deterministic)
+}
+func (m *ResultResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ResultResponse.Merge(m, src)
+}
+func (m *ResultResponse) XXX_Size() int {
+	return xxx_messageInfo_ResultResponse.Size(m)
+}
+func (m *ResultResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ResultResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ResultResponse proto.InternalMessageInfo
+
+func (m *ResultResponse) GetJson() []byte {
+	if m != nil {
+		return m.Json
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*ProgressRequest)(nil), "pmux.bridge.v1.ProgressRequest")
+	proto.RegisterType((*ProgressUpdate)(nil), "pmux.bridge.v1.ProgressUpdate")
+	proto.RegisterMapType((map[string]string)(nil), "pmux.bridge.v1.ProgressUpdate.LabelsEntry")
+	proto.RegisterType((*StreamRequest)(nil), "pmux.bridge.v1.StreamRequest")
+	proto.RegisterType((*StreamFrame)(nil), "pmux.bridge.v1.StreamFrame")
+	proto.RegisterType((*CommandRequest)(nil), "pmux.bridge.v1.CommandRequest")
+	proto.RegisterType((*CommandResponse)(nil), "pmux.bridge.v1.CommandResponse")
+	proto.RegisterType((*ResultRequest)(nil), "pmux.bridge.v1.ResultRequest")
+	proto.RegisterType((*ResultResponse)(nil), "pmux.bridge.v1.ResultResponse")
+}
+
+func init() { proto.RegisterFile("bridge.proto", fileDescriptor_1d3ed31acb30cd14) }
+
+var fileDescriptor_1d3ed31acb30cd14 = []byte{
+	// 536 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x7c, 0x93, 0xdf, 0x6e, 0xd3, 0x30,
+	0x14, 0xc6, 0x95, 0xb4, 0x4b, 0xdb, 0xd3, 0xae, 0x05, 0x0b, 0xa1, 0x28, 0x68, 0x6b, 0x89, 0xb8,
+	0x28, 0xd5, 0x48, 0x46, 0xb9, 0x01, 0x2e, 0x8b, 0x18, 0x12, 0x02, 0x84, 0x32, 0xb8, 0xe1, 0x06,
+	0x39, 0xad, 0xc9, 0x42, 0xe3, 0xd8, 0xd8, 0xce, 0xa0, 0xef, 0xc5, 0x83, 0xf1, 0x08, 0x28, 0xb6,
+	0x53, 0xb5, 0x8c, 0xee, 0xee, 0x7c, 0xdf, 0xf9, 0x13, 0xfb, 0x77, 0x1c, 0x18, 0xa4, 0x22, 0x5f,
+	0x65, 0x24, 0xe2, 0x82, 0x29, 0x86, 0x86, 0x9c, 0x56, 0xbf, 0x22, 0x6b, 0x5d, 0x3f, 0x0d, 0xc6,
+	0x19, 0x63, 0x59, 0x41, 0x62, 0x9d, 0x4d, 0xab, 0x6f, 0xb1, 0xca, 0x29, 0x91, 0x0a, 0x53, 0x6e,
+	0x1a, 0xc2, 0xbb, 0x30, 0xfa, 0x28, 0x58, 0x26, 0x88, 0x94, 0x09, 0xf9, 0x51, 0x11, 0xa9, 0xc2,
+	0x3f, 0x2e, 0x0c, 0x1b, 0xef, 0x33, 0x5f, 0x61, 0x45, 0xd0, 0x04, 0xfa, 0x2b, 0x22, 0x97, 0x22,
+	0xe7, 0x2a, 0x67, 0xa5, 0xef, 0x4c, 0x9c, 0x69, 0x2f, 0xd9, 0xb5, 0xd0, 0x3d, 0x38, 0x92, 0x0a,
+	0x67, 0xc4, 0x77, 0x27, 0xce, 0xb4, 0x95, 0x18, 0x81, 0xee, 0x83, 0xa7, 0x03, 0xe9, 0xb7, 0xb4,
+	0x6d, 0x15, 0xf2, 0xa1, 0xc3, 0xb1, 0x50, 0x39, 0x2e, 0xfc, 0xb6, 0x4e, 0x34, 0xb2, 0x9e, 0xa3,
+	0x98, 0xc2, 0x85, 0x7f, 0x64, 0xe6, 0x68, 0x81, 0x4e, 0x00, 0x74, 0xe7, 0xd7, 0x12, 0x53, 0xe2,
+	0x7b, 0xfa, 0xf3, 0x3d, 0xed, 0x7c, 0xc0, 0x94, 0x20, 0x04, 0xed, 0xaa, 0xcc, 0x95, 0xdf, 0xd1,
+	0x09, 0x1d, 0xa3, 0x33, 0x68, 0x11, 0x85, 0xfd, 0xee, 0xc4, 0x99, 0xf6, 0xe7, 0x41, 0x64, 0x38,
+	0x44, 0x0d, 0x87, 0xe8, 0x53, 0xc3, 0x21, 0xa9, 0xcb, 0xd0, 0x02, 0xbc, 0x02, 0xa7, 0xa4, 0x90,
+	0x7e, 0x6f, 0xd2, 0x9a, 0xf6, 0xe7, 0xb3, 0x68, 0x1f, 0x64, 0xb4, 0x0f, 0x24, 0x7a, 0xa7, 0x8b,
+	0x5f, 0x97, 0x4a, 0x6c, 0x12, 0xdb, 0x19, 0xbc, 0x80, 0xfe, 0x8e, 0x8d, 0xee, 0x40, 0x6b, 0x4d,
+	0x36, 0x96, 0x55, 0x1d, 0xd6, 0x77, 0xbb, 0xc6, 0x45, 0x65, 0x18, 0xf5, 0x12, 0x23, 0x5e, 0xba,
+	0xcf, 0x9d, 0xf0, 0x31, 0x1c, 0x5f, 0x2a, 0x41, 0x30, 0xb5, 0x3b, 0xa8, 0x01, 0x2d, 0xaf, 0x70,
+	0x59, 0x92, 0xc2, 0x0e, 0x68, 0x64, 0xf8, 0x10, 0xfa, 0xa6, 0xf4, 0x42, 0xd8, 0xab, 0xaf, 0xb0,
+	0xc2, 0xba, 0x6a, 0x90, 0xe8, 0x38, 0x9c, 0xc1, 0xf0, 0x15, 0xa3, 0x14, 0x97, 0xab, 0xdd, 0x71,
+	0xc6, 0xd9, 0x8e, 0x33, 0x32, 0xbc, 0x84, 0xd1, 0xb6, 0x56, 0x72, 0x56, 0x4a, 0x82, 0x86, 0xe0,
+	0xb2, 0xb5, 0xae, 0xeb, 0x26, 0x2e, 0x5b, 0xa3, 0x00, 0xba, 0xc2, 0xe6, 0xec, 0xc9, 0xb7, 0xba,
+	0xbe, 0x12, 0x11, 0x82, 0x09, 0xbd, 0xdf, 0x5e, 0x62, 0x44, 0x38, 0x82, 0xe3, 0x84, 0xc8, 0xaa,
+	0x50, 0xcd, 0x93, 0x7a, 0x04, 0xc3, 0xc6, 0xb0, 0x8d, 0x08, 0xda, 0xdf, 0xa5, 0x7d, 0x4a, 0x83,
+	0x44, 0xc7, 0xf3, 0xdf, 0x2e, 0x78, 0x0b, 0x4d, 0x1c, 0xbd, 0x87, 0x6e, 0x43, 0x1c, 0x8d, 0x0f,
+	0xed, 0xc2, 0x4e, 0x0f, 0x4e, 0x6f, 0x5f, 0xd6, 0xb9, 0x83, 0x2e, 0xc0, 0x33, 0xd0, 0xd0, 0xc9,
+	0xbf, 0xb5, 0x7b, 0xdc, 0x83, 0x07, 0xff, 0x4f, 0x6b, 0xd6, 0xe7, 0x0e, 0x7a, 0x0b, 0x1d, 0x4b,
+	0x0b, 0xdd, 0xf8, 0xe8, 0x3e, 0xf2, 0x60, 0x7c, 0x30, 0x6f, 0x09, 0xbc, 0x01, 0xcf, 0x30, 0xb9,
+	0x79, 0xa6, 0x3d, 0x78, 0xc1, 0xe9, 0xa1, 0xb4, 0x19, 0xb4, 0x38, 0xfb, 0x32, 0xcb, 0x72, 0x75,
+	0x55, 0xa5, 0xd1, 0x92, 0xd1, 0x78, 0x9d, 0xd3, 0x27, 0x4b, 0x46, 0x39, 0x2e, 0x37, 0x71, 0xdd,
+	0x17, 0xf3, 0x9f, 0x02, 0xf3, 0xd8, 0x74, 0xf3, 0x34, 0xf5, 0xf4, 0x2f, 0xf0, 0xec, 0xef, 0x00,
+	0x02, 0xc9, 0x0f, 0xe6, 0x38, 0x04, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// BridgeClient is the client API for Bridge service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type BridgeClient interface {
+	// Progress streams the progress updates published by the child. The most
+	// recent updates are replayed first.
+	Progress(ctx context.Context, in *ProgressRequest, opts ...grpc.CallOption) (Bridge_ProgressClient, error)
+	// Stream streams the raw content of a named channel.
+	Stream(ctx context.Context, in *StreamRequest, opts ...grpc.CallOption) (Bridge_StreamClient, error)
+	// Command delivers a command to the child and waits for its response.
+	Command(ctx context.Context, in *CommandRequest, opts ...grpc.CallOption) (*CommandResponse, error)
+	// Result waits for the result of the child, published on the "result"
+	// channel.
+	Result(ctx context.Context, in *ResultRequest, opts ...grpc.CallOption) (*ResultResponse, error)
+}
+
+type bridgeClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewBridgeClient(cc *grpc.ClientConn) BridgeClient {
+	return &bridgeClient{cc}
+}
+
+func (c *bridgeClient) Progress(ctx context.Context, in *ProgressRequest, opts ...grpc.CallOption) (Bridge_ProgressClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Bridge_serviceDesc.Streams[0], "/pmux.bridge.v1.Bridge/Progress", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &bridgeProgressClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Bridge_ProgressClient interface {
+	Recv() (*ProgressUpdate, error)
+	grpc.ClientStream
+}
+
+type bridgeProgressClient struct {
+	grpc.ClientStream
+}
+
+func (x *bridgeProgressClient) Recv() (*ProgressUpdate, error) {
+	m := new(ProgressUpdate)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *bridgeClient) Stream(ctx context.Context, in *StreamRequest, opts ...grpc.CallOption) (Bridge_StreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Bridge_serviceDesc.Streams[1], "/pmux.bridge.v1.Bridge/Stream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &bridgeStreamClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Bridge_StreamClient interface {
+	Recv() (*StreamFrame, error)
+	grpc.ClientStream
+}
+
+type bridgeStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *bridgeStreamClient) Recv() (*StreamFrame, error) {
+	m := new(StreamFrame)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *bridgeClient) Command(ctx context.Context, in *CommandRequest, opts ...grpc.CallOption) (*CommandResponse, error) {
+	out := new(CommandResponse)
+	err := c.cc.Invoke(ctx, "/pmux.bridge.v1.Bridge/Command", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *bridgeClient) Result(ctx context.Context, in *ResultRequest, opts ...grpc.CallOption) (*ResultResponse, error) {
+	out := new(ResultResponse)
+	err := c.cc.Invoke(ctx, "/pmux.bridge.v1.Bridge/Result", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// BridgeServer is the server API for Bridge service.
+type BridgeServer interface {
+	// Progress streams the progress updates published by the child. The most
+	// recent updates are replayed first.
+	Progress(*ProgressRequest, Bridge_ProgressServer) error
+	// Stream streams the raw content of a named channel.
+	Stream(*StreamRequest, Bridge_StreamServer) error
+	// Command delivers a command to the child and waits for its response.
+	Command(context.Context, *CommandRequest) (*CommandResponse, error)
+	// Result waits for the result of the child, published on the "result"
+	// channel.
+	Result(context.Context, *ResultRequest) (*ResultResponse, error)
+}
+
+// UnimplementedBridgeServer can be embedded to have forward compatible implementations.
+type UnimplementedBridgeServer struct {
+}
+
+func (*UnimplementedBridgeServer) Progress(req *ProgressRequest, srv Bridge_ProgressServer) error {
+	return status.Errorf(codes.Unimplemented, "method Progress not implemented")
+}
+func (*UnimplementedBridgeServer) Stream(req *StreamRequest, srv Bridge_StreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method Stream not implemented")
+}
+func (*UnimplementedBridgeServer) Command(ctx context.Context, req *CommandRequest) (*CommandResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Command not implemented")
+}
+func (*UnimplementedBridgeServer) Result(ctx context.Context, req *ResultRequest) (*ResultResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Result not implemented")
+}
+
+func RegisterBridgeServer(s *grpc.Server, srv BridgeServer) {
+	s.RegisterService(&_Bridge_serviceDesc, srv)
+}
+
+func _Bridge_Progress_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ProgressRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(BridgeServer).Progress(m, &bridgeProgressServer{stream})
+}
+
+type Bridge_ProgressServer interface {
+	Send(*ProgressUpdate) error
+	grpc.ServerStream
+}
+
+type bridgeProgressServer struct {
+	grpc.ServerStream
+}
+
+func (x *bridgeProgressServer) Send(m *ProgressUpdate) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Bridge_Stream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(BridgeServer).Stream(m, &bridgeStreamServer{stream})
+}
+
+type Bridge_StreamServer interface {
+	Send(*StreamFrame) error
+	grpc.ServerStream
+}
+
+type bridgeStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *bridgeStreamServer) Send(m *StreamFrame) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Bridge_Command_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CommandRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BridgeServer).Command(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pmux.bridge.v1.Bridge/Command",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BridgeServer).Command(ctx, req.(*CommandRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Bridge_Result_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResultRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BridgeServer).Result(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pmux.bridge.v1.Bridge/Result",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BridgeServer).Result(ctx, req.(*ResultRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Bridge_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "pmux.bridge.v1.Bridge",
+	HandlerType: (*BridgeServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Command",
+			Handler:    _Bridge_Command_Handler,
+		},
+		{
+			MethodName: "Result",
+			Handler:    _Bridge_Result_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Progress",
+			Handler:       _Bridge_Progress_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Stream",
+			Handler:       _Bridge_Stream_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "bridge.proto",
+}
@@ -0,0 +1,11 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+// Package bridgepb contains the gRPC definition of the communication bridge
+// protocol, mirroring the progress, stream and command modes of the line based
+// protocol implemented by pwrap's comm bridges, and the Go stubs generated from
+// it. Children serve it with "pwrap.TransportGRPC", see "pwrap.GRPCCommBridge".
+package bridgepb
+
+//go:generate protoc --go_out=plugins=grpc,paths=source_relative:. bridge.proto
@@ -0,0 +1,162 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// ChannelProgress is the name of the stream carrying progress updates.
+const ChannelProgress = "progress"
+
+// ChannelResult is the name of the stream carrying the result of the child,
+// JSON encoded on a single line.
+const ChannelResult = "result"
+
+// frame is the unit of data delivered to stream clients. Raw frames are
+// delivered as they are, updates are encoded using the format negotiated by
+// the client.
+type frame struct {
+	raw    []byte
+	update *ProgressUpdate
+}
+
+// channel is a named stream of frames, with its own replay history and clients.
+type channel struct {
+	sync.Mutex
+	frames  []*frame
+	size    int
+	clients map[string]*client
+}
+
+func newChannel(replay int) *channel {
+	return &channel{size: replay, clients: make(map[string]*client)}
+}
+
+// broadcast stores "f" in the replay history and queues it for every client, returning
+// the number of clients reached. It never blocks on slow clients: when a client's
+// queue is full the laggard policy is applied instead.
+func (c *channel) broadcast(f *frame, policy LaggardPolicy) int {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.size > 0 {
+		if len(c.frames) == c.size {
+			// Discard the oldest frame without retaining the
+			// underlying array forever.
+			copy(c.frames, c.frames[1:])
+			c.frames = c.frames[:len(c.frames)-1]
+		}
+		c.frames = append(c.frames, f)
+	}
+
+	n := 0
+	for k, v := range c.clients {
+		if v.push(f, policy) {
+			n++
+			continue
+		}
+		if policy == Disconnect {
+			log.Printf("[WARN] disconnecting laggard stream client %s", k)
+			v.kick()
+			delete(c.clients, k)
+		}
+	}
+	return n
+}
+
+// len returns the number of clients subscribed to the channel.
+func (c *channel) len() int {
+	c.Lock()
+	defer c.Unlock()
+	return len(c.clients)
+}
+
+type tx struct {
+	close  func()
+	c      <-chan *frame
+	kicked <-chan struct{}
+}
+
+// subscribe registers a new client with a queue of size "n", which receives the
+// replay history first.
+func (c *channel) subscribe(n int) *tx {
+	cl := &client{
+		c:      make(chan *frame, n),
+		kicked: make(chan struct{}),
+	}
+
+	// Holding the lock ensures that no frame is broadcasted between
+	// the replay of the history and the registration of the client.
+	c.Lock()
+	// generate a timestamp key inside the lock, so we're ensured to receive a unique one.
+	key := fmt.Sprintf("%d", time.Now().UnixNano())
+	for _, f := range c.frames {
+		cl.push(f, DropOldest)
+	}
+	c.clients[key] = cl
+	c.Unlock()
+
+	return &tx{
+		c:      cl.c,
+		kicked: cl.kicked,
+		close: func() {
+			c.Lock()
+			delete(c.clients, key)
+			c.Unlock()
+		},
+	}
+}
+
+// channelWriter is an "io.Writer" broadcasting raw frames on a channel.
+type channelWriter struct {
+	c      *channel
+	policy LaggardPolicy
+}
+
+func (w *channelWriter) Write(p []byte) (int, error) {
+	raw := make([]byte, len(p))
+	copy(raw, p)
+	n := w.c.broadcast(&frame{raw: raw}, w.policy)
+	return len(p) * n, nil
+}
+
+// client is a stream client's bounded queue.
+type client struct {
+	c      chan *frame
+	kicked chan struct{}
+	once   sync.Once
+}
+
+// push queues "f" without blocking. Returns false if the frame could not be queued.
+func (c *client) push(f *frame, policy LaggardPolicy) bool {
+	select {
+	case c.c <- f:
+		return true
+	default:
+	}
+	if policy != DropOldest {
+		return false
+	}
+	// Make room discarding the oldest frame. The consumer might have drained
+	// the queue in the meantime, hence the non-blocking receive.
+	select {
+	case <-c.c:
+	default:
+	}
+	select {
+	case c.c <- f:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *client) kick() {
+	c.once.Do(func() { close(c.kicked) })
+}
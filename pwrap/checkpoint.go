@@ -0,0 +1,52 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// FileCheckpoint is where resumable children write their checkpoint, inside the
+// working directory, in a format of their choice. It is passed back to them with
+// the "--resume" flag when the session is resumed, see "PWrap.Resume".
+const FileCheckpoint = "checkpoint"
+
+// ErrNoCheckpoint is returned by "PWrap.Resume" when the child did not write a
+// checkpoint.
+var ErrNoCheckpoint = errors.New("no checkpoint available")
+
+// ResumeChild makes the wrapper pass the path of "FileCheckpoint" to the child
+// with the "--resume" flag.
+func ResumeChild(ok bool) func(*PWrap) error {
+	return func(p *PWrap) error {
+		p.resume = ok
+		return nil
+	}
+}
+
+// Resume is like "Restart", but the child is told to continue from the checkpoint
+// it wrote into "FileCheckpoint" with the "--resume" flag, rather than starting
+// from scratch. The checkpoint has to be present and not empty. Sessions running as
+// Kubernetes Jobs or on remote hosts cannot be resumed, as their checkpoints are
+// not available on this host.
+func (p *PWrap) Resume() (string, error) {
+	s, err := p.ReadSession()
+	if err != nil {
+		return "", fmt.Errorf("unable to resume session: %w", err)
+	}
+	if s.Kubernetes != nil || s.Remote != nil {
+		return "", fmt.Errorf("unable to resume session: %w: checkpoints of Jobs and remote sessions are not available", ErrNoCheckpoint)
+	}
+	fi, err := os.Stat(p.Path(FileCheckpoint))
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("unable to resume session: %w", err)
+	}
+	if err != nil || fi.Size() == 0 {
+		return "", fmt.Errorf("unable to resume session: %w", ErrNoCheckpoint)
+	}
+	return p.restart(true)
+}
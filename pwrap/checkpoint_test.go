@@ -0,0 +1,72 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestResume_Errors(t *testing.T) {
+	t.Parallel()
+
+	pw, err := New(RootDir(t.TempDir()), Exec("yes"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pw.Resume(); !errors.Is(err, ErrNoSession) {
+		t.Fatalf("Sessions without state SHOULD NOT be resumed: %v", err)
+	}
+	if err := pw.UpdateSession(func(s *Session) {}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pw.Resume(); !errors.Is(err, ErrNoCheckpoint) {
+		t.Fatalf("Sessions without checkpoint SHOULD NOT be resumed: %v", err)
+	}
+	if err := os.WriteFile(pw.Path(FileCheckpoint), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pw.Resume(); !errors.Is(err, ErrNoCheckpoint) {
+		t.Fatalf("Sessions with an empty checkpoint SHOULD NOT be resumed: %v", err)
+	}
+	if err := os.WriteFile(pw.Path(FileCheckpoint), []byte("frame=4242"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := pw.UpdateSession(func(s *Session) { s.Remote = &Remote{Host: "node"} }); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pw.Resume(); !errors.Is(err, ErrNoCheckpoint) {
+		t.Fatalf("Remote sessions SHOULD NOT be resumed: %v", err)
+	}
+}
+
+func TestResumeChild(t *testing.T) {
+	t.Parallel()
+
+	pw, err := New(RootDir(t.TempDir()), Exec("yes", "-v"), ResumeChild(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	args, err := pw.childArgs("config", "sock", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "--resume=" + pw.Path(FileCheckpoint); !contains(args, want) {
+		t.Fatalf("Child arguments %q SHOULD contain %q", args, want)
+	}
+	if !contains(pw.wrapArgs(pw.rootDir), "--resume") {
+		t.Fatalf("Wrapper arguments SHOULD contain --resume")
+	}
+}
+
+func contains(args []string, s string) bool {
+	for _, v := range args {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
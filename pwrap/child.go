@@ -0,0 +1,399 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/kim-company/pmux/http/pwrapapi"
+)
+
+// childState tracks the lifecycle of the child process executed by the wrapper.
+type childState struct {
+	sync.Mutex
+	proc           *os.Process
+	done           chan struct{}
+	pid            int
+	startedAt      time.Time
+	finishedAt     time.Time
+	exitCode       *int
+	signal         string
+	oomKilled      bool
+	timeout        bool
+	err            error
+	lastProgress   *ProgressUpdate
+	lastProgressAt time.Time
+	restarts       int
+	// onChange, if set, is called every time the state changes.
+	onChange func()
+	// onProgress, if set, is called with every progress update.
+	onProgress func(*ProgressUpdate)
+	// pausedAt is set while the child is paused, in "pauseMode", using
+	// "suspend" unless paused by command. pausing serializes the pauses.
+	pausedAt  time.Time
+	pauseMode string
+	suspend   func(pid int, resume bool) error
+	pausing   sync.Mutex
+}
+
+func newChildState(restarts int) *childState {
+	return &childState{done: make(chan struct{}), restarts: restarts}
+}
+
+func (c *childState) started(proc *os.Process) {
+	c.Lock()
+	c.proc = proc
+	c.pid = proc.Pid
+	c.startedAt = time.Now()
+	c.Unlock()
+	c.changed()
+}
+
+func (c *childState) exited(state *os.ProcessState, err error) {
+	c.Lock()
+	c.finishedAt = time.Now()
+	c.pausedAt, c.pauseMode = time.Time{}, ""
+	c.err = err
+	if state != nil {
+		code := state.ExitCode()
+		c.exitCode = &code
+		c.signal = terminatingSignal(state)
+	}
+	c.Unlock()
+	c.changed()
+	close(c.done)
+}
+
+// killedByOOM records that the child was killed by the OOM killer, before its
+// exit is.
+func (c *childState) killedByOOM() {
+	c.Lock()
+	c.oomKilled = true
+	c.Unlock()
+}
+
+// timedOut records that the child is stopped for exceeding its maximum runtime,
+// before its exit is.
+func (c *childState) timedOut() {
+	c.Lock()
+	c.timeout = true
+	c.Unlock()
+}
+
+func (c *childState) progressed(u *ProgressUpdate) {
+	c.Lock()
+	c.lastProgress = u
+	c.lastProgressAt = time.Now()
+	c.Unlock()
+	if c.onProgress != nil {
+		c.onProgress(u)
+	}
+	c.changed()
+}
+
+func (c *childState) changed() {
+	if c.onChange != nil {
+		c.onChange()
+	}
+}
+
+// record copies the child's state into the session state "s".
+func (c *childState) record(s *Session) {
+	c.Lock()
+	defer c.Unlock()
+
+	s.PID = c.pid
+	s.ExitCode = c.exitCode
+	s.Signal = c.signal
+	s.OOMKilled = c.oomKilled
+	if !c.startedAt.IsZero() {
+		started := c.startedAt
+		s.StartedAt = &started
+		s.State = SessionRunning
+	}
+	s.PausedAt = nil
+	if !c.pausedAt.IsZero() {
+		paused := c.pausedAt
+		s.PausedAt = &paused
+		s.State = SessionPaused
+	}
+	if !c.finishedAt.IsZero() {
+		finished := c.finishedAt
+		s.FinishedAt = &finished
+		s.State = SessionExited
+		if c.err != nil {
+			s.State = SessionFailed
+			s.Error = c.err.Error()
+		}
+		if c.timeout {
+			s.State = SessionTimedOut
+		}
+	}
+	if !c.lastProgressAt.IsZero() {
+		at := c.lastProgressAt
+		s.LastProgress = c.lastProgress
+		s.LastProgressAt = &at
+	}
+}
+
+// Status returns a snapshot of the child's state.
+func (c *childState) Status() pwrapapi.ChildStatus {
+	c.Lock()
+	defer c.Unlock()
+
+	s := pwrapapi.ChildStatus{PID: c.pid, ExitCode: c.exitCode, Signal: c.signal, OOMKilled: c.oomKilled, TimedOut: c.timeout, Restarts: c.restarts}
+	if c.err != nil {
+		s.Error = c.err.Error()
+	}
+	if !c.startedAt.IsZero() {
+		started := c.startedAt
+		s.StartedAt = &started
+		end := time.Now()
+		if c.finishedAt.IsZero() {
+			s.Running = true
+		} else {
+			finished := c.finishedAt
+			s.FinishedAt = &finished
+			end = finished
+		}
+		s.UptimeSeconds = end.Sub(started).Seconds()
+	}
+	if !c.pausedAt.IsZero() {
+		paused := c.pausedAt
+		s.PausedAt = &paused
+	}
+	if !c.lastProgressAt.IsZero() {
+		at := c.lastProgressAt
+		s.LastProgressAt = &at
+		if p := c.lastProgress.Percent(); p >= 0 {
+			s.ProgressPercent = &p
+		}
+	}
+	return s
+}
+
+// stop asks the child to terminate, delivering "command" over the communication bridge
+// if set or sending a SIGTERM otherwise, and waits for it to exit. The child is killed if
+// it is still running once the grace period is over.
+func (c *childState) stop(ctx context.Context, b pwrapapi.Bridge, command string, grace time.Duration) error {
+	c.Lock()
+	proc := c.proc
+	c.Unlock()
+	if proc == nil {
+		return errors.New("child process has not been started yet")
+	}
+	select {
+	case <-c.done:
+		return nil
+	default:
+	}
+	c.thaw()
+
+	if command != "" {
+		if err := sendCommand(b, command); err != nil {
+			log.Printf("[WARN] unable to deliver stop command, sending SIGTERM instead: %v", err)
+			command = ""
+		}
+	}
+	if command == "" {
+		if err := proc.Signal(syscall.SIGTERM); err != nil {
+			return fmt.Errorf("unable to signal child: %w", err)
+		}
+	}
+
+	t := time.NewTimer(grace)
+	defer t.Stop()
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+	}
+	log.Printf("[WARN] child did not exit within %v, killing it", grace)
+	if err := proc.Kill(); err != nil {
+		return fmt.Errorf("unable to kill child: %w", err)
+	}
+	select {
+	case <-c.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return fmt.Errorf("child did not exit within %v and was killed", grace)
+}
+
+// pause pauses the child in "mode", see "pwrapapi.PauseFunc", delivering the pause
+// and resume commands over "b" or suspending its processes.
+func (c *childState) pause(b pwrapapi.Bridge, mode string, resume bool) error {
+	c.pausing.Lock()
+	defer c.pausing.Unlock()
+
+	c.Lock()
+	pid, paused, how := c.pid, !c.pausedAt.IsZero(), c.pauseMode
+	running := c.proc != nil && c.finishedAt.IsZero()
+	c.Unlock()
+	switch {
+	case !running:
+		return fmt.Errorf("%w: child is not running", pwrapapi.ErrPauseState)
+	case paused && !resume:
+		return fmt.Errorf("%w: child is paused already", pwrapapi.ErrPauseState)
+	case !paused && resume:
+		return fmt.Errorf("%w: child is not paused", pwrapapi.ErrPauseState)
+	}
+
+	if resume {
+		mode = how
+	}
+	var err error
+	if mode == pwrapapi.PauseCommand {
+		op := CommandPause
+		if resume {
+			op = CommandResume
+		}
+		err = sendCommand(b, op)
+	} else {
+		err = c.suspend(pid, resume)
+	}
+	if err != nil {
+		return err
+	}
+
+	c.Lock()
+	if resume {
+		c.pausedAt, c.pauseMode = time.Time{}, ""
+	} else {
+		c.pausedAt, c.pauseMode = time.Now(), mode
+	}
+	c.Unlock()
+	c.changed()
+	return nil
+}
+
+// reload replaces the configuration of the running child calling "write", then
+// delivers the reload command over "b". Children suspended with SIGSTOP are not
+// reloaded, as they could not respond.
+func (c *childState) reload(b pwrapapi.Bridge, write func() error) error {
+	// Pauses wait for the reload to be over.
+	c.pausing.Lock()
+	defer c.pausing.Unlock()
+
+	c.Lock()
+	how := c.pauseMode
+	running := c.proc != nil && c.finishedAt.IsZero()
+	c.Unlock()
+	switch {
+	case !running:
+		return fmt.Errorf("%w: child is not running", pwrapapi.ErrReloadState)
+	case how == pwrapapi.PauseSignal:
+		return fmt.Errorf("%w: child is suspended", pwrapapi.ErrReloadState)
+	}
+	if err := write(); err != nil {
+		return err
+	}
+	return sendCommand(b, CommandReload)
+}
+
+// thaw resumes the child if it was paused with SIGSTOP, as stopped processes do
+// not handle termination signals until they are continued.
+func (c *childState) thaw() {
+	c.Lock()
+	how := c.pauseMode
+	c.Unlock()
+	if how != pwrapapi.PauseSignal {
+		return
+	}
+	if err := c.pause(pwrapapi.Bridge{}, "", true); err != nil {
+		log.Printf("[WARN] unable to resume paused child: %v", err)
+	}
+}
+
+// sendCommand delivers the command of operation "op" to the child through its
+// communication bridge, returning an error if the child did not accept it.
+func sendCommand(b pwrapapi.Bridge, op string) error {
+	line, err := (&Command{Op: op}).Encode()
+	if err != nil {
+		return err
+	}
+	conn, err := b.Open("mode=command")
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.Write(line); err != nil {
+		return fmt.Errorf("unable to write command: %w", err)
+	}
+	var resp CommandResponse
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		if errors.Is(err, io.EOF) {
+			// Children that do not respond to commands.
+			return nil
+		}
+		return fmt.Errorf("unable to read command response: %w", err)
+	}
+	if !resp.OK {
+		return fmt.Errorf("%w: %q: %v", pwrapapi.ErrCommandRejected, op, resp.Error)
+	}
+	return nil
+}
+
+// monitorRetryInterval is the time waited before connecting again to the
+// child's communication bridge.
+const monitorRetryInterval = time.Millisecond * 500
+
+// monitorProgress follows the progress updates published by the child on its
+// communication bridge, recording them in "state", until the context is canceled.
+// Children that do not open a bridge, or that do not support the JSON format, are
+// simply never reported as progressing.
+func monitorProgress(ctx context.Context, b pwrapapi.Bridge, state *childState) {
+	for {
+		if err := followProgress(ctx, b, state); err != nil && ctx.Err() == nil {
+			log.Printf("[DEBUG] progress monitor: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(monitorRetryInterval):
+		}
+	}
+}
+
+func followProgress(ctx context.Context, b pwrapapi.Bridge, state *childState) error {
+	conn, err := b.OpenProgress(FormatJSON, pwrapapi.ProgressProtocol)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	dec := json.NewDecoder(conn)
+	for {
+		var u ProgressUpdate
+		if err := dec.Decode(&u); err != nil {
+			if errors.Is(err, context.Canceled) {
+				return nil
+			}
+			return err
+		}
+		state.progressed(&u)
+	}
+}
@@ -0,0 +1,43 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"github.com/kim-company/pmux/http/pwrapapi"
+)
+
+// The operations of the commands that children may match against, e.g. in their
+// "OnCommand" handler.
+const (
+	CommandPause  = pwrapapi.CommandPause
+	CommandResume = pwrapapi.CommandResume
+	CommandCancel = pwrapapi.CommandCancel
+	CommandReload = pwrapapi.CommandReload
+)
+
+// Command is the envelope of the commands delivered to the child, see
+// "pwrapapi.Command".
+type Command = pwrapapi.Command
+
+// ParseCommand decodes the command "cmd" received by a command handler, e.g.
+// {"op":"cancel","args":{}}.
+func ParseCommand(cmd string) (*Command, error) {
+	return pwrapapi.ParseCommand([]byte(cmd))
+}
+
+// CommandOps restricts the operations of the commands accepted by the wrapper
+// API to "ops", "pwrapapi.DefaultCommandOps" if empty. The stop command is
+// delivered regardless.
+func CommandOps(ops ...string) func(*PWrap) error {
+	return func(p *PWrap) error {
+		for _, v := range ops {
+			if err := pwrapapi.ValidateCommandOp(v); err != nil {
+				return err
+			}
+		}
+		p.commandOps = ops
+		return nil
+	}
+}
@@ -0,0 +1,38 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"os"
+	"testing"
+)
+
+func TestCommandOps(t *testing.T) {
+	t.Parallel()
+
+	if _, err := New(CommandOps("pause", "two words")); err == nil {
+		t.Fatalf("Invalid operations SHOULD be rejected")
+	}
+	if _, err := New(StopCommand("stop now")); err == nil {
+		t.Fatalf("Invalid stop operations SHOULD be rejected")
+	}
+	pw, err := New(RootDir(os.TempDir()), CommandOps(CommandCancel, "rewind"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(pw.WorkDir())
+	args := pw.wrapArgs(os.TempDir())
+	if !contains(args, "--command-op=cancel") || !contains(args, "--command-op=rewind") {
+		t.Fatalf("Operations SHOULD be passed to the wrapper: %v", args)
+	}
+
+	c, err := ParseCommand(`{"op":"cancel"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Op != CommandCancel {
+		t.Fatalf("Unexpected operation %q", c.Op)
+	}
+}
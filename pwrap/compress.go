@@ -0,0 +1,87 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/kim-company/pmux/tail"
+)
+
+// CompressLogs replaces the stdout and stderr files of the session with their gzip
+// compressed copies, named after them with the "tail.CompressedExt" extension,
+// which "tail.File" reads transparently. The output of a restarted session is
+// appended to the copies of its previous runs when it is compressed again. It
+// must not be called while the wrapper runs, and reports whether any file was
+// compressed.
+func (p *PWrap) CompressLogs() (bool, error) {
+	done := false
+	for _, v := range []string{FileStdout, FileStderr} {
+		ok, err := compressFile(p.Path(v))
+		if err != nil {
+			return done, fmt.Errorf("unable to compress %s: %w", v, err)
+		}
+		done = done || ok
+	}
+	return done, nil
+}
+
+// compressFile replaces the file at "path" with its compressed copy, appending it
+// to the existing copy as a further gzip member, if any. The copy is replaced
+// atomically, so that readers never observe partial writes. Files that do not
+// exist are skipped.
+func compressFile(path string) (bool, error) {
+	src, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer src.Close()
+	fi, err := src.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	dst := path + tail.CompressedExt
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(dst)+".*")
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	if prev, err := os.Open(dst); err == nil {
+		_, err = io.Copy(tmp, prev)
+		prev.Close()
+		if err != nil {
+			return false, err
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	}
+	zw := gzip.NewWriter(tmp)
+	if _, err := io.Copy(zw, src); err != nil {
+		return false, err
+	}
+	if err := zw.Close(); err != nil {
+		return false, err
+	}
+	if err := tmp.Chmod(fi.Mode().Perm()); err != nil {
+		return false, err
+	}
+	if err := tmp.Close(); err != nil {
+		return false, err
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return false, err
+	}
+	return true, os.Remove(path)
+}
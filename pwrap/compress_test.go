@@ -0,0 +1,60 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"os"
+	"testing"
+
+	"github.com/kim-company/pmux/tail"
+)
+
+func TestCompressLogs(t *testing.T) {
+	t.Parallel()
+
+	pw, err := New(RootDir(os.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(pw.WorkDir())
+	if err := os.WriteFile(pw.Path(FileStdout), []byte("first run\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := pw.CompressLogs(); err != nil || !ok {
+		t.Fatalf("Logs SHOULD be compressed: %v, %v", ok, err)
+	}
+	if _, err := os.Stat(pw.Path(FileStdout)); !os.IsNotExist(err) {
+		t.Fatalf("Compressed logs SHOULD be removed: %v", err)
+	}
+	if _, err := os.Stat(pw.Path(FileStderr + tail.CompressedExt)); err != nil {
+		t.Fatalf("Empty logs SHOULD be compressed too: %v", err)
+	}
+	if ok, err := pw.CompressLogs(); err != nil || ok {
+		t.Fatalf("Logs SHOULD NOT be compressed twice: %v, %v", ok, err)
+	}
+
+	// Opening the session does not create the logs again.
+	if _, err := New(OverrideSID(pw.SID()), RootDir(os.TempDir())); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(pw.Path(FileStdout)); !os.IsNotExist(err) {
+		t.Fatalf("Compressed logs SHOULD NOT be created again: %v", err)
+	}
+
+	// The output of restarted sessions is appended.
+	if err := os.WriteFile(pw.Path(FileStdout), []byte("second run\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pw.CompressLogs(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := tail.Lines(pw.Path(FileStdout), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "first run\nsecond run\n" {
+		t.Fatalf("Unexpected logs %q", b)
+	}
+}
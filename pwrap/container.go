@@ -0,0 +1,152 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Container describes the Docker container a child is executed in. The
+// working directory of the session is mounted at the same path inside the
+// container, so that the child finds its configuration and the wrapper its
+// output as usual.
+type Container struct {
+	Image string `json:"image"`
+	// Mounts are bind mounts in the "source:destination[:options]" form.
+	Mounts []string `json:"mounts,omitempty"`
+	// CPUs and Memory limit the resources available to the container, and
+	// are expressed as accepted by the "--cpus" and "--memory" flags of
+	// "docker run", e.g. "1.5" and "512m".
+	CPUs   string `json:"cpus,omitempty"`
+	Memory string `json:"memory,omitempty"`
+	// Network is the network the container is attached to. Defaults to
+	// "host", which allows the tcp transport to reach the child.
+	Network string `json:"network,omitempty"`
+}
+
+// DockerCommand is the command used to run containers.
+var DockerCommand = "docker"
+
+// Validate reports whether "c" can be used to run a child.
+func (c *Container) Validate() error {
+	if c.Image == "" {
+		return fmt.Errorf("container image not set")
+	}
+	for _, v := range c.Mounts {
+		if MountSource(v) == "" {
+			return fmt.Errorf("invalid container mount %q, expected source:destination[:options]", v)
+		}
+	}
+	return nil
+}
+
+// MountSource returns the host path mounted by "m", or an empty string if "m"
+// is not in the "source:destination[:options]" form.
+func MountSource(m string) string {
+	fields := strings.SplitN(m, ":", 3)
+	if len(fields) < 2 || fields[0] == "" || fields[1] == "" {
+		return ""
+	}
+	return fields[0]
+}
+
+// Docker makes the child run inside the container described by "c" rather than
+// directly on the host. This function has to be called before "Exec" if used in
+// the ``New'' function, as the executable is then looked up inside the image.
+func Docker(c *Container) func(*PWrap) error {
+	return func(p *PWrap) error {
+		if c == nil {
+			p.container = nil
+			return nil
+		}
+		if err := c.Validate(); err != nil {
+			return err
+		}
+		if _, err := exec.LookPath(DockerCommand); err != nil {
+			return err
+		}
+		p.container = c
+		return nil
+	}
+}
+
+// containerName returns the name of the container running "p"'s child, which
+// is its session identifier.
+func (p *PWrap) containerName() string {
+	return p.sid
+}
+
+// containerSockDir returns the directory hosting the unix socket of the
+// communication bridge of containerized children, which is the only part of
+// the socket directory of the host shared with the container.
+func (p *PWrap) containerSockDir() string {
+	return filepath.Join(p.sockDirPath(), p.sid+".d")
+}
+
+// command returns the command executing the child with "args" and the
+// additional environment variables "env", either directly or inside its container.
+func (p *PWrap) command(ctx context.Context, args []string, env ...string) *exec.Cmd {
+	if p.container == nil {
+		cmd := exec.CommandContext(ctx, p.name, args...)
+		cmd.Env = append(os.Environ(), env...)
+		return cmd
+	}
+
+	c := p.container
+	network := c.Network
+	if network == "" {
+		network = "host"
+	}
+	// The child runs with the wrapper's user, so that the files it creates
+	// in the working directory and its socket are accessible to the wrapper.
+	dargs := []string{"run", "--rm", "--init",
+		"--name=" + p.containerName(),
+		"--network=" + network,
+		"--user=" + strconv.Itoa(os.Getuid()) + ":" + strconv.Itoa(os.Getgid()),
+		"--volume=" + p.WorkDir() + ":" + p.WorkDir(),
+	}
+	if p.transport == TransportUnix {
+		dir := p.containerSockDir()
+		dargs = append(dargs, "--volume="+dir+":"+dir)
+	}
+	for _, v := range c.Mounts {
+		dargs = append(dargs, "--volume="+v)
+	}
+	if p.stdin {
+		dargs = append(dargs, "--interactive")
+	}
+	if c.CPUs != "" {
+		dargs = append(dargs, "--cpus="+c.CPUs)
+	}
+	if c.Memory != "" {
+		dargs = append(dargs, "--memory="+c.Memory)
+	}
+	// Only the variable names are passed, their values are taken from the
+	// environment of the docker client and do not show up in its arguments.
+	for _, v := range env {
+		dargs = append(dargs, "--env="+strings.SplitN(v, "=", 2)[0])
+	}
+	dargs = append(dargs, c.Image, p.name)
+	dargs = append(dargs, args...)
+
+	cmd := exec.CommandContext(ctx, DockerCommand, dargs...)
+	cmd.Env = append(os.Environ(), env...)
+	return cmd
+}
+
+// removeContainer removes the container of "p"'s child, if it is still around
+// after the docker client exited, e.g. because it was killed.
+func (p *PWrap) removeContainer() {
+	if p.container == nil {
+		return
+	}
+	exec.Command(DockerCommand, "rm", "--force", p.containerName()).Run()
+}
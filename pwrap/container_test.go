@@ -0,0 +1,68 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestContainer_Validate(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		c   Container
+		err bool
+	}{
+		{Container{Image: "alpine"}, false},
+		{Container{Image: "alpine", Mounts: []string{"/data:/data:ro"}}, false},
+		{Container{}, true},
+		{Container{Image: "alpine", Mounts: []string{"/data"}}, true},
+		{Container{Image: "alpine", Mounts: []string{":/data"}}, true},
+	}
+	for i, v := range tt {
+		if err := v.c.Validate(); (err != nil) != v.err {
+			t.Fatalf("%d: unexpected error: %v", i, err)
+		}
+	}
+}
+
+func TestPWrap_Command(t *testing.T) {
+	t.Parallel()
+
+	p := &PWrap{rootDir: "/srv", sid: "pmux-test", name: "job", transport: TransportUnix}
+	cmd := p.command(context.Background(), []string{"--config=c"}, EnvSocketToken+"=secret")
+	if cmd.Args[0] != "job" || cmd.Args[1] != "--config=c" {
+		t.Fatalf("unexpected host command: %v", cmd.Args)
+	}
+
+	p.container = &Container{Image: "alpine", Mounts: []string{"/data:/data"}, Memory: "512m"}
+	cmd = p.command(context.Background(), []string{"--config=c"}, EnvSocketToken+"=secret")
+	args := strings.Join(cmd.Args, " ")
+	for _, v := range []string{
+		"--name=pmux-test",
+		"--network=host",
+		"--volume=/srv/pmux-test:/srv/pmux-test",
+		"--volume=" + p.containerSockDir() + ":" + p.containerSockDir(),
+		"--volume=/data:/data",
+		"--memory=512m",
+		"--env=" + EnvSocketToken + " ",
+		"alpine job --config=c",
+	} {
+		if !strings.Contains(args, v) {
+			t.Fatalf("%q not found in %q", v, args)
+		}
+	}
+	if strings.Contains(args, "secret") {
+		t.Fatalf("token leaked in the arguments: %q", args)
+	}
+	if cmd.Env[len(cmd.Env)-1] != EnvSocketToken+"=secret" {
+		t.Fatalf("token not found in the environment")
+	}
+	if !strings.Contains(args, "--user=") {
+		t.Fatalf("user not set: %q", args)
+	}
+}
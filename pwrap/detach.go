@@ -0,0 +1,187 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/kim-company/pmux/kube"
+	"github.com/kim-company/pmux/tmux"
+)
+
+// FilePID contains the PID of a wrapper started as a detached process. It is
+// present only while the wrapper is running.
+const FilePID = "pid"
+
+// Detach makes the wrapper start as a process detached in its own session,
+// rather than inside a tmux session. Wrappers are always detached when tmux is
+// not installed.
+func Detach(ok bool) func(*PWrap) error {
+	return func(p *PWrap) error {
+		p.detach = ok
+		return nil
+	}
+}
+
+// detached reports whether the wrapper of "p" is started as a detached process.
+func (p *PWrap) detached() bool {
+	return p.detach || !tmux.Available()
+}
+
+// startDetached starts the wrapper with "args" in a new process session, storing
+// its PID in the "FilePID" file.
+func (p *PWrap) startDetached(args ...string) error {
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.SysProcAttr = detachedProcAttr()
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("unable to start detached wrapper: %w", err)
+	}
+	pid := cmd.Process.Pid
+	if err := p.replaceFile(FilePID, func(w io.Writer) error {
+		_, err := io.WriteString(w, strconv.Itoa(pid)+"\n")
+		return err
+	}); err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return fmt.Errorf("unable to store wrapper pid: %w", err)
+	}
+	// The wrapper is reaped if it exits while we are still around, or adopted
+	// by init otherwise.
+	go cmd.Wait()
+	return nil
+}
+
+// readPID returns the PID stored in the "FilePID" file at "path", or zero if
+// it is not available.
+func readPID(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
+
+// Running reports whether the wrapper of "p" is running, either inside its tmux
+// session, local or remote, as a detached process or as a Kubernetes Job.
+func (p *PWrap) Running() bool {
+	return HasSession(p.rootDir, p.sid)
+}
+
+// HasSession reports whether the wrapper of session "sid", having its working
+// directory inside "root", is running either inside its tmux session, local or
+// remote, as a detached process or as a Kubernetes Job.
+func HasSession(root, sid string) bool {
+	if pid := readPID(filepath.Join(root, sid, FilePID)); pid > 0 {
+		return processAlive(pid)
+	}
+	p := &PWrap{rootDir: root, sid: sid}
+	if k := p.job(); k != nil {
+		return p.jobRunning(k)
+	}
+	if r := p.placement(); r != nil {
+		return p.remoteRunning(r)
+	}
+	return tmux.Available() && tmux.HasSession(sid)
+}
+
+// ListSessions returns the identifiers of the sessions whose wrapper is running,
+// either inside tmux, local or remote, as a detached process or as a Kubernetes
+// Job having its working directory inside "root". Remote hosts are contacted once
+// each. As with "tmux.ListSessions", partial results may be returned together
+// with an error.
+func ListSessions(root string) ([]string, error) {
+	acc := []string{}
+	var err error
+	if tmux.Available() {
+		acc, err = tmux.ListSessions()
+	}
+	remotes := map[string]*Remote{}
+	placed := map[string]bool{}
+	entries, _ := os.ReadDir(root)
+	for _, v := range entries {
+		if !v.IsDir() {
+			continue
+		}
+		if pid := readPID(filepath.Join(root, v.Name(), FilePID)); pid > 0 && processAlive(pid) {
+			acc = append(acc, v.Name())
+			continue
+		}
+		p := &PWrap{rootDir: root, sid: v.Name()}
+		s, rerr := p.ReadSession()
+		switch {
+		case rerr != nil:
+		case s.Kubernetes != nil:
+			if p.jobRunning(s.Kubernetes) {
+				acc = append(acc, v.Name())
+			}
+		case s.Remote != nil:
+			remotes[s.Remote.Host] = s.Remote
+			placed[v.Name()] = true
+		}
+	}
+	for host, r := range remotes {
+		sids, rerr := listRemote(r)
+		if rerr != nil {
+			log.Printf("[WARN] unable to list the sessions of %s: %v", host, rerr)
+			continue
+		}
+		for _, v := range sids {
+			// Sessions of other servers may be running there.
+			if placed[v] {
+				acc = append(acc, v)
+			}
+		}
+	}
+	return acc, err
+}
+
+// signal delivers "sig" to the wrapper of "p". Jobs are deleted instead, which
+// makes Kubernetes terminate their pod.
+func (p *PWrap) signal(sig syscall.Signal) error {
+	if k := p.job(); k != nil {
+		return kube.DeleteSession(k.Namespace, p.sid, p.grace+killMargin)
+	}
+	if r := p.placement(); r != nil {
+		return p.signalRemote(r, sig)
+	}
+	if pid := readPID(p.Path(FilePID)); pid > 0 {
+		return signalProcess(pid, sig)
+	}
+	return tmux.SignalSession(p.sid, sig)
+}
+
+// kill terminates the wrapper of "p" together with its child.
+func (p *PWrap) kill() error {
+	if k := p.job(); k != nil {
+		return kube.DeleteSession(k.Namespace, p.sid, -1)
+	}
+	if r := p.placement(); r != nil {
+		return p.killRemote(r)
+	}
+	pid := readPID(p.Path(FilePID))
+	if pid == 0 {
+		return tmux.KillSession(p.sid)
+	}
+	if err := signalProcess(pid, syscall.SIGKILL); err != nil {
+		return err
+	}
+	// The wrapper did not have the chance to remove it.
+	if err := os.Remove(p.Path(FilePID)); err != nil && !os.IsNotExist(err) {
+		log.Printf("[WARN] unable to remove pid file of session %s: %v", p.sid, err)
+	}
+	return nil
+}
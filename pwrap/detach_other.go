@@ -0,0 +1,32 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+//go:build !windows
+
+package pwrap
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+)
+
+func detachedProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
+
+// signalProcess delivers "sig" to the process group led by "pid", which
+// includes the wrapper's child.
+func signalProcess(pid int, sig syscall.Signal) error {
+	if err := syscall.Kill(-pid, sig); err != nil {
+		return fmt.Errorf("unable to signal process %d: %w", pid, err)
+	}
+	return nil
+}
+
+// processAlive reports whether process "pid" exists.
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,54 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestHasSession_Detached(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	write := func(sid string, pid int) {
+		dir := filepath.Join(root, sid)
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, FilePID), []byte(strconv.Itoa(pid)+"\n"), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A process that has surely exited and has been reaped.
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	write("pmux-alive", os.Getpid())
+	write("pmux-exited", cmd.ProcessState.Pid())
+
+	if !HasSession(root, "pmux-alive") {
+		t.Fatal("expected session to be running")
+	}
+	if HasSession(root, "pmux-exited") {
+		t.Fatal("expected session not to be running")
+	}
+	sids, err := ListSessions(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := map[string]bool{}
+	for _, v := range sids {
+		found[v] = true
+	}
+	if !found["pmux-alive"] || found["pmux-exited"] {
+		t.Fatalf("unexpected sessions: %v", sids)
+	}
+}
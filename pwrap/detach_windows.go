@@ -0,0 +1,38 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+func detachedProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+// signalProcess delivers "sig" to process "pid". Signals other than SIGKILL
+// are not supported by Windows.
+func signalProcess(pid int, sig syscall.Signal) error {
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return fmt.Errorf("unable to find process %d: %w", pid, err)
+	}
+	if err := proc.Signal(sig); err != nil {
+		return fmt.Errorf("unable to signal process %d: %w", pid, err)
+	}
+	return nil
+}
+
+// processAlive reports whether process "pid" exists.
+func processAlive(pid int) bool {
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	proc.Release()
+	return true
+}
@@ -0,0 +1,81 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// dryRunTimeout is the time the executable is given to print its help.
+const dryRunTimeout = time.Second * 5
+
+// DryRun checks that the session can be started, without starting it: its
+// configuration decodes, unless it was uploaded as is, its communication socket can be created, and its
+// executable and sidecars exist. The executable is then probed with the flags it
+// receives from the wrapper followed by "--help", which has to succeed, so that
+// children rejecting their flags are detected. Executables running inside a
+// container, a Kubernetes Job or on a remote host are neither looked up nor
+// probed.
+func (p *PWrap) DryRun(ctx context.Context) error {
+	b, err := os.ReadFile(p.Path(FileConfig))
+	if err != nil {
+		return fmt.Errorf("dry run: unable to read configuration: %w", err)
+	}
+	if s, _ := p.ReadSession(); (s == nil || !s.RawConfig) && !json.Valid(b) {
+		return fmt.Errorf("dry run: configuration is not valid JSON")
+	}
+	for _, v := range p.sidecars {
+		if _, err := exec.LookPath(v.Path); err != nil {
+			return fmt.Errorf("dry run: sidecar %s: %w", v.Name, err)
+		}
+	}
+	if p.container != nil || p.kube != nil || p.remote != nil {
+		return nil
+	}
+	addr := p.SockPath()
+	if p.transport == TransportUnix {
+		if err := claimSock(addr); err != nil {
+			return fmt.Errorf("dry run: %w", err)
+		}
+		l, err := net.Listen("unix", addr)
+		if err != nil {
+			return fmt.Errorf("dry run: unable to create communication socket: %w", err)
+		}
+		l.Close()
+	}
+	if _, err := exec.LookPath(p.name); err != nil {
+		return fmt.Errorf("dry run: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dryRunTimeout)
+	defer cancel()
+	args, err := p.childArgs(p.Path(FileConfig), addr, 0)
+	if err != nil {
+		return fmt.Errorf("dry run: %w", err)
+	}
+	cmd := exec.CommandContext(ctx, p.name, append(args, "--help")...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			err = fmt.Errorf("no help printed within %v", dryRunTimeout)
+		}
+		// The reason usually comes first, followed by the usage.
+		msg := strings.SplitN(strings.TrimSpace(stderr.String()), "\n", 2)[0]
+		if msg != "" {
+			err = fmt.Errorf("%w: %s", err, msg)
+		}
+		return fmt.Errorf("dry run: %s does not accept its flags: %w", p.name, err)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestPWrap_DryRun(t *testing.T) {
+	t.Parallel()
+
+	dir, err := os.MkdirTemp("", "pmux-dryrun-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// The probe accepts the flags of the wrapper only.
+	script := dir + "/child"
+	if err := os.WriteFile(script, []byte(`#!/bin/sh
+for v in "$@"; do
+	case "$v" in
+	--config=*|--socket-path=*|--help) ;;
+	*) echo "unknown flag: $v" >&2; echo usage >&2; exit 1 ;;
+	esac
+done
+`), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, tt := range []struct {
+		args   []string
+		config string
+		raw    bool
+		err    string
+	}{
+		{nil, "{}", false, ""},
+		{[]string{"--verbose"}, "{}", false, "unknown flag: --verbose"},
+		{nil, "{", false, "configuration is not valid JSON"},
+		{nil, "", false, "configuration is not valid JSON"},
+		{nil, "\x00\xff", true, ""},
+	} {
+		pw, err := New(Exec(script, tt.args...), RootDir(dir))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.raw {
+			if err := pw.UpdateSession(func(s *Session) { s.RawConfig = true }); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := os.WriteFile(pw.Path(FileConfig), []byte(tt.config), 0644); err != nil {
+			t.Fatal(err)
+		}
+		err = pw.DryRun(context.Background())
+		switch {
+		case tt.err == "" && err != nil:
+			t.Fatalf("%d: %v", i, err)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Fatalf("%d: wanted error %q, found %v", i, tt.err, err)
+		}
+		if _, err := os.Stat(pw.SockPath()); !os.IsNotExist(err) {
+			t.Fatalf("%d: the socket SHOULD be removed after the dry run", i)
+		}
+	}
+
+	pw, err := New(Exec(script), RootDir(dir), Sidecars(Sidecar{Name: "metrics", Path: dir + "/missing"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(pw.Path(FileConfig), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := pw.DryRun(context.Background()); err == nil || !strings.Contains(err.Error(), "sidecar metrics") {
+		t.Fatalf("Missing sidecars SHOULD be reported, found %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/kim-company/pmux/trace"
+)
+
+var envNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ValidateEnv reports whether "vars", mapping the names of environment variables
+// to their values, can be added to the environment of the child. The variables
+// starting with "PMUX_" and "TRACEPARENT" are reserved to the wrapper.
+func ValidateEnv(vars map[string]string) error {
+	for k, v := range vars {
+		if !envNameRe.MatchString(k) {
+			return fmt.Errorf("invalid environment variable name %q", k)
+		}
+		if strings.HasPrefix(k, "PMUX_") || k == trace.EnvTraceparent {
+			return fmt.Errorf("environment variable %q is reserved", k)
+		}
+		if strings.ContainsRune(v, 0) {
+			return fmt.Errorf("invalid value of environment variable %q", k)
+		}
+	}
+	return nil
+}
+
+// ParseEnv parses environment variables in the NAME=value form.
+func ParseEnv(kvs []string) (map[string]string, error) {
+	vars := make(map[string]string, len(kvs))
+	for _, v := range kvs {
+		kv := strings.SplitN(v, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid environment variable %q, expected NAME=value", v)
+		}
+		vars[kv[0]] = kv[1]
+	}
+	if err := ValidateEnv(vars); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+// Env adds "vars" to the environment of the child, which otherwise inherits the
+// one of the wrapper. Unlike secrets, the values are recorded in the session
+// state and passed to the wrapper on its command line.
+func Env(vars map[string]string) func(*PWrap) error {
+	return func(p *PWrap) error {
+		if err := ValidateEnv(vars); err != nil {
+			return err
+		}
+		p.env = vars
+		return nil
+	}
+}
+
+// environ returns the variables of "vars" in the NAME=value form, sorted by name.
+func environ(vars map[string]string) []string {
+	names := make([]string, 0, len(vars))
+	for k := range vars {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	acc := make([]string, 0, len(names))
+	for _, k := range names {
+		acc = append(acc, k+"="+vars[k])
+	}
+	return acc
+}
@@ -0,0 +1,57 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestParseEnv(t *testing.T) {
+	t.Parallel()
+
+	vars, err := ParseEnv([]string{"LANG=C", "OPTS=a=b", "EMPTY="})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"LANG": "C", "OPTS": "a=b", "EMPTY": ""}
+	if !reflect.DeepEqual(vars, want) {
+		t.Fatalf("Variables SHOULD be %v, found %v", want, vars)
+	}
+	if e := environ(vars); !reflect.DeepEqual(e, []string{"EMPTY=", "LANG=C", "OPTS=a=b"}) {
+		t.Fatalf("Variables SHOULD be sorted by name: %q", e)
+	}
+	for _, v := range []string{"LANG", "=C", "1LANG=C", "PMUX_SID=x", "TRACEPARENT=x"} {
+		if _, err := ParseEnv([]string{v}); err == nil {
+			t.Fatalf("Variable %q SHOULD be rejected", v)
+		}
+	}
+}
+
+func TestEnv(t *testing.T) {
+	t.Parallel()
+
+	vars := map[string]string{"LANG": "C", "MODE": "fast mode"}
+	pw, err := New(RootDir(os.TempDir()), Exec("yes"), Env(vars))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(pw.WorkDir())
+	args := pw.wrapArgs(os.TempDir())
+	if !contains(args, "--env=LANG=C") || !contains(args, "--env=MODE=fast mode") {
+		t.Fatalf("Variables SHOULD be passed to the wrapper: %q", args)
+	}
+	if err := pw.UpdateSession(func(*Session) {}); err != nil {
+		t.Fatal(err)
+	}
+	s, err := pw.ReadSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(s.Env, vars) {
+		t.Fatalf("Variables SHOULD be recorded in the session, found %v", s.Env)
+	}
+}
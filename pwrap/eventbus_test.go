@@ -0,0 +1,107 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// serveOnce accepts a single connection on a new listener, handing it to "f".
+func serveOnce(t *testing.T, f func(net.Conn, *bufio.Reader)) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		f(conn, bufio.NewReader(conn))
+	}()
+	return l.Addr().String()
+}
+
+func readLine(r *bufio.Reader) string {
+	line, _ := r.ReadString('\n')
+	return strings.TrimSuffix(line, "\r\n")
+}
+
+func TestHooks_SendNATS(t *testing.T) {
+	t.Parallel()
+
+	type pub struct {
+		connect map[string]interface{}
+		subject string
+		event   Event
+	}
+	pubs := make(chan pub, 1)
+	addr := serveOnce(t, func(conn net.Conn, r *bufio.Reader) {
+		conn.Write([]byte("INFO {\"server_id\":\"test\"}\r\n"))
+		var p pub
+		json.Unmarshal([]byte(strings.TrimPrefix(readLine(r), "CONNECT ")), &p.connect)
+		fields := strings.Fields(readLine(r))
+		p.subject = fields[1]
+		n, _ := strconv.Atoi(fields[2])
+		payload := make([]byte, n+2)
+		io.ReadFull(r, payload)
+		json.Unmarshal(payload[:n], &p.event)
+		if readLine(r) == "PING" {
+			conn.Write([]byte("PONG\r\n"))
+		}
+		pubs <- p
+	})
+
+	Hooks{"nats://token@" + addr}.Send(NewEvent(EventStarted, "pmux-test", nil))
+	p := <-pubs
+	if p.subject != "pmux.session.started" {
+		t.Fatalf("unexpected subject %q", p.subject)
+	}
+	if p.connect["auth_token"] != "token" {
+		t.Fatalf("unexpected connect options: %v", p.connect)
+	}
+	if p.event.Type != EventStarted || p.event.SID != "pmux-test" {
+		t.Fatalf("unexpected event: %+v", p.event)
+	}
+}
+
+func TestHooks_SendRedis(t *testing.T) {
+	t.Parallel()
+
+	commands := make(chan []string, 2)
+	addr := serveOnce(t, func(conn net.Conn, r *bufio.Reader) {
+		for i := 0; i < 2; i++ {
+			n, _ := strconv.Atoi(strings.TrimPrefix(readLine(r), "*"))
+			args := make([]string, n)
+			for j := range args {
+				readLine(r)
+				args[j] = readLine(r)
+			}
+			commands <- args
+			conn.Write([]byte(":1\r\n"))
+		}
+	})
+
+	Hooks{"redis://:secret@" + addr + "/jobs.{sid}"}.Send(NewEvent(EventFinished, "pmux-test", nil))
+	if auth := <-commands; len(auth) != 2 || auth[0] != "AUTH" || auth[1] != "secret" {
+		t.Fatalf("unexpected auth command: %v", auth)
+	}
+	publish := <-commands
+	if len(publish) != 3 || publish[0] != "PUBLISH" || publish[1] != "jobs.pmux-test" {
+		t.Fatalf("unexpected publish command: %v", publish)
+	}
+	var e Event
+	if err := json.Unmarshal([]byte(publish[2]), &e); err != nil || e.Type != EventFinished {
+		t.Fatalf("unexpected event %q: %v", publish[2], err)
+	}
+}
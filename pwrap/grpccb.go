@@ -0,0 +1,381 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/kim-company/pmux/http/pwrapapi"
+	"github.com/kim-company/pmux/pwrap/bridgepb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// GRPCCommBridge is a communication bridge serving the gRPC service of package
+// "bridgepb" on a Unix Domain Socket, a strongly typed alternative to the line
+// based protocol of the other bridges. Wrappers started with "TransportGRPC"
+// translate the requests of their HTTP API to the calls of the service.
+type GRPCCommBridge struct {
+	*UnixCommBridge
+	server *grpc.Server
+	// ctx is the context the bridge has been opened with.
+	ctx context.Context
+	// closed is closed along with the bridge, ending the pending streams.
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// grpcStopTimeout is the maximum amount of time the pending calls have to
+// complete once the bridge is closed.
+const grpcStopTimeout = time.Second
+
+// NewGRPCCommBridge starts a Unix Domain Socket listener on "path", serving the
+// gRPC bridge service once opened. The token of the bridge, if any, has to be
+// presented by the calls in the "token" metadata key.
+// Is is the caller's responsibility to close the bridge when it's done.
+func NewGRPCCommBridge(ctx context.Context, path string, opts ...CommBridgeOption) (*GRPCCommBridge, error) {
+	u, err := NewUnixCommBridge(ctx, path, opts...)
+	if err != nil {
+		return nil, err
+	}
+	g := &GRPCCommBridge{UnixCommBridge: u, ctx: context.Background(), closed: make(chan struct{})}
+	u.self = g
+	g.server = grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, h grpc.UnaryHandler) (interface{}, error) {
+			if err := g.authorize(ctx); err != nil {
+				return nil, err
+			}
+			return h(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, h grpc.StreamHandler) error {
+			if err := g.authorize(ss.Context()); err != nil {
+				return err
+			}
+			return h(srv, ss)
+		}),
+	)
+	bridgepb.RegisterBridgeServer(g.server, &bridgeService{g})
+	return g, nil
+}
+
+// Open serves the gRPC bridge service, blocking until the bridge is closed. Context
+// cancelation will not make the function quit, but it will end any pending call.
+func (b *GRPCCommBridge) Open(ctx context.Context) {
+	b.ctx = ctx
+	if err := b.server.Serve(b.Listener); err != nil {
+		log.Printf("[ERROR] unable to accept more connections: %v", err)
+	}
+}
+
+// Close stops the gRPC server and removes the socket file. The pending calls
+// are given "grpcStopTimeout" to complete, so that the response to a command
+// making the child exit is still delivered.
+func (b *GRPCCommBridge) Close() error {
+	b.closeOnce.Do(func() { close(b.closed) })
+	stopped := make(chan struct{})
+	go func() {
+		b.server.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(grpcStopTimeout):
+		b.server.Stop()
+	}
+	// Once served, the listener is closed by the server.
+	if err := b.UnixCommBridge.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+		return err
+	}
+	return nil
+}
+
+// authorize checks the token presented by the call of "ctx".
+func (b *GRPCCommBridge) authorize(ctx context.Context) error {
+	if b.token == "" {
+		return nil
+	}
+	var token string
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get("token")) > 0 {
+		token = md.Get("token")[0]
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(b.token)) != 1 {
+		log.Printf("[ERROR] handle grpc call: call did not present a valid token")
+		return status.Error(codes.Unauthenticated, "invalid token")
+	}
+	return nil
+}
+
+// context returns a context that is done with "ctx", with the context the
+// bridge has been opened with or once the bridge is closed.
+func (b *GRPCCommBridge) context(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-b.ctx.Done():
+			cancel()
+		case <-b.closed:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// bridgeService implements "bridgepb.BridgeServer" on top of the channels of the
+// bridge.
+type bridgeService struct {
+	b *GRPCCommBridge
+}
+
+// Progress streams the progress updates. The content written as is to the
+// progress channel is only delivered by Stream.
+func (s *bridgeService) Progress(_ *bridgepb.ProgressRequest, stream bridgepb.Bridge_ProgressServer) error {
+	ctx, cancel := s.b.context(stream.Context())
+	defer cancel()
+	// Dead clients are detected by the transport, no heartbeat is needed.
+	return streamEnd(ctx, s.b.writeUpdates(ctx, ioutil.Discard, s.b.lookupChannel(ChannelProgress), progressSender(stream.Send), 0))
+}
+
+// Stream streams the content of the requested channel, the progress one by
+// default. Progress updates are JSON encoded.
+func (s *bridgeService) Stream(req *bridgepb.StreamRequest, stream bridgepb.Bridge_StreamServer) error {
+	ctx, cancel := s.b.context(stream.Context())
+	defer cancel()
+	name := req.Channel
+	if name == "" {
+		name = ChannelProgress
+	}
+	ch := s.b.lookupChannel(name)
+	if ch == nil {
+		return status.Errorf(codes.NotFound, "unknown channel %q", name)
+	}
+	w := frameWriter(stream.Send)
+	return streamEnd(ctx, s.b.writeUpdates(ctx, w, ch, &jsonProgressEncoder{enc: json.NewEncoder(w)}, 0))
+}
+
+// streamEnd returns the error ending a stream, none if it ended because its
+// context is done, which is the end of the stream for the client.
+func streamEnd(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// Command delivers the command to the command handler. Handler errors are
+// reported in the response, like on the line based protocol.
+func (s *bridgeService) Command(_ context.Context, req *bridgepb.CommandRequest) (*bridgepb.CommandResponse, error) {
+	log.Printf("[INFO] command read: %v", req.Command)
+	resp, err := s.b.runCommand(req.Command)
+	payload := &bridgepb.CommandResponse{Ok: err == nil, Response: resp}
+	if err != nil {
+		payload.Error = err.Error()
+	}
+	return payload, nil
+}
+
+// Result waits for the first frame written to the result channel.
+func (s *bridgeService) Result(ctx context.Context, _ *bridgepb.ResultRequest) (*bridgepb.ResultResponse, error) {
+	ctx, cancel := s.b.context(ctx)
+	defer cancel()
+	c := s.b.lookupChannel(ChannelResult).subscribe(s.b.queueSize)
+	defer c.close()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-c.kicked:
+			return nil, status.Error(codes.ResourceExhausted, "too slow in consuming the result")
+		case f := <-c.c:
+			if f.raw == nil {
+				continue
+			}
+			return &bridgepb.ResultResponse{Json: bytes.TrimSpace(f.raw)}, nil
+		}
+	}
+}
+
+// progressSender is a "progressEncoder" sending the updates over a gRPC stream.
+type progressSender func(*bridgepb.ProgressUpdate) error
+
+func (f progressSender) Encode(u *ProgressUpdate) error {
+	pu := &bridgepb.ProgressUpdate{
+		Description: u.Description,
+		Stage:       int64(u.Stage),
+		Stages:      int64(u.Stages),
+		Partial:     int64(u.Partial),
+		Total:       int64(u.Total),
+		StageName:   u.StageName,
+		Unit:        u.Unit,
+		Labels:      u.Labels,
+	}
+	if u.ETA != nil {
+		eta, err := ptypes.TimestampProto(*u.ETA)
+		if err != nil {
+			return fmt.Errorf("unable to encode progress update: %w", err)
+		}
+		pu.Eta = eta
+	}
+	return f(pu)
+}
+
+// frameWriter is an "io.Writer" sending the content written over a gRPC stream.
+type frameWriter func(*bridgepb.StreamFrame) error
+
+func (f frameWriter) Write(p []byte) (int, error) {
+	if err := f(&bridgepb.StreamFrame{Data: p}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// grpcDialer returns a DialFunc reaching a "GRPCCommBridge" on the socket at
+// "path". The connections returned speak the line based protocol, translated
+// to the calls of the gRPC service, so that the wrapper API does not depend on
+// the transport of the bridge.
+func grpcDialer(path string) pwrapapi.DialFunc {
+	return func() (net.Conn, error) {
+		// Dial the socket first, so that missing children are reported
+		// right away, as by the other transports.
+		sock, err := net.Dial("unix", path)
+		if err != nil {
+			return nil, err
+		}
+		socks := make(chan net.Conn, 1)
+		socks <- sock
+		cc, err := grpc.Dial(path, grpc.WithInsecure(), grpc.WithDialer(func(addr string, d time.Duration) (net.Conn, error) {
+			select {
+			case c := <-socks:
+				return c, nil
+			default:
+				return net.DialTimeout("unix", addr, d)
+			}
+		}))
+		if err != nil {
+			sock.Close()
+			return nil, fmt.Errorf("unable to dial %v: %w", path, err)
+		}
+		conn, peer := net.Pipe()
+		go func() {
+			defer cc.Close()
+			defer peer.Close()
+			err := translateGRPC(bridgepb.NewBridgeClient(cc), peer)
+			// The end of the stream, the client going away and the child
+			// exiting are not errors, as on the other transports.
+			switch {
+			case err == nil, errors.Is(err, io.EOF), errors.Is(err, io.ErrClosedPipe):
+			case status.Code(err) == codes.Canceled, status.Code(err) == codes.Unavailable:
+			default:
+				log.Printf("[ERROR] handle grpc conn: %v", err)
+			}
+		}()
+		return conn, nil
+	}
+}
+
+// translateGRPC serves the line based protocol on "conn" calling "client".
+func translateGRPC(client bridgepb.BridgeClient, conn net.Conn) error {
+	r := bufio.NewReader(conn)
+	header, err := r.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("unable to read header: %w", err)
+	}
+	h := parseHeader(header)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if h["token"] != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "token", h["token"])
+	}
+	switch h["mode"] {
+	case "command":
+		cmd, err := r.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("unable to read command: %w", err)
+		}
+		resp, err := client.Command(ctx, &bridgepb.CommandRequest{Command: strings.TrimRight(cmd, "\n")})
+		if err != nil {
+			return writeCommandResponse(conn, "", err)
+		}
+		return json.NewEncoder(conn).Encode(&CommandResponse{OK: resp.Ok, Response: resp.Response, Error: resp.Error})
+	case "progress":
+		enc, err := newProgressEncoder(h["format"], conn)
+		if err != nil {
+			return err
+		}
+		if err := negotiateProtocol(conn, h["v"]); err != nil {
+			return err
+		}
+		go cancelOnClose(r, cancel)
+		stream, err := client.Progress(ctx, &bridgepb.ProgressRequest{})
+		if err != nil {
+			return err
+		}
+		for {
+			pu, err := stream.Recv()
+			if err != nil {
+				return err
+			}
+			u := &ProgressUpdate{
+				Description: pu.Description,
+				Stage:       int(pu.Stage),
+				Stages:      int(pu.Stages),
+				Partial:     int(pu.Partial),
+				Total:       int(pu.Total),
+				StageName:   pu.StageName,
+				Unit:        pu.Unit,
+				Labels:      pu.Labels,
+			}
+			if pu.Eta != nil {
+				eta, err := ptypes.Timestamp(pu.Eta)
+				if err != nil {
+					return fmt.Errorf("invalid progress update: %w", err)
+				}
+				u.ETA = &eta
+			}
+			if err := enc.Encode(u); err != nil {
+				return err
+			}
+		}
+	case "stream":
+		go cancelOnClose(r, cancel)
+		stream, err := client.Stream(ctx, &bridgepb.StreamRequest{Channel: h["channel"]})
+		if err != nil {
+			return err
+		}
+		for {
+			f, err := stream.Recv()
+			if err != nil {
+				return err
+			}
+			if _, err := conn.Write(f.Data); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unrecognised header %q", header)
+	}
+}
+
+// cancelOnClose calls "cancel" once "r" is closed by the client.
+func cancelOnClose(r io.Reader, cancel context.CancelFunc) {
+	io.Copy(ioutil.Discard, r)
+	cancel()
+}
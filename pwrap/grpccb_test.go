@@ -0,0 +1,153 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kim-company/pmux/http/pwrapapi"
+	"github.com/kim-company/pmux/pwrap/bridgepb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func newTestGRPCBridge(t *testing.T, opts ...CommBridgeOption) (*GRPCCommBridge, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	path := filepath.Join(os.TempDir(), "pwrap-test-"+uuid.New().String()+".sock")
+	b, err := NewCommBridge(ctx, TransportGRPC, path, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go b.Open(ctx)
+	return b.(*GRPCCommBridge), func() {
+		cancel()
+		b.Close()
+	}
+}
+
+func TestGRPCCommBridge(t *testing.T) {
+	t.Parallel()
+
+	b, close := newTestGRPCBridge(t, AuthToken("secret"), Channels("logs"), OnCommandResponse(func(u CommBridge, cmd string) (string, error) {
+		if cmd != "ping" {
+			return "", fmt.Errorf("unknown command %q", cmd)
+		}
+		return "pong", nil
+	}))
+	defer close()
+	br := pwrapapi.Bridge{Dial: grpcDialer(b.path), Token: "secret"}
+
+	// Progress updates are translated back to the line based protocol.
+	conn, err := br.OpenProgress(FormatJSON, pwrapapi.ProgressProtocol)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if conn.Version != 2 {
+		t.Fatalf("gRPC bridges SHOULD speak version 2, found %d", conn.Version)
+	}
+	waitClients(t, b.bridge, 1)
+	eta := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+	if err := b.WriteProgress(&ProgressUpdate{Description: "working", Stage: 1, Stages: 2, Partial: 3, Total: 4, ETA: &eta, Labels: map[string]string{"k": "v"}}); err != nil {
+		t.Fatal(err)
+	}
+	var u ProgressUpdate
+	if err := json.NewDecoder(conn).Decode(&u); err != nil {
+		t.Fatal(err)
+	}
+	if u.Description != "working" || u.Stage != 1 || u.Total != 4 || u.ETA == nil || !u.ETA.Equal(eta) || u.Labels["k"] != "v" {
+		t.Fatalf("Unexpected update %+v", u)
+	}
+
+	// Commands and their responses.
+	for cmd, exp := range map[string]CommandResponse{
+		"ping":   {OK: true, Response: "pong"},
+		"cancel": {OK: false, Error: "unknown command \"cancel\""},
+	} {
+		conn, err := br.Open("mode=command")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := conn.Write([]byte(cmd + "\n")); err != nil {
+			t.Fatal(err)
+		}
+		var resp CommandResponse
+		if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+		if resp != exp {
+			t.Fatalf("Wanted %+v, found %+v", exp, resp)
+		}
+	}
+
+	// Named streams.
+	logs, err := br.Open("mode=stream;channel=logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logs.Close()
+	for i := 0; i < 100 && b.channel("logs").len() == 0; i++ {
+		time.Sleep(time.Millisecond * 10)
+	}
+	if _, err := b.Channel("logs").Write([]byte("line\n")); err != nil {
+		t.Fatal(err)
+	}
+	logs.SetReadDeadline(time.Now().Add(time.Second * 2))
+	if line, err := bufio.NewReader(logs).ReadString('\n'); err != nil || line != "line\n" {
+		t.Fatalf("Stream content SHOULD be delivered as is: %q, %v", line, err)
+	}
+}
+
+func TestGRPCCommBridge_Calls(t *testing.T) {
+	t.Parallel()
+
+	b, close := newTestGRPCBridge(t, AuthToken("secret"))
+	defer close()
+	cc, err := grpc.Dial(b.path, grpc.WithInsecure(), grpc.WithDialer(func(addr string, d time.Duration) (net.Conn, error) {
+		return net.DialTimeout("unix", addr, d)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cc.Close()
+	client := bridgepb.NewBridgeClient(cc)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	if _, err := client.Result(ctx, &bridgepb.ResultRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("Calls without a valid token SHOULD be refused, found %v", err)
+	}
+
+	ctx = metadata.AppendToOutgoingContext(ctx, "token", "secret")
+	stream, err := client.Stream(ctx, &bridgepb.StreamRequest{Channel: "unknown"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.NotFound {
+		t.Fatalf("Unknown channels SHOULD be rejected, found %v", err)
+	}
+	if _, err := b.Channel(ChannelResult).Write([]byte("{\"frames\":42}\n")); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Result(ctx, &bridgepb.ResultRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(resp.Json) != "{\"frames\":42}" {
+		t.Fatalf("Unexpected result %q", resp.Json)
+	}
+}
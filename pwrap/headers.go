@@ -0,0 +1,79 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// headerNameRe matches the names of HTTP headers, see RFC 7230.
+var headerNameRe = regexp.MustCompile("^[!#$%&'*+.^_`|~0-9A-Za-z-]+$")
+
+// ValidateHeaders reports whether "h", mapping the names of HTTP headers to their
+// values, can be attached to the registration and callback requests.
+func ValidateHeaders(h map[string]string) error {
+	for k, v := range h {
+		if !headerNameRe.MatchString(k) {
+			return fmt.Errorf("invalid header name %q", k)
+		}
+		if strings.ContainsAny(v, "\r\n\x00") {
+			return fmt.Errorf("invalid value of header %q", k)
+		}
+		switch http.CanonicalHeaderKey(k) {
+		case "Content-Type", "Content-Length", "Host":
+			return fmt.Errorf("header %q cannot be set", k)
+		}
+	}
+	return nil
+}
+
+// ParseHeaders parses HTTP headers in the "Name: value" form.
+func ParseHeaders(kvs []string) (map[string]string, error) {
+	h := make(map[string]string, len(kvs))
+	for _, v := range kvs {
+		kv := strings.SplitN(v, ":", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid header %q, expected Name: value", v)
+		}
+		h[kv[0]] = strings.TrimSpace(kv[1])
+	}
+	if err := ValidateHeaders(h); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// RegisterHeaders attaches the HTTP headers "h" to the registration and callback
+// requests, e.g. the credentials required by a gateway in front of the receiver.
+// Like the API token, they are recorded in the session state, but are not shown
+// by the server.
+func RegisterHeaders(h map[string]string) func(*PWrap) error {
+	return func(p *PWrap) error {
+		if err := ValidateHeaders(h); err != nil {
+			return err
+		}
+		p.regHeaders = h
+		return nil
+	}
+}
+
+// headerArgs returns the "--reg-header" flags of the wrapper passing "h", sorted
+// by name.
+func headerArgs(h map[string]string) []string {
+	names := make([]string, 0, len(h))
+	for k := range h {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	args := make([]string, 0, len(names))
+	for _, k := range names {
+		args = append(args, "--reg-header="+k+": "+h[k])
+	}
+	return args
+}
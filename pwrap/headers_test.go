@@ -0,0 +1,77 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestParseHeaders(t *testing.T) {
+	t.Parallel()
+
+	h, err := ParseHeaders([]string{"Authorization: Bearer a:b", "X-Tenant:acme"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"Authorization": "Bearer a:b", "X-Tenant": "acme"}
+	if !reflect.DeepEqual(h, want) {
+		t.Fatalf("Headers SHOULD be %v, found %v", want, h)
+	}
+	for _, v := range []string{"Authorization", ": token", "X Tenant: acme", "Content-Type: text/plain", "X-Tenant: a\nb"} {
+		if _, err := ParseHeaders([]string{v}); err == nil {
+			t.Fatalf("Header %q SHOULD be rejected", v)
+		}
+	}
+}
+
+func TestRegisterHeaders(t *testing.T) {
+	t.Parallel()
+
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get("Authorization")+" "+r.Header.Get("Content-Type"))
+	}))
+	defer srv.Close()
+
+	h := map[string]string{"Authorization": "Bearer secret"}
+	pw, err := New(RootDir(os.TempDir()), Register(srv.URL), RegisterHeaders(h))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(pw.WorkDir())
+	if args := pw.wrapArgs(os.TempDir()); !contains(args, "--reg-header=Authorization: Bearer secret") {
+		t.Fatalf("Headers SHOULD be passed to the wrapper: %v", args)
+	}
+
+	if err := pw.Register(4000); err != nil {
+		t.Fatal(err)
+	}
+	if err := pw.Callback(nil); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"Bearer secret application/json", "Bearer secret application/json"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Registration and callback SHOULD carry the headers: %q", got)
+	}
+
+	// Headers are recorded, so that restarts keep them.
+	if err := pw.UpdateSession(func(*Session) {}); err != nil {
+		t.Fatal(err)
+	}
+	s, err := pw.ReadSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(s.RegisterHeaders, h) {
+		t.Fatalf("Headers SHOULD be recorded in the session, found %v", s.RegisterHeaders)
+	}
+	if e := NewEvent(EventCreated, pw.SID(), s); e.Session.RegisterHeaders != nil {
+		t.Fatalf("Events SHOULD NOT carry the headers")
+	}
+}
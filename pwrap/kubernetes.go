@@ -0,0 +1,238 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/kim-company/pmux/kube"
+)
+
+// Kubernetes describes the Job a session is executed as. The wrapper runs inside
+// the Job's pod, with its working directory in an empty volume and the
+// configuration mounted from a Secret. As the wrapper API is not reachable
+// from the server, the session is followed through its registration URL and
+// webhooks, while its state is derived from the Job's.
+type Kubernetes struct {
+	Namespace string `json:"namespace"`
+	// Image has to provide both pmux and the executable of the session.
+	Image string `json:"image"`
+	// PMux is the path of the pmux executable inside the image. Defaults
+	// to "pmux", looked up in the image's PATH.
+	PMux           string `json:"pmux,omitempty"`
+	ServiceAccount string `json:"service_account,omitempty"`
+	// CPU and Memory limit the resources available to the pod, in the
+	// Kubernetes quantity format, e.g. "500m" and "1Gi".
+	CPU    string `json:"cpu,omitempty"`
+	Memory string `json:"memory,omitempty"`
+}
+
+// KubeRoot is the root directory of the wrapper inside the Job's pod.
+const KubeRoot = "/pmux"
+
+// Validate reports whether "k" can be used to run a session.
+func (k *Kubernetes) Validate() error {
+	if k.Namespace == "" {
+		return fmt.Errorf("kubernetes namespace not set")
+	}
+	if k.Image == "" {
+		return fmt.Errorf("kubernetes image not set")
+	}
+	return nil
+}
+
+// KubernetesJob makes the session run as a Kubernetes Job described by "k",
+// rather than on this host. This function has to be called before "Exec" if used
+// in the ``New'' function, as the executable is then looked up inside the image.
+func KubernetesJob(k *Kubernetes) func(*PWrap) error {
+	return func(p *PWrap) error {
+		if k == nil {
+			p.kube = nil
+			return nil
+		}
+		if err := k.Validate(); err != nil {
+			return err
+		}
+		p.kube = k
+		return nil
+	}
+}
+
+// job returns the Job description of "p", either set as an option or recorded
+// in its session state. Returns nil if the session does not run as a Job.
+func (p *PWrap) job() *Kubernetes {
+	if p.kube != nil {
+		return p.kube
+	}
+	if s, err := p.ReadSession(); err == nil {
+		return s.Kubernetes
+	}
+	return nil
+}
+
+// jobManifest returns the Secret and Job resources executing "p", encoded as a
+// Kubernetes "List" resource.
+func (p *PWrap) jobManifest(config []byte) ([]byte, error) {
+	k := p.kube
+	labels := map[string]string{kube.LabelSID: p.sid, "app.kubernetes.io/managed-by": "pmux"}
+	meta := map[string]interface{}{"name": p.sid, "namespace": k.Namespace, "labels": labels}
+	pmux := k.PMux
+	if pmux == "" {
+		pmux = "pmux"
+	}
+	container := map[string]interface{}{
+		"name":    "pmux",
+		"image":   k.Image,
+		"command": []string{pmux},
+		"args":    p.wrapArgs(KubeRoot),
+		"volumeMounts": []interface{}{
+			map[string]interface{}{"name": "workdir", "mountPath": KubeRoot},
+			map[string]interface{}{
+				"name":      "config",
+				"mountPath": filepath.Join(KubeRoot, p.sid, FileConfig),
+				"subPath":   FileConfig,
+				"readOnly":  true,
+			},
+		},
+	}
+	limits := map[string]string{}
+	if k.CPU != "" {
+		limits["cpu"] = k.CPU
+	}
+	if k.Memory != "" {
+		limits["memory"] = k.Memory
+	}
+	if len(limits) > 0 {
+		container["resources"] = map[string]interface{}{"limits": limits}
+	}
+	pod := map[string]interface{}{
+		"restartPolicy": "Never",
+		// The wrapper needs the time to stop its child gracefully
+		// and perform its callback.
+		"terminationGracePeriodSeconds": int((p.grace + killMargin).Seconds()),
+		"containers":                    []interface{}{container},
+		"volumes": []interface{}{
+			map[string]interface{}{"name": "workdir", "emptyDir": map[string]interface{}{}},
+			map[string]interface{}{"name": "config", "secret": map[string]interface{}{"secretName": p.sid}},
+		},
+	}
+	if k.ServiceAccount != "" {
+		pod["serviceAccountName"] = k.ServiceAccount
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "List",
+		"items": []interface{}{
+			map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "Secret",
+				"metadata":   meta,
+				"data":       map[string][]byte{FileConfig: config},
+			},
+			map[string]interface{}{
+				"apiVersion": "batch/v1",
+				"kind":       "Job",
+				"metadata":   meta,
+				"spec": map[string]interface{}{
+					// Restarts are requested explicitly.
+					"backoffLimit": 0,
+					"template": map[string]interface{}{
+						"metadata": map[string]interface{}{"labels": labels},
+						"spec":     pod,
+					},
+				},
+			},
+		},
+	})
+}
+
+// startJob creates the Secret holding the configuration of "p" and the Job
+// running its wrapper. Restarted sessions replace the resources of their
+// previous run, as Jobs cannot be updated.
+func (p *PWrap) startJob() error {
+	config, err := os.ReadFile(p.Path(FileConfig))
+	if err != nil {
+		return fmt.Errorf("unable to read configuration: %w", err)
+	}
+	manifest, err := p.jobManifest(config)
+	if err != nil {
+		return fmt.Errorf("unable to build job manifest: %w", err)
+	}
+	if p.restarts > 0 {
+		return kube.Replace(manifest)
+	}
+	return kube.Apply(manifest)
+}
+
+// jobRunning reports whether the Job running "p" is still active. Errors
+// other than a missing Job are logged, and the Job considered running.
+func (p *PWrap) jobRunning(k *Kubernetes) bool {
+	status, err := kube.GetJob(k.Namespace, p.sid)
+	if errors.Is(err, kube.ErrNotFound) {
+		return false
+	}
+	if err != nil {
+		log.Printf("[WARN] %v", err)
+		return true
+	}
+	return !status.Finished()
+}
+
+// Refresh updates the session state of "p" with the status of the Job it runs
+// as, or with the state recorded by its wrapper on the remote host it is placed
+// on. It does nothing for the other sessions, as their wrapper keeps their state
+// up to date.
+func (p *PWrap) Refresh() error {
+	if k := p.job(); k != nil {
+		return p.refreshJob(k)
+	}
+	if r := p.placement(); r != nil {
+		return p.refreshRemote(r)
+	}
+	return nil
+}
+
+// refreshJob updates the session state of "p" with the status of Job "k".
+func (p *PWrap) refreshJob(k *Kubernetes) error {
+	status, err := kube.GetJob(k.Namespace, p.sid)
+	if errors.Is(err, kube.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return p.UpdateSession(func(s *Session) {
+		recordJob(s, status)
+	})
+}
+
+// recordJob applies the Job "status" to session "s".
+func recordJob(s *Session, status *kube.JobStatus) {
+	if status.StartTime != nil {
+		s.StartedAt = status.StartTime
+	}
+	switch {
+	case status.Succeeded > 0:
+		code := 0
+		s.State, s.ExitCode = SessionExited, &code
+		s.FinishedAt = status.CompletionTime
+	case status.Failed > 0 || status.Finished():
+		s.State = SessionFailed
+		s.Error = status.FailureMessage()
+		if s.FinishedAt == nil {
+			now := time.Now()
+			s.FinishedAt = &now
+		}
+	case status.Active > 0:
+		s.State = SessionRunning
+	}
+}
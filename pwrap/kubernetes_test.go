@@ -0,0 +1,95 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kim-company/pmux/kube"
+)
+
+func TestPWrap_JobManifest(t *testing.T) {
+	t.Parallel()
+
+	p := &PWrap{
+		rootDir:   "/srv",
+		sid:       "pmux-test",
+		name:      "job",
+		args:      []string{"-v"},
+		grace:     DefaultGracePeriod,
+		transport: TransportUnix,
+		kube:      &Kubernetes{Namespace: "batch", Image: "registry/job:1", Memory: "1Gi"},
+	}
+	data, err := p.jobManifest([]byte("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list struct {
+		Items []struct {
+			Kind     string `json:"kind"`
+			Metadata struct {
+				Name      string            `json:"name"`
+				Namespace string            `json:"namespace"`
+				Labels    map[string]string `json:"labels"`
+			} `json:"metadata"`
+			Data map[string]string `json:"data"`
+			Spec struct {
+				Template struct {
+					Spec struct {
+						Containers []struct {
+							Image     string   `json:"image"`
+							Command   []string `json:"command"`
+							Args      []string `json:"args"`
+							Resources struct {
+								Limits map[string]string `json:"limits"`
+							} `json:"resources"`
+						} `json:"containers"`
+					} `json:"spec"`
+				} `json:"template"`
+			} `json:"spec"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 2 || list.Items[0].Kind != "Secret" || list.Items[1].Kind != "Job" {
+		t.Fatalf("unexpected manifest: %s", data)
+	}
+	for _, v := range list.Items {
+		if v.Metadata.Name != "pmux-test" || v.Metadata.Namespace != "batch" || v.Metadata.Labels[kube.LabelSID] != "pmux-test" {
+			t.Fatalf("unexpected metadata: %+v", v.Metadata)
+		}
+	}
+	if list.Items[0].Data[FileConfig] != "e30=" {
+		t.Fatalf("unexpected secret data: %v", list.Items[0].Data)
+	}
+	c := list.Items[1].Spec.Template.Spec.Containers[0]
+	args := strings.Join(c.Args, " ")
+	if c.Image != "registry/job:1" || c.Command[0] != "pmux" || c.Resources.Limits["memory"] != "1Gi" {
+		t.Fatalf("unexpected container: %+v", c)
+	}
+	if !strings.Contains(args, "--root="+KubeRoot+" ") || !strings.HasSuffix(args, "-- job -v") {
+		t.Fatalf("unexpected wrapper arguments: %q", args)
+	}
+}
+
+func TestRecordJob(t *testing.T) {
+	t.Parallel()
+
+	start := time.Now().Add(-time.Minute)
+	end := time.Now()
+	s := &Session{State: SessionCreated}
+	recordJob(s, &kube.JobStatus{Active: 1, StartTime: &start})
+	if s.State != SessionRunning || s.StartedAt == nil {
+		t.Fatalf("unexpected session: %+v", s)
+	}
+	recordJob(s, &kube.JobStatus{Succeeded: 1, StartTime: &start, CompletionTime: &end})
+	if s.State != SessionExited || s.ExitCode == nil || *s.ExitCode != 0 || s.FinishedAt == nil {
+		t.Fatalf("unexpected session: %+v", s)
+	}
+}
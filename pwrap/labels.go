@@ -0,0 +1,46 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidateLabels reports whether "labels" can be attached to a session and used
+// to select it, i.e. whether every key is not empty and does not contain "=".
+func ValidateLabels(labels map[string]string) error {
+	for k := range labels {
+		if k == "" || strings.Contains(k, "=") {
+			return fmt.Errorf("invalid label key %q", k)
+		}
+	}
+	return nil
+}
+
+// ParseLabels parses labels in the key=value form.
+func ParseLabels(kvs []string) (map[string]string, error) {
+	labels := make(map[string]string, len(kvs))
+	for _, v := range kvs {
+		kv := strings.SplitN(v, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid label %q, expected key=value", v)
+		}
+		labels[kv[0]] = kv[1]
+	}
+	return labels, nil
+}
+
+// Labels attaches the arbitrary key/value pairs "labels" to the session, e.g.
+// to tell apart the sessions of different products.
+func Labels(labels map[string]string) func(*PWrap) error {
+	return func(p *PWrap) error {
+		if err := ValidateLabels(labels); err != nil {
+			return err
+		}
+		p.labels = labels
+		return nil
+	}
+}
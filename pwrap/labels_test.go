@@ -0,0 +1,50 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"os"
+	"testing"
+)
+
+func TestParseLabels(t *testing.T) {
+	t.Parallel()
+
+	labels, err := ParseLabels([]string{"team=video", "query=a=b", "empty="})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(labels) != 3 || labels["team"] != "video" || labels["query"] != "a=b" || labels["empty"] != "" {
+		t.Fatalf("Unexpected labels: %v", labels)
+	}
+	for _, v := range []string{"team", "=video"} {
+		if _, err := ParseLabels([]string{v}); err == nil {
+			t.Fatalf("%q: expected an error", v)
+		}
+	}
+}
+
+func TestLabels(t *testing.T) {
+	t.Parallel()
+
+	if _, err := New(Labels(map[string]string{"": "video"})); err == nil {
+		t.Fatal("Empty label keys SHOULD NOT be accepted")
+	}
+	pw, err := New(Exec("yes"), RootDir(os.TempDir()), Labels(map[string]string{"team": "video"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(pw.WorkDir())
+	if err := pw.UpdateSession(func(*Session) {}); err != nil {
+		t.Fatal(err)
+	}
+	s, err := pw.ReadSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Labels["team"] != "video" {
+		t.Fatalf("Unexpected session labels: %v", s.Labels)
+	}
+}
@@ -0,0 +1,42 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidateName reports whether "name" can be used as the alias of a session:
+// up to 63 lowercase letters, digits and dashes, starting with a letter. Names
+// starting with "pmux-" are reserved to session identifiers.
+func ValidateName(name string) error {
+	if name == "" || len(name) > 63 || name[0] < 'a' || name[0] > 'z' || name[len(name)-1] == '-' {
+		return fmt.Errorf("invalid session name %q", name)
+	}
+	for _, c := range name {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+			return fmt.Errorf("invalid session name %q", name)
+		}
+	}
+	if strings.HasPrefix(name, "pmux-") {
+		return fmt.Errorf("session name %q is reserved", name)
+	}
+	return nil
+}
+
+// Name sets "name" as the alias of the session, which identifies it within its
+// namespace alongside its SID. Sessions have no alias if empty.
+func Name(name string) func(*PWrap) error {
+	return func(p *PWrap) error {
+		if name != "" {
+			if err := ValidateName(name); err != nil {
+				return err
+			}
+		}
+		p.alias = name
+		return nil
+	}
+}
@@ -0,0 +1,46 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestValidateName(t *testing.T) {
+	t.Parallel()
+
+	for _, v := range []string{"a", "nightly-encode", "job2"} {
+		if err := ValidateName(v); err != nil {
+			t.Fatalf("Name %q SHOULD be accepted: %v", v, err)
+		}
+	}
+	for _, v := range []string{"", "Nightly", "2job", "job-", "job_1", "a/b", "pmux-job", strings.Repeat("a", 64)} {
+		if err := ValidateName(v); err == nil {
+			t.Fatalf("Name %q SHOULD be rejected", v)
+		}
+	}
+}
+
+func TestName(t *testing.T) {
+	t.Parallel()
+
+	pw, err := New(RootDir(os.TempDir()), Name("nightly"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(pw.WorkDir())
+	if err := pw.UpdateSession(func(*Session) {}); err != nil {
+		t.Fatal(err)
+	}
+	s, err := pw.ReadSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Name != "nightly" {
+		t.Fatalf("Name SHOULD be recorded in the session, found %q", s.Name)
+	}
+}
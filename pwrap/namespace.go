@@ -0,0 +1,50 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"fmt"
+)
+
+// DefaultNamespace is the namespace of the sessions that do not select one.
+const DefaultNamespace = "default"
+
+// ValidateNamespace reports whether "ns" is a valid namespace name: at most 63
+// lowercase letters, digits and dashes, starting and ending with a letter or a
+// digit.
+func ValidateNamespace(ns string) error {
+	if ns == "" || len(ns) > 63 || ns[0] == '-' || ns[len(ns)-1] == '-' {
+		return fmt.Errorf("invalid namespace %q", ns)
+	}
+	for _, c := range ns {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+			return fmt.Errorf("invalid namespace %q", ns)
+		}
+	}
+	return nil
+}
+
+// Namespace places the session in namespace "ns", the tenant it belongs to.
+// Sessions are placed in "DefaultNamespace" if empty.
+func Namespace(ns string) func(*PWrap) error {
+	return func(p *PWrap) error {
+		if ns != "" {
+			if err := ValidateNamespace(ns); err != nil {
+				return err
+			}
+		}
+		p.namespace = ns
+		return nil
+	}
+}
+
+// InNamespace returns the namespace of the session, "DefaultNamespace" for
+// sessions that did not select one.
+func (s *Session) InNamespace() string {
+	if s.Namespace == "" {
+		return DefaultNamespace
+	}
+	return s.Namespace
+}
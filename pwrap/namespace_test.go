@@ -0,0 +1,25 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateNamespace(t *testing.T) {
+	t.Parallel()
+
+	for _, v := range []string{"default", "video", "team-42", "a", strings.Repeat("a", 63)} {
+		if err := ValidateNamespace(v); err != nil {
+			t.Fatalf("%q: %v", v, err)
+		}
+	}
+	for _, v := range []string{"", "Video", "-video", "video-", "vi_deo", "vi/deo", strings.Repeat("a", 64)} {
+		if err := ValidateNamespace(v); err == nil {
+			t.Fatalf("%q: expected an error", v)
+		}
+	}
+}
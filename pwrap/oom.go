@@ -0,0 +1,81 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"bufio"
+	"bytes"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// cgroupRoot is where the cgroup hierarchies are mounted.
+const cgroupRoot = "/sys/fs/cgroup"
+
+// oomCounters returns the candidate paths of the file counting the OOM kills
+// happened in the memory cgroup described by "cgroup", the content of
+// /proc/self/cgroup, together with the key of the counter. The memory controller
+// of cgroup v1 is preferred over the unified hierarchy of v2. The root of the
+// hierarchy is a candidate too, as it is the cgroup itself when mounted from a
+// cgroup namespace.
+func oomCounters(cgroup []byte) ([]string, string) {
+	var unified string
+	s := bufio.NewScanner(bytes.NewReader(cgroup))
+	for s.Scan() {
+		fields := strings.SplitN(s.Text(), ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		if fields[0] == "0" && fields[1] == "" {
+			unified = fields[2]
+			continue
+		}
+		for _, v := range strings.Split(fields[1], ",") {
+			if v == "memory" {
+				return []string{
+					path.Join(cgroupRoot, "memory", fields[2], "memory.oom_control"),
+					path.Join(cgroupRoot, "memory", "memory.oom_control"),
+				}, "oom_kill"
+			}
+		}
+	}
+	if unified == "" {
+		return nil, ""
+	}
+	return []string{
+		path.Join(cgroupRoot, unified, "memory.events"),
+		path.Join(cgroupRoot, "memory.events"),
+	}, "oom_kill"
+}
+
+// parseCounter returns the value of "key" in "data", made of "key value" lines
+// like cgroup event files.
+func parseCounter(data []byte, key string) (uint64, bool) {
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 2 && fields[0] == key {
+			n, err := strconv.ParseUint(fields[1], 10, 64)
+			return n, err == nil
+		}
+	}
+	return 0, false
+}
+
+// kmsgOOM reports whether the kernel log record "rec" says that process "pid"
+// was killed by the OOM killer, either the global one or the one of a memory
+// cgroup.
+func kmsgOOM(rec string, pid int) bool {
+	// Records are prefixed by their metadata, e.g. "3,1234,5678,-;".
+	if i := strings.IndexByte(rec, ';'); i >= 0 {
+		rec = rec[i+1:]
+	}
+	p := strconv.Itoa(pid)
+	if strings.Contains(rec, "Killed process "+p+" ") {
+		return true
+	}
+	return strings.HasPrefix(rec, "oom-kill:") && strings.Contains(rec+",", ",pid="+p+",")
+}
@@ -0,0 +1,88 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// oomProbe detects whether a process was killed by the OOM killer, looking for
+// its kill in the kernel log and comparing the OOM kills counted by the memory
+// cgroup of the wrapper, which the child shares, with those counted before it
+// started.
+type oomProbe struct {
+	counter, key string
+	kills        uint64
+}
+
+// newOOMProbe returns a probe counting the OOM kills happened from now on.
+func newOOMProbe() *oomProbe {
+	o := &oomProbe{}
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return o
+	}
+	paths, key := oomCounters(data)
+	for _, v := range paths {
+		if n, ok := readCounter(v, key); ok {
+			o.counter, o.key, o.kills = v, key, n
+			break
+		}
+	}
+	return o
+}
+
+// killed reports whether process "pid" was likely killed by the OOM killer:
+// either the kernel log says so or, if it cannot be read, e.g. because the
+// wrapper lacks the privileges, a kill happened in the memory cgroup since the
+// probe was created.
+func (o *oomProbe) killed(pid int) bool {
+	if found, err := scanKmsg(pid); err == nil && found {
+		return true
+	}
+	if o.counter == "" {
+		return false
+	}
+	n, ok := readCounter(o.counter, o.key)
+	return ok && n > o.kills
+}
+
+func readCounter(path, key string) (uint64, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+	return parseCounter(data, key)
+}
+
+// scanKmsg looks for the OOM kill of process "pid" in the records of the kernel
+// log buffer.
+func scanKmsg(pid int) (bool, error) {
+	fd, err := syscall.Open("/dev/kmsg", syscall.O_RDONLY|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return false, err
+	}
+	defer syscall.Close(fd)
+	// Every read returns a single record.
+	buf := make([]byte, 8192)
+	for {
+		n, err := syscall.Read(fd, buf)
+		if errors.Is(err, syscall.EAGAIN) {
+			return false, nil
+		}
+		if errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.EINTR) {
+			// Records were overwritten while reading.
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		if n > 0 && kmsgOOM(string(buf[:n]), pid) {
+			return true, nil
+		}
+	}
+}
@@ -0,0 +1,25 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"os/exec"
+	"testing"
+)
+
+func TestOOMProbe(t *testing.T) {
+	t.Parallel()
+
+	o := newOOMProbe()
+	cmd := exec.Command("sleep", "60")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	cmd.Process.Kill()
+	cmd.Wait()
+	if o.killed(cmd.Process.Pid) {
+		t.Fatalf("Processes killed with SIGKILL SHOULD NOT be reported as killed by the OOM killer")
+	}
+}
@@ -0,0 +1,19 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+//go:build !linux
+
+package pwrap
+
+// oomProbe detects whether a process was killed by the OOM killer, which is only
+// supported on Linux.
+type oomProbe struct{}
+
+func newOOMProbe() *oomProbe {
+	return &oomProbe{}
+}
+
+func (o *oomProbe) killed(pid int) bool {
+	return false
+}
@@ -0,0 +1,60 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOOMCounters(t *testing.T) {
+	t.Parallel()
+
+	for i, tt := range []struct {
+		cgroup string
+		want   []string
+	}{
+		{"0::/system.slice/pmux.service\n", []string{"/sys/fs/cgroup/system.slice/pmux.service/memory.events", "/sys/fs/cgroup/memory.events"}},
+		{"5:cpu,cpuacct:/\n4:memory:/docker/abc\n0::/\n", []string{"/sys/fs/cgroup/memory/docker/abc/memory.oom_control", "/sys/fs/cgroup/memory/memory.oom_control"}},
+		{"1:name=systemd:/\n", nil},
+	} {
+		paths, _ := oomCounters([]byte(tt.cgroup))
+		if !reflect.DeepEqual(paths, tt.want) {
+			t.Fatalf("%d: counters SHOULD be %v, found %v", i, tt.want, paths)
+		}
+	}
+}
+
+func TestParseCounter(t *testing.T) {
+	t.Parallel()
+
+	data := []byte("low 0\nhigh 0\nmax 3\noom 2\noom_kill 1\n")
+	if n, ok := parseCounter(data, "oom_kill"); !ok || n != 1 {
+		t.Fatalf("Unexpected oom_kill counter %d, %t", n, ok)
+	}
+	if _, ok := parseCounter(data, "oom_group_kill"); ok {
+		t.Fatalf("Missing counters SHOULD NOT be found")
+	}
+}
+
+func TestKmsgOOM(t *testing.T) {
+	t.Parallel()
+
+	for i, tt := range []struct {
+		rec string
+		ok  bool
+	}{
+		{"3,1234,5678,-;Out of memory: Killed process 4242 (mockcmd) total-vm:1024kB, anon-rss:512kB", true},
+		{"3,1234,5678,-;Memory cgroup out of memory: Killed process 4242 (mockcmd) total-vm:1024kB", true},
+		{"6,1234,5678,-;oom-kill:constraint=CONSTRAINT_MEMCG,task_memcg=/pmux,task=mockcmd,pid=4242,uid=0", true},
+		{"3,1234,5678,-;Out of memory: Killed process 42424 (mockcmd) total-vm:1024kB", false},
+		{"6,1234,5678,-;oom-kill:constraint=CONSTRAINT_NONE,task=mockcmd,pid=42421,uid=0", false},
+		{"6,1234,5678,-;mockcmd[4242]: segfault at 0 ip 0000000000401000", false},
+	} {
+		if ok := kmsgOOM(tt.rec, 4242); ok != tt.ok {
+			t.Fatalf("%d: OOM kill of %q SHOULD be %t", i, tt.rec, tt.ok)
+		}
+	}
+}
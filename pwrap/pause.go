@@ -0,0 +1,53 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+)
+
+// suspend stops the child "pid" and its descendants with SIGSTOP, or continues
+// them with SIGCONT if "resume" is set. Children running in containers are
+// paused with "docker pause" instead, as the docker client is not the child.
+func (p *PWrap) suspend(pid int, resume bool) error {
+	if p.container != nil {
+		verb := "pause"
+		if resume {
+			verb = "unpause"
+		}
+		out, err := exec.Command(DockerCommand, verb, p.containerName()).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("unable to %s container: %w: %s", verb, err, bytes.TrimSpace(out))
+		}
+		return nil
+	}
+	table, err := processTable()
+	if err != nil {
+		return err
+	}
+	children := make(map[int][]process, len(table))
+	for _, v := range table {
+		children[v.ppid] = append(children[v.ppid], v)
+	}
+	return signalTree(descendants(children, pid), resume)
+}
+
+// descendants returns "pid" followed by its descendants in the tree described by
+// "children", parents before their children.
+func descendants(children map[int][]process, pid int) []int {
+	acc := []int{pid}
+	seen := map[int]bool{pid: true}
+	for i := 0; i < len(acc); i++ {
+		for _, v := range children[acc[i]] {
+			if !seen[v.pid] {
+				seen[v.pid] = true
+				acc = append(acc, v.pid)
+			}
+		}
+	}
+	return acc
+}
@@ -0,0 +1,80 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// stopped reports whether process "pid" is stopped according to /proc, waiting
+// for a while for it to become "want", as signals are delivered asynchronously.
+func stopped(t *testing.T, pid int, want bool) bool {
+	for i := 0; i < 100; i++ {
+		data, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+		if err != nil {
+			t.Fatal(err)
+		}
+		state := bytes.Fields(data[bytes.LastIndexByte(data, ')')+1:])[0][0]
+		if (state == 'T') == want {
+			return want
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return !want
+}
+
+func TestPWrap_Suspend(t *testing.T) {
+	t.Parallel()
+
+	cmd := exec.Command("sh", "-c", "sleep 10 & wait")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+	// Wait for the shell to start its own child.
+	var pids []int
+	for i := 0; i < 100 && len(pids) < 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+		table, err := processTable()
+		if err != nil {
+			t.Fatal(err)
+		}
+		children := make(map[int][]process)
+		for _, v := range table {
+			children[v.ppid] = append(children[v.ppid], v)
+		}
+		pids = descendants(children, cmd.Process.Pid)
+	}
+	if len(pids) < 2 {
+		t.Fatalf("The child did not start its descendant: %v", pids)
+	}
+
+	p := &PWrap{}
+	if err := p.suspend(cmd.Process.Pid, false); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range pids {
+		if !stopped(t, v, true) {
+			t.Fatalf("Process %d SHOULD be stopped", v)
+		}
+	}
+	if err := p.suspend(cmd.Process.Pid, true); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range pids {
+		if stopped(t, v, false) {
+			t.Fatalf("Process %d SHOULD be continued", v)
+		}
+	}
+	exec.Command("kill", strconv.Itoa(pids[1])).Run()
+}
@@ -0,0 +1,29 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+//go:build !windows
+
+package pwrap
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+)
+
+// signalTree stops the processes "pids" with SIGSTOP, or continues them with
+// SIGCONT if "resume" is set, in order. Descendants that exited in the meantime
+// are skipped.
+func signalTree(pids []int, resume bool) error {
+	sig := syscall.SIGSTOP
+	if resume {
+		sig = syscall.SIGCONT
+	}
+	for i, pid := range pids {
+		if err := syscall.Kill(pid, sig); err != nil && (i == 0 || !errors.Is(err, syscall.ESRCH)) {
+			return fmt.Errorf("unable to signal process %d: %w", pid, err)
+		}
+	}
+	return nil
+}
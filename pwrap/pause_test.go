@@ -0,0 +1,111 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/kim-company/pmux/http/pwrapapi"
+)
+
+func TestDescendants(t *testing.T) {
+	t.Parallel()
+
+	children := map[int][]process{
+		1: {{pid: 2}, {pid: 3}},
+		2: {{pid: 4}},
+		4: {{pid: 5}},
+		9: {{pid: 10}},
+	}
+	if found := descendants(children, 2); !reflect.DeepEqual(found, []int{2, 4, 5}) {
+		t.Fatalf("Unexpected descendants %v", found)
+	}
+	if found := descendants(children, 1); !reflect.DeepEqual(found, []int{1, 2, 3, 4, 5}) {
+		t.Fatalf("Parents SHOULD precede their children, found %v", found)
+	}
+}
+
+func TestChildState_Pause(t *testing.T) {
+	t.Parallel()
+
+	var calls []bool
+	c := newChildState(0)
+	c.suspend = func(pid int, resume bool) error {
+		calls = append(calls, resume)
+		return nil
+	}
+	if err := c.pause(pwrapapi.Bridge{}, pwrapapi.PauseSignal, false); !errors.Is(err, pwrapapi.ErrPauseState) {
+		t.Fatalf("Children that did not start SHOULD NOT be paused: %v", err)
+	}
+	c.started(&os.Process{Pid: 42})
+	if err := c.pause(pwrapapi.Bridge{}, pwrapapi.PauseSignal, true); !errors.Is(err, pwrapapi.ErrPauseState) {
+		t.Fatalf("Running children SHOULD NOT be resumed: %v", err)
+	}
+	if err := c.pause(pwrapapi.Bridge{}, pwrapapi.PauseSignal, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.pause(pwrapapi.Bridge{}, pwrapapi.PauseSignal, false); !errors.Is(err, pwrapapi.ErrPauseState) {
+		t.Fatalf("Paused children SHOULD NOT be paused again: %v", err)
+	}
+	var s Session
+	c.record(&s)
+	if s.State != SessionPaused || s.PausedAt == nil || c.Status().PausedAt == nil {
+		t.Fatalf("Paused children SHOULD be reported, found state %q", s.State)
+	}
+
+	// Termination resumes stopped children first.
+	c.thaw()
+	c.record(&s)
+	if s.State != SessionRunning || s.PausedAt != nil {
+		t.Fatalf("Resumed children SHOULD be reported as running, found state %q", s.State)
+	}
+	if !reflect.DeepEqual(calls, []bool{false, true}) {
+		t.Fatalf("Unexpected suspensions %v", calls)
+	}
+}
+
+func TestChildState_Reload(t *testing.T) {
+	t.Parallel()
+
+	var ops []string
+	b, close := newTestBridge(t, OnCommandResponse(func(u CommBridge, cmd string) (string, error) {
+		c, err := ParseCommand(cmd)
+		if err != nil {
+			return "", err
+		}
+		ops = append(ops, c.Op)
+		return "reloaded", nil
+	}))
+	defer close()
+	br := pwrapapi.Bridge{Dial: pwrapapi.NewDialer(TransportUnix, b.path)}
+
+	writes := 0
+	write := func() error {
+		writes++
+		return nil
+	}
+	c := newChildState(0)
+	c.suspend = func(int, bool) error { return nil }
+	if err := c.reload(br, write); !errors.Is(err, pwrapapi.ErrReloadState) {
+		t.Fatalf("Children that did not start SHOULD NOT be reloaded: %v", err)
+	}
+	c.started(&os.Process{Pid: 42})
+	if err := c.pause(br, pwrapapi.PauseSignal, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.reload(br, write); !errors.Is(err, pwrapapi.ErrReloadState) {
+		t.Fatalf("Suspended children SHOULD NOT be reloaded: %v", err)
+	}
+	c.thaw()
+	if err := c.reload(br, write); err != nil {
+		t.Fatal(err)
+	}
+	if writes != 1 || !reflect.DeepEqual(ops, []string{CommandReload}) {
+		t.Fatalf("The configuration SHOULD be written once and reloaded, found %d writes and operations %q", writes, ops)
+	}
+}
@@ -0,0 +1,13 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import "errors"
+
+// signalTree is not supported by Windows, whose children can only be paused
+// through the pause command.
+func signalTree(pids []int, resume bool) error {
+	return errors.New("processes cannot be stopped on Windows, pause the child with a command instead")
+}
@@ -0,0 +1,40 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"io"
+	"log"
+	"os"
+	"strconv"
+)
+
+// FileWrapperPID contains the PID of the running wrapper, and FileChildPID the
+// one of its running child, for external monitoring agents. They are written by
+// the wrapper when the processes start and removed once they exit, but may be
+// left behind if the wrapper is killed. Unlike "FilePID", they are written
+// whatever the way the wrapper was started.
+const (
+	FileWrapperPID = "wrapper.pid"
+	FileChildPID   = "child.pid"
+)
+
+// writePID stores "pid" in file "rel" of the working directory. The file is
+// replaced atomically, readers never see it partially written.
+func (p *PWrap) writePID(rel string, pid int) {
+	if err := p.replaceFile(rel, func(w io.Writer) error {
+		_, err := io.WriteString(w, strconv.Itoa(pid)+"\n")
+		return err
+	}); err != nil {
+		log.Printf("[WARN] unable to write %s file: %v", rel, err)
+	}
+}
+
+// clearPID removes file "rel" of the working directory.
+func (p *PWrap) clearPID(rel string) {
+	if err := os.Remove(p.Path(rel)); err != nil && !os.IsNotExist(err) {
+		log.Printf("[WARN] unable to remove %s file: %v", rel, err)
+	}
+}
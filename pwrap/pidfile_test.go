@@ -0,0 +1,41 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"os"
+	"testing"
+)
+
+func TestPIDFiles(t *testing.T) {
+	t.Parallel()
+
+	pw, err := New(RootDir(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pw.writePID(FileWrapperPID, os.Getpid())
+	pw.writePID(FileChildPID, 42)
+	if pid := readPID(pw.Path(FileWrapperPID)); pid != os.Getpid() {
+		t.Fatalf("Wrapper pid SHOULD be %d, found %d", os.Getpid(), pid)
+	}
+	if pid := readPID(pw.Path(FileChildPID)); pid != 42 {
+		t.Fatalf("Child pid SHOULD be 42, found %d", pid)
+	}
+
+	pw.clearPID(FileChildPID)
+	pw.clearPID(FileChildPID)
+	if _, err := os.Stat(pw.Path(FileChildPID)); !os.IsNotExist(err) {
+		t.Fatalf("Child pid file SHOULD be removed: %v", err)
+	}
+
+	// Left behind files do not keep the working directory from being trashed.
+	if err := pw.trashFiles(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(pw.WorkDir()); !os.IsNotExist(err) {
+		t.Fatalf("Working directory SHOULD be removed: %v", err)
+	}
+}
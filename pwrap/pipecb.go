@@ -0,0 +1,34 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"context"
+	"fmt"
+)
+
+// PipeCommBridge is a communication bridge listening on a Windows named pipe.
+// Named pipes are only supported on Windows.
+type PipeCommBridge struct {
+	*bridge
+}
+
+// PipeName returns the named pipe path associated to "name".
+func PipeName(name string) string {
+	return `\\.\pipe\` + name
+}
+
+// NewPipeCommBridge starts a named pipe listener on "path", which is expected to be
+// in the form returned by "PipeName".
+// Is is the caller's responsibility to close the listener when it's done.
+func NewPipeCommBridge(ctx context.Context, path string, opts ...CommBridgeOption) (*PipeCommBridge, error) {
+	l, err := listenPipe(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to listen on %v: %w", path, err)
+	}
+	p := &PipeCommBridge{}
+	p.bridge = newBridge(l, p, opts...)
+	return p, nil
+}
@@ -0,0 +1,16 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+//go:build !windows
+
+package pwrap
+
+import (
+	"errors"
+	"net"
+)
+
+func listenPipe(path string) (net.Listener, error) {
+	return nil, errors.New("named pipes are only supported on windows")
+}
@@ -0,0 +1,140 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+//go:build windows
+
+package pwrap
+
+import (
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+var (
+	modkernel32          = syscall.NewLazyDLL("kernel32.dll")
+	procCreateNamedPipeW = modkernel32.NewProc("CreateNamedPipeW")
+	procConnectNamedPipe = modkernel32.NewProc("ConnectNamedPipe")
+)
+
+const (
+	pipeAccessDuplex       = 0x3
+	pipeTypeByte           = 0x0
+	pipeWait               = 0x0
+	pipeUnlimitedInstances = 255
+	pipeBufferSize         = 4096
+
+	errorPipeConnected syscall.Errno = 535
+)
+
+type pipeAddr string
+
+func (a pipeAddr) Network() string { return "pipe" }
+func (a pipeAddr) String() string  { return string(a) }
+
+// pipeListener is a minimal named pipe listener. Each call to Accept creates a
+// new pipe instance and blocks until a client connects to it.
+type pipeListener struct {
+	path string
+
+	mu     sync.Mutex
+	closed bool
+}
+
+func listenPipe(path string) (net.Listener, error) {
+	// Create and release a first instance, making sure that the pipe name
+	// is valid and not already taken by someone else.
+	h, err := createPipe(path)
+	if err != nil {
+		return nil, err
+	}
+	syscall.CloseHandle(h)
+	return &pipeListener{path: path}, nil
+}
+
+func createPipe(path string) (syscall.Handle, error) {
+	name, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return syscall.InvalidHandle, err
+	}
+	r, _, err := procCreateNamedPipeW.Call(
+		uintptr(unsafe.Pointer(name)),
+		pipeAccessDuplex,
+		pipeTypeByte|pipeWait,
+		pipeUnlimitedInstances,
+		pipeBufferSize,
+		pipeBufferSize,
+		0,
+		0,
+	)
+	h := syscall.Handle(r)
+	if h == syscall.InvalidHandle {
+		return h, err
+	}
+	return h, nil
+}
+
+func (l *pipeListener) isClosed() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.closed
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	if l.isClosed() {
+		return nil, net.ErrClosed
+	}
+	h, err := createPipe(l.path)
+	if err != nil {
+		return nil, err
+	}
+	r, _, err := procConnectNamedPipe.Call(uintptr(h), 0)
+	if r == 0 && err != errorPipeConnected {
+		syscall.CloseHandle(h)
+		return nil, err
+	}
+	if l.isClosed() {
+		// We were woken up by Close.
+		syscall.CloseHandle(h)
+		return nil, net.ErrClosed
+	}
+	return &pipeConn{File: os.NewFile(uintptr(h), l.path), addr: pipeAddr(l.path)}, nil
+}
+
+// Close closes the listener. A pending Accept is unblocked connecting to the
+// pipe instance it is waiting on.
+func (l *pipeListener) Close() error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil
+	}
+	l.closed = true
+	l.mu.Unlock()
+
+	if f, err := os.OpenFile(l.path, os.O_RDWR, 0); err == nil {
+		f.Close()
+	}
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr {
+	return pipeAddr(l.path)
+}
+
+// pipeConn is a connected pipe instance. Pipe instances are opened in synchronous
+// mode, hence deadlines are not supported.
+type pipeConn struct {
+	*os.File
+	addr pipeAddr
+}
+
+func (c *pipeConn) LocalAddr() net.Addr                { return c.addr }
+func (c *pipeConn) RemoteAddr() net.Addr               { return c.addr }
+func (c *pipeConn) SetDeadline(t time.Time) error      { return nil }
+func (c *pipeConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *pipeConn) SetWriteDeadline(t time.Time) error { return nil }
@@ -0,0 +1,75 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/phayes/freeport"
+)
+
+// PortRange is an inclusive range of TCP ports.
+type PortRange struct {
+	First, Last int
+}
+
+func (r PortRange) String() string {
+	return fmt.Sprintf("%d-%d", r.First, r.Last)
+}
+
+// ParsePortRange parses a port range in the "first-last" form, e.g.
+// "42000-43000".
+func ParsePortRange(s string) (*PortRange, error) {
+	kv := strings.SplitN(s, "-", 2)
+	if len(kv) != 2 {
+		return nil, fmt.Errorf("invalid port range %q, expected first-last", s)
+	}
+	first, err1 := strconv.Atoi(strings.TrimSpace(kv[0]))
+	last, err2 := strconv.Atoi(strings.TrimSpace(kv[1]))
+	if err1 != nil || err2 != nil || first < 1 || last > 65535 || first > last {
+		return nil, fmt.Errorf("invalid port range %q, expected first-last with 1 <= first <= last <= 65535", s)
+	}
+	return &PortRange{First: first, Last: last}, nil
+}
+
+// APIPorts makes the wrapper API listen on a port of range "r", rather than on a
+// random free port, so that firewalls can be configured narrowly. A nil range
+// selects a random port.
+func APIPorts(r *PortRange) func(*PWrap) error {
+	return func(p *PWrap) error {
+		p.apiPorts = r
+		return nil
+	}
+}
+
+// apiPort returns a port the wrapper API can listen on.
+func (p *PWrap) apiPort() (int, error) {
+	if p.apiPorts == nil {
+		return freeport.GetFreePort()
+	}
+	return freePortIn(*p.apiPorts)
+}
+
+// freePortIn returns a port of range "r" that is free at the moment. Ports are
+// tried starting from a random one, so that wrappers starting at the same time do
+// not all compete for the first port.
+func freePortIn(r PortRange) (int, error) {
+	n := r.Last - r.First + 1
+	start := rand.Intn(n)
+	for i := 0; i < n; i++ {
+		port := r.First + (start+i)%n
+		l, err := net.Listen("tcp", ":"+strconv.Itoa(port))
+		if err != nil {
+			continue
+		}
+		l.Close()
+		return port, nil
+	}
+	return 0, fmt.Errorf("no free port in range %v", r)
+}
@@ -0,0 +1,63 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"net"
+	"testing"
+)
+
+func TestParsePortRange(t *testing.T) {
+	t.Parallel()
+
+	r, err := ParsePortRange("42000-43000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.First != 42000 || r.Last != 43000 || r.String() != "42000-43000" {
+		t.Fatalf("Unexpected port range %+v", r)
+	}
+	for _, v := range []string{"", "42000", "43000-42000", "0-10", "42000-70000", "a-b"} {
+		if _, err := ParsePortRange(v); err == nil {
+			t.Fatalf("Parsing %q SHOULD fail", v)
+		}
+	}
+
+	pw, err := New(RootDir(t.TempDir()), Exec("yes"), APIPorts(r))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !contains(pw.wrapArgs(pw.rootDir), "--port-range=42000-43000") {
+		t.Fatalf("Wrapper arguments SHOULD contain the port range")
+	}
+}
+
+func TestFreePortIn(t *testing.T) {
+	t.Parallel()
+
+	l, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	busy := l.Addr().(*net.TCPAddr).Port
+
+	if _, err := freePortIn(PortRange{First: busy, Last: busy}); err == nil {
+		t.Fatalf("Busy port %d SHOULD NOT be returned", busy)
+	}
+	r := PortRange{First: busy, Last: busy + 1}
+	if busy == 65535 {
+		r = PortRange{First: busy - 1, Last: busy}
+	}
+	port, err := freePortIn(r)
+	if err != nil {
+		// The other port may be busy too.
+		t.Skip(err)
+	}
+	if port == busy || port < r.First || port > r.Last {
+		t.Fatalf("Port %d SHOULD be the free one of range %v", port, r)
+	}
+
+}
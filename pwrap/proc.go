@@ -0,0 +1,90 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/kim-company/pmux/tmux"
+)
+
+// ErrNoChild is returned by "PWrap.ChildPID" when the wrapper is not running a
+// child.
+var ErrNoChild = errors.New("child process not running")
+
+// process is an entry of the process table.
+type process struct {
+	pid, ppid int
+	// start orders processes by the time they were started, if known.
+	start uint64
+}
+
+// ChildPID returns the PID of the child executed by the wrapper of "p", found by
+// walking the process tree from the wrapper, which is the process of the tmux
+// pane or the detached process. The PID recorded in the session state is
+// returned if it belongs to a descendant of the wrapper, otherwise the oldest
+// child of the wrapper is, as sidecars are started after it. Sessions running as
+// Kubernetes Jobs or on remote hosts are not supported.
+func (p *PWrap) ChildPID() (int, error) {
+	if p.job() != nil || p.placement() != nil {
+		return 0, fmt.Errorf("child of session %s does not run on this host", p.sid)
+	}
+	wrapper := readPID(p.Path(FilePID))
+	if wrapper == 0 {
+		pid, err := tmux.PanePID(p.sid)
+		if err != nil {
+			return 0, fmt.Errorf("unable to find wrapper of session %s: %w", p.sid, err)
+		}
+		wrapper = pid
+	}
+	table, err := processTable()
+	if err != nil {
+		return 0, err
+	}
+	children := make(map[int][]process, len(table))
+	for _, v := range table {
+		children[v.ppid] = append(children[v.ppid], v)
+	}
+	var recorded int
+	if s, err := p.ReadSession(); err == nil {
+		recorded = s.PID
+	}
+	return childPID(children, wrapper, recorded)
+}
+
+// childPID returns "recorded" if it is a descendant of "wrapper" in the tree
+// described by "children", or else the oldest child of "wrapper".
+func childPID(children map[int][]process, wrapper, recorded int) (int, error) {
+	direct := children[wrapper]
+	if len(direct) == 0 {
+		return 0, ErrNoChild
+	}
+	if recorded > 0 {
+		seen := map[int]bool{wrapper: true}
+		queue := []int{wrapper}
+		for len(queue) > 0 {
+			next := queue[0]
+			queue = queue[1:]
+			for _, v := range children[next] {
+				if v.pid == recorded {
+					return recorded, nil
+				}
+				if !seen[v.pid] {
+					seen[v.pid] = true
+					queue = append(queue, v.pid)
+				}
+			}
+		}
+	}
+	sort.Slice(direct, func(i, j int) bool {
+		if direct[i].start != direct[j].start {
+			return direct[i].start < direct[j].start
+		}
+		return direct[i].pid < direct[j].pid
+	})
+	return direct[0].pid, nil
+}
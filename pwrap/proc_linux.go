@@ -0,0 +1,58 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// processTable returns the processes listed in /proc.
+func processTable() ([]process, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, fmt.Errorf("unable to list processes: %w", err)
+	}
+	var acc []process
+	for _, v := range entries {
+		pid, err := strconv.Atoi(v.Name())
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join("/proc", v.Name(), "stat"))
+		if err != nil {
+			// The process exited in the meantime.
+			continue
+		}
+		if proc, ok := parseStat(pid, data); ok {
+			acc = append(acc, proc)
+		}
+	}
+	return acc, nil
+}
+
+// parseStat parses the content of /proc/[pid]/stat. The name of the command is
+// skipped up to its last parenthesis, as it may contain spaces and parentheses.
+func parseStat(pid int, data []byte) (process, bool) {
+	i := bytes.LastIndexByte(data, ')')
+	if i < 0 {
+		return process{}, false
+	}
+	// Fields from the state on, the parent being the 4th and the start time
+	// the 22nd of the whole line.
+	fields := bytes.Fields(data[i+1:])
+	if len(fields) < 20 {
+		return process{}, false
+	}
+	ppid, err := strconv.Atoi(string(fields[1]))
+	if err != nil {
+		return process{}, false
+	}
+	start, _ := strconv.ParseUint(string(fields[19]), 10, 64)
+	return process{pid: pid, ppid: ppid, start: start}, true
+}
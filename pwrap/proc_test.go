@@ -0,0 +1,102 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestChildPID_Tree(t *testing.T) {
+	t.Parallel()
+
+	children := map[int][]process{
+		1:  {{pid: 10, ppid: 1}},
+		10: {{pid: 12, ppid: 10, start: 5}, {pid: 11, ppid: 10, start: 3}},
+		11: {{pid: 20, ppid: 11}},
+	}
+	for i, tt := range []struct {
+		wrapper, recorded, want int
+	}{
+		{10, 0, 11},
+		{10, 12, 12},
+		{10, 20, 20},
+		// Processes outside the tree of the wrapper are not trusted.
+		{10, 1, 11},
+	} {
+		pid, err := childPID(children, tt.wrapper, tt.recorded)
+		if err != nil || pid != tt.want {
+			t.Fatalf("%d: child pid SHOULD be %d, found %d, %v", i, tt.want, pid, err)
+		}
+	}
+	if _, err := childPID(children, 20, 0); !errors.Is(err, ErrNoChild) {
+		t.Fatalf("Processes without children SHOULD NOT have a child pid: %v", err)
+	}
+}
+
+func TestChildPID(t *testing.T) {
+	t.Parallel()
+
+	pw, err := New(RootDir(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A fake detached wrapper, running its child and a sidecar.
+	cmd := exec.Command("sh", "-c", "sleep 60 & sleep 60 & wait")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+	if err := os.WriteFile(pw.Path(FilePID), []byte(strconv.Itoa(cmd.Process.Pid)+"\n"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	var sleeps []process
+	for i := 0; i < 40 && len(sleeps) < 2; i++ {
+		time.Sleep(100 * time.Millisecond)
+		table, err := processTable()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sleeps = sleeps[:0]
+		for _, v := range table {
+			if v.ppid == cmd.Process.Pid {
+				sleeps = append(sleeps, v)
+			}
+		}
+	}
+	if len(sleeps) != 2 {
+		t.Fatalf("Unexpected children of the fake wrapper: %v", sleeps)
+	}
+	defer func() {
+		for _, v := range sleeps {
+			if proc, err := os.FindProcess(v.pid); err == nil {
+				proc.Kill()
+			}
+		}
+	}()
+	first, second := sleeps[0], sleeps[1]
+	if second.start < first.start || (second.start == first.start && second.pid < first.pid) {
+		first, second = second, first
+	}
+
+	pid, err := pw.ChildPID()
+	if err != nil || pid != first.pid {
+		t.Fatalf("Child pid SHOULD be the oldest child %d, found %d, %v", first.pid, pid, err)
+	}
+	if err := pw.UpdateSession(func(s *Session) { s.PID = second.pid }); err != nil {
+		t.Fatal(err)
+	}
+	if pid, err := pw.ChildPID(); err != nil || pid != second.pid {
+		t.Fatalf("Child pid SHOULD be the recorded one %d, found %d, %v", second.pid, pid, err)
+	}
+}
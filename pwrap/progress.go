@@ -0,0 +1,133 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// ProgressUpdate describes the progress of a wrapped command in completing its task.
+// Negative values are used for unknown quantities, e.g. a Total of -1 means that the
+// amount of work to be done is not known in advance. Only the description, stage and
+// partial/total fields are encoded in csv, the remaining ones are optional and
+// available to JSON clients only.
+type ProgressUpdate struct {
+	Description string `json:"description"`
+	Stage       int    `json:"stage"`
+	Stages      int    `json:"stages"`
+	Partial     int    `json:"partial"`
+	Total       int    `json:"total"`
+
+	// StageName is a human readable name of the current stage.
+	StageName string `json:"stage_name,omitempty"`
+	// Unit describes what Partial and Total are counting, e.g. "bytes" or "frames".
+	Unit string `json:"unit,omitempty"`
+	// ETA is the estimated completion time of the task.
+	ETA *time.Time `json:"eta,omitempty"`
+	// Labels are arbitrary key/value pairs attached to the update.
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// Percent returns the completion percentage of the current stage, or -1 if
+// it cannot be computed.
+func (u *ProgressUpdate) Percent() float64 {
+	if u.Total <= 0 || u.Partial < 0 {
+		return -1
+	}
+	return float64(u.Partial) / float64(u.Total) * 100
+}
+
+// WriteProgressFunc describes the signature of a structured progress writer function.
+type WriteProgressFunc func(*ProgressUpdate) error
+
+// ProgressUpdateAdapter adapts a structured progress writer to the positional
+// "WriteProgressUpdateFunc" signature.
+func ProgressUpdateAdapter(f WriteProgressFunc) WriteProgressUpdateFunc {
+	return func(d string, stage, stages, partial, tot int) error {
+		return f(&ProgressUpdate{
+			Description: d,
+			Stage:       stage,
+			Stages:      stages,
+			Partial:     partial,
+			Total:       tot,
+		})
+	}
+}
+
+// ProgressRecord is a progress update recorded in the "FileProgress" file,
+// together with the time it was received by the wrapper.
+type ProgressRecord struct {
+	Time time.Time `json:"time"`
+	*ProgressUpdate
+}
+
+// progressLog appends the progress updates of the child to a file.
+type progressLog struct {
+	sync.Mutex
+	f *os.File
+}
+
+func openProgressLog(path string) (*progressLog, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open progress file: %w", err)
+	}
+	return &progressLog{f: f}, nil
+}
+
+// write records "u", logging failures: the child is not affected by them.
+func (l *progressLog) write(u *ProgressUpdate) {
+	data, err := json.Marshal(&ProgressRecord{Time: time.Now(), ProgressUpdate: u})
+	if err != nil {
+		log.Printf("[WARN] unable to encode progress update: %v", err)
+		return
+	}
+	l.Lock()
+	defer l.Unlock()
+	if _, err := l.f.Write(append(data, '\n')); err != nil {
+		log.Printf("[WARN] unable to record progress update: %v", err)
+	}
+}
+
+func (l *progressLog) Close() error {
+	l.Lock()
+	defer l.Unlock()
+	return l.f.Close()
+}
+
+// ReadProgress decodes the progress records read from "r", e.g. the contents of
+// the "FileProgress" file. A truncated last line, written while the wrapper was
+// being killed, is ignored.
+func ReadProgress(r io.Reader) ([]*ProgressRecord, error) {
+	acc := []*ProgressRecord{}
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var rec ProgressRecord
+			if jerr := json.Unmarshal(line, &rec); jerr != nil {
+				if err == io.EOF {
+					return acc, nil
+				}
+				return nil, fmt.Errorf("unable to decode progress record: %w", jerr)
+			}
+			acc = append(acc, &rec)
+		}
+		if err == io.EOF {
+			return acc, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to read progress records: %w", err)
+		}
+	}
+}
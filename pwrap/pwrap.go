@@ -10,6 +10,8 @@ package pwrap
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -20,20 +22,74 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/kim-company/pmux/http/pwrapapi"
+	"github.com/kim-company/pmux/kube"
+	"github.com/kim-company/pmux/secrets"
+	"github.com/kim-company/pmux/tail"
 	"github.com/kim-company/pmux/tmux"
+	"github.com/kim-company/pmux/trace"
 	"github.com/phayes/freeport"
 )
 
 // PWrap is a process wrapper.
 type PWrap struct {
-	rootDir string
-	sid     string
-	name    string
-	args    []string
-	regURL  string
+	rootDir   string
+	sid       string
+	name      string
+	args      []string
+	regURL    string
+	grace     time.Duration
+	transport string
+	restarts  int
+	stopCmd   string
+	apiToken  string
+	webhooks  Hooks
+	container *Container
+	detach    bool
+	kube      *Kubernetes
+	remote    *Remote
+	quota     *Quota
+	labels    map[string]string
+	namespace string
+	priority  int
+	// secrets maps the names of the environment variables injected into
+	// the child to the references of their values.
+	secrets   map[string]string
+	artifacts *Artifacts
+	stdin     bool
+	sidecars  []Sidecar
+	// sockDir is the directory hosting the socket of the child, the
+	// default one if empty.
+	sockDir   string
+	streaming pwrapapi.StreamMode
+	// resume passes the checkpoint to the child.
+	resume bool
+	// apiPorts is the range of ports of the wrapper API, if limited.
+	apiPorts *PortRange
+	// regHeaders are attached to the registration and callback
+	// requests.
+	regHeaders map[string]string
+	// env is added to the environment of the child.
+	env map[string]string
+	// commandOps are the operations of the commands accepted by the
+	// wrapper API, the default ones if empty.
+	commandOps []string
+	// alias is the name identifying the session within its namespace.
+	alias string
+	// maxRuntime, if positive, is the time the child is allowed to run
+	// for.
+	maxRuntime time.Duration
+	// traceCtx is the span context of the operation in progress, parent
+	// of the spans started by the wrapper.
+	traceCtx  trace.SpanContext
+	sessionMu sync.Mutex
 }
 
 // SID returns the assigned session identifier.
@@ -46,13 +102,22 @@ func (p *PWrap) WorkDir() string {
 	return filepath.Join(p.rootDir, p.sid)
 }
 
-// Exec sets the executable and first arguments option.
+// Exec sets the executable and first arguments option. Arguments containing "{{"
+// are Go templates, executed with "ArgsData" by the wrapper right before the
+// child is, e.g. "--output={{.SID}}.mp4".
 func Exec(name string, args ...string) func(*PWrap) error {
 	return func(p *PWrap) error {
-		// Is "name" visible?
-		if _, err := exec.LookPath(name); err != nil {
+		if err := ValidateArgs(args); err != nil {
 			return err
 		}
+		// Is "name" visible? Containerized executables are looked up
+		// inside their image when the container, or the Job, starts, and
+		// remote ones when the remote session starts.
+		if p.container == nil && p.kube == nil && p.remote == nil {
+			if _, err := exec.LookPath(name); err != nil {
+				return err
+			}
+		}
 		p.name = name
 		p.args = args
 		return nil
@@ -67,11 +132,126 @@ func Register(url string) func(*PWrap) error {
 	}
 }
 
+// GracePeriod sets the amount of time a child is given to exit after it
+// has been asked to terminate, before being killed.
+func GracePeriod(d time.Duration) func(*PWrap) error {
+	return func(p *PWrap) error {
+		if d < 0 {
+			return fmt.Errorf("grace period cannot be negative: %v", d)
+		}
+		p.grace = d
+		return nil
+	}
+}
+
+// Priority sets the priority of the session, used by schedulers to decide which
+// session to start first. Higher values come first, the default is zero.
+func Priority(n int) func(*PWrap) error {
+	return func(p *PWrap) error {
+		p.priority = n
+		return nil
+	}
+}
+
+// Secrets injects the secrets referenced by "refs" into the environment of the
+// child, keyed by the name of their variable. Only the references are recorded,
+// the values are resolved by the wrapper right before starting the child. See
+// package secrets for the supported references.
+func Secrets(refs map[string]string) func(*PWrap) error {
+	return func(p *PWrap) error {
+		if err := secrets.ValidateRefs(refs); err != nil {
+			return err
+		}
+		p.secrets = refs
+		return nil
+	}
+}
+
+// Stdin connects the stdin of the child to a pipe, so that data can be streamed
+// into it through the wrapper API while it runs. The child reads from an empty
+// input otherwise.
+func Stdin(enabled bool) func(*PWrap) error {
+	return func(p *PWrap) error {
+		p.stdin = enabled
+		return nil
+	}
+}
+
+// Streaming sets how the wrapper API delivers the progress and the streams of
+// the child, see "pwrapapi.StreamMode".
+func Streaming(m pwrapapi.StreamMode) func(*PWrap) error {
+	return func(p *PWrap) error {
+		p.streaming = m
+		return nil
+	}
+}
+
+// Transport sets the transport used by the communication bridge between the
+// wrapper and its child. Defaults to "TransportUnix".
+func Transport(t string) func(*PWrap) error {
+	return func(p *PWrap) error {
+		switch t {
+		case "":
+			t = TransportUnix
+		case TransportUnix, TransportTCP, TransportPipe, TransportGRPC:
+		default:
+			return fmt.Errorf("unsupported communication bridge transport %q", t)
+		}
+		p.transport = t
+		return nil
+	}
+}
+
+// StopCommand sets the operation of the command delivered to the child through its
+// communication bridge when it is asked to shutdown gracefully, e.g. "CommandCancel".
+// When empty, a SIGTERM is sent instead.
+func StopCommand(op string) func(*PWrap) error {
+	return func(p *PWrap) error {
+		if op != "" {
+			if err := pwrapapi.ValidateCommandOp(op); err != nil {
+				return err
+			}
+		}
+		p.stopCmd = op
+		return nil
+	}
+}
+
+// Webhooks sets the URLs that receive the lifecycle events of the session.
+func Webhooks(urls ...string) func(*PWrap) error {
+	return func(p *PWrap) error {
+		p.webhooks = urls
+		return nil
+	}
+}
+
+// Restarts sets the number of times the session has been restarted, which is
+// reported by the wrapper's health and metrics routes.
+func Restarts(n int) func(*PWrap) error {
+	return func(p *PWrap) error {
+		p.restarts = n
+		return nil
+	}
+}
+
+// DefaultGracePeriod is the grace period used when no "GracePeriod" option
+// is provided.
+const DefaultGracePeriod = time.Second * 10
+
+// killMargin is the additional time waited on top of the grace period before
+// killing a session, giving the wrapper the chance to perform its callback.
+const killMargin = time.Second * 2
+
 const (
 	FileStderr = "stderr"
 	FileStdout = "stdout"
 	FileConfig = "config"
 	FileSID    = "sid"
+	// FileSession contains the JSON encoded "Session" state.
+	FileSession = "session"
+	// FileProgress contains every progress update received from the child,
+	// one JSON encoded "ProgressRecord" per line.
+	FileProgress = "progress"
 )
 
 // OverrideSID sets the sid option.
@@ -84,6 +264,15 @@ func OverrideSID(sid string) func(*PWrap) error {
 	}
 }
 
+// Trace sets the span context the spans of the wrapper descend from, so that
+// they are part of the trace of the operation that created the session.
+func Trace(c trace.SpanContext) func(*PWrap) error {
+	return func(p *PWrap) error {
+		p.traceCtx = c
+		return nil
+	}
+}
+
 // RootDir sets the root directory option.
 func RootDir(path string) func(*PWrap) error {
 	return func(p *PWrap) error {
@@ -94,13 +283,17 @@ func RootDir(path string) func(*PWrap) error {
 		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
 			return err
 		}
-		files := []string{FileStderr, FileStdout, FileConfig, FileSID}
+		files := []string{FileStderr, FileStdout, FileConfig, FileSID, FileSession}
 		for _, v := range files {
 			file := filepath.Join(dir, v)
 			if _, err := os.Stat(file); err == nil {
 				// In this case we want to stop: file already exists.
 				continue
 			}
+			if _, err := os.Stat(file + tail.CompressedExt); err == nil {
+				// The logs were compressed once the session finished.
+				continue
+			}
 
 			f, err := os.Create(file)
 			if err != nil {
@@ -116,7 +309,7 @@ func RootDir(path string) func(*PWrap) error {
 
 // New is used to instantiate new PWrap instances.
 func New(opts ...func(*PWrap) error) (*PWrap, error) {
-	pw := &PWrap{sid: tmux.NewSID()}
+	pw := &PWrap{sid: tmux.NewSID(), grace: DefaultGracePeriod, transport: TransportUnix}
 	for _, f := range opts {
 		if err := f(pw); err != nil {
 			return nil, fmt.Errorf("unable to apply option on process wrapper initialization: %w", err)
@@ -132,12 +325,41 @@ func (p *PWrap) Path(rel string) string {
 	return filepath.Join(p.WorkDir(), rel)
 }
 
-// SockPath returns a suitable socket address path for this session. It does not use the
-// working directory as in some systems the socket path cannot be longer than "n" chars.
-// Another reason is that this file is not actually a file that should be managed by the wrapper but
-// by the child command itself.
+// SockPath returns a suitable socket address path for this session, inside the
+// directory set with "SockDir". It does not use the working directory as in some
+// systems the socket path cannot be longer than "n" chars. Another reason is that
+// this file is not actually a file that should be managed by the wrapper but by
+// the child command itself.
 func (p *PWrap) SockPath() string {
-	return filepath.Join(os.TempDir(), p.sid+".sock")
+	return filepath.Join(p.sockDirPath(), p.sid+".sock")
+}
+
+// commAddr returns the address the child's communication bridge is expected to
+// listen on, depending on the transport selected.
+func (p *PWrap) commAddr() (string, error) {
+	switch p.transport {
+	case TransportTCP:
+		port, err := freeport.GetFreePort()
+		if err != nil {
+			return "", fmt.Errorf("unable to allocate communication bridge port: %w", err)
+		}
+		return fmt.Sprintf("127.0.0.1:%d", port), nil
+	case TransportPipe:
+		return PipeName(p.sid), nil
+	}
+	if p.container != nil {
+		// The socket directory is mounted inside the container.
+		dir := p.containerSockDir()
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return "", fmt.Errorf("unable to create communication bridge directory: %w", err)
+		}
+		return filepath.Join(dir, "bridge.sock"), nil
+	}
+	path := p.SockPath()
+	if err := claimSock(path); err != nil {
+		return "", err
+	}
+	return path, nil
 }
 
 func (p *PWrap) paths(rels ...string) []string {
@@ -175,10 +397,23 @@ func closeAll(files []*os.File) {
 	}
 }
 
-// StartSession starts the process wrapper in a tmux session. There is not guarantee that the process
+// StartSession starts the process wrapper in a tmux session, or as a detached process if
+// tmux is not available or "Detach" was set. There is not guarantee that the process
 // will still be running after this function returns. The session identifier returned will be
 // stored indide the relative ``FileSID'' file. This function is a non blocking function.
 func (p *PWrap) StartSession() (string, error) {
+	_, span := trace.Start(trace.ContextWith(context.Background(), p.traceCtx), "pwrap.StartSession")
+	defer span.End()
+	span.SetAttribute("pmux.sid", p.sid)
+	// The wrapper continues the trace of its start.
+	p.traceCtx = span.Context()
+
+	sid, err := p.startSession()
+	span.SetError(err)
+	return sid, err
+}
+
+func (p *PWrap) startSession() (string, error) {
 	sid := p.SID()
 	if sid == "" {
 		return "", fmt.Errorf("could not start process wrapper session: session identifier not set")
@@ -193,39 +428,153 @@ func (p *PWrap) StartSession() (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("could not write session identifier: %w", err)
 	}
+	if err = p.UpdateSession(func(*Session) {}); err != nil {
+		return "", fmt.Errorf("could not start process wrapper session: %w", err)
+	}
+	if p.kube == nil && p.remote == nil && p.sockDir == "" {
+		// The wrapper may not share the environment of the server,
+		// e.g. when started by a tmux server started by someone else.
+		p.sockDir = DefaultSockDir()
+	}
+	switch {
+	case p.kube != nil:
+		err = p.startJob()
+	case p.remote != nil:
+		err = p.startRemote()
+	case p.detached():
+		err = p.startDetached(p.wrapArgs(p.rootDir)...)
+	default:
+		err = tmux.NewSession(sid, os.Args[0], p.wrapArgs(p.rootDir)...)
+	}
+	if err != nil {
+		return "", fmt.Errorf("could not start process wrapper session: %w", err)
+	}
+
+	return sid, nil
+}
+
+// wrapArgs returns the arguments of the "wrap" command running "p", having its
+// working directory inside "root".
+func (p *PWrap) wrapArgs(root string) []string {
 	// Note: the child process will write it's data in the specified files of the working
 	// directory. The wrapper process though does not have any instruction to follow those
 	// guidelines. This is why we explicitly set the flags, to make also the wrapper write
 	// it's errors into the same file as the child does.
-	args := []string{"wrap", p.name}
-	args = append(args, p.args...)
-	args = append(args,
-		"--root="+p.rootDir,
-		"--sid="+sid,
-		"--reg-url="+p.regURL,
-		"--stderr="+p.Path(FileStderr),
-	)
-	if err = tmux.NewSession(sid, os.Args[0], args...); err != nil {
-		return "", fmt.Errorf("could not start process wrapper session: %w", err)
+	args := []string{"wrap",
+		"--root=" + root,
+		"--sid=" + p.sid,
+		"--reg-url=" + p.regURL,
+		"--stderr=" + filepath.Join(root, p.sid, FileStderr),
+		"--grace-period=" + p.grace.String(),
+		"--transport=" + p.transport,
+		"--restarts=" + strconv.Itoa(p.restarts),
+		"--stop-command=" + p.stopCmd,
 	}
-
-	return sid, nil
+	if p.sockDir != "" {
+		args = append(args, "--sock-dir="+p.sockDir)
+	}
+	if p.streaming != pwrapapi.StreamAuto {
+		args = append(args, "--streaming="+p.streaming.String())
+	}
+	if p.maxRuntime > 0 {
+		args = append(args, "--max-runtime="+p.maxRuntime.String())
+	}
+	for _, v := range p.commandOps {
+		args = append(args, "--command-op="+v)
+	}
+	args = append(args, headerArgs(p.regHeaders)...)
+	for _, v := range p.webhooks {
+		args = append(args, "--webhook="+v)
+	}
+	if tp := p.traceCtx.Traceparent(); tp != "" && trace.Endpoint() != "" {
+		args = append(args, "--traceparent="+tp, "--otlp-endpoint="+trace.Endpoint())
+	}
+	if q := p.quota; q != nil {
+		args = append(args, "--disk-quota="+strconv.FormatInt(q.Bytes, 10), "--disk-quota-action="+q.Action)
+	}
+	names := make([]string, 0, len(p.secrets))
+	for k := range p.secrets {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		args = append(args, "--secret="+k+"="+p.secrets[k])
+	}
+	for _, v := range environ(p.env) {
+		args = append(args, "--env="+v)
+	}
+	if p.stdin {
+		args = append(args, "--stdin")
+	}
+	if p.resume {
+		args = append(args, "--resume")
+	}
+	if p.apiPorts != nil {
+		args = append(args, "--port-range="+p.apiPorts.String())
+	}
+	for _, v := range p.sidecars {
+		args = append(args, "--sidecar="+v.String())
+	}
+	if a := p.artifacts; a != nil {
+		args = append(args, "--artifacts-url="+a.Destination)
+		for _, v := range a.Paths {
+			args = append(args, "--artifact="+v)
+		}
+	}
+	if c := p.container; c != nil {
+		args = append(args,
+			"--docker-image="+c.Image,
+			"--docker-cpus="+c.CPUs,
+			"--docker-memory="+c.Memory,
+			"--docker-network="+c.Network,
+		)
+		for _, v := range c.Mounts {
+			args = append(args, "--docker-mount="+v)
+		}
+	}
+	// The child's arguments follow the separator, so that they are not
+	// parsed as flags of the wrapper.
+	args = append(args, "--", p.name)
+	return append(args, p.args...)
 }
 
-// KillSession kills the associated tmux session, if any is running.
+// KillSession terminates the associated session, if any is running. The wrapper
+// is first asked to quit gracefully and the session is killed only if it is still
+// around after the grace period.
 func (p *PWrap) KillSession() error {
 	if p.sid == "" {
 		return fmt.Errorf("cannot kill session if process wrapper does not have a session identifier")
 	}
-	if err := tmux.KillSession(p.sid); err != nil {
+	if err := p.terminate(); err != nil {
 		return fmt.Errorf("unable to kill process wrapper session: %w", err)
 	}
 	p.sid = ""
 	return nil
 }
 
+// terminate sends a SIGTERM to the wrapper, which forwards it to its child, and waits
+// for the session to exit. If that does not happen within the grace period, the
+// session is killed.
+func (p *PWrap) terminate() error {
+	if err := p.signal(syscall.SIGTERM); err != nil {
+		log.Printf("[WARN] unable to gracefully terminate session %s: %v", p.sid, err)
+		return p.kill()
+	}
+
+	deadline := time.Now().Add(p.grace + killMargin)
+	for time.Now().Before(deadline) {
+		if !p.Running() {
+			return nil
+		}
+		time.Sleep(time.Millisecond * 100)
+	}
+	log.Printf("[WARN] session %s still running after %v, killing it", p.sid, p.grace)
+	return p.kill()
+}
+
 // Register performs an HTTP POST request to `regURL`, if present. It registers "port" with the
-// remote handler, and returnes a nil error only if the response's status is 200.
+// remote handler, together with the bearer token required to access the wrapper's API,
+// and returnes a nil error only if the response's status is 200.
 func (p *PWrap) Register(port int) error {
 	log.Printf("[INFO] registering port %d for wrapper %s", port, p.sid)
 	if p.regURL == "" {
@@ -235,13 +584,15 @@ func (p *PWrap) Register(port int) error {
 
 	buf := bytes.Buffer{}
 	if err := json.NewEncoder(&buf).Encode(&struct {
-		Port int `json:"port"`
+		Port  int    `json:"port"`
+		Token string `json:"token,omitempty"`
 	}{
-		Port: port,
+		Port:  port,
+		Token: p.apiToken,
 	}); err != nil {
 		return fmt.Errorf("error while building registration payload: %w", err)
 	}
-	resp, err := http.Post(p.regURL, "application/json", &buf)
+	resp, err := p.postRegURL("pwrap.Register", &buf)
 	if err != nil {
 		return fmt.Errorf("registration error: %w", err)
 	}
@@ -253,6 +604,35 @@ func (p *PWrap) Register(port int) error {
 	return nil
 }
 
+// postRegURL POSTs the JSON "body" to the registration URL inside the span "name",
+// propagating the trace context to the remote handler.
+func (p *PWrap) postRegURL(name string, body io.Reader) (*http.Response, error) {
+	ctx, span := trace.Start(trace.ContextWith(context.Background(), p.traceCtx), name)
+	defer span.End()
+	span.SetAttribute("pmux.sid", p.sid)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.regURL, body)
+	if err != nil {
+		span.SetError(err)
+		return nil, err
+	}
+	for k, v := range p.regHeaders {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	trace.Inject(ctx, req.Header)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		span.SetError(err)
+		return nil, err
+	}
+	span.SetAttribute("http.status_code", strconv.Itoa(resp.StatusCode))
+	if resp.StatusCode != http.StatusOK {
+		span.SetError(fmt.Errorf("status code returned is: %d", resp.StatusCode))
+	}
+	return resp, nil
+}
+
 type WrapStatus string
 
 const (
@@ -268,20 +648,38 @@ func (p *PWrap) Callback(err error) error {
 	}
 
 	var payload struct {
-		Error  string `json:"error"`
-		Status string `json:"status"`
+		Error    string `json:"error"`
+		Status   string `json:"status"`
+		ExitCode *int   `json:"exit_code,omitempty"`
+		Signal   string `json:"signal,omitempty"`
+		// OOMKilled distinguishes children killed by the OOM killer
+		// from those crashing, and TimedOut those stopped for
+		// exceeding their maximum runtime.
+		OOMKilled bool `json:"oom_killed,omitempty"`
+		TimedOut  bool `json:"timed_out,omitempty"`
+		// Artifacts are the URLs of the artifacts uploaded.
+		Artifacts []string `json:"artifacts,omitempty"`
 	}
 	payload.Status = WrapStatusSuccess
 	if err != nil {
 		payload.Error = err.Error()
 		payload.Status = string(WrapStatusError)
 	}
+	// The exit code and signal are only known when the child was
+	// started and its termination recorded.
+	if s, err := p.ReadSession(); err == nil {
+		payload.ExitCode = s.ExitCode
+		payload.Signal = s.Signal
+		payload.OOMKilled = s.OOMKilled
+		payload.TimedOut = s.State == SessionTimedOut
+		payload.Artifacts = s.ArtifactURLs
+	}
 
 	buf := bytes.Buffer{}
 	if err := json.NewEncoder(&buf).Encode(&payload); err != nil {
 		return fmt.Errorf("error while building callback payload: %w", err)
 	}
-	resp, err := http.Post(p.regURL, "application/json", &buf)
+	resp, err := p.postRegURL("pwrap.Callback", &buf)
 	if err != nil {
 		return fmt.Errorf("callback error: %w", err)
 	}
@@ -298,15 +696,56 @@ func (p *PWrap) Callback(err error) error {
 // The underlying program is executed running `<ename> --config=<configuration file path>`.
 // If an error occurs, is is both returned and written into wrapper's stderr, if possible.
 func (p *PWrap) Run(ctx context.Context) error {
-	port, err := freeport.GetFreePort()
+	ctx, span := trace.Start(trace.ContextWith(ctx, p.traceCtx), "pwrap.Run")
+	defer span.End()
+	span.SetAttribute("pmux.sid", p.sid)
+	span.SetAttribute("pmux.exec", p.name)
+	p.traceCtx = span.Context()
+
+	err := p.runSession(ctx)
+	span.SetError(err)
+	return err
+}
+
+func (p *PWrap) runSession(ctx context.Context) error {
+	if readPID(p.Path(FilePID)) == os.Getpid() {
+		// Started as a detached process: the session is over as soon
+		// as the wrapper returns.
+		defer os.Remove(p.Path(FilePID))
+	}
+	p.writePID(FileWrapperPID, os.Getpid())
+	defer p.clearPID(FileWrapperPID)
+	port, err := p.apiPort()
 	if err != nil {
 		return fmt.Errorf("unable to run: failed getting free port: %w", err)
 	}
+	// The API listens on every interface: only who receives the token
+	// through the registration is allowed to use it.
+	if p.apiToken, err = newToken(); err != nil {
+		return fmt.Errorf("unable to run: %w", err)
+	}
+	if err = p.UpdateSession(func(s *Session) {
+		s.Port = port
+		s.APIToken = p.apiToken
+		s.SockDir = p.sockDirPath()
+		s.Streaming = p.streaming
+		s.CommandOps = p.commandOps
+	}); err != nil {
+		log.Printf("[WARN] unable to record wrapper API address: %v", err)
+	}
 	if err = p.Register(port); err != nil {
 		return fmt.Errorf("unable to run: %w", err)
 	}
 
 	rerr := p.run(ctx, port)
+	// Artifacts are uploaded even when the child failed, and even when
+	// the wrapper was asked to terminate.
+	if err := p.uploadArtifacts(context.Background()); err != nil {
+		log.Printf("[ERROR] %v", err)
+		if rerr == nil {
+			rerr = err
+		}
+	}
 	cerr := p.Callback(rerr) // Callback in any case!
 
 	switch {
@@ -329,7 +768,15 @@ func (p *PWrap) run(ctx context.Context, port int) error {
 	}
 	defer closeAll(files)
 
-	paths := []string{p.Path(FileConfig), p.SockPath()}
+	addr, err := p.commAddr()
+	if err != nil {
+		return fmt.Errorf("unable to run: %w", err)
+	}
+	if p.container != nil {
+		defer os.RemoveAll(p.containerSockDir())
+		defer p.removeContainer()
+	}
+	paths := []string{p.Path(FileConfig), addr}
 
 	// What we want to accomplish is that if either the API or
 	// the tool exit, the other does too.
@@ -338,12 +785,95 @@ func (p *PWrap) run(ctx context.Context, port int) error {
 	defer cancel()
 
 	log.Printf("[INFO] executing %s, config: %s, socket path: %s", p.name, paths[0], paths[1])
-	args := append(p.args, "--config="+paths[0], "--socket-path="+paths[1])
-	cmd := exec.CommandContext(ctx, p.name, args...)
+	args, err := p.childArgs(paths[0], paths[1], port)
+	if err != nil {
+		return fmt.Errorf("unable to run: %w", err)
+	}
+	token, err := newToken()
+	if err != nil {
+		return fmt.Errorf("unable to run: %w", err)
+	}
+	env := append(environ(p.env), EnvSocketToken+"="+token)
+	if tp := p.traceCtx.Traceparent(); tp != "" {
+		env = append(env, trace.EnvTraceparent+"="+tp)
+	}
+	// Secrets only live in the environment of the child, never in its
+	// configuration or in the arguments of the wrapper.
+	values, err := secrets.Environ(ctx, p.secrets)
+	if err != nil {
+		return fmt.Errorf("unable to run: %w", err)
+	}
+	env = append(env, values...)
+	cmd := p.command(ctx, args, env...)
 	cmd.Stdout = files[0]
 	cmd.Stderr = files[1]
+	var stdin io.WriteCloser
+	if p.stdin {
+		// The pipe is closed once the child exits.
+		if stdin, err = cmd.StdinPipe(); err != nil {
+			return fmt.Errorf("unable to run: %w", err)
+		}
+	}
+	state := newChildState(p.restarts)
+	state.suspend = p.suspend
+	// When the context is canceled the child is asked to terminate, and
+	// killed only if it does not exit within the grace period.
+	cmd.Cancel = func() error {
+		state.thaw()
+		return cmd.Process.Signal(syscall.SIGTERM)
+	}
+	cmd.WaitDelay = p.grace
 
-	srv := pwrapapi.NewServer(pwrapapi.Port(port), pwrapapi.CmdSockPath(paths[1]))
+	// Updates are appended to the history of previous runs, if any.
+	if history, err := openProgressLog(p.Path(FileProgress)); err != nil {
+		log.Printf("[WARN] progress updates will not be recorded: %v", err)
+	} else {
+		defer history.Close()
+		state.onProgress = history.write
+	}
+	var lc lifecycle
+	state.onChange = func() {
+		var e *Event
+		if err := p.UpdateSession(func(s *Session) {
+			state.record(s)
+			if t, ok := lc.next(s); ok {
+				e = NewEvent(t, p.sid, s)
+			}
+		}); err != nil {
+			log.Printf("[WARN] unable to record session state: %v", err)
+		}
+		if e != nil {
+			p.webhooks.Send(e)
+		}
+	}
+	br := pwrapapi.Bridge{
+		Dial:  pwrapapi.NewDialer(p.transport, paths[1]),
+		Token: token,
+	}
+	if p.transport == TransportGRPC {
+		br.Dial = grpcDialer(paths[1])
+	}
+	srv := pwrapapi.NewServer(
+		pwrapapi.Port(port),
+		pwrapapi.APIToken(p.apiToken),
+		pwrapapi.Streaming(p.streaming),
+		pwrapapi.CmdOps(p.commandOps),
+		pwrapapi.CmdBridge(br),
+		pwrapapi.LogPaths(p.Path(FileStdout), p.Path(FileStderr)),
+		pwrapapi.ConfigPath(p.Path(FileConfig)),
+		pwrapapi.WorkDir(p.WorkDir()),
+		pwrapapi.Stdin(stdin),
+		pwrapapi.Child(state.Status),
+		pwrapapi.ChildStop(func(ctx context.Context) error {
+			return state.stop(ctx, br, p.stopCmd, p.grace)
+		}),
+		pwrapapi.ChildPause(func(_ context.Context, mode string, resume bool) error {
+			return state.pause(br, mode, resume)
+		}),
+		pwrapapi.ChildReload(func(_ context.Context, r io.Reader) error {
+			return state.reload(br, func() error { return p.WriteConfig(r) })
+		}),
+	)
 	errc := make(chan error, 1)
 	go func() {
 		err := srv.ListenAndServe()
@@ -356,69 +886,187 @@ func (p *PWrap) run(ctx context.Context, port int) error {
 			// server exited with a critical error
 			cancel()
 			errc <- err
+			return
 		}
 		errc <- nil
 	}()
 
-	err = cmd.Run()
-	if err != nil && errors.Is(err, context.Canceled) {
-		// It was the server that exited with a critical error
-		// apparently.
-		if srvErr := <-errc; srvErr != nil {
-			return fmt.Errorf("run exited due to a process wrapper API server error: %w", err)
+	// stopErr receives the reason of the child's termination when it is
+	// stopped by the wrapper, e.g. for exceeding its disk quota.
+	stopErr := make(chan error, 1)
+	oom := newOOMProbe()
+	if err = cmd.Start(); err == nil {
+		state.started(cmd.Process)
+		p.writePID(FileChildPID, cmd.Process.Pid)
+		go monitorProgress(ctx, br, state)
+		stopSidecars, serr := p.startSidecars(ctx, cmd.Process.Pid)
+		if serr != nil {
+			log.Printf("[ERROR] %v", serr)
+			stopErr <- serr
+			if err := state.stop(ctx, br, p.stopCmd, p.grace); err != nil {
+				log.Printf("[WARN] %v", err)
+			}
+		}
+		var timeout *time.Timer
+		if d := p.maxRuntime; d > 0 {
+			timeout = time.AfterFunc(d, func() {
+				log.Printf("[WARN] child exceeded its maximum runtime of %v, stopping it", d)
+				state.timedOut()
+				select {
+				case stopErr <- fmt.Errorf("%w: %v", ErrTimedOut, d):
+				default:
+				}
+				if err := state.stop(ctx, br, p.stopCmd, p.grace); err != nil {
+					log.Printf("[WARN] %v", err)
+				}
+			})
+		}
+		if q := p.quota; q != nil {
+			go watchQuota(ctx, p.WorkDir(), q, quotaCheckInterval, func(size int64) {
+				log.Printf("[WARN] working directory uses %d bytes, exceeding its disk quota of %d bytes", size, q.Bytes)
+				if q.Action != QuotaStop {
+					state.progressed(quotaWarning(size, q))
+					return
+				}
+				select {
+				case stopErr <- fmt.Errorf("disk quota of %d bytes exceeded", q.Bytes):
+				default:
+				}
+				if err := state.stop(ctx, br, p.stopCmd, p.grace); err != nil {
+					log.Printf("[WARN] %v", err)
+				}
+			})
+		}
+		err = cmd.Wait()
+		if timeout != nil {
+			timeout.Stop()
+		}
+		p.clearPID(FileChildPID)
+		if cmd.ProcessState != nil && terminatingSignal(cmd.ProcessState) == "SIGKILL" && oom.killed(cmd.Process.Pid) {
+			log.Printf("[WARN] child was likely killed by the OOM killer")
+			state.killedByOOM()
+			err = fmt.Errorf("out of memory: %w", err)
+		}
+		if stopSidecars != nil {
+			stopSidecars()
+		}
+		select {
+		case serr := <-stopErr:
+			err = serr
+		default:
 		}
-		return fmt.Errorf("run exited with an unexpected error: %w", err)
 	}
+	state.exited(cmd.ProcessState, err)
 
 	// Command exited and the server is still running (teoretically). Shutdown
 	// the server before inspecting the error.
-
-	ctx, cancel = context.WithTimeout(ctx, time.Second)
-	defer cancel()
-	srv.Shutdown(ctx)
+	sctx, scancel := context.WithTimeout(context.Background(), time.Second)
+	defer scancel()
+	srv.Shutdown(sctx)
+	var srvErr error
 	select {
-	case <-errc:
+	case srvErr = <-errc:
 	case <-time.After(time.Second * 5):
 		log.Printf("[WARN] pwrap run was stuck (for 5 seconds) waiting for the server to quit")
 	}
 
+	if err != nil && errors.Is(err, context.Canceled) && srvErr != nil {
+		// It was the server that exited with a critical error
+		// apparently.
+		return fmt.Errorf("run exited due to a process wrapper API server error: %w", srvErr)
+	}
 	if err != nil {
 		return fmt.Errorf("run exited with error: %w", err)
 	}
 	return nil
 }
 
+// childArgs returns the arguments of the child, reading its configuration from
+// "config" and listening on "addr", while the wrapper API listens on "port".
+func (p *PWrap) childArgs(config, addr string, port int) ([]string, error) {
+	args, err := ExpandArgs(p.args, p.argsData(port))
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, "--config="+config, "--socket-path="+addr)
+	if p.resume {
+		args = append(args, "--resume="+p.Path(FileCheckpoint))
+	}
+	if p.transport != TransportUnix {
+		// Children that only support unix sockets do not need to know
+		// about this flag.
+		args = append(args, "--socket-transport="+p.transport)
+	}
+	return args, nil
+}
+
+// newToken generates a random token suitable to authenticate connections to the
+// communication bridge.
+func newToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("unable to generate token: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
 // Trash removes any traces of the process from the system. It even kills the session if any
 // is running.
 func (p *PWrap) Trash() error {
-	if p.sid != "" {
-		if err := tmux.KillSession(p.sid); err != nil {
-			log.Printf("[WARN] error while trashing session: %w", err)
+	if p.sid != "" && p.Running() {
+		if err := p.terminate(); err != nil {
+			log.Printf("[WARN] error while trashing session: %v", err)
+		}
+	}
+	if k := p.job(); k != nil {
+		// Finished Jobs are kept until their session is trashed.
+		if err := kube.DeleteSession(k.Namespace, p.sid, -1); err != nil {
+			log.Printf("[WARN] error while trashing session: %v", err)
+		}
+	}
+	if r := p.placement(); r != nil {
+		if err := p.trashRemote(r); err != nil {
+			log.Printf("[WARN] error while trashing remote session files: %v", err)
 		}
 	}
 	return p.trashFiles()
 }
 
 func (p *PWrap) trashFiles() error {
-	expected := []string{FileStderr, FileStdout, FileConfig, FileSID}
-	found := 0
+	// The socket directory of the wrapper may differ from the one of
+	// the caller.
+	dir := p.sockDirPath()
+	if s, err := p.ReadSession(); err == nil && s.SockDir != "" {
+		dir = s.SockDir
+	}
+	expected := []string{FileStderr, FileStdout, FileStderr + tail.CompressedExt, FileStdout + tail.CompressedExt, FileConfig, FileSID, FileSession, FileProgress, FileWrapperPID, FileChildPID, FileCheckpoint}
+	unexpected := 0
 	filepath.Walk(p.WorkDir(), func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
-		found++
+		if path == p.WorkDir() {
+			return nil
+		}
 		for _, v := range expected {
 			if filepath.Base(path) == v {
 				return os.RemoveAll(path)
 			}
 		}
+		if strings.HasPrefix(filepath.Base(path), fileSidecarPrefix) {
+			return os.RemoveAll(path)
+		}
+		unexpected++
 		return nil
 
 	})
-	if found == len(expected)+1 /* 1 for the directory itself */ {
+	// The sockets live outside of the working directory.
+	os.Remove(filepath.Join(dir, p.sid+".sock"))
+	os.RemoveAll(filepath.Join(dir, p.sid+".d"))
+
+	// Files not created by pmux are left in place.
+	if unexpected == 0 {
 		return os.RemoveAll(p.WorkDir())
 	}
-	os.Remove(p.SockPath())
-
 	return nil
 }
@@ -5,13 +5,22 @@
 package pwrap
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
 	"errors"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/kim-company/pmux/http/pwrapapi"
+	"github.com/kim-company/pmux/trace"
 )
 
 func TestNew(t *testing.T) {
@@ -61,9 +70,12 @@ func TestTrashFiles(t *testing.T) {
 		t.Fatal(err)
 	}
 	path := pw.WorkDir()
+	if err := os.WriteFile(filepath.Join(path, FileCheckpoint), []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
 
 	// In this case, trash files should destroy the whole
-	// directory.
+	// directory, checkpoints included.
 	if err := pw.trashFiles(); err != nil {
 		t.Fatal(err)
 	}
@@ -122,3 +134,295 @@ func TestPath(t *testing.T) {
 		t.Fatalf("Wanted %v, found %v", expStderrPath, stderrPath)
 	}
 }
+
+func TestChildState_Status(t *testing.T) {
+	t.Parallel()
+
+	state := newChildState(0)
+	if s := state.Status(); s.Running || s.StartedAt != nil {
+		t.Fatalf("Unexpected status before start: %+v", s)
+	}
+
+	cmd := exec.Command("sh", "-c", "exit 3")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	state.started(cmd.Process)
+	if s := state.Status(); !s.Running || s.PID != cmd.Process.Pid {
+		t.Fatalf("Unexpected status while running: %+v", s)
+	}
+
+	err := cmd.Wait()
+	state.exited(cmd.ProcessState, err)
+	s := state.Status()
+	if s.Running || s.FinishedAt == nil || s.ExitCode == nil || *s.ExitCode != 3 || s.Error == "" {
+		t.Fatalf("Unexpected status after exit: %+v", s)
+	}
+}
+
+func TestChildState_Signal(t *testing.T) {
+	t.Parallel()
+
+	state := newChildState(0)
+	cmd := exec.Command("sh", "-c", "kill -KILL $$")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	state.started(cmd.Process)
+	err := cmd.Wait()
+	state.exited(cmd.ProcessState, err)
+
+	var s Session
+	state.record(&s)
+	if s.State != SessionFailed || s.ExitCode == nil || *s.ExitCode != -1 || s.Signal != "SIGKILL" {
+		t.Fatalf("Unexpected session exit state: %+v", s)
+	}
+	if st := state.Status(); st.Signal != "SIGKILL" {
+		t.Fatalf("Unexpected status after kill: %+v", st)
+	}
+}
+
+func TestProgressLog(t *testing.T) {
+	t.Parallel()
+
+	dir, err := os.MkdirTemp("", "pwrap-progress-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, FileProgress)
+
+	// Restarted wrappers append to the history of the previous runs.
+	for _, v := range []string{"first", "second"} {
+		l, err := openProgressLog(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		state := newChildState(0)
+		state.onProgress = l.write
+		state.progressed(&ProgressUpdate{Description: v, Partial: 1, Total: 2})
+		l.Close()
+	}
+	// A wrapper killed while writing leaves a truncated line behind.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"time":"2020-01-`)
+	f.Close()
+
+	f, err = os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	records, err := ReadProgress(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("Unexpected number of records: %d", len(records))
+	}
+	for i, v := range []string{"first", "second"} {
+		if r := records[i]; r.Description != v || r.Total != 2 || r.Time.IsZero() {
+			t.Fatalf("Unexpected record %d: %+v", i, r)
+		}
+	}
+}
+
+func TestChildState_Stop(t *testing.T) {
+	t.Parallel()
+
+	state := newChildState(0)
+	cmd := exec.Command("sh", "-c", "trap '' TERM; echo ready; sleep 10")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	state.started(cmd.Process)
+	// Wait for the trap to be installed.
+	if _, err := bufio.NewReader(stdout).ReadString('\n'); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		err := cmd.Wait()
+		state.exited(cmd.ProcessState, err)
+	}()
+
+	// The child ignores SIGTERM: it has to be killed.
+	err = state.stop(context.Background(), pwrapapi.Bridge{}, "", time.Millisecond*200)
+	if err == nil {
+		t.Fatal("Stop SHOULD report that the child was killed")
+	}
+	if s := state.Status(); s.Running {
+		t.Fatalf("Child SHOULD NOT be running: %+v", s)
+	}
+}
+
+func TestUpdateSession(t *testing.T) {
+	t.Parallel()
+
+	pw, err := New(Exec("yes", "y"), RootDir(os.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(pw.WorkDir())
+
+	if _, err := pw.ReadSession(); !errors.Is(err, ErrNoSession) {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := pw.UpdateSession(func(*Session) {}); err != nil {
+		t.Fatal(err)
+	}
+
+	state := newChildState(0)
+	state.onChange = func() {
+		if err := pw.UpdateSession(state.record); err != nil {
+			t.Error(err)
+		}
+	}
+	cmd := exec.Command("false")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	state.started(cmd.Process)
+	state.progressed(&ProgressUpdate{Description: "working", Partial: 1, Total: 2})
+	err = cmd.Wait()
+	state.exited(cmd.ProcessState, err)
+
+	s, err := pw.ReadSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.SID != pw.SID() || s.Exec != "yes" || len(s.Args) != 1 {
+		t.Fatalf("Unexpected session identity: %+v", s)
+	}
+	if s.State != SessionFailed || s.ExitCode == nil || *s.ExitCode != 1 {
+		t.Fatalf("Unexpected session exit state: %+v", s)
+	}
+	if s.CreatedAt.IsZero() || s.StartedAt == nil || s.FinishedAt == nil {
+		t.Fatalf("Unexpected session timestamps: %+v", s)
+	}
+	if s.LastProgress == nil || s.LastProgress.Description != "working" {
+		t.Fatalf("Unexpected last progress: %+v", s.LastProgress)
+	}
+}
+
+func TestWriteConfig(t *testing.T) {
+	t.Parallel()
+
+	pw, err := New(RootDir(os.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(pw.WorkDir())
+
+	if err := pw.WriteConfig(strings.NewReader(`{"bitrate":3000}`)); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(pw.Path(FileConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"bitrate":3000}` {
+		t.Fatalf("Unexpected configuration: %q", data)
+	}
+}
+
+func TestRegister_Trace(t *testing.T) {
+	t.Parallel()
+
+	parent, err := trace.ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var header string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Get(trace.Header)
+	}))
+	defer srv.Close()
+
+	pw, err := New(RootDir(os.TempDir()), Register(srv.URL), Trace(parent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(pw.WorkDir())
+
+	if err := pw.Register(4000); err != nil {
+		t.Fatal(err)
+	}
+	c, err := trace.ParseTraceparent(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.TraceID != parent.TraceID || c.SpanID == parent.SpanID {
+		t.Fatalf("Unexpected trace context propagated: %q", header)
+	}
+}
+
+func TestCallback_ExitCode(t *testing.T) {
+	t.Parallel()
+
+	var payload struct {
+		Status   string `json:"status"`
+		ExitCode *int   `json:"exit_code"`
+		Signal   string `json:"signal"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer srv.Close()
+
+	pw, err := New(RootDir(os.TempDir()), Register(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(pw.WorkDir())
+
+	code := -1
+	if err := pw.UpdateSession(func(s *Session) {
+		s.ExitCode = &code
+		s.Signal = "SIGTERM"
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := pw.Callback(errors.New("signal: terminated")); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Status != string(WrapStatusError) || payload.ExitCode == nil || *payload.ExitCode != -1 || payload.Signal != "SIGTERM" {
+		t.Fatalf("Unexpected callback payload: %+v", payload)
+	}
+}
+
+func TestSecrets(t *testing.T) {
+	t.Parallel()
+
+	if _, err := New(Secrets(map[string]string{"TOKEN": "plaintext"})); err == nil {
+		t.Fatal("Values that are not secret references SHOULD NOT be accepted")
+	}
+	refs := map[string]string{"TOKEN": "vault:secret/data/app#token", "DB_PASSWORD": "env:DB_PASSWORD"}
+	pw, err := New(Exec("yes"), RootDir(os.TempDir()), Secrets(refs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(pw.WorkDir())
+	args := strings.Join(pw.wrapArgs(os.TempDir()), " ")
+	if !strings.Contains(args, "--secret=DB_PASSWORD=env:DB_PASSWORD --secret=TOKEN=vault:secret/data/app#token") {
+		t.Fatalf("Secret references SHOULD be passed to the wrapper, sorted: %s", args)
+	}
+	if err := pw.UpdateSession(func(*Session) {}); err != nil {
+		t.Fatal(err)
+	}
+	s, err := pw.ReadSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Secrets) != 2 || s.Secrets["TOKEN"] != refs["TOKEN"] {
+		t.Fatalf("Unexpected session secrets: %v", s.Secrets)
+	}
+}
@@ -0,0 +1,139 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Actions performed when a session exceeds its disk quota.
+const (
+	// QuotaWarn reports the excess as a progress update, leaving the child
+	// running.
+	QuotaWarn = "warn"
+	// QuotaStop stops the child, as if the session was deleted, and marks
+	// the session as failed.
+	QuotaStop = "stop"
+)
+
+// quotaCheckInterval is the time between two measurements of the working directory.
+const quotaCheckInterval = time.Second * 10
+
+// Quota limits the disk space used by the working directory of a session.
+type Quota struct {
+	// Bytes is the maximum size of the working directory.
+	Bytes int64 `json:"bytes"`
+	// Action is either "QuotaWarn" or "QuotaStop". Defaults to "QuotaWarn".
+	Action string `json:"action,omitempty"`
+}
+
+// Validate reports whether "q" can be enforced.
+func (q *Quota) Validate() error {
+	if q.Bytes <= 0 {
+		return fmt.Errorf("disk quota has to be positive: %d", q.Bytes)
+	}
+	switch q.Action {
+	case "", QuotaWarn, QuotaStop:
+	default:
+		return fmt.Errorf("unsupported disk quota action %q", q.Action)
+	}
+	return nil
+}
+
+// ParseSize parses a size in bytes, optionally followed by one of the K, M, G
+// and T binary multipliers, e.g. "512M".
+func ParseSize(s string) (int64, error) {
+	v := strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(s)), "B")
+	shift := 0
+	if n := len(v); n > 0 {
+		if i := strings.IndexByte("KMGT", v[n-1]); i >= 0 {
+			shift = (i + 1) * 10
+			v = v[:n-1]
+		}
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 || n > (1<<62)>>shift {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n << shift, nil
+}
+
+// DiskQuota limits the disk space used by the working directory of the session
+// to "q". The directory is not limited if nil.
+func DiskQuota(q *Quota) func(*PWrap) error {
+	return func(p *PWrap) error {
+		if q != nil {
+			if err := q.Validate(); err != nil {
+				return err
+			}
+		}
+		p.quota = q
+		return nil
+	}
+}
+
+// dirSize returns the size of the regular files contained in "dir".
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Files may be removed while walking.
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// watchQuota measures "dir" every "interval" until "ctx" is done, calling
+// "exceeded" each time its size goes over the quota "q".
+func watchQuota(ctx context.Context, dir string, q *Quota, interval time.Duration, exceeded func(int64)) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	over := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		size, err := dirSize(dir)
+		if err != nil {
+			log.Printf("[WARN] unable to measure working directory: %v", err)
+			continue
+		}
+		if size > q.Bytes && !over {
+			exceeded(size)
+		}
+		over = size > q.Bytes
+	}
+}
+
+// quotaWarning returns the progress update reporting that the working directory
+// uses "size" bytes, exceeding "q".
+func quotaWarning(size int64, q *Quota) *ProgressUpdate {
+	return &ProgressUpdate{
+		Description: fmt.Sprintf("disk quota exceeded: working directory uses %d of %d bytes", size, q.Bytes),
+		Stage:       -1,
+		Stages:      -1,
+		Partial:     int(size),
+		Total:       int(q.Bytes),
+		Unit:        "bytes",
+		Labels:      map[string]string{"pmux.warning": "disk_quota"},
+	}
+}
@@ -0,0 +1,85 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseSize(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		in   string
+		want int64
+	}{
+		{"100", 100},
+		{"4k", 4 << 10},
+		{"512M", 512 << 20},
+		{"10GB", 10 << 30},
+		{"1T", 1 << 40},
+	}
+	for _, v := range tt {
+		n, err := ParseSize(v.in)
+		if err != nil {
+			t.Fatalf("%q: %v", v.in, err)
+		}
+		if n != v.want {
+			t.Fatalf("%q: wanted %d, found %d", v.in, v.want, n)
+		}
+	}
+	for _, v := range []string{"", "G", "-1", "1.5G", "10X"} {
+		if _, err := ParseSize(v); err == nil {
+			t.Fatalf("%q: expected an error", v)
+		}
+	}
+}
+
+func TestWatchQuota(t *testing.T) {
+	t.Parallel()
+
+	dir, err := os.MkdirTemp("", "pmux-quota-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	exceeded := make(chan int64, 1)
+	go watchQuota(ctx, dir, &Quota{Bytes: 1024}, time.Millisecond*10, func(size int64) {
+		exceeded <- size
+	})
+
+	select {
+	case size := <-exceeded:
+		t.Fatalf("quota exceeded by an empty directory: %d", size)
+	case <-time.After(time.Millisecond * 50):
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "out"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "out", "data"), make([]byte, 2048), 0644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case size := <-exceeded:
+		if size != 2048 {
+			t.Fatalf("unexpected size: %d", size)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("quota excess not detected")
+	}
+	// The excess is reported once.
+	select {
+	case <-exceeded:
+		t.Fatal("quota excess reported twice")
+	case <-time.After(time.Millisecond * 50):
+	}
+}
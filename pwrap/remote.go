@@ -0,0 +1,244 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// SSHCommand is the command used to reach remote hosts. It has to authenticate
+// without user interaction, e.g. using an agent or keys listed in its
+// configuration.
+var SSHCommand = "ssh"
+
+// remoteCmdExecTimeout is the time given to commands executed on remote hosts.
+const remoteCmdExecTimeout = time.Second * 10
+
+// Remote describes the host a session is placed on. The wrapper runs inside a tmux
+// session of the remote host, started over SSH, with its working directory inside
+// "Root". The server keeps a copy of the configuration and of the session state,
+// which is refreshed from the remote working directory.
+type Remote struct {
+	// Host is the SSH destination, in the "[user@]hostname" form. Ports
+	// and keys are taken from the SSH client configuration.
+	Host string `json:"host"`
+	// Root is the directory containing the working directories on the
+	// remote host.
+	Root string `json:"root"`
+	// PMux is the path of the pmux executable on the remote host. Defaults
+	// to "pmux", looked up in the PATH of the remote shell.
+	PMux string `json:"pmux,omitempty"`
+}
+
+// Validate reports whether "r" can be used to run a session.
+func (r *Remote) Validate() error {
+	if r.Host == "" {
+		return fmt.Errorf("remote host not set")
+	}
+	if !path.IsAbs(r.Root) {
+		return fmt.Errorf("remote root directory %q is not an absolute path", r.Root)
+	}
+	return nil
+}
+
+// Hostname returns the name of the remote host, without the user.
+func (r *Remote) Hostname() string {
+	return r.Host[strings.LastIndex(r.Host, "@")+1:]
+}
+
+// shellQuote quotes "s" so that it is interpreted literally by a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// errRemoteExit is returned by "run" when the remote command exits with a
+// non zero status.
+var errRemoteExit = errors.New("remote command failed")
+
+// run executes "args" on the remote host, feeding it "stdin" if not nil, and
+// returns its output. As SSH joins the arguments into a single command line
+// interpreted by the remote shell, each of them is quoted.
+func (r *Remote) run(stdin []byte, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), remoteCmdExecTimeout)
+	defer cancel()
+
+	quoted := make([]string, len(args))
+	for i, v := range args {
+		quoted[i] = shellQuote(v)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, SSHCommand, "-o", "BatchMode=yes", r.Host, "--", strings.Join(quoted, " "))
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	// SSH exits with 255 when the connection fails.
+	if errors.As(err, &exitErr) && exitErr.ExitCode() != 255 {
+		return nil, fmt.Errorf("%s on %s: %w: %s", args[0], r.Host, errRemoteExit, strings.TrimSpace(stderr.String()))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to reach %s: %w: %s", r.Host, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// OnRemote places the session on the remote host described by "r", rather than
+// on this one. This function has to be called before "Exec" if used in the ``New''
+// function, as the executable is then looked up on the remote host.
+func OnRemote(r *Remote) func(*PWrap) error {
+	return func(p *PWrap) error {
+		if r == nil {
+			p.remote = nil
+			return nil
+		}
+		if err := r.Validate(); err != nil {
+			return err
+		}
+		p.remote = r
+		return nil
+	}
+}
+
+// placement returns the remote host of "p", either set as an option or recorded
+// in its session state. Returns nil if the session runs on this host.
+func (p *PWrap) placement() *Remote {
+	if p.remote != nil {
+		return p.remote
+	}
+	if s, err := p.ReadSession(); err == nil {
+		return s.Remote
+	}
+	return nil
+}
+
+// startRemote copies the configuration of "p" to its remote working directory and
+// starts the wrapper inside a tmux session of the remote host.
+func (p *PWrap) startRemote() error {
+	r := p.remote
+	config, err := os.ReadFile(p.Path(FileConfig))
+	if err != nil {
+		return fmt.Errorf("unable to read configuration: %w", err)
+	}
+	dir := path.Join(r.Root, p.sid)
+	if _, err := r.run(config, "sh", "-c", `mkdir -p "$1" && cat > "$1/`+FileConfig+`"`, "sh", dir); err != nil {
+		return fmt.Errorf("unable to copy configuration: %w", err)
+	}
+	pmux := r.PMux
+	if pmux == "" {
+		pmux = "pmux"
+	}
+	args := append([]string{"tmux", "new", "-s", p.sid, "-d", pmux}, p.wrapArgs(r.Root)...)
+	if _, err := r.run(nil, args...); err != nil {
+		return fmt.Errorf("unable to create remote session: %w", err)
+	}
+	return nil
+}
+
+// remoteRunning reports whether the tmux session of "p" is present on "r". Errors
+// other than a missing session are logged, and the session considered running.
+func (p *PWrap) remoteRunning(r *Remote) bool {
+	_, err := r.run(nil, "tmux", "has-session", "-t", p.sid)
+	if errors.Is(err, errRemoteExit) {
+		return false
+	}
+	if err != nil {
+		log.Printf("[WARN] %v", err)
+	}
+	return true
+}
+
+// listRemote returns the pmux sessions running on "r".
+func listRemote(r *Remote) ([]string, error) {
+	out, err := r.run(nil, "tmux", "list-sessions", "-F", "#{session_name}")
+	if errors.Is(err, errRemoteExit) {
+		// No tmux server is running.
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var acc []string
+	for _, v := range strings.Fields(string(out)) {
+		if strings.HasPrefix(v, "pmux-") {
+			acc = append(acc, v)
+		}
+	}
+	return acc, nil
+}
+
+// signalRemote delivers "sig" to the wrapper running in the tmux session of "p" on "r".
+func (p *PWrap) signalRemote(r *Remote, sig syscall.Signal) error {
+	script := `pid=$(tmux list-panes -t "$1" -F '#{pane_pid}' | head -n 1) && kill -` + strconv.Itoa(int(sig)) + ` "$pid"`
+	_, err := r.run(nil, "sh", "-c", script, "sh", p.sid)
+	return err
+}
+
+// killRemote destroys the tmux session of "p" on "r".
+func (p *PWrap) killRemote(r *Remote) error {
+	_, err := r.run(nil, "tmux", "kill-session", "-t", p.sid)
+	return err
+}
+
+// trashRemote removes the remote working directory of "p".
+func (p *PWrap) trashRemote(r *Remote) error {
+	_, err := r.run(nil, "rm", "-rf", path.Join(r.Root, p.sid))
+	return err
+}
+
+// refreshRemote updates the session state of "p" with the one recorded by the
+// wrapper in the remote working directory.
+func (p *PWrap) refreshRemote(r *Remote) error {
+	out, err := r.run(nil, "cat", path.Join(r.Root, p.sid, FileSession))
+	if errors.Is(err, errRemoteExit) {
+		// The wrapper did not record its state yet.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var remote Session
+	if err := json.NewDecoder(bytes.NewReader(out)).Decode(&remote); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		return fmt.Errorf("unable to decode remote session state: %w", err)
+	}
+	return p.UpdateSession(func(s *Session) {
+		recordRemote(s, &remote)
+	})
+}
+
+// recordRemote applies the state recorded by the remote wrapper, "remote", to
+// session "s". Identity, placement and creation fields are kept.
+func recordRemote(s *Session, remote *Session) {
+	s.State = remote.State
+	s.StartedAt = remote.StartedAt
+	s.FinishedAt = remote.FinishedAt
+	s.PID = remote.PID
+	s.ExitCode = remote.ExitCode
+	s.Signal = remote.Signal
+	s.OOMKilled = remote.OOMKilled
+	s.Error = remote.Error
+	s.LastProgress = remote.LastProgress
+	s.LastProgressAt = remote.LastProgressAt
+	s.Port = remote.Port
+	s.APIToken = remote.APIToken
+}
@@ -0,0 +1,76 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeSSH replaces the SSH client with a script executing the remote command
+// line on this host.
+func fakeSSH(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "ssh")
+	// Invoked as: ssh -o BatchMode=yes <host> -- <command line>
+	if err := os.WriteFile(script, []byte("#!/bin/sh\nshift 4\nexec sh -c \"$1\"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	old := SSHCommand
+	SSHCommand = script
+	t.Cleanup(func() { SSHCommand = old })
+}
+
+func TestRemote_Run(t *testing.T) {
+	fakeSSH(t)
+
+	r := &Remote{Host: "user@example.com", Root: "/srv"}
+	args := []string{"it's", "$HOME", "a b", `"quoted"`}
+	out, err := r.run(nil, append([]string{"printf", `%s\n`}, args...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n"); strings.Join(got, "|") != strings.Join(args, "|") {
+		t.Fatalf("arguments not preserved: %q", got)
+	}
+
+	out, err = r.run([]byte("config"), "cat")
+	if err != nil || string(out) != "config" {
+		t.Fatalf("unexpected output %q: %v", out, err)
+	}
+	if _, err := r.run(nil, "false"); !errors.Is(err, errRemoteExit) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := r.run(nil, "sh", "-c", "exit 255"); err == nil || errors.Is(err, errRemoteExit) {
+		t.Fatalf("connection errors should not be reported as command failures: %v", err)
+	}
+}
+
+func TestRemote_Hostname(t *testing.T) {
+	t.Parallel()
+
+	for host, want := range map[string]string{"user@example.com": "example.com", "example.com": "example.com"} {
+		if got := (&Remote{Host: host}).Hostname(); got != want {
+			t.Fatalf("%q: unexpected hostname %q", host, got)
+		}
+	}
+}
+
+func TestRecordRemote(t *testing.T) {
+	t.Parallel()
+
+	r := &Remote{Host: "example.com", Root: "/srv"}
+	code := 1
+	s := &Session{SID: "pmux-test", State: SessionCreated, Labels: map[string]string{"team": "a"}, Remote: r}
+	recordRemote(s, &Session{SID: "pmux-test", State: SessionFailed, ExitCode: &code, Port: 4242, APIToken: "token"})
+	if s.State != SessionFailed || *s.ExitCode != 1 || s.Port != 4242 || s.APIToken != "token" {
+		t.Fatalf("remote state not recorded: %+v", s)
+	}
+	if s.Remote != r || s.Labels["team"] != "a" {
+		t.Fatalf("local fields not kept: %+v", s)
+	}
+}
@@ -0,0 +1,154 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+// Package report is meant to be used by the programs wrapped by pwrap, to report
+// their progress and results and to receive commands through the communication
+// bridge the wrapper connects to.
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/kim-company/pmux/pwrap"
+)
+
+// CommandFunc handles a command received from the wrapper. The response returned
+// is delivered back to the issuer of the command.
+type CommandFunc func(cmd string) (string, error)
+
+// Reporter delivers progress updates and results to the wrapper. Its methods are
+// safe for concurrent use.
+type Reporter struct {
+	br         pwrap.CommBridge
+	out        io.Writer
+	bridgeOpts []pwrap.CommBridgeOption
+
+	mu        sync.Mutex
+	onCommand CommandFunc
+}
+
+// Output sets where updates are printed when the program is not run by a wrapper,
+// i.e. when no socket path is given. Defaults to stdout.
+func Output(w io.Writer) func(*Reporter) {
+	return func(r *Reporter) {
+		r.out = w
+	}
+}
+
+// BridgeOptions adds "opts" to the options of the communication bridge, e.g.
+// "pwrap.SocketMode" and "pwrap.SocketGroup".
+func BridgeOptions(opts ...pwrap.CommBridgeOption) func(*Reporter) {
+	return func(r *Reporter) {
+		r.bridgeOpts = append(r.bridgeOpts, opts...)
+	}
+}
+
+// New starts the communication bridge on "addr" using "transport", which are given
+// to the program with the "--socket-path" and "--socket-transport" flags. The
+// authentication token is read from the environment. If "addr" is empty the
+// reporter prints the updates instead. The wrapper may connect, and reconnect,
+// at any time until the reporter is closed: the last update and the result are
+// replayed to it.
+func New(ctx context.Context, transport, addr string, opts ...func(*Reporter)) (*Reporter, error) {
+	r := &Reporter{out: os.Stdout}
+	for _, f := range opts {
+		f(r)
+	}
+	if addr == "" {
+		return r, nil
+	}
+
+	br, err := pwrap.NewCommBridge(ctx, transport, addr, append([]pwrap.CommBridgeOption{
+		pwrap.OnCommandResponse(r.handleCommand),
+		pwrap.AuthToken(os.Getenv(pwrap.EnvSocketToken)),
+	}, r.bridgeOpts...)...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to start communication bridge: %w", err)
+	}
+	r.br = br
+	go br.Open(ctx)
+	return r, nil
+}
+
+// FromArgs is like New, reading the socket path and transport from the
+// "--socket-path" and "--socket-transport" flags found in "args", e.g. os.Args.
+func FromArgs(ctx context.Context, args []string, opts ...func(*Reporter)) (*Reporter, error) {
+	var transport, addr string
+	for i := 0; i < len(args); i++ {
+		for _, f := range []struct {
+			name string
+			v    *string
+		}{{"--socket-path", &addr}, {"--socket-transport", &transport}} {
+			if v := args[i]; strings.HasPrefix(v, f.name+"=") {
+				*f.v = strings.TrimPrefix(v, f.name+"=")
+			} else if v == f.name && i+1 < len(args) {
+				*f.v = args[i+1]
+			}
+		}
+	}
+	return New(ctx, transport, addr, opts...)
+}
+
+// Report delivers "u" to the clients of the progress stream.
+func (r *Reporter) Report(u *pwrap.ProgressUpdate) error {
+	if r.br == nil {
+		_, err := fmt.Fprintf(r.out, "%d: %s\n", u.Partial, u.Description)
+		return err
+	}
+	return r.br.WriteProgress(u)
+}
+
+// WriteProgressUpdate is like Report, using positional arguments. It satisfies
+// "pwrap.WriteProgressUpdateFunc".
+func (r *Reporter) WriteProgressUpdate(d string, stage, stages, partial, tot int) error {
+	return pwrap.ProgressUpdateAdapter(r.Report)(d, stage, stages, partial, tot)
+}
+
+// Result delivers "v", JSON encoded, to the clients of the "pwrap.ChannelResult"
+// stream. It is meant to be called once, before the program exits.
+func (r *Reporter) Result(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("unable to encode result: %w", err)
+	}
+	data = append(data, '\n')
+	if r.br == nil {
+		_, err := r.out.Write(data)
+		return err
+	}
+	_, err = r.br.Channel(pwrap.ChannelResult).Write(data)
+	return err
+}
+
+// OnCommand sets the handler of the commands received from the wrapper. Commands
+// are refused until a handler is set.
+func (r *Reporter) OnCommand(h CommandFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onCommand = h
+}
+
+func (r *Reporter) handleCommand(_ pwrap.CommBridge, cmd string) (string, error) {
+	r.mu.Lock()
+	h := r.onCommand
+	r.mu.Unlock()
+	if h == nil {
+		return "", fmt.Errorf("commands are not supported")
+	}
+	return h(cmd)
+}
+
+// Close stops the communication bridge.
+func (r *Reporter) Close() error {
+	if r.br == nil {
+		return nil
+	}
+	return r.br.Close()
+}
@@ -0,0 +1,107 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package report
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kim-company/pmux/pwrap"
+)
+
+func dial(t *testing.T, path, header string) (net.Conn, *bufio.Reader) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Write([]byte(header + "\n")); err != nil {
+		t.Fatal(err)
+	}
+	return conn, bufio.NewReader(conn)
+}
+
+func TestReporter(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	path := filepath.Join(t.TempDir(), "report.sock")
+	r, err := FromArgs(ctx, []string{"mockcmd", "--config=config.json", "--socket-path", path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	command := func(cmd string) pwrap.CommandResponse {
+		conn, br := dial(t, path, "mode=command")
+		defer conn.Close()
+		conn.Write([]byte(cmd + "\n"))
+		var resp pwrap.CommandResponse
+		if err := json.NewDecoder(br).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	if resp := command("pause"); resp.OK {
+		t.Fatalf("command SHOULD have been refused without a handler: %+v", resp)
+	}
+	r.OnCommand(func(cmd string) (string, error) {
+		if cmd != "pause" {
+			return "", fmt.Errorf("unknown command")
+		}
+		return "paused", nil
+	})
+	if resp := command("pause"); !resp.OK || resp.Response != "paused" {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+
+	// Updates and results are replayed to late clients.
+	if err := r.Report(&pwrap.ProgressUpdate{Description: "working", Partial: 4}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Result(map[string]int{"frames": 42}); err != nil {
+		t.Fatal(err)
+	}
+	conn, br := dial(t, path, "mode=progress;format=json")
+	defer conn.Close()
+	var u pwrap.ProgressUpdate
+	if err := json.NewDecoder(br).Decode(&u); err != nil {
+		t.Fatal(err)
+	}
+	if u.Description != "working" || u.Partial != 4 {
+		t.Fatalf("unexpected update: %+v", u)
+	}
+	conn, br = dial(t, path, "mode=stream;channel="+pwrap.ChannelResult)
+	defer conn.Close()
+	line, err := br.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(line) != `{"frames":42}` {
+		t.Fatalf("unexpected result: %q", line)
+	}
+}
+
+func TestReporter_NoSocket(t *testing.T) {
+	t.Parallel()
+
+	buf := &bytes.Buffer{}
+	r, err := FromArgs(context.Background(), []string{"mockcmd"}, Output(buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	r.WriteProgressUpdate("waited", -1, -1, 3, -1)
+	r.Result("done")
+	if buf.String() != "3: waited\n\"done\"\n" {
+		t.Fatalf("unexpected output: %q", buf.String())
+	}
+}
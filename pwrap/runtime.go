@@ -0,0 +1,53 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrTimedOut is the error of the children stopped for exceeding their maximum
+// runtime, see "MaxRuntime".
+var ErrTimedOut = errors.New("maximum runtime exceeded")
+
+// MaxRuntime limits the time the child runs for. Once "d" is over, the child is
+// stopped like when the session is asked to terminate, and the session is
+// recorded as "SessionTimedOut". The time spent paused counts too. The child is
+// not limited if "d" is zero.
+func MaxRuntime(d time.Duration) func(*PWrap) error {
+	return func(p *PWrap) error {
+		if d < 0 {
+			return fmt.Errorf("maximum runtime cannot be negative: %v", d)
+		}
+		p.maxRuntime = d
+		return nil
+	}
+}
+
+// ParseMaxRuntime parses the maximum runtime "s", in the "time.ParseDuration"
+// format. An empty "s" means no limit.
+func ParseMaxRuntime(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid maximum runtime %q: %w", s, err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("maximum runtime has to be positive: %v", d)
+	}
+	return d, nil
+}
+
+// runtimeString returns "d" as recorded in the session state, empty if zero.
+func runtimeString(d time.Duration) string {
+	if d <= 0 {
+		return ""
+	}
+	return d.String()
+}
@@ -0,0 +1,60 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseMaxRuntime(t *testing.T) {
+	t.Parallel()
+
+	if d, err := ParseMaxRuntime(""); err != nil || d != 0 {
+		t.Fatalf("Empty runtimes SHOULD NOT be limited: %v, %v", d, err)
+	}
+	if d, err := ParseMaxRuntime("90m"); err != nil || d != 90*time.Minute {
+		t.Fatalf("Unexpected runtime: %v, %v", d, err)
+	}
+	for _, v := range []string{"1 hour", "0s", "-1m"} {
+		if _, err := ParseMaxRuntime(v); err == nil {
+			t.Fatalf("Runtime %q SHOULD be rejected", v)
+		}
+	}
+	if _, err := New(RootDir(t.TempDir()), MaxRuntime(-time.Second)); err == nil {
+		t.Fatalf("Negative runtimes SHOULD be rejected")
+	}
+}
+
+func TestMaxRuntime(t *testing.T) {
+	t.Parallel()
+
+	pw, err := New(RootDir(t.TempDir()), Exec("sh", "-c", "sleep 10"), MaxRuntime(200*time.Millisecond), GracePeriod(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !contains(pw.wrapArgs(pw.rootDir), "--max-runtime=200ms") {
+		t.Fatalf("Wrapper arguments SHOULD contain the maximum runtime: %q", pw.wrapArgs(pw.rootDir))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	start := time.Now()
+	if err := pw.Run(ctx); !errors.Is(err, ErrTimedOut) {
+		t.Fatalf("Run SHOULD fail with a timeout, found %v", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Fatalf("Child SHOULD be stopped once its runtime is over, it ran for %v", d)
+	}
+	s, err := pw.ReadSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.State != SessionTimedOut || s.MaxRuntime != "200ms" || s.Signal != "SIGTERM" || s.Error == "" {
+		t.Fatalf("Unexpected session state: %+v", s)
+	}
+}
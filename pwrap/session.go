@@ -0,0 +1,305 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/kim-company/pmux/http/pwrapapi"
+)
+
+// SessionState describes the lifecycle phase of a session.
+type SessionState string
+
+const (
+	// SessionCreated sessions have been started but their child has not
+	// been executed yet.
+	SessionCreated SessionState = "created"
+	// SessionQueued sessions are waiting for the server to start them.
+	SessionQueued  = "queued"
+	SessionRunning = "running"
+	// SessionPaused sessions are running, but their child is paused, see
+	// "pwrapapi.Pause".
+	SessionPaused = "paused"
+	// SessionExited sessions terminated successfully.
+	SessionExited = "exited"
+	// SessionFailed sessions terminated with an error.
+	SessionFailed = "failed"
+	// SessionTimedOut sessions were stopped for exceeding their maximum
+	// runtime, see "MaxRuntime".
+	SessionTimedOut = "timed_out"
+)
+
+// Session is the state of a session, persisted in the "FileSession" file of its
+// working directory. It is created when the session is started and kept up to date
+// by the wrapper as its child runs.
+type Session struct {
+	SID            string            `json:"sid"`
+	Exec           string            `json:"exec"`
+	Args           []string          `json:"args,omitempty"`
+	State          SessionState      `json:"state"`
+	Labels         map[string]string `json:"labels,omitempty"`
+	Namespace      string            `json:"namespace,omitempty"`
+	Name           string            `json:"name,omitempty"`
+	Priority       int               `json:"priority,omitempty"`
+	RegisterURL    string            `json:"register_url,omitempty"`
+	Restarts       int               `json:"restarts"`
+	CreatedAt      time.Time         `json:"created_at"`
+	StartedAt      *time.Time        `json:"started_at,omitempty"`
+	FinishedAt     *time.Time        `json:"finished_at,omitempty"`
+	PID            int               `json:"pid,omitempty"`
+	ExitCode       *int              `json:"exit_code,omitempty"`
+	Error          string            `json:"error,omitempty"`
+	LastProgress   *ProgressUpdate   `json:"last_progress,omitempty"`
+	LastProgressAt *time.Time        `json:"last_progress_at,omitempty"`
+	// PausedAt is set while the child is paused.
+	PausedAt *time.Time `json:"paused_at,omitempty"`
+	// Signal is the name of the signal that terminated the child, e.g.
+	// "SIGKILL", in which case ExitCode is -1.
+	Signal string `json:"signal,omitempty"`
+	// OOMKilled is set when the child was likely killed by the OOM killer,
+	// rather than crashing or being killed by someone else.
+	OOMKilled bool `json:"oom_killed,omitempty"`
+	// Port is the port the wrapper API is listening on, and APIToken the
+	// bearer token it requires.
+	Port     int    `json:"port,omitempty"`
+	APIToken string `json:"api_token,omitempty"`
+	// Container is set when the child runs inside a Docker container.
+	Container *Container `json:"container,omitempty"`
+	// Kubernetes is set when the session runs as a Kubernetes Job.
+	Kubernetes *Kubernetes `json:"kubernetes,omitempty"`
+	// Remote is set when the session is placed on a remote host.
+	Remote *Remote `json:"remote,omitempty"`
+	// DiskQuota is set when the working directory of the session is limited.
+	DiskQuota *Quota `json:"disk_quota,omitempty"`
+	// Retention is the time the session is kept for once finished, in
+	// the "time.ParseDuration" format. The server's retention applies if empty.
+	Retention string `json:"retention,omitempty"`
+	// MaxRuntime is the time the child is allowed to run for, in the
+	// "time.ParseDuration" format. The child is not limited if empty.
+	MaxRuntime string `json:"max_runtime,omitempty"`
+	// Secrets maps the names of the environment variables of the child to
+	// the references of the secrets injected into them. Their values are
+	// never recorded.
+	Secrets map[string]string `json:"secrets,omitempty"`
+	// Env holds the variables added to the environment of the child.
+	Env map[string]string `json:"env,omitempty"`
+	// Artifacts is set when files of the working directory are uploaded
+	// once the child exits, and ArtifactURLs lists those uploaded.
+	Artifacts    *Artifacts `json:"artifacts,omitempty"`
+	ArtifactURLs []string   `json:"artifact_urls,omitempty"`
+	// Stdin is set when data can be streamed into the stdin of the child.
+	Stdin bool `json:"stdin,omitempty"`
+	// Sidecars are the commands running alongside the child.
+	Sidecars []Sidecar `json:"sidecars,omitempty"`
+	// SockDir is the directory hosting the socket of the child, recorded
+	// by the wrapper.
+	SockDir string `json:"sock_dir,omitempty"`
+	// Streaming is how the wrapper API delivers the streams of the child.
+	Streaming pwrapapi.StreamMode `json:"streaming,omitempty"`
+	// CommandOps are the operations of the commands accepted by the
+	// wrapper API, the default ones if empty.
+	CommandOps []string `json:"command_ops,omitempty"`
+	// Resumed is set when the child was told to continue from its
+	// checkpoint, see "PWrap.Resume".
+	Resumed bool `json:"resumed,omitempty"`
+	// RawConfig is set when the configuration was uploaded as is, e.g. a
+	// binary file, rather than encoded as JSON.
+	RawConfig bool `json:"raw_config,omitempty"`
+	// RegisterHeaders are attached to the registration and callback
+	// requests. They may carry credentials, see "RegisterHeaders".
+	RegisterHeaders map[string]string `json:"register_headers,omitempty"`
+}
+
+// Refreshed reports whether the state of "s" is not recorded by its wrapper, but
+// has to be refreshed with "PWrap.Refresh" while the session runs.
+func (s *Session) Refreshed() bool {
+	return (s.Kubernetes != nil || s.Remote != nil) && (s.State == SessionCreated || s.State == SessionRunning || s.State == SessionPaused)
+}
+
+// ErrNoSession is returned when the state of a session has not been recorded yet.
+var ErrNoSession = errors.New("session state not available")
+
+// ReadSession returns the state recorded in "p"'s working directory.
+func (p *PWrap) ReadSession() (*Session, error) {
+	f, err := p.Open(FileSession, os.O_RDONLY, 0)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNoSession
+		}
+		return nil, fmt.Errorf("unable to open session state: %w", err)
+	}
+	defer f.Close()
+
+	var s Session
+	if err := json.NewDecoder(f).Decode(&s); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, ErrNoSession
+		}
+		return nil, fmt.Errorf("unable to decode session state: %w", err)
+	}
+	return &s, nil
+}
+
+// UpdateSession applies "f" to the state recorded in "p"'s working directory,
+// creating it if it is not available yet, and stores the result.
+func (p *PWrap) UpdateSession(f func(*Session)) error {
+	p.sessionMu.Lock()
+	defer p.sessionMu.Unlock()
+
+	s, err := p.ReadSession()
+	if err != nil {
+		if !errors.Is(err, ErrNoSession) {
+			return err
+		}
+		s = &Session{
+			SID:             p.sid,
+			Exec:            p.name,
+			Args:            p.args,
+			State:           SessionCreated,
+			Labels:          p.labels,
+			Namespace:       p.namespace,
+			Name:            p.alias,
+			Priority:        p.priority,
+			CreatedAt:       time.Now(),
+			RegisterURL:     p.regURL,
+			RegisterHeaders: p.regHeaders,
+			Container:       p.container,
+			Kubernetes:      p.kube,
+			Remote:          p.remote,
+			DiskQuota:       p.quota,
+			Secrets:         p.secrets,
+			Env:             p.env,
+			Artifacts:       p.artifacts,
+			Stdin:           p.stdin,
+			Sidecars:        p.sidecars,
+			MaxRuntime:      runtimeString(p.maxRuntime),
+		}
+	}
+	f(s)
+	return p.writeSession(s)
+}
+
+// writeSession replaces the session state file atomically, so that readers never
+// observe partial writes.
+func (p *PWrap) writeSession(s *Session) error {
+	err := p.replaceFile(FileSession, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(s)
+	})
+	if err != nil {
+		return fmt.Errorf("unable to store session state: %w", err)
+	}
+	return nil
+}
+
+// WriteConfig replaces the configuration file with the contents of "r".
+func (p *PWrap) WriteConfig(r io.Reader) error {
+	err := p.replaceFile(FileConfig, func(w io.Writer) error {
+		_, err := io.Copy(w, r)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("unable to store configuration: %w", err)
+	}
+	return nil
+}
+
+// replaceFile atomically replaces the file "rel" of the working directory with
+// the data written by "f".
+func (p *PWrap) replaceFile(rel string, f func(io.Writer) error) error {
+	tmp, err := os.CreateTemp(p.WorkDir(), "."+rel+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := f(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p.Path(rel))
+}
+
+// Restart terminates the session, if running, and starts it again keeping its
+// identifier, configuration and working directory. The executable, its arguments,
+// the registration URL, the labels, the namespace, the priority, the container,
+// the Job, the remote host, the disk quota, the maximum runtime, the secrets, the
+// artifacts, the stdin pipe, the sidecars and the socket directory are those
+// recorded in the session state.
+func (p *PWrap) Restart() (string, error) {
+	return p.restart(false)
+}
+
+// restart restarts the session, whose child is told to resume from its checkpoint
+// if "resume" is set.
+func (p *PWrap) restart(resume bool) (string, error) {
+	s, err := p.ReadSession()
+	if err != nil {
+		return "", fmt.Errorf("unable to restart session: %w", err)
+	}
+	if p.Running() {
+		if err := p.terminate(); err != nil {
+			return "", fmt.Errorf("unable to restart session: %w", err)
+		}
+	}
+	p.container, p.kube, p.remote = s.Container, s.Kubernetes, s.Remote
+	p.quota, p.labels, p.namespace = s.DiskQuota, s.Labels, s.Namespace
+	p.priority, p.secrets, p.artifacts = s.Priority, s.Secrets, s.Artifacts
+	p.stdin, p.sidecars, p.sockDir = s.Stdin, s.Sidecars, s.SockDir
+	p.streaming, p.env, p.alias = s.Streaming, s.Env, s.Name
+	p.commandOps = s.CommandOps
+	if p.maxRuntime, err = ParseMaxRuntime(s.MaxRuntime); err != nil {
+		return "", fmt.Errorf("unable to restart session: %w", err)
+	}
+	if err := Exec(s.Exec, s.Args...)(p); err != nil {
+		return "", fmt.Errorf("unable to restart session: %w", err)
+	}
+	p.regURL, p.regHeaders = s.RegisterURL, s.RegisterHeaders
+	p.restarts = s.Restarts + 1
+	p.resume = resume
+
+	if err := p.UpdateSession(func(s *Session) {
+		*s = Session{
+			SID:             s.SID,
+			Exec:            s.Exec,
+			Args:            s.Args,
+			State:           SessionCreated,
+			Labels:          s.Labels,
+			Namespace:       s.Namespace,
+			Name:            s.Name,
+			Priority:        s.Priority,
+			CreatedAt:       s.CreatedAt,
+			RegisterURL:     s.RegisterURL,
+			RegisterHeaders: s.RegisterHeaders,
+			Restarts:        p.restarts,
+			Container:       s.Container,
+			Kubernetes:      s.Kubernetes,
+			Remote:          s.Remote,
+			DiskQuota:       s.DiskQuota,
+			Retention:       s.Retention,
+			MaxRuntime:      s.MaxRuntime,
+			Secrets:         s.Secrets,
+			Env:             s.Env,
+			Artifacts:       s.Artifacts,
+			Stdin:           s.Stdin,
+			Sidecars:        s.Sidecars,
+			SockDir:         s.SockDir,
+			Streaming:       s.Streaming,
+			CommandOps:      s.CommandOps,
+			Resumed:         resume,
+			RawConfig:       s.RawConfig,
+		}
+	}); err != nil {
+		return "", fmt.Errorf("unable to restart session: %w", err)
+	}
+	return p.StartSession()
+}
@@ -0,0 +1,142 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Environment variables describing the session to its sidecars.
+const (
+	EnvSID      = "PMUX_SID"
+	EnvWorkDir  = "PMUX_WORK_DIR"
+	EnvChildPID = "PMUX_CHILD_PID"
+)
+
+// fileSidecarPrefix prefixes the files of the working directory receiving the
+// output of the sidecars, followed by their name and ".log".
+const fileSidecarPrefix = "sidecar-"
+
+var sidecarNameRx = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_-]*$`)
+
+// Sidecar is an auxiliary command, e.g. a metrics exporter or a file watcher,
+// started by the wrapper alongside the child and stopped together with it.
+type Sidecar struct {
+	// Name identifies the sidecar inside its session.
+	Name string   `json:"name"`
+	Path string   `json:"path"`
+	Args []string `json:"args,omitempty"`
+}
+
+// ParseSidecar parses a sidecar definition in the "name=path[,arg...]" form.
+func ParseSidecar(s string) (Sidecar, error) {
+	kv := strings.SplitN(s, "=", 2)
+	if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+		return Sidecar{}, fmt.Errorf("invalid sidecar definition %q, expected name=path[,arg...]", s)
+	}
+	fields := strings.Split(kv[1], ",")
+	sc := Sidecar{Name: kv[0], Path: fields[0], Args: fields[1:]}
+	return sc, sc.Validate()
+}
+
+// String returns "s" in the form parsed by "ParseSidecar".
+func (s Sidecar) String() string {
+	return s.Name + "=" + strings.Join(append([]string{s.Path}, s.Args...), ",")
+}
+
+// Validate reports whether "s" can be started.
+func (s Sidecar) Validate() error {
+	if !sidecarNameRx.MatchString(s.Name) {
+		return fmt.Errorf("invalid sidecar name %q", s.Name)
+	}
+	if s.Path == "" {
+		return fmt.Errorf("sidecar %s: path not set", s.Name)
+	}
+	return nil
+}
+
+// Sidecars makes the wrapper start "s" once the child is running. Sidecars are
+// stopped as soon as the child exits, their output is written to the
+// "sidecar-<name>.log" file of the working directory.
+func Sidecars(s ...Sidecar) func(*PWrap) error {
+	return func(p *PWrap) error {
+		names := make(map[string]bool, len(s))
+		for _, v := range s {
+			if err := v.Validate(); err != nil {
+				return err
+			}
+			if names[v.Name] {
+				return fmt.Errorf("duplicate sidecar %s", v.Name)
+			}
+			names[v.Name] = true
+		}
+		p.sidecars = s
+		return nil
+	}
+}
+
+// startSidecars starts the sidecars of the session, which runs its child as
+// process "pid". They receive SIGTERM when either "ctx" is done or the returned
+// function is called, which waits for them to exit.
+func (p *PWrap) startSidecars(ctx context.Context, pid int) (func(), error) {
+	ctx, cancel := context.WithCancel(ctx)
+	var done []chan struct{}
+	stop := func() {
+		cancel()
+		for _, v := range done {
+			<-v
+		}
+	}
+	env := append(os.Environ(),
+		EnvSID+"="+p.sid,
+		EnvWorkDir+"="+p.WorkDir(),
+		EnvChildPID+"="+strconv.Itoa(pid),
+	)
+	for _, v := range p.sidecars {
+		f, err := p.Open(fileSidecarPrefix+v.Name+".log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			stop()
+			return nil, fmt.Errorf("unable to start sidecar %s: %w", v.Name, err)
+		}
+		cmd := exec.CommandContext(ctx, v.Path, v.Args...)
+		cmd.Dir = p.WorkDir()
+		cmd.Env = env
+		cmd.Stdout, cmd.Stderr = f, f
+		cmd.Cancel = func() error {
+			return cmd.Process.Signal(syscall.SIGTERM)
+		}
+		cmd.WaitDelay = p.grace
+		if err := cmd.Start(); err != nil {
+			f.Close()
+			stop()
+			return nil, fmt.Errorf("unable to start sidecar %s: %w", v.Name, err)
+		}
+		log.Printf("[INFO] sidecar %s started, pid: %d", v.Name, cmd.Process.Pid)
+
+		c := make(chan struct{})
+		done = append(done, c)
+		go func(name string) {
+			defer close(c)
+			defer f.Close()
+			err := cmd.Wait()
+			if ctx.Err() == nil {
+				// Sidecars are not restarted: the child keeps
+				// running without them.
+				log.Printf("[WARN] sidecar %s exited before the child: %v", name, err)
+				return
+			}
+			log.Printf("[INFO] sidecar %s stopped", name)
+		}(v.Name)
+	}
+	return stop, nil
+}
@@ -0,0 +1,81 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSidecar(t *testing.T) {
+	t.Parallel()
+
+	s, err := ParseSidecar("metrics=/usr/bin/exporter,--port,9100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Sidecar{Name: "metrics", Path: "/usr/bin/exporter", Args: []string{"--port", "9100"}}); !reflect.DeepEqual(s, want) {
+		t.Fatalf("Wanted %+v, found %+v", want, s)
+	}
+	if str := s.String(); str != "metrics=/usr/bin/exporter,--port,9100" {
+		t.Fatalf("Unexpected sidecar definition: %s", str)
+	}
+	for _, v := range []string{"metrics", "=/usr/bin/exporter", "metrics=", "../metrics=/usr/bin/exporter"} {
+		if _, err := ParseSidecar(v); err == nil {
+			t.Fatalf("%q SHOULD NOT be a valid sidecar definition", v)
+		}
+	}
+	if _, err := New(Sidecars(s, s)); err == nil {
+		t.Fatal("Sidecars with the same name SHOULD NOT be accepted")
+	}
+}
+
+func TestPWrap_StartSidecars(t *testing.T) {
+	t.Parallel()
+
+	s := Sidecar{Name: "watcher", Path: "sh", Args: []string{"-c", "echo $PMUX_SID $PMUX_CHILD_PID; exec sleep 60"}}
+	pw, err := New(RootDir(os.TempDir()), GracePeriod(time.Second), Sidecars(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(pw.WorkDir())
+	if args := strings.Join(pw.wrapArgs(os.TempDir()), " "); !strings.Contains(args, "--sidecar=watcher=sh,-c,echo") {
+		t.Fatalf("Sidecars SHOULD be passed to the wrapper: %s", args)
+	}
+
+	stop, err := pw.startSidecars(context.Background(), 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := pw.SID() + " 42\n"
+	var out []byte
+	for i := 0; i < 20 && string(out) != want; i++ {
+		time.Sleep(time.Millisecond * 50)
+		out, _ = os.ReadFile(pw.Path("sidecar-watcher.log"))
+	}
+	if string(out) != want {
+		t.Fatalf("Wanted sidecar output %q, found %q", want, out)
+	}
+	start := time.Now()
+	stop()
+	if d := time.Since(start); d > time.Second/2 {
+		t.Fatalf("Sidecars SHOULD exit on SIGTERM, took %v", d)
+	}
+	if err := pw.trashFiles(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(pw.WorkDir()); !os.IsNotExist(err) {
+		t.Fatalf("The output of sidecars SHOULD be trashed with the session: %v", err)
+	}
+
+	pw.sidecars = []Sidecar{{Name: "missing", Path: "/nonexistent/sidecar"}}
+	if _, err := pw.startSidecars(context.Background(), 42); err == nil {
+		t.Fatal("Sidecars that cannot be started SHOULD return an error")
+	}
+}
@@ -0,0 +1,87 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// maxSockPath is the maximum length of the path of a unix socket, the smallest
+// among the supported systems: 104 bytes on macOS and the BSDs, 108 on Linux.
+const maxSockPath = 104
+
+// DefaultSockDir returns the directory hosting the sockets of the children when
+// none is configured: $XDG_RUNTIME_DIR, private to the user, if set, otherwise
+// the temporary directory, which may be shared with other users.
+func DefaultSockDir() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir
+		}
+	}
+	return os.TempDir()
+}
+
+// SockDir sets the directory hosting the unix socket of the child's communication
+// bridge. "DefaultSockDir" is used if empty.
+func SockDir(dir string) func(*PWrap) error {
+	return func(p *PWrap) error {
+		if dir != "" {
+			if err := checkSockPath(filepath.Join(dir, p.sid+".sock")); err != nil {
+				return err
+			}
+		}
+		p.sockDir = dir
+		return nil
+	}
+}
+
+// sockDirPath returns the directory hosting the socket of the child.
+func (p *PWrap) sockDirPath() string {
+	if p.sockDir != "" {
+		return p.sockDir
+	}
+	return DefaultSockDir()
+}
+
+// checkSockPath reports whether "path" is short enough to be bound by a unix
+// socket.
+func checkSockPath(path string) error {
+	if len(path) > maxSockPath {
+		return fmt.Errorf("socket path %s is longer than %d bytes", path, maxSockPath)
+	}
+	return nil
+}
+
+// claimSock makes sure that the child can listen on the unix socket "path": it
+// fails if the path is too long, or if another process is already listening on
+// it, while sockets left behind by previous children are removed.
+func claimSock(path string) error {
+	if err := checkSockPath(path); err != nil {
+		return err
+	}
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("unable to inspect socket path: %w", err)
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("socket path %s is taken by a file that is not a socket", path)
+	}
+	if conn, err := net.DialTimeout("unix", path, time.Millisecond*100); err == nil {
+		conn.Close()
+		return fmt.Errorf("socket path %s is in use by another process", path)
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("unable to remove stale socket: %w", err)
+	}
+	return nil
+}
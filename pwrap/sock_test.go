@@ -0,0 +1,106 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDefaultSockDir(t *testing.T) {
+	dir, err := os.MkdirTemp("", "pmux-sock-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	t.Setenv("XDG_RUNTIME_DIR", dir)
+	if d := DefaultSockDir(); d != dir {
+		t.Fatalf("Sockets SHOULD be placed in $XDG_RUNTIME_DIR, found %s", d)
+	}
+	pw, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path := pw.SockPath(); path != filepath.Join(dir, pw.SID()+".sock") {
+		t.Fatalf("Unexpected socket path: %s", path)
+	}
+	t.Setenv("XDG_RUNTIME_DIR", filepath.Join(dir, "missing"))
+	if d := DefaultSockDir(); d != os.TempDir() {
+		t.Fatalf("Sockets SHOULD be placed in the temporary directory, found %s", d)
+	}
+}
+
+func TestClaimSock(t *testing.T) {
+	t.Parallel()
+
+	dir, err := os.MkdirTemp("", "pmux-sock-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "child.sock")
+	if err := claimSock(path); err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := claimSock(path); err == nil || !strings.Contains(err.Error(), "in use") {
+		t.Fatalf("Sockets in use SHOULD NOT be claimed: %v", err)
+	}
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	l.Close()
+	if err := claimSock(path); err != nil {
+		t.Fatalf("Stale sockets SHOULD be claimed: %v", err)
+	}
+	if _, err := os.Lstat(path); !os.IsNotExist(err) {
+		t.Fatalf("Stale sockets SHOULD be removed: %v", err)
+	}
+
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := claimSock(path); err == nil {
+		t.Fatal("Files that are not sockets SHOULD NOT be claimed")
+	}
+	long := filepath.Join(dir, strings.Repeat("d", maxSockPath))
+	if err := claimSock(filepath.Join(long, "child.sock")); err == nil {
+		t.Fatal("Paths too long for a socket SHOULD NOT be claimed")
+	}
+	if _, err := New(SockDir(long)); err == nil {
+		t.Fatal("Socket directories too long SHOULD NOT be accepted")
+	}
+}
+
+func TestTrashFiles_Sockets(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	pw, err := New(RootDir(t.TempDir()), SockDir(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sock, containerDir := pw.SockPath(), pw.containerSockDir()
+	if err := os.WriteFile(sock, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(containerDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := pw.trashFiles(); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []string{sock, containerDir, pw.WorkDir()} {
+		if _, err := os.Stat(v); !os.IsNotExist(err) {
+			t.Fatalf("%s SHOULD be removed with the session, found %v", v, err)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"context"
+	"fmt"
+	"net"
+)
+
+// TCPCommBridge is a communication bridge listening on a TCP address. It is meant
+// to be used on the loopback interface only, on systems where unix sockets are
+// not viable.
+type TCPCommBridge struct {
+	*bridge
+}
+
+// NewTCPCommBridge starts a TCP listener on "addr", i.e. "127.0.0.1:4242".
+// Is is the caller's responsibility to close the listener when it's done.
+func NewTCPCommBridge(ctx context.Context, addr string, opts ...CommBridgeOption) (*TCPCommBridge, error) {
+	l, err := new(net.ListenConfig).Listen(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("unable to listen on %v: %w", addr, err)
+	}
+	t := &TCPCommBridge{}
+	t.bridge = newBridge(l, t, opts...)
+	return t, nil
+}
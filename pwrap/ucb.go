@@ -7,68 +7,320 @@ package pwrap
 import (
 	"bufio"
 	"context"
+	"crypto/subtle"
 	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net"
 	"os"
+	"os/user"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/kim-company/pmux/http/pwrapapi"
 )
 
-// UnixCommBridge is a Unix Socket listener that extends the communication channels
-// available by wrapped commands. If a command is capable of providing updates
-// about is progress in completing the task, it can use this socket to write
-// updates to it and receive commands from it.
-// The socket can be used to enable the communication between the child process
-// and the process wrapper, which will expose the socket to the internet through
-// its HTTP API.
-type UnixCommBridge struct {
-	path string
+// CommBridge is a listener that extends the communication channels available
+// by wrapped commands. If a command is capable of providing updates about is
+// progress in completing the task, it can use the bridge to write updates to
+// it and receive commands from it.
+// The bridge can be used to enable the communication between the child process
+// and the process wrapper, which will expose it to the internet through its
+// HTTP API.
+type CommBridge interface {
+	io.Writer
+	// Open makes the bridge accept new connections, blocking until it is closed.
+	Open(context.Context)
+	// Close closes the bridge, releasing its resources.
+	Close() error
+	// Addr returns the address the bridge is listening on.
+	Addr() net.Addr
+	// WriteProgress delivers a progress update to each connected client.
+	WriteProgress(*ProgressUpdate) error
+	// WriteProgressUpdate is like WriteProgress, using positional arguments.
+	WriteProgressUpdate(d string, stage, stages, partial, tot int) error
+	// Channel returns a writer delivering its content to the clients of the
+	// named stream.
+	Channel(name string) io.Writer
+}
+
+// Transports that can be used by the communication bridge.
+const (
+	TransportUnix = "unix"
+	TransportTCP  = "tcp"
+	TransportPipe = "pipe"
+	// TransportGRPC serves the gRPC service of package "bridgepb" on a Unix
+	// Domain Socket, instead of the line based protocol.
+	TransportGRPC = "grpc"
+)
+
+// NewCommBridge starts a communication bridge listening on "addr" using "transport".
+// Is is the caller's responsibility to close the bridge when it's done.
+func NewCommBridge(ctx context.Context, transport, addr string, opts ...CommBridgeOption) (CommBridge, error) {
+	switch transport {
+	case "", TransportUnix:
+		return NewUnixCommBridge(ctx, addr, opts...)
+	case TransportTCP:
+		return NewTCPCommBridge(ctx, addr, opts...)
+	case TransportPipe:
+		return NewPipeCommBridge(ctx, addr, opts...)
+	case TransportGRPC:
+		return NewGRPCCommBridge(ctx, addr, opts...)
+	default:
+		return nil, fmt.Errorf("unsupported communication bridge transport %q", transport)
+	}
+}
+
+// CommBridgeOption configures a communication bridge.
+type CommBridgeOption func(*bridge)
+
+// bridge implements the protocol shared by every communication bridge, regardless
+// of the transport used by its listener.
+type bridge struct {
 	net.Listener
-	last struct {
+	// self is the bridge handed over to command handlers.
+	self     CommBridge
+	channels struct {
 		sync.Mutex
-		u *string
+		m map[string]*channel
 	}
-	clients struct {
-		sync.Mutex
-		m map[string]chan string
+	replay int
+	// declared are the channels created along with the bridge.
+	declared []string
+
+	onCommand CommandHandlerFunc
+	heartbeat time.Duration
+	queueSize int
+	laggards  LaggardPolicy
+	token     string
+
+	sockMode  os.FileMode
+	sockGroup string
+}
+
+// DefaultReplaySize is the number of frames replayed to new progress clients
+// when no "ReplaySize" option is provided.
+const DefaultReplaySize = 1
+
+// ReplaySize sets the number of most recent frames that are kept by the bridge
+// and replayed to newly connected progress clients, allowing them to recover
+// the history of the updates. Frames exceeding the client's queue size are not
+// replayed.
+func ReplaySize(n int) CommBridgeOption {
+	return func(u *bridge) {
+		if n < 0 {
+			n = 0
+		}
+		u.replay = n
 	}
-	wroteCSVHeader bool
+}
 
-	onCommand func(*UnixCommBridge, string) error
+// Channels declares the named streams published by the bridge, besides
+// "ChannelProgress" and "ChannelResult", so that clients can subscribe to them
+// before anything is written. Clients cannot subscribe to channels that were
+// neither declared nor written to with "Channel".
+func Channels(names ...string) CommBridgeOption {
+	return func(u *bridge) {
+		u.declared = append(u.declared, names...)
+	}
+}
+
+// EnvSocketToken is the environment variable used by the wrapper to hand over
+// the communication bridge authentication token to its child.
+const EnvSocketToken = "PMUX_SOCKET_TOKEN"
+
+// AuthToken sets the shared secret that connections have to present in their
+// handshake header, i.e. "mode=progress;token=<token>", before entering progress
+// or command mode. An empty token disables authentication.
+func AuthToken(token string) CommBridgeOption {
+	return func(u *bridge) {
+		u.token = token
+	}
+}
+
+// SocketMode sets the permissions of the socket file of unix bridges once it is
+// listening, e.g. 0660, instead of those resulting from the umask. A zero mode
+// keeps them. Other transports ignore it.
+func SocketMode(mode os.FileMode) CommBridgeOption {
+	return func(u *bridge) {
+		u.sockMode = mode
+	}
+}
+
+// SocketGroup sets the group owning the socket file of unix bridges once it is
+// listening, given either as a name or as a numeric id. Combined with
+// "SocketMode", it grants the access to the socket to the members of the group
+// only. Other transports ignore it.
+func SocketGroup(group string) CommBridgeOption {
+	return func(u *bridge) {
+		u.sockGroup = group
+	}
+}
+
+// LaggardPolicy describes what happens to a progress client that does not keep up
+// with the updates, i.e. whose queue is full when a new frame has to be delivered.
+type LaggardPolicy int
+
+const (
+	// DropOldest discards the oldest queued frame to make room for the new one.
+	DropOldest LaggardPolicy = iota
+	// DropNewest discards the new frame, keeping the queue as it is.
+	DropNewest
+	// Disconnect closes the connection of the laggard client.
+	Disconnect
+)
+
+// DefaultQueueSize is the per-client queue size used when no "QueueSize" option
+// is provided.
+const DefaultQueueSize = 64
+
+// QueueSize sets the number of frames that can be queued for each progress client
+// before the laggard policy kicks in.
+func QueueSize(n int) CommBridgeOption {
+	return func(u *bridge) {
+		if n < 1 {
+			n = 1
+		}
+		u.queueSize = n
+	}
+}
+
+// Laggards sets the policy applied to progress clients that do not keep up with
+// the updates. Defaults to "DropOldest".
+func Laggards(p LaggardPolicy) CommBridgeOption {
+	return func(u *bridge) {
+		u.laggards = p
+	}
+}
+
+// DefaultHeartbeatInterval is the heartbeat interval used when no "HeartbeatInterval"
+// option is provided.
+const DefaultHeartbeatInterval = time.Second * 15
+
+// HeartbeatInterval sets the interval at which heartbeat frames are written on progress
+// connections that have not received any update in the meantime. Heartbeats are empty
+// lines, which are ignored by both csv and JSON-lines decoders, and allow clients to detect
+// a dead child. A zero interval disables heartbeats.
+func HeartbeatInterval(d time.Duration) CommBridgeOption {
+	return func(u *bridge) {
+		u.heartbeat = d
+	}
+}
+
+// CommandHandlerFunc describes the signature of a command handler. The response
+// returned is delivered back to the issuer of the command.
+type CommandHandlerFunc func(CommBridge, string) (string, error)
+
+// CommandResponse is written back, JSON encoded on a single line, over the
+// connection that delivered the command once its handler returns.
+type CommandResponse struct {
+	OK       bool   `json:"ok"`
+	Response string `json:"response,omitempty"`
+	Error    string `json:"error,omitempty"`
 }
 
 // OnCommand sets the onCommand function option. When a command is recevied through the socket,
-// this handler will be called.
-func OnCommand(h func(*UnixCommBridge, string) error) func(*UnixCommBridge) {
-	return func(u *UnixCommBridge) {
+// this handler will be called. The command is acknowledged with an empty response when the
+// handler does not return an error.
+func OnCommand(h func(CommBridge, string) error) CommBridgeOption {
+	return OnCommandResponse(func(u CommBridge, cmd string) (string, error) {
+		return "", h(u, cmd)
+	})
+}
+
+// OnCommandResponse is like OnCommand, but allows the handler to return a response
+// payload that is delivered back to the issuer of the command.
+func OnCommandResponse(h CommandHandlerFunc) CommBridgeOption {
+	return func(u *bridge) {
 		u.onCommand = h
 	}
 }
 
-// NewUnixCommBridge starts a Unix Domain Socket listener on ``path''.
+func newBridge(l net.Listener, self CommBridge, opts ...CommBridgeOption) *bridge {
+	b := &bridge{
+		Listener:  l,
+		self:      self,
+		heartbeat: DefaultHeartbeatInterval,
+		queueSize: DefaultQueueSize,
+	}
+	b.replay = DefaultReplaySize
+	for _, f := range opts {
+		f(b)
+	}
+	for _, v := range append([]string{ChannelProgress, ChannelResult}, b.declared...) {
+		b.channel(v)
+	}
+	return b
+}
+
+// UnixCommBridge is a communication bridge listening on a Unix Domain Socket.
+type UnixCommBridge struct {
+	*bridge
+	path string
+}
+
+// NewUnixCommBridge starts a Unix Domain Socket listener on "path".
 // Is is the caller's responsibility to close the listener when it's done.
-func NewUnixCommBridge(ctx context.Context, path string, opts ...func(*UnixCommBridge)) (*UnixCommBridge, error) {
+func NewUnixCommBridge(ctx context.Context, path string, opts ...CommBridgeOption) (*UnixCommBridge, error) {
 	os.Remove(path)
 	l, err := new(net.ListenConfig).Listen(ctx, "unix", path)
 	if err != nil {
 		return nil, fmt.Errorf("unable to listen on %v: %w", path, err)
 	}
-	u := &UnixCommBridge{Listener: l, path: path}
-	for _, f := range opts {
-		f(u)
+	u := &UnixCommBridge{path: path}
+	u.bridge = newBridge(l, u, opts...)
+	if err := u.setPermissions(); err != nil {
+		u.Close()
+		return nil, err
 	}
 	return u, nil
 }
 
-// Open makes the socket accept new connections. Open is expected to run in its own gorountine. Context
+// setPermissions applies the mode and the group configured to the socket file.
+func (b *UnixCommBridge) setPermissions() error {
+	if b.sockGroup != "" {
+		gid, err := lookupGroup(b.sockGroup)
+		if err != nil {
+			return err
+		}
+		if err := os.Chown(b.path, -1, gid); err != nil {
+			return fmt.Errorf("unable to change the group of %v: %w", b.path, err)
+		}
+	}
+	if b.sockMode != 0 {
+		if err := os.Chmod(b.path, b.sockMode); err != nil {
+			return fmt.Errorf("unable to change the mode of %v: %w", b.path, err)
+		}
+	}
+	return nil
+}
+
+// lookupGroup returns the id of "group", which is either a name or a numeric id.
+func lookupGroup(group string) (int, error) {
+	if gid, err := strconv.Atoi(group); err == nil {
+		return gid, nil
+	}
+	g, err := user.LookupGroup(group)
+	if err != nil {
+		return 0, fmt.Errorf("unable to find group %q: %w", group, err)
+	}
+	return strconv.Atoi(g.Gid)
+}
+
+// Close closes the unix listener and will remove its socket file.
+func (b *UnixCommBridge) Close() error {
+	defer os.Remove(b.path)
+	return b.Listener.Close()
+}
+
+// Open makes the bridge accept new connections. Open is expected to run in its own gorountine. Context
 // cancelation will not make the function quit, but it will close any pending connection activity. To
-// make the function exit b.Close() should be used instead, which will close the underlying unix socket.
-func (b *UnixCommBridge) Open(ctx context.Context) {
+// make the function exit b.Close() should be used instead, which will close the underlying listener.
+func (b *bridge) Open(ctx context.Context) {
 	for {
 		conn, err := b.Listener.Accept()
 
@@ -81,144 +333,291 @@ func (b *UnixCommBridge) Open(ctx context.Context) {
 	}
 }
 
-// Close closes the unix listener and will remove its socket file.
-func (b *UnixCommBridge) Close() error {
-	defer os.Remove(b.path)
-	return b.Listener.Close()
-}
-
 // WriteProgressUpdateFunc describes the signature of a progress writer function.
 type WriteProgressUpdateFunc func(d string, stage, stages, partial, tot int) error
 
-// WriteProgressUpdate is an helper function that writes the data in the underlying socket, using
-// csv for encoding. The first call to the function will also print the csv header.
-func (b *UnixCommBridge) WriteProgressUpdate(d string, stage, stages, partial, tot int) error {
-	w := csv.NewWriter(b)
-	if !b.wroteCSVHeader {
+// Progress encoding formats that can be requested by clients in the connection
+// header, i.e. "mode=progress;format=json".
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+// WriteProgress delivers a progress update to each client listening on the socket.
+// The update is encoded in the format each client negotiated, csv by default. CSV
+// clients receive the header before their first update.
+func (b *bridge) WriteProgress(u *ProgressUpdate) error {
+	// Copy the update, as it is going to be read concurrently by each
+	// client after this function returns.
+	cp := *u
+	if u.Labels != nil {
+		cp.Labels = make(map[string]string, len(u.Labels))
+		for k, v := range u.Labels {
+			cp.Labels[k] = v
+		}
+	}
+	b.channel(ChannelProgress).broadcast(&frame{update: &cp}, b.laggards)
+	return nil
+}
+
+// WriteProgressUpdate is a thin adapter around WriteProgress, kept for children
+// that report their progress using positional arguments.
+func (b *bridge) WriteProgressUpdate(d string, stage, stages, partial, tot int) error {
+	return ProgressUpdateAdapter(b.WriteProgress)(d, stage, stages, partial, tot)
+}
+
+// Write is an "io.Writer" implementation, which delivers the content written to each client
+// listening on the progress stream, regardless of the format they negotiated.
+func (b *bridge) Write(p []byte) (int, error) {
+	return b.Channel(ChannelProgress).Write(p)
+}
+
+// Channel returns a writer delivering its content to each client listening on the
+// named stream, which clients can consume using the "mode=stream;channel=<name>"
+// header. The content written is delivered as is, progress updates are only
+// available on the "ChannelProgress" stream.
+func (b *bridge) Channel(name string) io.Writer {
+	return &channelWriter{c: b.channel(name), policy: b.laggards}
+}
+
+type progressEncoder interface {
+	Encode(*ProgressUpdate) error
+}
+
+type csvProgressEncoder struct {
+	w           *csv.Writer
+	wroteHeader bool
+}
+
+func (e *csvProgressEncoder) Encode(u *ProgressUpdate) error {
+	if !e.wroteHeader {
 		header := []string{"DESCRIPTION", "STAGE", "STAGES", "PARTIAL", "TOTAL"}
-		if err := w.Write(header); err != nil {
+		if err := e.w.Write(header); err != nil {
 			return fmt.Errorf("unable to write progress update header: %w", err)
 		}
-		b.wroteCSVHeader = true
+		e.wroteHeader = true
 	}
-	if err := w.Write([]string{
-		d,
-		strconv.Itoa(stage),
-		strconv.Itoa(stages),
-		strconv.Itoa(partial),
-		strconv.Itoa(tot),
+	if err := e.w.Write([]string{
+		u.Description,
+		strconv.Itoa(u.Stage),
+		strconv.Itoa(u.Stages),
+		strconv.Itoa(u.Partial),
+		strconv.Itoa(u.Total),
 	}); err != nil {
 		return fmt.Errorf("unable to write progress update: %w", err)
 	}
-	w.Flush()
-
-	return nil
+	e.w.Flush()
+	return e.w.Error()
 }
 
-// Write is an ``io.Writer'' implementation, which delivers the content written to each client
-// listening on the socket.
-func (b *UnixCommBridge) Write(p []byte) (int, error) {
-	s := string(p)
+type jsonProgressEncoder struct {
+	enc *json.Encoder
+}
 
-	b.last.Lock()
-	b.last.u = &s
-	b.last.Unlock()
+func (e *jsonProgressEncoder) Encode(u *ProgressUpdate) error {
+	if err := e.enc.Encode(u); err != nil {
+		return fmt.Errorf("unable to write progress update: %w", err)
+	}
+	return nil
+}
 
-	b.clients.Lock()
-	defer b.clients.Unlock()
-	for _, v := range b.clients.m {
-		v <- s
+func newProgressEncoder(format string, w io.Writer) (progressEncoder, error) {
+	switch format {
+	case "", FormatCSV:
+		return &csvProgressEncoder{w: csv.NewWriter(w)}, nil
+	case FormatJSON:
+		return &jsonProgressEncoder{enc: json.NewEncoder(w)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported progress format %q", format)
 	}
-	return len(p) * len(b.clients.m), nil
+}
 
+// negotiateProtocol answers the version "v" of the progress protocol requested by
+// the client on "conn", see "pwrapapi.ProgressProtocol". Clients that did not
+// request a version speak version 1, which is not answered.
+func negotiateProtocol(conn io.Writer, v string) error {
+	if v == "" {
+		return nil
+	}
+	version, err := pwrapapi.ParseProtocolVersion(v)
+	if err != nil {
+		fmt.Fprintf(conn, "error=%v\n", err)
+		return err
+	}
+	if version > pwrapapi.ProgressProtocol {
+		version = pwrapapi.ProgressProtocol
+	}
+	if version < 2 {
+		return nil
+	}
+	_, err = fmt.Fprintf(conn, "v=%d\n", version)
+	return err
 }
 
-type tx struct {
-	close func()
-	c     <-chan string
+// parseHeader parses a connection header in the form "key=value;key=value".
+func parseHeader(header string) map[string]string {
+	m := make(map[string]string)
+	for _, v := range strings.Split(strings.TrimSpace(header), ";") {
+		kv := strings.SplitN(v, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		m[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return m
 }
 
-func (b *UnixCommBridge) handleConn(ctx context.Context, conn net.Conn) {
+func (b *bridge) handleConn(ctx context.Context, conn net.Conn) {
 	defer conn.Close()
+	network := b.Addr().Network()
 	r := bufio.NewReader(conn)
 	header, err := r.ReadString('\n')
 	if err != nil {
-		log.Printf("[ERROR] handle unix conn: unable to read header: %v", err)
+		log.Printf("[ERROR] handle %s conn: unable to read header: %v", network, err)
+		return
+	}
+	h := parseHeader(header)
+	if b.token != "" && subtle.ConstantTimeCompare([]byte(h["token"]), []byte(b.token)) != 1 {
+		log.Printf("[ERROR] handle %s conn: connection %v did not present a valid token", network, conn.RemoteAddr().String())
 		return
 	}
-	log.Printf("[DEBUG] header read: %v", header)
-	switch {
-	case strings.Contains(header, "mode=command"):
-		if err := b.readCommand(ctx, r); err != nil {
+	delete(h, "token")
+	log.Printf("[DEBUG] header read: %v", h)
+	switch h["mode"] {
+	case "command":
+		resp, err := b.readCommand(ctx, r)
+		if err != nil {
 			log.Printf("[ERROR] unable to read command: %v", err)
 		}
-	case strings.Contains(header, "mode=progress"):
-		if err := b.writeUpdates(ctx, conn); err != nil {
+		if err := writeCommandResponse(conn, resp, err); err != nil {
+			log.Printf("[ERROR] unable to write command response: %v", err)
+		}
+	case "progress", "stream":
+		// Progress mode is the stream mode on the progress channel.
+		name := h["channel"]
+		if h["mode"] == "progress" || name == "" {
+			name = ChannelProgress
+		}
+		ch := b.lookupChannel(name)
+		if ch == nil {
+			log.Printf("[ERROR] handle %s conn: unknown channel %q", network, name)
+			return
+		}
+		enc, err := newProgressEncoder(h["format"], conn)
+		if err != nil {
+			log.Printf("[ERROR] handle %s conn: %v", network, err)
+			return
+		}
+		if h["mode"] == "progress" {
+			if err := negotiateProtocol(conn, h["v"]); err != nil {
+				log.Printf("[ERROR] handle %s conn: %v", network, err)
+				return
+			}
+		}
+		if err := b.writeUpdates(ctx, conn, ch, enc, b.heartbeat); err != nil {
 			log.Printf("[ERROR] unable to write update to connection %v: %v", conn.RemoteAddr().String(), err)
 		}
 	default:
-		log.Printf("[ERROR] handle unix conn: unrecognised header \"%s\"", header)
+		log.Printf("[ERROR] handle %s conn: unrecognised header \"%s\"", network, header)
 		return
 	}
 }
 
-func (b *UnixCommBridge) getTx() *tx {
-	c := make(chan string, 1)
-
-	b.last.Lock()
-	// generate a timestamp key inside the lock, so we're ensured to receive a unique one.
-	key := fmt.Sprintf("%d", time.Now().UnixNano())
-	if b.last.u != nil {
-		c <- *b.last.u
+// channel returns the named channel, creating it if needed.
+func (b *bridge) channel(name string) *channel {
+	b.channels.Lock()
+	defer b.channels.Unlock()
+	if b.channels.m == nil {
+		b.channels.m = make(map[string]*channel)
 	}
-	b.last.Unlock()
-
-	b.clients.Lock()
-	if b.clients.m == nil {
-		b.clients.m = make(map[string]chan string)
-	}
-	b.clients.m[key] = c
-	b.clients.Unlock()
-
-	return &tx{
-		c: c,
-		close: func() {
-			close(c)
-			b.clients.Lock()
-			delete(b.clients.m, key)
-			b.clients.Unlock()
-		},
+	c, ok := b.channels.m[name]
+	if !ok {
+		c = newChannel(b.replay)
+		b.channels.m[name] = c
 	}
+	return c
 }
 
-func (b *UnixCommBridge) writeUpdates(ctx context.Context, w io.Writer) error {
-	c := b.getTx()
+// lookupChannel returns the named channel, nil if it does not exist. Unlike
+// "channel", it is used on behalf of the clients, which cannot create channels.
+func (b *bridge) lookupChannel(name string) *channel {
+	b.channels.Lock()
+	defer b.channels.Unlock()
+	return b.channels.m[name]
+}
 
+// writeUpdates delivers the frames of "ch" to "w", encoding the progress updates
+// with "enc". An empty line is written every "interval" without any frame, unless
+// it is zero.
+func (b *bridge) writeUpdates(ctx context.Context, w io.Writer, ch *channel, enc progressEncoder, interval time.Duration) error {
+	c := ch.subscribe(b.queueSize)
 	defer c.close()
+
+	var heartbeat <-chan time.Time
+	if interval > 0 {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		heartbeat = t.C
+	}
+
+	idle := true
 	for {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		case u := <-c.c:
+		case <-c.kicked:
+			return fmt.Errorf("client disconnected: too slow in consuming updates")
+		case <-heartbeat:
+			if !idle {
+				idle = true
+				continue
+			}
+			if _, err := w.Write([]byte("\n")); err != nil {
+				return err
+			}
+		case f := <-c.c:
+			idle = false
 			// Note: If the connection is closed, we will not be able to detect it
 			// util the next time that we try to write something into it.
-			if _, err := w.Write([]byte(u)); err != nil {
+			if f.update != nil {
+				if err := enc.Encode(f.update); err != nil {
+					return err
+				}
+				continue
+			}
+			if _, err := w.Write(f.raw); err != nil {
 				return err
 			}
 		}
 	}
 }
 
-func (b *UnixCommBridge) readCommand(ctx context.Context, r *bufio.Reader) error {
+func (b *bridge) readCommand(ctx context.Context, r *bufio.Reader) (string, error) {
 	if b.onCommand == nil {
-		return fmt.Errorf("no command handler has been configured")
+		return "", fmt.Errorf("no command handler has been configured")
 	}
 
 	cmd, err := r.ReadString('\n')
 	if err != nil {
-		return fmt.Errorf("unable to read command: %w", err)
+		return "", fmt.Errorf("unable to read command: %w", err)
 	}
 
 	log.Printf("[INFO] command read: %v", cmd)
-	return b.onCommand(b, strings.TrimRight(cmd, "\n"))
+	return b.runCommand(strings.TrimRight(cmd, "\n"))
+}
+
+// runCommand delivers "cmd" to the command handler, returning its response.
+func (b *bridge) runCommand(cmd string) (string, error) {
+	if b.onCommand == nil {
+		return "", fmt.Errorf("no command handler has been configured")
+	}
+	return b.onCommand(b.self, cmd)
+}
+
+func writeCommandResponse(w io.Writer, resp string, err error) error {
+	payload := CommandResponse{OK: err == nil, Response: resp}
+	if err != nil {
+		payload.Error = err.Error()
+	}
+	return json.NewEncoder(w).Encode(&payload)
 }
@@ -0,0 +1,454 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kim-company/pmux/http/pwrapapi"
+)
+
+func newTestBridge(t *testing.T, opts ...CommBridgeOption) (*UnixCommBridge, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	path := filepath.Join(os.TempDir(), "pwrap-test-"+uuid.New().String()+".sock")
+	b, err := NewUnixCommBridge(ctx, path, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go b.Open(ctx)
+	return b, func() {
+		cancel()
+		b.Close()
+	}
+}
+
+func dialBridge(t *testing.T, b *UnixCommBridge, header string) (net.Conn, *bufio.Reader) {
+	conn, err := net.Dial("unix", b.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Write([]byte(header + "\n")); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second * 2))
+	return conn, bufio.NewReader(conn)
+}
+
+// waitClients waits until "n" progress clients are registered on the bridge.
+func waitClients(t *testing.T, b *bridge, n int) {
+	for i := 0; i < 100; i++ {
+		if b.channel(ChannelProgress).len() == n {
+			return
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	t.Fatalf("Timeout waiting for %d clients", n)
+}
+
+func TestWriteProgressUpdate_Formats(t *testing.T) {
+	t.Parallel()
+
+	b, close := newTestBridge(t)
+	defer close()
+
+	csvConn, csvR := dialBridge(t, b, "mode=progress")
+	defer csvConn.Close()
+	jsonConn, jsonR := dialBridge(t, b, "mode=progress;format=json")
+	defer jsonConn.Close()
+	waitClients(t, b.bridge, 2)
+
+	if err := b.WriteProgressUpdate("working", 1, 2, 3, 4); err != nil {
+		t.Fatal(err)
+	}
+
+	header, err := csvR.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header != "DESCRIPTION,STAGE,STAGES,PARTIAL,TOTAL\n" {
+		t.Fatalf("Unexpected csv header: %q", header)
+	}
+	line, err := csvR.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != "working,1,2,3,4\n" {
+		t.Fatalf("Unexpected csv update: %q", line)
+	}
+
+	var u ProgressUpdate
+	if err := json.NewDecoder(jsonR).Decode(&u); err != nil {
+		t.Fatal(err)
+	}
+	exp := ProgressUpdate{Description: "working", Stage: 1, Stages: 2, Partial: 3, Total: 4}
+	if !reflect.DeepEqual(u, exp) {
+		t.Fatalf("Wanted %+v, found %+v", exp, u)
+	}
+}
+
+func TestReadCommand_Response(t *testing.T) {
+	t.Parallel()
+
+	b, close := newTestBridge(t, OnCommandResponse(func(u CommBridge, cmd string) (string, error) {
+		if cmd != "ping" {
+			return "", fmt.Errorf("unknown command %q", cmd)
+		}
+		return "pong", nil
+	}))
+	defer close()
+
+	tt := []struct {
+		cmd string
+		exp CommandResponse
+	}{
+		{"ping", CommandResponse{OK: true, Response: "pong"}},
+		{"cancel", CommandResponse{OK: false, Error: "unknown command \"cancel\""}},
+	}
+	for _, v := range tt {
+		conn, r := dialBridge(t, b, "mode=command")
+		if _, err := conn.Write([]byte(v.cmd + "\n")); err != nil {
+			t.Fatal(err)
+		}
+		var resp CommandResponse
+		if err := json.NewDecoder(r).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+		if resp != v.exp {
+			t.Fatalf("Wanted %+v, found %+v", v.exp, resp)
+		}
+	}
+}
+
+func TestWriteUpdates_Heartbeat(t *testing.T) {
+	t.Parallel()
+
+	b, close := newTestBridge(t, HeartbeatInterval(time.Millisecond*20))
+	defer close()
+
+	conn, r := dialBridge(t, b, "mode=progress")
+	defer conn.Close()
+
+	line, err := r.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != "\n" {
+		t.Fatalf("Unexpected heartbeat frame: %q", line)
+	}
+}
+
+func TestClientPush_LaggardPolicy(t *testing.T) {
+	t.Parallel()
+
+	f1, f2 := &frame{raw: []byte("1")}, &frame{raw: []byte("2")}
+	tt := []struct {
+		policy LaggardPolicy
+		ok     bool
+		exp    *frame
+	}{
+		{DropOldest, true, f2},
+		{DropNewest, false, f1},
+		{Disconnect, false, f1},
+	}
+	for _, v := range tt {
+		c := &client{c: make(chan *frame, 1), kicked: make(chan struct{})}
+		if !c.push(f1, v.policy) {
+			t.Fatalf("Policy %v: first push SHOULD succeed", v.policy)
+		}
+		if ok := c.push(f2, v.policy); ok != v.ok {
+			t.Fatalf("Policy %v: wanted %v, found %v", v.policy, v.ok, ok)
+		}
+		if f := <-c.c; f != v.exp {
+			t.Fatalf("Policy %v: wanted frame %s, found %s", v.policy, v.exp.raw, f.raw)
+		}
+	}
+}
+
+func TestBroadcast_Disconnect(t *testing.T) {
+	t.Parallel()
+
+	b, close := newTestBridge(t, QueueSize(1), Laggards(Disconnect), HeartbeatInterval(0))
+	defer close()
+
+	// Register a client that never consumes its queue.
+	tx := b.channel(ChannelProgress).subscribe(b.queueSize)
+	defer tx.close()
+
+	b.WriteProgressUpdate("first", 0, 0, 0, 0)
+	b.WriteProgressUpdate("second", 0, 0, 0, 0)
+
+	select {
+	case <-tx.kicked:
+	default:
+		t.Fatal("Laggard client SHOULD have been kicked")
+	}
+}
+
+func TestNewCommBridge_TCP(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	b, err := NewCommBridge(ctx, TransportTCP, "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	go b.Open(ctx)
+
+	conn, err := net.Dial("tcp", b.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("mode=progress;format=json\n"))
+	conn.SetReadDeadline(time.Now().Add(time.Second * 2))
+
+	// Wait for the connection to be registered before writing.
+	waitClients(t, b.(*TCPCommBridge).bridge, 1)
+	b.WriteProgressUpdate("tcp", 0, 0, 0, 0)
+
+	var u ProgressUpdate
+	if err := json.NewDecoder(conn).Decode(&u); err != nil {
+		t.Fatal(err)
+	}
+	if u.Description != "tcp" {
+		t.Fatalf("Unexpected update: %+v", u)
+	}
+}
+
+func TestHandleConn_AuthToken(t *testing.T) {
+	t.Parallel()
+
+	b, close := newTestBridge(t, AuthToken("secret"), OnCommandResponse(func(u CommBridge, cmd string) (string, error) {
+		return "ok", nil
+	}))
+	defer close()
+
+	for _, v := range []string{"mode=command", "mode=command;token=wrong"} {
+		conn, r := dialBridge(t, b, v)
+		conn.Write([]byte("cancel\n"))
+		if _, err := r.ReadString('\n'); err == nil {
+			t.Fatalf("Header %q: connection SHOULD have been rejected", v)
+		}
+		conn.Close()
+	}
+
+	conn, r := dialBridge(t, b, "mode=command;token=secret")
+	defer conn.Close()
+	conn.Write([]byte("cancel\n"))
+	var resp CommandResponse
+	if err := json.NewDecoder(r).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if !resp.OK {
+		t.Fatalf("Unexpected response: %+v", resp)
+	}
+}
+
+func TestNewUnixCommBridge_Permissions(t *testing.T) {
+	t.Parallel()
+
+	b, close := newTestBridge(t, SocketMode(0660), SocketGroup(strconv.Itoa(os.Getgid())))
+	defer close()
+
+	info, err := os.Stat(b.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0660 {
+		t.Fatalf("Wanted socket mode 0660, found %#o", mode)
+	}
+
+	path := filepath.Join(os.TempDir(), "pwrap-test-"+uuid.New().String()+".sock")
+	if _, err := NewUnixCommBridge(context.Background(), path, SocketGroup("pmux-missing-group")); err == nil {
+		t.Fatal("Bridges owned by missing groups SHOULD NOT be started")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("The socket of bridges that cannot be started SHOULD be removed: %v", err)
+	}
+}
+
+func TestGetTx_Replay(t *testing.T) {
+	t.Parallel()
+
+	b, close := newTestBridge(t, ReplaySize(3), HeartbeatInterval(0))
+	defer close()
+
+	for i := 0; i < 5; i++ {
+		b.WriteProgressUpdate("update", 0, 0, i, 0)
+	}
+
+	tx := b.channel(ChannelProgress).subscribe(b.queueSize)
+	defer tx.close()
+	for i := 2; i < 5; i++ {
+		f := <-tx.c
+		if f.update.Partial != i {
+			t.Fatalf("Wanted replayed update %d, found %d", i, f.update.Partial)
+		}
+	}
+	select {
+	case f := <-tx.c:
+		t.Fatalf("Unexpected replayed frame: %+v", f.update)
+	default:
+	}
+}
+
+func TestWriteProgress_JSONFields(t *testing.T) {
+	t.Parallel()
+
+	b, close := newTestBridge(t)
+	defer close()
+
+	conn, r := dialBridge(t, b, "mode=progress;format=json")
+	defer conn.Close()
+	waitClients(t, b.bridge, 1)
+
+	eta := time.Date(2020, 1, 8, 15, 24, 23, 0, time.UTC)
+	labels := map[string]string{"codec": "h264"}
+	if err := b.WriteProgress(&ProgressUpdate{
+		Description: "encoding",
+		Partial:     10,
+		Total:       40,
+		StageName:   "transcode",
+		Unit:        "frames",
+		ETA:         &eta,
+		Labels:      labels,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// Changes made by the caller after the write must not be delivered.
+	labels["codec"] = "vp9"
+
+	var u ProgressUpdate
+	if err := json.NewDecoder(r).Decode(&u); err != nil {
+		t.Fatal(err)
+	}
+	if u.StageName != "transcode" || u.Unit != "frames" || !u.ETA.Equal(eta) || u.Labels["codec"] != "h264" {
+		t.Fatalf("Unexpected update: %+v", u)
+	}
+	if p := u.Percent(); p != 25 {
+		t.Fatalf("Wanted 25%%, found %v", p)
+	}
+}
+
+func TestChannel_Stream(t *testing.T) {
+	t.Parallel()
+
+	b, close := newTestBridge(t, HeartbeatInterval(0), Channels("logs"))
+	defer close()
+
+	logs, logsR := dialBridge(t, b, "mode=stream;channel=logs")
+	defer logs.Close()
+	progress, _ := dialBridge(t, b, "mode=progress")
+	defer progress.Close()
+	waitClients(t, b.bridge, 1)
+	for i := 0; i < 100 && b.channel("logs").len() != 1; i++ {
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	if _, err := b.Channel("logs").Write([]byte("a log line\n")); err != nil {
+		t.Fatal(err)
+	}
+	line, err := logsR.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != "a log line\n" {
+		t.Fatalf("Unexpected log line: %q", line)
+	}
+	if b.channel(ChannelProgress).frames != nil {
+		t.Fatal("Log frames SHOULD NOT be delivered on the progress channel")
+	}
+}
+
+func TestChannel_Unknown(t *testing.T) {
+	t.Parallel()
+
+	b, close := newTestBridge(t, HeartbeatInterval(0))
+	defer close()
+
+	conn, r := dialBridge(t, b, "mode=stream;channel=unknown")
+	defer conn.Close()
+	if _, err := r.ReadString('\n'); err != io.EOF {
+		t.Fatalf("Clients of unknown channels SHOULD be disconnected, found %v", err)
+	}
+	if b.lookupChannel("unknown") != nil {
+		t.Fatal("Clients SHOULD NOT create channels")
+	}
+	conn, r = dialBridge(t, b, "mode=stream;channel="+ChannelResult)
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(time.Millisecond * 200))
+	if _, err := r.ReadString('\n'); err == io.EOF {
+		t.Fatal("Clients of the result channel SHOULD NOT be disconnected")
+	}
+}
+
+func TestHandleConn_ProtocolVersion(t *testing.T) {
+	t.Parallel()
+
+	b, close := newTestBridge(t)
+	defer close()
+
+	for _, tt := range []struct {
+		header, answer string
+	}{
+		{"mode=progress;v=2", "v=2\n"},
+		// Newer clients are answered with the latest version known.
+		{"mode=progress;v=9", "v=2\n"},
+		{"mode=progress;v=zero", "error=invalid progress protocol version \"zero\"\n"},
+	} {
+		conn, r := dialBridge(t, b, tt.header)
+		answer, err := r.ReadString('\n')
+		conn.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.header, err)
+		}
+		if answer != tt.answer {
+			t.Fatalf("%s: wanted answer %q, found %q", tt.header, tt.answer, answer)
+		}
+	}
+}
+
+func TestBridge_OpenProgress(t *testing.T) {
+	t.Parallel()
+
+	b, close := newTestBridge(t)
+	defer close()
+
+	conn, err := pwrapapi.Bridge{Dial: pwrapapi.NewDialer(TransportUnix, b.path)}.OpenProgress(FormatJSON, pwrapapi.ProgressProtocol)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if conn.Version != 2 {
+		t.Fatalf("Bridges SHOULD speak version 2, found %d", conn.Version)
+	}
+	waitClients(t, b.bridge, 1)
+	if err := b.WriteProgressUpdate("working", 1, 2, 3, 4); err != nil {
+		t.Fatal(err)
+	}
+	var u ProgressUpdate
+	if err := json.NewDecoder(conn).Decode(&u); err != nil {
+		t.Fatal(err)
+	}
+	if u.Description != "working" {
+		t.Fatalf("Unexpected update %+v", u)
+	}
+}
@@ -0,0 +1,137 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// EventType identifies the session lifecycle events delivered to webhooks.
+type EventType string
+
+const (
+	EventCreated EventType = "session.created"
+	EventStarted           = "session.started"
+	// EventProgressed is delivered every time the progress of the session
+	// reaches a new milestone, i.e. a multiple of "ProgressMilestone".
+	EventProgressed = "session.progressed"
+	EventFinished   = "session.finished"
+	EventDeleted    = "session.deleted"
+	// EventPaused and EventResumed are delivered when the child of the
+	// session is paused and resumed.
+	EventPaused  = "session.paused"
+	EventResumed = "session.resumed"
+)
+
+// ProgressMilestone is the percentage step at which progress events are delivered.
+const ProgressMilestone = 10
+
+// WebhookTimeout is the maximum time waited for a webhook to respond.
+const WebhookTimeout = time.Second * 10
+
+// Event is the payload delivered to webhooks.
+type Event struct {
+	Type    EventType `json:"type"`
+	SID     string    `json:"sid"`
+	Time    time.Time `json:"time"`
+	Session *Session  `json:"session,omitempty"`
+}
+
+// NewEvent returns an event of type "t" about the session "s", which may be nil.
+func NewEvent(t EventType, sid string, s *Session) *Event {
+	if s != nil {
+		// The token grants access to the wrapper API, while the
+		// headers may carry the credentials of the receiver.
+		c := *s
+		c.APIToken, c.RegisterHeaders = "", nil
+		s = &c
+	}
+	return &Event{Type: t, SID: sid, Time: time.Now(), Session: s}
+}
+
+// Hooks is a list of webhook URLs that receive session lifecycle events. Event bus
+// URLs are supported too, see "SchemeNATS".
+type Hooks []string
+
+// Send delivers "e" to every webhook with an HTTP POST request, or publishes it on
+// the event bus. Delivery errors are logged but otherwise ignored.
+func (h Hooks) Send(e *Event) {
+	if len(h) == 0 {
+		return
+	}
+	buf := bytes.Buffer{}
+	if err := json.NewEncoder(&buf).Encode(e); err != nil {
+		log.Printf("[ERROR] unable to encode %s event: %v", e.Type, err)
+		return
+	}
+	client := &http.Client{Timeout: WebhookTimeout}
+	for _, v := range h {
+		var err error
+		if u, perr := url.Parse(v); perr == nil && (u.Scheme == SchemeNATS || u.Scheme == SchemeRedis) {
+			err = publishEvent(u, e, buf.Bytes())
+			// Do not log credentials.
+			v = u.Redacted()
+		} else {
+			err = postEvent(client, v, buf.Bytes())
+		}
+		if err != nil {
+			log.Printf("[WARN] unable to deliver %s event to %s: %v", e.Type, v, err)
+		}
+	}
+}
+
+func postEvent(client *http.Client, url string, payload []byte) error {
+	resp, err := client.Post(url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("status code returned is: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// lifecycle derives the lifecycle events from the successive states of a session.
+type lifecycle struct {
+	state     SessionState
+	milestone int
+}
+
+// next returns the event triggered by the transition to "s", if any.
+func (l *lifecycle) next(s *Session) (EventType, bool) {
+	prev := l.state
+	l.state = s.State
+	switch {
+	case s.State == SessionPaused && prev != SessionPaused:
+		return EventPaused, true
+	case s.State == SessionRunning && prev == SessionPaused:
+		return EventResumed, true
+	case s.State == SessionRunning && prev != SessionRunning:
+		return EventStarted, true
+	case (s.State == SessionExited || s.State == SessionFailed || s.State == SessionTimedOut) && s.State != prev:
+		return EventFinished, true
+	case s.State == SessionRunning && s.LastProgress != nil:
+		p := s.LastProgress.Percent()
+		if p < 0 {
+			return "", false
+		}
+		m := int(p) / ProgressMilestone * ProgressMilestone
+		if m > l.milestone {
+			l.milestone = m
+			return EventProgressed, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,67 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package pwrap
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHooks_Send(t *testing.T) {
+	t.Parallel()
+
+	events := make(chan Event, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e Event
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+			t.Error(err)
+		}
+		events <- e
+	}))
+	defer srv.Close()
+
+	Hooks{srv.URL}.Send(NewEvent(EventCreated, "pmux-test", &Session{SID: "pmux-test", APIToken: "secret"}))
+	e := <-events
+	if e.Type != EventCreated || e.SID != "pmux-test" || e.Session == nil {
+		t.Fatalf("Unexpected event: %+v", e)
+	}
+	if e.Session.APIToken != "" {
+		t.Fatalf("Events SHOULD NOT leak the API token")
+	}
+}
+
+func TestLifecycle_Next(t *testing.T) {
+	t.Parallel()
+
+	var lc lifecycle
+	progress := func(partial int) *ProgressUpdate {
+		return &ProgressUpdate{Stage: -1, Stages: -1, Partial: partial, Total: 100}
+	}
+	for i, tt := range []struct {
+		session *Session
+		event   EventType
+	}{
+		{&Session{State: SessionCreated}, ""},
+		{&Session{State: SessionRunning}, EventStarted},
+		{&Session{State: SessionRunning, LastProgress: progress(5)}, ""},
+		{&Session{State: SessionRunning, LastProgress: progress(12)}, EventProgressed},
+		{&Session{State: SessionRunning, LastProgress: progress(19)}, ""},
+		{&Session{State: SessionRunning, LastProgress: progress(45)}, EventProgressed},
+		{&Session{State: SessionPaused, LastProgress: progress(45)}, EventPaused},
+		{&Session{State: SessionPaused, LastProgress: progress(45)}, ""},
+		{&Session{State: SessionRunning, LastProgress: progress(45)}, EventResumed},
+		{&Session{State: SessionFailed}, EventFinished},
+		{&Session{State: SessionFailed}, ""},
+		{&Session{State: SessionRunning}, EventStarted},
+		{&Session{State: SessionTimedOut}, EventFinished},
+	} {
+		e, _ := lc.next(tt.session)
+		if e != tt.event {
+			t.Fatalf("%d: wanted event %q, found %q", i, tt.event, e)
+		}
+	}
+}
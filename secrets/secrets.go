@@ -0,0 +1,166 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+// Package secrets resolves references to secrets, e.g. "vault:secret/data/db#password",
+// into their values, so that sessions can receive credentials without storing
+// them in their configuration. A reference is made of the scheme selecting its
+// provider, followed by the location of the secret inside it.
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Provider resolves the references of a scheme.
+type Provider interface {
+	// Resolve returns the value of the secret at "loc", the reference
+	// without its scheme.
+	Resolve(ctx context.Context, loc string) (string, error)
+}
+
+var (
+	mu        sync.RWMutex
+	providers = map[string]Provider{
+		"env":   Env{},
+		"file":  File{},
+		"vault": &Vault{},
+	}
+)
+
+// Register makes "p" resolve the references of "scheme", replacing the provider
+// registered before, if any.
+func Register(scheme string, p Provider) {
+	mu.Lock()
+	defer mu.Unlock()
+	providers[scheme] = p
+}
+
+// Schemes returns the schemes that have a provider, sorted.
+func Schemes() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	acc := make([]string, 0, len(providers))
+	for k := range providers {
+		acc = append(acc, k)
+	}
+	sort.Strings(acc)
+	return acc
+}
+
+// provider returns the provider of reference "ref" and the location of the secret.
+func provider(ref string) (Provider, string, error) {
+	kv := strings.SplitN(ref, ":", 2)
+	if len(kv) != 2 || kv[1] == "" {
+		return nil, "", fmt.Errorf("invalid secret reference %q, expected scheme:location", ref)
+	}
+	mu.RLock()
+	p, ok := providers[kv[0]]
+	mu.RUnlock()
+	if !ok {
+		return nil, "", fmt.Errorf("invalid secret reference %q: unsupported scheme %q", ref, kv[0])
+	}
+	return p, kv[1], nil
+}
+
+// Validate reports whether "ref" is a reference that can be resolved, without
+// resolving it.
+func Validate(ref string) error {
+	_, _, err := provider(ref)
+	return err
+}
+
+// Resolve returns the value of the secret referenced by "ref".
+func Resolve(ctx context.Context, ref string) (string, error) {
+	p, loc, err := provider(ref)
+	if err != nil {
+		return "", err
+	}
+	v, err := p.Resolve(ctx, loc)
+	if err != nil {
+		return "", fmt.Errorf("unable to resolve secret %q: %w", ref, err)
+	}
+	return v, nil
+}
+
+var envName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ValidateRefs reports whether "refs", mapping environment variable names to
+// secret references, can be injected into the environment of a process.
+func ValidateRefs(refs map[string]string) error {
+	for k, v := range refs {
+		if !envName.MatchString(k) {
+			return fmt.Errorf("invalid secret environment variable name %q", k)
+		}
+		if err := Validate(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParseRefs parses references in the NAME=reference form.
+func ParseRefs(kvs []string) (map[string]string, error) {
+	refs := make(map[string]string, len(kvs))
+	for _, v := range kvs {
+		kv := strings.SplitN(v, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid secret %q, expected NAME=reference", v)
+		}
+		refs[kv[0]] = kv[1]
+	}
+	if err := ValidateRefs(refs); err != nil {
+		return nil, err
+	}
+	return refs, nil
+}
+
+// Environ resolves "refs", returning the resulting environment variables in the
+// NAME=value form, sorted by name.
+func Environ(ctx context.Context, refs map[string]string) ([]string, error) {
+	names := make([]string, 0, len(refs))
+	for k := range refs {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	env := make([]string, 0, len(refs))
+	for _, k := range names {
+		v, err := Resolve(ctx, refs[k])
+		if err != nil {
+			return nil, err
+		}
+		env = append(env, k+"="+v)
+	}
+	return env, nil
+}
+
+// Env resolves the "env:NAME" references, reading the environment of the process.
+type Env struct{}
+
+// Resolve implements Provider.
+func (Env) Resolve(_ context.Context, name string) (string, error) {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return v, nil
+}
+
+// File resolves the "file:/path" references, reading the contents of the file
+// without its trailing newline, e.g. Docker and Kubernetes secrets.
+type File struct{}
+
+// Resolve implements Provider.
+func (File) Resolve(_ context.Context, path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
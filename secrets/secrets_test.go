@@ -0,0 +1,116 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package secrets
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidateRefs(t *testing.T) {
+	t.Parallel()
+
+	for i, tt := range []struct {
+		refs map[string]string
+		ok   bool
+	}{
+		{map[string]string{"DB_PASSWORD": "env:PASSWORD"}, true},
+		{map[string]string{"TOKEN": "file:/run/secrets/token", "KEY": "vault:secret/data/app#key"}, true},
+		{map[string]string{"1TOKEN": "env:TOKEN"}, false},
+		{map[string]string{"TOKEN": "TOKEN"}, false},
+		{map[string]string{"TOKEN": "env:"}, false},
+		{map[string]string{"TOKEN": "s3:bucket/token"}, false},
+	} {
+		err := ValidateRefs(tt.refs)
+		if tt.ok && err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		if !tt.ok && err == nil {
+			t.Fatalf("%d: references %v SHOULD NOT be valid", i, tt.refs)
+		}
+	}
+}
+
+func TestEnviron(t *testing.T) {
+	t.Parallel()
+
+	dir, err := os.MkdirTemp("", "pmux-secrets-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "token")
+	if err := os.WriteFile(path, []byte("s3cr3t\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("PMUX_SECRETS_TEST", "hunter2")
+	defer os.Unsetenv("PMUX_SECRETS_TEST")
+
+	env, err := Environ(context.Background(), map[string]string{
+		"TOKEN":    "file:" + path,
+		"PASSWORD": "env:PMUX_SECRETS_TEST",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(env) != 2 || env[0] != "PASSWORD=hunter2" || env[1] != "TOKEN=s3cr3t" {
+		t.Fatalf("unexpected environment: %v", env)
+	}
+	if _, err := Environ(context.Background(), map[string]string{"X": "env:PMUX_SECRETS_TEST_UNSET"}); err == nil {
+		t.Fatal("unset environment variables SHOULD NOT be resolved")
+	}
+}
+
+func TestVault_Resolve(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/app":
+			w.Write([]byte(`{"data":{"data":{"password":"v2"},"metadata":{"version":1}}}`))
+		case "/v1/kv/app":
+			w.Write([]byte(`{"data":{"password":"v1","port":5432}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	v := &Vault{Addr: srv.URL, Token: "root"}
+	for i, tt := range []struct {
+		loc   string
+		value string
+		ok    bool
+	}{
+		{"secret/data/app#password", "v2", true},
+		{"kv/app#password", "v1", true},
+		{"kv/app#port", "5432", true},
+		{"kv/app#user", "", false},
+		{"kv/missing#password", "", false},
+		{"kv/app", "", false},
+	} {
+		value, err := v.Resolve(context.Background(), tt.loc)
+		if tt.ok && err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		if !tt.ok && err == nil {
+			t.Fatalf("%d: %s SHOULD NOT be resolved", i, tt.loc)
+		}
+		if value != tt.value {
+			t.Fatalf("%d: wanted %q, found %q", i, tt.value, value)
+		}
+	}
+	if _, err := (&Vault{Addr: srv.URL}).Resolve(context.Background(), "kv/app#password"); err == nil {
+		t.Fatal("unauthenticated requests SHOULD fail")
+	}
+}
@@ -0,0 +1,97 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Vault resolves the "vault:path#key" references, reading field "key" of the
+// secret at "path" of a HashiCorp Vault server, e.g.
+// "vault:secret/data/db#password". Both versions of the KV secrets engine are
+// supported.
+type Vault struct {
+	// Addr is the address of the server. Defaults to $VAULT_ADDR.
+	Addr string
+	// Token authenticates the requests. Defaults to $VAULT_TOKEN.
+	Token string
+	// Client performs the requests. Defaults to a client with a 10s timeout.
+	Client *http.Client
+}
+
+var vaultClient = &http.Client{Timeout: time.Second * 10}
+
+// Resolve implements Provider.
+func (v *Vault) Resolve(ctx context.Context, loc string) (string, error) {
+	kv := strings.SplitN(loc, "#", 2)
+	if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+		return "", fmt.Errorf("invalid vault location %q, expected path#key", loc)
+	}
+	addr, token, client := v.Addr, v.Token, v.Client
+	if addr == "" {
+		addr = os.Getenv("VAULT_ADDR")
+	}
+	if token == "" {
+		token = os.Getenv("VAULT_TOKEN")
+	}
+	if client == nil {
+		client = vaultClient
+	}
+	if addr == "" {
+		return "", fmt.Errorf("vault address not set")
+	}
+
+	url := strings.TrimSuffix(addr, "/") + "/v1/" + strings.TrimPrefix(kv[0], "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("vault returned %s: %s", resp.Status, strings.TrimSpace(string(b)))
+	}
+
+	var body struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("unable to decode vault response: %w", err)
+	}
+	data := body.Data
+	// Secrets of the KV version 2 engine are wrapped, together with their
+	// metadata.
+	if inner, ok := data["data"].(map[string]interface{}); ok {
+		if _, ok := data["metadata"]; ok {
+			data = inner
+		}
+	}
+	field, ok := data[kv[1]]
+	if !ok {
+		return "", fmt.Errorf("key %q not found in %s", kv[1], kv[0])
+	}
+	if s, ok := field.(string); ok {
+		return s, nil
+	}
+	b, err := json.Marshal(field)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,64 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package tail
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+)
+
+// CompressedExt is the extension of the gzip compressed copies of the files,
+// which "File" reads when the files themselves are not found.
+const CompressedExt = ".gz"
+
+// Compressed writes the last lines of the gzip compressed content of "r" into
+// "w", as described by "opts". Compressed files are not written anymore, hence
+// they are never followed.
+func Compressed(w io.Writer, r io.Reader, opts Options) error {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	if opts.Lines < 0 {
+		if _, err := io.Copy(w, zr); err != nil {
+			return err
+		}
+		if opts.Flush != nil {
+			opts.Flush()
+		}
+		return nil
+	}
+
+	// Lines are read in full, as where the last ones start is not known
+	// before the end of the content.
+	var lines [][]byte
+	br := bufio.NewReader(zr)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 && opts.Lines > 0 {
+			if len(lines) == opts.Lines {
+				lines = lines[1:]
+			}
+			lines = append(lines, line)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for _, v := range lines {
+		if _, err := w.Write(v); err != nil {
+			return err
+		}
+	}
+	if opts.Flush != nil && len(lines) > 0 {
+		opts.Flush()
+	}
+	return nil
+}
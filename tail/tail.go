@@ -0,0 +1,160 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+// Package tail provides "tail -f" like functionalities on the files written
+// by wrapped processes, such as their stdout and stderr.
+package tail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// PollInterval is the interval at which followed files are checked for new content.
+var PollInterval = time.Millisecond * 250
+
+const chunkSize = 4096
+
+// Offset returns the offset in "f" where its last "n" lines start. Negative values
+// of "n" return the beginning of the file.
+func Offset(f io.ReadSeeker, n int) (int64, error) {
+	if n < 0 {
+		return 0, nil
+	}
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return size, nil
+	}
+
+	buf := make([]byte, chunkSize)
+	pos := size
+	lines := 0
+	for pos > 0 {
+		l := int64(len(buf))
+		if pos < l {
+			l = pos
+		}
+		pos -= l
+		if _, err := f.Seek(pos, io.SeekStart); err != nil {
+			return 0, err
+		}
+		if _, err := io.ReadFull(f, buf[:l]); err != nil {
+			return 0, err
+		}
+		for i := l - 1; i >= 0; i-- {
+			if buf[i] != '\n' {
+				continue
+			}
+			// A newline at the very end of the file does not
+			// start a new line.
+			if pos+i == size-1 {
+				continue
+			}
+			lines++
+			if lines == n {
+				return pos + i + 1, nil
+			}
+		}
+	}
+	return 0, nil
+}
+
+// Options describes how a file should be tailed.
+type Options struct {
+	// Lines is the number of trailing lines to be written. Negative values
+	// write the whole file.
+	Lines int
+	// Follow keeps on writing the content appended to the file until the
+	// context is canceled.
+	Follow bool
+	// Flush, if not nil, is called each time new content is written.
+	Flush func()
+}
+
+// File writes the last lines of the file at "path" into "w", following it if requested.
+// If the file does not exist but its compressed copy does, the copy is read instead,
+// see "Compressed".
+func File(ctx context.Context, w io.Writer, path string, opts Options) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		if c, cerr := os.Open(path + CompressedExt); cerr == nil {
+			defer c.Close()
+			if err := Compressed(w, c, opts); err != nil {
+				return fmt.Errorf("unable to tail %v: %w", c.Name(), err)
+			}
+			return nil
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("unable to tail %v: %w", path, err)
+	}
+	defer f.Close()
+	return Reader(ctx, w, f, opts)
+}
+
+// Reader is like File, but works on an already opened file.
+func Reader(ctx context.Context, w io.Writer, f io.ReadSeeker, opts Options) error {
+	off, err := Offset(f, opts.Lines)
+	if err != nil {
+		return fmt.Errorf("unable to find tail offset: %w", err)
+	}
+	if _, err := f.Seek(off, io.SeekStart); err != nil {
+		return err
+	}
+	flush := func() {
+		if opts.Flush != nil {
+			opts.Flush()
+		}
+	}
+
+	pos := off
+	for {
+		n, err := io.Copy(w, f)
+		if err != nil {
+			return err
+		}
+		pos += n
+		if n > 0 {
+			flush()
+		}
+		if !opts.Follow {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(PollInterval):
+		}
+
+		// Start over if the file was truncated in the meantime.
+		size, err := f.Seek(0, io.SeekEnd)
+		if err != nil {
+			return err
+		}
+		if size < pos {
+			pos = 0
+		}
+		if _, err := f.Seek(pos, io.SeekStart); err != nil {
+			return err
+		}
+	}
+}
+
+// Lines returns the last "n" lines of the file at "path".
+func Lines(path string, n int) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := File(context.Background(), buf, path, Options{Lines: n}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
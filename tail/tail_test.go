@@ -0,0 +1,108 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package tail
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOffset(t *testing.T) {
+	t.Parallel()
+
+	long := strings.Repeat("x", chunkSize+10)
+	tt := []struct {
+		content string
+		n       int
+		exp     string
+	}{
+		{"a\nb\nc\n", 2, "b\nc\n"},
+		{"a\nb\nc", 2, "b\nc"},
+		{"a\nb\nc\n", 0, ""},
+		{"a\nb\nc\n", 5, "a\nb\nc\n"},
+		{"a\nb\nc\n", -1, "a\nb\nc\n"},
+		{"", 3, ""},
+		{"a\n" + long + "\nb\n", 2, long + "\nb\n"},
+	}
+	for _, v := range tt {
+		r := strings.NewReader(v.content)
+		off, err := Offset(r, v.n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if found := v.content[off:]; found != v.exp {
+			t.Fatalf("Tail %d of %q: wanted %q, found %q", v.n, v.content, v.exp, found)
+		}
+	}
+}
+
+func TestReader_Follow(t *testing.T) {
+	t.Parallel()
+
+	r := strings.NewReader("a\nb\n")
+	ctx, cancel := context.WithTimeout(context.Background(), PollInterval*2)
+	defer cancel()
+
+	buf := &bytes.Buffer{}
+	start := time.Now()
+	if err := Reader(ctx, buf, r, Options{Lines: 1, Follow: true}); err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) < PollInterval {
+		t.Fatal("Reader SHOULD have been following the file until cancelation")
+	}
+	if buf.String() != "b\n" {
+		t.Fatalf("Unexpected content: %q", buf.String())
+	}
+}
+
+func TestFile_Compressed(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "stdout")
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte("a\nb\n"))
+	zw.Close()
+	// Further members are appended to the previous ones.
+	zw = gzip.NewWriter(&buf)
+	zw.Write([]byte("c\nd"))
+	zw.Close()
+	if err := os.WriteFile(path+CompressedExt, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []struct {
+		n   int
+		exp string
+	}{
+		{-1, "a\nb\nc\nd"},
+		{0, ""},
+		{2, "c\nd"},
+		{10, "a\nb\nc\nd"},
+	} {
+		out := &bytes.Buffer{}
+		if err := File(context.Background(), out, path, Options{Lines: v.n, Follow: true}); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != v.exp {
+			t.Fatalf("Tail %d: wanted %q, found %q", v.n, v.exp, out)
+		}
+	}
+
+	// Files that were not compressed take precedence.
+	if err := os.WriteFile(path, []byte("e\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := Lines(path, -1); err != nil || string(b) != "e\n" {
+		t.Fatalf("Unexpected content %q: %v", b, err)
+	}
+}
@@ -0,0 +1,27 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+//go:build !linux
+
+package tmux
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+var errNoPTY = errors.New("pseudo terminals are only supported on linux")
+
+func openPTY() (*os.File, *os.File, error) {
+	return nil, nil, errNoPTY
+}
+
+func setSize(f *os.File, cols, rows int) error {
+	return errNoPTY
+}
+
+func ttyProcAttr() *syscall.SysProcAttr {
+	return nil
+}
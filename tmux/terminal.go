@@ -0,0 +1,66 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package tmux
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// Terminal is a tmux client attached to a session inside a pseudo terminal, so
+// that the session can be driven by programs, e.g. on behalf of a remote user.
+// Reading returns the output of the client, writing delivers keystrokes to it.
+type Terminal struct {
+	*os.File
+	cmd *exec.Cmd
+}
+
+// AttachTerminal starts a tmux client attached to session "sid", which must belong
+// to pmux, inside a pseudo terminal of "cols" columns and "rows" rows. Input is
+// ignored if "readOnly" is set.
+func AttachTerminal(sid string, readOnly bool, cols, rows int) (*Terminal, error) {
+	if err := validateSID(sid); err != nil {
+		return nil, fmt.Errorf("cannot attach to session: %w", err)
+	}
+	if !HasSession(sid) {
+		return nil, fmt.Errorf("cannot attach to session: session %v is not running", sid)
+	}
+	m, s, err := openPTY()
+	if err != nil {
+		return nil, fmt.Errorf("cannot attach to session: %w", err)
+	}
+	defer s.Close()
+	if err := setSize(m, cols, rows); err != nil {
+		m.Close()
+		return nil, fmt.Errorf("cannot attach to session: %w", err)
+	}
+
+	args := []string{"attach-session", "-t", sid}
+	if readOnly {
+		args = append(args, "-r")
+	}
+	cmd := exec.Command("tmux", args...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = s, s, s
+	cmd.Env = append(os.Environ(), "TERM=xterm-256color")
+	cmd.SysProcAttr = ttyProcAttr()
+	if err := cmd.Start(); err != nil {
+		m.Close()
+		return nil, fmt.Errorf("cannot attach to session: %w", err)
+	}
+	return &Terminal{File: m, cmd: cmd}, nil
+}
+
+// Resize changes the size of the terminal to "cols" columns and "rows" rows.
+func (t *Terminal) Resize(cols, rows int) error {
+	return setSize(t.File, cols, rows)
+}
+
+// Close detaches the client from the session, which keeps running.
+func (t *Terminal) Close() error {
+	t.cmd.Process.Kill()
+	t.cmd.Wait()
+	return t.File.Close()
+}
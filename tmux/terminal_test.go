@@ -0,0 +1,65 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+//go:build linux
+
+package tmux
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestAttachTerminal(t *testing.T) {
+	t.Parallel()
+
+	sid := NewSID()
+	if err := NewSession(sid, "cat"); err != nil {
+		t.Fatal(err)
+	}
+	defer KillSession(sid)
+
+	term, err := AttachTerminal(sid, false, 80, 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer term.Close()
+	if err := term.Resize(100, 30); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := term.Write([]byte("hello pmux\r")); err != nil {
+		t.Fatal(err)
+	}
+
+	// The pane echoes the input, and cat repeats it.
+	out := make(chan []byte)
+	go func() {
+		acc := []byte{}
+		buf := make([]byte, 4096)
+		for {
+			n, err := term.Read(buf)
+			acc = append(acc, buf[:n]...)
+			if bytes.Count(acc, []byte("hello pmux")) >= 2 || err != nil {
+				out <- acc
+				return
+			}
+		}
+	}()
+	select {
+	case acc := <-out:
+		if bytes.Count(acc, []byte("hello pmux")) < 2 {
+			t.Fatalf("the output of the pane SHOULD be delivered: %q", acc)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("the output of the pane SHOULD be delivered")
+	}
+
+	if err := term.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !HasSession(sid) {
+		t.Fatal("the session SHOULD keep running once the terminal is closed")
+	}
+}
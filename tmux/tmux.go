@@ -8,10 +8,14 @@ package tmux
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"fmt"
 	"log"
+	"os"
 	"os/exec"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/google/uuid"
@@ -20,6 +24,15 @@ import (
 
 const defaultCmdExecTimeout = time.Millisecond * 100
 
+// ExitChannel is the tmux wait channel signaled each time a session belonging
+// to pmux is closed, see "WaitExit".
+const ExitChannel = "pmux-exited"
+
+// exitHook is the global session-closed hook signaling "ExitChannel". Hooks
+// bound to a session are destroyed together with it before they can run, hence
+// a global one is used, at an index unlikely to be taken by those of users.
+const exitHook = "session-closed[4242]"
+
 // verify returns an error if it is not able to find the tmux executable.
 func verify() error {
 	path, err := exec.LookPath("tmux")
@@ -30,6 +43,12 @@ func verify() error {
 	return nil
 }
 
+// Available reports whether the tmux executable can be found.
+func Available() bool {
+	_, err := exec.LookPath("tmux")
+	return err == nil
+}
+
 // Version returns tmux version. Returns an error only if the command cannot
 // be executed, does not check the output produced.
 func Version() (string, error) {
@@ -68,6 +87,42 @@ func NewSession(sid, name string, args ...string) error {
 	if err := pipe.RunTimeout(p, defaultCmdExecTimeout); err != nil {
 		return fmt.Errorf("unable to create new tmux session: %w", err)
 	}
+	// The hook is installed once the server is surely running. It is lost
+	// when the server exits, together with its last session.
+	if Supports(FeatureExitHook) != nil {
+		return nil
+	}
+	if err := setExitHook(); err != nil {
+		log.Printf("[WARN] exits of tmux sessions will not be notified: %v", err)
+	}
+	return nil
+}
+
+// setExitHook installs the hook signaling "ExitChannel" when a session belonging
+// to pmux is closed, unless it is already installed.
+func setExitHook() error {
+	cmd := fmt.Sprintf("if-shell -F '#{m:pmux-*,#{hook_session_name}}' 'wait-for -S %s'", ExitChannel)
+	p := pipe.Exec("tmux", "set-hook", "-g", exitHook, cmd)
+	if _, stderr, err := pipe.DividedOutputTimeout(p, defaultCmdExecTimeout); err != nil {
+		return fmt.Errorf("unable to set session-closed hook: %w, %s", err, bytes.TrimSpace(stderr))
+	}
+	return nil
+}
+
+// WaitExit blocks until a session belonging to pmux is closed, or until "ctx" is
+// done. Sessions closed while nobody waits are reported to the next call, at once
+// for all of them. An error is returned if no tmux server is running, e.g. after
+// the last session was closed, or if the server exits in the meantime: sessions
+// may then close without being reported. The error wraps "ErrUnsupported" if
+// tmux does not support "FeatureExitHook".
+func WaitExit(ctx context.Context) error {
+	if err := Supports(FeatureExitHook); err != nil {
+		return fmt.Errorf("unable to wait for session exits: %w", err)
+	}
+	out, err := exec.CommandContext(ctx, "tmux", "wait-for", ExitChannel).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("unable to wait for session exits: %w, %s", err, bytes.TrimSpace(out))
+	}
 	return nil
 }
 
@@ -92,6 +147,10 @@ func ListSessions() ([]string, error) {
 	acc := []string{}
 
 	stdout, stderr, err := pipe.DividedOutputTimeout(p, defaultCmdExecTimeout)
+	if err != nil && noServer(stderr) {
+		// The tmux server exits as soon as its last session does.
+		return acc, nil
+	}
 	if err != nil {
 		return acc, fmt.Errorf("unable to list tmux sessions: %w, %v", err, string(stderr))
 	}
@@ -116,9 +175,107 @@ func ListSessions() ([]string, error) {
 	return acc, nil
 }
 
+// noServer reports whether the "stderr" output of a tmux command says that no
+// tmux server is running.
+func noServer(stderr []byte) bool {
+	return bytes.Contains(stderr, []byte("no server running")) || bytes.Contains(stderr, []byte("error connecting to"))
+}
+
 // HasSession returns true if tmux is running a session named "sid".
 func HasSession(sid string) bool {
 	p := pipe.Exec("tmux", "has-session", "-t", sid)
 	err := pipe.RunTimeout(p, defaultCmdExecTimeout)
 	return err == nil
 }
+
+// SignalSession delivers "sig" to the process running inside the session's pane,
+// i.e. the executable that was passed to `NewSession`. If the session identifier
+// does not belong to pmux returns an error.
+func SignalSession(sid string, sig syscall.Signal) error {
+	if err := validateSID(sid); err != nil {
+		return fmt.Errorf("cannot signal session: %w", err)
+	}
+	pid, err := PanePID(sid)
+	if err != nil {
+		return fmt.Errorf("cannot signal session: %w", err)
+	}
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return fmt.Errorf("unable to find session process %d: %w", pid, err)
+	}
+	if err := proc.Signal(sig); err != nil {
+		return fmt.Errorf("unable to signal session process %d: %w", pid, err)
+	}
+	return nil
+}
+
+// PanePID returns the PID of the process running in the first pane of session
+// "sid", i.e. the executable that was passed to `NewSession`.
+func PanePID(sid string) (int, error) {
+	if err := validateSID(sid); err != nil {
+		return 0, err
+	}
+	p := pipe.Exec("tmux", "list-panes", "-t", sid, "-F", "#{pane_pid}")
+	out, err := pipe.OutputTimeout(p, defaultCmdExecTimeout)
+	if err != nil {
+		return 0, fmt.Errorf("unable to list session panes: %w", err)
+	}
+	line := strings.SplitN(strings.TrimSpace(string(out)), "\n", 2)[0]
+	pid, err := strconv.Atoi(line)
+	if err != nil {
+		return 0, fmt.Errorf("unable to parse pane pid %q: %w", line, err)
+	}
+	return pid, nil
+}
+
+// CapturePane returns the text currently displayed by the pane of session "sid",
+// preceded by up to "history" lines of its scrollback. With "escapes" set, the
+// text keeps the escape sequences of its colours and attributes, as long as tmux
+// supports "FeatureCaptureEscapes".
+func CapturePane(sid string, history int, escapes bool) ([]byte, error) {
+	if err := validateSID(sid); err != nil {
+		return nil, fmt.Errorf("cannot capture pane: %w", err)
+	}
+	if escapes {
+		if err := Supports(FeatureCaptureEscapes); err != nil {
+			return nil, fmt.Errorf("cannot capture pane: %w", err)
+		}
+	}
+	args := []string{"capture-pane", "-p", "-t", sid}
+	if history > 0 {
+		args = append(args, "-S", strconv.Itoa(-history))
+	}
+	if escapes {
+		args = append(args, "-e")
+	}
+	p := pipe.Exec("tmux", args...)
+	out, err := pipe.OutputTimeout(p, defaultCmdExecTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("unable to capture pane: %w", err)
+	}
+	return out, nil
+}
+
+// Attach replaces the current process with a tmux client attached to session
+// "sid", which must belong to pmux. Input is ignored if "readOnly" is set. Attach
+// returns only if the client could not be started.
+func Attach(sid string, readOnly bool) error {
+	if err := validateSID(sid); err != nil {
+		return fmt.Errorf("cannot attach to session: %w", err)
+	}
+	if !HasSession(sid) {
+		return fmt.Errorf("cannot attach to session: session %v is not running", sid)
+	}
+	path, err := exec.LookPath("tmux")
+	if err != nil {
+		return fmt.Errorf("tmux is not available: %w", err)
+	}
+	args := []string{"tmux", "attach-session", "-t", sid}
+	if readOnly {
+		args = append(args, "-r")
+	}
+	if err := syscall.Exec(path, args, os.Environ()); err != nil {
+		return fmt.Errorf("unable to attach to session: %w", err)
+	}
+	return nil
+}
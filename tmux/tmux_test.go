@@ -6,8 +6,13 @@
 package tmux
 
 import (
+	"bytes"
+	"context"
+	"os"
 	"strings"
+	"syscall"
 	"testing"
+	"time"
 )
 
 func TestHasSession(t *testing.T) {
@@ -35,6 +40,22 @@ func TestHasSession(t *testing.T) {
 	}
 }
 
+func TestWaitExit(t *testing.T) {
+	t.Parallel()
+
+	sid := NewSID()
+	if err := NewSession(sid, "sleep", "1"); err != nil {
+		t.Fatal(err)
+	}
+	defer KillSession(sid)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := WaitExit(ctx); err != nil {
+		t.Fatalf("Session exits SHOULD be notified: %v", err)
+	}
+}
+
 func TestVersion(t *testing.T) {
 	t.Parallel()
 
@@ -67,3 +88,83 @@ func TestValidateSID(t *testing.T) {
 		t.Fatalf("Expected sid validation error for <%v>", sid)
 	}
 }
+
+func TestCapturePane(t *testing.T) {
+	t.Parallel()
+
+	sid := NewSID()
+	if err := NewSession(sid, "sh", "-c", "seq 1 100; sleep 60"); err != nil {
+		t.Fatal(err)
+	}
+	defer KillSession(sid)
+
+	var screen []byte
+	for i := 0; i < 20; i++ {
+		var err error
+		if screen, err = CapturePane(sid, 0, false); err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(screen, []byte("100\n")) {
+			break
+		}
+		time.Sleep(time.Millisecond * 50)
+	}
+	if !bytes.Contains(screen, []byte("100\n")) || bytes.HasPrefix(screen, []byte("1\n")) {
+		t.Fatalf("screen SHOULD show the last lines only: %q", screen)
+	}
+	history, err := CapturePane(sid, 1000, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(history, []byte("1\n2\n")) {
+		t.Fatalf("history SHOULD include the first lines: %q", history)
+	}
+	if _, err := CapturePane("base", 0, false); err == nil {
+		t.Fatal("sessions not belonging to pmux SHOULD NOT be captured")
+	}
+}
+
+func TestPanePID(t *testing.T) {
+	t.Parallel()
+
+	sid := NewSID()
+	if err := NewSession(sid, "sleep", "60"); err != nil {
+		t.Fatal(err)
+	}
+	defer KillSession(sid)
+
+	pid, err := PanePID(sid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := proc.Signal(syscall.Signal(0)); err != nil {
+		t.Fatalf("Pane process %d SHOULD be running: %v", pid, err)
+	}
+	if _, err := PanePID("base"); err == nil {
+		t.Fatal("sessions not belonging to pmux SHOULD NOT be inspected")
+	}
+}
+
+func TestSignalSession(t *testing.T) {
+	t.Parallel()
+
+	sid := NewSID()
+	if err := NewSession(sid, "sleep", "60"); err != nil {
+		t.Fatal(err)
+	}
+	defer KillSession(sid)
+
+	if err := SignalSession(sid, syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20 && HasSession(sid); i++ {
+		time.Sleep(time.Millisecond * 50)
+	}
+	if HasSession(sid) {
+		t.Fatalf("Session <%s> SHOULD NOT BE present after SIGTERM", sid)
+	}
+}
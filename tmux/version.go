@@ -0,0 +1,109 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package tmux
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrUnsupported is returned by the operations that the installed tmux does not
+// support, see "Supports".
+var ErrUnsupported = errors.New("not supported by the installed tmux")
+
+// Info is a parsed tmux version.
+type Info struct {
+	Major, Minor int
+	// Devel is set for development builds, e.g. "master", and for the
+	// builds of OpenBSD, which do not carry a version number: they are
+	// assumed to support every feature.
+	Devel bool
+	// Raw is the version as reported by tmux, e.g. "3.3a".
+	Raw string
+}
+
+// AtLeast reports whether "v" is version "major"."minor" or a later one.
+func (v Info) AtLeast(major, minor int) bool {
+	if v.Devel {
+		return true
+	}
+	return v.Major > major || (v.Major == major && v.Minor >= minor)
+}
+
+func (v Info) String() string {
+	return v.Raw
+}
+
+var versionRe = regexp.MustCompile(`^(?:next-)?(\d+)\.(\d+)[a-z]?(?:-rc\d*)?$`)
+
+// parseVersion parses the output of "tmux -V", e.g. "tmux 3.3a", "tmux next-3.4"
+// or "tmux master".
+func parseVersion(s string) (Info, error) {
+	raw := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(s), "tmux "))
+	if raw == "master" || strings.HasPrefix(raw, "openbsd-") {
+		return Info{Devel: true, Raw: raw}, nil
+	}
+	m := versionRe.FindStringSubmatch(raw)
+	if m == nil {
+		return Info{}, fmt.Errorf("unable to parse tmux version %q", s)
+	}
+	major, _ := strconv.Atoi(m[1])
+	minor, _ := strconv.Atoi(m[2])
+	return Info{Major: major, Minor: minor, Raw: raw}, nil
+}
+
+var version struct {
+	once sync.Once
+	info Info
+	err  error
+}
+
+// VersionInfo returns the parsed version of the installed tmux, which is detected
+// once.
+func VersionInfo() (Info, error) {
+	version.once.Do(func() {
+		var v string
+		if v, version.err = Version(); version.err == nil {
+			version.info, version.err = parseVersion(v)
+		}
+	})
+	return version.info, version.err
+}
+
+// Feature is a capability of tmux that depends on its version.
+type Feature struct {
+	Name         string
+	Major, Minor int
+}
+
+var (
+	// FeatureExitHook is the global hook notifying the exits of sessions,
+	// see "WaitExit", which requires hooks to be array options.
+	FeatureExitHook = Feature{Name: "session exit notifications", Major: 3, Minor: 0}
+	// FeatureCaptureEscapes is the capture of panes keeping their escape
+	// sequences, see "CapturePane".
+	FeatureCaptureEscapes = Feature{Name: "capture of escape sequences", Major: 1, Minor: 8}
+)
+
+// Supports returns an error wrapping "ErrUnsupported" if the installed tmux is
+// older than required by feature "f", or if its version cannot be detected.
+func Supports(f Feature) error {
+	v, err := VersionInfo()
+	if err != nil {
+		return fmt.Errorf("%s: %w: %v", f.Name, ErrUnsupported, err)
+	}
+	return v.supports(f)
+}
+
+func (v Info) supports(f Feature) error {
+	if !v.AtLeast(f.Major, f.Minor) {
+		return fmt.Errorf("%s: %w: tmux %d.%d or later is required, found %s", f.Name, ErrUnsupported, f.Major, f.Minor, v)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package tmux
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	t.Parallel()
+
+	for i, tt := range []struct {
+		in           string
+		major, minor int
+		devel, err   bool
+	}{
+		{in: "tmux 3.3a", major: 3, minor: 3},
+		{in: "tmux 2.9\n", major: 2, minor: 9},
+		{in: "tmux next-3.4", major: 3, minor: 4},
+		{in: "tmux 3.4-rc", major: 3, minor: 4},
+		{in: "tmux 3.4-rc2", major: 3, minor: 4},
+		{in: "tmux master", devel: true},
+		{in: "tmux openbsd-7.3", devel: true},
+		{in: "tmux", err: true},
+		{in: "screen 4.9", err: true},
+	} {
+		v, err := parseVersion(tt.in)
+		if tt.err {
+			if err == nil {
+				t.Fatalf("%d: parsing %q SHOULD fail, found %+v", i, tt.in, v)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		if v.Major != tt.major || v.Minor != tt.minor || v.Devel != tt.devel {
+			t.Fatalf("%d: %q SHOULD be parsed as %d.%d (devel %t), found %+v", i, tt.in, tt.major, tt.minor, tt.devel, v)
+		}
+	}
+}
+
+func TestInfo_Supports(t *testing.T) {
+	t.Parallel()
+
+	for i, tt := range []struct {
+		v  Info
+		ok bool
+	}{
+		{Info{Major: 2, Minor: 9, Raw: "2.9"}, false},
+		{Info{Major: 3, Minor: 0, Raw: "3.0"}, true},
+		{Info{Major: 3, Minor: 3, Raw: "3.3a"}, true},
+		{Info{Major: 4, Minor: 0, Raw: "4.0"}, true},
+		{Info{Devel: true, Raw: "master"}, true},
+	} {
+		err := tt.v.supports(FeatureExitHook)
+		if tt.ok != (err == nil) {
+			t.Fatalf("%d: unexpected support of tmux %v: %v", i, tt.v, err)
+		}
+		if err != nil && !errors.Is(err, ErrUnsupported) {
+			t.Fatalf("%d: error SHOULD wrap ErrUnsupported: %v", i, err)
+		}
+	}
+}
+
+func TestVersionInfo(t *testing.T) {
+	t.Parallel()
+
+	v, err := VersionInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !v.AtLeast(1, 0) {
+		t.Fatalf("Unexpected tmux version %+v", v)
+	}
+}
@@ -0,0 +1,211 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package trace
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// batchInterval is the maximum time a span waits before being exported.
+	batchInterval = time.Second * 5
+	// batchSize is the number of spans that triggers an export.
+	batchSize = 256
+	// exportTimeout is the time given to the collector to accept a batch.
+	exportTimeout = time.Second * 10
+)
+
+// tracesPath is the path of the OTLP/HTTP traces route.
+const tracesPath = "/v1/traces"
+
+type exporter struct {
+	mu       sync.Mutex
+	endpoint string
+	service  string
+	spans    []*Span
+	timer    *time.Timer
+}
+
+var defaultExporter = &exporter{}
+
+// SetEndpoint sets the OTLP/HTTP collector spans are exported to, e.g.
+// "http://localhost:4318", and the name of the service reported. Spans are
+// dropped when "endpoint" is empty.
+func SetEndpoint(endpoint, service string) {
+	e := defaultExporter
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.endpoint = endpoint
+	e.service = service
+}
+
+// Endpoint returns the collector spans are exported to, if any.
+func Endpoint() string {
+	e := defaultExporter
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.endpoint
+}
+
+// Flush exports the spans that are still pending. It has to be called before
+// the program exits.
+func Flush(ctx context.Context) error {
+	return defaultExporter.flush(ctx)
+}
+
+func (e *exporter) export(s *Span) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.endpoint == "" {
+		return
+	}
+	e.spans = append(e.spans, s)
+	if len(e.spans) >= batchSize {
+		go e.flush(context.Background())
+		return
+	}
+	if e.timer == nil {
+		e.timer = time.AfterFunc(batchInterval, func() {
+			e.flush(context.Background())
+		})
+	}
+}
+
+func (e *exporter) flush(ctx context.Context) error {
+	e.mu.Lock()
+	spans, endpoint, service := e.spans, e.endpoint, e.service
+	e.spans = nil
+	if e.timer != nil {
+		e.timer.Stop()
+		e.timer = nil
+	}
+	e.mu.Unlock()
+	if len(spans) == 0 || endpoint == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, exportTimeout)
+	defer cancel()
+	if err := post(ctx, endpoint, encode(service, spans)); err != nil {
+		log.Printf("[WARN] unable to export %d spans: %v", len(spans), err)
+		return err
+	}
+	return nil
+}
+
+func post(ctx context.Context, endpoint string, payload interface{}) error {
+	buf := bytes.Buffer{}
+	if err := json.NewEncoder(&buf).Encode(payload); err != nil {
+		return err
+	}
+	url := strings.TrimSuffix(endpoint, "/")
+	if !strings.HasSuffix(url, tracesPath) {
+		url += tracesPath
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector returned status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+type keyValue struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue string `json:"stringValue"`
+	} `json:"value"`
+}
+
+func attributes(m map[string]string) []keyValue {
+	acc := make([]keyValue, 0, len(m))
+	for k, v := range m {
+		kv := keyValue{Key: k}
+		kv.Value.StringValue = v
+		acc = append(acc, kv)
+	}
+	sort.Slice(acc, func(i, j int) bool { return acc[i].Key < acc[j].Key })
+	return acc
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID      string      `json:"traceId"`
+	SpanID       string      `json:"spanId"`
+	ParentSpanID string      `json:"parentSpanId,omitempty"`
+	Name         string      `json:"name"`
+	Kind         int         `json:"kind"`
+	Start        string      `json:"startTimeUnixNano"`
+	End          string      `json:"endTimeUnixNano"`
+	Attributes   []keyValue  `json:"attributes,omitempty"`
+	Status       *otlpStatus `json:"status,omitempty"`
+}
+
+// encode returns the OTLP "ExportTraceServiceRequest" carrying "spans".
+func encode(service string, spans []*Span) interface{} {
+	acc := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		s.mu.Lock()
+		v := otlpSpan{
+			TraceID: hex.EncodeToString(s.ctx.TraceID[:]),
+			SpanID:  hex.EncodeToString(s.ctx.SpanID[:]),
+			Name:    s.name,
+			// SPAN_KIND_INTERNAL
+			Kind:       1,
+			Start:      strconv.FormatInt(s.start.UnixNano(), 10),
+			End:        strconv.FormatInt(s.end.UnixNano(), 10),
+			Attributes: attributes(s.attrs),
+		}
+		if s.parent != [8]byte{} {
+			v.ParentSpanID = hex.EncodeToString(s.parent[:])
+		}
+		if s.err != "" {
+			// STATUS_CODE_ERROR
+			v.Status = &otlpStatus{Code: 2, Message: s.err}
+		}
+		s.mu.Unlock()
+		acc = append(acc, v)
+	}
+	if service == "" {
+		service = "pmux"
+	}
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": attributes(map[string]string{"service.name": service}),
+			},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]string{"name": "github.com/kim-company/pmux"},
+				"spans": acc,
+			}},
+		}},
+	}
+}
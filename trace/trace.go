@@ -0,0 +1,167 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+// Package trace provides a minimal OpenTelemetry tracer: spans are propagated
+// using the W3C Trace Context "traceparent" format, and exported to an OTLP/HTTP
+// collector, encoded as JSON. Spans are dropped when no endpoint is set.
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Header is the HTTP header carrying the trace context.
+	Header = "traceparent"
+	// EnvTraceparent is the environment variable carrying the trace context
+	// to child processes.
+	EnvTraceparent = "TRACEPARENT"
+)
+
+// SpanContext identifies a span inside its trace.
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+}
+
+// IsValid reports whether "c" identifies a span.
+func (c SpanContext) IsValid() bool {
+	return c.TraceID != [16]byte{} && c.SpanID != [8]byte{}
+}
+
+// Traceparent returns "c" in the W3C "traceparent" format, or an empty string if
+// "c" is not valid.
+func (c SpanContext) Traceparent() string {
+	if !c.IsValid() {
+		return ""
+	}
+	return "00-" + hex.EncodeToString(c.TraceID[:]) + "-" + hex.EncodeToString(c.SpanID[:]) + "-01"
+}
+
+// ParseTraceparent parses a span context in the W3C "traceparent" format.
+func ParseTraceparent(s string) (SpanContext, error) {
+	var c SpanContext
+	fields := strings.Split(strings.TrimSpace(s), "-")
+	if len(fields) < 4 || len(fields[0]) != 2 || fields[0] == "ff" {
+		return c, fmt.Errorf("invalid traceparent %q", s)
+	}
+	tid, err := hex.DecodeString(fields[1])
+	if err != nil || len(tid) != len(c.TraceID) {
+		return c, fmt.Errorf("invalid trace id in traceparent %q", s)
+	}
+	sid, err := hex.DecodeString(fields[2])
+	if err != nil || len(sid) != len(c.SpanID) {
+		return c, fmt.Errorf("invalid span id in traceparent %q", s)
+	}
+	copy(c.TraceID[:], tid)
+	copy(c.SpanID[:], sid)
+	if !c.IsValid() {
+		return c, fmt.Errorf("invalid traceparent %q", s)
+	}
+	return c, nil
+}
+
+type contextKey struct{}
+
+// ContextWith returns a copy of "ctx" carrying "c", which becomes the parent of
+// the spans started from it. "ctx" is returned unchanged if "c" is not valid.
+func ContextWith(ctx context.Context, c SpanContext) context.Context {
+	if !c.IsValid() {
+		return ctx
+	}
+	return context.WithValue(ctx, contextKey{}, c)
+}
+
+// FromContext returns the span context carried by "ctx", if any.
+func FromContext(ctx context.Context) SpanContext {
+	c, _ := ctx.Value(contextKey{}).(SpanContext)
+	return c
+}
+
+// Inject sets the trace context carried by "ctx" in "h".
+func Inject(ctx context.Context, h http.Header) {
+	if tp := FromContext(ctx).Traceparent(); tp != "" {
+		h.Set(Header, tp)
+	}
+}
+
+// Extract returns a copy of "ctx" carrying the trace context found in "h", if
+// valid.
+func Extract(ctx context.Context, h http.Header) context.Context {
+	c, err := ParseTraceparent(h.Get(Header))
+	if err != nil {
+		return ctx
+	}
+	return ContextWith(ctx, c)
+}
+
+// Span is an operation being traced.
+type Span struct {
+	mu     sync.Mutex
+	ctx    SpanContext
+	parent [8]byte
+	name   string
+	start  time.Time
+	end    time.Time
+	attrs  map[string]string
+	err    string
+	ended  bool
+}
+
+// Start starts a span named "name", child of the span carried by "ctx" or
+// starting a new trace otherwise. The returned context carries the new span.
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	s := &Span{name: name, start: time.Now(), attrs: map[string]string{}}
+	parent := FromContext(ctx)
+	if parent.IsValid() {
+		s.ctx.TraceID = parent.TraceID
+		s.parent = parent.SpanID
+	} else {
+		rand.Read(s.ctx.TraceID[:])
+	}
+	rand.Read(s.ctx.SpanID[:])
+	return ContextWith(ctx, s.ctx), s
+}
+
+// Context returns the span context of "s".
+func (s *Span) Context() SpanContext {
+	return s.ctx
+}
+
+// SetAttribute records the attribute "key" of "s".
+func (s *Span) SetAttribute(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attrs[key] = value
+}
+
+// SetError marks "s" as failed because of "err", if not nil.
+func (s *Span) SetError(err error) {
+	if err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err.Error()
+}
+
+// End completes "s", handing it to the exporter. Further calls do nothing.
+func (s *Span) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mu.Unlock()
+	defaultExporter.export(s)
+}
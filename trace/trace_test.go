@@ -0,0 +1,99 @@
+// SPDX-FileCopyrightText: 2019 KIM KeepInMind GmbH
+//
+// SPDX-License-Identifier: MIT
+
+package trace
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	t.Parallel()
+	tp := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	c, err := ParseTraceparent(tp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Traceparent() != tp {
+		t.Fatalf("unexpected traceparent: wanted %q, found %q", tp, c.Traceparent())
+	}
+	for _, v := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba9-01",
+	} {
+		if _, err := ParseTraceparent(v); err == nil {
+			t.Fatalf("expected an error parsing %q", v)
+		}
+	}
+}
+
+func TestStart(t *testing.T) {
+	t.Parallel()
+	ctx, root := Start(context.Background(), "root")
+	if !root.Context().IsValid() {
+		t.Fatal("root span context is not valid")
+	}
+	if root.parent != [8]byte{} {
+		t.Fatal("root span has a parent")
+	}
+
+	h := http.Header{}
+	Inject(ctx, h)
+	_, child := Start(Extract(context.Background(), h), "child")
+	if child.Context().TraceID != root.Context().TraceID {
+		t.Fatal("child span is not part of the trace of its parent")
+	}
+	if child.parent != root.Context().SpanID {
+		t.Fatal("child span does not descend from its parent")
+	}
+	if child.Context().SpanID == root.Context().SpanID {
+		t.Fatal("child span shares the identifier of its parent")
+	}
+}
+
+func TestFlush(t *testing.T) {
+	var payload struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []otlpSpan `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != tracesPath {
+			t.Errorf("unexpected path: %v", r.URL.Path)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer srv.Close()
+
+	SetEndpoint(srv.URL, "test")
+	defer SetEndpoint("", "")
+	_, span := Start(context.Background(), "span")
+	span.SetAttribute("key", "value")
+	span.End()
+	if err := Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(payload.ResourceSpans) != 1 || len(payload.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("unexpected payload: %+v", payload)
+	}
+	spans := payload.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 1 {
+		t.Fatalf("unexpected number of spans exported: %d", len(spans))
+	}
+	if spans[0].Name != "span" || len(spans[0].Attributes) != 1 {
+		t.Fatalf("unexpected span exported: %+v", spans[0])
+	}
+}